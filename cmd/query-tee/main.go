@@ -50,8 +50,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	routes, err := mimirReadRoutes(cfg)
+	if err != nil {
+		level.Error(util_log.Logger).Log("msg", "Unable to initialize the proxy routes", "err", err.Error())
+		os.Exit(1)
+	}
+
 	// Run the proxy.
-	proxy, err := querytee.NewProxy(cfg.ProxyConfig, util_log.Logger, mimirReadRoutes(cfg), registry)
+	proxy, err := querytee.NewProxy(cfg.ProxyConfig, util_log.Logger, routes, registry)
 	if err != nil {
 		level.Error(util_log.Logger).Log("msg", "Unable to initialize the proxy", "err", err.Error())
 		os.Exit(1)
@@ -65,7 +71,7 @@ func main() {
 	proxy.Await()
 }
 
-func mimirReadRoutes(cfg Config) []querytee.Route {
+func mimirReadRoutes(cfg Config) ([]querytee.Route, error) {
 	prefix := cfg.PathPrefix
 
 	// Strip trailing slashes.
@@ -73,20 +79,30 @@ func mimirReadRoutes(cfg Config) []querytee.Route {
 		prefix = prefix[:len(prefix)-1]
 	}
 
-	samplesComparator := querytee.NewSamplesComparator(querytee.SampleComparisonOptions{
+	ignoreSeries, err := querytee.ParseSeriesSelectors(cfg.ProxyConfig.CompareIgnoreSeries)
+	if err != nil {
+		return nil, err
+	}
+
+	comparisonOpts := querytee.SampleComparisonOptions{
 		Tolerance:         cfg.ProxyConfig.ValueComparisonTolerance,
 		UseRelativeError:  cfg.ProxyConfig.UseRelativeError,
 		SkipRecentSamples: cfg.ProxyConfig.SkipRecentSamples,
-	})
+		IgnoreSeries:      ignoreSeries,
+	}
+	samplesComparator := querytee.NewSamplesComparator(comparisonOpts)
+	labelsComparator := querytee.NewLabelsComparator()
+	seriesComparator := querytee.NewSeriesComparator(comparisonOpts)
+
 	return []querytee.Route{
 		{Path: prefix + "/api/v1/query", RouteName: "api_v1_query", Methods: []string{"GET", "POST"}, ResponseComparator: samplesComparator},
 		{Path: prefix + "/api/v1/query_range", RouteName: "api_v1_query_range", Methods: []string{"GET", "POST"}, ResponseComparator: samplesComparator},
 		{Path: prefix + "/api/v1/query_exemplars", RouteName: "api_v1_query_exemplars", Methods: []string{"GET", "POST"}, ResponseComparator: nil},
-		{Path: prefix + "/api/v1/labels", RouteName: "api_v1_labels", Methods: []string{"GET", "POST"}, ResponseComparator: nil},
-		{Path: prefix + "/api/v1/label/{name}/values", RouteName: "api_v1_label_name_values", Methods: []string{"GET", "POST"}, ResponseComparator: nil},
-		{Path: prefix + "/api/v1/series", RouteName: "api_v1_series", Methods: []string{"GET", "POST"}, ResponseComparator: nil},
+		{Path: prefix + "/api/v1/labels", RouteName: "api_v1_labels", Methods: []string{"GET", "POST"}, ResponseComparator: labelsComparator},
+		{Path: prefix + "/api/v1/label/{name}/values", RouteName: "api_v1_label_name_values", Methods: []string{"GET", "POST"}, ResponseComparator: labelsComparator},
+		{Path: prefix + "/api/v1/series", RouteName: "api_v1_series", Methods: []string{"GET", "POST"}, ResponseComparator: seriesComparator},
 		{Path: prefix + "/api/v1/metadata", RouteName: "api_v1_metadata", Methods: []string{"GET", "POST"}, ResponseComparator: nil},
 		{Path: prefix + "/prometheus/config/v1/rules", RouteName: "prometheus_config_v1_rules", Methods: []string{"GET", "POST"}, ResponseComparator: nil},
 		{Path: prefix + "/api/v1/alerts", RouteName: "api_v1_alerts", Methods: []string{"GET", "POST"}, ResponseComparator: nil},
-	}
+	}, nil
 }
@@ -10,16 +10,24 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/tools/querytee"
 )
 
 func TestMimirReadRoutes(t *testing.T) {
-	routes := mimirReadRoutes(Config{PathPrefix: ""})
+	routes, err := mimirReadRoutes(Config{PathPrefix: ""})
+	require.NoError(t, err)
 	for _, r := range routes {
 		assert.True(t, strings.HasPrefix(r.Path, "/api/v1/") || strings.HasPrefix(r.Path, "/prometheus/"))
 	}
 
-	routes = mimirReadRoutes(Config{PathPrefix: "/some/random/prefix///"})
+	routes, err = mimirReadRoutes(Config{PathPrefix: "/some/random/prefix///"})
+	require.NoError(t, err)
 	for _, r := range routes {
 		assert.Regexp(t, "/some/random/prefix/[a-z].*", r.Path)
 	}
+
+	_, err = mimirReadRoutes(Config{ProxyConfig: querytee.ProxyConfig{CompareIgnoreSeries: []string{`{job=`}}})
+	require.Error(t, err)
 }
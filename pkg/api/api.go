@@ -343,12 +343,16 @@ func (a *API) RegisterCompactor(c *compactor.MultitenantCompactor) {
 		{Desc: "Ring status", Path: "/compactor/ring"},
 	})
 	a.RegisterRoute("/compactor/ring", http.HandlerFunc(c.RingHandler), false, true, "GET", "POST")
+	a.RegisterRoute("/compactor/tenant/{tenant}/planned_jobs", http.HandlerFunc(c.PlannedJobsHandler), false, true, "GET")
 	a.RegisterRoute("/api/v1/upload/block/{block}/start", http.HandlerFunc(c.StartBlockUpload), true, false, http.MethodPost)
 	a.RegisterRoute("/api/v1/upload/block/{block}/files", http.HandlerFunc(c.UploadBlockFile), true, false, http.MethodPost)
 	a.RegisterRoute("/api/v1/upload/block/{block}/finish", http.HandlerFunc(c.FinishBlockUpload), true, false, http.MethodPost)
 	a.RegisterRoute("/api/v1/upload/block/{block}/check", http.HandlerFunc(c.GetBlockUploadStateHandler), true, false, http.MethodGet)
 	a.RegisterRoute("/compactor/delete_tenant", http.HandlerFunc(c.DeleteTenant), true, true, "POST")
 	a.RegisterRoute("/compactor/delete_tenant_status", http.HandlerFunc(c.DeleteTenantStatus), true, true, "GET")
+	a.RegisterRoute("/compactor/rewrite_jobs", http.HandlerFunc(c.SubmitRewriteJob), true, true, http.MethodPost)
+	a.RegisterRoute("/compactor/rewrite_jobs", http.HandlerFunc(c.ListRewriteJobs), true, true, http.MethodGet)
+	a.RegisterRoute("/compactor/rewrite_jobs/{job}", http.HandlerFunc(c.GetRewriteJob), true, true, http.MethodGet)
 }
 
 type Distributor interface {
@@ -393,6 +397,12 @@ func (a *API) RegisterQueryFrontend1(f *frontendv1.Frontend) {
 }
 
 func (a *API) RegisterQueryFrontend2(f *frontendv2.Frontend) {
+	a.indexPage.AddLinks(defaultWeight, "Query-frontend", []IndexPageLink{
+		{Desc: "Active queries", Path: path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/status/active_queries")},
+	})
+	a.RegisterRoute(path.Join(a.cfg.PrometheusHTTPPrefix, "/api/v1/status/active_queries"), http.HandlerFunc(f.ActiveQueriesHandler), true, true, "GET")
+	a.RegisterRoute("/query-frontend/cancel_query", http.HandlerFunc(f.CancelActiveQueryHandler), true, true, "POST")
+
 	frontendv2pb.RegisterFrontendForQuerierServer(a.server.GRPC, f)
 }
 
@@ -7,8 +7,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"os"
 	"path"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log"
@@ -16,10 +20,16 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/oklog/ulid"
 	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
 	"github.com/thanos-io/objstore"
 	"github.com/thanos-io/thanos/pkg/block"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
 
+	"github.com/grafana/dskit/runutil"
 	"github.com/grafana/dskit/tenant"
 	"github.com/grafana/regexp"
 
@@ -28,6 +38,7 @@ import (
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/util"
 	util_log "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 // Name of file where we store a block's meta file while it's being uploaded.
@@ -72,7 +83,8 @@ func (c *MultitenantCompactor) StartBlockUpload(w http.ResponseWriter, r *http.R
 // FinishBlockUpload handles request for finishing block upload.
 //
 // Finishing block upload performs block validation, and if all checks pass, marks block as finished
-// by uploading meta.json file.
+// by uploading meta.json file. If the full block validation is enabled for the tenant, the validation
+// runs asynchronously, and its outcome is reported by GetBlockUploadStateHandler.
 func (c *MultitenantCompactor) FinishBlockUpload(w http.ResponseWriter, r *http.Request) {
 	blockID, tenantID, err := c.parseBlockUploadParameters(r)
 	if err != nil {
@@ -99,6 +111,28 @@ func (c *MultitenantCompactor) FinishBlockUpload(w http.ResponseWriter, r *http.
 		return
 	}
 
+	if c.cfgProvider.CompactorBlockUploadValidationEnabled(tenantID) {
+		if !c.blockUploadValidations.reserve() {
+			http.Error(w, "too many block upload validations in progress, try again later", http.StatusTooManyRequests)
+			return
+		}
+
+		// Create the validation file to signal that the block validation has started.
+		if err := c.uploadValidation(ctx, blockID, userBkt, validationFile{LastUpdate: time.Now().UnixMilli()}); err != nil {
+			c.blockUploadValidations.release()
+			writeBlockUploadError(err, op, "while creating validation file", logger, w)
+			return
+		}
+
+		meta := *m
+		c.blockUploadValidations.run(func(ctx context.Context) {
+			c.validateAndCompleteBlockUpload(ctx, logger, tenantID, userBkt, blockID, meta)
+		})
+
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	if err := c.completeBlockUpload(ctx, logger, userBkt, blockID, *m); err != nil {
 		writeBlockUploadError(err, op, "", logger, w)
 		return
@@ -393,11 +427,22 @@ func (r bodyReader) Read(b []byte) (int, error) {
 }
 
 type validationFile struct {
-	LastUpdate int64  // UnixMillis of last update time.
-	Error      string // Error message if validation failed.
+	LastUpdate int64    // UnixMillis of last update time.
+	Error      string   // Error message if validation failed.
+	Issues     []string `json:",omitempty"` // Issues found in the block, if validation failed.
 }
 
-const validationFileStaleTimeout = 5 * time.Minute
+const (
+	validationFileStaleTimeout = 5 * time.Minute
+
+	// validationHeartbeatInterval is how often the validation file is updated while a block is being validated,
+	// so that the validation isn't considered stale. It must be lower than validationFileStaleTimeout.
+	validationHeartbeatInterval = 1 * time.Minute
+
+	// maxBlockValidationIssues is the max number of issues reported by the validation of a block. The validation
+	// stops once the limit is reached.
+	maxBlockValidationIssues = 10
+)
 
 type blockUploadState int
 
@@ -431,8 +476,9 @@ func (c *MultitenantCompactor) GetBlockUploadStateHandler(w http.ResponseWriter,
 	}
 
 	type result struct {
-		State string `json:"result"`
-		Error string `json:"error,omitempty"`
+		State  string   `json:"result"`
+		Error  string   `json:"error,omitempty"`
+		Issues []string `json:"issues,omitempty"`
 	}
 
 	res := result{}
@@ -452,6 +498,7 @@ func (c *MultitenantCompactor) GetBlockUploadStateHandler(w http.ResponseWriter,
 	case blockValidationFailed:
 		res.State = "failed"
 		res.Error = v.Error
+		res.Issues = v.Issues
 	}
 
 	util.WriteJSONResponse(w, res)
@@ -559,3 +606,353 @@ func (c *MultitenantCompactor) loadValidation(ctx context.Context, userBkt objst
 
 	return v, nil
 }
+
+func (c *MultitenantCompactor) uploadValidation(ctx context.Context, blockID ulid.ULID, userBkt objstore.Bucket, v validationFile) error {
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return errors.Wrap(err, "failed to encode validation file")
+	}
+	if err := userBkt.Upload(ctx, path.Join(blockID.String(), validationFilename), buf); err != nil {
+		return errors.Wrapf(err, "failed uploading %s to bucket", validationFilename)
+	}
+	return nil
+}
+
+// blockUploadValidationPool runs the validations of uploaded blocks in the background, up to a max concurrency.
+// The context of the running validations is canceled when the pool is stopped.
+type blockUploadValidationPool struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	slots  chan struct{}
+
+	mtx     sync.Mutex
+	stopped bool
+	running sync.WaitGroup
+}
+
+func newBlockUploadValidationPool(maxConcurrency int) *blockUploadValidationPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &blockUploadValidationPool{
+		ctx:    ctx,
+		cancel: cancel,
+		slots:  make(chan struct{}, maxConcurrency),
+	}
+}
+
+// reserve reserves a slot to run a validation, and returns false if the max concurrency has been reached
+// or the pool has been stopped. A reserved slot must be either used by run() or released.
+func (p *blockUploadValidationPool) reserve() bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.stopped {
+		return false
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		p.running.Add(1)
+		return true
+	default:
+		return false
+	}
+}
+
+// release releases a slot reserved with reserve().
+func (p *blockUploadValidationPool) release() {
+	<-p.slots
+	p.running.Done()
+}
+
+// run runs the validation in the background, in a slot reserved with reserve().
+func (p *blockUploadValidationPool) run(validate func(ctx context.Context)) {
+	go func() {
+		defer p.release()
+		validate(p.ctx)
+	}()
+}
+
+// stop cancels the running validations and waits until they return.
+func (p *blockUploadValidationPool) stop() {
+	p.mtx.Lock()
+	p.stopped = true
+	p.mtx.Unlock()
+
+	p.cancel()
+	p.running.Wait()
+}
+
+// validateAndCompleteBlockUpload validates the uploaded block and completes its upload if the block is valid.
+// Otherwise, the issues found in the block are stored in the validation file. It's meant to run in the background,
+// after the block upload has been finished by the client. If the validation is interrupted, like when the compactor
+// is stopping, or fails because of an internal error, like an object storage failure, the validation file is left
+// to become stale, so that the client can finish the block upload again.
+func (c *MultitenantCompactor) validateAndCompleteBlockUpload(ctx context.Context, logger log.Logger, tenantID string, userBkt objstore.Bucket, blockID ulid.ULID, meta metadata.Meta) {
+	level.Debug(logger).Log("msg", "validating block")
+	begin := time.Now()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Keep the validation file updated while validating the block, so the validation isn't considered stale.
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		c.updateValidationPeriodically(ctx, logger, userBkt, blockID)
+	}()
+
+	issues, err := c.validateBlock(ctx, logger, tenantID, userBkt, blockID, meta)
+
+	// Stop updating the validation file before storing the validation outcome.
+	interrupted := ctx.Err() != nil
+	cancel()
+	<-heartbeatDone
+
+	switch {
+	case interrupted:
+		level.Warn(logger).Log("msg", "block validation interrupted", "err", err)
+		return
+	case err != nil:
+		level.Error(logger).Log("msg", "error while validating block", "err", err)
+		return
+	case len(issues) > 0:
+		level.Warn(logger).Log("msg", "uploaded block is invalid", "issues", len(issues), "first_issue", issues[0])
+		c.storeValidationFailure(logger, userBkt, blockID, fmt.Sprintf("block is invalid: %s", issues[0]), issues)
+		return
+	}
+
+	if err := c.completeBlockUpload(context.Background(), logger, userBkt, blockID, meta); err != nil {
+		level.Error(logger).Log("msg", "error while completing block upload", "err", err)
+		return
+	}
+
+	if err := userBkt.Delete(context.Background(), path.Join(blockID.String(), validationFilename)); err != nil {
+		level.Warn(logger).Log("msg", fmt.Sprintf(
+			"failed to delete %s from block in object storage", validationFilename), "err", err)
+	}
+
+	level.Info(logger).Log("msg", "uploaded block validated and completed", "duration", time.Since(begin))
+}
+
+func (c *MultitenantCompactor) updateValidationPeriodically(ctx context.Context, logger log.Logger, userBkt objstore.Bucket, blockID ulid.ULID) {
+	ticker := time.NewTicker(validationHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.uploadValidation(ctx, blockID, userBkt, validationFile{LastUpdate: time.Now().UnixMilli()}); err != nil && ctx.Err() == nil {
+				level.Warn(logger).Log("msg", "failed to update validation file", "err", err)
+			}
+		}
+	}
+}
+
+func (c *MultitenantCompactor) storeValidationFailure(logger log.Logger, userBkt objstore.Bucket, blockID ulid.ULID, msg string, issues []string) {
+	v := validationFile{LastUpdate: time.Now().UnixMilli(), Error: msg, Issues: issues}
+	if err := c.uploadValidation(context.Background(), blockID, userBkt, v); err != nil {
+		level.Error(logger).Log("msg", "failed to store block validation failure", "err", err)
+	}
+}
+
+// validateBlock downloads the uploaded block and fully verifies it: the block files, the index structure and
+// the order of series, the labels of each series against the tenant's limits and, if enabled for the tenant,
+// the chunks checksum and the samples time range. It returns the issues found in the block, while the returned
+// error is set only if the validation couldn't run.
+func (c *MultitenantCompactor) validateBlock(ctx context.Context, logger log.Logger, tenantID string, userBkt objstore.Bucket, blockID ulid.ULID, meta metadata.Meta) ([]string, error) {
+	uploadDir := filepath.Join(c.compactorCfg.DataDir, "upload")
+	if err := os.MkdirAll(uploadDir, 0750); err != nil {
+		return nil, errors.Wrap(err, "create block upload dir")
+	}
+	blockDir, err := os.MkdirTemp(uploadDir, blockID.String()+"-")
+	if err != nil {
+		return nil, errors.Wrap(err, "create block validation dir")
+	}
+	defer func() {
+		if err := os.RemoveAll(blockDir); err != nil {
+			level.Warn(logger).Log("msg", "failed to remove block validation dir", "dir", blockDir, "err", err)
+		}
+	}()
+
+	issues := &blockValidationIssues{}
+
+	hasIndex := false
+	for _, f := range meta.Thanos.Files {
+		if f.RelPath == block.MetaFilename {
+			continue
+		}
+		if f.RelPath == block.IndexFilename {
+			hasIndex = true
+		}
+
+		dst := filepath.Join(blockDir, filepath.FromSlash(f.RelPath))
+		if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+			return nil, errors.Wrap(err, "create block validation dir")
+		}
+		if err := objstore.DownloadFile(ctx, logger, userBkt, path.Join(blockID.String(), f.RelPath), dst); err != nil {
+			if userBkt.IsObjNotFoundErr(errors.Cause(err)) {
+				issues.add("missing file %s", f.RelPath)
+				continue
+			}
+			return nil, errors.Wrapf(err, "download block file %s", f.RelPath)
+		}
+
+		info, err := os.Stat(dst)
+		if err != nil {
+			return nil, errors.Wrapf(err, "stat block file %s", f.RelPath)
+		}
+		if info.Size() != f.SizeBytes {
+			issues.add("file %s has size %d, while %d is expected", f.RelPath, info.Size(), f.SizeBytes)
+		}
+	}
+	if !hasIndex {
+		issues.add("missing file %s", block.IndexFilename)
+	}
+	if len(issues.list) > 0 {
+		return issues.list, nil
+	}
+
+	// The index is checked before opening the block, which assumes the index is valid.
+	stats, err := block.GatherIndexHealthStats(logger, filepath.Join(blockDir, block.IndexFilename), meta.MinTime, meta.MaxTime)
+	if err == nil {
+		err = stats.AnyErr()
+	}
+	if err != nil {
+		issues.add("invalid index: %s", err)
+		return issues.list, nil
+	}
+
+	if err := meta.WriteToDir(logger, blockDir); err != nil {
+		return nil, errors.Wrap(err, "write block meta")
+	}
+
+	b, err := tsdb.OpenBlock(logger, blockDir, nil)
+	if err != nil {
+		issues.add("open block: %s", err)
+		return issues.list, nil
+	}
+	defer runutil.CloseWithLogOnErr(logger, b, "close validated block")
+
+	if err := validateBlockSeries(b, meta, c.cfgProvider, tenantID, c.cfgProvider.CompactorBlockUploadVerifyChunks(tenantID), issues); err != nil {
+		return nil, err
+	}
+	return issues.list, nil
+}
+
+// validateBlockSeries validates the labels and, if verifyChunks is true, the chunks of all the series of the block.
+func validateBlockSeries(b *tsdb.Block, meta metadata.Meta, limits validation.LabelValidationConfig, tenantID string, verifyChunks bool, issues *blockValidationIssues) (returnErr error) {
+	indexr, err := b.Index()
+	if err != nil {
+		return errors.Wrap(err, "open block index")
+	}
+	defer runutil.CloseWithErrCapture(&returnErr, indexr, "close block index reader")
+
+	chunkr, err := b.Chunks()
+	if err != nil {
+		return errors.Wrap(err, "open block chunks")
+	}
+	defer runutil.CloseWithErrCapture(&returnErr, chunkr, "close block chunks reader")
+
+	postings, err := indexr.Postings(index.AllPostingsKey())
+	if err != nil {
+		return errors.Wrap(err, "get all postings")
+	}
+
+	var (
+		lset labels.Labels
+		chks []chunks.Meta
+	)
+	for postings.Next() && !issues.full() {
+		if err := indexr.Series(postings.At(), &lset, &chks); err != nil {
+			issues.add("read series: %s", err)
+			return nil
+		}
+
+		if err := validateSeriesLabels(lset, limits, tenantID); err != nil {
+			issues.add("series %s: %s", lset, err)
+		}
+
+		if !verifyChunks {
+			continue
+		}
+		for _, chk := range chks {
+			if err := verifyChunk(chunkr, chk, meta.MinTime, meta.MaxTime); err != nil {
+				issues.add("series %s: %s", lset, err)
+				break
+			}
+		}
+	}
+	if err := postings.Err(); err != nil {
+		issues.add("iterate postings: %s", err)
+	}
+	return nil
+}
+
+// validateSeriesLabels checks the series labels against the tenant's limits. A limit is not enforced if
+// it's not greater than 0.
+func validateSeriesLabels(lset labels.Labels, limits validation.LabelValidationConfig, tenantID string) error {
+	if maxNames := limits.MaxLabelNamesPerSeries(tenantID); maxNames > 0 && len(lset) > maxNames {
+		return fmt.Errorf("series has %d labels, exceeding the limit of %d labels per series", len(lset), maxNames)
+	}
+
+	maxNameLength := limits.MaxLabelNameLength(tenantID)
+	maxValueLength := limits.MaxLabelValueLength(tenantID)
+	for _, l := range lset {
+		if !model.LabelName(l.Name).IsValid() {
+			return fmt.Errorf("invalid label name %q", l.Name)
+		}
+		if maxNameLength > 0 && len(l.Name) > maxNameLength {
+			return fmt.Errorf("label name %q is longer than the limit of %d characters", l.Name, maxNameLength)
+		}
+		if maxValueLength > 0 && len(l.Value) > maxValueLength {
+			return fmt.Errorf("value of label %q is longer than the limit of %d characters", l.Name, maxValueLength)
+		}
+	}
+	return nil
+}
+
+// verifyChunk reads the chunk, verifying its checksum, and checks that its samples are ordered, within
+// the chunk time range, and within the block time range [minTime, maxTime).
+func verifyChunk(chunkr tsdb.ChunkReader, chk chunks.Meta, minTime, maxTime int64) error {
+	c, err := chunkr.Chunk(chk)
+	if err != nil {
+		return errors.Wrapf(err, "read chunk %d", chk.Ref)
+	}
+
+	it := c.Iterator(nil)
+	lastT := int64(math.MinInt64)
+	for it.Next() {
+		t, _ := it.At()
+		switch {
+		case t <= lastT:
+			return fmt.Errorf("chunk %d has out-of-order sample with timestamp %d", chk.Ref, t)
+		case t < chk.MinTime || t > chk.MaxTime:
+			return fmt.Errorf("chunk %d has sample with timestamp %d outside the chunk time range [%d, %d]", chk.Ref, t, chk.MinTime, chk.MaxTime)
+		case t < minTime || t >= maxTime:
+			return fmt.Errorf("chunk %d has sample with timestamp %d outside the block time range [%d, %d)", chk.Ref, t, minTime, maxTime)
+		}
+		lastT = t
+	}
+	if err := it.Err(); err != nil {
+		return errors.Wrapf(err, "iterate chunk %d", chk.Ref)
+	}
+	return nil
+}
+
+// blockValidationIssues collects the issues found by the block validation, up to maxBlockValidationIssues.
+type blockValidationIssues struct {
+	list []string
+}
+
+func (i *blockValidationIssues) add(format string, args ...interface{}) {
+	if i.full() {
+		return
+	}
+	i.list = append(i.list, fmt.Sprintf(format, args...))
+}
+
+func (i *blockValidationIssues) full() bool {
+	return len(i.list) >= maxBlockValidationIssues
+}
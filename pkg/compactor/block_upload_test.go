@@ -12,13 +12,16 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"path"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/test"
 	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -1331,6 +1334,15 @@ func TestMultitenantCompactor_GetBlockUploadStateHandler(t *testing.T) {
 			expectedBody:       `{"result":"failed","error":"error during validation"}`,
 		},
 
+		"validation failed with issues": {
+			setupBucket: func(t *testing.T, bkt objstore.Bucket) {
+				marshalAndUploadJSON(t, bkt, path.Join(tenantID, blockID, uploadingMetaFilename), metadata.Meta{})
+				marshalAndUploadJSON(t, bkt, path.Join(tenantID, blockID, validationFilename), validationFile{LastUpdate: time.Now().UnixMilli(), Error: "block is invalid: missing file index", Issues: []string{"missing file index"}})
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"result":"failed","error":"block is invalid: missing file index","issues":["missing file index"]}`,
+		},
+
 		"stale validation file": {
 			setupBucket: func(t *testing.T, bkt objstore.Bucket) {
 				marshalAndUploadJSON(t, bkt, path.Join(tenantID, blockID, uploadingMetaFilename), metadata.Meta{})
@@ -1372,3 +1384,266 @@ func TestMultitenantCompactor_GetBlockUploadStateHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestMultitenantCompactor_ValidateAndCompleteBlockUpload(t *testing.T) {
+	const tenantID = "tenant"
+
+	series := []labels.Labels{
+		labels.FromStrings("__name__", "metric", "a", "1"),
+		labels.FromStrings("__name__", "metric", "a", "2"),
+	}
+
+	tests := map[string]struct {
+		maxLabelNamesPerSeries int
+		verifyChunks           bool
+		mutateMeta             func(meta *metadata.Meta)
+		corruptBlock           func(t *testing.T, bkt objstore.Bucket, blockID ulid.ULID)
+		expectedState          string
+		expectedError          string
+		expectedIssues         []string
+	}{
+		"valid block": {
+			verifyChunks:  true,
+			expectedState: "complete",
+		},
+		"missing file": {
+			corruptBlock: func(t *testing.T, bkt objstore.Bucket, blockID ulid.ULID) {
+				require.NoError(t, bkt.Delete(context.Background(), path.Join(tenantID, blockID.String(), "chunks", "000001")))
+			},
+			expectedState:  "failed",
+			expectedError:  "block is invalid: missing file chunks/000001",
+			expectedIssues: []string{"missing file chunks/000001"},
+		},
+		"chunks outside the block time range": {
+			mutateMeta: func(meta *metadata.Meta) {
+				meta.MaxTime = 500
+			},
+			expectedState: "failed",
+			expectedError: "block is invalid: invalid index: found 2 chunks non-completely outside the block time range",
+		},
+		"too many labels": {
+			maxLabelNamesPerSeries: 1,
+			expectedState:          "failed",
+			expectedError:          `block is invalid: series {__name__="metric", a="1"}: series has 2 labels, exceeding the limit of 1 labels per series`,
+			expectedIssues: []string{
+				`series {__name__="metric", a="1"}: series has 2 labels, exceeding the limit of 1 labels per series`,
+				`series {__name__="metric", a="2"}: series has 2 labels, exceeding the limit of 1 labels per series`,
+			},
+		},
+		"corrupted chunks": {
+			verifyChunks: true,
+			corruptBlock: func(t *testing.T, bkt objstore.Bucket, blockID ulid.ULID) {
+				name := path.Join(tenantID, blockID.String(), "chunks", "000001")
+				r, err := bkt.Get(context.Background(), name)
+				require.NoError(t, err)
+				data, err := io.ReadAll(r)
+				require.NoError(t, err)
+				require.NoError(t, r.Close())
+
+				// Flip the last byte, which is part of the CRC32 of the last chunk.
+				data[len(data)-1] ^= 0xff
+				require.NoError(t, bkt.Upload(context.Background(), name, bytes.NewReader(data)))
+			},
+			expectedState: "failed",
+			expectedError: `block is invalid: series {__name__="metric", a="2"}: read chunk`,
+		},
+		"corrupted chunks without chunks verification": {
+			corruptBlock: func(t *testing.T, bkt objstore.Bucket, blockID ulid.ULID) {
+				name := path.Join(tenantID, blockID.String(), "chunks", "000001")
+				r, err := bkt.Get(context.Background(), name)
+				require.NoError(t, err)
+				data, err := io.ReadAll(r)
+				require.NoError(t, err)
+				require.NoError(t, r.Close())
+
+				data[len(data)-1] ^= 0xff
+				require.NoError(t, bkt.Upload(context.Background(), name, bytes.NewReader(data)))
+			},
+			expectedState: "complete",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			bkt := objstore.NewInMemBucket()
+			blockID, meta := uploadTestBlockFiles(t, bkt, tenantID, series)
+			if tc.mutateMeta != nil {
+				tc.mutateMeta(meta)
+			}
+			if tc.corruptBlock != nil {
+				tc.corruptBlock(t, bkt, blockID)
+			}
+			marshalAndUploadJSON(t, bkt, path.Join(tenantID, blockID.String(), uploadingMetaFilename), meta)
+
+			cfgProvider := newMockConfigProvider()
+			cfgProvider.blockUploadEnabled[tenantID] = true
+			cfgProvider.blockUploadValidation[tenantID] = true
+			cfgProvider.blockUploadVerifyChunks[tenantID] = tc.verifyChunks
+			cfgProvider.maxLabelNamesPerSeries[tenantID] = tc.maxLabelNamesPerSeries
+
+			c := &MultitenantCompactor{
+				logger:                 log.NewNopLogger(),
+				bucketClient:           bkt,
+				cfgProvider:            cfgProvider,
+				compactorCfg:           Config{DataDir: t.TempDir()},
+				blockUploadValidations: newBlockUploadValidationPool(1),
+			}
+			t.Cleanup(c.blockUploadValidations.stop)
+
+			newRequest := func(method, pth string) *http.Request {
+				r := httptest.NewRequest(method, pth, nil)
+				r = mux.SetURLVars(r, map[string]string{"block": blockID.String()})
+				return r.WithContext(user.InjectOrgID(r.Context(), tenantID))
+			}
+
+			// The block upload can't be completed while the max number of validations are running.
+			require.True(t, c.blockUploadValidations.reserve())
+			w := httptest.NewRecorder()
+			c.FinishBlockUpload(w, newRequest(http.MethodPost, fmt.Sprintf("/api/v1/upload/block/%s/finish", blockID)))
+			require.Equal(t, http.StatusTooManyRequests, w.Code, w.Body.String())
+			c.blockUploadValidations.release()
+
+			w = httptest.NewRecorder()
+			c.FinishBlockUpload(w, newRequest(http.MethodPost, fmt.Sprintf("/api/v1/upload/block/%s/finish", blockID)))
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			type result struct {
+				State  string   `json:"result"`
+				Error  string   `json:"error"`
+				Issues []string `json:"issues"`
+			}
+			var res result
+			test.Poll(t, 10*time.Second, tc.expectedState, func() interface{} {
+				w := httptest.NewRecorder()
+				c.GetBlockUploadStateHandler(w, newRequest(http.MethodGet, fmt.Sprintf("/api/v1/upload/block/%s/check", blockID)))
+				res = result{}
+				if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+					return err.Error()
+				}
+				return res.State
+			})
+
+			assert.True(t, strings.HasPrefix(res.Error, tc.expectedError), "unexpected error: %s", res.Error)
+			if tc.expectedIssues != nil {
+				assert.Equal(t, tc.expectedIssues, res.Issues)
+			}
+
+			// The validation file is removed once the block upload is completed.
+			validationExists, err := bkt.Exists(context.Background(), path.Join(tenantID, blockID.String(), validationFilename))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedState != "complete", validationExists)
+
+			metaExists, err := bkt.Exists(context.Background(), path.Join(tenantID, blockID.String(), block.MetaFilename))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedState == "complete", metaExists)
+		})
+	}
+}
+
+func TestMultitenantCompactor_ValidateAndCompleteBlockUpload_ShouldNotFailBlockOnInternalError(t *testing.T) {
+	const tenantID = "tenant"
+
+	ctx := context.Background()
+	bkt := &mockBucketFailure{Bucket: objstore.NewInMemBucket()}
+	blockID, meta := uploadTestBlockFiles(t, bkt, tenantID, []labels.Labels{labels.FromStrings("__name__", "metric", "a", "1")})
+	marshalAndUploadJSON(t, bkt, path.Join(tenantID, blockID.String(), uploadingMetaFilename), meta)
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.blockUploadEnabled[tenantID] = true
+	cfgProvider.blockUploadValidation[tenantID] = true
+
+	c := &MultitenantCompactor{
+		logger:                 log.NewNopLogger(),
+		bucketClient:           bkt,
+		cfgProvider:            cfgProvider,
+		compactorCfg:           Config{DataDir: t.TempDir()},
+		blockUploadValidations: newBlockUploadValidationPool(1),
+	}
+	t.Cleanup(c.blockUploadValidations.stop)
+
+	userBkt := bucket.NewUserBucketClient(tenantID, bkt, cfgProvider)
+	validationStart := validationFile{LastUpdate: time.Now().UnixMilli()}
+	require.NoError(t, c.uploadValidation(ctx, blockID, userBkt, validationStart))
+
+	// The block files can't be downloaded because of an object storage failure.
+	bkt.GetFailures = []string{path.Join(tenantID, blockID.String(), "chunks", "000001")}
+	c.validateAndCompleteBlockUpload(ctx, log.NewNopLogger(), tenantID, userBkt, blockID, *meta)
+
+	// The validation file is left as is, and the block upload isn't failed.
+	state, _, v, err := c.getBlockUploadState(ctx, userBkt, blockID)
+	require.NoError(t, err)
+	assert.Equal(t, blockValidationInProgress, state)
+	assert.Equal(t, validationStart, *v)
+
+	// Once the validation file is stale, the block upload can be finished again.
+	bkt.GetFailures = nil
+	require.NoError(t, c.uploadValidation(ctx, blockID, userBkt, validationFile{LastUpdate: time.Now().Add(-validationFileStaleTimeout).UnixMilli()}))
+
+	r := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/upload/block/%s/finish", blockID), nil)
+	r = mux.SetURLVars(r, map[string]string{"block": blockID.String()})
+	w := httptest.NewRecorder()
+	c.FinishBlockUpload(w, r.WithContext(user.InjectOrgID(r.Context(), tenantID)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	test.Poll(t, 10*time.Second, blockIsComplete, func() interface{} {
+		state, _, _, err := c.getBlockUploadState(ctx, userBkt, blockID)
+		if err != nil {
+			return err
+		}
+		return state
+	})
+}
+
+// uploadTestBlockFiles creates a block with the given series and uploads its files, as done by the block
+// upload API. It returns the block ID and the meta to upload, listing the uploaded files.
+func uploadTestBlockFiles(t *testing.T, bkt objstore.Bucket, tenantID string, series []labels.Labels) (ulid.ULID, *metadata.Meta) {
+	blockDir := t.TempDir()
+	blockID, err := createBlockWithOptions(context.Background(), blockDir, series, 100, 0, 1000, nil, 0, false, metadata.NoneFunc)
+	require.NoError(t, err)
+
+	meta, err := metadata.ReadFromDir(filepath.Join(blockDir, blockID.String()))
+	require.NoError(t, err)
+	files, err := block.GatherFileStats(filepath.Join(blockDir, blockID.String()), metadata.NoneFunc, log.NewNopLogger())
+	require.NoError(t, err)
+	meta.Thanos.Files = nil
+	for _, f := range files {
+		if !rePath.MatchString(f.RelPath) {
+			continue
+		}
+		meta.Thanos.Files = append(meta.Thanos.Files, f)
+		require.NoError(t, objstore.UploadFile(context.Background(), log.NewNopLogger(), bkt, filepath.Join(blockDir, blockID.String(), f.RelPath), path.Join(tenantID, blockID.String(), f.RelPath)))
+	}
+	return blockID, meta
+}
+
+func TestBlockUploadValidationPool(t *testing.T) {
+	p := newBlockUploadValidationPool(2)
+
+	// Slots can be reserved up to the max concurrency.
+	require.True(t, p.reserve())
+	require.True(t, p.reserve())
+	require.False(t, p.reserve())
+
+	// Released slots can be reserved again.
+	p.release()
+	require.True(t, p.reserve())
+	p.release()
+	p.release()
+
+	// Running validations are canceled when the pool is stopped, and the pool waits for them.
+	require.True(t, p.reserve())
+	started := make(chan struct{})
+	finished := false
+	p.run(func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		finished = true
+	})
+	<-started
+
+	p.stop()
+	require.True(t, finished)
+
+	// Slots can't be reserved once the pool is stopped.
+	require.False(t, p.reserve())
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/grafana/mimir/pkg/storage/bucket"
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/storage/tsdb/downsample"
 	"github.com/grafana/mimir/pkg/util"
 	util_log "github.com/grafana/mimir/pkg/util/log"
 	"github.com/grafana/mimir/pkg/util/validation"
@@ -37,11 +38,14 @@ const (
 )
 
 type BlocksCleanerConfig struct {
-	DeletionDelay           time.Duration
-	CleanupInterval         time.Duration
-	CleanupConcurrency      int
-	TenantCleanupDelay      time.Duration // Delay before removing tenant deletion mark and "debug".
-	DeleteBlocksConcurrency int
+	DeletionDelay            time.Duration
+	CleanupInterval          time.Duration
+	CleanupConcurrency       int
+	TenantCleanupDelay       time.Duration // Delay before removing tenant deletion mark and "debug".
+	DeleteBlocksConcurrency  int
+	TopLabelNames            int // Number of top label names to store in the bucket index block stats.
+	TopLabelNamesMaxBlocks   int // Max number of blocks to read the top label names from at every update.
+	TopLabelNamesConcurrency int
 }
 
 type BlocksCleaner struct {
@@ -341,12 +345,14 @@ func (c *BlocksCleaner) cleanUser(ctx context.Context, userID string) (returnErr
 	if idx != nil {
 		// We do not want to stop the remaining work in the cleaner if an
 		// error occurs here. Errors are logged in the function.
-		retention := c.cfgProvider.CompactorBlocksRetentionPeriod(userID)
-		c.applyUserRetentionPeriod(ctx, idx, retention, userBucket, userLogger)
+		for _, resolution := range downsample.Resolutions {
+			retention := blocksRetentionPeriod(c.cfgProvider, userID, resolution)
+			c.applyUserRetentionPeriod(ctx, idx, resolution, retention, userBucket, userLogger)
+		}
 	}
 
 	// Generate an updated in-memory version of the bucket index.
-	w := bucketindex.NewUpdater(c.bucketClient, userID, c.cfgProvider, c.logger)
+	w := bucketindex.NewUpdater(c.bucketClient, userID, c.cfgProvider, c.logger).WithTopLabelNames(c.cfg.TopLabelNames, c.cfg.TopLabelNamesMaxBlocks, c.cfg.TopLabelNamesConcurrency)
 	idx, partials, err := w.UpdateIndex(ctx, idx)
 	if err != nil {
 		return err
@@ -487,29 +493,68 @@ func (c *BlocksCleaner) cleanUserPartialBlocks(ctx context.Context, partials map
 	}
 }
 
+// defaultRetentionPeriod returns the retention period of the user's series not matching any retention
+// rule, in blocks at the given resolution. Downsampled blocks follow the raw blocks retention period,
+// unless a specific one is configured and downsampling is enabled for the user.
+func defaultRetentionPeriod(cfgProvider ConfigProvider, userID string, resolution int64) time.Duration {
+	retention := cfgProvider.CompactorBlocksRetentionPeriod(userID)
+	if resolution == downsample.ResolutionRaw || !cfgProvider.CompactorDownsamplingEnabled(userID) {
+		return retention
+	}
+
+	var downsampledRetention time.Duration
+	switch resolution {
+	case downsample.Resolution5m:
+		downsampledRetention = cfgProvider.CompactorDownsampled5mBlocksRetentionPeriod(userID)
+	case downsample.Resolution1h:
+		downsampledRetention = cfgProvider.CompactorDownsampled1hBlocksRetentionPeriod(userID)
+	}
+
+	if downsampledRetention > 0 {
+		return downsampledRetention
+	}
+	return retention
+}
+
+// blocksRetentionPeriod returns the retention period of the user's blocks at the given resolution.
+// A block is deleted only once all its series have aged past their retention period, so the longest
+// retention rule period applies to the whole block. The series expiring earlier are removed from the
+// block by the BucketRetentionRewriter.
+func blocksRetentionPeriod(cfgProvider ConfigProvider, userID string, resolution int64) time.Duration {
+	retention := defaultRetentionPeriod(cfgProvider, userID, resolution)
+	if retention == 0 {
+		return 0
+	}
+
+	if maxRulePeriod := cfgProvider.CompactorRetentionRules(userID).MaxPeriod(); maxRulePeriod > retention {
+		return maxRulePeriod
+	}
+	return retention
+}
+
 // applyUserRetentionPeriod marks blocks for deletion which have aged past the retention period.
-func (c *BlocksCleaner) applyUserRetentionPeriod(ctx context.Context, idx *bucketindex.Index, retention time.Duration, userBucket objstore.Bucket, userLogger log.Logger) {
+func (c *BlocksCleaner) applyUserRetentionPeriod(ctx context.Context, idx *bucketindex.Index, resolution int64, retention time.Duration, userBucket objstore.Bucket, userLogger log.Logger) {
 	// The retention period of zero is a special value indicating to never delete.
 	if retention <= 0 {
 		return
 	}
 
-	level.Debug(userLogger).Log("msg", "applying retention", "retention", retention.String())
-	blocks := listBlocksOutsideRetentionPeriod(idx, time.Now().Add(-retention))
+	level.Debug(userLogger).Log("msg", "applying retention", "retention", retention.String(), "resolution", resolution)
+	blocks := listBlocksOutsideRetentionPeriod(idx, resolution, time.Now().Add(-retention))
 
 	// Attempt to mark all blocks. It is not critical if a marking fails, as
 	// the cleaner will retry applying the retention in its next cycle.
 	for _, b := range blocks {
-		level.Info(userLogger).Log("msg", "applied retention: marking block for deletion", "block", b.ID, "maxTime", b.MaxTime)
+		level.Info(userLogger).Log("msg", "applied retention: marking block for deletion", "block", b.ID, "maxTime", b.MaxTime, "resolution", resolution)
 		if err := block.MarkForDeletion(ctx, userLogger, userBucket, b.ID, fmt.Sprintf("block exceeding retention of %v", retention), c.blocksMarkedForDeletion); err != nil {
 			level.Warn(userLogger).Log("msg", "failed to mark block for deletion", "block", b.ID, "err", err)
 		}
 	}
 }
 
-// listBlocksOutsideRetentionPeriod determines the blocks which have aged past
-// the specified retention period, and are not already marked for deletion.
-func listBlocksOutsideRetentionPeriod(idx *bucketindex.Index, threshold time.Time) (result bucketindex.Blocks) {
+// listBlocksOutsideRetentionPeriod determines the blocks at the given resolution which have
+// aged past the specified retention period, and are not already marked for deletion.
+func listBlocksOutsideRetentionPeriod(idx *bucketindex.Index, resolution int64, threshold time.Time) (result bucketindex.Blocks) {
 	// Whilst re-marking a block is not harmful, it is wasteful and generates
 	// a warning log message. Use the block deletion marks already in-memory
 	// to prevent marking blocks already marked for deletion.
@@ -519,6 +564,10 @@ func listBlocksOutsideRetentionPeriod(idx *bucketindex.Index, threshold time.Tim
 	}
 
 	for _, b := range idx.Blocks {
+		if b.Resolution != resolution {
+			continue
+		}
+
 		maxTime := time.Unix(b.MaxTime/1000, 0)
 		if maxTime.Before(threshold) {
 			if _, isMarked := marked[b.ID]; !isMarked {
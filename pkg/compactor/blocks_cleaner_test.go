@@ -10,6 +10,7 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -23,6 +24,7 @@ import (
 	"github.com/oklog/ulid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/thanos-io/objstore"
@@ -32,9 +34,11 @@ import (
 	"github.com/grafana/mimir/pkg/storage/bucket"
 	"github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/storage/tsdb/downsample"
 	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/test"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 type testBlocksCleanerOptions struct {
@@ -472,20 +476,20 @@ func TestBlocksCleaner_ListBlocksOutsideRetentionPeriod(t *testing.T) {
 	assert.ElementsMatch(t, []ulid.ULID{id1, id2, id3}, idx.Blocks.GetULIDs())
 
 	// Excessive retention period (wrapping epoch)
-	result := listBlocksOutsideRetentionPeriod(idx, time.Unix(10, 0).Add(-time.Hour))
+	result := listBlocksOutsideRetentionPeriod(idx, downsample.ResolutionRaw, time.Unix(10, 0).Add(-time.Hour))
 	assert.ElementsMatch(t, []ulid.ULID{}, result.GetULIDs())
 
 	// Normal operation - varying retention period.
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(6, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResolutionRaw, time.Unix(6, 0))
 	assert.ElementsMatch(t, []ulid.ULID{}, result.GetULIDs())
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(7, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResolutionRaw, time.Unix(7, 0))
 	assert.ElementsMatch(t, []ulid.ULID{id1}, result.GetULIDs())
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(8, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResolutionRaw, time.Unix(8, 0))
 	assert.ElementsMatch(t, []ulid.ULID{id1, id2}, result.GetULIDs())
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(9, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResolutionRaw, time.Unix(9, 0))
 	assert.ElementsMatch(t, []ulid.ULID{id1, id2, id3}, result.GetULIDs())
 
 	// Avoiding redundant marking - blocks already marked for deletion.
@@ -495,24 +499,71 @@ func TestBlocksCleaner_ListBlocksOutsideRetentionPeriod(t *testing.T) {
 
 	idx.BlockDeletionMarks = bucketindex.BlockDeletionMarks{mark1}
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(7, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResolutionRaw, time.Unix(7, 0))
 	assert.ElementsMatch(t, []ulid.ULID{}, result.GetULIDs())
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(8, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResolutionRaw, time.Unix(8, 0))
 	assert.ElementsMatch(t, []ulid.ULID{id2}, result.GetULIDs())
 
 	idx.BlockDeletionMarks = bucketindex.BlockDeletionMarks{mark1, mark2}
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(7, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResolutionRaw, time.Unix(7, 0))
 	assert.ElementsMatch(t, []ulid.ULID{}, result.GetULIDs())
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(8, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResolutionRaw, time.Unix(8, 0))
 	assert.ElementsMatch(t, []ulid.ULID{}, result.GetULIDs())
 
-	result = listBlocksOutsideRetentionPeriod(idx, time.Unix(9, 0))
+	result = listBlocksOutsideRetentionPeriod(idx, downsample.ResolutionRaw, time.Unix(9, 0))
 	assert.ElementsMatch(t, []ulid.ULID{id3}, result.GetULIDs())
 }
 
+func TestBlocksCleaner_ListBlocksOutsideRetentionPeriodShouldHonorResolution(t *testing.T) {
+	raw := &bucketindex.Block{ID: ulid.MustNew(1, nil), MinTime: 5000, MaxTime: 6000}
+	res5m := &bucketindex.Block{ID: ulid.MustNew(2, nil), MinTime: 5000, MaxTime: 6000, Resolution: downsample.Resolution5m}
+	res1h := &bucketindex.Block{ID: ulid.MustNew(3, nil), MinTime: 5000, MaxTime: 6000, Resolution: downsample.Resolution1h}
+	idx := &bucketindex.Index{Blocks: bucketindex.Blocks{raw, res5m, res1h}}
+
+	assert.ElementsMatch(t, []ulid.ULID{raw.ID}, listBlocksOutsideRetentionPeriod(idx, downsample.ResolutionRaw, time.Unix(7, 0)).GetULIDs())
+	assert.ElementsMatch(t, []ulid.ULID{res5m.ID}, listBlocksOutsideRetentionPeriod(idx, downsample.Resolution5m, time.Unix(7, 0)).GetULIDs())
+	assert.ElementsMatch(t, []ulid.ULID{res1h.ID}, listBlocksOutsideRetentionPeriod(idx, downsample.Resolution1h, time.Unix(7, 0)).GetULIDs())
+}
+
+func TestBlocksCleaner_RetentionPeriod(t *testing.T) {
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.userRetentionPeriods["user-1"] = 24 * time.Hour
+	cfgProvider.downsampled5mRetention["user-1"] = 48 * time.Hour
+	cfgProvider.downsampled1hRetention["user-1"] = 72 * time.Hour
+
+	// Downsampled blocks follow the raw blocks retention when downsampling is disabled.
+	for _, res := range downsample.Resolutions {
+		assert.Equal(t, 24*time.Hour, defaultRetentionPeriod(cfgProvider, "user-1", res))
+		assert.Equal(t, 24*time.Hour, blocksRetentionPeriod(cfgProvider, "user-1", res))
+	}
+
+	cfgProvider.downsamplingEnabled["user-1"] = true
+	assert.Equal(t, 24*time.Hour, defaultRetentionPeriod(cfgProvider, "user-1", downsample.ResolutionRaw))
+	assert.Equal(t, 48*time.Hour, defaultRetentionPeriod(cfgProvider, "user-1", downsample.Resolution5m))
+	assert.Equal(t, 72*time.Hour, defaultRetentionPeriod(cfgProvider, "user-1", downsample.Resolution1h))
+
+	// Blocks are retained until the longest retention rule period expires.
+	cfgProvider.retentionRules["user-1"] = validation.RetentionRules{
+		{Selector: `{env="dev"}`, Period: model.Duration(time.Hour)},
+		{Selector: `{env="prod"}`, Period: model.Duration(60 * time.Hour)},
+	}
+	assert.Equal(t, 24*time.Hour, defaultRetentionPeriod(cfgProvider, "user-1", downsample.ResolutionRaw))
+	assert.Equal(t, 60*time.Hour, blocksRetentionPeriod(cfgProvider, "user-1", downsample.ResolutionRaw))
+	assert.Equal(t, 60*time.Hour, blocksRetentionPeriod(cfgProvider, "user-1", downsample.Resolution5m))
+	assert.Equal(t, 72*time.Hour, blocksRetentionPeriod(cfgProvider, "user-1", downsample.Resolution1h))
+
+	// Downsampled blocks follow the raw blocks retention when a specific one is not configured.
+	cfgProvider.downsampled1hRetention["user-1"] = 0
+	assert.Equal(t, 24*time.Hour, defaultRetentionPeriod(cfgProvider, "user-1", downsample.Resolution1h))
+
+	// Blocks are retained forever when the blocks retention period is disabled.
+	cfgProvider.userRetentionPeriods["user-1"] = 0
+	assert.Equal(t, time.Duration(0), blocksRetentionPeriod(cfgProvider, "user-1", downsample.ResolutionRaw))
+}
+
 func TestBlocksCleaner_ShouldRemoveBlocksOutsideRetentionPeriod(t *testing.T) {
 	bucketClient, _ := mimir_testutil.PrepareFilesystemBucket(t)
 	bucketClient = bucketindex.BucketWithGlobalMarkers(bucketClient)
@@ -940,6 +991,14 @@ type mockBucketFailure struct {
 	objstore.Bucket
 
 	DeleteFailures []string
+	GetFailures    []string
+}
+
+func (m *mockBucketFailure) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if util.StringsContain(m.GetFailures, name) {
+		return nil, errors.New("mocked get failure")
+	}
+	return m.Bucket.Get(ctx, name)
 }
 
 func (m *mockBucketFailure) Delete(ctx context.Context, name string) error {
@@ -955,8 +1014,19 @@ type mockConfigProvider struct {
 	instancesShardSize           map[string]int
 	splitGroups                  map[string]int
 	blockUploadEnabled           map[string]bool
+	blockUploadValidation        map[string]bool
+	blockUploadVerifyChunks      map[string]bool
+	maxLabelNamesPerSeries       map[string]int
+	maxLabelNameLength           map[string]int
+	maxLabelValueLength          map[string]int
+	blockRewriteEnabled          map[string]bool
 	userPartialBlockDelay        map[string]time.Duration
 	userPartialBlockDelayInvalid map[string]bool
+	maxBlocksPerJob              map[string]int
+	downsamplingEnabled          map[string]bool
+	downsampled5mRetention       map[string]time.Duration
+	downsampled1hRetention       map[string]time.Duration
+	retentionRules               map[string]validation.RetentionRules
 }
 
 func newMockConfigProvider() *mockConfigProvider {
@@ -965,8 +1035,19 @@ func newMockConfigProvider() *mockConfigProvider {
 		splitAndMergeShards:          make(map[string]int),
 		splitGroups:                  make(map[string]int),
 		blockUploadEnabled:           make(map[string]bool),
+		blockUploadValidation:        make(map[string]bool),
+		blockUploadVerifyChunks:      make(map[string]bool),
+		maxLabelNamesPerSeries:       make(map[string]int),
+		maxLabelNameLength:           make(map[string]int),
+		maxLabelValueLength:          make(map[string]int),
+		blockRewriteEnabled:          make(map[string]bool),
 		userPartialBlockDelay:        make(map[string]time.Duration),
 		userPartialBlockDelayInvalid: make(map[string]bool),
+		maxBlocksPerJob:              make(map[string]int),
+		downsamplingEnabled:          make(map[string]bool),
+		downsampled5mRetention:       make(map[string]time.Duration),
+		downsampled1hRetention:       make(map[string]time.Duration),
+		retentionRules:               make(map[string]validation.RetentionRules),
 	}
 }
 
@@ -1002,10 +1083,54 @@ func (m *mockConfigProvider) CompactorBlockUploadEnabled(tenantID string) bool {
 	return m.blockUploadEnabled[tenantID]
 }
 
+func (m *mockConfigProvider) CompactorBlockUploadValidationEnabled(tenantID string) bool {
+	return m.blockUploadValidation[tenantID]
+}
+
+func (m *mockConfigProvider) CompactorBlockUploadVerifyChunks(tenantID string) bool {
+	return m.blockUploadVerifyChunks[tenantID]
+}
+
+func (m *mockConfigProvider) MaxLabelNamesPerSeries(userID string) int {
+	return m.maxLabelNamesPerSeries[userID]
+}
+
+func (m *mockConfigProvider) MaxLabelNameLength(userID string) int {
+	return m.maxLabelNameLength[userID]
+}
+
+func (m *mockConfigProvider) MaxLabelValueLength(userID string) int {
+	return m.maxLabelValueLength[userID]
+}
+
+func (m *mockConfigProvider) CompactorBlockRewriteEnabled(tenantID string) bool {
+	return m.blockRewriteEnabled[tenantID]
+}
+
 func (m *mockConfigProvider) CompactorPartialBlockDeletionDelay(user string) (time.Duration, bool) {
 	return m.userPartialBlockDelay[user], !m.userPartialBlockDelayInvalid[user]
 }
 
+func (m *mockConfigProvider) CompactorMaxBlocksPerJob(user string) int {
+	return m.maxBlocksPerJob[user]
+}
+
+func (m *mockConfigProvider) CompactorDownsamplingEnabled(user string) bool {
+	return m.downsamplingEnabled[user]
+}
+
+func (m *mockConfigProvider) CompactorDownsampled5mBlocksRetentionPeriod(user string) time.Duration {
+	return m.downsampled5mRetention[user]
+}
+
+func (m *mockConfigProvider) CompactorDownsampled1hBlocksRetentionPeriod(user string) time.Duration {
+	return m.downsampled1hRetention[user]
+}
+
+func (m *mockConfigProvider) CompactorRetentionRules(user string) validation.RetentionRules {
+	return m.retentionRules[user]
+}
+
 func (m *mockConfigProvider) S3SSEType(user string) string {
 	return ""
 }
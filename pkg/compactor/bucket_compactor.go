@@ -294,6 +294,13 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 		return false, nil, nil
 	}
 
+	// Compact only the oldest blocks if the job has too many of them. The compacted block is
+	// compacted together with the remaining ones when the job is rerun.
+	if c.maxBlocksPerJob > 0 && len(toCompact) > c.maxBlocksPerJob {
+		level.Info(jobLogger).Log("msg", "compaction job has more blocks than the max allowed per job; compacting only the oldest ones", "blocks", len(toCompact), "max_blocks_per_job", c.maxBlocksPerJob)
+		toCompact = toCompact[:c.maxBlocksPerJob]
+	}
+
 	// The planner returned some blocks to compact, so we can enrich the logger
 	// with the min/max time between all blocks to compact.
 	jobLogger = log.With(jobLogger, "minTime", minTime(toCompact).String(), "maxTime", maxTime(toCompact).String())
@@ -350,11 +357,21 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 
 	compactionBegin := time.Now()
 
-	if job.UseSplitting() {
-		compIDs, err = c.comp.CompactWithSplitting(subDir, blocksToCompactDirs, nil, uint64(job.SplittingShards()))
-	} else {
+	dirs := blocksToCompactDirs
+	if c.maxBlocksMergedPerPass > 0 && len(dirs) > c.maxBlocksMergedPerPass {
+		if dirs, err = c.mergeInPasses(subDir, dirs, jobLogger); err != nil {
+			return false, nil, errors.Wrapf(err, "compact blocks %v", blocksToCompactDirs)
+		}
+	}
+
+	switch {
+	case len(dirs) == 0:
+		// All the blocks merged in passes had no samples.
+	case job.UseSplitting():
+		compIDs, err = c.comp.CompactWithSplitting(subDir, dirs, nil, uint64(job.SplittingShards()))
+	default:
 		var compID ulid.ULID
-		compID, err = c.comp.Compact(subDir, blocksToCompactDirs, nil)
+		compID, err = c.comp.Compact(subDir, dirs, nil)
 		compIDs = append(compIDs, compID)
 	}
 	if err != nil {
@@ -443,6 +460,44 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 	return true, compIDs, nil
 }
 
+// mergeInPasses merges the blocks in the input dirs in multiple passes, each one merging at most
+// c.maxBlocksMergedPerPass blocks into an intermediate block written in dest, until the blocks left can be
+// merged at once. Since each pass opens a bounded number of blocks and spills its result to disk, the
+// memory used to merge many overlapping blocks is bounded too. The input blocks merged by a pass are removed
+// from the local disk. It returns the dirs of the blocks left, which are empty if all blocks had no samples.
+func (c *BucketCompactor) mergeInPasses(dest string, dirs []string, logger log.Logger) ([]string, error) {
+	// Don't modify the input slice.
+	dirs = append([]string(nil), dirs...)
+
+	for len(dirs) > c.maxBlocksMergedPerPass {
+		pass := dirs[:c.maxBlocksMergedPerPass]
+
+		begin := time.Now()
+		id, err := c.comp.Compact(dest, pass, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "merge blocks %v", pass)
+		}
+		c.metrics.intermediateMergePasses.Inc()
+
+		elapsed := time.Since(begin)
+		level.Info(logger).Log("msg", "merged blocks into an intermediate block", "intermediate_block", id, "blocks", fmt.Sprintf("%v", pass), "blocks_left", len(dirs)-len(pass), "duration", elapsed, "duration_ms", elapsed.Milliseconds())
+
+		for _, dir := range pass {
+			if err := os.RemoveAll(dir); err != nil {
+				return nil, errors.Wrapf(err, "remove merged block dir %s", dir)
+			}
+		}
+
+		dirs = dirs[len(pass):]
+		// The intermediate block is empty if the merged blocks had no samples.
+		if id != (ulid.ULID{}) {
+			dirs = append(dirs, filepath.Join(dest, id.String()))
+		}
+	}
+
+	return dirs, nil
+}
+
 // convertCompactionResultToForEachJobs filters out empty ULIDs.
 // When handling result of split compactions, shard index is index in the slice returned by compaction.
 func convertCompactionResultToForEachJobs(compactedBlocks []ulid.ULID, splitJob bool, jobLogger log.Logger) []ulidWithShardIndex {
@@ -592,6 +647,7 @@ type BucketCompactorMetrics struct {
 	blocksMarkedForDeletion      prometheus.Counter
 	blocksMarkedForNoCompact     prometheus.Counter
 	blocksMaxTimeDelta           prometheus.Histogram
+	intermediateMergePasses      prometheus.Counter
 }
 
 // NewBucketCompactorMetrics makes a new BucketCompactorMetrics.
@@ -624,6 +680,10 @@ func NewBucketCompactorMetrics(blocksMarkedForDeletion prometheus.Counter, reg p
 			Help:    "Difference between now and the max time of a block being compacted in seconds.",
 			Buckets: prometheus.LinearBuckets(86400, 43200, 8), // 1 to 5 days, in 12 hour intervals
 		}),
+		intermediateMergePasses: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_intermediate_merge_passes_total",
+			Help: "Total number of merge passes writing an intermediate block to the local disk, run by compaction jobs merging more blocks than the configured max blocks merged per pass.",
+		}),
 	}
 }
 
@@ -649,6 +709,15 @@ type BucketCompactor struct {
 	sortJobs                       JobsOrderFunc
 	blockSyncConcurrency           int
 	metrics                        *BucketCompactorMetrics
+
+	// Optional history of the compaction jobs run.
+	jobsHistory *compactionJobsHistory
+
+	// maxBlocksPerJob is the max number of blocks compacted by a job. 0 means no limit.
+	maxBlocksPerJob int
+
+	// maxBlocksMergedPerPass is the max number of blocks merged at once. 0 means no limit.
+	maxBlocksMergedPerPass int
 }
 
 // NewBucketCompactor creates a new bucket compactor.
@@ -739,7 +808,9 @@ func (c *BucketCompactor) Compact(ctx context.Context, maxCompactionTime time.Du
 
 					c.metrics.groupCompactionRunsStarted.Inc()
 
+					jobStartedAt := time.Now()
 					shouldRerunJob, compactedBlockIDs, err := c.runCompactionJob(workCtx, g)
+					c.jobsHistory.add(g, jobStartedAt, err)
 					if err == nil {
 						c.metrics.groupCompactionRunsCompleted.Inc()
 						if hasNonZeroULIDs(compactedBlockIDs) {
@@ -800,7 +871,8 @@ func (c *BucketCompactor) Compact(ctx context.Context, maxCompactionTime time.Du
 			return errors.Wrap(err, "garbage")
 		}
 
-		jobs, err := c.grouper.Groups(c.sy.Metas())
+		// Downsampled blocks are never compacted.
+		jobs, err := c.grouper.Groups(excludeDownsampledBlocks(c.sy.Metas()))
 		if err != nil {
 			return errors.Wrap(err, "build compaction jobs")
 		}
@@ -16,6 +16,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -434,6 +435,125 @@ func TestGroupCompactE2E(t *testing.T) {
 	})
 }
 
+func TestGroupCompactE2E_OverlappingBlocksWithBoundedMerges(t *testing.T) {
+	tests := map[string]struct {
+		maxBlocksPerJob                 int
+		maxBlocksMergedPerPass          int
+		expectedGroupCompactions        float64
+		expectedIntermediateMergePasses float64
+		expectedCompactionLevel         int
+	}{
+		"no limits": {
+			expectedGroupCompactions: 1,
+			expectedCompactionLevel:  2,
+		},
+		"max blocks merged per pass": {
+			maxBlocksMergedPerPass:          2,
+			expectedGroupCompactions:        1,
+			expectedIntermediateMergePasses: 3,
+			expectedCompactionLevel:         4,
+		},
+		"max blocks per job": {
+			maxBlocksPerJob:          3,
+			expectedGroupCompactions: 2,
+			expectedCompactionLevel:  3,
+		},
+		"max blocks per job at the minimum": {
+			// The compaction level depends on the order in which the blocks having the same
+			// min time are compacted, so it's not checked.
+			maxBlocksPerJob:          2,
+			expectedGroupCompactions: 4,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+			defer cancel()
+
+			bkt := objstore.NewInMemBucket()
+			logger := log.NewNopLogger()
+			extLabels := labels.Labels{{Name: "e1", Value: "1"}}
+
+			// Overlapping blocks, like the ones produced by out-of-order ingestion or backfilling.
+			var specs []blockgenSpec
+			for i := 0; i < 5; i++ {
+				specs = append(specs, blockgenSpec{
+					numSamples: 100, mint: 0, maxt: 1000, extLset: extLabels, res: 0,
+					series: []labels.Labels{
+						{{Name: "a", Value: "common"}},
+						{{Name: "a", Value: strconv.Itoa(i)}},
+					},
+				})
+			}
+			// Due to TSDB compaction delay (not compacting fresh block), we need one more block to be pushed to trigger compaction.
+			specs = append(specs, blockgenSpec{
+				numSamples: 100, mint: 3000, maxt: 4000, extLset: extLabels, res: 0,
+				series: []labels.Labels{{{Name: "a", Value: "fresh"}}},
+			})
+			metas := createAndUpload(t, bkt, specs, nil)
+
+			ignoreDeletionMarkFilter := NewExcludeMarkedForDeletionFilter(objstore.WithNoopInstr(bkt))
+			duplicateBlocksFilter := NewShardAwareDeduplicateFilter()
+			metaFetcher, err := block.NewMetaFetcher(nil, 32, objstore.WithNoopInstr(bkt), "", nil, []block.MetadataFilter{
+				ignoreDeletionMarkFilter,
+				duplicateBlocksFilter,
+			})
+			require.NoError(t, err)
+
+			blocksMarkedForDeletion := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+			sy, err := NewMetaSyncer(nil, nil, bkt, metaFetcher, duplicateBlocksFilter, ignoreDeletionMarkFilter, blocksMarkedForDeletion)
+			require.NoError(t, err)
+
+			comp, err := tsdb.NewLeveledCompactor(ctx, nil, logger, []int64{1000, 3000}, nil, nil, true)
+			require.NoError(t, err)
+
+			planner := NewSplitAndMergePlanner([]int64{1000, 3000})
+			grouper := NewSplitAndMergeGrouper("user-1", []int64{1000, 3000}, 0, 0, logger)
+			metrics := NewBucketCompactorMetrics(blocksMarkedForDeletion, prometheus.NewPedanticRegistry())
+			bComp, err := NewBucketCompactor(logger, sy, grouper, planner, comp, t.TempDir(), bkt, 1, true, ownAllJobs, sortJobsByNewestBlocksFirst, 4, metrics)
+			require.NoError(t, err)
+			bComp.maxBlocksPerJob = testData.maxBlocksPerJob
+			bComp.maxBlocksMergedPerPass = testData.maxBlocksMergedPerPass
+
+			require.NoError(t, bComp.Compact(ctx, 0))
+			assert.Equal(t, testData.expectedGroupCompactions, promtest.ToFloat64(metrics.groupCompactions))
+			assert.Equal(t, testData.expectedIntermediateMergePasses, promtest.ToFloat64(metrics.intermediateMergePasses))
+			assert.Equal(t, 0.0, promtest.ToFloat64(metrics.groupCompactionRunsFailed))
+
+			// All overlapping blocks have been compacted into a single block.
+			var compacted []metadata.Meta
+			require.NoError(t, bkt.Iter(ctx, "", func(n string) error {
+				id, ok := block.IsBlockDir(n)
+				if !ok || id == metas[5].ULID {
+					return nil
+				}
+				if exists, err := bkt.Exists(ctx, path.Join(id.String(), metadata.DeletionMarkFilename)); err != nil || exists {
+					return err
+				}
+
+				meta, err := block.DownloadMeta(ctx, logger, bkt, id)
+				if err != nil {
+					return err
+				}
+				compacted = append(compacted, meta)
+				return nil
+			}))
+
+			require.Len(t, compacted, 1)
+			meta := compacted[0]
+			assert.Equal(t, int64(0), meta.MinTime)
+			assert.Equal(t, int64(1000), meta.MaxTime)
+			assert.Equal(t, uint64(6), meta.Stats.NumSeries)
+			assert.Equal(t, uint64(6*100), meta.Stats.NumSamples)
+			if testData.expectedCompactionLevel > 0 {
+				assert.Equal(t, testData.expectedCompactionLevel, meta.Compaction.Level)
+			}
+			assert.ElementsMatch(t, []ulid.ULID{metas[0].ULID, metas[1].ULID, metas[2].ULID, metas[3].ULID, metas[4].ULID}, meta.Compaction.Sources)
+		})
+	}
+}
+
 type blockgenSpec struct {
 	mint, maxt int64
 	series     []labels.Labels
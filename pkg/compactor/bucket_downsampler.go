@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/runutil"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/downsample"
+)
+
+// BucketDownsamplerMetrics holds the metrics tracked by BucketDownsampler.
+type BucketDownsamplerMetrics struct {
+	blocksDownsampled    *prometheus.CounterVec
+	downsamplingFailures *prometheus.CounterVec
+}
+
+// NewBucketDownsamplerMetrics makes a new BucketDownsamplerMetrics.
+func NewBucketDownsamplerMetrics(reg prometheus.Registerer) *BucketDownsamplerMetrics {
+	return &BucketDownsamplerMetrics{
+		blocksDownsampled: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_downsampled_total",
+			Help: "Total number of blocks downsampled by the compactor, by target resolution.",
+		}, []string{"resolution"}),
+		downsamplingFailures: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_block_downsampling_failures_total",
+			Help: "Total number of blocks the compactor failed to downsample, by target resolution.",
+		}, []string{"resolution"}),
+	}
+}
+
+type ownBlockFunc func(blockID ulid.ULID) (bool, error)
+
+// ownAllBlocks is an ownBlockFunc that always return true.
+var ownAllBlocks = func(blockID ulid.ULID) (bool, error) {
+	return true, nil
+}
+
+// BucketDownsampler downsamples the compacted blocks in a bucket to 5m and 1h resolutions.
+// Raw blocks are downsampled to 5m resolution once their compaction is complete, and blocks
+// at 5m resolution are then downsampled to 1h resolution. Downsampled blocks are never compacted.
+type BucketDownsampler struct {
+	logger        log.Logger
+	sy            *Syncer
+	grouper       Grouper
+	bkt           objstore.Bucket
+	downsampleDir string
+	largestRange  int64
+	ownBlock      ownBlockFunc
+	metrics       *BucketDownsamplerMetrics
+}
+
+// NewBucketDownsampler creates a new bucket downsampler. The largestRange is the largest
+// compaction range, in milliseconds: only blocks whose largest compaction range is complete
+// are downsampled.
+func NewBucketDownsampler(
+	logger log.Logger,
+	sy *Syncer,
+	grouper Grouper,
+	bkt objstore.Bucket,
+	downsampleDir string,
+	largestRange int64,
+	ownBlock ownBlockFunc,
+	metrics *BucketDownsamplerMetrics,
+) *BucketDownsampler {
+	return &BucketDownsampler{
+		logger:        logger,
+		sy:            sy,
+		grouper:       grouper,
+		bkt:           bkt,
+		downsampleDir: downsampleDir,
+		largestRange:  largestRange,
+		ownBlock:      ownBlock,
+		metrics:       metrics,
+	}
+}
+
+// Downsample downsamples all the blocks which are ready to be downsampled and owned by this instance.
+func (d *BucketDownsampler) Downsample(ctx context.Context) error {
+	defer func() {
+		if err := os.RemoveAll(d.downsampleDir); err != nil {
+			level.Error(d.logger).Log("msg", "failed to remove downsampling work directory", "path", d.downsampleDir, "err", err)
+		}
+	}()
+
+	// Blocks at 5m resolution are downsampled to 1h resolution, so we downsample
+	// to 5m first and sync the metas again before downsampling to 1h.
+	for _, resolution := range []int64{downsample.Resolution5m, downsample.Resolution1h} {
+		if err := d.sy.SyncMetas(ctx); err != nil {
+			return errors.Wrap(err, "sync")
+		}
+
+		metas := d.sy.Metas()
+
+		// Blocks which are going to be compacted must not be downsampled yet.
+		jobs, err := d.grouper.Groups(excludeDownsampledBlocks(metas))
+		if err != nil {
+			return errors.Wrap(err, "build compaction jobs")
+		}
+
+		for _, meta := range planDownsampling(metas, jobs, d.largestRange, resolution) {
+			if ok, err := d.ownBlock(meta.ULID); err != nil {
+				level.Info(d.logger).Log("msg", "skipped downsampling because unable to check whether the block is owned by the compactor instance", "block", meta.ULID, "err", err)
+				continue
+			} else if !ok {
+				continue
+			}
+
+			resolutionLabel := strconv.FormatInt(resolution, 10)
+			if err := d.downsampleBlock(ctx, meta, resolution); err != nil {
+				d.metrics.downsamplingFailures.WithLabelValues(resolutionLabel).Inc()
+				return errors.Wrapf(err, "downsample block %s to resolution %d", meta.ULID, resolution)
+			}
+			d.metrics.blocksDownsampled.WithLabelValues(resolutionLabel).Inc()
+		}
+	}
+
+	return nil
+}
+
+func (d *BucketDownsampler) downsampleBlock(ctx context.Context, meta *metadata.Meta, resolution int64) (rerr error) {
+	blockLogger := log.With(d.logger, "block", meta.ULID, "resolution", resolution)
+	begin := time.Now()
+
+	bdir := filepath.Join(d.downsampleDir, meta.ULID.String())
+	defer func() {
+		if err := os.RemoveAll(bdir); err != nil {
+			level.Warn(blockLogger).Log("msg", "failed to remove downloaded block", "dir", bdir, "err", err)
+		}
+	}()
+
+	if err := block.Download(ctx, blockLogger, d.bkt, meta.ULID, bdir); err != nil {
+		return errors.Wrap(err, "download block")
+	}
+
+	b, err := tsdb.OpenBlock(blockLogger, bdir, downsample.NewPool())
+	if err != nil {
+		return errors.Wrap(err, "open block")
+	}
+	defer runutil.CloseWithErrCapture(&rerr, b, "downsampled source block")
+
+	id, err := downsample.Downsample(blockLogger, meta, b, d.downsampleDir, resolution)
+	if err != nil {
+		return err
+	}
+	if id == (ulid.ULID{}) {
+		level.Info(blockLogger).Log("msg", "skipped uploading the downsampled block because it contains no series")
+		return nil
+	}
+
+	resdir := filepath.Join(d.downsampleDir, id.String())
+	defer func() {
+		if err := os.RemoveAll(resdir); err != nil {
+			level.Warn(blockLogger).Log("msg", "failed to remove downsampled block", "dir", resdir, "err", err)
+		}
+	}()
+
+	// Ensure the output block is valid.
+	if err := block.VerifyIndex(blockLogger, filepath.Join(resdir, block.IndexFilename), meta.MinTime, meta.MaxTime); err != nil {
+		return errors.Wrapf(err, "invalid downsampled block %s", id)
+	}
+
+	if err := mimir_tsdb.UploadBlock(ctx, blockLogger, d.bkt, resdir, nil); err != nil {
+		return errors.Wrapf(err, "upload of %s failed", id)
+	}
+
+	elapsed := time.Since(begin)
+	level.Info(blockLogger).Log("msg", "downsampled block", "result_block", id, "duration", elapsed, "duration_ms", elapsed.Milliseconds())
+	return nil
+}
+
+// planDownsampling returns the blocks which should be downsampled to the given resolution, sorted by min time.
+// A block is downsampled once the time range of the largest compaction range it belongs to is complete, it is
+// not part of any compaction job and its sources are not fully covered yet by blocks at the given resolution.
+func planDownsampling(metas map[ulid.ULID]*metadata.Meta, compactionJobs []*Job, largestRange, resolution int64) []*metadata.Meta {
+	var sourceResolution int64
+	switch resolution {
+	case downsample.Resolution5m:
+		sourceResolution = downsample.ResolutionRaw
+	case downsample.Resolution1h:
+		sourceResolution = downsample.Resolution5m
+	default:
+		return nil
+	}
+
+	compacting := map[ulid.ULID]struct{}{}
+	for _, job := range compactionJobs {
+		for _, id := range job.IDs() {
+			compacting[id] = struct{}{}
+		}
+	}
+
+	// Sources already covered by blocks at the target resolution.
+	covered := map[ulid.ULID]struct{}{}
+	maxTime := int64(math.MinInt64)
+	for _, m := range metas {
+		if m.MaxTime > maxTime {
+			maxTime = m.MaxTime
+		}
+		if m.Thanos.Downsample.Resolution == resolution {
+			for _, id := range m.Compaction.Sources {
+				covered[id] = struct{}{}
+			}
+		}
+	}
+
+	var res []*metadata.Meta
+	for _, m := range metas {
+		if m.Thanos.Downsample.Resolution != sourceResolution {
+			continue
+		}
+		if _, ok := compacting[m.ULID]; ok {
+			continue
+		}
+
+		// Blocks in the most recent time range may still be compacted with blocks not uploaded yet.
+		if getRangeStart(m, largestRange)+largestRange > maxTime {
+			continue
+		}
+
+		if allSourcesCovered(m, covered) {
+			continue
+		}
+
+		res = append(res, m)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].MinTime != res[j].MinTime {
+			return res[i].MinTime < res[j].MinTime
+		}
+		return res[i].ULID.Compare(res[j].ULID) < 0
+	})
+
+	return res
+}
+
+func allSourcesCovered(m *metadata.Meta, covered map[ulid.ULID]struct{}) bool {
+	// Blocks without sources can't be covered by any other block.
+	if len(m.Compaction.Sources) == 0 {
+		return false
+	}
+
+	for _, id := range m.Compaction.Sources {
+		if _, ok := covered[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// excludeDownsampledBlocks returns the blocks which have not been produced by downsampling.
+func excludeDownsampledBlocks(metas map[ulid.ULID]*metadata.Meta) map[ulid.ULID]*metadata.Meta {
+	res := make(map[ulid.ULID]*metadata.Meta, len(metas))
+	for id, m := range metas {
+		switch m.Thanos.Downsample.Resolution {
+		case downsample.Resolution5m, downsample.Resolution1h:
+			continue
+		}
+		res[id] = m
+	}
+	return res
+}
@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/downsample"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+func TestPlanDownsampling(t *testing.T) {
+	const day = int64(24 * time.Hour / time.Millisecond)
+
+	newMeta := func(id uint64, mint, maxt, resolution int64, sources ...ulid.ULID) *metadata.Meta {
+		m := &metadata.Meta{}
+		m.ULID = ulid.MustNew(id, nil)
+		m.MinTime = mint
+		m.MaxTime = maxt
+		m.Thanos.Downsample.Resolution = resolution
+		m.Compaction.Sources = sources
+		if len(sources) == 0 {
+			m.Compaction.Sources = []ulid.ULID{m.ULID}
+		}
+		return m
+	}
+
+	var (
+		// Raw blocks: the first two days are fully compacted, the third one is not complete yet.
+		raw1 = newMeta(1, 0, day, downsample.ResolutionRaw)
+		raw2 = newMeta(2, day, 2*day, downsample.ResolutionRaw)
+		raw3 = newMeta(3, 2*day, 2*day+2*time.Hour.Milliseconds(), downsample.ResolutionRaw)
+
+		// raw1 has already been downsampled to 5m resolution.
+		res5m1 = newMeta(10, 0, day, downsample.Resolution5m, raw1.Compaction.Sources...)
+	)
+
+	metas := map[ulid.ULID]*metadata.Meta{}
+	for _, m := range []*metadata.Meta{raw1, raw2, raw3, res5m1} {
+		metas[m.ULID] = m
+	}
+
+	getIDs := func(metas []*metadata.Meta) []ulid.ULID {
+		var ids []ulid.ULID
+		for _, m := range metas {
+			ids = append(ids, m.ULID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []ulid.ULID{raw2.ULID}, getIDs(planDownsampling(metas, nil, day, downsample.Resolution5m)))
+	assert.Equal(t, []ulid.ULID{res5m1.ULID}, getIDs(planDownsampling(metas, nil, day, downsample.Resolution1h)))
+
+	// Blocks which are going to be compacted are not downsampled.
+	job := NewJob("user-1", "key", labels.EmptyLabels(), downsample.ResolutionRaw, metadata.NoneFunc, false, 0, "")
+	require.NoError(t, job.AppendMeta(raw2))
+	assert.Empty(t, planDownsampling(metas, []*Job{job}, day, downsample.Resolution5m))
+
+	// A block compacted again after having been downsampled is downsampled again.
+	raw1Recompacted := newMeta(4, 0, day, downsample.ResolutionRaw, raw1.ULID, ulid.MustNew(5, nil))
+	delete(metas, raw1.ULID)
+	metas[raw1Recompacted.ULID] = raw1Recompacted
+	assert.Equal(t, []ulid.ULID{raw1Recompacted.ULID, raw2.ULID}, getIDs(planDownsampling(metas, nil, day, downsample.Resolution5m)))
+
+	// Raw blocks can't be downsampled to 1h resolution.
+	assert.Empty(t, planDownsampling(metas, nil, day, downsample.ResolutionRaw))
+}
+
+func TestBucketDownsampler_Downsample(t *testing.T) {
+	const (
+		day      = int64(24 * time.Hour / time.Millisecond)
+		interval = int64(time.Minute / time.Millisecond)
+	)
+
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	bkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	// The first block covers a full day, while the second one the first hours of the next day.
+	fullDay := uploadTestBlock(t, bkt, 0, day, interval)
+	uploadTestBlock(t, bkt, day, day+2*time.Hour.Milliseconds(), interval)
+
+	duplicateBlocksFilter := NewShardAwareDeduplicateFilter()
+	metaFetcher, err := block.NewMetaFetcher(nil, 32, objstore.WithNoopInstr(bkt), "", nil, []block.MetadataFilter{
+		duplicateBlocksFilter,
+	})
+	require.NoError(t, err)
+
+	blocksMarkedForDeletion := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+	sy, err := NewMetaSyncer(nil, nil, bkt, metaFetcher, duplicateBlocksFilter, NewExcludeMarkedForDeletionFilter(nil), blocksMarkedForDeletion)
+	require.NoError(t, err)
+
+	reg := prometheus.NewPedanticRegistry()
+	grouper := NewSplitAndMergeGrouper("user-1", []int64{2 * time.Hour.Milliseconds(), day}, 0, 0, logger)
+	downsampler := NewBucketDownsampler(logger, sy, grouper, bkt, t.TempDir(), day, ownAllBlocks, NewBucketDownsamplerMetrics(reg))
+
+	require.NoError(t, downsampler.Downsample(ctx))
+
+	// Only the full day block has been downsampled, to both resolutions.
+	require.NoError(t, sy.SyncMetas(ctx))
+	downsampled := map[int64]*metadata.Meta{}
+	for _, m := range sy.Metas() {
+		if res := m.Thanos.Downsample.Resolution; res != downsample.ResolutionRaw {
+			require.NotContains(t, downsampled, res)
+			downsampled[res] = m
+		}
+	}
+
+	require.Len(t, downsampled, 2)
+	for _, res := range []int64{downsample.Resolution5m, downsample.Resolution1h} {
+		require.Contains(t, downsampled, res)
+		assert.Equal(t, []ulid.ULID{fullDay.ULID}, downsampled[res].Compaction.Sources)
+		assert.Equal(t, fullDay.MinTime, downsampled[res].MinTime)
+		assert.Equal(t, fullDay.MaxTime, downsampled[res].MaxTime)
+	}
+
+	// Downsampling again is a no-op.
+	require.NoError(t, downsampler.Downsample(ctx))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_compactor_blocks_downsampled_total Total number of blocks downsampled by the compactor, by target resolution.
+		# TYPE cortex_compactor_blocks_downsampled_total counter
+		cortex_compactor_blocks_downsampled_total{resolution="300000"} 1
+		cortex_compactor_blocks_downsampled_total{resolution="3600000"} 1
+	`), "cortex_compactor_blocks_downsampled_total", "cortex_compactor_block_downsampling_failures_total"))
+}
+
+// uploadTestBlock uploads a block with a single series having a sample every interval between mint and maxt.
+func uploadTestBlock(t *testing.T, bkt objstore.Bucket, mint, maxt, interval int64) *metadata.Meta {
+	return uploadTestBlockWithSeries(t, bkt, mint, maxt, interval, labels.FromStrings("__name__", "series_1"))
+}
+
+// uploadTestBlockWithSeries uploads a block with the given series, each one having a sample every interval between mint and maxt.
+func uploadTestBlockWithSeries(t *testing.T, bkt objstore.Bucket, mint, maxt, interval int64, series ...labels.Labels) *metadata.Meta {
+	var chks []chunks.Meta
+	for ts := mint; ts < maxt; {
+		chk := chunkenc.NewXORChunk()
+		app, err := chk.Appender()
+		require.NoError(t, err)
+
+		chkMint := ts
+		for ; ts < maxt && chk.NumSamples() < 120; ts += interval {
+			app.Append(ts, float64(ts))
+		}
+		chks = append(chks, chunks.Meta{Chunk: chk, MinTime: chkMint, MaxTime: ts - interval})
+	}
+
+	specs := make(mimir_testutil.BlockSeriesSpecs, 0, len(series))
+	for _, lset := range series {
+		// Each series needs its own chunk metas, because the block generator updates their references.
+		specs = append(specs, &mimir_testutil.BlockSeriesSpec{Labels: lset, Chunks: append([]chunks.Meta(nil), chks...)})
+	}
+
+	dir := t.TempDir()
+	meta, err := mimir_testutil.GenerateBlockFromSpec("user-1", dir, specs)
+	require.NoError(t, err)
+	require.NoError(t, mimir_tsdb.UploadBlock(context.Background(), log.NewNopLogger(), bkt, filepath.Join(dir, meta.ULID.String()), nil))
+
+	return meta
+}
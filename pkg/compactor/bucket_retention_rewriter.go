@@ -0,0 +1,474 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"github.com/prometheus/prometheus/tsdb/tombstones"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/runutil"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/downsample"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const (
+	// retentionRequestIDPrefix is the prefix of the ID of the deletions applied by the
+	// BucketRetentionRewriter, as recorded in the rewrites of the block meta.json.
+	retentionRequestIDPrefix = "compactor-retention:"
+
+	// retentionDefaultRequestID is the ID of the deletion of the series not matching any retention rule.
+	retentionDefaultRequestID = retentionRequestIDPrefix + "default"
+)
+
+// BucketRetentionRewriterMetrics holds the metrics tracked by BucketRetentionRewriter.
+type BucketRetentionRewriterMetrics struct {
+	blocksRewritten         prometheus.Counter
+	blocksRewriteFailures   prometheus.Counter
+	seriesDeleted           prometheus.Counter
+	blocksMarkedForDeletion prometheus.Counter
+}
+
+// NewBucketRetentionRewriterMetrics makes a new BucketRetentionRewriterMetrics.
+func NewBucketRetentionRewriterMetrics(blocksMarkedForDeletion prometheus.Counter, reg prometheus.Registerer) *BucketRetentionRewriterMetrics {
+	return &BucketRetentionRewriterMetrics{
+		blocksRewritten: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_retention_blocks_rewritten_total",
+			Help: "Total number of blocks rewritten by the compactor to delete the series which aged past their retention rule period.",
+		}),
+		blocksRewriteFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_retention_block_rewrite_failures_total",
+			Help: "Total number of blocks the compactor failed to rewrite to apply the retention rules.",
+		}),
+		seriesDeleted: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_retention_series_deleted_total",
+			Help: "Total number of series deleted from blocks by the compactor because aged past their retention rule period.",
+		}),
+		blocksMarkedForDeletion: blocksMarkedForDeletion,
+	}
+}
+
+// BucketRetentionRewriter applies the per-series retention rules to the blocks in a bucket. Blocks
+// containing series which aged past their retention period are rewritten without such series, and
+// the original blocks are marked for deletion. Blocks are deleted altogether by the BlocksCleaner
+// once all their series aged past their retention period.
+type BucketRetentionRewriter struct {
+	logger          log.Logger
+	sy              *Syncer
+	grouper         Grouper
+	bkt             objstore.Bucket
+	rewriteDir      string
+	rules           validation.RetentionRules
+	retentionPeriod func(resolution int64) time.Duration
+	ownBlock        ownBlockFunc
+	metrics         *BucketRetentionRewriterMetrics
+}
+
+// NewBucketRetentionRewriter creates a new bucket retention rewriter. The retentionPeriod function returns
+// the retention period of the series not matching any rule, in blocks at the given resolution.
+func NewBucketRetentionRewriter(
+	logger log.Logger,
+	sy *Syncer,
+	grouper Grouper,
+	bkt objstore.Bucket,
+	rewriteDir string,
+	rules validation.RetentionRules,
+	retentionPeriod func(resolution int64) time.Duration,
+	ownBlock ownBlockFunc,
+	metrics *BucketRetentionRewriterMetrics,
+) *BucketRetentionRewriter {
+	return &BucketRetentionRewriter{
+		logger:          logger,
+		sy:              sy,
+		grouper:         grouper,
+		bkt:             bkt,
+		rewriteDir:      rewriteDir,
+		rules:           rules,
+		retentionPeriod: retentionPeriod,
+		ownBlock:        ownBlock,
+		metrics:         metrics,
+	}
+}
+
+// retentionRewrite is a block to rewrite, along with the deletions to apply.
+type retentionRewrite struct {
+	meta      *metadata.Meta
+	deletions []metadata.DeletionRequest
+}
+
+// Rewrite rewrites all the blocks containing expired series and owned by this instance.
+func (r *BucketRetentionRewriter) Rewrite(ctx context.Context) error {
+	defer func() {
+		if err := os.RemoveAll(r.rewriteDir); err != nil {
+			level.Error(r.logger).Log("msg", "failed to remove retention rewrite work directory", "path", r.rewriteDir, "err", err)
+		}
+	}()
+
+	if err := r.sy.SyncMetas(ctx); err != nil {
+		return errors.Wrap(err, "sync")
+	}
+
+	metas := r.sy.Metas()
+
+	// Blocks which are going to be compacted will be rewritten once compacted.
+	jobs, err := r.grouper.Groups(excludeDownsampledBlocks(metas))
+	if err != nil {
+		return errors.Wrap(err, "build compaction jobs")
+	}
+
+	for _, rewrite := range planRetentionRewrites(metas, jobs, r.rules, r.retentionPeriod, time.Now()) {
+		if ok, err := r.ownBlock(rewrite.meta.ULID); err != nil {
+			level.Info(r.logger).Log("msg", "skipped retention rewrite because unable to check whether the block is owned by the compactor instance", "block", rewrite.meta.ULID, "err", err)
+			continue
+		} else if !ok {
+			continue
+		}
+
+		if err := r.rewriteBlock(ctx, rewrite); err != nil {
+			r.metrics.blocksRewriteFailures.Inc()
+			return errors.Wrapf(err, "rewrite block %s", rewrite.meta.ULID)
+		}
+		r.metrics.blocksRewritten.Inc()
+	}
+
+	return nil
+}
+
+func (r *BucketRetentionRewriter) rewriteBlock(ctx context.Context, rewrite retentionRewrite) (rerr error) {
+	meta := rewrite.meta
+	blockLogger := log.With(r.logger, "block", meta.ULID)
+	begin := time.Now()
+
+	bdir := filepath.Join(r.rewriteDir, meta.ULID.String())
+	defer func() {
+		if err := os.RemoveAll(bdir); err != nil {
+			level.Warn(blockLogger).Log("msg", "failed to remove downloaded block", "dir", bdir, "err", err)
+		}
+	}()
+
+	if err := block.Download(ctx, blockLogger, r.bkt, meta.ULID, bdir); err != nil {
+		return errors.Wrap(err, "download block")
+	}
+
+	// Downsampled blocks contain aggregated chunks, which are copied as is.
+	b, err := tsdb.OpenBlock(blockLogger, bdir, downsample.NewPool())
+	if err != nil {
+		return errors.Wrap(err, "open block")
+	}
+	defer runutil.CloseWithErrCapture(&rerr, b, "rewritten source block")
+
+	deleted := map[string]struct{}{}
+	for _, d := range rewrite.deletions {
+		deleted[d.RequestID] = struct{}{}
+	}
+	keep := func(lset labels.Labels) bool {
+		_, ok := deleted[retentionRequestID(r.rules, lset)]
+		return !ok
+	}
+
+	id, deletedSeries, err := writeRetentionRewrittenBlock(blockLogger, meta, b, r.rewriteDir, keep, rewrite.deletions)
+	if err != nil {
+		return err
+	}
+	r.metrics.seriesDeleted.Add(float64(deletedSeries))
+
+	if id != (ulid.ULID{}) {
+		resdir := filepath.Join(r.rewriteDir, id.String())
+		defer func() {
+			if err := os.RemoveAll(resdir); err != nil {
+				level.Warn(blockLogger).Log("msg", "failed to remove rewritten block", "dir", resdir, "err", err)
+			}
+		}()
+
+		// Ensure the output block is valid.
+		if err := block.VerifyIndex(blockLogger, filepath.Join(resdir, block.IndexFilename), meta.MinTime, meta.MaxTime); err != nil {
+			return errors.Wrapf(err, "invalid rewritten block %s", id)
+		}
+
+		if err := mimir_tsdb.UploadBlock(ctx, blockLogger, r.bkt, resdir, nil); err != nil {
+			return errors.Wrapf(err, "upload of %s failed", id)
+		}
+	} else {
+		level.Info(blockLogger).Log("msg", "all series in the block aged past their retention period, skipped uploading the rewritten block")
+	}
+
+	// Spawn a new context so we always mark a block for deletion in full on shutdown.
+	delCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if err := block.MarkForDeletion(delCtx, blockLogger, r.bkt, meta.ULID, "source of block rewritten by retention rules", r.metrics.blocksMarkedForDeletion); err != nil {
+		return errors.Wrapf(err, "mark block %s for deletion from bucket", meta.ULID)
+	}
+
+	elapsed := time.Since(begin)
+	level.Info(blockLogger).Log("msg", "rewritten block to apply retention rules", "result_block", id, "deleted_series", deletedSeries, "duration", elapsed, "duration_ms", elapsed.Milliseconds())
+	return nil
+}
+
+// planRetentionRewrites returns the blocks which contain series aged past their retention period, sorted by
+// min time, along with the deletions to apply. A block is rewritten only if it is not part of any compaction
+// job, some but not all of its series aged past their retention period, and the deletions of such series
+// have not been applied yet. Series age out of a block once the whole block time range is past their
+// retention period. Blocks whose series are all aged past their retention period are deleted by the
+// BlocksCleaner.
+func planRetentionRewrites(metas map[ulid.ULID]*metadata.Meta, compactionJobs []*Job, rules validation.RetentionRules, retentionPeriod func(resolution int64) time.Duration, now time.Time) []retentionRewrite {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	compacting := map[ulid.ULID]struct{}{}
+	for _, job := range compactionJobs {
+		for _, id := range job.IDs() {
+			compacting[id] = struct{}{}
+		}
+	}
+
+	var res []retentionRewrite
+	for _, m := range metas {
+		if _, ok := compacting[m.ULID]; ok {
+			continue
+		}
+
+		applied := map[string]struct{}{}
+		for _, rw := range m.Thanos.Rewrites {
+			for _, d := range rw.DeletionsApplied {
+				applied[d.RequestID] = struct{}{}
+			}
+		}
+
+		isExpired := func(period time.Duration) bool {
+			return period > 0 && m.MaxTime <= now.Add(-period).UnixMilli()
+		}
+
+		var (
+			deletions  []metadata.DeletionRequest
+			allExpired = true
+			intervals  = tombstones.Intervals{{Mint: m.MinTime, Maxt: m.MaxTime}}
+		)
+
+		for _, rule := range rules {
+			if !isExpired(time.Duration(rule.Period)) {
+				allExpired = false
+				continue
+			}
+
+			requestID := retentionRuleRequestID(rule)
+			if _, ok := applied[requestID]; !ok {
+				deletions = append(deletions, metadata.DeletionRequest{Matchers: rule.Matchers(), Intervals: intervals, RequestID: requestID})
+			}
+		}
+
+		if !isExpired(retentionPeriod(m.Thanos.Downsample.Resolution)) {
+			allExpired = false
+		} else if _, ok := applied[retentionDefaultRequestID]; !ok {
+			deletions = append(deletions, metadata.DeletionRequest{Intervals: intervals, RequestID: retentionDefaultRequestID})
+		}
+
+		if allExpired || len(deletions) == 0 {
+			continue
+		}
+
+		res = append(res, retentionRewrite{meta: m, deletions: deletions})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].meta.MinTime != res[j].meta.MinTime {
+			return res[i].meta.MinTime < res[j].meta.MinTime
+		}
+		return res[i].meta.ULID.Compare(res[j].meta.ULID) < 0
+	})
+
+	return res
+}
+
+func retentionRuleRequestID(rule validation.RetentionRule) string {
+	return retentionRequestIDPrefix + rule.Selector
+}
+
+// retentionRequestID returns the ID of the deletion which removes the series with the given labels.
+func retentionRequestID(rules validation.RetentionRules, lset labels.Labels) string {
+	if rule, ok := rules.Match(lset); ok {
+		return retentionRuleRequestID(rule)
+	}
+	return retentionDefaultRequestID
+}
+
+// writeRetentionRewrittenBlock writes a copy of the block into dir, keeping only the series for which keep
+// returns true, and returns the new block ID along with the number of series not kept. The new block keeps
+// the compaction sources of the original one, and its meta.json records the applied deletions.
+// If the rewritten block would contain no series, an empty ULID is returned.
+func writeRetentionRewrittenBlock(logger log.Logger, origMeta *metadata.Meta, b tsdb.BlockReader, dir string, keep func(labels.Labels) bool, deletions []metadata.DeletionRequest) (id ulid.ULID, deletedSeries int, err error) {
+	indexr, err := b.Index()
+	if err != nil {
+		return id, 0, errors.Wrap(err, "open index reader")
+	}
+	defer runutil.CloseWithErrCapture(&err, indexr, "rewrite index reader")
+
+	chunkr, err := b.Chunks()
+	if err != nil {
+		return id, 0, errors.Wrap(err, "open chunk reader")
+	}
+	defer runutil.CloseWithErrCapture(&err, chunkr, "rewrite chunk reader")
+
+	id = ulid.MustNew(ulid.Now(), rand.Reader)
+	blockDir := filepath.Join(dir, id.String())
+
+	if err := os.MkdirAll(blockDir, 0o750); err != nil {
+		return id, 0, errors.Wrap(err, "create block directory")
+	}
+
+	// Remove the partially written block in case of failure.
+	defer func() {
+		if err != nil {
+			if rerr := os.RemoveAll(blockDir); rerr != nil {
+				err = errors.Wrapf(err, "failed to remove the partially rewritten block: %v", rerr)
+			}
+		}
+	}()
+
+	stats, deletedSeries, err := copySeries(indexr, chunkr, blockDir, keep)
+	if err != nil {
+		return id, 0, err
+	}
+
+	if stats.NumSeries == 0 {
+		if err := os.RemoveAll(blockDir); err != nil {
+			return id, 0, errors.Wrap(err, "remove empty rewritten block")
+		}
+		return ulid.ULID{}, deletedSeries, nil
+	}
+
+	rewrite := metadata.Rewrite{
+		Sources:          origMeta.Compaction.Sources,
+		DeletionsApplied: deletions,
+	}
+	if err := writeRewrittenBlockMeta(logger, origMeta, id, blockDir, stats, rewrite); err != nil {
+		return id, 0, err
+	}
+
+	return id, deletedSeries, nil
+}
+
+// writeRewrittenBlockMeta writes the meta.json of the block with the given ID, rewritten from the original block.
+// The rewritten block keeps the time range, compaction sources and external labels of the original block, and
+// records the rewrite.
+func writeRewrittenBlockMeta(logger log.Logger, origMeta *metadata.Meta, id ulid.ULID, blockDir string, stats tsdb.BlockStats, rewrite metadata.Rewrite) error {
+	thanosMeta := origMeta.Thanos
+	thanosMeta.Labels = make(map[string]string, len(origMeta.Thanos.Labels))
+	for k, v := range origMeta.Thanos.Labels {
+		thanosMeta.Labels[k] = v
+	}
+	thanosMeta.Rewrites = append(append([]metadata.Rewrite(nil), origMeta.Thanos.Rewrites...), rewrite)
+	thanosMeta.Source = metadata.BucketRewriteSource
+	thanosMeta.SegmentFiles = block.GetSegmentFiles(blockDir)
+	thanosMeta.Files = nil
+
+	meta := &metadata.Meta{
+		BlockMeta: tsdb.BlockMeta{
+			ULID:       id,
+			MinTime:    origMeta.MinTime,
+			MaxTime:    origMeta.MaxTime,
+			Stats:      stats,
+			Compaction: origMeta.Compaction,
+			Version:    metadata.TSDBVersion1,
+		},
+		Thanos: thanosMeta,
+	}
+
+	return errors.Wrap(meta.WriteToDir(logger, blockDir), "write meta")
+}
+
+// copySeries copies the series for which keep returns true, along with their chunks, into blockDir.
+func copySeries(indexr tsdb.IndexReader, chunkr tsdb.ChunkReader, blockDir string, keep func(labels.Labels) bool) (stats tsdb.BlockStats, deletedSeries int, err error) {
+	chunkw, err := chunks.NewWriter(filepath.Join(blockDir, block.ChunksDirname))
+	if err != nil {
+		return stats, 0, errors.Wrap(err, "open chunk writer")
+	}
+	defer runutil.CloseWithErrCapture(&err, chunkw, "rewrite chunk writer")
+
+	indexw, err := index.NewWriter(context.Background(), filepath.Join(blockDir, block.IndexFilename))
+	if err != nil {
+		return stats, 0, errors.Wrap(err, "open index writer")
+	}
+	defer runutil.CloseWithErrCapture(&err, indexw, "rewrite index writer")
+
+	// Symbols must be added before series. Symbols only used by deleted series are kept, which is harmless.
+	symbols := indexr.Symbols()
+	for symbols.Next() {
+		if err := indexw.AddSymbol(symbols.At()); err != nil {
+			return stats, 0, errors.Wrap(err, "add symbol")
+		}
+	}
+	if err := symbols.Err(); err != nil {
+		return stats, 0, errors.Wrap(err, "iterate symbols")
+	}
+
+	postings, err := indexr.Postings(index.AllPostingsKey())
+	if err != nil {
+		return stats, 0, errors.Wrap(err, "get all postings")
+	}
+	postings = indexr.SortedPostings(postings)
+
+	var (
+		lset labels.Labels
+		chks []chunks.Meta
+		ref  storage.SeriesRef
+	)
+
+	for postings.Next() {
+		if err := indexr.Series(postings.At(), &lset, &chks); err != nil {
+			return stats, 0, errors.Wrapf(err, "get series %d", postings.At())
+		}
+
+		if !keep(lset) {
+			deletedSeries++
+			continue
+		}
+
+		for i := range chks {
+			chk, err := chunkr.Chunk(chks[i])
+			if err != nil {
+				return stats, 0, errors.Wrapf(err, "get chunk %d, series %d", chks[i].Ref, postings.At())
+			}
+			chks[i].Chunk = chk
+		}
+
+		if err := chunkw.WriteChunks(chks...); err != nil {
+			return stats, 0, errors.Wrapf(err, "write chunks of series %d", postings.At())
+		}
+		if err := indexw.AddSeries(ref, lset, chks...); err != nil {
+			return stats, 0, errors.Wrapf(err, "add series %d", postings.At())
+		}
+		ref++
+
+		stats.NumSeries++
+		stats.NumChunks += uint64(len(chks))
+		for _, c := range chks {
+			stats.NumSamples += uint64(c.Chunk.NumSamples())
+		}
+	}
+	if err := postings.Err(); err != nil {
+		return stats, 0, errors.Wrap(err, "iterate series")
+	}
+
+	return stats, deletedSeries, nil
+}
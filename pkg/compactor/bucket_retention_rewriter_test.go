@@ -0,0 +1,227 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/downsample"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestPlanRetentionRewrites(t *testing.T) {
+	const day = 24 * time.Hour
+
+	var rules validation.RetentionRules
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- selector: '{env="dev"}'
+  period: 7d
+- selector: '{__name__=~"slo_.*"}'
+  period: 2y
+`), &rules))
+
+	now := time.Now()
+	newMeta := func(id uint64, age time.Duration, resolution int64, appliedRequestIDs ...string) *metadata.Meta {
+		m := &metadata.Meta{}
+		m.ULID = ulid.MustNew(id, nil)
+		m.MaxTime = now.Add(-age).UnixMilli()
+		m.MinTime = m.MaxTime - day.Milliseconds()
+		m.Thanos.Downsample.Resolution = resolution
+		if len(appliedRequestIDs) > 0 {
+			rw := metadata.Rewrite{}
+			for _, id := range appliedRequestIDs {
+				rw.DeletionsApplied = append(rw.DeletionsApplied, metadata.DeletionRequest{RequestID: id})
+			}
+			m.Thanos.Rewrites = []metadata.Rewrite{rw}
+		}
+		return m
+	}
+
+	var (
+		devRequestID = retentionRequestIDPrefix + `{env="dev"}`
+
+		recent        = newMeta(1, day, downsample.ResolutionRaw)
+		devExpired    = newMeta(2, 10*day, downsample.ResolutionRaw)
+		devApplied    = newMeta(3, 10*day, downsample.ResolutionRaw, devRequestID)
+		defaultExpiry = newMeta(4, 40*day, downsample.ResolutionRaw)
+		allApplied    = newMeta(5, 40*day, downsample.ResolutionRaw, devRequestID, retentionDefaultRequestID)
+		allExpired    = newMeta(6, 3*365*day, downsample.ResolutionRaw)
+		downsampled   = newMeta(7, 40*day, downsample.Resolution5m)
+	)
+
+	metas := map[ulid.ULID]*metadata.Meta{}
+	for _, m := range []*metadata.Meta{recent, devExpired, devApplied, defaultExpiry, allApplied, allExpired, downsampled} {
+		metas[m.ULID] = m
+	}
+
+	// Raw blocks are retained for 30 days, and blocks at 5m resolution for 90 days.
+	retentionPeriod := func(resolution int64) time.Duration {
+		if resolution == downsample.Resolution5m {
+			return 90 * day
+		}
+		return 30 * day
+	}
+
+	getRequestIDs := func(rewrites []retentionRewrite) map[ulid.ULID][]string {
+		res := map[ulid.ULID][]string{}
+		for _, rw := range rewrites {
+			for _, d := range rw.deletions {
+				res[rw.meta.ULID] = append(res[rw.meta.ULID], d.RequestID)
+				assert.Equal(t, rw.meta.MinTime, d.Intervals[0].Mint)
+				assert.Equal(t, rw.meta.MaxTime, d.Intervals[0].Maxt)
+			}
+		}
+		return res
+	}
+
+	assert.Equal(t, map[ulid.ULID][]string{
+		devExpired.ULID:    {devRequestID},
+		defaultExpiry.ULID: {devRequestID, retentionDefaultRequestID},
+		downsampled.ULID:   {devRequestID},
+	}, getRequestIDs(planRetentionRewrites(metas, nil, rules, retentionPeriod, now)))
+
+	// Blocks which are going to be compacted are not rewritten.
+	job := NewJob("user-1", "key", labels.EmptyLabels(), downsample.ResolutionRaw, metadata.NoneFunc, false, 0, "")
+	require.NoError(t, job.AppendMeta(devExpired))
+	assert.Equal(t, map[ulid.ULID][]string{
+		defaultExpiry.ULID: {devRequestID, retentionDefaultRequestID},
+		downsampled.ULID:   {devRequestID},
+	}, getRequestIDs(planRetentionRewrites(metas, []*Job{job}, rules, retentionPeriod, now)))
+
+	// The series not matching any rule are never deleted when the blocks retention period is disabled.
+	noRetention := func(int64) time.Duration { return 0 }
+	assert.Equal(t, map[ulid.ULID][]string{
+		devExpired.ULID:    {devRequestID},
+		defaultExpiry.ULID: {devRequestID},
+		downsampled.ULID:   {devRequestID},
+		allExpired.ULID:    {devRequestID, retentionRequestIDPrefix + `{__name__=~"slo_.*"}`},
+	}, getRequestIDs(planRetentionRewrites(metas, nil, rules, noRetention, now)))
+
+	// Nothing is rewritten without rules.
+	assert.Empty(t, planRetentionRewrites(metas, nil, nil, retentionPeriod, now))
+}
+
+func TestBucketRetentionRewriter_Rewrite(t *testing.T) {
+	const day = 24 * time.Hour
+
+	var rules validation.RetentionRules
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- selector: '{env="dev"}'
+  period: 7d
+- selector: '{__name__=~"slo_.*"}'
+  period: 2y
+`), &rules))
+
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	bkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	// The block covers a whole day, 10 days ago.
+	maxt := time.Now().Add(-10 * day).Truncate(day).UnixMilli()
+	orig := uploadTestBlockWithSeries(t, bkt, maxt-day.Milliseconds(), maxt, time.Minute.Milliseconds(),
+		labels.FromStrings("__name__", "up", "env", "dev"),
+		labels.FromStrings("__name__", "up", "env", "prod"),
+		labels.FromStrings("__name__", "slo_errors", "env", "prod"),
+	)
+
+	duplicateBlocksFilter := NewShardAwareDeduplicateFilter()
+	excludeMarkedForDeletionFilter := NewExcludeMarkedForDeletionFilter(objstore.WithNoopInstr(bkt))
+	metaFetcher, err := block.NewMetaFetcher(nil, 32, objstore.WithNoopInstr(bkt), "", nil, []block.MetadataFilter{
+		excludeMarkedForDeletionFilter,
+		duplicateBlocksFilter,
+	})
+	require.NoError(t, err)
+
+	blocksMarkedForDeletion := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+	sy, err := NewMetaSyncer(nil, nil, bkt, metaFetcher, duplicateBlocksFilter, excludeMarkedForDeletionFilter, blocksMarkedForDeletion)
+	require.NoError(t, err)
+
+	reg := prometheus.NewPedanticRegistry()
+	grouper := NewSplitAndMergeGrouper("user-1", []int64{2 * time.Hour.Milliseconds(), day.Milliseconds()}, 0, 0, logger)
+	retentionPeriod := func(int64) time.Duration { return 0 }
+	rewriter := NewBucketRetentionRewriter(logger, sy, grouper, bkt, t.TempDir(), rules, retentionPeriod, ownAllBlocks, NewBucketRetentionRewriterMetrics(blocksMarkedForDeletion, reg))
+
+	require.NoError(t, rewriter.Rewrite(ctx))
+
+	// The original block has been replaced by a rewritten one, without the expired series.
+	require.NoError(t, sy.SyncMetas(ctx))
+	metas := sy.Metas()
+	require.Len(t, metas, 1)
+	require.NotContains(t, metas, orig.ULID)
+
+	var rewritten *metadata.Meta
+	for _, m := range metas {
+		rewritten = m
+	}
+
+	assert.Equal(t, orig.MinTime, rewritten.MinTime)
+	assert.Equal(t, orig.MaxTime, rewritten.MaxTime)
+	assert.Equal(t, orig.Compaction.Sources, rewritten.Compaction.Sources)
+	assert.Equal(t, uint64(2), rewritten.Stats.NumSeries)
+	assert.Equal(t, uint64(2*24*60), rewritten.Stats.NumSamples)
+	require.Len(t, rewritten.Thanos.Rewrites, 1)
+	require.Len(t, rewritten.Thanos.Rewrites[0].DeletionsApplied, 1)
+	assert.Equal(t, retentionRequestIDPrefix+`{env="dev"}`, rewritten.Thanos.Rewrites[0].DeletionsApplied[0].RequestID)
+
+	assert.Equal(t, []labels.Labels{
+		labels.FromStrings("__name__", "slo_errors", "env", "prod"),
+		labels.FromStrings("__name__", "up", "env", "prod"),
+	}, readBlockSeries(t, bkt, rewritten.ULID))
+
+	// Rewriting again is a no-op.
+	require.NoError(t, rewriter.Rewrite(ctx))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_compactor_retention_blocks_rewritten_total Total number of blocks rewritten by the compactor to delete the series which aged past their retention rule period.
+		# TYPE cortex_compactor_retention_blocks_rewritten_total counter
+		cortex_compactor_retention_blocks_rewritten_total 1
+		# HELP cortex_compactor_retention_block_rewrite_failures_total Total number of blocks the compactor failed to rewrite to apply the retention rules.
+		# TYPE cortex_compactor_retention_block_rewrite_failures_total counter
+		cortex_compactor_retention_block_rewrite_failures_total 0
+		# HELP cortex_compactor_retention_series_deleted_total Total number of series deleted from blocks by the compactor because aged past their retention rule period.
+		# TYPE cortex_compactor_retention_series_deleted_total counter
+		cortex_compactor_retention_series_deleted_total 1
+	`), "cortex_compactor_retention_blocks_rewritten_total", "cortex_compactor_retention_block_rewrite_failures_total", "cortex_compactor_retention_series_deleted_total"))
+}
+
+// readBlockSeries downloads the block and returns the labels of its series.
+func readBlockSeries(t *testing.T, bkt objstore.Bucket, id ulid.ULID) []labels.Labels {
+	dir := filepath.Join(t.TempDir(), id.String())
+	require.NoError(t, block.Download(context.Background(), log.NewNopLogger(), bkt, id, dir))
+
+	b, err := tsdb.OpenBlock(log.NewNopLogger(), dir, nil)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, b.Close()) }()
+
+	q, err := tsdb.NewBlockQuerier(b, b.MinTime(), b.MaxTime())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, q.Close()) }()
+
+	var res []labels.Labels
+	set := q.Select(true, nil, labels.MustNewMatcher(labels.MatchRegexp, model.MetricNameLabel, ".+"))
+	for set.Next() {
+		res = append(res, set.At().Labels())
+	}
+	require.NoError(t, set.Err())
+	return res
+}
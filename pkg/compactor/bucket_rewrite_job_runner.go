@@ -0,0 +1,792 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/runutil"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/downsample"
+)
+
+// BucketRewriteJobRunnerMetrics holds the metrics tracked by BucketRewriteJobRunner.
+type BucketRewriteJobRunnerMetrics struct {
+	jobsCompleted           prometheus.Counter
+	jobsFailed              prometheus.Counter
+	blocksRewritten         prometheus.Counter
+	blocksMarkedForDeletion prometheus.Counter
+}
+
+// NewBucketRewriteJobRunnerMetrics makes a new BucketRewriteJobRunnerMetrics.
+func NewBucketRewriteJobRunnerMetrics(blocksMarkedForDeletion prometheus.Counter, reg prometheus.Registerer) *BucketRewriteJobRunnerMetrics {
+	return &BucketRewriteJobRunnerMetrics{
+		jobsCompleted: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_rewrite_jobs_completed_total",
+			Help: "Total number of block rewrite jobs completed by the compactor.",
+		}),
+		jobsFailed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_rewrite_jobs_failed_total",
+			Help: "Total number of block rewrite jobs failed.",
+		}),
+		blocksRewritten: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_rewrite_job_blocks_rewritten_total",
+			Help: "Total number of blocks rewritten by block rewrite jobs.",
+		}),
+		blocksMarkedForDeletion: blocksMarkedForDeletion,
+	}
+}
+
+// BucketRewriteJobRunner runs the pending block rewrite jobs of a tenant. Each job rewrites the blocks
+// overlapping its time range, uploading the rewritten blocks and marking the original ones for deletion.
+// Blocks which are going to be compacted are rewritten once compacted, so a job may take multiple runs
+// to complete.
+type BucketRewriteJobRunner struct {
+	logger     log.Logger
+	sy         *Syncer
+	grouper    Grouper
+	fetcher    block.MetadataFetcher
+	bkt        objstore.Bucket
+	rewriteDir string
+	metrics    *BucketRewriteJobRunnerMetrics
+
+	// Rewrite jobs are identified by ULIDs, and are sharded among compactors like blocks.
+	ownJob ownBlockFunc
+}
+
+// NewBucketRewriteJobRunner creates a new bucket rewrite job runner. The fetcher is used to fetch the
+// blocks to rewrite, and unlike the syncer it shouldn't filter out the blocks marked for no-compaction,
+// so that the blocks with out-of-order chunks can be fixed.
+func NewBucketRewriteJobRunner(
+	logger log.Logger,
+	sy *Syncer,
+	grouper Grouper,
+	fetcher block.MetadataFetcher,
+	bkt objstore.Bucket,
+	rewriteDir string,
+	ownJob ownBlockFunc,
+	metrics *BucketRewriteJobRunnerMetrics,
+) *BucketRewriteJobRunner {
+	return &BucketRewriteJobRunner{
+		logger:     logger,
+		sy:         sy,
+		grouper:    grouper,
+		fetcher:    fetcher,
+		bkt:        bkt,
+		rewriteDir: rewriteDir,
+		ownJob:     ownJob,
+		metrics:    metrics,
+	}
+}
+
+// Run runs the pending rewrite jobs owned by this instance.
+func (r *BucketRewriteJobRunner) Run(ctx context.Context) error {
+	jobs, err := listRewriteJobs(ctx, r.bkt)
+	if err != nil {
+		return err
+	}
+
+	var pending []*RewriteJob
+	for _, job := range jobs {
+		if job.State != RewriteJobPending {
+			continue
+		}
+
+		if ok, err := r.ownJob(job.ID); err != nil {
+			level.Info(r.logger).Log("msg", "skipped rewrite job because unable to check whether the job is owned by the compactor instance", "job", job.ID, "err", err)
+			continue
+		} else if !ok {
+			continue
+		}
+
+		pending = append(pending, job)
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	defer func() {
+		if err := os.RemoveAll(r.rewriteDir); err != nil {
+			level.Error(r.logger).Log("msg", "failed to remove rewrite job work directory", "path", r.rewriteDir, "err", err)
+		}
+	}()
+
+	if err := r.sy.SyncMetas(ctx); err != nil {
+		return errors.Wrap(err, "sync")
+	}
+
+	compactionJobs, err := r.grouper.Groups(excludeDownsampledBlocks(r.sy.Metas()))
+	if err != nil {
+		return errors.Wrap(err, "build compaction jobs")
+	}
+
+	metas, _, err := r.fetcher.Fetch(ctx)
+	if err != nil {
+		return errors.Wrap(err, "fetch blocks")
+	}
+
+	for _, job := range pending {
+		if err := r.runJob(ctx, job, metas, compactionJobs); err != nil {
+			return errors.Wrapf(err, "run rewrite job %s", job.ID)
+		}
+	}
+
+	return nil
+}
+
+// runJob processes the blocks of the job. An error is returned only if the job status can't be updated,
+// while the job is marked as failed if a block can't be rewritten.
+func (r *BucketRewriteJobRunner) runJob(ctx context.Context, job *RewriteJob, metas map[ulid.ULID]*metadata.Meta, compactionJobs []*Job) error {
+	jobLogger := log.With(r.logger, "job", job.ID)
+
+	rewriter, err := newSeriesRewriter(job.Spec)
+	if err != nil {
+		return r.failJob(ctx, jobLogger, job, err)
+	}
+
+	blocks, deferred := planRewriteJobBlocks(job, metas, compactionJobs)
+	for _, meta := range blocks {
+		report, err := r.processBlock(ctx, jobLogger, job, meta, rewriter)
+		if err != nil {
+			return r.failJob(ctx, jobLogger, job, errors.Wrapf(err, "rewrite block %s", meta.ULID))
+		}
+
+		job.Blocks = append(job.Blocks, report)
+		job.ProcessedSources = append(job.ProcessedSources, meta.Compaction.Sources...)
+		if err := writeRewriteJob(ctx, r.bkt, job); err != nil {
+			return err
+		}
+	}
+
+	if deferred > 0 {
+		level.Info(jobLogger).Log("msg", "rewrite job will be resumed once the blocks being compacted are compacted", "deferred_blocks", deferred)
+		return nil
+	}
+
+	job.State = RewriteJobCompleted
+	job.CompletedAt = time.Now()
+	if err := writeRewriteJob(ctx, r.bkt, job); err != nil {
+		return err
+	}
+
+	r.metrics.jobsCompleted.Inc()
+	level.Info(jobLogger).Log("msg", "rewrite job completed", "blocks", len(job.Blocks), "dry_run", job.Spec.DryRun)
+	return nil
+}
+
+func (r *BucketRewriteJobRunner) failJob(ctx context.Context, logger log.Logger, job *RewriteJob, cause error) error {
+	level.Error(logger).Log("msg", "rewrite job failed", "err", cause)
+	r.metrics.jobsFailed.Inc()
+
+	job.State = RewriteJobFailed
+	job.Error = cause.Error()
+	job.CompletedAt = time.Now()
+	return writeRewriteJob(ctx, r.bkt, job)
+}
+
+func (r *BucketRewriteJobRunner) processBlock(ctx context.Context, jobLogger log.Logger, job *RewriteJob, meta *metadata.Meta, rewriter *seriesRewriter) (report RewriteJobBlockReport, rerr error) {
+	blockLogger := log.With(jobLogger, "block", meta.ULID)
+	begin := time.Now()
+
+	bdir := filepath.Join(r.rewriteDir, meta.ULID.String())
+	defer func() {
+		if err := os.RemoveAll(bdir); err != nil {
+			level.Warn(blockLogger).Log("msg", "failed to remove downloaded block", "dir", bdir, "err", err)
+		}
+	}()
+
+	if err := block.Download(ctx, blockLogger, r.bkt, meta.ULID, bdir); err != nil {
+		return report, errors.Wrap(err, "download block")
+	}
+
+	// Downsampled blocks contain aggregated chunks, which are copied as is.
+	b, err := tsdb.OpenBlock(blockLogger, bdir, downsample.NewPool())
+	if err != nil {
+		return report, errors.Wrap(err, "open block")
+	}
+	defer runutil.CloseWithErrCapture(&rerr, b, "rewrite job source block")
+
+	minT, maxT := job.timeRange()
+	report, id, err := rewriteJobBlock(blockLogger, meta, b, r.rewriteDir, rewriter, minT, maxT, job.Spec.DryRun)
+	if err != nil {
+		return report, err
+	}
+
+	if job.Spec.DryRun || !report.changed() {
+		level.Info(blockLogger).Log("msg", "checked block series affected by rewrite job", "matched_series", report.MatchedSeries, "relabeled_series", report.RelabeledSeries, "dropped_series", report.DroppedSeries, "fixed_series", report.FixedSeries, "dry_run", job.Spec.DryRun)
+		return report, nil
+	}
+
+	if id != (ulid.ULID{}) {
+		resdir := filepath.Join(r.rewriteDir, id.String())
+		defer func() {
+			if err := os.RemoveAll(resdir); err != nil {
+				level.Warn(blockLogger).Log("msg", "failed to remove rewritten block", "dir", resdir, "err", err)
+			}
+		}()
+
+		// Ensure the output block is valid.
+		if err := block.VerifyIndex(blockLogger, filepath.Join(resdir, block.IndexFilename), meta.MinTime, meta.MaxTime); err != nil {
+			return report, errors.Wrapf(err, "invalid rewritten block %s", id)
+		}
+
+		if err := mimir_tsdb.UploadBlock(ctx, blockLogger, r.bkt, resdir, nil); err != nil {
+			return report, errors.Wrapf(err, "upload of %s failed", id)
+		}
+		report.RewrittenBlock = id.String()
+	} else {
+		level.Info(blockLogger).Log("msg", "all series in the block have been dropped, skipped uploading the rewritten block")
+	}
+
+	// Spawn a new context so we always mark a block for deletion in full on shutdown.
+	delCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if err := block.MarkForDeletion(delCtx, blockLogger, r.bkt, meta.ULID, fmt.Sprintf("source of block rewritten by rewrite job %s", job.ID), r.metrics.blocksMarkedForDeletion); err != nil {
+		return report, errors.Wrapf(err, "mark block %s for deletion from bucket", meta.ULID)
+	}
+	r.metrics.blocksRewritten.Inc()
+
+	elapsed := time.Since(begin)
+	level.Info(blockLogger).Log("msg", "rewritten block", "result_block", id, "matched_series", report.MatchedSeries, "relabeled_series", report.RelabeledSeries, "dropped_series", report.DroppedSeries, "fixed_series", report.FixedSeries, "duration", elapsed, "duration_ms", elapsed.Milliseconds())
+	return report, nil
+}
+
+// planRewriteJobBlocks returns the blocks to process for the job, sorted by min time, and the number of
+// blocks whose processing is deferred because they're going to be compacted. A block is processed if it
+// overlaps the job time range and some of its compaction sources haven't been processed yet. Blocks
+// which are part of a compaction job are processed once compacted, unless the job is a dry run.
+func planRewriteJobBlocks(job *RewriteJob, metas map[ulid.ULID]*metadata.Meta, compactionJobs []*Job) (res []*metadata.Meta, deferred int) {
+	minT, maxT := job.timeRange()
+
+	processed := make(map[ulid.ULID]struct{}, len(job.ProcessedSources))
+	for _, id := range job.ProcessedSources {
+		processed[id] = struct{}{}
+	}
+
+	compacting := map[ulid.ULID]struct{}{}
+	for _, job := range compactionJobs {
+		for _, id := range job.IDs() {
+			compacting[id] = struct{}{}
+		}
+	}
+
+	for _, m := range metas {
+		if m.MinTime >= maxT || m.MaxTime <= minT {
+			continue
+		}
+
+		allProcessed := len(m.Compaction.Sources) > 0
+		for _, id := range m.Compaction.Sources {
+			if _, ok := processed[id]; !ok {
+				allProcessed = false
+				break
+			}
+		}
+		if allProcessed {
+			continue
+		}
+
+		if _, ok := compacting[m.ULID]; ok && !job.Spec.DryRun {
+			deferred++
+			continue
+		}
+
+		res = append(res, m)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].MinTime != res[j].MinTime {
+			return res[i].MinTime < res[j].MinTime
+		}
+		return res[i].ULID.Compare(res[j].ULID) < 0
+	})
+
+	return res, deferred
+}
+
+// seriesRewriter rewrites series according to a RewriteJobSpec.
+type seriesRewriter struct {
+	spec     RewriteJobSpec
+	matchers [][]*labels.Matcher
+}
+
+func newSeriesRewriter(spec RewriteJobSpec) (*seriesRewriter, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid rewrite job")
+	}
+
+	matchers, err := spec.matchers()
+	if err != nil {
+		return nil, err
+	}
+
+	return &seriesRewriter{spec: spec, matchers: matchers}, nil
+}
+
+func (r *seriesRewriter) matches(lset labels.Labels) bool {
+	for _, ms := range r.matchers {
+		matches := true
+		for _, m := range ms {
+			if !m.Matches(lset.Get(m.Name)) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// rewrite returns the labels of the series once rewritten, nil if the series is dropped, and whether the series
+// matches the selectors of the job.
+func (r *seriesRewriter) rewrite(lset labels.Labels) (labels.Labels, bool) {
+	if !r.matches(lset) {
+		return lset, false
+	}
+	if r.spec.DropSeries {
+		return nil, true
+	}
+	if len(r.spec.RelabelConfigs) > 0 {
+		return relabel.Process(lset.Copy(), r.spec.RelabelConfigs...), true
+	}
+	return lset, true
+}
+
+// rewrittenSeries is a series whose labels or chunks have been changed by the rewrite job.
+type rewrittenSeries struct {
+	lset labels.Labels
+	chks []chunks.Meta
+}
+
+// rewriteJobBlock rewrites the series of the block, and returns the report of the affected series and the
+// ID of the rewritten block written into dir. No block is written in dry-run mode, if no series is affected,
+// or if all series are dropped: in such cases the returned ID is empty.
+//
+// Only the samples within the [minT, maxT) time range are dropped or relabeled. The series whose labels don't
+// change are copied in the index order, while the relabeled ones are kept in memory and merged into the index
+// order. Relabeled series which end up having the same labels of other series are merged with them. If the block
+// isn't fully within the time range, the samples of the dropped or relabeled series outside the time range are
+// kept in memory too, and written with the original labels.
+func rewriteJobBlock(logger log.Logger, origMeta *metadata.Meta, b tsdb.BlockReader, dir string, rewriter *seriesRewriter, minT, maxT int64, dryRun bool) (report RewriteJobBlockReport, id ulid.ULID, err error) {
+	report = RewriteJobBlockReport{Block: origMeta.ULID, MinTime: origMeta.MinTime, MaxTime: origMeta.MaxTime}
+
+	indexr, err := b.Index()
+	if err != nil {
+		return report, id, errors.Wrap(err, "open index reader")
+	}
+	defer runutil.CloseWithErrCapture(&err, indexr, "rewrite job index reader")
+
+	chunkr, err := b.Chunks()
+	if err != nil {
+		return report, id, errors.Wrap(err, "open chunk reader")
+	}
+	defer runutil.CloseWithErrCapture(&err, chunkr, "rewrite job chunk reader")
+
+	var (
+		partial     = origMeta.MinTime < minT || origMeta.MaxTime > maxT
+		downsampled = origMeta.Thanos.Downsample.Resolution > downsample.ResolutionRaw
+	)
+
+	// First pass: find the affected series, and keep the rewritten ones in memory.
+	var rewritten []rewrittenSeries
+	err = forEachBlockSeries(indexr, func(lset labels.Labels, chks []chunks.Meta) error {
+		newLset, matched := rewriter.rewrite(lset)
+		if !matched {
+			return nil
+		}
+		report.MatchedSeries++
+
+		if partial && (newLset == nil || !labels.Equal(lset, newLset)) {
+			inside, outside, err := splitChunksByTime(lset, chunkr, chks, minT, maxT, downsampled)
+			if err != nil {
+				return err
+			}
+			if len(outside) > 0 {
+				rewritten = append(rewritten, rewrittenSeries{lset: lset.Copy(), chks: outside})
+			}
+			chks = inside
+		}
+
+		switch {
+		case len(chks) == 0:
+			// No samples within the time range.
+		case newLset == nil:
+			report.DroppedSeries++
+			report.addExample(fmt.Sprintf("%s dropped", lset))
+			return nil
+		case !labels.Equal(lset, newLset):
+			report.RelabeledSeries++
+			report.addExample(fmt.Sprintf("%s relabeled to %s", lset, newLset))
+			rewritten = append(rewritten, rewrittenSeries{lset: newLset, chks: append([]chunks.Meta(nil), chks...)})
+		}
+
+		if rewriter.spec.FixOutOfOrderChunks && !chunksInOrder(chks) {
+			report.FixedSeries++
+			report.addExample(fmt.Sprintf("%s out-of-order chunks fixed", lset))
+		}
+		return nil
+	})
+	if err != nil {
+		return report, id, err
+	}
+
+	if dryRun || !report.changed() {
+		return report, id, nil
+	}
+
+	id = ulid.MustNew(ulid.Now(), rand.Reader)
+	blockDir := filepath.Join(dir, id.String())
+
+	if err := os.MkdirAll(blockDir, 0o750); err != nil {
+		return report, id, errors.Wrap(err, "create block directory")
+	}
+
+	// Remove the partially written block in case of failure.
+	defer func() {
+		if err != nil {
+			if rerr := os.RemoveAll(blockDir); rerr != nil {
+				err = errors.Wrapf(err, "failed to remove the partially rewritten block: %v", rerr)
+			}
+		}
+	}()
+
+	// Second pass: write the rewritten series.
+	stats, err := writeRewriteJobSeries(indexr, chunkr, blockDir, rewriter, sortAndMergeRewrittenSeries(rewritten), downsampled)
+	if err != nil {
+		return report, id, err
+	}
+
+	if stats.NumSeries == 0 {
+		if err := os.RemoveAll(blockDir); err != nil {
+			return report, id, errors.Wrap(err, "remove empty rewritten block")
+		}
+		return report, ulid.ULID{}, nil
+	}
+
+	err = writeRewrittenBlockMeta(logger, origMeta, id, blockDir, stats, metadata.Rewrite{Sources: origMeta.Compaction.Sources})
+	return report, id, err
+}
+
+// forEachBlockSeries calls fn for each series of the block, in the index order. The labels and chunks passed to
+// fn are only valid until fn returns.
+func forEachBlockSeries(indexr tsdb.IndexReader, fn func(labels.Labels, []chunks.Meta) error) error {
+	postings, err := indexr.Postings(index.AllPostingsKey())
+	if err != nil {
+		return errors.Wrap(err, "get all postings")
+	}
+	postings = indexr.SortedPostings(postings)
+
+	var (
+		lset labels.Labels
+		chks []chunks.Meta
+	)
+
+	for postings.Next() {
+		if err := indexr.Series(postings.At(), &lset, &chks); err != nil {
+			return errors.Wrapf(err, "get series %d", postings.At())
+		}
+		if err := fn(lset, chks); err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(postings.Err(), "iterate series")
+}
+
+// sortAndMergeRewrittenSeries sorts the series by labels, merging the ones having the same labels.
+func sortAndMergeRewrittenSeries(series []rewrittenSeries) []rewrittenSeries {
+	sort.SliceStable(series, func(i, j int) bool {
+		return labels.Compare(series[i].lset, series[j].lset) < 0
+	})
+
+	res := series[:0]
+	for _, s := range series {
+		if len(res) > 0 && labels.Equal(res[len(res)-1].lset, s.lset) {
+			res[len(res)-1].chks = append(res[len(res)-1].chks, s.chks...)
+			continue
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// writeRewriteJobSeries writes the series of the block into blockDir, skipping the series dropped or relabeled
+// by the rewriter, and adding the rewritten series kept in memory, which must be sorted by labels.
+func writeRewriteJobSeries(indexr tsdb.IndexReader, chunkr tsdb.ChunkReader, blockDir string, rewriter *seriesRewriter, rewritten []rewrittenSeries, downsampled bool) (stats tsdb.BlockStats, err error) {
+	chunkw, err := chunks.NewWriter(filepath.Join(blockDir, block.ChunksDirname))
+	if err != nil {
+		return stats, errors.Wrap(err, "open chunk writer")
+	}
+	defer runutil.CloseWithErrCapture(&err, chunkw, "rewrite job chunk writer")
+
+	indexw, err := index.NewWriter(context.Background(), filepath.Join(blockDir, block.IndexFilename))
+	if err != nil {
+		return stats, errors.Wrap(err, "open index writer")
+	}
+	defer runutil.CloseWithErrCapture(&err, indexw, "rewrite job index writer")
+
+	if err := addRewriteJobSymbols(indexr, indexw, rewritten); err != nil {
+		return stats, err
+	}
+
+	var ref storage.SeriesRef
+	writeSeries := func(lset labels.Labels, chks []chunks.Meta, fix bool) error {
+		for i := range chks {
+			if chks[i].Chunk != nil {
+				// Chunk split at the job time range boundaries.
+				continue
+			}
+			chk, err := chunkr.Chunk(chks[i])
+			if err != nil {
+				return errors.Wrapf(err, "get chunk %d of series %s", chks[i].Ref, lset)
+			}
+			chks[i].Chunk = chk
+		}
+
+		if fix {
+			var err error
+			if chks, err = fixChunks(lset, chks, downsampled); err != nil {
+				return err
+			}
+		}
+
+		if err := chunkw.WriteChunks(chks...); err != nil {
+			return errors.Wrapf(err, "write chunks of series %s", lset)
+		}
+		if err := indexw.AddSeries(ref, lset, chks...); err != nil {
+			return errors.Wrapf(err, "add series %s", lset)
+		}
+		ref++
+
+		stats.NumSeries++
+		stats.NumChunks += uint64(len(chks))
+		for _, c := range chks {
+			stats.NumSamples += uint64(c.Chunk.NumSamples())
+		}
+		return nil
+	}
+
+	err = forEachBlockSeries(indexr, func(lset labels.Labels, chks []chunks.Meta) error {
+		newLset, matched := rewriter.rewrite(lset)
+		if newLset == nil || !labels.Equal(lset, newLset) {
+			// Dropped or relabeled series.
+			return nil
+		}
+
+		// Write the rewritten series sorting before this one.
+		for len(rewritten) > 0 && labels.Compare(rewritten[0].lset, lset) < 0 {
+			if err := writeSeries(rewritten[0].lset, rewritten[0].chks, true); err != nil {
+				return err
+			}
+			rewritten = rewritten[1:]
+		}
+
+		fix := matched && rewriter.spec.FixOutOfOrderChunks
+		if len(rewritten) > 0 && labels.Equal(rewritten[0].lset, lset) {
+			chks = append(chks, rewritten[0].chks...)
+			rewritten = rewritten[1:]
+			fix = true
+		}
+
+		return writeSeries(lset, chks, fix)
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	for _, s := range rewritten {
+		if err := writeSeries(s.lset, s.chks, true); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// addRewriteJobSymbols adds the symbols of the original block, along with the symbols of the rewritten series,
+// to the index writer. Symbols only used by dropped or relabeled series are kept, which is harmless.
+func addRewriteJobSymbols(indexr tsdb.IndexReader, indexw tsdb.IndexWriter, rewritten []rewrittenSeries) error {
+	newSymbols := map[string]struct{}{}
+	for _, s := range rewritten {
+		for _, l := range s.lset {
+			newSymbols[l.Name] = struct{}{}
+			newSymbols[l.Value] = struct{}{}
+		}
+	}
+
+	sorted := make([]string, 0, len(newSymbols))
+	for s := range newSymbols {
+		sorted = append(sorted, s)
+	}
+	sort.Strings(sorted)
+
+	symbols := indexr.Symbols()
+	for symbols.Next() {
+		sym := symbols.At()
+		for len(sorted) > 0 && sorted[0] < sym {
+			if err := indexw.AddSymbol(sorted[0]); err != nil {
+				return errors.Wrap(err, "add symbol")
+			}
+			sorted = sorted[1:]
+		}
+		if len(sorted) > 0 && sorted[0] == sym {
+			sorted = sorted[1:]
+		}
+		if err := indexw.AddSymbol(sym); err != nil {
+			return errors.Wrap(err, "add symbol")
+		}
+	}
+	if err := symbols.Err(); err != nil {
+		return errors.Wrap(err, "iterate symbols")
+	}
+
+	for _, sym := range sorted {
+		if err := indexw.AddSymbol(sym); err != nil {
+			return errors.Wrap(err, "add symbol")
+		}
+	}
+	return nil
+}
+
+// splitChunksByTime splits the chunks of the series into the ones within the [minT, maxT) time range and the ones
+// outside it. The chunks crossing the time range boundaries are re-encoded into a chunk for each side, while the
+// other chunks are returned as is. Chunks of downsampled blocks contain aggregated samples, and can't be split.
+func splitChunksByTime(lset labels.Labels, chunkr tsdb.ChunkReader, chks []chunks.Meta, minT, maxT int64, downsampled bool) (inside, outside []chunks.Meta, err error) {
+	for _, c := range chks {
+		switch {
+		case c.MinTime >= minT && c.MaxTime < maxT:
+			inside = append(inside, c)
+		case c.MaxTime < minT || c.MinTime >= maxT:
+			outside = append(outside, c)
+		case downsampled:
+			return nil, nil, errors.Errorf("chunk %d of series %s crosses the time range boundaries, which can't be done in downsampled blocks", c.Ref, lset)
+		default:
+			chk, err := chunkr.Chunk(c)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "get chunk %d of series %s", c.Ref, lset)
+			}
+
+			parts, err := splitChunk(chk, minT, maxT)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "split chunk %d of series %s", c.Ref, lset)
+			}
+			for i, part := range parts {
+				switch {
+				case part.Chunk == nil:
+					// No samples on this side.
+				case i == 1:
+					inside = append(inside, part)
+				default:
+					outside = append(outside, part)
+				}
+			}
+		}
+	}
+	return inside, outside, nil
+}
+
+// splitChunk re-encodes the samples of the chunk into three chunks: the samples before minT, the samples within
+// the [minT, maxT) time range, and the samples from maxT on. The returned chunks without samples are empty.
+func splitChunk(chk chunkenc.Chunk, minT, maxT int64) (parts [3]chunks.Meta, err error) {
+	var apps [3]chunkenc.Appender
+
+	it := chk.Iterator(nil)
+	for it.Next() {
+		ts, v := it.At()
+
+		i := 1
+		if ts < minT {
+			i = 0
+		} else if ts >= maxT {
+			i = 2
+		}
+
+		if apps[i] == nil {
+			c := chunkenc.NewXORChunk()
+			if apps[i], err = c.Appender(); err != nil {
+				return parts, err
+			}
+			parts[i] = chunks.Meta{Chunk: c, MinTime: ts}
+		}
+		apps[i].Append(ts, v)
+		parts[i].MaxTime = ts
+	}
+	return parts, it.Err()
+}
+
+// chunksInOrder returns whether the chunks are sorted by time and don't overlap.
+func chunksInOrder(chks []chunks.Meta) bool {
+	for i := 1; i < len(chks); i++ {
+		if chks[i].MinTime <= chks[i-1].MaxTime {
+			return false
+		}
+	}
+	return true
+}
+
+// fixChunks sorts the chunks by time, merging the overlapping ones. The samples of overlapping chunks
+// are deduplicated by timestamp. Overlapping chunks of downsampled blocks can't be merged.
+func fixChunks(lset labels.Labels, chks []chunks.Meta, downsampled bool) ([]chunks.Meta, error) {
+	sort.SliceStable(chks, func(i, j int) bool {
+		if chks[i].MinTime != chks[j].MinTime {
+			return chks[i].MinTime < chks[j].MinTime
+		}
+		return chks[i].MaxTime < chks[j].MaxTime
+	})
+
+	if chunksInOrder(chks) {
+		return chks, nil
+	}
+	if downsampled {
+		return nil, errors.Errorf("series %s has overlapping chunks, which can't be merged in downsampled blocks", lset)
+	}
+
+	series := make([]storage.ChunkSeries, 0, len(chks))
+	for _, chk := range chks {
+		series = append(series, newSingleChunkSeries(lset, chk))
+	}
+
+	var res []chunks.Meta
+	it := storage.NewCompactingChunkSeriesMerger(storage.ChainedSeriesMerge)(series...).Iterator()
+	for it.Next() {
+		res = append(res, it.At())
+	}
+	if err := it.Err(); err != nil {
+		return nil, errors.Wrapf(err, "merge overlapping chunks of series %s", lset)
+	}
+	return res, nil
+}
+
+func newSingleChunkSeries(lset labels.Labels, chk chunks.Meta) storage.ChunkSeries {
+	return &storage.ChunkSeriesEntry{
+		Lset: lset,
+		ChunkIteratorFn: func() chunks.Iterator {
+			return storage.NewListChunkSeriesIterator(chk)
+		},
+	}
+}
@@ -0,0 +1,530 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"gopkg.in/yaml.v3"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/downsample"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+func TestPlanRewriteJobBlocks(t *testing.T) {
+	const day = int64(24 * time.Hour / time.Millisecond)
+
+	newMeta := func(id uint64, mint, maxt int64, sources ...ulid.ULID) *metadata.Meta {
+		m := &metadata.Meta{}
+		m.ULID = ulid.MustNew(id, nil)
+		m.MinTime = mint
+		m.MaxTime = maxt
+		m.Compaction.Sources = sources
+		if len(sources) == 0 {
+			m.Compaction.Sources = []ulid.ULID{m.ULID}
+		}
+		return m
+	}
+
+	var (
+		block1 = newMeta(1, 0, day)
+		block2 = newMeta(2, day, 2*day)
+		block3 = newMeta(3, 2*day, 3*day)
+		block4 = newMeta(4, 3*day, 4*day)
+
+		// block5 is the result of the compaction of two blocks, one of which has already been processed.
+		processed   = ulid.MustNew(100, nil)
+		unprocessed = ulid.MustNew(101, nil)
+		block5      = newMeta(5, 4*day, 5*day, processed, unprocessed)
+
+		// block6 is the result of the compaction of two processed blocks.
+		block6 = newMeta(6, 5*day, 6*day, processed, ulid.MustNew(102, nil))
+	)
+
+	metas := map[ulid.ULID]*metadata.Meta{}
+	for _, m := range []*metadata.Meta{block1, block2, block3, block4, block5, block6} {
+		metas[m.ULID] = m
+	}
+
+	newJob := func(start, end int64, dryRun bool) *RewriteJob {
+		job := NewRewriteJob(ulid.MustNew(1000, nil), RewriteJobSpec{
+			Selectors:  []string{`{__name__="up"}`},
+			DropSeries: true,
+			DryRun:     dryRun,
+		}, time.UnixMilli(10*day))
+		if start > 0 {
+			job.Spec.Start = time.UnixMilli(start)
+		}
+		if end > 0 {
+			job.Spec.End = time.UnixMilli(end)
+		}
+		job.ProcessedSources = []ulid.ULID{processed, ulid.MustNew(102, nil)}
+		return job
+	}
+
+	getIDs := func(metas []*metadata.Meta) []ulid.ULID {
+		var res []ulid.ULID
+		for _, m := range metas {
+			res = append(res, m.ULID)
+		}
+		return res
+	}
+
+	// block3 is going to be compacted.
+	compactionJob := NewJob("user-1", "key", labels.EmptyLabels(), downsample.ResolutionRaw, metadata.NoneFunc, false, 0, "")
+	require.NoError(t, compactionJob.AppendMeta(block3))
+
+	for name, tc := range map[string]struct {
+		job              *RewriteJob
+		expectedBlocks   []ulid.ULID
+		expectedDeferred int
+	}{
+		"whole time range": {
+			job:              newJob(0, 0, false),
+			expectedBlocks:   []ulid.ULID{block1.ULID, block2.ULID, block4.ULID, block5.ULID},
+			expectedDeferred: 1,
+		},
+		"blocks overlapping the time range": {
+			job:              newJob(day+1, 3*day+1, false),
+			expectedBlocks:   []ulid.ULID{block2.ULID, block4.ULID},
+			expectedDeferred: 1,
+		},
+		"blocks starting at the end of the time range are excluded": {
+			job:              newJob(day+1, 3*day, false),
+			expectedBlocks:   []ulid.ULID{block2.ULID},
+			expectedDeferred: 1,
+		},
+		"blocks being compacted are not deferred in dry-run mode": {
+			job:            newJob(0, 0, true),
+			expectedBlocks: []ulid.ULID{block1.ULID, block2.ULID, block3.ULID, block4.ULID, block5.ULID},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			blocks, deferred := planRewriteJobBlocks(tc.job, metas, []*Job{compactionJob})
+			assert.Equal(t, tc.expectedBlocks, getIDs(blocks))
+			assert.Equal(t, tc.expectedDeferred, deferred)
+		})
+	}
+}
+
+func TestBucketRewriteJobRunner_Run(t *testing.T) {
+	const day = 24 * time.Hour
+
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+	bkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	// The block covers a whole day, 10 days ago.
+	maxt := time.Now().Add(-10 * day).Truncate(day).UnixMilli()
+	mint := maxt - day.Milliseconds()
+	interval := time.Minute.Milliseconds()
+	orig := uploadTestBlockWithSeries(t, bkt, mint, maxt, interval,
+		labels.FromStrings("__name__", "up", "cluster", "bad", "job", "a"),
+		labels.FromStrings("__name__", "up", "cluster", "bad", "job", "b"),
+		labels.FromStrings("__name__", "up", "cluster", "bad", "job", "c"),
+		labels.FromStrings("__name__", "up", "cluster", "good", "job", "a"),
+		labels.FromStrings("__name__", "up", "cluster", "other", "job", "a"),
+	)
+
+	// The block with out-of-order chunks covers the previous day.
+	ooo := uploadTestBlockWithChunks(t, bkt, &mimir_testutil.BlockSeriesSpec{
+		Labels: labels.FromStrings("__name__", "up", "cluster", "good", "job", "d"),
+		Chunks: []chunks.Meta{
+			newTestChunk(t, mint-day.Milliseconds()/2, mint, interval),
+			newTestChunk(t, mint-day.Milliseconds(), mint-day.Milliseconds()/2+10*interval, interval),
+		},
+	})
+
+	duplicateBlocksFilter := NewShardAwareDeduplicateFilter()
+	excludeMarkedForDeletionFilter := NewExcludeMarkedForDeletionFilter(objstore.WithNoopInstr(bkt))
+	metaFetcher, err := block.NewMetaFetcher(nil, 32, objstore.WithNoopInstr(bkt), "", nil, []block.MetadataFilter{
+		excludeMarkedForDeletionFilter,
+		duplicateBlocksFilter,
+	})
+	require.NoError(t, err)
+
+	blocksMarkedForDeletion := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+	sy, err := NewMetaSyncer(nil, nil, bkt, metaFetcher, duplicateBlocksFilter, excludeMarkedForDeletionFilter, blocksMarkedForDeletion)
+	require.NoError(t, err)
+
+	reg := prometheus.NewPedanticRegistry()
+	grouper := NewSplitAndMergeGrouper("user-1", []int64{2 * time.Hour.Milliseconds(), day.Milliseconds()}, 0, 0, logger)
+	runner := NewBucketRewriteJobRunner(logger, sy, grouper, metaFetcher, bkt, t.TempDir(), ownAllBlocks, NewBucketRewriteJobRunnerMetrics(blocksMarkedForDeletion, reg))
+
+	relabelSpec := func(dryRun bool) RewriteJobSpec {
+		spec := RewriteJobSpec{}
+		require.NoError(t, yaml.Unmarshal([]byte(`
+selectors: ['{cluster="bad"}']
+relabel_configs:
+  - source_labels: [job]
+    regex: c
+    action: drop
+  - target_label: cluster
+    replacement: good
+`), &spec))
+		spec.DryRun = dryRun
+		return spec
+	}
+
+	submit := func(id uint64, spec RewriteJobSpec) ulid.ULID {
+		job := NewRewriteJob(ulid.MustNew(ulid.Now()+id, nil), spec, time.Now())
+		require.NoError(t, writeRewriteJob(ctx, bkt, job))
+		return job.ID
+	}
+
+	expectedReport := RewriteJobBlockReport{
+		Block:           orig.ULID,
+		MinTime:         orig.MinTime,
+		MaxTime:         orig.MaxTime,
+		MatchedSeries:   3,
+		RelabeledSeries: 2,
+		DroppedSeries:   1,
+		Examples: []string{
+			`{__name__="up", cluster="bad", job="a"} relabeled to {__name__="up", cluster="good", job="a"}`,
+			`{__name__="up", cluster="bad", job="b"} relabeled to {__name__="up", cluster="good", job="b"}`,
+			`{__name__="up", cluster="bad", job="c"} dropped`,
+		},
+	}
+
+	t.Run("dry run reports the affected series without rewriting blocks", func(t *testing.T) {
+		id := submit(1, relabelSpec(true))
+		require.NoError(t, runner.Run(ctx))
+
+		job, err := readRewriteJob(ctx, bkt, id)
+		require.NoError(t, err)
+		assert.Equal(t, RewriteJobCompleted, job.State)
+		assert.Empty(t, job.Error)
+		require.Len(t, job.Blocks, 2)
+		assert.Equal(t, expectedReport, job.Blocks[1])
+
+		// The block with out-of-order chunks doesn't contain any selected series.
+		assert.Equal(t, RewriteJobBlockReport{Block: ooo.ULID, MinTime: ooo.MinTime, MaxTime: ooo.MaxTime}, job.Blocks[0])
+
+		require.NoError(t, sy.SyncMetas(ctx))
+		assert.Len(t, sy.Metas(), 2)
+		assert.Contains(t, sy.Metas(), orig.ULID)
+	})
+
+	t.Run("relabel and drop series", func(t *testing.T) {
+		id := submit(2, relabelSpec(false))
+		require.NoError(t, runner.Run(ctx))
+
+		job, err := readRewriteJob(ctx, bkt, id)
+		require.NoError(t, err)
+		assert.Equal(t, RewriteJobCompleted, job.State)
+		require.Len(t, job.Blocks, 2)
+		assert.Empty(t, job.Blocks[0].RewrittenBlock)
+
+		report := job.Blocks[1]
+		require.NotEmpty(t, report.RewrittenBlock)
+		rewrittenID := ulid.MustParse(report.RewrittenBlock)
+		report.RewrittenBlock = ""
+		assert.Equal(t, expectedReport, report)
+
+		require.NoError(t, sy.SyncMetas(ctx))
+		metas := sy.Metas()
+		require.Len(t, metas, 2)
+		require.NotContains(t, metas, orig.ULID)
+		require.Contains(t, metas, rewrittenID)
+
+		rewritten := metas[rewrittenID]
+		assert.Equal(t, orig.MinTime, rewritten.MinTime)
+		assert.Equal(t, orig.MaxTime, rewritten.MaxTime)
+		assert.Equal(t, orig.Compaction.Sources, rewritten.Compaction.Sources)
+		assert.Equal(t, metadata.BucketRewriteSource, rewritten.Thanos.Source)
+		assert.Equal(t, uint64(3), rewritten.Stats.NumSeries)
+
+		// The relabeled series has been merged with the existing one, deduplicating the samples.
+		samplesPerSeries := int(day.Milliseconds() / interval)
+		assert.Equal(t, map[string]int{
+			`{__name__="up", cluster="good", job="a"}`:  samplesPerSeries,
+			`{__name__="up", cluster="good", job="b"}`:  samplesPerSeries,
+			`{__name__="up", cluster="other", job="a"}`: samplesPerSeries,
+		}, readBlockSeriesSamples(t, bkt, rewrittenID))
+	})
+
+	t.Run("fix out-of-order chunks", func(t *testing.T) {
+		id := submit(3, RewriteJobSpec{Selectors: []string{`{job="d"}`}, FixOutOfOrderChunks: true})
+		require.NoError(t, runner.Run(ctx))
+
+		job, err := readRewriteJob(ctx, bkt, id)
+		require.NoError(t, err)
+		assert.Equal(t, RewriteJobCompleted, job.State)
+		require.Len(t, job.Blocks, 2)
+		assert.Equal(t, 1, job.Blocks[0].MatchedSeries)
+		assert.Equal(t, 1, job.Blocks[0].FixedSeries)
+		require.NotEmpty(t, job.Blocks[0].RewrittenBlock)
+		assert.Equal(t, 0, job.Blocks[1].MatchedSeries)
+		assert.Empty(t, job.Blocks[1].RewrittenBlock)
+
+		assert.Equal(t, map[string]int{
+			`{__name__="up", cluster="good", job="d"}`: int(day.Milliseconds() / interval),
+		}, readBlockSeriesSamples(t, bkt, ulid.MustParse(job.Blocks[0].RewrittenBlock)))
+	})
+
+	t.Run("completed jobs are not run again", func(t *testing.T) {
+		require.NoError(t, runner.Run(ctx))
+
+		jobs, err := listRewriteJobs(ctx, bkt)
+		require.NoError(t, err)
+		require.Len(t, jobs, 3)
+		for _, job := range jobs {
+			assert.Equal(t, RewriteJobCompleted, job.State)
+			assert.Len(t, job.Blocks, 2)
+		}
+	})
+
+	t.Run("jobs failing to rewrite blocks are marked as failed", func(t *testing.T) {
+		// Out-of-order chunks can't be written without fixing them.
+		id := submit(4, RewriteJobSpec{Selectors: []string{`{job="e"}`}, DropSeries: true})
+		uploadTestBlockWithChunks(t, bkt,
+			&mimir_testutil.BlockSeriesSpec{
+				Labels: labels.FromStrings("__name__", "up", "job", "e"),
+				Chunks: []chunks.Meta{newTestChunk(t, maxt, maxt+day.Milliseconds(), interval)},
+			},
+			&mimir_testutil.BlockSeriesSpec{
+				Labels: labels.FromStrings("__name__", "up", "job", "f"),
+				Chunks: []chunks.Meta{
+					newTestChunk(t, maxt+day.Milliseconds()/2, maxt+day.Milliseconds(), interval),
+					newTestChunk(t, maxt, maxt+day.Milliseconds()/2+10*interval, interval),
+				},
+			},
+		)
+		require.NoError(t, runner.Run(ctx))
+
+		job, err := readRewriteJob(ctx, bkt, id)
+		require.NoError(t, err)
+		assert.Equal(t, RewriteJobFailed, job.State)
+		assert.Contains(t, job.Error, "rewrite block")
+	})
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_compactor_rewrite_jobs_completed_total Total number of block rewrite jobs completed by the compactor.
+		# TYPE cortex_compactor_rewrite_jobs_completed_total counter
+		cortex_compactor_rewrite_jobs_completed_total 3
+		# HELP cortex_compactor_rewrite_jobs_failed_total Total number of block rewrite jobs failed.
+		# TYPE cortex_compactor_rewrite_jobs_failed_total counter
+		cortex_compactor_rewrite_jobs_failed_total 1
+		# HELP cortex_compactor_rewrite_job_blocks_rewritten_total Total number of blocks rewritten by block rewrite jobs.
+		# TYPE cortex_compactor_rewrite_job_blocks_rewritten_total counter
+		cortex_compactor_rewrite_job_blocks_rewritten_total 2
+	`), "cortex_compactor_rewrite_jobs_completed_total", "cortex_compactor_rewrite_jobs_failed_total", "cortex_compactor_rewrite_job_blocks_rewritten_total"))
+}
+
+func TestRewriteJobBlock_PartialTimeRange(t *testing.T) {
+	logger := log.NewNopLogger()
+	interval := time.Minute.Milliseconds()
+
+	// The block covers [0, 300) intervals, while the job time range is [50, 150) intervals, so the
+	// first two chunks of the selected series cross the time range boundaries and the third is outside it.
+	dir := t.TempDir()
+	meta, err := mimir_testutil.GenerateBlockFromSpec("user-1", dir, []*mimir_testutil.BlockSeriesSpec{
+		{
+			Labels: labels.FromStrings("__name__", "up", "job", "a"),
+			Chunks: []chunks.Meta{
+				newTestChunk(t, 0, 100*interval, interval),
+				newTestChunk(t, 100*interval, 200*interval, interval),
+				newTestChunk(t, 200*interval, 300*interval, interval),
+			},
+		},
+		{
+			Labels: labels.FromStrings("__name__", "up", "job", "b"),
+			Chunks: []chunks.Meta{newTestChunk(t, 0, 300*interval, interval)},
+		},
+	})
+	require.NoError(t, err)
+
+	b, err := tsdb.OpenBlock(logger, filepath.Join(dir, meta.ULID.String()), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, b.Close()) })
+
+	newRewriter := func(t *testing.T, spec string) *seriesRewriter {
+		s := RewriteJobSpec{}
+		require.NoError(t, yaml.Unmarshal([]byte(spec), &s))
+		rewriter, err := newSeriesRewriter(s)
+		require.NoError(t, err)
+		return rewriter
+	}
+
+	t.Run("relabeled series keep the samples outside the time range", func(t *testing.T) {
+		rewriter := newRewriter(t, `
+selectors: ['{job="a"}']
+relabel_configs:
+  - target_label: job
+    replacement: c
+`)
+		outDir := t.TempDir()
+		report, id, err := rewriteJobBlock(logger, meta, b, outDir, rewriter, 50*interval, 150*interval, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.RelabeledSeries)
+		require.NotEqual(t, ulid.ULID{}, id)
+
+		timestamps := readBlockDirSeriesTimestamps(t, filepath.Join(outDir, id.String()))
+		require.Len(t, timestamps, 3)
+
+		a := timestamps[`{__name__="up", job="a"}`]
+		require.Len(t, a, 200)
+		assert.Equal(t, int64(0), a[0])
+		assert.Equal(t, 49*interval, a[49])
+		assert.Equal(t, 150*interval, a[50])
+		assert.Equal(t, 299*interval, a[199])
+
+		c := timestamps[`{__name__="up", job="c"}`]
+		require.Len(t, c, 100)
+		assert.Equal(t, 50*interval, c[0])
+		assert.Equal(t, 149*interval, c[99])
+
+		assert.Len(t, timestamps[`{__name__="up", job="b"}`], 300)
+	})
+
+	t.Run("dropped series keep the samples outside the time range", func(t *testing.T) {
+		rewriter := newRewriter(t, `
+selectors: ['{job="a"}']
+drop_series: true
+`)
+		outDir := t.TempDir()
+		report, id, err := rewriteJobBlock(logger, meta, b, outDir, rewriter, 50*interval, 150*interval, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.DroppedSeries)
+		require.NotEqual(t, ulid.ULID{}, id)
+
+		timestamps := readBlockDirSeriesTimestamps(t, filepath.Join(outDir, id.String()))
+		require.Len(t, timestamps, 2)
+		assert.Len(t, timestamps[`{__name__="up", job="a"}`], 200)
+		assert.Len(t, timestamps[`{__name__="up", job="b"}`], 300)
+	})
+
+	t.Run("series without samples in the time range are not affected", func(t *testing.T) {
+		rewriter := newRewriter(t, `
+selectors: ['{job="a"}']
+drop_series: true
+`)
+		report, id, err := rewriteJobBlock(logger, meta, b, t.TempDir(), rewriter, 300*interval, 400*interval, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.MatchedSeries)
+		assert.False(t, report.changed())
+		assert.Equal(t, ulid.ULID{}, id)
+	})
+
+	t.Run("chunks of downsampled blocks crossing the time range boundaries can't be split", func(t *testing.T) {
+		rewriter := newRewriter(t, `
+selectors: ['{job="a"}']
+drop_series: true
+`)
+		downsampledMeta := *meta
+		downsampledMeta.Thanos.Downsample.Resolution = downsample.Resolution5m
+		_, _, err := rewriteJobBlock(logger, &downsampledMeta, b, t.TempDir(), rewriter, 50*interval, 150*interval, false)
+		require.ErrorContains(t, err, "crosses the time range boundaries")
+	})
+}
+
+func TestFixChunks(t *testing.T) {
+	lset := labels.FromStrings("__name__", "up")
+	interval := time.Minute.Milliseconds()
+
+	t.Run("chunks are sorted by time", func(t *testing.T) {
+		chks := []chunks.Meta{newTestChunk(t, 100*interval, 200*interval, interval), newTestChunk(t, 0, 100*interval, interval)}
+		fixed, err := fixChunks(lset, chks, false)
+		require.NoError(t, err)
+		require.Len(t, fixed, 2)
+		assert.Equal(t, int64(0), fixed[0].MinTime)
+		assert.Equal(t, 100*interval, fixed[1].MinTime)
+	})
+
+	t.Run("overlapping chunks are merged", func(t *testing.T) {
+		chks := []chunks.Meta{newTestChunk(t, 50*interval, 200*interval, interval), newTestChunk(t, 0, 100*interval, interval)}
+		fixed, err := fixChunks(lset, chks, false)
+		require.NoError(t, err)
+		assert.True(t, chunksInOrder(fixed))
+
+		samples := 0
+		for _, c := range fixed {
+			samples += c.Chunk.NumSamples()
+		}
+		assert.Equal(t, 200, samples)
+	})
+
+	t.Run("overlapping chunks of downsampled blocks can't be merged", func(t *testing.T) {
+		chks := []chunks.Meta{newTestChunk(t, 50*interval, 200*interval, interval), newTestChunk(t, 0, 100*interval, interval)}
+		_, err := fixChunks(lset, chks, true)
+		require.Error(t, err)
+	})
+}
+
+// newTestChunk returns a chunk with a sample every interval in the [mint, maxt) time range.
+func newTestChunk(t *testing.T, mint, maxt, interval int64) chunks.Meta {
+	chk := chunkenc.NewXORChunk()
+	app, err := chk.Appender()
+	require.NoError(t, err)
+
+	for ts := mint; ts < maxt; ts += interval {
+		app.Append(ts, float64(ts))
+	}
+	return chunks.Meta{Chunk: chk, MinTime: mint, MaxTime: maxt - interval}
+}
+
+// uploadTestBlockWithChunks uploads a block containing the given series, whose chunks may be out of order.
+func uploadTestBlockWithChunks(t *testing.T, bkt objstore.Bucket, series ...*mimir_testutil.BlockSeriesSpec) *metadata.Meta {
+	dir := t.TempDir()
+	meta, err := mimir_testutil.GenerateBlockFromSpec("user-1", dir, series)
+	require.NoError(t, err)
+	require.NoError(t, mimir_tsdb.UploadBlock(context.Background(), log.NewNopLogger(), bkt, filepath.Join(dir, meta.ULID.String()), nil))
+
+	return meta
+}
+
+// readBlockSeriesSamples downloads the block and returns the number of samples of each series.
+func readBlockSeriesSamples(t *testing.T, bkt objstore.Bucket, id ulid.ULID) map[string]int {
+	dir := filepath.Join(t.TempDir(), id.String())
+	require.NoError(t, block.Download(context.Background(), log.NewNopLogger(), bkt, id, dir))
+
+	res := map[string]int{}
+	for series, timestamps := range readBlockDirSeriesTimestamps(t, dir) {
+		res[series] = len(timestamps)
+	}
+	return res
+}
+
+// readBlockDirSeriesTimestamps returns the sample timestamps of each series of the block in dir.
+func readBlockDirSeriesTimestamps(t *testing.T, dir string) map[string][]int64 {
+	b, err := tsdb.OpenBlock(log.NewNopLogger(), dir, nil)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, b.Close()) }()
+
+	q, err := tsdb.NewBlockQuerier(b, b.MinTime(), b.MaxTime())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, q.Close()) }()
+
+	res := map[string][]int64{}
+	set := q.Select(true, nil, labels.MustNewMatcher(labels.MatchRegexp, model.MetricNameLabel, ".+"))
+	for set.Next() {
+		it := set.At().Iterator()
+		for it.Next() {
+			ts, _ := it.At()
+			res[set.At().Labels().String()] = append(res[set.At().Labels().String()], ts)
+		}
+		require.NoError(t, it.Err())
+	}
+	require.NoError(t, set.Err())
+	return res
+}
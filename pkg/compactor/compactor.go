@@ -23,6 +23,7 @@ import (
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/ring"
 	"github.com/grafana/dskit/services"
+	"github.com/oklog/ulid"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -34,6 +35,7 @@ import (
 	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
 	"github.com/grafana/mimir/pkg/util"
 	util_log "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 const (
@@ -47,12 +49,16 @@ const (
 )
 
 var (
-	errInvalidBlockRanges                 = "compactor block range periods should be divisible by the previous one, but %s is not divisible by %s"
-	errInvalidCompactionOrder             = fmt.Errorf("unsupported compaction order (supported values: %s)", strings.Join(CompactionOrders, ", "))
-	errInvalidMaxOpeningBlocksConcurrency = fmt.Errorf("invalid max-opening-blocks-concurrency value, must be positive")
-	errInvalidMaxClosingBlocksConcurrency = fmt.Errorf("invalid max-closing-blocks-concurrency value, must be positive")
-	errInvalidSymbolFlushersConcurrency   = fmt.Errorf("invalid symbols-flushers-concurrency value, must be positive")
-	RingOp                                = ring.NewOp([]ring.InstanceState{ring.ACTIVE}, nil)
+	errInvalidBlockRanges                      = "compactor block range periods should be divisible by the previous one, but %s is not divisible by %s"
+	errInvalidCompactionOrder                  = fmt.Errorf("unsupported compaction order (supported values: %s)", strings.Join(CompactionOrders, ", "))
+	errInvalidMaxOpeningBlocksConcurrency      = fmt.Errorf("invalid max-opening-blocks-concurrency value, must be positive")
+	errInvalidMaxClosingBlocksConcurrency      = fmt.Errorf("invalid max-closing-blocks-concurrency value, must be positive")
+	errInvalidSymbolFlushersConcurrency        = fmt.Errorf("invalid symbols-flushers-concurrency value, must be positive")
+	errInvalidMaxBlocksMergedPerPass           = fmt.Errorf("invalid max-blocks-merged-per-pass value, must be 0 or greater than 1")
+	errInvalidTopLabelNamesMaxBlocks           = fmt.Errorf("invalid bucket-index-top-label-names-max-blocks-per-update value, must not be negative")
+	errInvalidTopLabelNamesConcurrency         = fmt.Errorf("invalid bucket-index-top-label-names-concurrency value, must be positive")
+	errInvalidBlockUploadValidationConcurrency = fmt.Errorf("invalid max-block-upload-validation-concurrency value, must be positive")
+	RingOp                                     = ring.NewOp([]ring.InstanceState{ring.ACTIVE}, nil)
 )
 
 // BlocksGrouperFactory builds and returns the grouper to use to compact a tenant's blocks.
@@ -89,6 +95,12 @@ type Config struct {
 	TenantCleanupDelay    time.Duration           `yaml:"tenant_cleanup_delay" category:"advanced"`
 	MaxCompactionTime     time.Duration           `yaml:"max_compaction_time" category:"advanced"`
 
+	BucketIndexTopLabelNames                   int `yaml:"bucket_index_top_label_names" category:"experimental"`
+	BucketIndexTopLabelNamesMaxBlocksPerUpdate int `yaml:"bucket_index_top_label_names_max_blocks_per_update" category:"experimental"`
+	BucketIndexTopLabelNamesConcurrency        int `yaml:"bucket_index_top_label_names_concurrency" category:"experimental"`
+	MaxBlocksMergedPerPass                     int `yaml:"max_blocks_merged_per_pass" category:"experimental"`
+	MaxBlockUploadValidationConcurrency        int `yaml:"max_block_upload_validation_concurrency" category:"experimental"`
+
 	// Compactor concurrency options
 	MaxOpeningBlocksConcurrency int `yaml:"max_opening_blocks_concurrency" category:"advanced"` // Number of goroutines opening blocks before compaction.
 	MaxClosingBlocksConcurrency int `yaml:"max_closing_blocks_concurrency" category:"advanced"` // Max number of blocks that can be closed concurrently during split compaction. Note that closing of newly compacted block uses a lot of memory for writing index.
@@ -137,6 +149,11 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 		"If not 0, blocks will be marked for deletion and compactor component will permanently delete blocks marked for deletion from the bucket. "+
 		"If 0, blocks will be deleted straight away. Note that deleting blocks immediately can cause query failures.")
 	f.DurationVar(&cfg.TenantCleanupDelay, "compactor.tenant-cleanup-delay", 6*time.Hour, "For tenants marked for deletion, this is time between deleting of last block, and doing final cleanup (marker files, debug files) of the tenant.")
+	f.IntVar(&cfg.BucketIndexTopLabelNames, "compactor.bucket-index-top-label-names", 0, "Number of label names with the highest number of values to store in the bucket index for each block. The label names are read from the postings offset table of the block index. 0 to disable.")
+	f.IntVar(&cfg.BucketIndexTopLabelNamesMaxBlocksPerUpdate, "compactor.bucket-index-top-label-names-max-blocks-per-update", 100, "Maximum number of blocks to read the top label names from at every update of the bucket index of a tenant. The most recent blocks are read first, and the remaining ones at the next updates. Blocks failing to be read are retried after 24 hours. 0 for no limit.")
+	f.IntVar(&cfg.BucketIndexTopLabelNamesConcurrency, "compactor.bucket-index-top-label-names-concurrency", 4, "Number of blocks to read the top label names from concurrently when updating the bucket index of a tenant.")
+	f.IntVar(&cfg.MaxBlocksMergedPerPass, "compactor.max-blocks-merged-per-pass", 0, "Maximum number of blocks merged at once by a compaction job. When a job compacts more blocks, such as many overlapping out-of-order blocks, they're merged in multiple passes, each one writing an intermediate block to the local disk, to bound the memory used by the compaction. 0 to merge all blocks at once.")
+	f.IntVar(&cfg.MaxBlockUploadValidationConcurrency, "compactor.max-block-upload-validation-concurrency", 1, "Max number of blocks uploaded with the block upload API validated concurrently. When the limit is reached, the requests to complete a block upload are rejected with 429 (Too Many Requests), and can be retried later.")
 	// compactor concurrency options
 	f.IntVar(&cfg.MaxOpeningBlocksConcurrency, "compactor.max-opening-blocks-concurrency", 1, "Number of goroutines opening blocks before compaction.")
 	f.IntVar(&cfg.MaxClosingBlocksConcurrency, "compactor.max-closing-blocks-concurrency", 1, "Max number of blocks that can be closed concurrently during split compaction. Note that closing of newly compacted block uses a lot of memory for writing index.")
@@ -163,6 +180,18 @@ func (cfg *Config) Validate() error {
 	if cfg.SymbolsFlushersConcurrency < 1 {
 		return errInvalidSymbolFlushersConcurrency
 	}
+	if cfg.MaxBlocksMergedPerPass < 0 || cfg.MaxBlocksMergedPerPass == 1 {
+		return errInvalidMaxBlocksMergedPerPass
+	}
+	if cfg.MaxBlockUploadValidationConcurrency < 1 {
+		return errInvalidBlockUploadValidationConcurrency
+	}
+	if cfg.BucketIndexTopLabelNamesMaxBlocksPerUpdate < 0 {
+		return errInvalidTopLabelNamesMaxBlocks
+	}
+	if cfg.BucketIndexTopLabelNames > 0 && cfg.BucketIndexTopLabelNamesConcurrency < 1 {
+		return errInvalidTopLabelNamesConcurrency
+	}
 
 	if !util.StringsContain(CompactionOrders, cfg.CompactionJobsOrder) {
 		return errInvalidCompactionOrder
@@ -175,6 +204,9 @@ func (cfg *Config) Validate() error {
 type ConfigProvider interface {
 	bucket.TenantConfigProvider
 
+	// Limits of the series labels, used to validate uploaded blocks.
+	validation.LabelValidationConfig
+
 	// CompactorBlocksRetentionPeriod returns the retention period for a given user.
 	CompactorBlocksRetentionPeriod(user string) time.Duration
 
@@ -195,6 +227,33 @@ type ConfigProvider interface {
 
 	// CompactorBlockUploadEnabled returns whether block upload is enabled for a given tenant.
 	CompactorBlockUploadEnabled(tenantID string) bool
+
+	// CompactorBlockUploadValidationEnabled returns whether the validation of uploaded blocks is enabled for a given tenant.
+	CompactorBlockUploadValidationEnabled(tenantID string) bool
+
+	// CompactorBlockUploadVerifyChunks returns whether the chunks of uploaded blocks are verified for a given tenant.
+	CompactorBlockUploadVerifyChunks(tenantID string) bool
+
+	// CompactorBlockRewriteEnabled returns whether block rewrite is enabled for a given tenant.
+	CompactorBlockRewriteEnabled(tenantID string) bool
+
+	// CompactorMaxBlocksPerJob returns the maximum number of blocks compacted together by a single
+	// compaction job for a given tenant. 0 means no limit.
+	CompactorMaxBlocksPerJob(userID string) int
+
+	// CompactorDownsamplingEnabled returns whether downsampling of blocks is enabled for a given tenant.
+	CompactorDownsamplingEnabled(userID string) bool
+
+	// CompactorDownsampled5mBlocksRetentionPeriod returns the retention period of blocks downsampled
+	// to 5m resolution for a given user. 0 means the raw blocks retention period applies.
+	CompactorDownsampled5mBlocksRetentionPeriod(userID string) time.Duration
+
+	// CompactorDownsampled1hBlocksRetentionPeriod returns the retention period of blocks downsampled
+	// to 1h resolution for a given user. 0 means the raw blocks retention period applies.
+	CompactorDownsampled1hBlocksRetentionPeriod(userID string) time.Duration
+
+	// CompactorRetentionRules returns the per-series retention rules for a given tenant.
+	CompactorRetentionRules(userID string) validation.RetentionRules
 }
 
 // MultitenantCompactor is a multi-tenant TSDB blocks compactor based on Thanos.
@@ -233,6 +292,12 @@ type MultitenantCompactor struct {
 	shardingStrategy shardingStrategy
 	jobsOrder        JobsOrderFunc
 
+	// History of the compaction jobs run by this compactor.
+	jobsHistory *compactionJobsHistory
+
+	// Validations of the blocks uploaded with the block upload API.
+	blockUploadValidations *blockUploadValidationPool
+
 	// Metrics.
 	compactionRunsStarted          prometheus.Counter
 	compactionRunsCompleted        prometheus.Counter
@@ -248,6 +313,13 @@ type MultitenantCompactor struct {
 	// Metrics shared across all BucketCompactor instances.
 	bucketCompactorMetrics *BucketCompactorMetrics
 
+	// Metrics shared across all BucketDownsampler instances.
+	bucketDownsamplerMetrics *BucketDownsamplerMetrics
+
+	// Metrics shared across all BucketRetentionRewriter instances.
+	bucketRetentionRewriterMetrics *BucketRetentionRewriterMetrics
+	bucketRewriteJobRunnerMetrics  *BucketRewriteJobRunnerMetrics
+
 	// TSDB syncer metrics
 	syncerMetrics *aggregatedSyncerMetrics
 }
@@ -342,6 +414,9 @@ func newMultitenantCompactor(
 	}
 
 	c.bucketCompactorMetrics = NewBucketCompactorMetrics(c.blocksMarkedForDeletion, registerer)
+	c.bucketDownsamplerMetrics = NewBucketDownsamplerMetrics(registerer)
+	c.bucketRetentionRewriterMetrics = NewBucketRetentionRewriterMetrics(c.blocksMarkedForDeletion, registerer)
+	c.bucketRewriteJobRunnerMetrics = NewBucketRewriteJobRunnerMetrics(c.blocksMarkedForDeletion, registerer)
 
 	if len(compactorCfg.EnabledTenants) > 0 {
 		level.Info(c.logger).Log("msg", "compactor using enabled users", "enabled", strings.Join(compactorCfg.EnabledTenants, ", "))
@@ -350,6 +425,8 @@ func newMultitenantCompactor(
 		level.Info(c.logger).Log("msg", "compactor using disabled users", "disabled", strings.Join(compactorCfg.DisabledTenants, ", "))
 	}
 
+	c.jobsHistory = newCompactionJobsHistory()
+	c.blockUploadValidations = newBlockUploadValidationPool(compactorCfg.MaxBlockUploadValidationConcurrency)
 	c.jobsOrder = GetJobsOrderFunction(compactorCfg.CompactionJobsOrder)
 	if c.jobsOrder == nil {
 		return nil, errInvalidCompactionOrder
@@ -442,11 +519,14 @@ func (c *MultitenantCompactor) starting(ctx context.Context) error {
 
 	// Create the blocks cleaner (service).
 	c.blocksCleaner = NewBlocksCleaner(BlocksCleanerConfig{
-		DeletionDelay:           c.compactorCfg.DeletionDelay,
-		CleanupInterval:         util.DurationWithJitter(c.compactorCfg.CleanupInterval, 0.1),
-		CleanupConcurrency:      c.compactorCfg.CleanupConcurrency,
-		TenantCleanupDelay:      c.compactorCfg.TenantCleanupDelay,
-		DeleteBlocksConcurrency: defaultDeleteBlocksConcurrency,
+		DeletionDelay:            c.compactorCfg.DeletionDelay,
+		CleanupInterval:          util.DurationWithJitter(c.compactorCfg.CleanupInterval, 0.1),
+		CleanupConcurrency:       c.compactorCfg.CleanupConcurrency,
+		TenantCleanupDelay:       c.compactorCfg.TenantCleanupDelay,
+		DeleteBlocksConcurrency:  defaultDeleteBlocksConcurrency,
+		TopLabelNames:            c.compactorCfg.BucketIndexTopLabelNames,
+		TopLabelNamesMaxBlocks:   c.compactorCfg.BucketIndexTopLabelNamesMaxBlocksPerUpdate,
+		TopLabelNamesConcurrency: c.compactorCfg.BucketIndexTopLabelNamesConcurrency,
 	}, c.bucketClient, c.shardingStrategy.blocksCleanerOwnUser, c.cfgProvider, c.parentLogger, c.registerer)
 
 	// Start blocks cleaner asynchronously, don't wait until initial cleanup is finished.
@@ -461,6 +541,7 @@ func (c *MultitenantCompactor) starting(ctx context.Context) error {
 func (c *MultitenantCompactor) stopping(_ error) error {
 	ctx := context.Background()
 
+	c.blockUploadValidations.stop()
 	services.StopAndAwaitTerminated(ctx, c.blocksCleaner) //nolint:errcheck
 	if c.ringSubservices != nil {
 		return services.StopManagerAndAwaitStopped(ctx, c.ringSubservices)
@@ -570,6 +651,10 @@ func (c *MultitenantCompactor) compactUsers(ctx context.Context) {
 		level.Info(c.logger).Log("msg", "successfully compacted user blocks", "user", userID)
 	}
 
+	// Forget the compaction jobs run for unowned tenants, which belong to different compactors now,
+	// or have been deleted completely.
+	c.jobsHistory.retainTenants(ownedUsers)
+
 	// Delete local files for unowned tenants, if there are any. This cleans up
 	// leftover local files for tenants that belong to different compactors now,
 	// or have been deleted completely.
@@ -677,10 +762,12 @@ func (c *MultitenantCompactor) compactUser(ctx context.Context, userID string) e
 		return errors.Wrap(err, "failed to create syncer")
 	}
 
+	grouper := c.blocksGrouperFactory(ctx, c.compactorCfg, c.cfgProvider, userID, ulogger, reg)
+
 	compactor, err := NewBucketCompactor(
 		ulogger,
 		syncer,
-		c.blocksGrouperFactory(ctx, c.compactorCfg, c.cfgProvider, userID, ulogger, reg),
+		grouper,
 		c.blocksPlanner,
 		c.blocksCompactor,
 		path.Join(c.compactorCfg.DataDir, "compact"),
@@ -696,10 +783,96 @@ func (c *MultitenantCompactor) compactUser(ctx context.Context, userID string) e
 		return errors.Wrap(err, "failed to create bucket compactor")
 	}
 
+	compactor.jobsHistory = c.jobsHistory
+	compactor.maxBlocksPerJob = c.cfgProvider.CompactorMaxBlocksPerJob(userID)
+	compactor.maxBlocksMergedPerPass = c.compactorCfg.MaxBlocksMergedPerPass
+
 	if err := compactor.Compact(ctx, c.compactorCfg.MaxCompactionTime); err != nil {
 		return errors.Wrap(err, "compaction")
 	}
 
+	ownBlock := func(blockID ulid.ULID) (bool, error) {
+		return c.shardingStrategy.ownBlock(userID, blockID)
+	}
+
+	if c.cfgProvider.CompactorDownsamplingEnabled(userID) {
+		ranges := c.compactorCfg.BlockRanges.ToMilliseconds()
+		downsampler := NewBucketDownsampler(
+			ulogger,
+			syncer,
+			grouper,
+			bucket,
+			path.Join(c.compactorCfg.DataDir, "downsample"),
+			ranges[len(ranges)-1],
+			ownBlock,
+			c.bucketDownsamplerMetrics,
+		)
+
+		if err := downsampler.Downsample(ctx); err != nil {
+			return errors.Wrap(err, "downsampling")
+		}
+	}
+
+	if rules := c.cfgProvider.CompactorRetentionRules(userID); len(rules) > 0 {
+		rewriter := NewBucketRetentionRewriter(
+			ulogger,
+			syncer,
+			grouper,
+			bucket,
+			path.Join(c.compactorCfg.DataDir, "rewrite"),
+			rules,
+			func(resolution int64) time.Duration {
+				return defaultRetentionPeriod(c.cfgProvider, userID, resolution)
+			},
+			ownBlock,
+			c.bucketRetentionRewriterMetrics,
+		)
+
+		if err := rewriter.Rewrite(ctx); err != nil {
+			return errors.Wrap(err, "retention rewrite")
+		}
+	}
+
+	if c.cfgProvider.CompactorBlockRewriteEnabled(userID) {
+		// Unlike the syncer, the fetcher of the blocks to rewrite doesn't filter out the blocks marked
+		// for no-compaction, so that rewrite jobs can fix blocks with out-of-order chunks.
+		rewriteDeduplicateBlocksFilter := NewShardAwareDeduplicateFilter()
+		rewriteFetcher, err := block.NewMetaFetcher(
+			ulogger,
+			c.compactorCfg.MetaSyncConcurrency,
+			bucket,
+			"",
+			reg,
+			[]block.MetadataFilter{
+				NewLabelRemoverFilter([]string{
+					mimir_tsdb.DeprecatedTenantIDExternalLabel,
+					mimir_tsdb.DeprecatedIngesterIDExternalLabel,
+				}),
+				block.NewConsistencyDelayMetaFilter(ulogger, c.compactorCfg.ConsistencyDelay, reg),
+				NewExcludeMarkedForDeletionFilter(bucket),
+				rewriteDeduplicateBlocksFilter,
+			},
+		)
+		if err != nil {
+			return err
+		}
+
+		runner := NewBucketRewriteJobRunner(
+			ulogger,
+			syncer,
+			grouper,
+			rewriteFetcher,
+			bucket,
+			path.Join(c.compactorCfg.DataDir, "rewrite-jobs"),
+			ownBlock,
+			c.bucketRewriteJobRunnerMetrics,
+		)
+
+		if err := runner.Run(ctx); err != nil {
+			return errors.Wrap(err, "rewrite jobs")
+		}
+	}
+
 	return nil
 }
 
@@ -735,6 +908,8 @@ type shardingStrategy interface {
 	compactorOwnUser(userID string) (bool, error)
 	blocksCleanerOwnUser(userID string) (bool, error)
 	ownJob(job *Job) (bool, error)
+	ownBlock(userID string, blockID ulid.ULID) (bool, error)
+	jobOwner(job *Job) (string, error)
 }
 
 // splitAndMergeShardingStrategy is used by split-and-merge compactor when configured with sharding.
@@ -791,23 +966,56 @@ func (s *splitAndMergeShardingStrategy) ownJob(job *Job) (bool, error) {
 	return instanceOwnsTokenInRing(r, s.ringLifecycler.Addr, job.ShardingKey())
 }
 
+// jobOwner returns the address of the compactor executing the job, regardless of this instance.
+func (s *splitAndMergeShardingStrategy) jobOwner(job *Job) (string, error) {
+	if !s.allowedTenants.IsAllowed(job.UserID()) {
+		return "", nil
+	}
+
+	r := s.ring.ShuffleShard(job.UserID(), s.configProvider.CompactorTenantShardSize(job.UserID()))
+
+	return instanceOwningTokenInRing(r, job.ShardingKey())
+}
+
+// Only single compactor should downsample or rewrite a block.
+func (s *splitAndMergeShardingStrategy) ownBlock(userID string, blockID ulid.ULID) (bool, error) {
+	ok, err := s.compactorOwnUser(userID)
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	r := s.ring.ShuffleShard(userID, s.configProvider.CompactorTenantShardSize(userID))
+
+	return instanceOwnsTokenInRing(r, s.ringLifecycler.Addr, blockID.String())
+}
+
 func instanceOwnsTokenInRing(r ring.ReadRing, instanceAddr string, key string) (bool, error) {
+	// Check whether this compactor instance owns the token.
+	owner, err := instanceOwningTokenInRing(r, key)
+	if err != nil {
+		return false, err
+	}
+
+	return owner == instanceAddr, nil
+}
+
+// instanceOwningTokenInRing returns the address of the instance owning the key in the ring.
+func instanceOwningTokenInRing(r ring.ReadRing, key string) (string, error) {
 	// Hash the key.
 	hasher := fnv.New32a()
 	_, _ = hasher.Write([]byte(key))
 	hash := hasher.Sum32()
 
-	// Check whether this compactor instance owns the token.
 	rs, err := r.Get(hash, RingOp, nil, nil, nil)
 	if err != nil {
-		return false, err
+		return "", err
 	}
 
 	if len(rs.Instances) != 1 {
-		return false, fmt.Errorf("unexpected number of compactors in the shard (expected 1, got %d)", len(rs.Instances))
+		return "", fmt.Errorf("unexpected number of compactors in the shard (expected 1, got %d)", len(rs.Instances))
 	}
 
-	return rs.Instances[0].Addr == instanceAddr, nil
+	return rs.Instances[0].Addr, nil
 }
 
 const compactorMetaPrefix = "compactor-meta-"
@@ -131,6 +131,25 @@ func TestConfig_Validate(t *testing.T) {
 			setup:    func(cfg *Config) { cfg.SymbolsFlushersConcurrency = 0 },
 			expected: errInvalidSymbolFlushersConcurrency.Error(),
 		},
+		"should pass with a valid value of max-blocks-merged-per-pass": {
+			setup:    func(cfg *Config) { cfg.MaxBlocksMergedPerPass = 2 },
+			expected: "",
+		},
+		"should fail on invalid value of max-blocks-merged-per-pass": {
+			setup:    func(cfg *Config) { cfg.MaxBlocksMergedPerPass = 1 },
+			expected: errInvalidMaxBlocksMergedPerPass.Error(),
+		},
+		"should fail on invalid value of bucket-index-top-label-names-max-blocks-per-update": {
+			setup:    func(cfg *Config) { cfg.BucketIndexTopLabelNamesMaxBlocksPerUpdate = -1 },
+			expected: errInvalidTopLabelNamesMaxBlocks.Error(),
+		},
+		"should fail on invalid value of bucket-index-top-label-names-concurrency": {
+			setup: func(cfg *Config) {
+				cfg.BucketIndexTopLabelNames = 10
+				cfg.BucketIndexTopLabelNamesConcurrency = 0
+			},
+			expected: errInvalidTopLabelNamesConcurrency.Error(),
+		},
 	}
 
 	for testName, testData := range tests {
@@ -27,6 +27,9 @@ type Job struct {
 
 	// The number of shards to split compacted block into. Not used if splitting is disabled.
 	splitNumShards uint32
+
+	// The shard of the blocks in this job, if known. See the job's shardID planned by the split-and-merge grouper.
+	shardID string
 }
 
 // NewJob returns a new compaction Job.
@@ -126,6 +129,12 @@ func (job *Job) SplittingShards() uint32 {
 	return job.splitNumShards
 }
 
+// ShardID returns the shard of the blocks in this job: the split group for jobs splitting blocks, or the shard
+// of the blocks to merge otherwise. It's empty if unknown.
+func (job *Job) ShardID() string {
+	return job.shardID
+}
+
 // ShardingKey returns the key used to shard this job across multiple instances.
 func (job *Job) ShardingKey() string {
 	return job.shardingKey
@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	_ "embed" // Used to embed html template
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/thanos/pkg/block"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/listblocks"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+// maxJobsHistoryPerTenant is the number of compaction jobs run by the compactor which are kept in the history
+// of each tenant.
+const maxJobsHistoryPerTenant = 100
+
+//go:embed planned_jobs.gohtml
+var plannedJobsPageHTML string
+var plannedJobsPageTemplate = template.Must(template.New("webpage").Funcs(template.FuncMap{
+	"formatTime": func(t int64) string {
+		return util.TimeFromMillis(t).UTC().Format(time.RFC3339)
+	},
+	"formatBytes": humanize.IBytes,
+}).Parse(plannedJobsPageHTML))
+
+type plannedJobsPageContents struct {
+	Now         time.Time              `json:"now"`
+	Tenant      string                 `json:"tenant"`
+	PlannedJobs []plannedCompactionJob `json:"planned_jobs"`
+	History     []compactionJobRun     `json:"history"`
+}
+
+// plannedCompactionJob is a compaction job planned for a tenant, in the order the compactors run it.
+type plannedCompactionJob struct {
+	Key     string `json:"key"`
+	Stage   string `json:"stage"`
+	ShardID string `json:"shard_id,omitempty"`
+
+	// SplitShards is the number of shards the blocks are split into by a split job.
+	SplitShards uint32 `json:"split_shards,omitempty"`
+
+	MinTime int64       `json:"min_time"`
+	MaxTime int64       `json:"max_time"`
+	Blocks  []ulid.ULID `json:"blocks"`
+
+	// EstimatedOutputSizeBytes is the size of the input blocks. The compacted blocks are usually smaller,
+	// because the samples of the overlapping blocks are deduplicated.
+	EstimatedOutputSizeBytes uint64 `json:"estimated_output_size_bytes"`
+
+	// Owner is the address of the compactor running the job.
+	Owner      string `json:"owner,omitempty"`
+	OwnerError string `json:"owner_error,omitempty"`
+}
+
+// compactionJobRun is a compaction job run by this compactor.
+type compactionJobRun struct {
+	Key             string    `json:"key"`
+	Stage           string    `json:"stage"`
+	ShardID         string    `json:"shard_id,omitempty"`
+	MinTime         int64     `json:"min_time"`
+	MaxTime         int64     `json:"max_time"`
+	Blocks          int       `json:"blocks"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Error           string    `json:"error,omitempty"`
+}
+
+func jobStage(job *Job) string {
+	if job.UseSplitting() {
+		return string(stageSplit)
+	}
+	return string(stageMerge)
+}
+
+// compactionJobsHistory keeps the most recent compaction jobs run by this compactor, for each tenant.
+type compactionJobsHistory struct {
+	mtx  sync.Mutex
+	runs map[string][]compactionJobRun
+}
+
+func newCompactionJobsHistory() *compactionJobsHistory {
+	return &compactionJobsHistory{runs: map[string][]compactionJobRun{}}
+}
+
+// add records the run of a job started at the given time. It's a no-op on a nil history.
+func (h *compactionJobsHistory) add(job *Job, startedAt time.Time, err error) {
+	if h == nil {
+		return
+	}
+
+	run := compactionJobRun{
+		Key:             job.Key(),
+		Stage:           jobStage(job),
+		ShardID:         job.ShardID(),
+		MinTime:         job.MinTime(),
+		MaxTime:         job.MaxTime(),
+		Blocks:          len(job.Metas()),
+		StartedAt:       startedAt,
+		DurationSeconds: time.Since(startedAt).Seconds(),
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	runs := append(h.runs[job.UserID()], run)
+	if len(runs) > maxJobsHistoryPerTenant {
+		runs = runs[len(runs)-maxJobsHistoryPerTenant:]
+	}
+	h.runs[job.UserID()] = runs
+}
+
+// retainTenants removes the runs of the tenants not in the given set, like the tenants not owned by
+// this compactor anymore. It's a no-op on a nil history.
+func (h *compactionJobsHistory) retainTenants(userIDs map[string]struct{}) {
+	if h == nil {
+		return
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for userID := range h.runs {
+		if _, ok := userIDs[userID]; !ok {
+			delete(h.runs, userID)
+		}
+	}
+}
+
+// get returns the runs of the tenant, most recent first.
+func (h *compactionJobsHistory) get(userID string) []compactionJobRun {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	runs := h.runs[userID]
+	out := make([]compactionJobRun, 0, len(runs))
+	for i := len(runs) - 1; i >= 0; i-- {
+		out = append(out, runs[i])
+	}
+	return out
+}
+
+// PlannedJobsHandler shows the compaction jobs planned for a tenant, as planned by the compactors when compacting
+// the tenant's blocks now, and the history of the jobs recently run by this compactor.
+func (c *MultitenantCompactor) PlannedJobsHandler(w http.ResponseWriter, req *http.Request) {
+	if c.State() != services.Running {
+		// The sharding strategy and the ring are not ready before the compactor is running.
+		writeMessage(w, "Compactor is not running yet.")
+		return
+	}
+
+	tenantID := mux.Vars(req)["tenant"]
+	if tenantID == "" {
+		util.WriteTextResponse(w, "Tenant ID can't be empty")
+		return
+	}
+
+	jobs, err := c.planJobs(req.Context(), tenantID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to plan compaction jobs: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	planned := make([]plannedCompactionJob, 0, len(jobs))
+	for _, job := range jobs {
+		p := plannedCompactionJob{
+			Key:     job.Key(),
+			Stage:   jobStage(job),
+			ShardID: job.ShardID(),
+			MinTime: job.MinTime(),
+			MaxTime: job.MaxTime(),
+			Blocks:  job.IDs(),
+		}
+		if job.UseSplitting() {
+			p.SplitShards = job.SplittingShards()
+		}
+		for _, m := range job.Metas() {
+			p.EstimatedOutputSizeBytes += listblocks.GetBlockSizeBytes(m)
+		}
+		if p.Owner, err = c.shardingStrategy.jobOwner(job); err != nil {
+			p.OwnerError = err.Error()
+		}
+		planned = append(planned, p)
+	}
+
+	util.RenderHTTPResponse(w, plannedJobsPageContents{
+		Now:         time.Now(),
+		Tenant:      tenantID,
+		PlannedJobs: planned,
+		History:     c.jobsHistory.get(tenantID),
+	}, plannedJobsPageTemplate, req)
+}
+
+// planJobs returns the compaction jobs of the tenant, sorted by the configured jobs order. The blocks are
+// filtered like when compacting the tenant's blocks, but the compacted blocks not deleted yet aren't
+// garbage collected.
+func (c *MultitenantCompactor) planJobs(ctx context.Context, userID string) ([]*Job, error) {
+	bucket := bucket.NewUserBucketClient(userID, c.bucketClient, c.cfgProvider)
+	reg := prometheus.NewRegistry()
+	ulogger := util_log.WithUserID(userID, c.logger)
+
+	fetcher, err := block.NewMetaFetcher(
+		ulogger,
+		c.compactorCfg.MetaSyncConcurrency,
+		bucket,
+		"",
+		reg,
+		[]block.MetadataFilter{
+			NewLabelRemoverFilter([]string{
+				mimir_tsdb.DeprecatedTenantIDExternalLabel,
+				mimir_tsdb.DeprecatedIngesterIDExternalLabel,
+			}),
+			block.NewConsistencyDelayMetaFilter(ulogger, c.compactorCfg.ConsistencyDelay, reg),
+			NewExcludeMarkedForDeletionFilter(bucket),
+			NewShardAwareDeduplicateFilter(),
+			NewNoCompactionMarkFilter(bucket, true),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	metas, _, err := fetcher.Fetch(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetch blocks metadata")
+	}
+
+	grouper := c.blocksGrouperFactory(ctx, c.compactorCfg, c.cfgProvider, userID, ulogger, reg)
+	jobs, err := grouper.Groups(excludeDownsampledBlocks(metas))
+	if err != nil {
+		return nil, errors.Wrap(err, "build compaction jobs")
+	}
+
+	return c.jobsOrder(jobs), nil
+}
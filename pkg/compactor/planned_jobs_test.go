@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
+	"github.com/oklog/ulid"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+)
+
+func TestMultitenantCompactor_PlannedJobsHandler(t *testing.T) {
+	const userID = "user-1"
+
+	bkt := objstore.NewInMemBucket()
+	require.NoError(t, bkt.Upload(context.Background(), "user-1/01DTVP434PA9VFXSW2JK000001/meta.json", strings.NewReader(mockBlockMetaJSONWithTimeRange("01DTVP434PA9VFXSW2JK000001", 1574776800000, 1574784000000))))
+	require.NoError(t, bkt.Upload(context.Background(), "user-1/01DTVP434PA9VFXSW2JK000002/meta.json", strings.NewReader(mockBlockMetaJSONWithTimeRange("01DTVP434PA9VFXSW2JK000002", 1574863200000, 1574870400000))))
+
+	cfg := prepareConfig(t)
+	cfg.ShardingRing.InstanceID = "compactor-1"
+	cfg.ShardingRing.InstanceAddr = "1.2.3.4"
+
+	limits := newMockConfigProvider()
+	limits.splitAndMergeShards = map[string]int{userID: 4}
+	limits.splitGroups = map[string]int{userID: 4}
+
+	c, _, tsdbPlanner, _, _ := prepareWithConfigProvider(t, cfg, bkt, limits)
+
+	// Mock the planner as if there's no compaction to do, so the same jobs are planned after the compaction run.
+	tsdbPlanner.On("Plan", mock.Anything, mock.Anything).Return([]*metadata.Meta{}, nil)
+
+	newRequest := func(tenantID string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/compactor/tenant/"+tenantID+"/planned_jobs", nil)
+		req.Header.Set("Accept", "application/json")
+		return mux.SetURLVars(req, map[string]string{"tenant": tenantID})
+	}
+
+	// The jobs can't be planned before the compactor is running.
+	resp := httptest.NewRecorder()
+	c.PlannedJobsHandler(resp, newRequest(userID))
+	assert.Contains(t, resp.Body.String(), "Compactor is not running yet.")
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	// Wait until a run has completed.
+	test.Poll(t, 5*time.Second, 1.0, func() interface{} {
+		return prom_testutil.ToFloat64(c.compactionRunsCompleted)
+	})
+
+	resp = httptest.NewRecorder()
+	c.PlannedJobsHandler(resp, newRequest(userID))
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	contents := plannedJobsPageContents{}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &contents))
+	assert.Equal(t, userID, contents.Tenant)
+
+	// Jobs are sorted from the oldest one.
+	assert.Equal(t, []plannedCompactionJob{
+		{
+			Key:         "0@17241709254077376921-split-4_of_4-1574776800000-1574784000000",
+			Stage:       "split",
+			ShardID:     "4_of_4",
+			SplitShards: 4,
+			MinTime:     1574776800000,
+			MaxTime:     1574784000000,
+			Blocks:      []ulid.ULID{ulid.MustParse("01DTVP434PA9VFXSW2JK000001")},
+			Owner:       "1.2.3.4:0",
+		},
+		{
+			Key:         "0@17241709254077376921-split-1_of_4-1574863200000-1574870400000",
+			Stage:       "split",
+			ShardID:     "1_of_4",
+			SplitShards: 4,
+			MinTime:     1574863200000,
+			MaxTime:     1574870400000,
+			Blocks:      []ulid.ULID{ulid.MustParse("01DTVP434PA9VFXSW2JK000002")},
+			Owner:       "1.2.3.4:0",
+		},
+	}, contents.PlannedJobs)
+
+	// Both jobs have been run by the compactor.
+	require.Len(t, contents.History, 2)
+	assert.ElementsMatch(t, []string{
+		"0@17241709254077376921-split-4_of_4-1574776800000-1574784000000",
+		"0@17241709254077376921-split-1_of_4-1574863200000-1574870400000",
+	}, []string{contents.History[0].Key, contents.History[1].Key})
+	for _, run := range contents.History {
+		assert.Empty(t, run.Error)
+		assert.Equal(t, 1, run.Blocks)
+	}
+
+	// The jobs are rendered in the HTML page too.
+	req := newRequest(userID)
+	req.Header.Del("Accept")
+	resp = httptest.NewRecorder()
+	c.PlannedJobsHandler(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(), "0@17241709254077376921-split-4_of_4-1574776800000-1574784000000")
+
+	// Other tenants have no jobs.
+	resp = httptest.NewRecorder()
+	c.PlannedJobsHandler(resp, newRequest("user-2"))
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	contents = plannedJobsPageContents{}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &contents))
+	assert.Empty(t, contents.PlannedJobs)
+	assert.Empty(t, contents.History)
+}
+
+func TestCompactionJobsHistory(t *testing.T) {
+	h := newCompactionJobsHistory()
+
+	newJob := func(userID, key string) *Job {
+		job := NewJob(userID, key, labels.EmptyLabels(), 0, metadata.NoneFunc, false, 0, key)
+		require.NoError(t, job.AppendMeta(&metadata.Meta{}))
+		return job
+	}
+
+	for i := 0; i < maxJobsHistoryPerTenant+10; i++ {
+		h.add(newJob("user-1", "job"), time.Now(), nil)
+	}
+	h.add(newJob("user-1", "failed"), time.Now(), errors.New("compaction failed"))
+	h.add(newJob("user-2", "other"), time.Now(), nil)
+
+	runs := h.get("user-1")
+	require.Len(t, runs, maxJobsHistoryPerTenant)
+	assert.Equal(t, "failed", runs[0].Key)
+	assert.Equal(t, "compaction failed", runs[0].Error)
+	assert.Equal(t, "job", runs[1].Key)
+
+	require.Len(t, h.get("user-2"), 1)
+	assert.Empty(t, h.get("user-3"))
+
+	// The runs of the tenants not retained are removed.
+	h.retainTenants(map[string]struct{}{"user-1": {}, "user-3": {}})
+	require.Len(t, h.get("user-1"), maxJobsHistoryPerTenant)
+	assert.Empty(t, h.get("user-2"))
+	assert.Empty(t, h.get("user-3"))
+
+	// Adding to or pruning a nil history is a no-op.
+	var nilHistory *compactionJobsHistory
+	nilHistory.add(newJob("user-1", "job"), time.Now(), nil)
+	nilHistory.retainTenants(map[string]struct{}{})
+}
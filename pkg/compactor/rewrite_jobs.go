@@ -0,0 +1,245 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/thanos-io/objstore"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// rewriteJobsPrefix is the prefix of the objects storing the block rewrite jobs in the tenant's bucket.
+	rewriteJobsPrefix = "rewrite-jobs"
+
+	// rewriteJobExtension is the extension of the objects storing the block rewrite jobs.
+	rewriteJobExtension = ".yaml"
+
+	// maxRewriteJobBlockExamples is the max number of affected series reported for each block.
+	maxRewriteJobBlockExamples = 10
+)
+
+var errRewriteJobNotFound = errors.New("rewrite job not found")
+
+// RewriteJobState is the state of a block rewrite job.
+type RewriteJobState string
+
+const (
+	RewriteJobPending   RewriteJobState = "pending"
+	RewriteJobCompleted RewriteJobState = "completed"
+	RewriteJobFailed    RewriteJobState = "failed"
+)
+
+// RewriteJobSpec describes how to rewrite the series of a tenant's blocks.
+type RewriteJobSpec struct {
+	// Selectors select the series to rewrite. A series is rewritten if it matches any selector.
+	Selectors []string `yaml:"selectors"`
+
+	// Start and End select the [Start, End) time range of the samples to drop or relabel. Blocks overlapping
+	// the time range are rewritten, keeping the samples outside the time range as they are. A zero Start
+	// means no lower bound, while End defaults to the job submission time.
+	Start time.Time `yaml:"start,omitempty"`
+	End   time.Time `yaml:"end,omitempty"`
+
+	// RelabelConfigs are applied to the selected series. Series dropped by the relabeling are deleted.
+	RelabelConfigs []*relabel.Config `yaml:"relabel_configs,omitempty"`
+
+	// DropSeries deletes the selected series.
+	DropSeries bool `yaml:"drop_series,omitempty"`
+
+	// FixOutOfOrderChunks sorts the chunks of the selected series by time, merging the overlapping ones.
+	FixOutOfOrderChunks bool `yaml:"fix_out_of_order_chunks,omitempty"`
+
+	// DryRun only reports the series which would be affected, without rewriting any block.
+	DryRun bool `yaml:"dry_run,omitempty"`
+}
+
+// Validate returns an error if the spec is invalid.
+func (s *RewriteJobSpec) Validate() error {
+	if len(s.Selectors) == 0 {
+		return errors.New("at least one series selector is required")
+	}
+	if _, err := s.matchers(); err != nil {
+		return err
+	}
+	if !s.DropSeries && len(s.RelabelConfigs) == 0 && !s.FixOutOfOrderChunks {
+		return errors.New("at least one of relabel configs, drop series and fix out-of-order chunks is required")
+	}
+	if s.DropSeries && len(s.RelabelConfigs) > 0 {
+		return errors.New("relabel configs can't be used together with drop series")
+	}
+	for i, cfg := range s.RelabelConfigs {
+		if cfg == nil {
+			return errors.Errorf("relabel config %d is empty", i)
+		}
+	}
+	if !s.Start.IsZero() && !s.End.IsZero() && s.End.Before(s.Start) {
+		return errors.New("end must be after start")
+	}
+	return nil
+}
+
+// matchers returns the matchers of each selector.
+func (s *RewriteJobSpec) matchers() ([][]*labels.Matcher, error) {
+	res := make([][]*labels.Matcher, 0, len(s.Selectors))
+	for _, sel := range s.Selectors {
+		m, err := parser.ParseMetricSelector(sel)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid series selector %q", sel)
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+// RewriteJob is a job rewriting the series of a tenant's blocks, along with its status.
+type RewriteJob struct {
+	ID   ulid.ULID      `yaml:"id"`
+	Spec RewriteJobSpec `yaml:"spec"`
+
+	State       RewriteJobState `yaml:"state"`
+	Error       string          `yaml:"error,omitempty"`
+	SubmittedAt time.Time       `yaml:"submitted_at"`
+	CompletedAt time.Time       `yaml:"completed_at,omitempty"`
+
+	// Blocks reports the blocks processed so far.
+	Blocks []RewriteJobBlockReport `yaml:"blocks,omitempty"`
+
+	// ProcessedSources are the compaction sources of the blocks processed so far. A block is processed
+	// once all its compaction sources have been processed, even if it has been compacted in the meanwhile.
+	ProcessedSources []ulid.ULID `yaml:"processed_sources,omitempty"`
+}
+
+// NewRewriteJob makes a new pending RewriteJob.
+func NewRewriteJob(id ulid.ULID, spec RewriteJobSpec, now time.Time) *RewriteJob {
+	return &RewriteJob{
+		ID:          id,
+		Spec:        spec,
+		State:       RewriteJobPending,
+		SubmittedAt: now,
+	}
+}
+
+// timeRange returns the [minT, maxT) time range, in milliseconds, of the samples to rewrite.
+func (j *RewriteJob) timeRange() (minT, maxT int64) {
+	end := j.Spec.End
+	if end.IsZero() || end.After(j.SubmittedAt) {
+		end = j.SubmittedAt
+	}
+
+	minT = int64(0)
+	if !j.Spec.Start.IsZero() {
+		minT = j.Spec.Start.UnixMilli()
+	}
+	return minT, end.UnixMilli()
+}
+
+// RewriteJobBlockReport reports the series affected by a rewrite job in a block.
+type RewriteJobBlockReport struct {
+	Block   ulid.ULID `yaml:"block"`
+	MinTime int64     `yaml:"min_time"`
+	MaxTime int64     `yaml:"max_time"`
+
+	// RewrittenBlock is the block replacing the original one. It's empty in dry-run mode, if no
+	// series were affected, or if all series were dropped.
+	RewrittenBlock string `yaml:"rewritten_block,omitempty"`
+
+	MatchedSeries   int `yaml:"matched_series"`
+	RelabeledSeries int `yaml:"relabeled_series"`
+	DroppedSeries   int `yaml:"dropped_series"`
+	FixedSeries     int `yaml:"fixed_series"`
+
+	// Examples lists some of the affected series, and how they're affected.
+	Examples []string `yaml:"examples,omitempty"`
+}
+
+func (r *RewriteJobBlockReport) changed() bool {
+	return r.RelabeledSeries > 0 || r.DroppedSeries > 0 || r.FixedSeries > 0
+}
+
+func (r *RewriteJobBlockReport) addExample(example string) {
+	if len(r.Examples) < maxRewriteJobBlockExamples {
+		r.Examples = append(r.Examples, example)
+	}
+}
+
+func rewriteJobPath(id ulid.ULID) string {
+	return path.Join(rewriteJobsPrefix, id.String()+rewriteJobExtension)
+}
+
+// writeRewriteJob uploads the rewrite job to the tenant's bucket.
+func writeRewriteJob(ctx context.Context, bkt objstore.Bucket, job *RewriteJob) error {
+	data, err := yaml.Marshal(job)
+	if err != nil {
+		return errors.Wrap(err, "encode rewrite job")
+	}
+	return errors.Wrap(bkt.Upload(ctx, rewriteJobPath(job.ID), bytes.NewReader(data)), "upload rewrite job")
+}
+
+// readRewriteJob reads the rewrite job from the tenant's bucket. It returns errRewriteJobNotFound
+// if the job doesn't exist.
+func readRewriteJob(ctx context.Context, bkt objstore.BucketReader, id ulid.ULID) (*RewriteJob, error) {
+	r, err := bkt.Get(ctx, rewriteJobPath(id))
+	if bkt.IsObjNotFoundErr(err) {
+		return nil, errRewriteJobNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "get rewrite job %s", id)
+	}
+	defer func() { _ = r.Close() }()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read rewrite job %s", id)
+	}
+
+	job := &RewriteJob{}
+	if err := yaml.Unmarshal(data, job); err != nil {
+		return nil, errors.Wrapf(err, "decode rewrite job %s", id)
+	}
+	return job, nil
+}
+
+// listRewriteJobs returns all the rewrite jobs in the tenant's bucket, sorted by ID.
+func listRewriteJobs(ctx context.Context, bkt objstore.BucketReader) ([]*RewriteJob, error) {
+	var ids []ulid.ULID
+	err := bkt.Iter(ctx, rewriteJobsPrefix, func(name string) error {
+		id, err := ulid.Parse(strings.TrimSuffix(path.Base(name), rewriteJobExtension))
+		if err != nil || !strings.HasSuffix(name, rewriteJobExtension) {
+			// Not a rewrite job.
+			return nil
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "list rewrite jobs")
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Compare(ids[j]) < 0 })
+
+	jobs := make([]*RewriteJob, 0, len(ids))
+	for _, id := range ids {
+		job, err := readRewriteJob(ctx, bkt, id)
+		if errors.Is(err, errRewriteJobNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
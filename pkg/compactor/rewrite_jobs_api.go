@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/tenant"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/util"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+// maxRewriteJobSpecSize is the max size of the body of requests submitting a rewrite job.
+const maxRewriteJobSpecSize = 1024 * 1024
+
+// SubmitRewriteJob handles requests to submit a block rewrite job. The job spec is read from the request
+// body, in YAML or JSON format, and the submitted job is returned in YAML format.
+func (c *MultitenantCompactor) SubmitRewriteJob(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := c.parseRewriteJobTenant(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRewriteJobSpecSize+1))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxRewriteJobSpecSize {
+		http.Error(w, fmt.Sprintf("request body larger than %d bytes", maxRewriteJobSpecSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	spec := RewriteJobSpec{}
+	dec := yaml.NewDecoder(bytes.NewReader(body))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		http.Error(w, fmt.Sprintf("malformed request body: %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := spec.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("invalid rewrite job: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	job := NewRewriteJob(ulid.MustNew(ulid.Now(), rand.Reader), spec, time.Now())
+	logger := log.With(util_log.WithContext(ctx, c.logger), "job", job.ID)
+
+	userBkt := bucket.NewUserBucketClient(tenantID, c.bucketClient, c.cfgProvider)
+	if err := writeRewriteJob(ctx, userBkt, job); err != nil {
+		level.Error(logger).Log("msg", "failed to store rewrite job", "err", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	level.Info(logger).Log("msg", "rewrite job submitted", "selectors", fmt.Sprintf("%q", spec.Selectors), "dry_run", spec.DryRun)
+	util.WriteYAMLResponse(w, job)
+}
+
+// ListRewriteJobs handles requests to list the tenant's block rewrite jobs, in YAML format.
+func (c *MultitenantCompactor) ListRewriteJobs(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := c.parseRewriteJobTenant(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userBkt := bucket.NewUserBucketClient(tenantID, c.bucketClient, c.cfgProvider)
+	jobs, err := listRewriteJobs(r.Context(), userBkt)
+	if err != nil {
+		level.Error(util_log.WithContext(r.Context(), c.logger)).Log("msg", "failed to list rewrite jobs", "err", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteYAMLResponse(w, jobs)
+}
+
+// GetRewriteJob handles requests to get a block rewrite job, including the report of the blocks processed
+// so far, in YAML format.
+func (c *MultitenantCompactor) GetRewriteJob(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := c.parseRewriteJobTenant(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jobID, err := ulid.Parse(mux.Vars(r)["job"])
+	if err != nil {
+		http.Error(w, "invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	userBkt := bucket.NewUserBucketClient(tenantID, c.bucketClient, c.cfgProvider)
+	job, err := readRewriteJob(r.Context(), userBkt, jobID)
+	if errors.Is(err, errRewriteJobNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(util_log.WithContext(r.Context(), c.logger)).Log("msg", "failed to read rewrite job", "job", jobID, "err", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteYAMLResponse(w, job)
+}
+
+// parseRewriteJobTenant returns the tenant from the request, and checks if the tenant has block rewrite enabled.
+func (c *MultitenantCompactor) parseRewriteJobTenant(r *http.Request) (string, error) {
+	tenantID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		return "", errors.New("invalid tenant ID")
+	}
+
+	if !c.cfgProvider.CompactorBlockRewriteEnabled(tenantID) {
+		return "", errors.New("block rewrite is disabled")
+	}
+
+	return tenantID, nil
+}
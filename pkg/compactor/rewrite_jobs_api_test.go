@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+)
+
+func TestMultitenantCompactor_RewriteJobsAPI(t *testing.T) {
+	const tenantID = "user-1"
+
+	bkt := objstore.NewInMemBucket()
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.blockRewriteEnabled[tenantID] = true
+	c := &MultitenantCompactor{
+		logger:       log.NewNopLogger(),
+		bucketClient: bkt,
+		cfgProvider:  cfgProvider,
+	}
+
+	newRequest := func(method, target, body, tenantID string) *http.Request {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if tenantID != "" {
+			r = r.WithContext(user.InjectOrgID(r.Context(), tenantID))
+		}
+		return r
+	}
+
+	t.Run("submit", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			tenantID         string
+			body             string
+			expectedStatus   int
+			expectedResponse string
+		}{
+			"missing tenant": {
+				body:             `{"selectors": ["{cluster=\"bad\"}"], "drop_series": true}`,
+				expectedStatus:   http.StatusBadRequest,
+				expectedResponse: "invalid tenant ID",
+			},
+			"block rewrite disabled": {
+				tenantID:         "user-2",
+				body:             `{"selectors": ["{cluster=\"bad\"}"], "drop_series": true}`,
+				expectedStatus:   http.StatusBadRequest,
+				expectedResponse: "block rewrite is disabled",
+			},
+			"malformed body": {
+				tenantID:         tenantID,
+				body:             `{"selectors": ["{cluster=\"bad\"}"], "unknown": true}`,
+				expectedStatus:   http.StatusBadRequest,
+				expectedResponse: "malformed request body",
+			},
+			"invalid job": {
+				tenantID:         tenantID,
+				body:             `{"selectors": ["{cluster=\"bad\"}"]}`,
+				expectedStatus:   http.StatusBadRequest,
+				expectedResponse: "invalid rewrite job: at least one of relabel configs, drop series and fix out-of-order chunks is required",
+			},
+			"valid JSON job": {
+				tenantID:       tenantID,
+				body:           `{"selectors": ["{cluster=\"bad\"}"], "drop_series": true, "dry_run": true}`,
+				expectedStatus: http.StatusOK,
+			},
+			"valid YAML job": {
+				tenantID: tenantID,
+				body: `
+selectors: ['{cluster="bad"}']
+relabel_configs:
+  - target_label: cluster
+    replacement: good
+`,
+				expectedStatus: http.StatusOK,
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				resp := httptest.NewRecorder()
+				c.SubmitRewriteJob(resp, newRequest(http.MethodPost, "/compactor/rewrite_jobs", tc.body, tc.tenantID))
+				require.Equal(t, tc.expectedStatus, resp.Code, resp.Body.String())
+
+				if tc.expectedStatus != http.StatusOK {
+					assert.Contains(t, resp.Body.String(), tc.expectedResponse)
+					return
+				}
+
+				job := RewriteJob{}
+				require.NoError(t, yaml.Unmarshal(resp.Body.Bytes(), &job))
+				assert.Equal(t, RewriteJobPending, job.State)
+				assert.Equal(t, []string{`{cluster="bad"}`}, job.Spec.Selectors)
+
+				// The job has been stored in the tenant's bucket.
+				stored, err := readRewriteJob(context.Background(), bucket.NewUserBucketClient(tenantID, bkt, nil), job.ID)
+				require.NoError(t, err)
+				assert.Equal(t, job.ID, stored.ID)
+				assert.Equal(t, job.Spec.DryRun, stored.Spec.DryRun)
+			})
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		c.ListRewriteJobs(resp, newRequest(http.MethodGet, "/compactor/rewrite_jobs", "", tenantID))
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+		var jobs []*RewriteJob
+		require.NoError(t, yaml.Unmarshal(resp.Body.Bytes(), &jobs))
+		require.Len(t, jobs, 2)
+
+		// Jobs are sorted by ID, and the submission order of the jobs above is random.
+		if !jobs[0].Spec.DryRun {
+			jobs[0], jobs[1] = jobs[1], jobs[0]
+		}
+		assert.True(t, jobs[0].Spec.DryRun)
+		assert.Len(t, jobs[1].Spec.RelabelConfigs, 1)
+
+		// Other tenants' jobs are not listed.
+		cfgProvider.blockRewriteEnabled["user-2"] = true
+		resp = httptest.NewRecorder()
+		c.ListRewriteJobs(resp, newRequest(http.MethodGet, "/compactor/rewrite_jobs", "", "user-2"))
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		require.NoError(t, yaml.Unmarshal(resp.Body.Bytes(), &jobs))
+		assert.Empty(t, jobs)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		job := NewRewriteJob(ulid.MustNew(ulid.Now(), nil), RewriteJobSpec{Selectors: []string{`{job="a"}`}, FixOutOfOrderChunks: true}, time.Now())
+		job.State = RewriteJobCompleted
+		job.Blocks = []RewriteJobBlockReport{{Block: ulid.MustNew(1, nil), MatchedSeries: 1, FixedSeries: 1}}
+		require.NoError(t, writeRewriteJob(context.Background(), bucket.NewUserBucketClient(tenantID, bkt, nil), job))
+
+		for name, tc := range map[string]struct {
+			jobID          string
+			expectedStatus int
+		}{
+			"existing job": {
+				jobID:          job.ID.String(),
+				expectedStatus: http.StatusOK,
+			},
+			"invalid job ID": {
+				jobID:          "invalid",
+				expectedStatus: http.StatusBadRequest,
+			},
+			"missing job": {
+				jobID:          ulid.MustNew(2, nil).String(),
+				expectedStatus: http.StatusNotFound,
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				r := newRequest(http.MethodGet, "/compactor/rewrite_jobs/"+tc.jobID, "", tenantID)
+				r = mux.SetURLVars(r, map[string]string{"job": tc.jobID})
+
+				resp := httptest.NewRecorder()
+				c.GetRewriteJob(resp, r)
+				require.Equal(t, tc.expectedStatus, resp.Code, resp.Body.String())
+
+				if tc.expectedStatus == http.StatusOK {
+					actual := RewriteJob{}
+					require.NoError(t, yaml.Unmarshal(resp.Body.Bytes(), &actual))
+					assert.Equal(t, job.ID, actual.ID)
+					assert.Equal(t, RewriteJobCompleted, actual.State)
+					assert.Equal(t, job.Blocks, actual.Blocks)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRewriteJobSpec_Validate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		spec          string
+		expectedError string
+	}{
+		"relabel": {
+			spec: `
+selectors: ['{cluster="bad"}']
+relabel_configs:
+  - target_label: cluster
+    replacement: good
+`,
+		},
+		"drop series": {
+			spec: `{selectors: ['{__name__="unwanted"}', '{job="unwanted"}'], drop_series: true, start: 2022-10-01T00:00:00Z, end: 2022-10-02T00:00:00Z}`,
+		},
+		"fix out-of-order chunks": {
+			spec: `{selectors: ['{__name__=~".+"}'], fix_out_of_order_chunks: true, dry_run: true}`,
+		},
+		"missing selectors": {
+			spec:          `{drop_series: true}`,
+			expectedError: "at least one series selector is required",
+		},
+		"invalid selector": {
+			spec:          `{selectors: ['{cluster=bad}'], drop_series: true}`,
+			expectedError: `invalid series selector "{cluster=bad}"`,
+		},
+		"missing rewrite": {
+			spec:          `{selectors: ['{cluster="bad"}']}`,
+			expectedError: "at least one of relabel configs, drop series and fix out-of-order chunks is required",
+		},
+		"relabel and drop series": {
+			spec: `
+selectors: ['{cluster="bad"}']
+drop_series: true
+relabel_configs:
+  - target_label: cluster
+    replacement: good
+`,
+			expectedError: "relabel configs can't be used together with drop series",
+		},
+		"end before start": {
+			spec:          `{selectors: ['{cluster="bad"}'], drop_series: true, start: 2022-10-02T00:00:00Z, end: 2022-10-01T00:00:00Z}`,
+			expectedError: "end must be after start",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			spec := RewriteJobSpec{}
+			require.NoError(t, yaml.Unmarshal([]byte(tc.spec), &spec))
+
+			err := spec.Validate()
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestRewriteJob_TimeRange(t *testing.T) {
+	submittedAt := time.Date(2022, 10, 10, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC)
+
+	for name, tc := range map[string]struct {
+		start, end   time.Time
+		expectedMinT int64
+		expectedMaxT int64
+	}{
+		"no time range": {
+			expectedMinT: 0,
+			expectedMaxT: submittedAt.UnixMilli(),
+		},
+		"time range": {
+			start:        start,
+			end:          end,
+			expectedMinT: start.UnixMilli(),
+			expectedMaxT: end.UnixMilli(),
+		},
+		"end after submission": {
+			start:        start,
+			end:          submittedAt.Add(time.Hour),
+			expectedMinT: start.UnixMilli(),
+			expectedMaxT: submittedAt.UnixMilli(),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			job := NewRewriteJob(ulid.MustNew(1, nil), RewriteJobSpec{Start: tc.start, End: tc.end}, submittedAt)
+			minT, maxT := job.timeRange()
+			assert.Equal(t, tc.expectedMinT, minT)
+			assert.Equal(t, tc.expectedMaxT, maxT)
+		})
+	}
+}
+
+func TestRewriteJobs_Storage(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+
+	spec := RewriteJobSpec{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+selectors: ['{cluster="bad"}']
+start: 2022-10-01T00:00:00Z
+relabel_configs:
+  - target_label: cluster
+    replacement: good
+`), &spec))
+
+	job1 := NewRewriteJob(ulid.MustNew(2, nil), spec, time.Date(2022, 10, 10, 0, 0, 0, 0, time.UTC))
+	job1.Blocks = []RewriteJobBlockReport{{Block: ulid.MustNew(10, nil), RewrittenBlock: ulid.MustNew(11, nil).String(), MatchedSeries: 1, RelabeledSeries: 1}}
+	job1.ProcessedSources = []ulid.ULID{ulid.MustNew(10, nil)}
+	job2 := NewRewriteJob(ulid.MustNew(1, nil), RewriteJobSpec{Selectors: []string{`{job="a"}`}, DropSeries: true}, time.Date(2022, 10, 11, 0, 0, 0, 0, time.UTC))
+
+	require.NoError(t, writeRewriteJob(ctx, bkt, job1))
+	require.NoError(t, writeRewriteJob(ctx, bkt, job2))
+
+	// Objects which are not rewrite jobs are ignored.
+	require.NoError(t, bkt.Upload(ctx, rewriteJobsPrefix+"/README.md", strings.NewReader("")))
+
+	actual, err := readRewriteJob(ctx, bkt, job1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, job1.ID, actual.ID)
+	assert.Equal(t, job1.Blocks, actual.Blocks)
+	assert.Equal(t, job1.ProcessedSources, actual.ProcessedSources)
+	assert.True(t, job1.SubmittedAt.Equal(actual.SubmittedAt))
+	assert.True(t, job1.Spec.Start.Equal(actual.Spec.Start))
+	assert.True(t, actual.Spec.End.IsZero())
+	require.Len(t, actual.Spec.RelabelConfigs, 1)
+	assert.Equal(t, "good", actual.Spec.RelabelConfigs[0].Replacement)
+
+	_, err = readRewriteJob(ctx, bkt, ulid.MustNew(3, nil))
+	assert.ErrorIs(t, err, errRewriteJobNotFound)
+
+	jobs, err := listRewriteJobs(ctx, bkt)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, job2.ID, jobs[0].ID)
+	assert.Equal(t, job1.ID, jobs[1].ID)
+}
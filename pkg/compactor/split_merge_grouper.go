@@ -84,6 +84,7 @@ func (g *SplitAndMergeGrouper) Groups(blocks map[ulid.ULID]*metadata.Meta) (res
 			g.shardCount,
 			job.shardingKey(),
 		)
+		compactionJob.shardID = job.shardID
 
 		for _, m := range job.blocks {
 			if err := compactionJob.AppendMeta(m); err != nil {
@@ -187,8 +187,11 @@ func (d *Distributor) queryIngesterStream(ctx context.Context, replicationSet ri
 		// Note we can't signal goroutines to stop by closing 'results', because it has multiple concurrent senders.
 		stop        = make(chan struct{}) // Signal all background goroutines to stop.
 		doneReading = make(chan struct{}) // Signal that the reader has stopped.
+		startTime   = time.Now()
 	)
 
+	defer func() { reqStats.AddIngesterFetchTime(time.Since(startTime)) }()
+
 	hashToChunkseries := map[string]ingester_client.TimeSeriesChunk{}
 	hashToTimeSeries := map[string]mimirpb.TimeSeries{}
 
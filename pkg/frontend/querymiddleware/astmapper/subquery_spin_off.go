@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package astmapper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+const (
+	// SubqueryMetricName is a reserved metric name denoting a special metric which contains a spun off subquery.
+	SubqueryMetricName = "__subquery_spinoff__"
+
+	// SubqueryQueryLabelName is a reserved label name containing the inner expression of a spun off subquery.
+	SubqueryQueryLabelName = "__query__"
+
+	// SubqueryStepLabelName is a reserved label name containing the resolution step of a spun off subquery.
+	SubqueryStepLabelName = "__step__"
+
+	// minSpinOffSubqueryRange is the minimum range a subquery must have to be spun off. Subqueries
+	// with a smaller range are cheap enough to be run by queriers as part of the outer query.
+	minSpinOffSubqueryRange = time.Hour
+)
+
+type subquerySpinOffMapper struct {
+	ctx context.Context
+
+	// noStepSubqueryIntervalFn returns the resolution step used by subqueries which don't specify it.
+	noStepSubqueryIntervalFn func(rangeMillis int64) int64
+	stats                    *SubquerySpinOffMapperStats
+}
+
+// NewSubquerySpinOffMapper creates a new mapper which replaces each subquery with a matrix selector, whose
+// samples are the results of the subquery inner expression run as a range query. The rest of the query is
+// embedded in queries which are sent downstream like for query sharding.
+func NewSubquerySpinOffMapper(ctx context.Context, noStepSubqueryIntervalFn func(rangeMillis int64) int64, stats *SubquerySpinOffMapperStats) ASTMapper {
+	return NewASTExprMapper(&subquerySpinOffMapper{
+		ctx:                      ctx,
+		noStepSubqueryIntervalFn: noStepSubqueryIntervalFn,
+		stats:                    stats,
+	})
+}
+
+// MapExpr implements ExprMapper.
+func (m *subquerySpinOffMapper) MapExpr(expr parser.Expr) (mapped parser.Expr, finished bool, err error) {
+	if err := m.ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasSpinOffSubqueries, err := anyNode(expr, m.canSpinOff)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Subtrees without subqueries to spin off are sent downstream as they are.
+	if !hasSpinOffSubqueries {
+		hasVectorSelector, err := anyNode(expr, isVectorSelector)
+		if err != nil {
+			return nil, false, err
+		}
+
+		// Only instant vectors can be embedded, so keep traversing any other type of expression.
+		if hasVectorSelector && expr.Type() == parser.ValueTypeVector {
+			expr, err := vectorSquasher(expr)
+			return expr, true, err
+		}
+		if _, ok := expr.(*parser.MatrixSelector); ok {
+			return nil, false, fmt.Errorf("unable to embed the range vector selector %s", expr)
+		}
+
+		return expr, false, nil
+	}
+
+	subquery, ok := expr.(*parser.SubqueryExpr)
+	if !ok {
+		return expr, false, nil
+	}
+
+	// The subquery itself may not be spun off, while a nested one can.
+	if spinOff, _ := m.canSpinOff(subquery); !spinOff {
+		return expr, false, nil
+	}
+
+	mapped, err = m.spinOff(subquery)
+	if err != nil {
+		return nil, false, err
+	}
+
+	m.stats.AddSpunOffSubqueries(1)
+	return mapped, true, nil
+}
+
+// canSpinOff returns whether the input node is a subquery which can be spun off.
+func (m *subquerySpinOffMapper) canSpinOff(node parser.Node) (bool, error) {
+	subquery, ok := node.(*parser.SubqueryExpr)
+	if !ok {
+		return false, nil
+	}
+
+	if subquery.Range < minSpinOffSubqueryRange || m.subqueryStep(subquery) <= 0 {
+		return false, nil
+	}
+
+	// The start() and end() preprocessors would be resolved against the time range of the
+	// spun off range query, instead of the one of the outer query.
+	usesStartOrEnd, err := anyNode(subquery.Expr, isStartOrEndModifier)
+	if err != nil {
+		return false, err
+	}
+	return !usesStartOrEnd, nil
+}
+
+// subqueryStep returns the resolution step of the subquery, or 0 if it can't be determined.
+func (m *subquerySpinOffMapper) subqueryStep(subquery *parser.SubqueryExpr) time.Duration {
+	if subquery.Step > 0 {
+		return subquery.Step
+	}
+	if m.noStepSubqueryIntervalFn == nil {
+		return 0
+	}
+	return time.Duration(m.noStepSubqueryIntervalFn(subquery.Range.Milliseconds())) * time.Millisecond
+}
+
+// spinOff returns a matrix selector, with the same range, offset and @ modifier of the input subquery,
+// whose matchers contain the subquery inner expression and resolution step.
+func (m *subquerySpinOffMapper) spinOff(subquery *parser.SubqueryExpr) (parser.Expr, error) {
+	queryMatcher, err := labels.NewMatcher(labels.MatchEqual, SubqueryQueryLabelName, subquery.Expr.String())
+	if err != nil {
+		return nil, err
+	}
+	stepMatcher, err := labels.NewMatcher(labels.MatchEqual, SubqueryStepLabelName, m.subqueryStep(subquery).String())
+	if err != nil {
+		return nil, err
+	}
+
+	return &parser.MatrixSelector{
+		VectorSelector: &parser.VectorSelector{
+			Name:           SubqueryMetricName,
+			LabelMatchers:  []*labels.Matcher{queryMatcher, stepMatcher},
+			OriginalOffset: subquery.OriginalOffset,
+			Timestamp:      subquery.Timestamp,
+			StartOrEnd:     subquery.StartOrEnd,
+		},
+		Range: subquery.Range,
+	}, nil
+}
+
+// isStartOrEndModifier returns whether the node has a start() or end() @ modifier.
+func isStartOrEndModifier(node parser.Node) (bool, error) {
+	switch n := node.(type) {
+	case *parser.VectorSelector:
+		return n.StartOrEnd != 0, nil
+	case *parser.SubqueryExpr:
+		return n.StartOrEnd != 0, nil
+	}
+	return false, nil
+}
+
+type SubquerySpinOffMapperStats struct {
+	spunOffSubqueries int
+}
+
+func NewSubquerySpinOffMapperStats() *SubquerySpinOffMapperStats {
+	return &SubquerySpinOffMapperStats{}
+}
+
+// AddSpunOffSubqueries add num spun off subqueries to the counter.
+func (s *SubquerySpinOffMapperStats) AddSpunOffSubqueries(num int) {
+	s.spunOffSubqueries += num
+}
+
+// GetSpunOffSubqueries returns the number of spun off subqueries.
+func (s *SubquerySpinOffMapperStats) GetSpunOffSubqueries() int {
+	return s.spunOffSubqueries
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package astmapper
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubquerySpinOffMapper(t *testing.T) {
+	noStepSubqueryIntervalFn := func(int64) int64 {
+		return time.Minute.Milliseconds()
+	}
+
+	for testName, tc := range map[string]struct {
+		in                        string
+		out                       string
+		expectedSpunOffSubqueries int
+	}{
+		"should spin off a subquery": {
+			in:                        `max_over_time(rate(metric[5m])[1d:1m])`,
+			out:                       `max_over_time(` + spunOffSubquery(`rate(metric[5m])`, "1m0s", "1d") + `)`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"should spin off a subquery with offset and @ modifier": {
+			in:                        `max_over_time(rate(metric[5m])[1d:1m] offset 1h @ 1000)`,
+			out:                       `max_over_time(` + spunOffSubquery(`rate(metric[5m])`, "1m0s", "1d") + ` offset 1h @ 1000.000)`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"should spin off a subquery without step using the default one": {
+			in:                        `avg_over_time(sum(metric)[2h:])`,
+			out:                       `avg_over_time(` + spunOffSubquery(`sum(metric)`, "1m0s", "2h") + `)`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"should spin off a top-level subquery": {
+			in:                        `rate(metric[5m])[1d:1m]`,
+			out:                       spunOffSubquery(`rate(metric[5m])`, "1m0s", "1d"),
+			expectedSpunOffSubqueries: 1,
+		},
+		"should spin off the outermost subquery only": {
+			in:                        `max_over_time(deriv(rate(metric[5m])[1h:1m])[1d:5m])`,
+			out:                       `max_over_time(` + spunOffSubquery(`deriv(rate(metric[5m])[1h:1m])`, "5m0s", "1d") + `)`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"should spin off a subquery nested in a subquery which can't be spun off": {
+			in:                        `deriv(max_over_time(metric[1d:1m])[5m:1m])`,
+			out:                       `deriv(max_over_time(` + spunOffSubquery(`metric`, "1m0s", "1d") + `)[5m:1m])`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"should embed the parts of the query without subqueries to spin off": {
+			in:                        `sum(max_over_time(rate(metric[5m])[1d:1m])) / sum(rate(other[5m]))`,
+			out:                       `sum(max_over_time(` + spunOffSubquery(`rate(metric[5m])`, "1m0s", "1d") + `)) / ` + embeddedQuery(`sum(rate(other[5m]))`),
+			expectedSpunOffSubqueries: 1,
+		},
+		"should embed the instant vectors of non vector expressions": {
+			in:                        `max_over_time(metric[1d:1m]) * scalar(other)`,
+			out:                       `max_over_time(` + spunOffSubquery(`metric`, "1m0s", "1d") + `) * scalar(` + embeddedQuery(`other`) + `)`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"should spin off multiple subqueries": {
+			in:                        `max_over_time(metric[1d:1m]) - min_over_time(metric[1d:1m])`,
+			out:                       `max_over_time(` + spunOffSubquery(`metric`, "1m0s", "1d") + `) - min_over_time(` + spunOffSubquery(`metric`, "1m0s", "1d") + `)`,
+			expectedSpunOffSubqueries: 2,
+		},
+		"should not spin off subqueries with a small range": {
+			in:  `max_over_time(rate(metric[5m])[30m:1m])`,
+			out: embeddedQuery(`max_over_time(rate(metric[5m])[30m:1m])`),
+		},
+		"should not spin off subqueries using start() or end()": {
+			in:  `max_over_time(metric @ end()[1d:1m])`,
+			out: embeddedQuery(`max_over_time(metric @ end()[1d:1m])`),
+		},
+		"should not spin off queries without subqueries": {
+			in:  `sum(rate(metric[5m]))`,
+			out: embeddedQuery(`sum(rate(metric[5m]))`),
+		},
+	} {
+		t.Run(testName, func(t *testing.T) {
+			stats := NewSubquerySpinOffMapperStats()
+			mapper := NewSubquerySpinOffMapper(context.Background(), noStepSubqueryIntervalFn, stats)
+
+			expr, err := parser.ParseExpr(tc.in)
+			require.NoError(t, err)
+			out, err := parser.ParseExpr(tc.out)
+			require.NoError(t, err)
+
+			mapped, err := mapper.Map(expr)
+			require.NoError(t, err)
+			assert.Equal(t, out.String(), mapped.String())
+			assert.Equal(t, tc.expectedSpunOffSubqueries, stats.GetSpunOffSubqueries())
+		})
+	}
+}
+
+func TestSubquerySpinOffMapper_ShouldFailOnRangeVectorsWhichCannotBeEmbedded(t *testing.T) {
+	stats := NewSubquerySpinOffMapperStats()
+	mapper := NewSubquerySpinOffMapper(context.Background(), nil, stats)
+
+	expr, err := parser.ParseExpr(`quantile_over_time(scalar(max_over_time(metric[1d:1m])), metric[1h])`)
+	require.NoError(t, err)
+
+	_, err = mapper.Map(expr)
+	require.Error(t, err)
+}
+
+func spunOffSubquery(query, step, rangeInterval string) string {
+	return fmt.Sprintf(`%s{%s=%q, %s=%q}[%s]`, SubqueryMetricName, SubqueryQueryLabelName, query, SubqueryStepLabelName, step, rangeInterval)
+}
+
+func embeddedQuery(query string) string {
+	encoded, err := JSONCodec.Encode([]string{query})
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf(`%s{%s=%q}`, EmbeddedQueriesMetricName, EmbeddedQueriesLabelName, encoded)
+}
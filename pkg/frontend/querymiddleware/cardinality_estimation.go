@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const (
+	// cardinalityEstimateBucketSize is the size of the time buckets used to group queries with a similar
+	// time range, which are expected to fetch a similar number of series.
+	cardinalityEstimateBucketSize = 2 * time.Hour
+
+	// cardinalityEstimateTTL is the TTL of the cardinality estimates stored in the results cache.
+	cardinalityEstimateTTL = 7 * 24 * time.Hour
+
+	// cardinalityEstimateMaxDeviation is the max relative deviation between the estimated and the actual
+	// number of series fetched by a query, above which the cached estimate is updated.
+	cardinalityEstimateMaxDeviation = 0.1
+
+	cardinalityEstimationResultMissing  = "missing"
+	cardinalityEstimationResultAccurate = "accurate"
+	cardinalityEstimationResultWrong    = "wrong"
+)
+
+// cardinalityEstimation is a Middleware that attaches to the request the number of series the query
+// is estimated to fetch, so that the query sharding middleware can choose the number of shards based
+// on the query cardinality. The estimate is the number of series fetched by previous executions of
+// the same query over a similar time range, stored in the results cache.
+type cardinalityEstimation struct {
+	cache  cache.Cache
+	limits Limits
+	next   Handler
+	logger log.Logger
+
+	estimationsTotal *prometheus.CounterVec
+}
+
+func newCardinalityEstimationMiddleware(cache cache.Cache, limits Limits, logger log.Logger, registerer prometheus.Registerer) Middleware {
+	estimationsTotal := promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_frontend_query_cardinality_estimations_total",
+		Help: "Total number of queries whose cardinality has been estimated by the query-frontend, by whether the estimate was missing, accurate or wrong.",
+	}, []string{"result"})
+
+	// Initialize known label values.
+	for _, result := range []string{cardinalityEstimationResultMissing, cardinalityEstimationResultAccurate, cardinalityEstimationResultWrong} {
+		estimationsTotal.WithLabelValues(result)
+	}
+
+	return MiddlewareFunc(func(next Handler) Handler {
+		return &cardinalityEstimation{
+			cache:            cache,
+			limits:           limits,
+			next:             next,
+			logger:           logger,
+			estimationsTotal: estimationsTotal,
+		}
+	})
+}
+
+func (c *cardinalityEstimation) Do(ctx context.Context, req Request) (Response, error) {
+	spanLog, ctx := spanlogger.NewWithLogger(ctx, c.logger, "cardinalityEstimation.Do")
+	defer spanLog.Span.Finish()
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	// Skip the estimation if the query won't be sharded or the estimate can't be used to choose the number of shards.
+	if req.GetOptions().ShardingDisabled || req.GetOptions().TotalShards > 0 || req.GetOptions().CacheDisabled {
+		return c.next.Do(ctx, req)
+	}
+	if validation.SmallestPositiveNonZeroUint64PerTenant(tenantIDs, c.limits.QueryShardingTargetSeriesPerShard) == 0 {
+		return c.next.Do(ctx, req)
+	}
+
+	key := generateCardinalityEstimationCacheKey(tenant.JoinTenantIDs(tenantIDs), req, cardinalityEstimateBucketSize)
+	estimate, estimateAvailable := c.lookupCardinalityForKey(ctx, key)
+	if estimateAvailable {
+		req = req.WithHints(hintsWithEstimatedSeriesCount(req.GetHints(), estimate))
+		level.Debug(spanLog).Log("msg", "estimated query cardinality", "estimated_series_count", estimate)
+	}
+
+	// Track the stats of the downstream query on their own, in order to compare the actual
+	// number of fetched series with the estimate. They're merged back to the query stats.
+	queryStats := stats.FromContext(ctx)
+	downstreamStats, downstreamCtx := stats.ContextWithEmptyStats(ctx)
+	res, err := c.next.Do(downstreamCtx, req)
+	queryStats.Merge(downstreamStats)
+	if err != nil {
+		return nil, err
+	}
+
+	// The series fetched by the query are unknown if any part of it was fetched from the results cache.
+	if downstreamStats.LoadResultsCacheHits() > 0 {
+		return res, nil
+	}
+
+	actual := downstreamStats.LoadFetchedSeries()
+	switch {
+	case !estimateAvailable:
+		c.estimationsTotal.WithLabelValues(cardinalityEstimationResultMissing).Inc()
+	case isCardinalityEstimateWithinDeviation(estimate, actual):
+		c.estimationsTotal.WithLabelValues(cardinalityEstimationResultAccurate).Inc()
+		return res, nil
+	default:
+		c.estimationsTotal.WithLabelValues(cardinalityEstimationResultWrong).Inc()
+	}
+
+	if actual > 0 {
+		c.storeCardinalityForKey(ctx, key, actual)
+		level.Debug(spanLog).Log("msg", "updated query cardinality estimate", "estimated_series_count", estimate, "actual_series_count", actual)
+	}
+
+	return res, nil
+}
+
+// lookupCardinalityForKey fetches the cardinality estimate for the given key from the cache.
+// Returns false if the estimate is not available.
+func (c *cardinalityEstimation) lookupCardinalityForKey(ctx context.Context, key string) (uint64, bool) {
+	hashedKey := cacheHashKey(key)
+	res := c.cache.Fetch(ctx, []string{hashedKey})
+	data, ok := res[hashedKey]
+	if !ok {
+		return 0, false
+	}
+
+	var statistics QueryStatistics
+	if err := proto.Unmarshal(data, &statistics); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to unmarshal cardinality estimate", "err", err)
+		return 0, false
+	}
+	return statistics.EstimatedSeriesCount, statistics.EstimatedSeriesCount > 0
+}
+
+// storeCardinalityForKey stores the cardinality estimate for the given key in the cache.
+func (c *cardinalityEstimation) storeCardinalityForKey(ctx context.Context, key string, count uint64) {
+	data, err := proto.Marshal(&QueryStatistics{EstimatedSeriesCount: count})
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "failed to marshal cardinality estimate", "err", err)
+		return
+	}
+
+	c.cache.Store(ctx, map[string][]byte{cacheHashKey(key): data}, cardinalityEstimateTTL)
+}
+
+// isCardinalityEstimateWithinDeviation returns whether the actual number of series fetched by a query
+// doesn't deviate from the estimate by more than cardinalityEstimateMaxDeviation.
+func isCardinalityEstimateWithinDeviation(estimate, actual uint64) bool {
+	if estimate == 0 {
+		return actual == 0
+	}
+	return math.Abs(float64(actual)-float64(estimate))/float64(estimate) <= cardinalityEstimateMaxDeviation
+}
+
+// hintsWithEstimatedSeriesCount returns a copy of the input hints with the estimated series count set.
+// The input hints may be shared by multiple requests, so they're never modified in place.
+func hintsWithEstimatedSeriesCount(hints *Hints, estimate uint64) *Hints {
+	updated := &Hints{}
+	if hints != nil {
+		*updated = *hints
+	}
+	updated.EstimatedSeriesCount = estimate
+	return updated
+}
+
+// generateCardinalityEstimationCacheKey generates the cache key used to store the cardinality estimate
+// of the request. Queries with the same start time bucket and range length bucket share the same key.
+func generateCardinalityEstimationCacheKey(userID string, req Request, bucketSize time.Duration) string {
+	bucketMillis := bucketSize.Milliseconds()
+	startBucket := req.GetStart() / bucketMillis
+	rangeBucket := (req.GetEnd() - req.GetStart()) / bucketMillis
+
+	return fmt.Sprintf("QS:%s:%s:%d:%d", userID, req.GetQuery(), startBucket, rangeBucket)
+}
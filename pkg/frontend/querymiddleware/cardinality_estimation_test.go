@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/querier/stats"
+)
+
+func TestCardinalityEstimation_Do(t *testing.T) {
+	const (
+		tenantID = "test"
+		query    = "sum(metric)"
+	)
+
+	req := &PrometheusRangeQueryRequest{
+		Path:  "/query_range",
+		Start: time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC).UnixMilli(),
+		End:   time.Date(2022, 10, 1, 6, 0, 0, 0, time.UTC).UnixMilli(),
+		Step:  time.Minute.Milliseconds(),
+		Query: query,
+		Hints: &Hints{TotalQueries: 3},
+	}
+	key := generateCardinalityEstimationCacheKey(tenantID, req, cardinalityEstimateBucketSize)
+
+	tests := map[string]struct {
+		targetSeriesPerShard     uint64
+		cachedEstimate           uint64
+		fetchedSeries            uint64
+		resultsCacheHits         uint32
+		expectedHintedEstimate   uint64
+		expectedCachedEstimate   uint64
+		expectedEstimateIsCached bool
+	}{
+		"should store the estimate if missing": {
+			targetSeriesPerShard:     100,
+			fetchedSeries:            1000,
+			expectedCachedEstimate:   1000,
+			expectedEstimateIsCached: true,
+		},
+		"should hint the cached estimate and not update it if accurate": {
+			targetSeriesPerShard:     100,
+			cachedEstimate:           1000,
+			fetchedSeries:            1050,
+			expectedHintedEstimate:   1000,
+			expectedCachedEstimate:   1000,
+			expectedEstimateIsCached: true,
+		},
+		"should hint the cached estimate and update it if wrong": {
+			targetSeriesPerShard:     100,
+			cachedEstimate:           1000,
+			fetchedSeries:            2000,
+			expectedHintedEstimate:   1000,
+			expectedCachedEstimate:   2000,
+			expectedEstimateIsCached: true,
+		},
+		"should not store the estimate if the query was partially fetched from the results cache": {
+			targetSeriesPerShard: 100,
+			fetchedSeries:        1000,
+			resultsCacheHits:     1,
+		},
+		"should not store the estimate if the query fetched no series": {
+			targetSeriesPerShard: 100,
+		},
+		"should neither hint nor store the estimate if the target series per shard is disabled": {
+			targetSeriesPerShard:     0,
+			cachedEstimate:           1000,
+			fetchedSeries:            2000,
+			expectedCachedEstimate:   1000,
+			expectedEstimateIsCached: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			c := cache.NewMockCache()
+			limits := mockLimits{targetSeriesPerShard: testData.targetSeriesPerShard}
+			mw := newCardinalityEstimationMiddleware(c, limits, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+			estimator := mw.Wrap(nil).(*cardinalityEstimation)
+			if testData.cachedEstimate > 0 {
+				estimator.storeCardinalityForKey(context.Background(), key, testData.cachedEstimate)
+			}
+
+			downstream := HandlerFunc(func(ctx context.Context, r Request) (Response, error) {
+				assert.Equal(t, testData.expectedHintedEstimate, r.GetHints().GetEstimatedSeriesCount())
+				assert.Equal(t, int32(3), r.GetHints().GetTotalQueries())
+
+				queryStats := stats.FromContext(ctx)
+				queryStats.AddFetchedSeries(testData.fetchedSeries)
+				queryStats.AddResultsCacheHits(testData.resultsCacheHits)
+				return &PrometheusResponse{Status: statusSuccess}, nil
+			})
+
+			queryStats, ctx := stats.ContextWithEmptyStats(user.InjectOrgID(context.Background(), tenantID))
+			_, err := mw.Wrap(downstream).Do(ctx, req)
+			require.NoError(t, err)
+
+			// The stats of the downstream query should be merged into the query stats.
+			assert.Equal(t, testData.fetchedSeries, queryStats.LoadFetchedSeries())
+
+			// The hints of the input request should not be modified.
+			assert.Equal(t, uint64(0), req.GetHints().GetEstimatedSeriesCount())
+
+			estimate, ok := estimator.lookupCardinalityForKey(context.Background(), key)
+			assert.Equal(t, testData.expectedEstimateIsCached, ok)
+			assert.Equal(t, testData.expectedCachedEstimate, estimate)
+		})
+	}
+}
+
+func TestGenerateCardinalityEstimationCacheKey(t *testing.T) {
+	newRequest := func(start, end time.Time) Request {
+		return &PrometheusRangeQueryRequest{
+			Start: start.UnixMilli(),
+			End:   end.UnixMilli(),
+			Query: "up",
+		}
+	}
+
+	start := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	key := generateCardinalityEstimationCacheKey("test", newRequest(start, start.Add(6*time.Hour)), 2*time.Hour)
+
+	// Requests with a similar time range should share the same key.
+	assert.Equal(t, key, generateCardinalityEstimationCacheKey("test", newRequest(start.Add(time.Minute), start.Add(6*time.Hour+time.Minute)), 2*time.Hour))
+
+	// Requests with a different start bucket, range length, tenant or query should have a different key.
+	assert.NotEqual(t, key, generateCardinalityEstimationCacheKey("test", newRequest(start.Add(2*time.Hour), start.Add(8*time.Hour)), 2*time.Hour))
+	assert.NotEqual(t, key, generateCardinalityEstimationCacheKey("test", newRequest(start, start.Add(12*time.Hour)), 2*time.Hour))
+	assert.NotEqual(t, key, generateCardinalityEstimationCacheKey("other", newRequest(start, start.Add(6*time.Hour)), 2*time.Hour))
+}
+
+func TestIsCardinalityEstimateWithinDeviation(t *testing.T) {
+	for _, tc := range []struct {
+		estimate, actual uint64
+		expected         bool
+	}{
+		{estimate: 0, actual: 0, expected: true},
+		{estimate: 0, actual: 1, expected: false},
+		{estimate: 100, actual: 100, expected: true},
+		{estimate: 100, actual: 90, expected: true},
+		{estimate: 100, actual: 110, expected: true},
+		{estimate: 100, actual: 89, expected: false},
+		{estimate: 100, actual: 111, expected: false},
+	} {
+		assert.Equal(t, tc.expected, isCardinalityEstimateWithinDeviation(tc.estimate, tc.actual), "estimate: %d actual: %d", tc.estimate, tc.actual)
+	}
+}
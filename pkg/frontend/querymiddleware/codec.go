@@ -8,6 +8,7 @@ package querymiddleware
 import (
 	"bytes"
 	"context"
+	stdjson "encoding/json"
 	"fmt"
 	"io"
 	"math"
@@ -21,6 +22,7 @@ import (
 	"github.com/go-kit/log"
 	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/status"
+	"github.com/golang/snappy"
 	"github.com/opentracing/opentracing-go"
 	otlog "github.com/opentracing/opentracing-go/log"
 	"github.com/prometheus/common/model"
@@ -38,6 +40,7 @@ var (
 	errStepTooSmall   = apierror.New(apierror.TypeBadData, "exceeded maximum resolution of 11,000 points per timeseries. Try decreasing the query resolution (?step=XX)")
 
 	// PrometheusCodec is a codec to encode and decode Prometheus query range requests and responses.
+	// Query results are retrieved from queriers in JSON format.
 	PrometheusCodec Codec = prometheusCodec{}
 )
 
@@ -111,7 +114,21 @@ type Response interface {
 	GetHeaders() []*PrometheusResponseHeader
 }
 
-type prometheusCodec struct{}
+type prometheusCodec struct {
+	// The format and compression requested to queriers for the query result responses.
+	// The zero values mean JSON format without compression.
+	preferredFormat      string
+	preferredCompression string
+}
+
+// NewPrometheusCodec returns a codec to encode and decode Prometheus query range requests and responses,
+// which retrieves query results from queriers in the input format and compression.
+func NewPrometheusCodec(queryResultResponseFormat, queryResultResponseCompression string) Codec {
+	return prometheusCodec{
+		preferredFormat:      queryResultResponseFormat,
+		preferredCompression: queryResultResponseCompression,
+	}
+}
 
 func (prometheusCodec) MergeResponse(responses ...Response) (Response, error) {
 	if len(responses) == 0 {
@@ -241,7 +258,7 @@ func decodeOptions(r *http.Request, opts *Options) {
 	}
 }
 
-func (prometheusCodec) EncodeRequest(ctx context.Context, r Request) (*http.Request, error) {
+func (c prometheusCodec) EncodeRequest(ctx context.Context, r Request) (*http.Request, error) {
 	var u *url.URL
 	switch r := r.(type) {
 	case *PrometheusRangeQueryRequest:
@@ -274,6 +291,17 @@ func (prometheusCodec) EncodeRequest(ctx context.Context, r Request) (*http.Requ
 		Header:     http.Header{},
 	}
 
+	// Queriers not supporting the preferred format fall back to JSON.
+	switch c.preferredFormat {
+	case formatProtobuf:
+		req.Header.Set("Accept", protobufMimeType+", "+jsonMimeType)
+	default:
+		req.Header.Set("Accept", jsonMimeType)
+	}
+	if c.preferredCompression != compressionNone {
+		req.Header.Set("Accept-Encoding", c.preferredCompression)
+	}
+
 	return req.WithContext(ctx), nil
 }
 
@@ -301,7 +329,18 @@ func (prometheusCodec) DecodeResponse(ctx context.Context, r *http.Response, _ R
 	}
 	log.LogFields(otlog.Int("bytes", len(buf)))
 
-	if err := json.Unmarshal(buf, &resp); err != nil {
+	if r.Header.Get("Content-Encoding") == compressionSnappy {
+		if buf, err = snappy.Decode(nil, buf); err != nil {
+			return nil, apierror.Newf(apierror.TypeInternal, "error decompressing response: %v", err)
+		}
+	}
+
+	if isMimeType(r.Header.Get("Content-Type"), protobufMimeType) {
+		err = resp.Unmarshal(buf)
+	} else {
+		err = json.Unmarshal(buf, &resp)
+	}
+	if err != nil {
 		return nil, apierror.Newf(apierror.TypeInternal, "error decoding response: %v", err)
 	}
 
@@ -326,7 +365,21 @@ func (prometheusCodec) EncodeResponse(ctx context.Context, res Response) (*http.
 		sp.LogFields(otlog.Int("series", len(a.Data.Result)))
 	}
 
-	b, err := json.Marshal(a)
+	var body interface{} = a
+	if queryStats := renderedStatsFromContext(ctx); queryStats != nil && a.Data != nil {
+		data, err := a.Data.marshalJSONWithStats(newResponseStats(queryStats))
+		if err != nil {
+			return nil, apierror.Newf(apierror.TypeInternal, "error encoding response: %v", err)
+		}
+
+		// The data field shadows the one of the embedded response.
+		body = struct {
+			*PrometheusResponse
+			Data stdjson.RawMessage `json:"data"`
+		}{a, data}
+	}
+
+	b, err := json.Marshal(body)
 	if err != nil {
 		return nil, apierror.Newf(apierror.TypeInternal, "error encoding response: %v", err)
 	}
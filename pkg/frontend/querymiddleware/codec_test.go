@@ -28,6 +28,7 @@ import (
 
 	apierror "github.com/grafana/mimir/pkg/api/error"
 	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/querier/stats"
 )
 
 var (
@@ -333,6 +334,68 @@ func TestResponseRoundtrip(t *testing.T) {
 	}
 }
 
+func TestEncodeResponseWithStats(t *testing.T) {
+	queryStats := &stats.Stats{}
+	queryStats.AddWallTime(2 * time.Second)
+	queryStats.AddQueueTime(100 * time.Millisecond)
+	queryStats.AddEvaluationTime(time.Second)
+	queryStats.AddIngesterFetchTime(200 * time.Millisecond)
+	queryStats.AddStoreGatewayFetchTime(300 * time.Millisecond)
+	queryStats.AddSamplesProcessed(1000)
+	queryStats.UpdatePeakSamples(100)
+	queryStats.AddResultsCacheLookups(4)
+	queryStats.AddResultsCacheHits(1)
+	queryStats.AddShardedQueries(32)
+	queryStats.UpdateQueryShards(16)
+
+	res := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: matrix,
+			Result: []SampleStream{{
+				Labels:  []mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}},
+				Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: 1}},
+			}},
+		},
+	}
+
+	ctx := context.WithValue(context.Background(), renderStatsContextKey, queryStats)
+	encoded, err := PrometheusCodec.EncodeResponse(ctx, res)
+	require.NoError(t, err)
+
+	encodedJSON, err := bodyBuffer(encoded)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"status": "success",
+		"data": {
+			"resultType": "matrix",
+			"result": [{"metric": {"foo": "bar"}, "values": [[1, "1"]]}],
+			"stats": {
+				"timings": {
+					"evalTotalTime": 1,
+					"execQueueTime": 0.1,
+					"querierWallTime": 2,
+					"ingesterFetchTime": 0.2,
+					"storeGatewayFetchTime": 0.3
+				},
+				"samples": {
+					"totalQueryableSamples": 1000,
+					"peakSamples": 100
+				},
+				"resultsCache": {
+					"lookups": 4,
+					"hits": 1,
+					"hitRatio": 0.25
+				},
+				"sharding": {
+					"shardedQueries": 32,
+					"shards": 16
+				}
+			}
+		}
+	}`, string(encodedJSON))
+}
+
 func TestMergeAPIResponses(t *testing.T) {
 	for _, tc := range []struct {
 		name     string
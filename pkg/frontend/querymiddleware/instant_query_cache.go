@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/dskit/tenant"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+type instantQueryCacheMiddlewareMetrics struct {
+	queryResultCacheAttemptedCount prometheus.Counter
+	queryResultCacheHitsCount      prometheus.Counter
+	queryResultCacheSkippedCount   *prometheus.CounterVec
+}
+
+func newInstantQueryCacheMiddlewareMetrics(reg prometheus.Registerer) *instantQueryCacheMiddlewareMetrics {
+	m := &instantQueryCacheMiddlewareMetrics{
+		queryResultCacheAttemptedCount: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_instant_query_result_cache_attempted_total",
+			Help: "Total number of instant queries that were attempted to be fetched from cache.",
+		}),
+		queryResultCacheHitsCount: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_instant_query_result_cache_hits_total",
+			Help: "Total number of instant queries that were fetched from cache.",
+		}),
+		queryResultCacheSkippedCount: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_frontend_instant_query_result_cache_skipped_total",
+			Help: "Total number of times an instant query was not cacheable because of a reason. This metric is tracked for each partial query when instant query splitting is enabled.",
+		}, []string{"reason"}),
+	}
+
+	// Initialize known label values.
+	for _, reason := range []string{notCachableReasonTooNew, notCachableReasonModifiersNotCachable} {
+		m.queryResultCacheSkippedCount.WithLabelValues(reason)
+	}
+
+	return m
+}
+
+// instantQueryCacheMiddleware is a Middleware that runs instant queries through the results cache.
+// When placed after the splitInstantQueryByIntervalMiddleware, each split partial query is cached
+// on its own, so that partial queries can be reused by subsequent queries.
+type instantQueryCacheMiddleware struct {
+	next    Handler
+	limits  Limits
+	logger  log.Logger
+	metrics *instantQueryCacheMiddlewareMetrics
+
+	cache          cache.Cache
+	resolution     time.Duration
+	extractor      Extractor
+	shouldCacheReq shouldCacheFn
+}
+
+// newInstantQueryCacheMiddleware makes a new instantQueryCacheMiddleware.
+func newInstantQueryCacheMiddleware(
+	limits Limits,
+	cache cache.Cache,
+	resolution time.Duration,
+	extractor Extractor,
+	shouldCacheReq shouldCacheFn,
+	logger log.Logger,
+	reg prometheus.Registerer) Middleware {
+	metrics := newInstantQueryCacheMiddlewareMetrics(reg)
+
+	return MiddlewareFunc(func(next Handler) Handler {
+		return &instantQueryCacheMiddleware{
+			next:           next,
+			limits:         limits,
+			logger:         logger,
+			metrics:        metrics,
+			cache:          cache,
+			resolution:     resolution,
+			extractor:      extractor,
+			shouldCacheReq: shouldCacheReq,
+		}
+	})
+}
+
+func (c *instantQueryCacheMiddleware) Do(ctx context.Context, req Request) (Response, error) {
+	if c.shouldCacheReq != nil && !c.shouldCacheReq(req) {
+		return c.next.Do(ctx, req)
+	}
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	c.metrics.queryResultCacheAttemptedCount.Inc()
+
+	maxCacheFreshness := validation.MaxDurationPerTenant(tenantIDs, c.limits.MaxCacheFreshness)
+	maxCacheTime := int64(model.Now().Add(-maxCacheFreshness))
+
+	// Align the evaluation timestamp to the configured resolution, so that queries
+	// issued within the same resolution window share the same cached result.
+	alignedReq := alignInstantQueryRequest(req, c.resolution)
+
+	if cachable, reason := isInstantRequestCachable(alignedReq, maxCacheTime, c.logger); !cachable {
+		c.metrics.queryResultCacheSkippedCount.WithLabelValues(reason).Inc()
+		return c.next.Do(ctx, req)
+	}
+
+	key := generateInstantQueryCacheKey(tenant.JoinTenantIDs(tenantIDs), alignedReq)
+	queryStats := stats.FromContext(ctx)
+	queryStats.AddResultsCacheLookups(1)
+	if cached, ok := fetchCachedResponse(ctx, c.cache, key, c.logger); ok {
+		c.metrics.queryResultCacheHitsCount.Inc()
+		queryStats.AddResultsCacheHits(1)
+		return cached, nil
+	}
+
+	res, err := c.next.Do(ctx, alignedReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if isResponseCachable(res, c.logger) {
+		ttl := resultsCacheTTLForEnd(c.limits, tenantIDs, alignedReq.GetEnd())
+		storeCachedResponse(ctx, c.cache, key, alignedReq, c.extractor.ResponseWithoutHeaders(res), ttl, c.logger)
+	}
+
+	return res, nil
+}
+
+// isInstantRequestCachable says whether the instant query request is eligible for caching.
+func isInstantRequestCachable(req Request, maxCacheTime int64, logger log.Logger) (cachable bool, reason string) {
+	// Do not cache it at all if the query evaluation time is more recent than the configured max cache freshness.
+	if req.GetStart() > maxCacheTime {
+		return false, notCachableReasonTooNew
+	}
+
+	if !areEvaluationTimeModifiersCachable(req, maxCacheTime, logger) {
+		return false, notCachableReasonModifiersNotCachable
+	}
+
+	return true, ""
+}
+
+// alignInstantQueryRequest returns a copy of the input request with the evaluation time
+// rounded down to the given resolution. Returns the input request if resolution is 0.
+func alignInstantQueryRequest(req Request, resolution time.Duration) Request {
+	resolutionMillis := resolution.Milliseconds()
+	if resolutionMillis <= 0 {
+		return req
+	}
+
+	aligned := req.GetStart() - (req.GetStart() % resolutionMillis)
+	if aligned == req.GetStart() {
+		return req
+	}
+
+	return req.WithStartEnd(aligned, aligned)
+}
+
+// generateInstantQueryCacheKey generates the results cache key for an instant query request.
+func generateInstantQueryCacheKey(userID string, req Request) string {
+	return fmt.Sprintf("%s:instant:%s:%d", userID, req.GetQuery(), req.GetStart())
+}
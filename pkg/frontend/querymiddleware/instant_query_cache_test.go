@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestInstantQueryCacheMiddleware(t *testing.T) {
+	now := time.Now()
+
+	expectedResponse := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: model.ValVector.String(),
+			Result: []SampleStream{
+				{
+					Labels:  []mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}},
+					Samples: []mimirpb.Sample{{Value: 137, TimestampMs: 1634292000000}},
+				},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		query               string
+		time                time.Time
+		resolution          time.Duration
+		followUpTime        time.Time
+		expectedDownstreams int
+		expectedStores      int
+		expectedSkipReason  string
+	}{
+		"should cache a query older than the max cache freshness": {
+			query:               `sum(metric)`,
+			time:                now.Add(-time.Hour),
+			followUpTime:        now.Add(-time.Hour),
+			expectedDownstreams: 1,
+			expectedStores:      1,
+		},
+		"should not cache a query more recent than the max cache freshness": {
+			query:               `sum(metric)`,
+			time:                now,
+			followUpTime:        now,
+			expectedDownstreams: 2,
+			expectedStores:      0,
+			expectedSkipReason:  notCachableReasonTooNew,
+		},
+		"should not cache a query with a negative offset": {
+			query:               `sum(metric offset -1h)`,
+			time:                now.Add(-time.Hour),
+			followUpTime:        now.Add(-time.Hour),
+			expectedDownstreams: 2,
+			expectedStores:      0,
+			expectedSkipReason:  notCachableReasonModifiersNotCachable,
+		},
+		"should not reuse the cached result for a different timestamp when resolution is disabled": {
+			query:               `sum(metric)`,
+			time:                now.Add(-time.Hour).Truncate(time.Minute),
+			followUpTime:        now.Add(-time.Hour).Truncate(time.Minute).Add(10 * time.Second),
+			expectedDownstreams: 2,
+			expectedStores:      2,
+		},
+		"should reuse the cached result for timestamps within the same resolution interval": {
+			query:               `sum(metric)`,
+			time:                now.Add(-time.Hour).Truncate(time.Minute),
+			resolution:          time.Minute,
+			followUpTime:        now.Add(-time.Hour).Truncate(time.Minute).Add(10 * time.Second),
+			expectedDownstreams: 1,
+			expectedStores:      1,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cacheBackend := cache.NewInstrumentedMockCache()
+			mw := newInstantQueryCacheMiddleware(
+				mockLimits{maxCacheFreshness: 10 * time.Minute},
+				cacheBackend,
+				testData.resolution,
+				PrometheusResponseExtractor{},
+				resultsCacheAlwaysEnabled,
+				log.NewNopLogger(),
+				prometheus.NewPedanticRegistry(),
+			)
+
+			var downstreamReqs []Request
+			handler := mw.Wrap(HandlerFunc(func(_ context.Context, req Request) (Response, error) {
+				downstreamReqs = append(downstreamReqs, req)
+				return expectedResponse, nil
+			}))
+
+			ctx := user.InjectOrgID(context.Background(), "user-1")
+
+			for _, ts := range []time.Time{testData.time, testData.followUpTime} {
+				res, err := handler.Do(ctx, &PrometheusInstantQueryRequest{
+					Path:  "/api/v1/query",
+					Time:  ts.UnixMilli(),
+					Query: testData.query,
+				})
+				require.NoError(t, err)
+				assert.Equal(t, expectedResponse, res)
+			}
+
+			assert.Len(t, downstreamReqs, testData.expectedDownstreams)
+			assert.Equal(t, testData.expectedStores, cacheBackend.CountStoreCalls())
+
+			if testData.resolution > 0 {
+				for _, req := range downstreamReqs {
+					assert.Zero(t, req.GetStart()%testData.resolution.Milliseconds(), "downstream request time should be aligned to the resolution")
+				}
+			}
+
+			metrics := handler.(*instantQueryCacheMiddleware).metrics
+			assert.Equal(t, 2.0, testutil.ToFloat64(metrics.queryResultCacheAttemptedCount))
+			if testData.expectedSkipReason != "" {
+				assert.Equal(t, 2.0, testutil.ToFloat64(metrics.queryResultCacheSkippedCount.WithLabelValues(testData.expectedSkipReason)))
+			}
+		})
+	}
+}
+
+func TestInstantQueryCacheMiddleware_ShouldNotCacheResponseWithNoStoreHeader(t *testing.T) {
+	cacheBackend := cache.NewInstrumentedMockCache()
+
+	mw := newInstantQueryCacheMiddleware(
+		mockLimits{maxCacheFreshness: 10 * time.Minute},
+		cacheBackend,
+		0,
+		PrometheusResponseExtractor{},
+		resultsCacheAlwaysEnabled,
+		log.NewNopLogger(),
+		prometheus.NewPedanticRegistry(),
+	)
+
+	downstreamReqs := 0
+	handler := mw.Wrap(HandlerFunc(func(_ context.Context, req Request) (Response, error) {
+		downstreamReqs++
+		return &PrometheusResponse{
+			Status:  statusSuccess,
+			Data:    &PrometheusData{ResultType: model.ValVector.String()},
+			Headers: []*PrometheusResponseHeader{{Name: cacheControlHeader, Values: []string{noStoreValue}}},
+		}, nil
+	}))
+
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+	req := &PrometheusInstantQueryRequest{
+		Path:  "/api/v1/query",
+		Time:  time.Now().Add(-time.Hour).UnixMilli(),
+		Query: `sum(metric)`,
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := handler.Do(ctx, req)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, downstreamReqs)
+	assert.Equal(t, 0, cacheBackend.CountStoreCalls())
+}
+
+func TestAlignInstantQueryRequest(t *testing.T) {
+	req := &PrometheusInstantQueryRequest{Time: 1634292025000}
+
+	assert.Equal(t, Request(req), alignInstantQueryRequest(req, 0))
+	assert.Equal(t, int64(1634292000000), alignInstantQueryRequest(req, time.Minute).GetStart())
+	assert.Equal(t, int64(1634292020000), alignInstantQueryRequest(req, 10*time.Second).GetStart())
+	assert.Equal(t, Request(req), alignInstantQueryRequest(req, 5*time.Second))
+}
@@ -8,6 +8,7 @@ package querymiddleware
 import (
 	"context"
 	"net/http"
+	"net/textproto"
 	"sync"
 	"time"
 
@@ -22,11 +23,21 @@ import (
 
 	apierror "github.com/grafana/mimir/pkg/api/error"
 	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/httpgrpcutil"
 	util_math "github.com/grafana/mimir/pkg/util/math"
 	"github.com/grafana/mimir/pkg/util/spanlogger"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
+type contextKey int
+
+// propagatedHeadersContextKey is the context key holding the headers of the original request which
+// should be propagated to the sub-requests.
+const propagatedHeadersContextKey contextKey = 0
+
+// propagatedHeaders is the list of headers of the original request which are propagated to the sub-requests.
+var propagatedHeaders = []string{httpgrpcutil.QuerySourceHeader, httpgrpcutil.PartialResponseHeader}
+
 // Limits allows us to specify per-tenant runtime limits on the behavior of
 // the query handling code.
 type Limits interface {
@@ -40,6 +51,12 @@ type Limits interface {
 	// frontend will process in parallel.
 	MaxQueryParallelism(userID string) int
 
+	// MaxReturnedSeriesPerQuery returns the maximum number of series a query can return. 0 to disable limit.
+	MaxReturnedSeriesPerQuery(userID string) int
+
+	// MaxQueryResponseSizeBytes returns the maximum size in bytes of the response of a query. 0 to disable limit.
+	MaxQueryResponseSizeBytes(userID string) int
+
 	// MaxCacheFreshness returns the period after which results are cacheable,
 	// to prevent caching of very recent results.
 	MaxCacheFreshness(userID string) time.Duration
@@ -51,6 +68,11 @@ type Limits interface {
 	// be run for a given received query. 0 to disable limit.
 	QueryShardingMaxShardedQueries(userID string) int
 
+	// QueryShardingTargetSeriesPerShard returns the target number of series each sharded query
+	// should fetch, used to choose the number of shards based on the estimated query cardinality.
+	// 0 to disable it.
+	QueryShardingTargetSeriesPerShard(userID string) uint64
+
 	// SplitInstantQueriesByInterval returns the time interval to split instant queries for a given tenant.
 	SplitInstantQueriesByInterval(userID string) time.Duration
 
@@ -58,8 +80,9 @@ type Limits interface {
 	// This method is copied from compactor.ConfigProvider.
 	CompactorSplitAndMergeShards(userID string) int
 
-	// CompactorBlocksRetentionPeriod returns the retention period for a given user.
-	CompactorBlocksRetentionPeriod(userID string) time.Duration
+	// CompactorBlocksMaxRetentionPeriod returns the longest retention period among the raw and
+	// downsampled blocks for a given user.
+	CompactorBlocksMaxRetentionPeriod(userID string) time.Duration
 
 	// OutOfOrderTimeWindow returns the out-of-order time window for the user.
 	OutOfOrderTimeWindow(userID string) model.Duration
@@ -96,7 +119,7 @@ func (l limitsMiddleware) Do(ctx context.Context, r Request) (Response, error) {
 	}
 
 	// Clamp the time range based on the max query lookback and block retention period.
-	blocksRetentionPeriod := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, l.CompactorBlocksRetentionPeriod)
+	blocksRetentionPeriod := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, l.CompactorBlocksMaxRetentionPeriod)
 	maxQueryLookback := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, l.MaxQueryLookback)
 	maxLookback := util_math.MinDuration(blocksRetentionPeriod, maxQueryLookback)
 	if maxLookback > 0 {
@@ -211,6 +234,14 @@ func (rt limitedParallelismRoundTripper) RoundTrip(r *http.Request) (*http.Respo
 		return nil, err
 	}
 
+	// Keep track of the headers which should be propagated to the sub-requests.
+	if headers := getPropagatedHeaders(r); len(headers) > 0 {
+		ctx = context.WithValue(ctx, propagatedHeadersContextKey, headers)
+	}
+
+	// Keep track of the query stats if they've been requested in the response.
+	ctx = contextWithRenderedStats(ctx, r)
+
 	if span := opentracing.SpanFromContext(ctx); span != nil {
 		request.LogToSpan(span)
 	}
@@ -263,7 +294,22 @@ func (rt limitedParallelismRoundTripper) RoundTrip(r *http.Request) (*http.Respo
 		return nil, err
 	}
 
-	return rt.codec.EncodeResponse(ctx, response)
+	// The limits are enforced only on the merged query result, because the results of the partial
+	// queries can exceed them when the query-frontend reduces them, like for topk() or filters.
+	resultSizeLimiter := newResultSizeLimiter(tenantIDs, rt.limits)
+	if err := resultSizeLimiter.checkSeries(response); err != nil {
+		return nil, err
+	}
+
+	encoded, err := rt.codec.EncodeResponse(ctx, response)
+	if err != nil {
+		return nil, err
+	}
+	if err := resultSizeLimiter.checkEncodedSize(encoded.ContentLength); err != nil {
+		return nil, err
+	}
+
+	return encoded, nil
 }
 
 // roundTripperHandler is an adapter that implements the Handler interface using a http.RoundTripper to perform
@@ -285,6 +331,12 @@ func (rth roundTripperHandler) Do(ctx context.Context, r Request) (Response, err
 		return nil, apierror.New(apierror.TypeBadData, err.Error())
 	}
 
+	if headers, ok := ctx.Value(propagatedHeadersContextKey).(http.Header); ok {
+		for name, values := range headers {
+			request.Header[name] = values
+		}
+	}
+
 	response, err := rth.next.RoundTrip(request)
 	if err != nil {
 		return nil, err
@@ -293,3 +345,14 @@ func (rth roundTripperHandler) Do(ctx context.Context, r Request) (Response, err
 
 	return rth.codec.DecodeResponse(ctx, response, r, rth.logger)
 }
+
+// getPropagatedHeaders returns the headers of the input request which should be propagated to the sub-requests.
+func getPropagatedHeaders(r *http.Request) http.Header {
+	headers := http.Header{}
+	for _, name := range propagatedHeaders {
+		if values := r.Header.Values(name); len(values) > 0 {
+			headers[textproto.CanonicalMIMEHeaderKey(name)] = values
+		}
+	}
+	return headers
+}
@@ -7,7 +7,10 @@ package querymiddleware
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -20,7 +23,11 @@ import (
 	"github.com/weaveworks/common/user"
 	"go.uber.org/atomic"
 
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/httpgrpcutil"
+	"github.com/grafana/mimir/pkg/util/limiter"
 )
 
 func TestLimitsMiddleware_MaxQueryLookback(t *testing.T) {
@@ -285,6 +292,9 @@ type mockLimits struct {
 	maxCacheFreshness              time.Duration
 	maxQueryParallelism            int
 	maxShardedQueries              int
+	maxReturnedSeriesPerQuery      int
+	maxQueryResponseSizeBytes      int
+	targetSeriesPerShard           uint64
 	splitInstantQueriesInterval    time.Duration
 	totalShards                    int
 	compactorShards                int
@@ -315,6 +325,14 @@ func (m mockLimits) MaxQueryParallelism(string) int {
 	return m.maxQueryParallelism
 }
 
+func (m mockLimits) MaxReturnedSeriesPerQuery(string) int {
+	return m.maxReturnedSeriesPerQuery
+}
+
+func (m mockLimits) MaxQueryResponseSizeBytes(string) int {
+	return m.maxQueryResponseSizeBytes
+}
+
 func (m mockLimits) MaxCacheFreshness(string) time.Duration {
 	return m.maxCacheFreshness
 }
@@ -327,6 +345,10 @@ func (m mockLimits) QueryShardingMaxShardedQueries(string) int {
 	return m.maxShardedQueries
 }
 
+func (m mockLimits) QueryShardingTargetSeriesPerShard(string) uint64 {
+	return m.targetSeriesPerShard
+}
+
 func (m mockLimits) SplitInstantQueriesByInterval(string) time.Duration {
 	return m.splitInstantQueriesInterval
 }
@@ -335,7 +357,7 @@ func (m mockLimits) CompactorSplitAndMergeShards(userID string) int {
 	return m.compactorShards
 }
 
-func (m mockLimits) CompactorBlocksRetentionPeriod(userID string) time.Duration {
+func (m mockLimits) CompactorBlocksMaxRetentionPeriod(userID string) time.Duration {
 	return m.compactorBlocksRetentionPeriod
 }
 
@@ -501,3 +523,194 @@ func TestLimitedRoundTripper_OriginalRequestContextCancellation(t *testing.T) {
 	).RoundTrip(r)
 	require.NoError(t, err)
 }
+
+func TestLimitedRoundTripper_ShouldPropagateHeadersToSubRequests(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "foo")
+
+	tests := map[string]struct {
+		headers         http.Header
+		expectedHeaders http.Header
+	}{
+		"no propagated headers": {
+			headers:         http.Header{"Other": []string{"value"}},
+			expectedHeaders: http.Header{},
+		},
+		"query source and partial response headers": {
+			headers: http.Header{
+				httpgrpcutil.QuerySourceHeader:     []string{httpgrpcutil.QuerySourceRuler},
+				httpgrpcutil.PartialResponseHeader: []string{"true"},
+				"Other":                            []string{"value"},
+			},
+			expectedHeaders: http.Header{
+				httpgrpcutil.QuerySourceHeader:     []string{httpgrpcutil.QuerySourceRuler},
+				httpgrpcutil.PartialResponseHeader: []string{"true"},
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				receivedMx sync.Mutex
+				received   []http.Header
+			)
+
+			downstream := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+				actual := http.Header{}
+				for _, name := range []string{httpgrpcutil.QuerySourceHeader, httpgrpcutil.PartialResponseHeader, "Other"} {
+					if values := req.Header.Values(name); len(values) > 0 {
+						actual[name] = values
+					}
+				}
+
+				receivedMx.Lock()
+				received = append(received, actual)
+				receivedMx.Unlock()
+
+				return PrometheusCodec.EncodeResponse(req.Context(), newEmptyPrometheusResponse())
+			})
+
+			r, err := PrometheusCodec.EncodeRequest(ctx, &PrometheusRangeQueryRequest{
+				Path:  "/query_range",
+				Start: util.TimeToMillis(time.Now().Add(-time.Hour)),
+				End:   util.TimeToMillis(time.Now()),
+				Step:  int64(1 * time.Second * time.Millisecond),
+				Query: `foo`,
+			})
+			require.NoError(t, err)
+			for name, values := range testData.headers {
+				r.Header[name] = values
+			}
+
+			_, err = newLimitedParallelismRoundTripper(downstream, PrometheusCodec, mockLimits{maxQueryParallelism: 2},
+				MiddlewareFunc(func(next Handler) Handler {
+					return HandlerFunc(func(c context.Context, req Request) (Response, error) {
+						for i := 0; i < 3; i++ {
+							if _, err := next.Do(c, req); err != nil {
+								return nil, err
+							}
+						}
+						return newEmptyPrometheusResponse(), nil
+					})
+				}),
+			).RoundTrip(r)
+			require.NoError(t, err)
+
+			assert.Equal(t, []http.Header{testData.expectedHeaders, testData.expectedHeaders, testData.expectedHeaders}, received)
+		})
+	}
+}
+
+func TestLimitedRoundTripper_ShouldEnforceResultSizeLimits(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "foo")
+
+	// Each partial query returns 3 different series, and the middleware returns either the 6 series
+	// of the 2 partial queries it runs, or only the first series of each partial query, like an
+	// aggregation reducing the partial query results (eg. topk) would do.
+	newPartialResponse := func(shard int) *PrometheusResponse {
+		res := newEmptyPrometheusResponse()
+		for i := 0; i < 3; i++ {
+			res.Data.Result = append(res.Data.Result, SampleStream{
+				Labels:  []mimirpb.LabelAdapter{{Name: "series", Value: fmt.Sprintf("%d-%d", shard, i)}},
+				Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: 1}, {TimestampMs: 2000, Value: 2}},
+			})
+		}
+		return res
+	}
+
+	encodedResponseSize := func(series []SampleStream) int {
+		encoded, err := PrometheusCodec.EncodeResponse(ctx, &PrometheusResponse{
+			Status: statusSuccess,
+			Data:   &PrometheusData{ResultType: model.ValMatrix.String(), Result: series},
+		})
+		require.NoError(t, err)
+		return int(encoded.ContentLength)
+	}
+
+	partialResponseSize := encodedResponseSize(newPartialResponse(0).Data.Result)
+	mergedResponseSize := encodedResponseSize(append(newPartialResponse(0).Data.Result, newPartialResponse(1).Data.Result...))
+	reducedResponseSize := encodedResponseSize([]SampleStream{newPartialResponse(0).Data.Result[0], newPartialResponse(1).Data.Result[0]})
+	require.Less(t, reducedResponseSize, partialResponseSize)
+
+	tests := map[string]struct {
+		reduce        bool
+		limits        mockLimits
+		expectedError string
+	}{
+		"no limits": {},
+		"limits not exceeded": {
+			limits: mockLimits{maxReturnedSeriesPerQuery: 6, maxQueryResponseSizeBytes: mergedResponseSize},
+		},
+		"limits exceeded by the partial query results but not by the reduced query result": {
+			reduce: true,
+			limits: mockLimits{maxReturnedSeriesPerQuery: 2, maxQueryResponseSizeBytes: reducedResponseSize},
+		},
+		"max returned series exceeded by the merged query result": {
+			limits:        mockLimits{maxReturnedSeriesPerQuery: 5},
+			expectedError: fmt.Sprintf(limiter.MaxReturnedSeriesHitMsgFormat, 5),
+		},
+		"max returned series exceeded by the reduced query result": {
+			reduce:        true,
+			limits:        mockLimits{maxReturnedSeriesPerQuery: 1},
+			expectedError: fmt.Sprintf(limiter.MaxReturnedSeriesHitMsgFormat, 1),
+		},
+		"max response size exceeded by the encoded query response": {
+			limits:        mockLimits{maxQueryResponseSizeBytes: mergedResponseSize - 1},
+			expectedError: fmt.Sprintf(limiter.MaxQueryResponseSizeHitMsgFormat, mergedResponseSize-1),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			downstream := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+				shard, err := strconv.Atoi(strings.TrimPrefix(req.URL.Query().Get("query"), "foo_"))
+				require.NoError(t, err)
+				return PrometheusCodec.EncodeResponse(req.Context(), newPartialResponse(shard))
+			})
+
+			r, err := PrometheusCodec.EncodeRequest(ctx, &PrometheusRangeQueryRequest{
+				Path:  "/query_range",
+				Start: util.TimeToMillis(time.Now().Add(-time.Hour)),
+				End:   util.TimeToMillis(time.Now()),
+				Step:  int64(1 * time.Second * time.Millisecond),
+				Query: `foo`,
+			})
+			require.NoError(t, err)
+
+			testData.limits.maxQueryParallelism = 2
+			res, err := newLimitedParallelismRoundTripper(downstream, PrometheusCodec, testData.limits,
+				MiddlewareFunc(func(next Handler) Handler {
+					return HandlerFunc(func(c context.Context, req Request) (Response, error) {
+						merged := newEmptyPrometheusResponse()
+						for shard := 0; shard < 2; shard++ {
+							partial, err := next.Do(c, req.WithQuery(fmt.Sprintf("foo_%d", shard)))
+							if err != nil {
+								return nil, err
+							}
+
+							result := partial.(*PrometheusResponse).Data.Result
+							if testData.reduce {
+								result = result[:1]
+							}
+							merged.Data.Result = append(merged.Data.Result, result...)
+						}
+						return merged, nil
+					})
+				}),
+			).RoundTrip(r)
+
+			if testData.expectedError == "" {
+				require.NoError(t, err)
+				assert.Equal(t, http.StatusOK, res.StatusCode)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Equal(t, testData.expectedError, err.Error())
+
+			resp, ok := apierror.HTTPResponseFromError(err)
+			require.True(t, ok)
+			assert.Equal(t, int32(http.StatusUnprocessableEntity), resp.Code)
+		})
+	}
+}
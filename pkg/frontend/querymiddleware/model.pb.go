@@ -649,6 +649,8 @@ func (m *Options) GetInstantSplitInterval() int64 {
 type Hints struct {
 	// Total number of queries that are expected to to be executed to serve the original request.
 	TotalQueries int32 `protobuf:"varint,1,opt,name=TotalQueries,proto3" json:"TotalQueries,omitempty"`
+	// Estimated number of series a query will fetch. 0 if the estimate is not available.
+	EstimatedSeriesCount uint64 `protobuf:"varint,2,opt,name=EstimatedSeriesCount,proto3" json:"EstimatedSeriesCount,omitempty"`
 }
 
 func (m *Hints) Reset()      { *m = Hints{} }
@@ -690,6 +692,58 @@ func (m *Hints) GetTotalQueries() int32 {
 	return 0
 }
 
+func (m *Hints) GetEstimatedSeriesCount() uint64 {
+	if m != nil {
+		return m.EstimatedSeriesCount
+	}
+	return 0
+}
+
+// QueryStatistics holds statistics about a query, cached to estimate the cost of later executions of the same query.
+type QueryStatistics struct {
+	// Number of series fetched by the query.
+	EstimatedSeriesCount uint64 `protobuf:"varint,1,opt,name=EstimatedSeriesCount,proto3" json:"EstimatedSeriesCount,omitempty"`
+}
+
+func (m *QueryStatistics) Reset()      { *m = QueryStatistics{} }
+func (*QueryStatistics) ProtoMessage() {}
+func (*QueryStatistics) Descriptor() ([]byte, []int) {
+	return fileDescriptor_4c16552f9fdb66d8, []int{10}
+}
+func (m *QueryStatistics) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *QueryStatistics) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_QueryStatistics.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *QueryStatistics) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_QueryStatistics.Merge(m, src)
+}
+func (m *QueryStatistics) XXX_Size() int {
+	return m.Size()
+}
+func (m *QueryStatistics) XXX_DiscardUnknown() {
+	xxx_messageInfo_QueryStatistics.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_QueryStatistics proto.InternalMessageInfo
+
+func (m *QueryStatistics) GetEstimatedSeriesCount() uint64 {
+	if m != nil {
+		return m.EstimatedSeriesCount
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*PrometheusRangeQueryRequest)(nil), "queryrange.PrometheusRangeQueryRequest")
 	proto.RegisterType((*PrometheusInstantQueryRequest)(nil), "queryrange.PrometheusInstantQueryRequest")
@@ -701,75 +755,78 @@ func init() {
 	proto.RegisterType((*Extent)(nil), "queryrange.Extent")
 	proto.RegisterType((*Options)(nil), "queryrange.Options")
 	proto.RegisterType((*Hints)(nil), "queryrange.Hints")
+	proto.RegisterType((*QueryStatistics)(nil), "queryrange.QueryStatistics")
 }
 
 func init() { proto.RegisterFile("model.proto", fileDescriptor_4c16552f9fdb66d8) }
 
 var fileDescriptor_4c16552f9fdb66d8 = []byte{
-	// 993 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xcd, 0x6e, 0x1c, 0x45,
-	0x10, 0xde, 0xd9, 0x7f, 0xd7, 0x86, 0xb5, 0x69, 0x5b, 0x62, 0x6c, 0x94, 0x99, 0xd5, 0x28, 0x07,
-	0xf3, 0xe3, 0x35, 0x38, 0xe2, 0x82, 0x04, 0x22, 0x13, 0x5b, 0x8a, 0x11, 0x82, 0xd0, 0xb6, 0x38,
-	0x70, 0x41, 0xbd, 0x3b, 0x9d, 0xdd, 0x21, 0xf3, 0x97, 0x9e, 0x9e, 0x90, 0xbd, 0x21, 0x9e, 0x80,
-	0x23, 0x2f, 0x80, 0xc4, 0x81, 0x33, 0x27, 0x1e, 0x20, 0x47, 0x73, 0x8b, 0x38, 0x0c, 0x78, 0x2d,
-	0x24, 0xb4, 0xa7, 0x3c, 0x02, 0xea, 0xea, 0x99, 0xdd, 0x71, 0x6c, 0x44, 0xb8, 0xec, 0x56, 0x57,
-	0x7d, 0x55, 0xfd, 0xd5, 0x37, 0xd5, 0x05, 0xbd, 0x30, 0xf6, 0x78, 0x30, 0x4c, 0x44, 0x2c, 0x63,
-	0x02, 0x8f, 0x32, 0x2e, 0x66, 0x82, 0x45, 0x13, 0xbe, 0xb3, 0x37, 0xf1, 0xe5, 0x34, 0x1b, 0x0d,
-	0xc7, 0x71, 0xb8, 0x3f, 0x89, 0x27, 0xf1, 0x3e, 0x42, 0x46, 0xd9, 0x03, 0x3c, 0xe1, 0x01, 0x2d,
-	0x9d, 0xba, 0x63, 0x4d, 0xe2, 0x78, 0x12, 0xf0, 0x15, 0xca, 0xcb, 0x04, 0x93, 0x7e, 0x1c, 0x15,
-	0xf1, 0x77, 0xaa, 0xe5, 0x04, 0x7b, 0xc0, 0x22, 0xb6, 0x1f, 0xfa, 0xa1, 0x2f, 0xf6, 0x93, 0x87,
-	0x13, 0x6d, 0x25, 0x23, 0xfd, 0x5f, 0x64, 0x6c, 0xbf, 0x58, 0x91, 0x45, 0x33, 0x1d, 0x72, 0x7e,
-	0xa9, 0xc3, 0xeb, 0xf7, 0x45, 0x1c, 0x72, 0x39, 0xe5, 0x59, 0x4a, 0x15, 0xdf, 0xcf, 0x15, 0x73,
-	0xca, 0x1f, 0x65, 0x3c, 0x95, 0x84, 0x40, 0x33, 0x61, 0x72, 0x6a, 0x1a, 0x03, 0x63, 0x77, 0x8d,
-	0xa2, 0x4d, 0xb6, 0xa0, 0x95, 0x4a, 0x26, 0xa4, 0x59, 0x1f, 0x18, 0xbb, 0x0d, 0xaa, 0x0f, 0x64,
-	0x03, 0x1a, 0x3c, 0xf2, 0xcc, 0x06, 0xfa, 0x94, 0xa9, 0x72, 0x53, 0xc9, 0x13, 0xb3, 0x89, 0x2e,
-	0xb4, 0xc9, 0x07, 0xd0, 0x91, 0x7e, 0xc8, 0xe3, 0x4c, 0x9a, 0xad, 0x81, 0xb1, 0xdb, 0x3b, 0xd8,
-	0x1e, 0x6a, 0x72, 0xc3, 0x92, 0xdc, 0xf0, 0xb0, 0x68, 0xd7, 0xed, 0x3e, 0xcd, 0xed, 0xda, 0x0f,
-	0x7f, 0xd8, 0x06, 0x2d, 0x73, 0xd4, 0xd5, 0x28, 0xac, 0xd9, 0x46, 0x3e, 0xfa, 0x40, 0x6e, 0x43,
-	0x27, 0x4e, 0x54, 0x4a, 0x6a, 0x76, 0xb0, 0xe8, 0xe6, 0x70, 0x25, 0xff, 0xf0, 0x33, 0x1d, 0x72,
-	0x9b, 0xaa, 0x1c, 0x2d, 0x91, 0xa4, 0x0f, 0x75, 0xdf, 0x33, 0xbb, 0xc8, 0xad, 0xee, 0x7b, 0x64,
-	0x0f, 0x5a, 0x53, 0x3f, 0x92, 0xa9, 0xb9, 0x86, 0x25, 0x5e, 0xad, 0x96, 0xb8, 0xa7, 0x02, 0x58,
-	0xc0, 0xa0, 0x1a, 0xe5, 0xfc, 0x66, 0xc0, 0xcd, 0x95, 0x70, 0xc7, 0x51, 0x2a, 0x59, 0x24, 0xff,
-	0x53, 0x3a, 0x02, 0x4d, 0xd5, 0x4a, 0xa1, 0x1c, 0xda, 0xab, 0x9e, 0x1a, 0xff, 0xd2, 0x53, 0xf3,
-	0x7f, 0xf6, 0xd4, 0xba, 0xda, 0x53, 0xfb, 0xa5, 0x7a, 0x3a, 0x05, 0xb3, 0x32, 0x0b, 0x3c, 0x4d,
-	0xe2, 0x28, 0xe5, 0xf7, 0x38, 0xf3, 0xb8, 0x20, 0xdb, 0xd0, 0xfc, 0x94, 0x85, 0x5c, 0x77, 0xe3,
-	0xb6, 0x16, 0xb9, 0x6d, 0xec, 0x51, 0x74, 0x91, 0x9b, 0xd0, 0xfe, 0x82, 0x05, 0x19, 0x4f, 0xcd,
-	0xfa, 0xa0, 0xb1, 0x0a, 0x16, 0x4e, 0xe7, 0xc7, 0x3a, 0x90, 0xab, 0x65, 0x89, 0x03, 0xed, 0x13,
-	0xc9, 0x64, 0x96, 0x16, 0x25, 0x61, 0x91, 0xdb, 0xed, 0x14, 0x3d, 0xb4, 0x88, 0x10, 0x17, 0x9a,
-	0x87, 0x4c, 0x32, 0x94, 0xab, 0x77, 0xb0, 0x53, 0xa5, 0xbf, 0xaa, 0xa8, 0x10, 0x2e, 0x59, 0xe4,
-	0x76, 0xdf, 0x63, 0x92, 0xbd, 0x1d, 0x87, 0xbe, 0xe4, 0x61, 0x22, 0x67, 0x14, 0x73, 0xc9, 0x7b,
-	0xb0, 0x76, 0x24, 0x44, 0x2c, 0x4e, 0x67, 0x09, 0xd7, 0x12, 0xbb, 0xaf, 0x2d, 0x72, 0x7b, 0x93,
-	0x97, 0xce, 0x4a, 0xc6, 0x0a, 0x49, 0xde, 0x80, 0x16, 0x1e, 0x50, 0xfd, 0x35, 0x77, 0x73, 0x91,
-	0xdb, 0xeb, 0x98, 0x52, 0x81, 0x6b, 0x04, 0x39, 0x82, 0x8e, 0x16, 0x29, 0x35, 0x5b, 0x83, 0xc6,
-	0x6e, 0xef, 0xe0, 0xd6, 0xf5, 0x44, 0x2f, 0x2b, 0x5a, 0xca, 0x54, 0xe6, 0x3a, 0xdf, 0x19, 0xd0,
-	0xbf, 0xdc, 0x15, 0x19, 0x02, 0x50, 0x9e, 0x66, 0x81, 0x44, 0xf2, 0x5a, 0xa7, 0xfe, 0x22, 0xb7,
-	0x41, 0x2c, 0xbd, 0xb4, 0x82, 0x20, 0x1f, 0x41, 0x5b, 0x9f, 0xf0, 0x4b, 0xf4, 0x0e, 0xcc, 0x2a,
-	0x91, 0x13, 0x16, 0x26, 0x01, 0x3f, 0x91, 0x82, 0xb3, 0xd0, 0xed, 0xab, 0xc1, 0x51, 0x8a, 0xeb,
-	0x4a, 0xb4, 0xc8, 0x73, 0x7e, 0x35, 0xe0, 0x46, 0x15, 0x48, 0x12, 0x68, 0x07, 0x6c, 0xc4, 0x03,
-	0xf5, 0x99, 0x1a, 0x38, 0x86, 0xe3, 0x58, 0x48, 0xfe, 0x24, 0x19, 0x0d, 0x3f, 0x51, 0xfe, 0xfb,
-	0xcc, 0x17, 0xee, 0x5d, 0x55, 0xed, 0xf7, 0xdc, 0x7e, 0xf7, 0x65, 0x56, 0x93, 0xce, 0xbb, 0xe3,
-	0xb1, 0x44, 0x72, 0xa1, 0x28, 0x84, 0x5c, 0x0a, 0x7f, 0x4c, 0x8b, 0x7b, 0xc8, 0xfb, 0xd0, 0x49,
-	0x91, 0x41, 0x5a, 0x74, 0xb1, 0xb1, 0xba, 0x52, 0x53, 0x5b, 0xb1, 0x7f, 0x8c, 0x23, 0x46, 0xcb,
-	0x04, 0xe7, 0x6b, 0xe8, 0xdf, 0x65, 0xe3, 0x29, 0xf7, 0x96, 0x63, 0xb6, 0x0d, 0x8d, 0x87, 0x7c,
-	0x56, 0x68, 0xd7, 0x59, 0xe4, 0xb6, 0x3a, 0x52, 0xf5, 0xa3, 0x76, 0x11, 0x7f, 0x22, 0xb9, 0x7a,
-	0x1f, 0xfa, 0x22, 0x52, 0x95, 0xeb, 0x08, 0x43, 0xee, 0x7a, 0x71, 0x55, 0x09, 0xa5, 0xa5, 0xe1,
-	0xfc, 0x6c, 0x40, 0x5b, 0x83, 0x88, 0x5d, 0x6e, 0x44, 0x75, 0x4d, 0xc3, 0x5d, 0x5b, 0xe4, 0xb6,
-	0x76, 0x94, 0xcb, 0x71, 0x5b, 0x2f, 0x47, 0x7c, 0xf6, 0x9a, 0x05, 0x8f, 0x3c, 0xbd, 0x25, 0x07,
-	0xd0, 0x95, 0x82, 0x8d, 0xf9, 0x57, 0xbe, 0x57, 0xcc, 0x5a, 0x39, 0x18, 0xe8, 0x3e, 0xf6, 0xc8,
-	0x87, 0xd0, 0x15, 0x45, 0x3b, 0xc5, 0xd2, 0xdc, 0xba, 0xb2, 0x34, 0xef, 0x44, 0x33, 0xf7, 0xc6,
-	0x22, 0xb7, 0x97, 0x48, 0xba, 0xb4, 0x3e, 0x6e, 0x76, 0x1b, 0x1b, 0x4d, 0xe7, 0x2f, 0x03, 0x3a,
-	0xc5, 0xda, 0x20, 0xb7, 0xe0, 0x15, 0x94, 0xe9, 0xd0, 0x4f, 0xd9, 0x28, 0xe0, 0x1e, 0xf2, 0xee,
-	0xd2, 0xcb, 0x4e, 0xf2, 0x26, 0x6c, 0x9c, 0x4c, 0x99, 0xf0, 0xfc, 0x68, 0xb2, 0x04, 0xd6, 0x11,
-	0x78, 0xc5, 0x4f, 0x06, 0xd0, 0x3b, 0x8d, 0x25, 0x0b, 0x30, 0x90, 0xe2, 0x3b, 0x6b, 0xd1, 0xaa,
-	0x8b, 0x1c, 0xc0, 0x56, 0xb1, 0x25, 0x4f, 0x92, 0xc0, 0x97, 0xcb, 0x8a, 0x4d, 0xac, 0x78, 0x6d,
-	0xec, 0xc5, 0x9c, 0xe3, 0x48, 0x72, 0xf1, 0x98, 0x05, 0xc5, 0x86, 0xbb, 0x36, 0xe6, 0xbc, 0x05,
-	0x2d, 0x5c, 0x6d, 0xc4, 0x81, 0x1b, 0x78, 0xbf, 0x5a, 0xca, 0x3e, 0xd7, 0x6b, 0xa6, 0x45, 0x2f,
-	0xf9, 0xdc, 0xa3, 0xb3, 0x73, 0xab, 0xf6, 0xec, 0xdc, 0xaa, 0x3d, 0x3f, 0xb7, 0x8c, 0x6f, 0xe7,
-	0x96, 0xf1, 0xd3, 0xdc, 0x32, 0x9e, 0xce, 0x2d, 0xe3, 0x6c, 0x6e, 0x19, 0x7f, 0xce, 0x2d, 0xe3,
-	0xef, 0xb9, 0x55, 0x7b, 0x3e, 0xb7, 0x8c, 0xef, 0x2f, 0xac, 0xda, 0xd9, 0x85, 0x55, 0x7b, 0x76,
-	0x61, 0xd5, 0xbe, 0x5c, 0xc7, 0x31, 0x09, 0x7d, 0xcf, 0x0b, 0xf8, 0x37, 0x4c, 0xf0, 0x51, 0x1b,
-	0xbf, 0xc3, 0xed, 0x7f, 0x02, 0x00, 0x00, 0xff, 0xff, 0x35, 0xce, 0x26, 0x85, 0x03, 0x08, 0x00,
-	0x00,
+	// 1027 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x55, 0x4d, 0x6f, 0x1b, 0x45,
+	0x18, 0xf6, 0xda, 0xbb, 0xb6, 0xf3, 0x3a, 0x38, 0x61, 0x12, 0x89, 0x4d, 0x50, 0x77, 0xad, 0x55,
+	0x0f, 0x01, 0x11, 0x07, 0x52, 0x71, 0x41, 0x02, 0xd1, 0x4d, 0x2c, 0x35, 0x08, 0x41, 0x19, 0x47,
+	0x1c, 0xb8, 0x54, 0x63, 0xef, 0xd4, 0x5e, 0xba, 0x5f, 0x9d, 0x9d, 0x2d, 0xf5, 0x0d, 0xf1, 0x0b,
+	0x38, 0xf2, 0x07, 0x90, 0x38, 0x70, 0xe6, 0xc4, 0x0f, 0xe8, 0x31, 0xdc, 0x2a, 0x0e, 0x0b, 0x71,
+	0x84, 0x84, 0x7c, 0xea, 0x4f, 0x40, 0x33, 0xb3, 0x6b, 0x6f, 0x9a, 0x44, 0xb4, 0x97, 0x64, 0xe6,
+	0xfd, 0x78, 0xe6, 0x79, 0x1f, 0xbf, 0x7e, 0x0c, 0x9d, 0x30, 0xf6, 0x68, 0xd0, 0x4f, 0x58, 0xcc,
+	0x63, 0x04, 0x8f, 0x33, 0xca, 0x66, 0x8c, 0x44, 0x13, 0xba, 0xbb, 0x3f, 0xf1, 0xf9, 0x34, 0x1b,
+	0xf5, 0xc7, 0x71, 0x78, 0x30, 0x89, 0x27, 0xf1, 0x81, 0x2c, 0x19, 0x65, 0x0f, 0xe5, 0x4d, 0x5e,
+	0xe4, 0x49, 0xb5, 0xee, 0x5a, 0x93, 0x38, 0x9e, 0x04, 0x74, 0x55, 0xe5, 0x65, 0x8c, 0x70, 0x3f,
+	0x8e, 0x8a, 0xfc, 0xfb, 0x55, 0x38, 0x46, 0x1e, 0x92, 0x88, 0x1c, 0x84, 0x7e, 0xe8, 0xb3, 0x83,
+	0xe4, 0xd1, 0x44, 0x9d, 0x92, 0x91, 0xfa, 0x5f, 0x74, 0xec, 0xbc, 0x8c, 0x48, 0xa2, 0x99, 0x4a,
+	0x39, 0xbf, 0xd5, 0xe1, 0xed, 0xfb, 0x2c, 0x0e, 0x29, 0x9f, 0xd2, 0x2c, 0xc5, 0x82, 0xef, 0x57,
+	0x82, 0x39, 0xa6, 0x8f, 0x33, 0x9a, 0x72, 0x84, 0x40, 0x4f, 0x08, 0x9f, 0x9a, 0x5a, 0x4f, 0xdb,
+	0x5b, 0xc3, 0xf2, 0x8c, 0xb6, 0xc1, 0x48, 0x39, 0x61, 0xdc, 0xac, 0xf7, 0xb4, 0xbd, 0x06, 0x56,
+	0x17, 0xb4, 0x09, 0x0d, 0x1a, 0x79, 0x66, 0x43, 0xc6, 0xc4, 0x51, 0xf4, 0xa6, 0x9c, 0x26, 0xa6,
+	0x2e, 0x43, 0xf2, 0x8c, 0x3e, 0x86, 0x16, 0xf7, 0x43, 0x1a, 0x67, 0xdc, 0x34, 0x7a, 0xda, 0x5e,
+	0xe7, 0x70, 0xa7, 0xaf, 0xc8, 0xf5, 0x4b, 0x72, 0xfd, 0xe3, 0x62, 0x5c, 0xb7, 0xfd, 0x2c, 0xb7,
+	0x6b, 0x3f, 0xfd, 0x65, 0x6b, 0xb8, 0xec, 0x11, 0x4f, 0x4b, 0x61, 0xcd, 0xa6, 0xe4, 0xa3, 0x2e,
+	0xe8, 0x0e, 0xb4, 0xe2, 0x44, 0xb4, 0xa4, 0x66, 0x4b, 0x82, 0x6e, 0xf5, 0x57, 0xf2, 0xf7, 0xbf,
+	0x54, 0x29, 0x57, 0x17, 0x70, 0xb8, 0xac, 0x44, 0x5d, 0xa8, 0xfb, 0x9e, 0xd9, 0x96, 0xdc, 0xea,
+	0xbe, 0x87, 0xf6, 0xc1, 0x98, 0xfa, 0x11, 0x4f, 0xcd, 0x35, 0x09, 0xf1, 0x66, 0x15, 0xe2, 0x9e,
+	0x48, 0x48, 0x00, 0x0d, 0xab, 0x2a, 0xe7, 0x0f, 0x0d, 0x6e, 0xad, 0x84, 0x3b, 0x89, 0x52, 0x4e,
+	0x22, 0xfe, 0xbf, 0xd2, 0x21, 0xd0, 0xc5, 0x28, 0x85, 0x72, 0xf2, 0xbc, 0x9a, 0xa9, 0x71, 0xc3,
+	0x4c, 0xfa, 0x6b, 0xce, 0x64, 0x5c, 0x9d, 0xa9, 0xf9, 0x4a, 0x33, 0x9d, 0x82, 0x59, 0xd9, 0x05,
+	0x9a, 0x26, 0x71, 0x94, 0xd2, 0x7b, 0x94, 0x78, 0x94, 0xa1, 0x1d, 0xd0, 0xbf, 0x20, 0x21, 0x55,
+	0xd3, 0xb8, 0xc6, 0x22, 0xb7, 0xb5, 0x7d, 0x2c, 0x43, 0xe8, 0x16, 0x34, 0xbf, 0x26, 0x41, 0x46,
+	0x53, 0xb3, 0xde, 0x6b, 0xac, 0x92, 0x45, 0xd0, 0xf9, 0xb9, 0x0e, 0xe8, 0x2a, 0x2c, 0x72, 0xa0,
+	0x39, 0xe4, 0x84, 0x67, 0x69, 0x01, 0x09, 0x8b, 0xdc, 0x6e, 0xa6, 0x32, 0x82, 0x8b, 0x0c, 0x72,
+	0x41, 0x3f, 0x26, 0x9c, 0x48, 0xb9, 0x3a, 0x87, 0xbb, 0x55, 0xfa, 0x2b, 0x44, 0x51, 0xe1, 0xa2,
+	0x45, 0x6e, 0x77, 0x3d, 0xc2, 0xc9, 0x7b, 0x71, 0xe8, 0x73, 0x1a, 0x26, 0x7c, 0x86, 0x65, 0x2f,
+	0xfa, 0x10, 0xd6, 0x06, 0x8c, 0xc5, 0xec, 0x74, 0x96, 0x50, 0x25, 0xb1, 0xfb, 0xd6, 0x22, 0xb7,
+	0xb7, 0x68, 0x19, 0xac, 0x74, 0xac, 0x2a, 0xd1, 0x3b, 0x60, 0xc8, 0x8b, 0x54, 0x7f, 0xcd, 0xdd,
+	0x5a, 0xe4, 0xf6, 0x86, 0x6c, 0xa9, 0x94, 0xab, 0x0a, 0x34, 0x80, 0x96, 0x12, 0x29, 0x35, 0x8d,
+	0x5e, 0x63, 0xaf, 0x73, 0x78, 0xfb, 0x7a, 0xa2, 0x97, 0x15, 0x2d, 0x65, 0x2a, 0x7b, 0x9d, 0x1f,
+	0x34, 0xe8, 0x5e, 0x9e, 0x0a, 0xf5, 0x01, 0x30, 0x4d, 0xb3, 0x80, 0x4b, 0xf2, 0x4a, 0xa7, 0xee,
+	0x22, 0xb7, 0x81, 0x2d, 0xa3, 0xb8, 0x52, 0x81, 0x3e, 0x85, 0xa6, 0xba, 0xc9, 0x4f, 0xa2, 0x73,
+	0x68, 0x56, 0x89, 0x0c, 0x49, 0x98, 0x04, 0x74, 0xc8, 0x19, 0x25, 0xa1, 0xdb, 0x15, 0x8b, 0x23,
+	0x14, 0x57, 0x48, 0xb8, 0xe8, 0x73, 0x7e, 0xd7, 0x60, 0xbd, 0x5a, 0x88, 0x12, 0x68, 0x06, 0x64,
+	0x44, 0x03, 0xf1, 0x31, 0x35, 0xe4, 0x1a, 0x8e, 0x63, 0xc6, 0xe9, 0xd3, 0x64, 0xd4, 0xff, 0x5c,
+	0xc4, 0xef, 0x13, 0x9f, 0xb9, 0x47, 0x02, 0xed, 0xcf, 0xdc, 0xfe, 0xe0, 0x55, 0xac, 0x49, 0xf5,
+	0xdd, 0xf5, 0x48, 0xc2, 0x29, 0x13, 0x14, 0x42, 0xca, 0x99, 0x3f, 0xc6, 0xc5, 0x3b, 0xe8, 0x23,
+	0x68, 0xa5, 0x92, 0x41, 0x5a, 0x4c, 0xb1, 0xb9, 0x7a, 0x52, 0x51, 0x5b, 0xb1, 0x7f, 0x22, 0x57,
+	0x0c, 0x97, 0x0d, 0xce, 0xb7, 0xd0, 0x3d, 0x22, 0xe3, 0x29, 0xf5, 0x96, 0x6b, 0xb6, 0x03, 0x8d,
+	0x47, 0x74, 0x56, 0x68, 0xd7, 0x5a, 0xe4, 0xb6, 0xb8, 0x62, 0xf1, 0x47, 0x78, 0x11, 0x7d, 0xca,
+	0x69, 0xc4, 0xcb, 0x87, 0x50, 0x55, 0xae, 0x81, 0x4c, 0xb9, 0x1b, 0xc5, 0x53, 0x65, 0x29, 0x2e,
+	0x0f, 0xce, 0xaf, 0x1a, 0x34, 0x55, 0x11, 0xb2, 0x4b, 0x47, 0x14, 0xcf, 0x34, 0xdc, 0xb5, 0x45,
+	0x6e, 0xab, 0x40, 0x69, 0x8e, 0x3b, 0xca, 0x1c, 0xe5, 0xd7, 0x5e, 0xb1, 0xa0, 0x91, 0xa7, 0x5c,
+	0xb2, 0x07, 0x6d, 0xce, 0xc8, 0x98, 0x3e, 0xf0, 0xbd, 0x62, 0xd7, 0xca, 0xc5, 0x90, 0xe1, 0x13,
+	0x0f, 0x7d, 0x02, 0x6d, 0x56, 0x8c, 0x53, 0x98, 0xe6, 0xf6, 0x15, 0xd3, 0xbc, 0x1b, 0xcd, 0xdc,
+	0xf5, 0x45, 0x6e, 0x2f, 0x2b, 0xf1, 0xf2, 0xf4, 0x99, 0xde, 0x6e, 0x6c, 0xea, 0xce, 0x3f, 0x1a,
+	0xb4, 0x0a, 0xdb, 0x40, 0xb7, 0xe1, 0x0d, 0x29, 0xd3, 0xb1, 0x9f, 0x92, 0x51, 0x40, 0x3d, 0xc9,
+	0xbb, 0x8d, 0x2f, 0x07, 0xd1, 0xbb, 0xb0, 0x39, 0x9c, 0x12, 0xe6, 0xf9, 0xd1, 0x64, 0x59, 0x58,
+	0x97, 0x85, 0x57, 0xe2, 0xa8, 0x07, 0x9d, 0xd3, 0x98, 0x93, 0x40, 0x26, 0x52, 0xf9, 0x3d, 0x33,
+	0x70, 0x35, 0x84, 0x0e, 0x61, 0xbb, 0x70, 0xc9, 0x61, 0x12, 0xf8, 0x7c, 0x89, 0xa8, 0x4b, 0xc4,
+	0x6b, 0x73, 0x2f, 0xf7, 0x9c, 0x44, 0x9c, 0xb2, 0x27, 0x24, 0x28, 0x1c, 0xee, 0xda, 0x9c, 0xf3,
+	0x00, 0x0c, 0x69, 0x6d, 0xc8, 0x81, 0x75, 0xf9, 0xbe, 0x30, 0x65, 0x9f, 0x2a, 0x9b, 0x31, 0xf0,
+	0xa5, 0x98, 0x78, 0x60, 0x90, 0x72, 0x3f, 0x24, 0x9c, 0x7a, 0x43, 0x19, 0x3a, 0x8a, 0xb3, 0x48,
+	0xfd, 0xb2, 0xe9, 0xf8, 0xda, 0x9c, 0x33, 0x80, 0x0d, 0xe9, 0xf3, 0xc2, 0xa3, 0xfc, 0x94, 0xfb,
+	0xe3, 0x9b, 0x61, 0xb4, 0x9b, 0x61, 0xdc, 0xc1, 0xd9, 0xb9, 0x55, 0x7b, 0x7e, 0x6e, 0xd5, 0x5e,
+	0x9c, 0x5b, 0xda, 0xf7, 0x73, 0x4b, 0xfb, 0x65, 0x6e, 0x69, 0xcf, 0xe6, 0x96, 0x76, 0x36, 0xb7,
+	0xb4, 0xbf, 0xe7, 0x96, 0xf6, 0xef, 0xdc, 0xaa, 0xbd, 0x98, 0x5b, 0xda, 0x8f, 0x17, 0x56, 0xed,
+	0xec, 0xc2, 0xaa, 0x3d, 0xbf, 0xb0, 0x6a, 0xdf, 0x6c, 0xc8, 0x0d, 0x0d, 0x7d, 0xcf, 0x0b, 0xe8,
+	0x77, 0x84, 0xd1, 0x51, 0x53, 0xae, 0xc0, 0x9d, 0xff, 0x06, 0x00, 0xa2, 0xb9, 0xd6, 0xe3, 0x7e,
+	0x08, 0x00, 0x00,
 }
 
 func (this *PrometheusRangeQueryRequest) Equal(that interface{}) bool {
@@ -1124,6 +1181,33 @@ func (this *Hints) Equal(that interface{}) bool {
 	if this.TotalQueries != that1.TotalQueries {
 		return false
 	}
+	if this.EstimatedSeriesCount != that1.EstimatedSeriesCount {
+		return false
+	}
+	return true
+}
+func (this *QueryStatistics) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*QueryStatistics)
+	if !ok {
+		that2, ok := that.(QueryStatistics)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.EstimatedSeriesCount != that1.EstimatedSeriesCount {
+		return false
+	}
 	return true
 }
 func (this *PrometheusRangeQueryRequest) GoString() string {
@@ -1276,9 +1360,20 @@ func (this *Hints) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 5)
+	s := make([]string, 0, 6)
 	s = append(s, "&querymiddleware.Hints{")
 	s = append(s, "TotalQueries: "+fmt.Sprintf("%#v", this.TotalQueries)+",\n")
+	s = append(s, "EstimatedSeriesCount: "+fmt.Sprintf("%#v", this.EstimatedSeriesCount)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *QueryStatistics) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&querymiddleware.QueryStatistics{")
+	s = append(s, "EstimatedSeriesCount: "+fmt.Sprintf("%#v", this.EstimatedSeriesCount)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1829,6 +1924,11 @@ func (m *Hints) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.EstimatedSeriesCount != 0 {
+		i = encodeVarintModel(dAtA, i, uint64(m.EstimatedSeriesCount))
+		i--
+		dAtA[i] = 0x10
+	}
 	if m.TotalQueries != 0 {
 		i = encodeVarintModel(dAtA, i, uint64(m.TotalQueries))
 		i--
@@ -1837,6 +1937,34 @@ func (m *Hints) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *QueryStatistics) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *QueryStatistics) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *QueryStatistics) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.EstimatedSeriesCount != 0 {
+		i = encodeVarintModel(dAtA, i, uint64(m.EstimatedSeriesCount))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintModel(dAtA []byte, offset int, v uint64) int {
 	offset -= sovModel(v)
 	base := offset
@@ -2079,6 +2207,21 @@ func (m *Hints) Size() (n int) {
 	if m.TotalQueries != 0 {
 		n += 1 + sovModel(uint64(m.TotalQueries))
 	}
+	if m.EstimatedSeriesCount != 0 {
+		n += 1 + sovModel(uint64(m.EstimatedSeriesCount))
+	}
+	return n
+}
+
+func (m *QueryStatistics) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.EstimatedSeriesCount != 0 {
+		n += 1 + sovModel(uint64(m.EstimatedSeriesCount))
+	}
 	return n
 }
 
@@ -2232,6 +2375,17 @@ func (this *Hints) String() string {
 	}
 	s := strings.Join([]string{`&Hints{`,
 		`TotalQueries:` + fmt.Sprintf("%v", this.TotalQueries) + `,`,
+		`EstimatedSeriesCount:` + fmt.Sprintf("%v", this.EstimatedSeriesCount) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *QueryStatistics) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&QueryStatistics{`,
+		`EstimatedSeriesCount:` + fmt.Sprintf("%v", this.EstimatedSeriesCount) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -3816,6 +3970,94 @@ func (m *Hints) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EstimatedSeriesCount", wireType)
+			}
+			m.EstimatedSeriesCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowModel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.EstimatedSeriesCount |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipModel(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthModel
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *QueryStatistics) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowModel
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: QueryStatistics: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: QueryStatistics: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EstimatedSeriesCount", wireType)
+			}
+			m.EstimatedSeriesCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowModel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.EstimatedSeriesCount |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipModel(dAtA[iNdEx:])
@@ -165,6 +165,11 @@ func (d *PrometheusData) UnmarshalJSON(b []byte) error {
 }
 
 func (d *PrometheusData) MarshalJSON() ([]byte, error) {
+	return d.marshalJSONWithStats(nil)
+}
+
+// marshalJSONWithStats marshals the data including the query stats in the "stats" field, unless they're nil.
+func (d *PrometheusData) marshalJSONWithStats(stats *responseStats) ([]byte, error) {
 	if d == nil {
 		return []byte("null"), nil
 	}
@@ -174,32 +179,50 @@ func (d *PrometheusData) MarshalJSON() ([]byte, error) {
 		return json.Marshal(struct {
 			Type   model.ValueType     `json:"resultType"`
 			Result stringSampleStreams `json:"result"`
+			Stats  *responseStats      `json:"stats,omitempty"`
 		}{
 			Type:   model.ValString,
 			Result: d.Result,
+			Stats:  stats,
 		})
 
 	case model.ValScalar.String():
 		return json.Marshal(struct {
 			Type   model.ValueType     `json:"resultType"`
 			Result scalarSampleStreams `json:"result"`
+			Stats  *responseStats      `json:"stats,omitempty"`
 		}{
 			Type:   model.ValScalar,
 			Result: d.Result,
+			Stats:  stats,
 		})
 
 	case model.ValVector.String():
 		return json.Marshal(struct {
 			Type   model.ValueType      `json:"resultType"`
 			Result []vectorSampleStream `json:"result"`
+			Stats  *responseStats       `json:"stats,omitempty"`
 		}{
 			Type:   model.ValVector,
 			Result: asVectorSampleStreams(d.Result),
+			Stats:  stats,
 		})
 
 	case model.ValMatrix.String():
-		type plain *PrometheusData
-		return json.Marshal(plain(d))
+		if stats == nil {
+			type plain *PrometheusData
+			return json.Marshal(plain(d))
+		}
+
+		return json.Marshal(struct {
+			Type   model.ValueType `json:"resultType"`
+			Result []SampleStream  `json:"result"`
+			Stats  *responseStats  `json:"stats,omitempty"`
+		}{
+			Type:   model.ValMatrix,
+			Result: d.Result,
+			Stats:  stats,
+		})
 
 	default:
 		return nil, fmt.Errorf("can't marshal prometheus result type %q", d.ResultType)
@@ -135,6 +135,7 @@ func (s *querySharding) Do(ctx context.Context, r Request) (Response, error) {
 	// Update query stats.
 	queryStats := stats.FromContext(ctx)
 	queryStats.AddShardedQueries(uint32(shardingStats.GetShardedQueries()))
+	queryStats.UpdateQueryShards(uint32(totalShards))
 
 	r = r.WithQuery(shardedQuery)
 	shardedQueryable := newShardedQueryable(r, s.next)
@@ -262,38 +263,52 @@ func (s *querySharding) getShardsForQuery(ctx context.Context, tenantIDs []strin
 		return 1
 	}
 
-	// Honor the number of shards specified in the request (if any).
+	hints := r.GetHints()
+	maxShardedQueries := validation.SmallestPositiveIntPerTenant(tenantIDs, s.limit.QueryShardingMaxShardedQueries)
+
+	// The number of shardable legs is computed only if required, because it requires to parse and map the query.
+	numShardableLegs := 0
+	getNumShardableLegs := func() int {
+		if numShardableLegs == 0 {
+			numShardableLegs = s.getNumShardableLegs(ctx, r.GetQuery())
+		}
+		return numShardableLegs
+	}
+
+	// Honor the number of shards specified in the request (if any), otherwise adjust the number
+	// of shards based on the estimated query cardinality (if any).
 	if r.GetOptions().TotalShards > 0 {
 		totalShards = int(r.GetOptions().TotalShards)
+	} else if hints != nil && hints.EstimatedSeriesCount > 0 {
+		seriesPerShard := validation.SmallestPositiveNonZeroUint64PerTenant(tenantIDs, s.limit.QueryShardingTargetSeriesPerShard)
+		if seriesPerShard > 0 {
+			prevTotalShards := totalShards
+			estimatedShards := int((hints.EstimatedSeriesCount + seriesPerShard - 1) / seriesPerShard)
+
+			// The estimated number of shards can be higher than the default one, so that high cardinality
+			// queries are sharded enough, but it's bound by the max sharded queries limit (if any).
+			maxEstimatedShards := totalShards
+			if maxShardedQueries > 0 {
+				maxEstimatedShards = maxShardedQueries / getNumShardableLegs()
+			}
+			totalShards = util_math.Max(1, util_math.Min(maxEstimatedShards, estimatedShards))
+
+			if prevTotalShards != totalShards {
+				level.Debug(spanLog).Log(
+					"msg", "number of shards has been adjusted based on the estimated query cardinality",
+					"updated total shards", totalShards,
+					"previous total shards", prevTotalShards,
+					"estimated series count", hints.EstimatedSeriesCount,
+					"target series per shard", seriesPerShard)
+			}
+		}
 	}
 
-	maxShardedQueries := validation.SmallestPositiveIntPerTenant(tenantIDs, s.limit.QueryShardingMaxShardedQueries)
-	hints := r.GetHints()
-
 	// If total queries is provided through hints, then we adjust the number of shards for the query
 	// based on the configured max sharded queries limit.
 	if hints != nil && hints.TotalQueries > 0 && maxShardedQueries > 0 {
-		// Calculate how many legs are shardable. To do it we use a trick: rewrite the query passing 1
-		// total shards and then we check how many sharded queries are generated. In case of any error,
-		// we just consider as if there's only 1 shardable leg (the error will be detected anyway later on).
-		//
-		// "Leg" is the terminology we use in query sharding to mention a part of the query that can be sharded.
-		// For example, look at this query:
-		// sum(metric) / count(metric)
-		//
-		// This query has 2 shardable "legs":
-		// - sum(metric)
-		// - count(metric)
-		//
-		// Calling s.shardQuery() with 1 total shards we can see how many shardable legs the query has.
-		_, shardingStats, err := s.shardQuery(ctx, r.GetQuery(), 1)
-		numShardableLegs := 1
-		if err == nil && shardingStats.GetShardedQueries() > 0 {
-			numShardableLegs = shardingStats.GetShardedQueries()
-		}
-
 		prevTotalShards := totalShards
-		totalShards = util_math.Max(1, util_math.Min(totalShards, (maxShardedQueries/int(hints.TotalQueries))/numShardableLegs))
+		totalShards = util_math.Max(1, util_math.Min(totalShards, (maxShardedQueries/int(hints.TotalQueries))/getNumShardableLegs()))
 
 		if prevTotalShards != totalShards {
 			level.Debug(spanLog).Log(
@@ -301,7 +316,7 @@ func (s *querySharding) getShardsForQuery(ctx context.Context, tenantIDs []strin
 				"updated total shards", totalShards,
 				"previous total shards", prevTotalShards,
 				"max sharded queries", maxShardedQueries,
-				"shardable legs", numShardableLegs,
+				"shardable legs", getNumShardableLegs(),
 				"total queries", hints.TotalQueries)
 		}
 	}
@@ -344,6 +359,26 @@ func (s *querySharding) getShardsForQuery(ctx context.Context, tenantIDs []strin
 	return totalShards
 }
 
+// getNumShardableLegs returns the number of shardable legs of the input query. To do it we use a trick:
+// rewrite the query passing 1 total shards and then we check how many sharded queries are generated.
+// In case of any error, we just consider as if there's only 1 shardable leg (the error will be detected
+// anyway later on).
+//
+// "Leg" is the terminology we use in query sharding to mention a part of the query that can be sharded.
+// For example, look at this query:
+// sum(metric) / count(metric)
+//
+// This query has 2 shardable "legs":
+// - sum(metric)
+// - count(metric)
+func (s *querySharding) getNumShardableLegs(ctx context.Context, query string) int {
+	_, shardingStats, err := s.shardQuery(ctx, query, 1)
+	if err == nil && shardingStats.GetShardedQueries() > 0 {
+		return shardingStats.GetShardedQueries()
+	}
+	return 1
+}
+
 // promqlResultToSamples transforms a promql query result into a samplestream
 func promqlResultToSamples(res *promql.Result) ([]SampleStream, error) {
 	if res.Err != nil {
@@ -38,6 +38,7 @@ import (
 	apierror "github.com/grafana/mimir/pkg/api/error"
 	"github.com/grafana/mimir/pkg/frontend/querymiddleware/astmapper"
 	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/sharding"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/validation"
@@ -1167,6 +1168,129 @@ func TestQuerySharding_ShouldSupportMaxShardedQueries(t *testing.T) {
 	}
 }
 
+func TestQuerySharding_ShouldSupportCardinalityEstimation(t *testing.T) {
+	tests := map[string]struct {
+		query                string
+		hints                *Hints
+		totalShards          int
+		maxShardedQueries    int
+		targetSeriesPerShard uint64
+		expectedShards       int
+		expectedLegs         int
+	}{
+		"no estimate": {
+			hints:                &Hints{TotalQueries: 1},
+			totalShards:          16,
+			maxShardedQueries:    64,
+			targetSeriesPerShard: 100,
+			expectedShards:       16,
+		},
+		"estimate lower than the target series per shard": {
+			hints:                &Hints{TotalQueries: 1, EstimatedSeriesCount: 50},
+			totalShards:          16,
+			maxShardedQueries:    64,
+			targetSeriesPerShard: 100,
+			expectedShards:       1,
+		},
+		"estimate requiring less shards than the total shards": {
+			hints:                &Hints{TotalQueries: 1, EstimatedSeriesCount: 350},
+			totalShards:          16,
+			maxShardedQueries:    64,
+			targetSeriesPerShard: 100,
+			expectedShards:       4,
+		},
+		"estimate requiring more shards than the total shards": {
+			hints:                &Hints{TotalQueries: 1, EstimatedSeriesCount: 3000},
+			totalShards:          16,
+			maxShardedQueries:    64,
+			targetSeriesPerShard: 100,
+			expectedShards:       30,
+		},
+		"estimate requiring more shards than the total shards and the max sharded queries": {
+			hints:                &Hints{TotalQueries: 1, EstimatedSeriesCount: 10000},
+			totalShards:          16,
+			maxShardedQueries:    64,
+			targetSeriesPerShard: 100,
+			expectedShards:       64,
+		},
+		"estimate requiring more shards than the total shards and the max sharded queries with multiple shardable legs": {
+			query:                "sum(metric) / count(metric)",
+			hints:                &Hints{TotalQueries: 1, EstimatedSeriesCount: 10000},
+			totalShards:          16,
+			maxShardedQueries:    64,
+			targetSeriesPerShard: 100,
+			expectedShards:       32,
+			expectedLegs:         2,
+		},
+		"estimate requiring more shards than the total shards with the max sharded queries disabled": {
+			hints:                &Hints{TotalQueries: 1, EstimatedSeriesCount: 10000},
+			totalShards:          16,
+			maxShardedQueries:    0,
+			targetSeriesPerShard: 100,
+			expectedShards:       16,
+		},
+		"estimate requiring more shards than allowed by the max sharded queries": {
+			hints:                &Hints{TotalQueries: 4, EstimatedSeriesCount: 1200},
+			totalShards:          16,
+			maxShardedQueries:    32,
+			targetSeriesPerShard: 100,
+			expectedShards:       8,
+		},
+		"target series per shard disabled": {
+			hints:                &Hints{TotalQueries: 1, EstimatedSeriesCount: 50},
+			totalShards:          16,
+			maxShardedQueries:    64,
+			targetSeriesPerShard: 0,
+			expectedShards:       16,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			query, expectedLegs := testData.query, testData.expectedLegs
+			if query == "" {
+				query, expectedLegs = "sum(metric)", 1
+			}
+
+			req := &PrometheusRangeQueryRequest{
+				Path:  "/query_range",
+				Start: util.TimeToMillis(start),
+				End:   util.TimeToMillis(end),
+				Step:  step.Milliseconds(),
+				Query: query,
+				Hints: testData.hints,
+			}
+
+			limits := mockLimits{
+				totalShards:          testData.totalShards,
+				maxShardedQueries:    testData.maxShardedQueries,
+				targetSeriesPerShard: testData.targetSeriesPerShard,
+			}
+			shardingware := newQueryShardingMiddleware(log.NewNopLogger(), newEngine(), limits, nil)
+
+			downstream := &mockHandler{}
+			downstream.On("Do", mock.Anything, mock.Anything).Return(&PrometheusResponse{
+				Status: statusSuccess, Data: &PrometheusData{
+					ResultType: string(parser.ValueTypeVector),
+				},
+			}, nil)
+
+			queryStats, ctx := stats.ContextWithEmptyStats(user.InjectOrgID(context.Background(), "test"))
+			res, err := shardingware.Wrap(downstream).Do(ctx, req)
+			require.NoError(t, err)
+			assert.Equal(t, statusSuccess, res.(*PrometheusResponse).GetStatus())
+			downstream.AssertNumberOfCalls(t, "Do", testData.expectedShards*expectedLegs)
+
+			// The number of shards is reported in the query stats only when the query is sharded.
+			if testData.expectedShards > 1 {
+				assert.Equal(t, uint32(testData.expectedShards), queryStats.LoadQueryShards())
+			} else {
+				assert.Equal(t, uint32(0), queryStats.LoadQueryShards())
+			}
+		})
+	}
+}
+
 func TestQuerySharding_ShouldReturnErrorOnDownstreamHandlerFailure(t *testing.T) {
 	req := &PrometheusRangeQueryRequest{
 		Path:  "/query_range",
@@ -0,0 +1,722 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	prom_remote "github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/ingester/client"
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/storage/sharding"
+	"github.com/grafana/mimir/pkg/util"
+	util_math "github.com/grafana/mimir/pkg/util/math"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const (
+	remoteReadPathSuffix = "/api/v1/read"
+
+	// Content type of the STREAMED_XOR_CHUNKS remote read responses.
+	remoteReadStreamedContentType = "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse"
+
+	// Queries are a set of matchers with time ranges - should not get into megabytes.
+	maxRemoteReadQuerySize = 1024 * 1024
+
+	// Max size of the streamed remote read response of a partial query received from a querier. Partial query
+	// results are received from queriers through gRPC, whose messages are limited to 100MiB by default.
+	maxRemoteReadPartialResponseSize = 100 * 1024 * 1024
+
+	// Maximum number of bytes in frame when using streaming remote read.
+	// Google's recommendation is to keep protobuf message not larger than 1MB.
+	// https://developers.google.com/protocol-buffers/docs/techniques#large-data
+	maxRemoteReadFrameBytes = 1024 * 1024
+
+	// Maximum number of samples encoded in a single chunk of a streamed remote read response,
+	// which is the same number of samples the TSDB head cuts chunks at.
+	maxRemoteReadSamplesPerChunk = 120
+)
+
+// remoteReadPartialQuery is a query of a remote read request, restricted to a time range and a shard.
+type remoteReadPartialQuery struct {
+	// queryIndex is the index of the query, in the original remote read request, the partial query belongs to.
+	queryIndex int
+
+	// start and end are the boundaries (both inclusive) of the partial query time range. Samples outside
+	// of the time range returned by queriers are discarded, because the partial queries time ranges are
+	// not overlapping.
+	start, end int64
+
+	request *client.QueryRequest
+}
+
+type remoteReadMetrics struct {
+	partialQueriesTotal prometheus.Counter
+}
+
+func newRemoteReadMetrics(reg prometheus.Registerer) *remoteReadMetrics {
+	return &remoteReadMetrics{
+		partialQueriesTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_remote_read_partial_queries_total",
+			Help: "Total number of partial queries remote read requests have been split into by the query-frontend.",
+		}),
+	}
+}
+
+// remoteReadRoundTripper is a http.RoundTripper that splits each query of a remote read request by
+// time and by series shard, runs the partial queries in parallel across queriers, and merges their results
+// into a single remote read response, in the response type negotiated with the client. The partial query
+// results are retrieved from queriers as streamed XOR chunks, and merged one series at a time, so that
+// STREAMED_XOR_CHUNKS responses are streamed to the client while being merged.
+type remoteReadRoundTripper struct {
+	next          http.RoundTripper
+	limits        Limits
+	splitInterval time.Duration
+	sharding      bool
+	logger        log.Logger
+	metrics       *remoteReadMetrics
+}
+
+func newRemoteReadRoundTripper(next http.RoundTripper, splitInterval time.Duration, sharding bool, limits Limits, logger log.Logger, metrics *remoteReadMetrics) http.RoundTripper {
+	return &remoteReadRoundTripper{
+		next:          next,
+		limits:        limits,
+		splitInterval: splitInterval,
+		sharding:      sharding,
+		logger:        logger,
+		metrics:       metrics,
+	}
+}
+
+func (rt *remoteReadRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	spanLog, ctx := spanlogger.NewWithLogger(r.Context(), rt.logger, "remoteReadRoundTripper.RoundTrip")
+	defer spanLog.Finish()
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	var req client.ReadRequest
+	if _, err := util.ParseProtoReader(ctx, r.Body, int(r.ContentLength), maxRemoteReadQuerySize, nil, &req, util.RawSnappy); err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	respType, err := negotiateRemoteReadResponseType(req.AcceptedResponseTypes)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	partials, err := rt.splitReadRequest(tenantIDs, &req)
+	if err != nil {
+		return nil, err
+	}
+	rt.metrics.partialQueriesTotal.Add(float64(len(partials)))
+	level.Debug(spanLog).Log("msg", "split remote read request", "queries", len(req.Queries), "partial_queries", len(partials))
+
+	// The partial queries are run one query at a time, so that only the results of the partial queries of
+	// a single query are held in memory while being merged.
+	partialsByQuery := make([][]remoteReadPartialQuery, len(req.Queries))
+	for _, partial := range partials {
+		partialsByQuery[partial.queryIndex] = append(partialsByQuery[partial.queryIndex], partial)
+	}
+
+	headers := getPropagatedHeaders(r)
+	parallelism := validation.SmallestPositiveIntPerTenant(tenantIDs, rt.limits.MaxQueryParallelism)
+	merger := newRemoteReadSeriesMerger(len(req.Queries), newResultSizeLimiter(tenantIDs, rt.limits).maxSeries, func(ctx context.Context, queryIdx int) ([]*remoteReadPartialStream, error) {
+		return rt.runPartialQueries(ctx, r.URL.Path, headers, partialsByQuery[queryIdx], parallelism)
+	})
+
+	if respType == client.STREAMED_XOR_CHUNKS {
+		// The partial queries of the first query are run before returning the response, so that their errors
+		// are returned to the client with their status code, like for remote read requests with a single query.
+		if err := merger.prefetch(ctx, 0); err != nil {
+			return nil, err
+		}
+		return streamRemoteReadXORChunksResponse(ctx, len(req.Queries), merger, rt.logger), nil
+	}
+	return encodeRemoteReadSamplesResponse(ctx, len(req.Queries), merger)
+}
+
+// splitReadRequest splits each query of the remote read request by time and by shard. The time range
+// of each query is manipulated based on the tenant limits, like it's done for the PromQL queries.
+func (rt *remoteReadRoundTripper) splitReadRequest(tenantIDs []string, req *client.ReadRequest) ([]remoteReadPartialQuery, error) {
+	totalShards := 1
+	if rt.sharding {
+		totalShards = util_math.Max(1, validation.SmallestPositiveIntPerTenant(tenantIDs, rt.limits.QueryShardingTotalShards))
+	}
+	maxShardedQueries := validation.SmallestPositiveIntPerTenant(tenantIDs, rt.limits.QueryShardingMaxShardedQueries)
+
+	var partials []remoteReadPartialQuery
+
+	for queryIdx, query := range req.Queries {
+		from, to, matchers, err := client.FromQueryRequest(query)
+		if err != nil {
+			return nil, apierror.New(apierror.TypeBadData, err.Error())
+		}
+
+		start, end, err := rt.applyTimeRangeLimits(tenantIDs, int64(from), int64(to))
+		if err != nil {
+			return nil, err
+		}
+		if end < start {
+			// The query is fully outside the allowed time range, so it gets an empty result.
+			continue
+		}
+
+		timeRanges := splitRemoteReadTimeRange(start, end, rt.splitInterval)
+
+		// Do not shard queries which already select a shard, and honor the max number of sharded queries.
+		shards := totalShards
+		if shard, _, err := sharding.ShardFromMatchers(matchers); err != nil {
+			return nil, apierror.New(apierror.TypeBadData, err.Error())
+		} else if shard != nil {
+			shards = 1
+		}
+		if maxShardedQueries > 0 && shards > 1 {
+			shards = util_math.Max(1, util_math.Min(shards, maxShardedQueries/len(timeRanges)))
+		}
+
+		for _, timeRange := range timeRanges {
+			for shardIdx := 0; shardIdx < shards; shardIdx++ {
+				partialMatchers := matchers
+				if shards > 1 {
+					partialMatchers = make([]*labels.Matcher, 0, len(matchers)+1)
+					partialMatchers = append(partialMatchers, matchers...)
+					partialMatchers = append(partialMatchers, sharding.ShardSelector{ShardIndex: uint64(shardIdx), ShardCount: uint64(shards)}.Matcher())
+				}
+
+				partialReq, err := client.ToQueryRequest(model.Time(timeRange[0]), model.Time(timeRange[1]), partialMatchers)
+				if err != nil {
+					return nil, apierror.New(apierror.TypeBadData, err.Error())
+				}
+
+				partials = append(partials, remoteReadPartialQuery{
+					queryIndex: queryIdx,
+					start:      timeRange[0],
+					end:        timeRange[1],
+					request:    partialReq,
+				})
+			}
+		}
+	}
+
+	return partials, nil
+}
+
+// applyTimeRangeLimits returns the time range of a remote read query manipulated based on the max query lookback,
+// blocks retention period and creation grace period, or an error if the query exceeds the max total query length.
+// The returned end is lower than start if the query is fully outside the allowed time range.
+func (rt *remoteReadRoundTripper) applyTimeRangeLimits(tenantIDs []string, start, end int64) (int64, int64, error) {
+	blocksRetentionPeriod := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, rt.limits.CompactorBlocksMaxRetentionPeriod)
+	maxQueryLookback := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, rt.limits.MaxQueryLookback)
+	if maxLookback := util_math.MinDuration(blocksRetentionPeriod, maxQueryLookback); maxLookback > 0 {
+		start = util_math.Max64(start, util.TimeToMillis(time.Now().Add(-maxLookback)))
+	}
+
+	if creationGracePeriod := validation.LargestPositiveNonZeroDurationPerTenant(tenantIDs, rt.limits.CreationGracePeriod); creationGracePeriod > 0 {
+		end = util_math.Min64(end, util.TimeToMillis(time.Now().Add(creationGracePeriod)))
+	}
+
+	if maxQueryLength := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, rt.limits.MaxTotalQueryLength); maxQueryLength > 0 && end >= start {
+		if queryLen := timestamp.Time(end).Sub(timestamp.Time(start)); queryLen > maxQueryLength {
+			return 0, 0, apierror.New(apierror.TypeBadData, validation.NewMaxTotalQueryLengthError(queryLen, maxQueryLength).Error())
+		}
+	}
+
+	return start, end, nil
+}
+
+// runPartialQueries runs the partial queries of a query in parallel, limited by the input parallelism, and returns
+// the streams of their results, in the same order of the partial queries. The streams must be closed by the caller.
+func (rt *remoteReadRoundTripper) runPartialQueries(ctx context.Context, path string, headers http.Header, partials []remoteReadPartialQuery, parallelism int) ([]*remoteReadPartialStream, error) {
+	streams := make([]*remoteReadPartialStream, len(partials))
+	err := concurrency.ForEachJob(ctx, len(partials), parallelism, func(jobCtx context.Context, idx int) error {
+		// The partial query is run with the request context, instead of the job one, because the response body
+		// is read after all the jobs have completed, when the job context has been canceled.
+		body, err := rt.doPartialQuery(ctx, path, headers, partials[idx].request)
+		if err != nil {
+			return err
+		}
+
+		streams[idx] = newRemoteReadPartialStream(partials[idx], body)
+		return nil
+	})
+	if err != nil {
+		closeRemoteReadPartialStreams(streams)
+		return nil, err
+	}
+
+	return streams, nil
+}
+
+// doPartialQuery runs a partial query through the next round tripper, requesting the result as streamed
+// XOR chunks, and returns the response body. The body must be closed by the caller.
+func (rt *remoteReadRoundTripper) doPartialQuery(ctx context.Context, path string, headers http.Header, query *client.QueryRequest) (io.ReadCloser, error) {
+	data, err := proto.Marshal(&client.ReadRequest{
+		Queries:               []*client.QueryRequest{query},
+		AcceptedResponseTypes: []client.ReadRequest_ResponseType{client.STREAMED_XOR_CHUNKS},
+	})
+	if err != nil {
+		return nil, apierror.Newf(apierror.TypeInternal, "error encoding remote read request: %v", err)
+	}
+
+	body := snappy.Encode(nil, data)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, apierror.New(apierror.TypeInternal, err.Error())
+	}
+	req.RequestURI = path // This is what the httpgrpc code looks at.
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	if err := user.InjectOrgIDIntoHTTPRequest(ctx, req); err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		defer func() { _ = resp.Body.Close() }()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, httpgrpc.Errorf(resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != remoteReadStreamedContentType {
+		_ = resp.Body.Close()
+		return nil, apierror.Newf(apierror.TypeInternal, "unexpected content type of remote read response: %q", contentType)
+	}
+
+	return &remoteReadPartialResponseBody{ReadCloser: resp.Body, bytesLeft: maxRemoteReadPartialResponseSize}, nil
+}
+
+// remoteReadPartialResponseBody is the body of the response of a partial query, failing to read once
+// more than maxRemoteReadPartialResponseSize bytes have been read.
+type remoteReadPartialResponseBody struct {
+	io.ReadCloser
+	bytesLeft int
+}
+
+func (b *remoteReadPartialResponseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytesLeft -= n
+	if b.bytesLeft < 0 {
+		return n, apierror.Newf(apierror.TypeInternal, "remote read response of a partial query exceeded the max size of %d bytes", maxRemoteReadPartialResponseSize)
+	}
+	return n, err
+}
+
+// splitRemoteReadTimeRange splits the input time range (both ends inclusive) into non-overlapping
+// time ranges aligned to the interval. Returns the input time range if interval is 0.
+func splitRemoteReadTimeRange(start, end int64, interval time.Duration) [][2]int64 {
+	intervalMillis := interval.Milliseconds()
+	if intervalMillis <= 0 {
+		return [][2]int64{{start, end}}
+	}
+
+	var ranges [][2]int64
+	for rangeStart := start; rangeStart <= end; {
+		nextStart := (rangeStart/intervalMillis + 1) * intervalMillis
+		ranges = append(ranges, [2]int64{rangeStart, util_math.Min64(nextStart-1, end)})
+		rangeStart = nextStart
+	}
+	return ranges
+}
+
+// remoteReadPartialStream reads the series of the streamed remote read response of a partial query, while
+// merging them. Series are sorted by labels, and the chunks of a series may be split across consecutive frames.
+type remoteReadPartialStream struct {
+	partial remoteReadPartialQuery
+	body    io.ReadCloser
+	reader  *prom_remote.ChunkedReader
+
+	// series are the series of the last frame read, not consumed yet.
+	series []*client.StreamChunkedSeries
+}
+
+func newRemoteReadPartialStream(partial remoteReadPartialQuery, body io.ReadCloser) *remoteReadPartialStream {
+	return &remoteReadPartialStream{
+		partial: partial,
+		body:    body,
+		reader:  prom_remote.NewChunkedReader(body, prom_remote.DefaultChunkedReadLimit, nil),
+	}
+}
+
+// head returns the next series of the stream, without consuming it, or nil if there are no more series.
+// The response body is closed once fully read.
+func (s *remoteReadPartialStream) head() (*client.StreamChunkedSeries, error) {
+	for len(s.series) == 0 {
+		if s.reader == nil {
+			return nil, nil
+		}
+
+		frame, err := s.reader.Next()
+		if errors.Is(err, io.EOF) {
+			s.close()
+			return nil, nil
+		}
+		if err != nil {
+			return nil, apierror.Newf(apierror.TypeInternal, "error reading remote read response: %v", err)
+		}
+
+		// The frame is copied, because the reader reuses its buffer while the unmarshalled
+		// series labels and chunks reference it.
+		var res client.StreamReadResponse
+		if err := res.Unmarshal(append([]byte(nil), frame...)); err != nil {
+			return nil, apierror.Newf(apierror.TypeInternal, "error decoding remote read response: %v", err)
+		}
+		s.series = res.ChunkedSeries
+	}
+
+	return s.series[0], nil
+}
+
+// next consumes the series returned by head.
+func (s *remoteReadPartialStream) next() {
+	s.series = s.series[1:]
+}
+
+// close closes the response body and releases the stream.
+func (s *remoteReadPartialStream) close() {
+	if s.reader == nil {
+		return
+	}
+
+	_ = s.body.Close()
+	s.body = nil
+	s.reader = nil
+	s.series = nil
+}
+
+func closeRemoteReadPartialStreams(streams []*remoteReadPartialStream) {
+	for _, s := range streams {
+		if s != nil {
+			s.close()
+		}
+	}
+}
+
+// remoteReadSeriesMerger runs the partial queries of each query of a remote read request, one query at a time,
+// and merges their streamed results one series at a time, reading the partial query response bodies while merging.
+type remoteReadSeriesMerger struct {
+	// maxSeries is the max number of series returned by each query (0 to disable the limit).
+	maxSeries int
+
+	// runPartialQueries runs the partial queries of a query, returning their streams sorted by time range.
+	runPartialQueries func(ctx context.Context, queryIdx int) ([]*remoteReadPartialStream, error)
+
+	// prefetched are the streams of the queries whose partial queries have already been run.
+	prefetched [][]*remoteReadPartialStream
+}
+
+func newRemoteReadSeriesMerger(numQueries, maxSeries int, runPartialQueries func(ctx context.Context, queryIdx int) ([]*remoteReadPartialStream, error)) *remoteReadSeriesMerger {
+	return &remoteReadSeriesMerger{
+		maxSeries:         maxSeries,
+		runPartialQueries: runPartialQueries,
+		prefetched:        make([][]*remoteReadPartialStream, numQueries),
+	}
+}
+
+// prefetch runs the partial queries of the query, before its series are merged.
+func (m *remoteReadSeriesMerger) prefetch(ctx context.Context, queryIdx int) error {
+	if queryIdx >= len(m.prefetched) || m.prefetched[queryIdx] != nil {
+		return nil
+	}
+
+	streams, err := m.runPartialQueries(ctx, queryIdx)
+	if err != nil {
+		return err
+	}
+
+	m.prefetched[queryIdx] = streams
+	return nil
+}
+
+// forEachSeries runs the partial queries of the query, if not prefetched, and calls f with each series of the query,
+// sorted by labels, and its samples within the query time range, sorted by timestamp. Series without samples within
+// the query time range are skipped. An error is returned if the query returns more than the max number of series.
+// The streams of the query are consumed and closed, so forEachSeries can be called once for each query.
+func (m *remoteReadSeriesMerger) forEachSeries(ctx context.Context, queryIdx int, f func(lbls []mimirpb.LabelAdapter, samples []mimirpb.Sample) error) error {
+	if err := m.prefetch(ctx, queryIdx); err != nil {
+		return err
+	}
+
+	streams := m.prefetched[queryIdx]
+	m.prefetched[queryIdx] = nil
+	defer closeRemoteReadPartialStreams(streams)
+
+	numSeries := 0
+	for {
+		// Find the series with the lowest labels among the next series of each partial query.
+		var lowest []mimirpb.LabelAdapter
+		for _, s := range streams {
+			head, err := s.head()
+			if err != nil {
+				return err
+			}
+			if head != nil && (lowest == nil || labels.Compare(mimirpb.FromLabelAdaptersToLabels(head.Labels), mimirpb.FromLabelAdaptersToLabels(lowest)) < 0) {
+				lowest = head.Labels
+			}
+		}
+		if lowest == nil {
+			return nil
+		}
+
+		// Copy the labels, so that the returned series don't reference the frames of the partial query results.
+		lbls := make([]mimirpb.LabelAdapter, 0, len(lowest))
+		for _, l := range lowest {
+			lbls = append(lbls, mimirpb.LabelAdapter{Name: strings.Clone(l.Name), Value: strings.Clone(l.Value)})
+		}
+
+		// Each series belongs to a single shard, and the partial queries time ranges are not overlapping,
+		// so the samples are appended to the merged series in timestamp order. Samples outside of the
+		// partial query time range are discarded.
+		var samples []mimirpb.Sample
+		for _, s := range streams {
+			for {
+				head, err := s.head()
+				if err != nil {
+					return err
+				}
+				if head == nil || labels.Compare(mimirpb.FromLabelAdaptersToLabels(head.Labels), mimirpb.FromLabelAdaptersToLabels(lbls)) != 0 {
+					break
+				}
+
+				for _, chk := range head.Chunks {
+					if chk.MaxTimeMs < s.partial.start || chk.MinTimeMs > s.partial.end {
+						continue
+					}
+					if samples, err = appendRemoteReadChunkSamples(samples, chk, s.partial.start, s.partial.end); err != nil {
+						return err
+					}
+				}
+				s.next()
+			}
+		}
+
+		if len(samples) == 0 {
+			continue
+		}
+
+		numSeries++
+		if m.maxSeries > 0 && numSeries > m.maxSeries {
+			return newMaxReturnedSeriesError(m.maxSeries)
+		}
+		if err := f(lbls, samples); err != nil {
+			return err
+		}
+	}
+}
+
+// appendRemoteReadChunkSamples appends the samples of the chunk within the time range (both ends inclusive),
+// and more recent than the last sample appended, to the input samples.
+func appendRemoteReadChunkSamples(samples []mimirpb.Sample, chk client.StreamChunk, start, end int64) ([]mimirpb.Sample, error) {
+	decoded, err := chunkenc.FromData(chunkenc.Encoding(chk.Type), chk.Data)
+	if err != nil {
+		return nil, apierror.Newf(apierror.TypeInternal, "error decoding remote read response chunk: %v", err)
+	}
+
+	it := decoded.Iterator(nil)
+	for it.Next() {
+		t, v := it.At()
+		if t < start || t > end || (len(samples) > 0 && t <= samples[len(samples)-1].TimestampMs) {
+			continue
+		}
+		samples = append(samples, mimirpb.Sample{TimestampMs: t, Value: v})
+	}
+	if err := it.Err(); err != nil {
+		return nil, apierror.Newf(apierror.TypeInternal, "error decoding remote read response chunk: %v", err)
+	}
+
+	return samples, nil
+}
+
+// encodeRemoteReadSamplesResponse encodes the merged series of each query in a SAMPLES remote read response.
+func encodeRemoteReadSamplesResponse(ctx context.Context, numQueries int, merger *remoteReadSeriesMerger) (*http.Response, error) {
+	results := make([]*client.QueryResponse, numQueries)
+	for queryIdx := range results {
+		res := &client.QueryResponse{}
+		if err := merger.forEachSeries(ctx, queryIdx, func(lbls []mimirpb.LabelAdapter, samples []mimirpb.Sample) error {
+			res.Timeseries = append(res.Timeseries, mimirpb.TimeSeries{Labels: lbls, Samples: samples})
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		results[queryIdx] = res
+	}
+
+	data, err := proto.Marshal(&client.ReadResponse{Results: results})
+	if err != nil {
+		return nil, apierror.Newf(apierror.TypeInternal, "error encoding remote read response: %v", err)
+	}
+
+	body := snappy.Encode(nil, data)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":     []string{"application/x-protobuf"},
+			"Content-Encoding": []string{"snappy"},
+		},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
+
+// streamRemoteReadXORChunksResponse returns a STREAMED_XOR_CHUNKS remote read response, whose body is written
+// while merging the series of each query: the frames of a series are written as soon as the series has been
+// merged, re-encoding its samples in XOR chunks. Errors occurring while streaming, like exceeding the max number
+// of series of a query, are logged and returned by the response body reader, because the response status has
+// already been sent to the client.
+func streamRemoteReadXORChunksResponse(ctx context.Context, numQueries int, merger *remoteReadSeriesMerger, logger log.Logger) *http.Response {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		stream := prom_remote.NewChunkedWriter(pw, nopFlusher{})
+		var err error
+		for queryIdx := 0; queryIdx < numQueries && err == nil; queryIdx++ {
+			err = merger.forEachSeries(ctx, queryIdx, func(lbls []mimirpb.LabelAdapter, samples []mimirpb.Sample) error {
+				return writeRemoteReadChunkedSeries(stream, queryIdx, mimirpb.TimeSeries{Labels: lbls, Samples: samples})
+			})
+		}
+		if err != nil {
+			level.Warn(logger).Log("msg", "failed to stream remote read response", "err", err)
+		}
+		_ = pw.CloseWithError(err)
+
+		// Release the streams of the queries not merged because of an error.
+		for queryIdx := range merger.prefetched {
+			closeRemoteReadPartialStreams(merger.prefetched[queryIdx])
+		}
+	}()
+
+	// Stop streaming the response if the request is canceled before the response body has been fully read.
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = pr.CloseWithError(ctx.Err())
+		case <-done:
+		}
+	}()
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type": []string{remoteReadStreamedContentType},
+		},
+		Body:          pr,
+		ContentLength: -1,
+	}
+}
+
+// writeRemoteReadChunkedSeries writes the series samples, encoded in XOR chunks, to the stream. A series
+// is written in multiple frames if its chunks exceed maxRemoteReadFrameBytes.
+func writeRemoteReadChunkedSeries(stream io.Writer, queryIdx int, ts mimirpb.TimeSeries) error {
+	labelsSize := 0
+	for _, l := range ts.Labels {
+		labelsSize += l.Size()
+	}
+
+	var chks []client.StreamChunk
+	frameBytesLeft := maxRemoteReadFrameBytes - labelsSize
+
+	for chunkStart := 0; chunkStart < len(ts.Samples); chunkStart += maxRemoteReadSamplesPerChunk {
+		chunkEnd := util_math.Min(chunkStart+maxRemoteReadSamplesPerChunk, len(ts.Samples))
+		samples := ts.Samples[chunkStart:chunkEnd]
+
+		chk := chunkenc.NewXORChunk()
+		app, err := chk.Appender()
+		if err != nil {
+			return err
+		}
+		for _, s := range samples {
+			app.Append(s.TimestampMs, s.Value)
+		}
+
+		chks = append(chks, client.StreamChunk{
+			MinTimeMs: samples[0].TimestampMs,
+			MaxTimeMs: samples[len(samples)-1].TimestampMs,
+			Type:      client.XOR,
+			Data:      chk.Bytes(),
+		})
+		frameBytesLeft -= chks[len(chks)-1].Size()
+
+		// We are fine with minor inaccuracy of max bytes per frame. The inaccuracy will be max of full chunk size.
+		if frameBytesLeft > 0 && chunkEnd < len(ts.Samples) {
+			continue
+		}
+
+		b, err := proto.Marshal(&client.StreamReadResponse{
+			ChunkedSeries: []*client.StreamChunkedSeries{{
+				Labels: ts.Labels,
+				Chunks: chks,
+			}},
+			QueryIndex: int64(queryIdx),
+		})
+		if err != nil {
+			return errors.Wrap(err, "marshal client.StreamReadResponse")
+		}
+		if _, err := stream.Write(b); err != nil {
+			return errors.Wrap(err, "write to stream")
+		}
+
+		chks = chks[:0]
+		frameBytesLeft = maxRemoteReadFrameBytes - labelsSize
+	}
+
+	return nil
+}
+
+// negotiateRemoteReadResponseType returns the first response type accepted by the client which is supported.
+func negotiateRemoteReadResponseType(accepted []client.ReadRequest_ResponseType) (client.ReadRequest_ResponseType, error) {
+	if len(accepted) == 0 {
+		return client.SAMPLES, nil
+	}
+
+	for _, resType := range accepted {
+		if resType == client.SAMPLES || resType == client.STREAMED_XOR_CHUNKS {
+			return resType, nil
+		}
+	}
+	return 0, fmt.Errorf("server does not support any of the requested response types: %v; supported: %v", accepted, []client.ReadRequest_ResponseType{client.SAMPLES, client.STREAMED_XOR_CHUNKS})
+}
+
+func isRemoteRead(path string) bool {
+	return strings.HasSuffix(path, remoteReadPathSuffix)
+}
+
+// nopFlusher is a http.Flusher doing nothing, used to write the streamed remote read response to a pipe.
+type nopFlusher struct{}
+
+func (nopFlusher) Flush() {}
@@ -0,0 +1,338 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	prom_remote "github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/ingester/client"
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/storage/sharding"
+	"github.com/grafana/mimir/pkg/util"
+	util_math "github.com/grafana/mimir/pkg/util/math"
+)
+
+func TestRemoteReadRoundTripper(t *testing.T) {
+	var (
+		start = time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+		end   = start.Add(6 * time.Hour)
+	)
+
+	// Generate a sample every minute (within the queried time range) for each series.
+	var storage []mimirpb.TimeSeries
+	for i := 0; i < 4; i++ {
+		ts := mimirpb.TimeSeries{Labels: []mimirpb.LabelAdapter{{Name: labels.MetricName, Value: "metric"}, {Name: "series", Value: fmt.Sprintf("%d", i)}}}
+		for t := start.Add(-time.Hour); !t.After(end.Add(time.Hour)); t = t.Add(time.Minute) {
+			ts.Samples = append(ts.Samples, mimirpb.Sample{TimestampMs: util.TimeToMillis(t), Value: float64(i)})
+		}
+		storage = append(storage, ts)
+	}
+
+	expectedSeries := make([]mimirpb.TimeSeries, 0, len(storage))
+	for _, ts := range storage {
+		expected := mimirpb.TimeSeries{Labels: ts.Labels}
+		for _, s := range ts.Samples {
+			if s.TimestampMs >= util.TimeToMillis(start) && s.TimestampMs <= util.TimeToMillis(end) {
+				expected.Samples = append(expected.Samples, s)
+			}
+		}
+		expectedSeries = append(expectedSeries, expected)
+	}
+
+	tests := map[string]struct {
+		splitInterval          time.Duration
+		sharding               bool
+		limits                 mockLimits
+		responseType           client.ReadRequest_ResponseType
+		expectedPartialQueries int
+		expectedErr            string
+	}{
+		"no splitting nor sharding": {
+			responseType:           client.SAMPLES,
+			expectedPartialQueries: 2,
+		},
+		"split by time": {
+			splitInterval:          2 * time.Hour,
+			responseType:           client.SAMPLES,
+			expectedPartialQueries: 2 * 4,
+		},
+		"split by time and sharded": {
+			splitInterval:          2 * time.Hour,
+			sharding:               true,
+			limits:                 mockLimits{totalShards: 3},
+			responseType:           client.SAMPLES,
+			expectedPartialQueries: 2 * 4 * 3,
+		},
+		"split by time and sharded with streamed XOR chunks response": {
+			splitInterval:          2 * time.Hour,
+			sharding:               true,
+			limits:                 mockLimits{totalShards: 3},
+			responseType:           client.STREAMED_XOR_CHUNKS,
+			expectedPartialQueries: 2 * 4 * 3,
+		},
+		"sharded with max sharded queries": {
+			splitInterval:          2 * time.Hour,
+			sharding:               true,
+			limits:                 mockLimits{totalShards: 3, maxShardedQueries: 8},
+			responseType:           client.SAMPLES,
+			expectedPartialQueries: 2 * 4 * 2,
+		},
+		"max total query length exceeded": {
+			limits:       mockLimits{maxTotalQueryLength: time.Hour},
+			responseType: client.SAMPLES,
+			expectedErr:  "the total query time range exceeds the limit",
+		},
+		"max returned series exceeded": {
+			limits:       mockLimits{maxReturnedSeriesPerQuery: 3},
+			responseType: client.SAMPLES,
+			expectedErr:  "the query returned more than the maximum number of series (limit: 3 series)",
+		},
+		"max returned series exceeded with streamed XOR chunks response": {
+			splitInterval: 2 * time.Hour,
+			sharding:      true,
+			limits:        mockLimits{totalShards: 3, maxReturnedSeriesPerQuery: 3},
+			responseType:  client.STREAMED_XOR_CHUNKS,
+			expectedErr:   "the query returned more than the maximum number of series (limit: 3 series)",
+		},
+		"max returned series not exceeded": {
+			splitInterval:          2 * time.Hour,
+			sharding:               true,
+			limits:                 mockLimits{totalShards: 3, maxReturnedSeriesPerQuery: 4},
+			responseType:           client.STREAMED_XOR_CHUNKS,
+			expectedPartialQueries: 2 * 4 * 3,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				partialQueriesMx sync.Mutex
+				partialQueries   int
+			)
+
+			// Mock the querier, which returns the series matching the query (including samples outside
+			// of the query time range, to check they're discarded by the query-frontend).
+			downstream := RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+				assert.Equal(t, "/prometheus/api/v1/read", r.URL.Path)
+
+				orgID, err := user.ExtractOrgID(r.Context())
+				require.NoError(t, err)
+				require.Equal(t, "test", orgID)
+
+				var req client.ReadRequest
+				_, err = util.ParseProtoReader(r.Context(), r.Body, int(r.ContentLength), maxRemoteReadQuerySize, nil, &req, util.RawSnappy)
+				require.NoError(t, err)
+				require.Len(t, req.Queries, 1)
+				require.Equal(t, []client.ReadRequest_ResponseType{client.STREAMED_XOR_CHUNKS}, req.AcceptedResponseTypes)
+
+				partialQueriesMx.Lock()
+				partialQueries++
+				partialQueriesMx.Unlock()
+
+				_, _, matchers, err := client.FromQueryRequest(req.Queries[0])
+				require.NoError(t, err)
+				shard, matchers, err := sharding.RemoveShardFromMatchers(matchers)
+				require.NoError(t, err)
+
+				// Each chunk is written in a different frame, to check series split across frames are merged.
+				body := &bytes.Buffer{}
+				stream := prom_remote.NewChunkedWriter(body, nopFlusher{})
+				for _, ts := range storage {
+					lbls := mimirpb.FromLabelAdaptersToLabels(ts.Labels)
+					if shard != nil && lbls.Hash()%shard.ShardCount != shard.ShardIndex {
+						continue
+					}
+					if !matchesAll(lbls, matchers) {
+						continue
+					}
+
+					for chunkStart := 0; chunkStart < len(ts.Samples); chunkStart += maxRemoteReadSamplesPerChunk {
+						chunkSamples := ts.Samples[chunkStart:util_math.Min(chunkStart+maxRemoteReadSamplesPerChunk, len(ts.Samples))]
+						require.NoError(t, writeRemoteReadChunkedSeries(stream, 0, mimirpb.TimeSeries{Labels: ts.Labels, Samples: chunkSamples}))
+					}
+				}
+
+				return &http.Response{
+					StatusCode:    http.StatusOK,
+					Header:        http.Header{"Content-Type": []string{remoteReadStreamedContentType}},
+					Body:          io.NopCloser(body),
+					ContentLength: int64(body.Len()),
+				}, nil
+			})
+
+			// The remote read request has 2 queries, the first matching all series and the second matching one series.
+			queries := make([]*client.QueryRequest, 0, 2)
+			for _, matchers := range [][]*labels.Matcher{
+				{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "metric")},
+				{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "metric"), labels.MustNewMatcher(labels.MatchEqual, "series", "1")},
+			} {
+				query, err := client.ToQueryRequest(model.Time(util.TimeToMillis(start)), model.Time(util.TimeToMillis(end)), matchers)
+				require.NoError(t, err)
+				queries = append(queries, query)
+			}
+
+			data, err := proto.Marshal(&client.ReadRequest{Queries: queries, AcceptedResponseTypes: []client.ReadRequest_ResponseType{testData.responseType}})
+			require.NoError(t, err)
+			ctx := user.InjectOrgID(context.Background(), "test")
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/prometheus/api/v1/read", bytes.NewReader(snappy.Encode(nil, data)))
+			require.NoError(t, err)
+
+			reg := prometheus.NewPedanticRegistry()
+			rt := newRemoteReadRoundTripper(downstream, testData.splitInterval, testData.sharding, testData.limits, log.NewNopLogger(), newRemoteReadMetrics(reg))
+			res, err := rt.RoundTrip(req)
+			if testData.expectedErr != "" {
+				// Errors occurring while streaming the response are returned by the response body reader.
+				if err == nil && testData.responseType == client.STREAMED_XOR_CHUNKS {
+					require.Equal(t, http.StatusOK, res.StatusCode)
+					_, err = io.ReadAll(res.Body)
+				}
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+
+			var actual [][]mimirpb.TimeSeries
+			switch testData.responseType {
+			case client.STREAMED_XOR_CHUNKS:
+				assert.Equal(t, remoteReadStreamedContentType, res.Header.Get("Content-Type"))
+				actual = decodeStreamedXORChunksResponse(t, res.Body, len(queries))
+			default:
+				assert.Equal(t, "application/x-protobuf", res.Header.Get("Content-Type"))
+
+				var readRes client.ReadResponse
+				_, err := util.ParseProtoReader(ctx, res.Body, int(res.ContentLength), maxRemoteReadPartialResponseSize, nil, &readRes, util.RawSnappy)
+				require.NoError(t, err)
+				for _, queryRes := range readRes.Results {
+					actual = append(actual, queryRes.Timeseries)
+				}
+			}
+
+			require.Len(t, actual, 2)
+			assert.Equal(t, expectedSeries, actual[0])
+			assert.Equal(t, expectedSeries[1:2], actual[1])
+
+			// The partial queries of the queries following the first one are run while streaming the response.
+			partialQueriesMx.Lock()
+			assert.Equal(t, testData.expectedPartialQueries, partialQueries)
+			partialQueriesMx.Unlock()
+			assert.Equal(t, float64(testData.expectedPartialQueries), testutil.ToFloat64(rt.(*remoteReadRoundTripper).metrics.partialQueriesTotal))
+		})
+	}
+}
+
+func TestRemoteReadRoundTripper_ShouldReturnErrorOnQuerierFailure(t *testing.T) {
+	downstream := RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(bytes.NewReader([]byte("querier unavailable")))}, nil
+	})
+
+	query, err := client.ToQueryRequest(0, 1000, []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "metric")})
+	require.NoError(t, err)
+	data, err := proto.Marshal(&client.ReadRequest{Queries: []*client.QueryRequest{query}})
+	require.NoError(t, err)
+
+	req, err := http.NewRequestWithContext(user.InjectOrgID(context.Background(), "test"), http.MethodPost, "/api/v1/read", bytes.NewReader(snappy.Encode(nil, data)))
+	require.NoError(t, err)
+
+	rt := newRemoteReadRoundTripper(downstream, 0, false, mockLimits{}, log.NewNopLogger(), newRemoteReadMetrics(nil))
+	_, err = rt.RoundTrip(req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "querier unavailable")
+}
+
+func TestRemoteReadPartialResponseBody(t *testing.T) {
+	body := &remoteReadPartialResponseBody{ReadCloser: io.NopCloser(strings.NewReader("0123456789")), bytesLeft: 10}
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	body = &remoteReadPartialResponseBody{ReadCloser: io.NopCloser(strings.NewReader("0123456789")), bytesLeft: 9}
+	_, err = io.ReadAll(body)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "remote read response of a partial query exceeded the max size")
+}
+
+func TestSplitRemoteReadTimeRange(t *testing.T) {
+	hour := time.Hour.Milliseconds()
+
+	assert.Equal(t, [][2]int64{{10, 5 * hour}}, splitRemoteReadTimeRange(10, 5*hour, 0))
+	assert.Equal(t, [][2]int64{{10, 2*hour - 1}, {2 * hour, 4*hour - 1}, {4 * hour, 5 * hour}}, splitRemoteReadTimeRange(10, 5*hour, 2*time.Hour))
+	assert.Equal(t, [][2]int64{{0, 2*hour - 1}, {2 * hour, 2 * hour}}, splitRemoteReadTimeRange(0, 2*hour, 2*time.Hour))
+	assert.Equal(t, [][2]int64{{hour, hour}}, splitRemoteReadTimeRange(hour, hour, 2*time.Hour))
+}
+
+func decodeStreamedXORChunksResponse(t *testing.T, body io.Reader, numQueries int) [][]mimirpb.TimeSeries {
+	out := make([][]mimirpb.TimeSeries, numQueries)
+	stream := prom_remote.NewChunkedReader(body, prom_remote.DefaultChunkedReadLimit, nil)
+
+	for {
+		var frame client.StreamReadResponse
+		err := stream.NextProto(&frame)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		for _, series := range frame.ChunkedSeries {
+			// The labels are unmarshalled as strings referencing the frame buffer, which is reused by the reader.
+			lbls := make([]mimirpb.LabelAdapter, 0, len(series.Labels))
+			for _, l := range series.Labels {
+				lbls = append(lbls, mimirpb.LabelAdapter{Name: strings.Clone(l.Name), Value: strings.Clone(l.Value)})
+			}
+
+			// Series may be split across multiple frames.
+			var ts *mimirpb.TimeSeries
+			if n := len(out[frame.QueryIndex]); n > 0 && labels.Equal(mimirpb.FromLabelAdaptersToLabels(out[frame.QueryIndex][n-1].Labels), mimirpb.FromLabelAdaptersToLabels(lbls)) {
+				ts = &out[frame.QueryIndex][n-1]
+			} else {
+				out[frame.QueryIndex] = append(out[frame.QueryIndex], mimirpb.TimeSeries{Labels: lbls})
+				ts = &out[frame.QueryIndex][len(out[frame.QueryIndex])-1]
+			}
+
+			for _, chk := range series.Chunks {
+				decoded, err := chunkenc.FromData(chunkenc.EncXOR, chk.Data)
+				require.NoError(t, err)
+
+				it := decoded.Iterator(nil)
+				for it.Next() {
+					sampleTs, v := it.At()
+					ts.Samples = append(ts.Samples, mimirpb.Sample{TimestampMs: sampleTs, Value: v})
+				}
+				require.NoError(t, it.Err())
+			}
+		}
+	}
+
+	return out
+}
+
+func matchesAll(lbls labels.Labels, matchers []*labels.Matcher) bool {
+	for _, m := range matchers {
+		if !m.Matches(lbls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
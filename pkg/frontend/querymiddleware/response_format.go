@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/httputil"
+	v1 "github.com/prometheus/prometheus/web/api/v1"
+	"github.com/weaveworks/common/middleware"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	// Supported formats of the query result responses sent by queriers to the query-frontend.
+	formatJSON     = "json"
+	formatProtobuf = "protobuf"
+
+	// Supported compressions of the query result responses sent by queriers to the query-frontend.
+	compressionNone   = ""
+	compressionSnappy = "snappy"
+
+	jsonMimeType     = "application/json"
+	protobufMimeType = "application/vnd.mimir.queryresponse+protobuf"
+)
+
+var (
+	allFormats      = []string{formatJSON, formatProtobuf}
+	allCompressions = []string{compressionSnappy}
+)
+
+// NewResponseFormatMiddleware returns a middleware which encodes the successful responses of the Prometheus
+// instant and range query API in the format and compression negotiated with the query-frontend through the
+// Accept and Accept-Encoding request headers. When the protobuf format is accepted, the query is executed by
+// the middleware itself and the PromQL engine result is encoded straight to protobuf, without going through
+// JSON. Responses are left untouched if the client doesn't explicitly accept the protobuf format or snappy
+// compression, so that end users keep getting JSON.
+func NewResponseFormatMiddleware(engine v1.QueryEngine, queryable storage.Queryable, statsRenderer v1.StatsRenderer, logger log.Logger) middleware.Interface {
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			toProtobuf := acceptsMimeType(r.Header.Get("Accept"), protobufMimeType)
+			toSnappy := acceptsEncoding(r.Header.Get("Accept-Encoding"), compressionSnappy)
+
+			if toProtobuf {
+				serveProtobufQuery(w, r, engine, queryable, statsRenderer, toSnappy, logger)
+				return
+			}
+			if !toSnappy {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &bufferedResponseWriter{header: http.Header{}, statusCode: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			body := rec.body.Bytes()
+
+			// Error responses are never compressed, because the query-frontend doesn't decode them.
+			if rec.statusCode/100 == 2 && isMimeType(rec.header.Get("Content-Type"), jsonMimeType) {
+				body = snappy.Encode(nil, body)
+				rec.header.Set("Content-Encoding", compressionSnappy)
+				rec.header.Set("Content-Length", strconv.Itoa(len(body)))
+			}
+
+			for name, values := range rec.header {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(rec.statusCode)
+			if _, err := w.Write(body); err != nil {
+				level.Warn(logger).Log("msg", "failed to write query response", "err", err)
+			}
+		})
+	})
+}
+
+// serveProtobufQuery executes the instant or range query of the input request and writes its result
+// in protobuf format, compressed with snappy if requested. Errors are written in JSON format, like
+// the Prometheus API does, because the query-frontend decodes them regardless of the format.
+func serveProtobufQuery(w http.ResponseWriter, r *http.Request, engine v1.QueryEngine, queryable storage.Queryable, statsRenderer v1.StatsRenderer, toSnappy bool, logger log.Logger) {
+	body, err := executeQueryToProtobuf(r, engine, queryable, statsRenderer)
+	if err != nil {
+		writeQueryError(w, err, logger)
+		return
+	}
+
+	if toSnappy {
+		body = snappy.Encode(nil, body)
+		w.Header().Set("Content-Encoding", compressionSnappy)
+	}
+
+	w.Header().Set("Content-Type", protobufMimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		level.Warn(logger).Log("msg", "failed to write query response", "err", err)
+	}
+}
+
+// executeQueryToProtobuf executes the instant or range query of the input request with the PromQL engine,
+// and returns its result encoded in protobuf format.
+func executeQueryToProtobuf(r *http.Request, engine v1.QueryEngine, queryable storage.Queryable, statsRenderer v1.StatsRenderer) ([]byte, error) {
+	ctx := r.Context()
+
+	req, err := PrometheusCodec.DecodeRequest(ctx, r)
+	if err != nil {
+		if !apierror.IsAPIError(err) {
+			err = apierror.New(apierror.TypeBadData, err.Error())
+		}
+		return nil, err
+	}
+
+	opts := &promql.QueryOpts{EnablePerStepStats: r.FormValue("stats") == "all"}
+
+	var qry promql.Query
+	switch req := req.(type) {
+	case *PrometheusRangeQueryRequest:
+		qry, err = engine.NewRangeQuery(queryable, opts, req.Query, util.TimeFromMillis(req.Start), util.TimeFromMillis(req.End), time.Duration(req.Step)*time.Millisecond)
+	case *PrometheusInstantQueryRequest:
+		qry, err = engine.NewInstantQuery(queryable, opts, req.Query, util.TimeFromMillis(req.Time))
+	default:
+		return nil, apierror.Newf(apierror.TypeBadData, "unsupported request type %T", req)
+	}
+	if err != nil {
+		return nil, decorateWithParamName(err, "query")
+	}
+
+	// The query must be closed only after the result has been encoded, because
+	// the engine reuses the memory of the result points once closed.
+	defer qry.Close()
+
+	res := qry.Exec(httputil.ContextFromRequest(ctx, r))
+	if res.Err != nil {
+		return nil, mapEngineError(res.Err)
+	}
+
+	if statsRenderer != nil {
+		statsRenderer(ctx, qry.Stats(), r.FormValue("stats"))
+	}
+
+	result, err := promqlResultToSamples(res)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeInternal, err.Error())
+	}
+
+	resp := PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: string(res.Value.Type()),
+			Result:     result,
+		},
+	}
+	return resp.Marshal()
+}
+
+// writeQueryError writes the input error as a Prometheus API JSON error response.
+func writeQueryError(w http.ResponseWriter, err error, logger log.Logger) {
+	if !apierror.IsAPIError(err) {
+		err = apierror.New(apierror.TypeInternal, err.Error())
+	}
+
+	res, _ := apierror.HTTPResponseFromError(err)
+	for _, h := range res.Headers {
+		w.Header()[h.Key] = h.Values
+	}
+	w.WriteHeader(int(res.Code))
+	if _, err := w.Write(res.Body); err != nil {
+		level.Warn(logger).Log("msg", "failed to write query error response", "err", err)
+	}
+}
+
+// bufferedResponseWriter is a http.ResponseWriter buffering the whole response in memory.
+type bufferedResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+}
+
+// acceptsMimeType returns whether the input Accept header value explicitly includes the mime type.
+func acceptsMimeType(accept, mimeType string) bool {
+	for _, value := range strings.Split(accept, ",") {
+		if isMimeType(value, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMimeType returns whether the input header value matches the mime type, ignoring any parameter.
+func isMimeType(value, mimeType string) bool {
+	parsed, _, err := mime.ParseMediaType(strings.TrimSpace(value))
+	return err == nil && parsed == mimeType
+}
+
+// acceptsEncoding returns whether the input Accept-Encoding header value explicitly includes the encoding.
+func acceptsEncoding(acceptEncoding, encoding string) bool {
+	for _, value := range strings.Split(acceptEncoding, ",") {
+		if name, _, _ := strings.Cut(value, ";"); strings.TrimSpace(name) == encoding {
+			return true
+		}
+	}
+	return false
+}
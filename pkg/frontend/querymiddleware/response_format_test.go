@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestResponseFormatMiddleware(t *testing.T) {
+	expectedResponse := mockPrometheusResponseSingleSeries(
+		[]mimirpb.LabelAdapter{{Name: "__name__", Value: "metric"}, {Name: "foo", Value: "bar"}},
+		mimirpb.Sample{TimestampMs: 1000, Value: 1},
+		mimirpb.Sample{TimestampMs: 2000, Value: 2},
+	)
+	expectedJSON, err := json.Marshal(expectedResponse)
+	require.NoError(t, err)
+
+	// The protobuf responses are encoded straight from the result of the query executed by the middleware.
+	queryable := storageSeriesQueryable([]*promql.StorageSeries{
+		promql.NewStorageSeries(promql.Series{
+			Metric: labels.FromStrings("__name__", "metric", "foo", "bar"),
+			Points: []promql.Point{{T: 1000, V: 1}, {T: 2000, V: 2}},
+		}),
+	})
+
+	tests := map[string]struct {
+		query                   string
+		accept                  string
+		acceptEncoding          string
+		expectedStatusCode      int
+		expectedContentType     string
+		expectedContentEncoding string
+		expectedNextCalled      bool
+	}{
+		"no format requested": {
+			query:               "metric",
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: jsonMimeType,
+			expectedNextCalled:  true,
+		},
+		"JSON requested": {
+			query:               "metric",
+			accept:              jsonMimeType,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: jsonMimeType,
+			expectedNextCalled:  true,
+		},
+		"protobuf requested": {
+			query:               "metric",
+			accept:              protobufMimeType + ", " + jsonMimeType,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: protobufMimeType,
+		},
+		"JSON with snappy compression requested": {
+			query:                   "metric",
+			accept:                  jsonMimeType,
+			acceptEncoding:          compressionSnappy,
+			expectedStatusCode:      http.StatusOK,
+			expectedContentType:     jsonMimeType,
+			expectedContentEncoding: compressionSnappy,
+			expectedNextCalled:      true,
+		},
+		"protobuf with snappy compression requested": {
+			query:                   "metric",
+			accept:                  protobufMimeType,
+			acceptEncoding:          "gzip, " + compressionSnappy,
+			expectedStatusCode:      http.StatusOK,
+			expectedContentType:     protobufMimeType,
+			expectedContentEncoding: compressionSnappy,
+		},
+		"protobuf with snappy compression requested but the query is invalid": {
+			query:               "metric{",
+			accept:              protobufMimeType,
+			acceptEncoding:      compressionSnappy,
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedContentType: jsonMimeType,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			nextCalled := false
+			handler := NewResponseFormatMiddleware(newEngine(), queryable, nil, log.NewNopLogger()).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nextCalled = true
+				w.Header().Set("Content-Type", jsonMimeType)
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write(expectedJSON)
+			}))
+
+			req := httptest.NewRequest("GET", "/api/v1/query_range?"+url.Values{
+				"query": []string{testData.query},
+				"start": []string{"1"},
+				"end":   []string{"2"},
+				"step":  []string{"1"},
+			}.Encode(), nil)
+			if testData.accept != "" {
+				req.Header.Set("Accept", testData.accept)
+			}
+			if testData.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", testData.acceptEncoding)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			assert.Equal(t, testData.expectedNextCalled, nextCalled)
+			assert.Equal(t, testData.expectedStatusCode, res.StatusCode)
+			assert.Equal(t, testData.expectedContentType, res.Header.Get("Content-Type"))
+			assert.Equal(t, testData.expectedContentEncoding, res.Header.Get("Content-Encoding"))
+
+			// The query-frontend should decode the same response, whatever the format.
+			decoded, err := PrometheusCodec.DecodeResponse(context.Background(), res, nil, log.NewNopLogger())
+			if testData.expectedStatusCode != http.StatusOK {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), `invalid parameter "query"`)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, expectedResponse.Data, decoded.(*PrometheusResponse).Data)
+		})
+	}
+}
+
+func TestPrometheusCodec_EncodeRequest_ShouldRequestPreferredResponseFormat(t *testing.T) {
+	req := &PrometheusRangeQueryRequest{Path: "/api/v1/query_range", Start: 0, End: 60000, Step: 15000, Query: "up"}
+
+	tests := map[string]struct {
+		codec                  Codec
+		expectedAccept         string
+		expectedAcceptEncoding string
+	}{
+		"default codec": {
+			codec:          PrometheusCodec,
+			expectedAccept: jsonMimeType,
+		},
+		"JSON format": {
+			codec:          NewPrometheusCodec(formatJSON, compressionNone),
+			expectedAccept: jsonMimeType,
+		},
+		"protobuf format": {
+			codec:          NewPrometheusCodec(formatProtobuf, compressionNone),
+			expectedAccept: protobufMimeType + ", " + jsonMimeType,
+		},
+		"protobuf format with snappy compression": {
+			codec:                  NewPrometheusCodec(formatProtobuf, compressionSnappy),
+			expectedAccept:         protobufMimeType + ", " + jsonMimeType,
+			expectedAcceptEncoding: compressionSnappy,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			encoded, err := testData.codec.EncodeRequest(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedAccept, encoded.Header.Get("Accept"))
+			assert.Equal(t, testData.expectedAcceptEncoding, encoded.Header.Get("Accept-Encoding"))
+		})
+	}
+}
+
+func BenchmarkPrometheusCodec_DecodeAndMergeShardedResponses(b *testing.B) {
+	const (
+		numSeriesPerShard   = 100
+		numSamplesPerSeries = 240
+	)
+
+	for _, numShards := range []int{16, 64} {
+		// Generate the mocked responses of each shard and encode them in all formats.
+		encoded := map[string][][]byte{}
+		for s := 0; s < numShards; s++ {
+			res := mockPrometheusResponse(numSeriesPerShard, numSamplesPerSeries)
+
+			jsonBody, err := json.Marshal(res)
+			require.NoError(b, err)
+			protobufBody, err := res.Marshal()
+			require.NoError(b, err)
+
+			encoded["json"] = append(encoded["json"], jsonBody)
+			encoded["protobuf"] = append(encoded["protobuf"], protobufBody)
+			encoded["protobuf-snappy"] = append(encoded["protobuf-snappy"], snappy.Encode(nil, protobufBody))
+		}
+
+		for _, format := range []string{"json", "protobuf", "protobuf-snappy"} {
+			header := http.Header{}
+			switch format {
+			case "json":
+				header.Set("Content-Type", jsonMimeType)
+			case "protobuf":
+				header.Set("Content-Type", protobufMimeType)
+			case "protobuf-snappy":
+				header.Set("Content-Type", protobufMimeType)
+				header.Set("Content-Encoding", compressionSnappy)
+			}
+
+			b.Run(fmt.Sprintf("shards=%d, format=%s", numShards, format), func(b *testing.B) {
+				b.ReportAllocs()
+
+				for n := 0; n < b.N; n++ {
+					responses := make([]Response, 0, numShards)
+					for _, body := range encoded[format] {
+						res, err := PrometheusCodec.DecodeResponse(context.Background(), &http.Response{
+							StatusCode:    200,
+							Header:        header,
+							Body:          io.NopCloser(bytes.NewReader(body)),
+							ContentLength: int64(len(body)),
+						}, nil, log.NewNopLogger())
+						require.NoError(b, err)
+						responses = append(responses, res)
+					}
+
+					_, err := PrometheusCodec.MergeResponse(responses...)
+					require.NoError(b, err)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"fmt"
+
+	"github.com/prometheus/common/model"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util/limiter"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// resultSizeLimiter enforces the per-tenant limits on the number of series returned by a query
+// and the size of its response. The limits are checked on the merged query result and its encoded
+// response, the size being the number of bytes of the response body returned to the client.
+type resultSizeLimiter struct {
+	maxSeries int
+	maxBytes  int
+}
+
+func newResultSizeLimiter(tenantIDs []string, limits Limits) resultSizeLimiter {
+	return resultSizeLimiter{
+		maxSeries: validation.SmallestPositiveIntPerTenant(tenantIDs, limits.MaxReturnedSeriesPerQuery),
+		maxBytes:  validation.SmallestPositiveIntPerTenant(tenantIDs, limits.MaxQueryResponseSizeBytes),
+	}
+}
+
+// checkSeries checks the number of series returned by the query result.
+func (l resultSizeLimiter) checkSeries(res Response) error {
+	if l.maxSeries > 0 && returnedSeriesCount(res) > l.maxSeries {
+		return newMaxReturnedSeriesError(l.maxSeries)
+	}
+	return nil
+}
+
+// checkEncodedSize checks the size in bytes of the encoded query response body.
+func (l resultSizeLimiter) checkEncodedSize(size int64) error {
+	if l.maxBytes > 0 && size > int64(l.maxBytes) {
+		return newMaxQueryResponseSizeError(l.maxBytes)
+	}
+	return nil
+}
+
+// returnedSeriesCount returns the number of series in the query result. Scalar and string
+// results don't count as series.
+func returnedSeriesCount(res Response) int {
+	promRes, ok := res.(*PrometheusResponse)
+	if !ok || promRes.Data == nil {
+		return 0
+	}
+
+	switch promRes.Data.ResultType {
+	case model.ValVector.String(), model.ValMatrix.String():
+		return len(promRes.Data.Result)
+	default:
+		return 0
+	}
+}
+
+func newMaxReturnedSeriesError(limit int) error {
+	return apierror.New(apierror.TypeExec, fmt.Sprintf(limiter.MaxReturnedSeriesHitMsgFormat, limit))
+}
+
+func newMaxQueryResponseSizeError(limit int) error {
+	return apierror.New(apierror.TypeExec, fmt.Sprintf(limiter.MaxQueryResponseSizeHitMsgFormat, limit))
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
@@ -29,6 +30,8 @@ import (
 	"github.com/grafana/mimir/pkg/cache"
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 const (
@@ -460,6 +463,76 @@ func filterRecentCacheExtents(req Request, maxCacheFreshness time.Duration, extr
 	return extents, nil
 }
 
+// resultsCacheTTLForEnd returns the TTL to use when caching a response whose time range ends at the input
+// timestamp (in milliseconds). A lower TTL is used if the response overlaps the out-of-order time window.
+func resultsCacheTTLForEnd(limits Limits, tenantIDs []string, end int64) time.Duration {
+	lowerTTLWithinTimePeriod := validation.MaxDurationPerTenant(tenantIDs, func(tenantID string) time.Duration {
+		return time.Duration(limits.OutOfOrderTimeWindow(tenantID))
+	})
+	if lowerTTLWithinTimePeriod > 0 && end >= time.Now().Add(-lowerTTLWithinTimePeriod).UnixMilli() {
+		return resultsCacheLowerTTL
+	}
+	return resultsCacheTTL
+}
+
+// fetchCachedResponse looks up the response for the given key in the cache. The response is expected to be
+// stored as a single extent by storeCachedResponse. Returns false on cache miss or if the cached entry can't be decoded.
+func fetchCachedResponse(ctx context.Context, c cache.Cache, key string, logger log.Logger) (Response, bool) {
+	spanLog, ctx := spanlogger.NewWithLogger(ctx, logger, "fetchCachedResponse")
+	defer spanLog.Finish()
+
+	hashedKey := cacheHashKey(key)
+	spanLog.LogKV("key", key, "hashedKey", hashedKey)
+
+	founds := c.Fetch(ctx, []string{hashedKey})
+	data, ok := founds[hashedKey]
+	if !ok {
+		return nil, false
+	}
+
+	var cached CachedResponse
+	if err := proto.Unmarshal(data, &cached); err != nil {
+		level.Error(spanLog).Log("msg", "error unmarshalling cached response", "err", err)
+		spanLog.Error(err)
+		return nil, false
+	}
+
+	// Ensure there's no hashed key collision.
+	if cached.Key != key || len(cached.Extents) != 1 {
+		return nil, false
+	}
+
+	res, err := cached.Extents[0].toResponse()
+	if err != nil {
+		level.Error(spanLog).Log("msg", "error decoding cached response", "err", err)
+		spanLog.Error(err)
+		return nil, false
+	}
+
+	spanLog.LogKV("returned bytes", len(data))
+	return res, true
+}
+
+// storeCachedResponse stores the response to the given request in the cache as a single extent.
+func storeCachedResponse(ctx context.Context, c cache.Cache, key string, req Request, res Response, ttl time.Duration, logger log.Logger) {
+	extent, err := toExtent(ctx, req, res)
+	if err != nil {
+		level.Error(logger).Log("msg", "error converting response to cache extent", "err", err)
+		return
+	}
+
+	buf, err := proto.Marshal(&CachedResponse{
+		Key:     key,
+		Extents: []Extent{extent},
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshalling cached response", "err", err)
+		return
+	}
+
+	c.Store(ctx, map[string][]byte{cacheHashKey(key): buf}, ttl)
+}
+
 func jaegerTraceID(ctx context.Context) string {
 	span := opentracing.SpanFromContext(ctx)
 	if span == nil {
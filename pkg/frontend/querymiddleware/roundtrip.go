@@ -8,6 +8,7 @@ package querymiddleware
 import (
 	"context"
 	"flag"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -40,6 +41,15 @@ type Config struct {
 	ShardedQueries         bool `yaml:"parallelize_shardable_queries"`
 	CacheUnalignedRequests bool `yaml:"cache_unaligned_requests" category:"advanced"`
 
+	CacheInstantQueries           bool          `yaml:"cache_instant_queries" category:"experimental"`
+	InstantQueriesCacheResolution time.Duration `yaml:"instant_queries_cache_resolution" category:"experimental"`
+	CacheShardedQueries           bool          `yaml:"cache_sharded_queries" category:"experimental"`
+	SpinOffSubqueries             bool          `yaml:"spin_off_subqueries" category:"experimental"`
+	SplitRemoteReadRequests       bool          `yaml:"split_remote_read_requests" category:"experimental"`
+
+	QueryResultResponseFormat      string `yaml:"query_result_response_format" category:"experimental"`
+	QueryResultResponseCompression string `yaml:"query_result_response_compression" category:"experimental"`
+
 	// CacheSplitter allows to inject a CacheSplitter to use for generating cache keys.
 	// If nil, the querymiddleware package uses a ConstSplitter with SplitQueriesByInterval.
 	CacheSplitter CacheSplitter `yaml:"-"`
@@ -55,6 +65,13 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.CacheResults, "query-frontend.cache-results", false, "Cache query results.")
 	f.BoolVar(&cfg.ShardedQueries, "query-frontend.parallelize-shardable-queries", false, "True to enable query sharding.")
 	f.BoolVar(&cfg.CacheUnalignedRequests, "query-frontend.cache-unaligned-requests", false, "Cache requests that are not step-aligned.")
+	f.BoolVar(&cfg.CacheInstantQueries, "query-frontend.cache-instant-queries", false, "Cache instant query results. When instant query splitting is enabled, each split partial query is cached separately. Requires -query-frontend.cache-results to be enabled.")
+	f.DurationVar(&cfg.InstantQueriesCacheResolution, "query-frontend.instant-queries-cache-resolution", 0, "Round down the evaluation timestamp of cached instant queries to this resolution, so that queries issued within the same interval share the same cached result. 0 to disable.")
+	f.BoolVar(&cfg.CacheShardedQueries, "query-frontend.cache-sharded-queries", false, "Cache the results of the partial queries generated by query sharding, so that queries sharing the same sharded inner expression reuse each other's results. Requires -query-frontend.cache-results and -query-frontend.parallelize-shardable-queries to be enabled.")
+	f.BoolVar(&cfg.SpinOffSubqueries, "query-frontend.spin-off-subqueries", false, "Run the inner expression of subqueries with a range of at least 1h as range queries through the query-frontend, so that they're split by interval, cached and sharded like any other range query, and evaluate the outer query in the query-frontend.")
+	f.BoolVar(&cfg.SplitRemoteReadRequests, "query-frontend.split-remote-read-requests", false, "Split each query of remote read requests by -query-frontend.split-queries-by-interval and, if -query-frontend.parallelize-shardable-queries is enabled, by series shard, and execute the partial queries in parallel across queriers.")
+	f.StringVar(&cfg.QueryResultResponseFormat, "query-frontend.query-result-response-format", formatJSON, fmt.Sprintf("Format to use when retrieving query results from queriers. Supported values: %s. Queriers not supporting the requested format respond in JSON.", strings.Join(allFormats, ", ")))
+	f.StringVar(&cfg.QueryResultResponseCompression, "query-frontend.query-result-response-compression", compressionNone, fmt.Sprintf("Compression to use when retrieving query results from queriers. Supported values: %s, or empty to disable compression.", strings.Join(allCompressions, ", ")))
 	cfg.ResultsCacheConfig.RegisterFlags(f)
 }
 
@@ -68,6 +85,21 @@ func (cfg *Config) Validate() error {
 			return errors.Wrap(err, "invalid ResultsCache config")
 		}
 	}
+	if cfg.CacheInstantQueries && !cfg.CacheResults {
+		return errors.New("-query-frontend.cache-instant-queries may only be enabled in conjunction with -query-frontend.cache-results. Please set the latter")
+	}
+	if cfg.CacheShardedQueries && (!cfg.CacheResults || !cfg.ShardedQueries) {
+		return errors.New("-query-frontend.cache-sharded-queries may only be enabled in conjunction with -query-frontend.cache-results and -query-frontend.parallelize-shardable-queries. Please set the latter")
+	}
+	if cfg.InstantQueriesCacheResolution < 0 {
+		return errors.New("-query-frontend.instant-queries-cache-resolution must not be negative")
+	}
+	if !util.StringsContain(allFormats, cfg.QueryResultResponseFormat) {
+		return fmt.Errorf("unknown query result response format '%s'. Supported values: %s", cfg.QueryResultResponseFormat, strings.Join(allFormats, ", "))
+	}
+	if cfg.QueryResultResponseCompression != compressionNone && !util.StringsContain(allCompressions, cfg.QueryResultResponseCompression) {
+		return fmt.Errorf("unknown query result response compression '%s'. Supported values: %s", cfg.QueryResultResponseCompression, strings.Join(allCompressions, ", "))
+	}
 	return nil
 }
 
@@ -175,25 +207,28 @@ func newQueryTripperware(
 		queryRangeMiddleware = append(queryRangeMiddleware, newInstrumentMiddleware("step_align", metrics, log), newStepAlignMiddleware())
 	}
 
-	// Inject the middleware to split requests by interval + results cache (if at least one of the two is enabled).
-	if cfg.SplitQueriesByInterval > 0 || cfg.CacheResults {
-		var c cache.Cache
+	// Subqueries are spun off before splitting range queries by interval, so that the range query run for
+	// a subquery spans the whole outer query time range and is split and cached only once.
+	spinOffRangeQueryIndex := len(queryRangeMiddleware)
 
-		// Init the cache client.
-		if cfg.CacheResults {
-			var err error
+	// Init the cache client.
+	var c cache.Cache
+	if cfg.CacheResults {
+		var err error
 
-			c, err = newResultsCache(cfg.ResultsCacheConfig, log, registerer)
-			if err != nil {
-				return nil, err
-			}
-			c = cache.NewCompression(cfg.ResultsCacheConfig.Compression, c, log)
+		c, err = newResultsCache(cfg.ResultsCacheConfig, log, registerer)
+		if err != nil {
+			return nil, err
 		}
+		c = cache.NewCompression(cfg.ResultsCacheConfig.Compression, c, log)
+	}
 
-		shouldCache := func(r Request) bool {
-			return !r.GetOptions().CacheDisabled
-		}
+	shouldCache := func(r Request) bool {
+		return !r.GetOptions().CacheDisabled
+	}
 
+	// Inject the middleware to split requests by interval + results cache (if at least one of the two is enabled).
+	if cfg.SplitQueriesByInterval > 0 || cfg.CacheResults {
 		splitter := cfg.CacheSplitter
 		if splitter == nil {
 			splitter = ConstSplitter(cfg.SplitQueriesByInterval)
@@ -222,6 +257,18 @@ func newQueryTripperware(
 		newSplitInstantQueryByIntervalMiddleware(limits, log, engine, registerer),
 	)
 
+	// Inject the instant query results cache after the splitting middleware, so that each split partial query is cached.
+	if cfg.CacheResults && cfg.CacheInstantQueries {
+		queryInstantMiddleware = append(
+			queryInstantMiddleware,
+			newInstrumentMiddleware("instant_query_results_cache", metrics, log),
+			newInstantQueryCacheMiddleware(limits, c, cfg.InstantQueriesCacheResolution, cacheExtractor, shouldCache, log, registerer),
+		)
+	}
+
+	// Subqueries are spun off after the instant query results cache, so that the outer query results are cached.
+	spinOffInstantQueryIndex := len(queryInstantMiddleware)
+
 	if cfg.ShardedQueries {
 		queryshardingMiddleware := newQueryShardingMiddleware(
 			log,
@@ -229,6 +276,23 @@ func newQueryTripperware(
 			limits,
 			registerer,
 		)
+
+		// Inject the cardinality estimation before the sharding middleware, so that the number of shards
+		// can be chosen based on the estimated cardinality of each (split) query.
+		if cfg.CacheResults {
+			cardinalityEstimationMiddleware := newCardinalityEstimationMiddleware(c, limits, log, registerer)
+			queryRangeMiddleware = append(
+				queryRangeMiddleware,
+				newInstrumentMiddleware("cardinality_estimation", metrics, log),
+				cardinalityEstimationMiddleware,
+			)
+			queryInstantMiddleware = append(
+				queryInstantMiddleware,
+				newInstrumentMiddleware("cardinality_estimation", metrics, log),
+				cardinalityEstimationMiddleware,
+			)
+		}
+
 		queryRangeMiddleware = append(
 			queryRangeMiddleware,
 			newInstrumentMiddleware("querysharding", metrics, log),
@@ -239,6 +303,21 @@ func newQueryTripperware(
 			newInstrumentMiddleware("querysharding", metrics, log),
 			queryshardingMiddleware,
 		)
+
+		// Inject the sharded queries results cache after the sharding middleware, so that each partial query is cached.
+		if cfg.CacheResults && cfg.CacheShardedQueries {
+			shardedQueriesCacheMiddleware := newShardedQueriesCacheMiddleware(limits, c, cfg.CacheUnalignedRequests, cacheExtractor, shouldCache, log, registerer)
+			queryRangeMiddleware = append(
+				queryRangeMiddleware,
+				newInstrumentMiddleware("sharded_queries_results_cache", metrics, log),
+				shardedQueriesCacheMiddleware,
+			)
+			queryInstantMiddleware = append(
+				queryInstantMiddleware,
+				newInstrumentMiddleware("sharded_queries_results_cache", metrics, log),
+				shardedQueriesCacheMiddleware,
+			)
+		}
 	}
 
 	if cfg.MaxRetries > 0 {
@@ -247,18 +326,53 @@ func newQueryTripperware(
 		queryInstantMiddleware = append(queryInstantMiddleware, newInstrumentMiddleware("retry", metrics, log), newRetryMiddleware(log, cfg.MaxRetries, retryMiddlewareMetrics))
 	}
 
+	var spinOffMetrics spinOffSubqueriesMetrics
+	if cfg.SpinOffSubqueries {
+		spinOffMetrics = newSpinOffSubqueriesMetrics(registerer)
+	}
+
+	var remoteReadMetrics *remoteReadMetrics
+	if cfg.SplitRemoteReadRequests {
+		remoteReadMetrics = newRemoteReadMetrics(registerer)
+	}
+
 	return func(next http.RoundTripper) http.RoundTripper {
-		queryrange := newLimitedParallelismRoundTripper(next, codec, limits, queryRangeMiddleware...)
+		queryRangeMiddleware, queryInstantMiddleware := queryRangeMiddleware, queryInstantMiddleware
+
+		var queryrange http.RoundTripper
+		if cfg.SpinOffSubqueries {
+			// The spun off subqueries are run through the whole range query middlewares chain,
+			// like any other range query received by the query-frontend.
+			rangeQueryHandler := roundTripperHandler{
+				logger: log,
+				next: RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					return queryrange.RoundTrip(r)
+				}),
+				codec: codec,
+			}
+			spinOffMiddleware := newSpinOffSubqueriesMiddleware(rangeQueryHandler, log, engine, engineOpts.NoStepSubqueryIntervalFn, spinOffMetrics)
+
+			queryRangeMiddleware = insertMiddlewares(queryRangeMiddleware, spinOffRangeQueryIndex, newInstrumentMiddleware("spin_off_subqueries", metrics, log), spinOffMiddleware)
+			queryInstantMiddleware = insertMiddlewares(queryInstantMiddleware, spinOffInstantQueryIndex, newInstrumentMiddleware("spin_off_subqueries", metrics, log), spinOffMiddleware)
+		}
+
+		queryrange = newLimitedParallelismRoundTripper(next, codec, limits, queryRangeMiddleware...)
 		instant := defaultInstantQueryParamsRoundTripper(
 			newLimitedParallelismRoundTripper(next, codec, limits, queryInstantMiddleware...),
 			time.Now,
 		)
+		remoteRead := next
+		if cfg.SplitRemoteReadRequests {
+			remoteRead = newRemoteReadRoundTripper(next, cfg.SplitQueriesByInterval, cfg.ShardedQueries, limits, log, remoteReadMetrics)
+		}
 		return RoundTripFunc(func(r *http.Request) (*http.Response, error) {
 			switch {
 			case isRangeQuery(r.URL.Path):
 				return queryrange.RoundTrip(r)
 			case isInstantQuery(r.URL.Path):
 				return instant.RoundTrip(r)
+			case isRemoteRead(r.URL.Path):
+				return remoteRead.RoundTrip(r)
 			default:
 				return next.RoundTrip(r)
 			}
@@ -266,6 +380,14 @@ func newQueryTripperware(
 	}, nil
 }
 
+// insertMiddlewares returns a copy of middlewares with the input ones inserted at index.
+func insertMiddlewares(middlewares []Middleware, index int, inserted ...Middleware) []Middleware {
+	out := make([]Middleware, 0, len(middlewares)+len(inserted))
+	out = append(out, middlewares[:index]...)
+	out = append(out, inserted...)
+	return append(out, middlewares[index:]...)
+}
+
 func newActiveUsersTripperware(logger log.Logger, registerer prometheus.Registerer) Tripperware {
 	// Per tenant query metrics.
 	queriesPerTenant := promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
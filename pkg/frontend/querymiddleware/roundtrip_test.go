@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/client_golang/prometheus"
@@ -32,6 +33,49 @@ import (
 	"github.com/grafana/mimir/pkg/mimirpb"
 )
 
+func TestConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		config        func(cfg *Config)
+		expectedError string
+	}{
+		"should pass with default config": {
+			config: func(cfg *Config) {},
+		},
+		"should pass with protobuf query result response format and snappy compression": {
+			config: func(cfg *Config) {
+				cfg.QueryResultResponseFormat = formatProtobuf
+				cfg.QueryResultResponseCompression = compressionSnappy
+			},
+		},
+		"should fail with unknown query result response format": {
+			config: func(cfg *Config) {
+				cfg.QueryResultResponseFormat = "xml"
+			},
+			expectedError: "unknown query result response format 'xml'. Supported values: json, protobuf",
+		},
+		"should fail with unknown query result response compression": {
+			config: func(cfg *Config) {
+				cfg.QueryResultResponseCompression = "gzip"
+			},
+			expectedError: "unknown query result response compression 'gzip'. Supported values: snappy",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := Config{}
+			flagext.DefaultValues(&cfg)
+			testData.config(&cfg)
+
+			if testData.expectedError != "" {
+				assert.EqualError(t, cfg.Validate(), testData.expectedError)
+			} else {
+				assert.NoError(t, cfg.Validate())
+			}
+		})
+	}
+}
+
 func TestRangeTripperware(t *testing.T) {
 	var (
 		query        = "/api/v1/query_range?end=1536716880&query=sum%28container_memory_rss%29+by+%28namespace%29&start=1536673680&step=120"
@@ -176,6 +220,70 @@ func TestInstantTripperware(t *testing.T) {
 	})
 }
 
+func TestInstantTripperware_SpinOffSubqueries(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+
+	tw, err := NewTripperware(
+		Config{
+			SpinOffSubqueries: true,
+		},
+		log.NewNopLogger(),
+		mockLimits{},
+		PrometheusCodec,
+		nil,
+		promql.EngineOpts{
+			Logger:     log.NewNopLogger(),
+			Reg:        nil,
+			MaxSamples: 1000,
+			Timeout:    time.Minute,
+		},
+		nil,
+	)
+	require.NoError(t, err)
+
+	ts := time.Date(2021, 1, 2, 3, 4, 0, 0, time.UTC)
+	rt := RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		// The spun off subquery is expected to be run as a range query.
+		if !isRangeQuery(r.URL.Path) || r.URL.Query().Get("query") != "foo" {
+			return nil, fmt.Errorf("unexpected request %s", r.URL)
+		}
+
+		req, err := PrometheusCodec.DecodeRequest(r.Context(), r)
+		if err != nil {
+			return nil, err
+		}
+
+		// Return a sample for each step of the requested time range.
+		var samples []mimirpb.Sample
+		for t := req.GetStart(); t <= req.GetEnd(); t += req.GetStep() {
+			samples = append(samples, mimirpb.Sample{TimestampMs: t, Value: 1})
+		}
+
+		return PrometheusCodec.EncodeResponse(r.Context(), &PrometheusResponse{
+			Status: "success",
+			Data: &PrometheusData{
+				ResultType: "matrix",
+				Result: []SampleStream{
+					{
+						Labels:  []mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}},
+						Samples: samples,
+					},
+				},
+			},
+		})
+	})
+
+	queryClient, err := api.NewClient(api.Config{Address: "http://localhost", RoundTripper: tw(rt)})
+	require.NoError(t, err)
+	api := v1.NewAPI(queryClient)
+
+	res, _, err := api.Query(ctx, `count_over_time(foo[2h:1m])`, ts)
+	require.NoError(t, err)
+	require.Equal(t, model.Vector{
+		{Metric: model.Metric{"foo": "bar"}, Timestamp: model.TimeFromUnixNano(ts.UnixNano()), Value: 121},
+	}, res)
+}
+
 func TestTripperware_Metrics(t *testing.T) {
 	tests := map[string]struct {
 		path                    string
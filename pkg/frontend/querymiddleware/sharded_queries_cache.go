@@ -0,0 +1,232 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/dskit/tenant"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/storage/sharding"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+var errMultipleShardsInQuery = errors.New("the query selects more than one shard")
+
+type shardedQueriesCacheMiddlewareMetrics struct {
+	queryResultCacheAttemptedCount prometheus.Counter
+	queryResultCacheHitsCount      prometheus.Counter
+	queryResultCacheSkippedCount   *prometheus.CounterVec
+}
+
+func newShardedQueriesCacheMiddlewareMetrics(reg prometheus.Registerer) *shardedQueriesCacheMiddlewareMetrics {
+	m := &shardedQueriesCacheMiddlewareMetrics{
+		queryResultCacheAttemptedCount: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_sharded_query_result_cache_attempted_total",
+			Help: "Total number of sharded partial queries that were attempted to be fetched from cache.",
+		}),
+		queryResultCacheHitsCount: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_sharded_query_result_cache_hits_total",
+			Help: "Total number of sharded partial queries that were fetched from cache.",
+		}),
+		queryResultCacheSkippedCount: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_frontend_sharded_query_result_cache_skipped_total",
+			Help: "Total number of times a sharded partial query was not cacheable because of a reason.",
+		}, []string{"reason"}),
+	}
+
+	// Initialize known label values.
+	for _, reason := range []string{notCachableReasonUnalignedTimeRange, notCachableReasonTooNew,
+		notCachableReasonModifiersNotCachable} {
+		m.queryResultCacheSkippedCount.WithLabelValues(reason)
+	}
+
+	return m
+}
+
+// shardedQueriesCacheMiddleware is a Middleware that runs the partial queries generated by the query sharding
+// middleware through the results cache. Partial queries are cached by their normalized expression, shard and
+// time range, so that different queries sharing the same sharded inner aggregation reuse each other's results.
+// Requests which are not sharded partial queries are passed through as is.
+type shardedQueriesCacheMiddleware struct {
+	next    Handler
+	limits  Limits
+	logger  log.Logger
+	metrics *shardedQueriesCacheMiddlewareMetrics
+
+	cache                  cache.Cache
+	cacheUnalignedRequests bool
+	extractor              Extractor
+	shouldCacheReq         shouldCacheFn
+}
+
+// newShardedQueriesCacheMiddleware makes a new shardedQueriesCacheMiddleware.
+func newShardedQueriesCacheMiddleware(
+	limits Limits,
+	cache cache.Cache,
+	cacheUnalignedRequests bool,
+	extractor Extractor,
+	shouldCacheReq shouldCacheFn,
+	logger log.Logger,
+	reg prometheus.Registerer) Middleware {
+	metrics := newShardedQueriesCacheMiddlewareMetrics(reg)
+
+	return MiddlewareFunc(func(next Handler) Handler {
+		return &shardedQueriesCacheMiddleware{
+			next:                   next,
+			limits:                 limits,
+			logger:                 logger,
+			metrics:                metrics,
+			cache:                  cache,
+			cacheUnalignedRequests: cacheUnalignedRequests,
+			extractor:              extractor,
+			shouldCacheReq:         shouldCacheReq,
+		}
+	})
+}
+
+func (c *shardedQueriesCacheMiddleware) Do(ctx context.Context, req Request) (Response, error) {
+	if c.shouldCacheReq != nil && !c.shouldCacheReq(req) {
+		return c.next.Do(ctx, req)
+	}
+
+	normalizedQuery, shard, err := normalizeShardedQuery(req.GetQuery())
+	if err != nil {
+		level.Debug(c.logger).Log("msg", "unable to normalize sharded query, skipping the cache", "query", req.GetQuery(), "err", err)
+		return c.next.Do(ctx, req)
+	}
+	if shard == nil {
+		// Not a sharded partial query.
+		return c.next.Do(ctx, req)
+	}
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	c.metrics.queryResultCacheAttemptedCount.Inc()
+
+	maxCacheFreshness := validation.MaxDurationPerTenant(tenantIDs, c.limits.MaxCacheFreshness)
+	maxCacheTime := int64(model.Now().Add(-maxCacheFreshness))
+
+	if cachable, reason := isShardedRequestCachable(req, maxCacheTime, c.cacheUnalignedRequests, c.logger); !cachable {
+		c.metrics.queryResultCacheSkippedCount.WithLabelValues(reason).Inc()
+		return c.next.Do(ctx, req)
+	}
+
+	key := generateShardedQueryCacheKey(tenant.JoinTenantIDs(tenantIDs), normalizedQuery, *shard, req)
+	queryStats := stats.FromContext(ctx)
+	queryStats.AddResultsCacheLookups(1)
+	if cached, ok := fetchCachedResponse(ctx, c.cache, key, c.logger); ok {
+		c.metrics.queryResultCacheHitsCount.Inc()
+		queryStats.AddResultsCacheHits(1)
+		return cached, nil
+	}
+
+	res, err := c.next.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if isResponseCachable(res, c.logger) {
+		ttl := resultsCacheTTLForEnd(c.limits, tenantIDs, req.GetEnd())
+		storeCachedResponse(ctx, c.cache, key, req, c.extractor.ResponseWithoutHeaders(res), ttl, c.logger)
+	}
+
+	return res, nil
+}
+
+// isShardedRequestCachable says whether the sharded partial query request is eligible for caching.
+// Differently from isRequestCachable, the whole time range must be older than the max cache freshness
+// because the response is cached as is, without filtering out recent samples.
+func isShardedRequestCachable(req Request, maxCacheTime int64, cacheUnalignedRequests bool, logger log.Logger) (cachable bool, reason string) {
+	if !cacheUnalignedRequests && !isRequestStepAligned(req) {
+		return false, notCachableReasonUnalignedTimeRange
+	}
+
+	if req.GetEnd() > maxCacheTime {
+		return false, notCachableReasonTooNew
+	}
+
+	if !areEvaluationTimeModifiersCachable(req, maxCacheTime, logger) {
+		return false, notCachableReasonModifiersNotCachable
+	}
+
+	return true, ""
+}
+
+// normalizeShardedQuery parses the input query and returns its canonical representation, without the
+// shard label matcher, together with the selected shard. The returned shard is nil if the query is not
+// a sharded partial query.
+func normalizeShardedQuery(query string) (string, *sharding.ShardSelector, error) {
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var (
+		shard      *sharding.ShardSelector
+		inspectErr error
+	)
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		selector, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+
+		selectorShard, matchers, err := sharding.RemoveShardFromMatchers(selector.LabelMatchers)
+		if err != nil {
+			inspectErr = err
+			return err
+		}
+		if selectorShard != nil {
+			if shard != nil && *shard != *selectorShard {
+				inspectErr = errMultipleShardsInQuery
+				return inspectErr
+			}
+			shard = selectorShard
+		}
+
+		sortLabelMatchers(matchers)
+		selector.LabelMatchers = matchers
+		return nil
+	})
+	if inspectErr != nil {
+		return "", nil, inspectErr
+	}
+
+	return expr.String(), shard, nil
+}
+
+// sortLabelMatchers sorts the input matchers by name, type and value.
+func sortLabelMatchers(matchers []*labels.Matcher) {
+	sort.Slice(matchers, func(i, j int) bool {
+		if matchers[i].Name != matchers[j].Name {
+			return matchers[i].Name < matchers[j].Name
+		}
+		if matchers[i].Type != matchers[j].Type {
+			return matchers[i].Type < matchers[j].Type
+		}
+		return matchers[i].Value < matchers[j].Value
+	})
+}
+
+// generateShardedQueryCacheKey generates the results cache key for a sharded partial query request.
+func generateShardedQueryCacheKey(userID, normalizedQuery string, shard sharding.ShardSelector, req Request) string {
+	return fmt.Sprintf("%s:sharded:%s:%s:%d:%d:%d", userID, shard.LabelValue(), normalizedQuery, req.GetStart(), req.GetEnd(), req.GetStep())
+}
@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/storage/sharding"
+)
+
+func TestNormalizeShardedQuery(t *testing.T) {
+	tests := map[string]struct {
+		query              string
+		expectedNormalized string
+		expectedShard      *sharding.ShardSelector
+		expectedErr        bool
+	}{
+		"not sharded query": {
+			query:              `sum(rate(metric{job="a"}[5m]))`,
+			expectedNormalized: `sum(rate(metric{job="a"}[5m]))`,
+		},
+		"sharded query": {
+			query:              `sum by (pod) (rate(metric{__query_shard__="2_of_4",job="a"}[5m]))`,
+			expectedNormalized: `sum by (pod) (rate(metric{job="a"}[5m]))`,
+			expectedShard:      &sharding.ShardSelector{ShardIndex: 1, ShardCount: 4},
+		},
+		"sharded query with matchers in a different order and formatting": {
+			query:              `sum   by (pod)(rate(metric{pod=~"api-.*", job="a", __query_shard__="2_of_4"}[5m]))`,
+			expectedNormalized: `sum by (pod) (rate(metric{job="a",pod=~"api-.*"}[5m]))`,
+			expectedShard:      &sharding.ShardSelector{ShardIndex: 1, ShardCount: 4},
+		},
+		"sharded query selecting different shards": {
+			query:       `sum(metric{__query_shard__="1_of_4"}) + sum(metric{__query_shard__="2_of_4"})`,
+			expectedErr: true,
+		},
+		"invalid shard": {
+			query:       `sum(metric{__query_shard__="5_of_4"})`,
+			expectedErr: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			normalized, shard, err := normalizeShardedQuery(testData.query)
+			if testData.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedNormalized, normalized)
+			assert.Equal(t, testData.expectedShard, shard)
+		})
+	}
+}
+
+func TestShardedQueriesCacheMiddleware(t *testing.T) {
+	var (
+		start = time.Now().Add(-2 * time.Hour).Truncate(time.Minute)
+		end   = start.Add(time.Hour)
+		step  = time.Minute
+	)
+
+	expectedResponse := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: model.ValMatrix.String(),
+			Result: []SampleStream{
+				{
+					Labels:  []mimirpb.LabelAdapter{{Name: "pod", Value: "api-1"}},
+					Samples: []mimirpb.Sample{{Value: 137, TimestampMs: start.UnixMilli()}},
+				},
+			},
+		},
+	}
+
+	newRequest := func(query string, start, end time.Time) Request {
+		return &PrometheusRangeQueryRequest{
+			Path:  "/api/v1/query_range",
+			Start: start.UnixMilli(),
+			End:   end.UnixMilli(),
+			Step:  step.Milliseconds(),
+			Query: query,
+		}
+	}
+
+	tests := map[string]struct {
+		requests            []Request
+		expectedDownstreams int
+		expectedHits        int
+		expectedSkipReason  string
+	}{
+		"should not cache non-sharded queries": {
+			requests: []Request{
+				newRequest(`sum(metric)`, start, end),
+				newRequest(`sum(metric)`, start, end),
+			},
+			expectedDownstreams: 2,
+		},
+		"should reuse the cached partial query with the same shard and time range": {
+			requests: []Request{
+				newRequest(`sum by (pod) (metric{job="a",__query_shard__="1_of_2"})`, start, end),
+				newRequest(`sum by (pod) (metric{__query_shard__="1_of_2",job="a"})`, start, end),
+			},
+			expectedDownstreams: 1,
+			expectedHits:        1,
+		},
+		"should not reuse the cached partial query for a different shard": {
+			requests: []Request{
+				newRequest(`sum by (pod) (metric{__query_shard__="1_of_2"})`, start, end),
+				newRequest(`sum by (pod) (metric{__query_shard__="2_of_2"})`, start, end),
+			},
+			expectedDownstreams: 2,
+		},
+		"should not reuse the cached partial query for a different time range": {
+			requests: []Request{
+				newRequest(`sum by (pod) (metric{__query_shard__="1_of_2"})`, start, end),
+				newRequest(`sum by (pod) (metric{__query_shard__="1_of_2"})`, start, end.Add(step)),
+			},
+			expectedDownstreams: 2,
+		},
+		"should not cache partial queries whose time range ends after the max cache freshness": {
+			requests: []Request{
+				newRequest(`sum by (pod) (metric{__query_shard__="1_of_2"})`, start, time.Now().Truncate(time.Minute)),
+				newRequest(`sum by (pod) (metric{__query_shard__="1_of_2"})`, start, time.Now().Truncate(time.Minute)),
+			},
+			expectedDownstreams: 2,
+			expectedSkipReason:  notCachableReasonTooNew,
+		},
+		"should not cache partial queries which are not step aligned": {
+			requests: []Request{
+				newRequest(`sum by (pod) (metric{__query_shard__="1_of_2"})`, start.Add(time.Second), end),
+				newRequest(`sum by (pod) (metric{__query_shard__="1_of_2"})`, start.Add(time.Second), end),
+			},
+			expectedDownstreams: 2,
+			expectedSkipReason:  notCachableReasonUnalignedTimeRange,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			mw := newShardedQueriesCacheMiddleware(
+				mockLimits{maxCacheFreshness: 10 * time.Minute},
+				cache.NewMockCache(),
+				false,
+				PrometheusResponseExtractor{},
+				resultsCacheAlwaysEnabled,
+				log.NewNopLogger(),
+				prometheus.NewPedanticRegistry(),
+			)
+
+			downstreamReqs := 0
+			handler := mw.Wrap(HandlerFunc(func(_ context.Context, req Request) (Response, error) {
+				downstreamReqs++
+				return expectedResponse, nil
+			}))
+
+			ctx := user.InjectOrgID(context.Background(), "user-1")
+			for _, req := range testData.requests {
+				res, err := handler.Do(ctx, req)
+				require.NoError(t, err)
+				assert.Equal(t, expectedResponse, res)
+			}
+
+			assert.Equal(t, testData.expectedDownstreams, downstreamReqs)
+
+			metrics := handler.(*shardedQueriesCacheMiddleware).metrics
+			assert.Equal(t, float64(testData.expectedHits), testutil.ToFloat64(metrics.queryResultCacheHitsCount))
+			if testData.expectedSkipReason != "" {
+				assert.Equal(t, float64(len(testData.requests)), testutil.ToFloat64(metrics.queryResultCacheSkippedCount.WithLabelValues(testData.expectedSkipReason)))
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/frontend/querymiddleware/astmapper"
+	"github.com/grafana/mimir/pkg/storage/lazyquery"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+const skippedReasonNoSubqueries = "no-subqueries"
+
+// spinOffSubqueriesMiddleware is a Middleware that runs the inner expression of long subqueries as range queries
+// through the range query handler, so that they can be split by interval, cached and sharded independently from
+// the outer query, which is then evaluated in the query-frontend on top of their results.
+type spinOffSubqueriesMiddleware struct {
+	next              Handler
+	rangeQueryHandler Handler
+	logger            log.Logger
+
+	engine                   *promql.Engine
+	noStepSubqueryIntervalFn func(rangeMillis int64) int64
+
+	metrics spinOffSubqueriesMetrics
+}
+
+type spinOffSubqueriesMetrics struct {
+	spinOffAttempts   prometheus.Counter
+	spinOffSuccesses  prometheus.Counter
+	spinOffSkipped    *prometheus.CounterVec
+	spunOffSubqueries prometheus.Counter
+}
+
+func newSpinOffSubqueriesMetrics(registerer prometheus.Registerer) spinOffSubqueriesMetrics {
+	m := spinOffSubqueriesMetrics{
+		spinOffAttempts: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_subquery_spin_off_attempted_total",
+			Help: "Total number of queries the query-frontend attempted to spin off subqueries from.",
+		}),
+		spinOffSuccesses: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_subquery_spin_off_succeeded_total",
+			Help: "Total number of queries the query-frontend successfully spun off subqueries from.",
+		}),
+		spinOffSkipped: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_frontend_subquery_spin_off_skipped_total",
+			Help: "Total number of queries the query-frontend skipped or failed to spin off subqueries from.",
+		}, []string{"reason"}),
+		spunOffSubqueries: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_spun_off_subqueries_total",
+			Help: "Total number of subqueries spun off as range queries.",
+		}),
+	}
+
+	// Initialize known label values.
+	for _, reason := range []string{skippedReasonParsingFailed, skippedReasonMappingFailed, skippedReasonNoSubqueries} {
+		m.spinOffSkipped.WithLabelValues(reason)
+	}
+
+	return m
+}
+
+// newSpinOffSubqueriesMiddleware makes a new spinOffSubqueriesMiddleware. The spun off subqueries are run
+// through rangeQueryHandler, while any other part of the query is run through the next handler.
+func newSpinOffSubqueriesMiddleware(
+	rangeQueryHandler Handler,
+	logger log.Logger,
+	engine *promql.Engine,
+	noStepSubqueryIntervalFn func(rangeMillis int64) int64,
+	metrics spinOffSubqueriesMetrics) Middleware {
+	return MiddlewareFunc(func(next Handler) Handler {
+		return &spinOffSubqueriesMiddleware{
+			next:                     next,
+			rangeQueryHandler:        rangeQueryHandler,
+			logger:                   logger,
+			engine:                   engine,
+			noStepSubqueryIntervalFn: noStepSubqueryIntervalFn,
+			metrics:                  metrics,
+		}
+	})
+}
+
+func (s *spinOffSubqueriesMiddleware) Do(ctx context.Context, req Request) (Response, error) {
+	logger := log.With(s.logger, "query", req.GetQuery(), "query_start", req.GetStart(), "query_end", req.GetEnd())
+
+	spanLog, ctx := spanlogger.NewWithLogger(ctx, logger, "spinOffSubqueriesMiddleware.Do")
+	defer spanLog.Span.Finish()
+
+	s.metrics.spinOffAttempts.Inc()
+
+	mapperStats := astmapper.NewSubquerySpinOffMapperStats()
+	mapperCtx, cancel := context.WithTimeout(ctx, shardingTimeout)
+	defer cancel()
+	mapper := astmapper.NewSubquerySpinOffMapper(mapperCtx, s.noStepSubqueryIntervalFn, mapperStats)
+
+	expr, err := parser.ParseExpr(req.GetQuery())
+	if err != nil {
+		level.Warn(spanLog).Log("msg", "failed to parse query", "err", err)
+		s.metrics.spinOffSkipped.WithLabelValues(skippedReasonParsingFailed).Inc()
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	spinOffQuery, err := mapper.Map(expr)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			level.Error(spanLog).Log("msg", "timeout while spinning off subqueries, please fill in a bug report with this query, falling back to try executing without spinning off subqueries", "err", err)
+		} else {
+			level.Warn(spanLog).Log("msg", "failed to map the input query, falling back to try executing without spinning off subqueries", "err", err)
+		}
+		s.metrics.spinOffSkipped.WithLabelValues(skippedReasonMappingFailed).Inc()
+		return s.next.Do(ctx, req)
+	}
+
+	if mapperStats.GetSpunOffSubqueries() == 0 {
+		level.Debug(spanLog).Log("msg", "input query has no subqueries to spin off, falling back to try executing without spinning off subqueries")
+		s.metrics.spinOffSkipped.WithLabelValues(skippedReasonNoSubqueries).Inc()
+		return s.next.Do(ctx, req)
+	}
+
+	level.Debug(spanLog).Log("msg", "subqueries have been spun off", "rewritten", spinOffQuery, "spun_off_subqueries", mapperStats.GetSpunOffSubqueries())
+
+	// Update metrics.
+	s.metrics.spinOffSuccesses.Inc()
+	s.metrics.spunOffSubqueries.Add(float64(mapperStats.GetSpunOffSubqueries()))
+
+	req = req.WithQuery(spinOffQuery.String())
+	queryable := newSpinOffSubqueriesQueryable(req, s.next, s.rangeQueryHandler)
+
+	qry, err := newQuery(req, s.engine, lazyquery.NewLazyQueryable(queryable))
+	if err != nil {
+		level.Warn(spanLog).Log("msg", "failed to create new query from request with spun off subqueries", "err", err)
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	res := qry.Exec(ctx)
+	extracted, err := promqlResultToSamples(res)
+	if err != nil {
+		level.Warn(spanLog).Log("msg", "failed to execute query with spun off subqueries", "err", err)
+		return nil, mapEngineError(err)
+	}
+	return &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: string(res.Value.Type()),
+			Result:     extracted,
+		},
+		Headers: queryable.getResponseHeaders(),
+	}, nil
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/grafana/dskit/concurrency"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/grafana/mimir/pkg/frontend/querymiddleware/astmapper"
+)
+
+// maxSpunOffSubqueryPoints is the maximum number of points per series each range query run for a spun off subquery
+// can return. Longer subqueries are run through multiple range queries. It matches the limit enforced when decoding
+// range query requests.
+const maxSpunOffSubqueryPoints = 11000
+
+var errMissingSubqueryStep = errors.New("missing spun off subquery step")
+
+// spinOffSubqueriesQueryable is an implementor of the Queryable interface, which runs spun off subqueries
+// as range queries through the range query handler and any other embedded query through the downstream handler.
+type spinOffSubqueriesQueryable struct {
+	req               Request
+	rangeQueryHandler Handler
+	embedded          *shardedQueryable
+}
+
+// newSpinOffSubqueriesQueryable makes a new spinOffSubqueriesQueryable. Like shardedQueryable, we expect a new
+// queryable is created for each query.
+func newSpinOffSubqueriesQueryable(req Request, next, rangeQueryHandler Handler) *spinOffSubqueriesQueryable {
+	return &spinOffSubqueriesQueryable{
+		req:               req,
+		rangeQueryHandler: rangeQueryHandler,
+		embedded:          newShardedQueryable(req, next),
+	}
+}
+
+// Querier implements storage.Queryable.
+func (q *spinOffSubqueriesQueryable) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	embedded, err := q.embedded.Querier(ctx, mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &spinOffSubqueriesQuerier{
+		Querier:           embedded,
+		ctx:               ctx,
+		req:               q.req,
+		rangeQueryHandler: q.rangeQueryHandler,
+		responseHeaders:   q.embedded.responseHeaders,
+	}, nil
+}
+
+// getResponseHeaders returns the merged response headers received when running the spun off subqueries
+// and the embedded queries.
+func (q *spinOffSubqueriesQueryable) getResponseHeaders() []*PrometheusResponseHeader {
+	return q.embedded.getResponseHeaders()
+}
+
+// spinOffSubqueriesQuerier implements the storage.Querier interface. It runs the subquery from the
+// astmapper.SubqueryMetricName metric labels as range queries, and delegates the selection of any other
+// metric to the embedded queries querier.
+type spinOffSubqueriesQuerier struct {
+	storage.Querier
+
+	ctx               context.Context
+	req               Request
+	rangeQueryHandler Handler
+
+	// Keep track of response headers received when running spun off subqueries.
+	responseHeaders *responseHeadersTracker
+}
+
+// Select implements storage.Querier.
+func (q *spinOffSubqueriesQuerier) Select(sorted bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	var isSubquery bool
+	var query, step string
+	for _, matcher := range matchers {
+		switch matcher.Name {
+		case labels.MetricName:
+			isSubquery = matcher.Value == astmapper.SubqueryMetricName
+		case astmapper.SubqueryQueryLabelName:
+			query = matcher.Value
+		case astmapper.SubqueryStepLabelName:
+			step = matcher.Value
+		}
+	}
+
+	if !isSubquery {
+		return q.Querier.Select(sorted, hints, matchers...)
+	}
+	if query == "" {
+		return storage.ErrSeriesSet(errMissingEmbeddedQuery)
+	}
+	if step == "" {
+		return storage.ErrSeriesSet(errMissingSubqueryStep)
+	}
+	if hints == nil {
+		return storage.ErrSeriesSet(errors.New("missing select hints for spun off subquery"))
+	}
+
+	stepDuration, err := time.ParseDuration(step)
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+
+	return q.handleSubquery(query, stepDuration.Milliseconds(), hints)
+}
+
+// handleSubquery runs the subquery inner expression as range queries over the time range selected by hints.
+// Like PromQL engine does for subqueries, the range queries are evaluated at timestamps aligned to the step.
+func (q *spinOffSubqueriesQuerier) handleSubquery(query string, step int64, hints *storage.SelectHints) storage.SeriesSet {
+	// Start with the first timestamp after the selected start that is aligned with the step.
+	start := step * (hints.Start / step)
+	if start < hints.Start {
+		start += step
+	}
+	end := step * (hints.End / step)
+	if end < start {
+		return storage.EmptySeriesSet()
+	}
+
+	reqs := make([]Request, 0, (end-start)/(step*maxSpunOffSubqueryPoints)+1)
+	for reqStart := start; reqStart <= end; reqStart += step * maxSpunOffSubqueryPoints {
+		reqEnd := reqStart + step*(maxSpunOffSubqueryPoints-1)
+		if reqEnd > end {
+			reqEnd = end
+		}
+
+		reqs = append(reqs, &PrometheusRangeQueryRequest{
+			Path:    q.rangeQueryPath(),
+			Start:   reqStart,
+			End:     reqEnd,
+			Step:    step,
+			Query:   query,
+			Options: q.req.GetOptions(),
+		})
+	}
+
+	resps := make([]Response, len(reqs))
+	err := concurrency.ForEachJob(q.ctx, len(reqs), len(reqs), func(ctx context.Context, idx int) error {
+		resp, err := q.rangeQueryHandler.Do(ctx, reqs[idx])
+		if err != nil {
+			return err
+		}
+		if _, err := responseToSamples(resp); err != nil {
+			return err
+		}
+		resps[idx] = resp // No mutex is needed since each job writes its own index. This is like writing separate variables.
+
+		q.responseHeaders.mergeHeaders(resp.(*PrometheusResponse).Headers)
+		return nil
+	})
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+
+	merged, err := PrometheusCodec.MergeResponse(resps...)
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+
+	// The samples are the results of the subquery inner expression, which can only be selected through
+	// range vector selectors. No stale markers need to be injected, so hints aren't passed.
+	return newSeriesSetFromEmbeddedQueriesResults([][]SampleStream{merged.(*PrometheusResponse).Data.Result}, nil)
+}
+
+// rangeQueryPath returns the path of the range queries run for spun off subqueries.
+func (q *spinOffSubqueriesQuerier) rangeQueryPath() string {
+	switch r := q.req.(type) {
+	case *PrometheusRangeQueryRequest:
+		return r.Path
+	case *PrometheusInstantQueryRequest:
+		return strings.TrimSuffix(r.Path, instantQueryPathSuffix) + queryRangePathSuffix
+	default:
+		return queryRangePathSuffix
+	}
+}
@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+func TestSpinOffSubqueriesCorrectness(t *testing.T) {
+	var (
+		numSeries          = 100
+		numStaleSeries     = 10
+		numHistograms      = 10
+		numStaleHistograms = 2
+		histogramBuckets   = []float64{1.0, 2.0, 4.0, 10.0, 100.0, math.Inf(1)}
+	)
+
+	tests := map[string]struct {
+		query                     string
+		instantQueryOnly          bool
+		expectedSpunOffSubqueries int
+		expectedMinRangeQueries   int
+	}{
+		"max_over_time of rate": {
+			query:                     `max_over_time(rate(metric_counter[5m])[1d:1m])`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"avg_over_time of sum by": {
+			query:                     `avg_over_time(sum by(group_1) (rate(metric_counter[5m]))[2h:5m])`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"subquery with unaligned step": {
+			query:                     `min_over_time(metric_counter[90m:7m])`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"subquery without step": {
+			query:                     `sum_over_time(metric_counter[3h:])`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"subquery with offset": {
+			query:                     `max_over_time(rate(metric_counter[5m])[2h:1m] offset 1h)`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"subquery with @ modifier": {
+			query:                     fmt.Sprintf(`max_over_time(rate(metric_counter[5m])[2h:1m] @ %d)`, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()),
+			expectedSpunOffSubqueries: 1,
+		},
+		"subquery spun off to multiple range queries": {
+			query:                     `count_over_time(metric_counter[4h:1s])`,
+			expectedSpunOffSubqueries: 1,
+			expectedMinRangeQueries:   2,
+		},
+		"nested subqueries": {
+			query:                     `max_over_time(deriv(rate(metric_counter[5m])[30m:1m])[3h:5m])`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"subquery returned as is": {
+			query:                     `rate(metric_counter[5m])[1h:1m]`,
+			instantQueryOnly:          true,
+			expectedSpunOffSubqueries: 1,
+		},
+		"binary expression with a subquery and an embedded query": {
+			query:                     `sum(max_over_time(rate(metric_counter[5m])[2h:1m])) / sum(rate(metric_counter[5m]))`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"binary expression with two subqueries": {
+			query:                     `max_over_time(metric_counter[2h:1m]) - min_over_time(metric_counter[2h:1m])`,
+			expectedSpunOffSubqueries: 2,
+		},
+		"histogram_quantile of subquery": {
+			query:                     `histogram_quantile(0.5, sum by(le) (max_over_time(rate(metric_histogram_bucket[5m])[2h:1m])))`,
+			expectedSpunOffSubqueries: 1,
+		},
+		"subquery with small range": {
+			query:                     `max_over_time(rate(metric_counter[5m])[30m:1m])`,
+			expectedSpunOffSubqueries: 0,
+		},
+		"query without subqueries": {
+			query:                     `sum by(group_1) (rate(metric_counter[5m]))`,
+			expectedSpunOffSubqueries: 0,
+		},
+	}
+
+	end := time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC)
+	start := end.Add(-2 * time.Hour)
+	step := 30 * time.Second
+	seriesStart := start.Add(-26 * time.Hour)
+
+	// Generate the series.
+	var series []*promql.StorageSeries
+
+	for i := 0; i < numSeries; i++ {
+		gen := factor(float64(i) * 0.1)
+		if i >= numSeries-numStaleSeries {
+			// Wrap the generator to inject the staleness marker between minute 10 and 20.
+			gen = stale(start.Add(10*time.Minute), start.Add(20*time.Minute), gen)
+		}
+
+		series = append(series, newSeries(newTestCounterLabels(i), seriesStart, end, step, gen))
+	}
+
+	for i := 0; i < numHistograms; i++ {
+		for bucketIdx, bucketLe := range histogramBuckets {
+			// We expect each bucket to have a value higher than the previous one.
+			gen := factor(float64(i) * float64(bucketIdx) * 0.1)
+			if i >= numHistograms-numStaleHistograms {
+				// Wrap the generator to inject the staleness marker between minute 10 and 20.
+				gen = stale(start.Add(10*time.Minute), start.Add(20*time.Minute), gen)
+			}
+
+			series = append(series, newSeries(newTestHistogramLabels(numSeries+i, bucketLe), seriesStart, end, step, gen))
+		}
+	}
+
+	// Create a queryable on the fixtures.
+	queryable := storageSeriesQueryable(series)
+
+	for testName, testData := range tests {
+		// Change scope to ensure it work fine when test cases are executed concurrently.
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			reqs := []Request{
+				&PrometheusInstantQueryRequest{
+					Path:  "/query",
+					Time:  util.TimeToMillis(end),
+					Query: testData.query,
+				},
+			}
+			if !testData.instantQueryOnly {
+				reqs = append(reqs, &PrometheusRangeQueryRequest{
+					Path:  "/query_range",
+					Start: util.TimeToMillis(start),
+					End:   util.TimeToMillis(end),
+					Step:  (2 * time.Minute).Milliseconds(),
+					Query: testData.query,
+				})
+			}
+
+			for _, req := range reqs {
+				t.Run(fmt.Sprintf("%T", req), func(t *testing.T) {
+					reg := prometheus.NewPedanticRegistry()
+					engine := newEngine()
+					downstream := &downstreamHandler{
+						engine:    engine,
+						queryable: queryable,
+					}
+
+					// Run the query without spinning off subqueries.
+					expectedRes, err := downstream.Do(context.Background(), req)
+					require.Nil(t, err)
+					expectedPrometheusRes := expectedRes.(*PrometheusResponse)
+					sort.Sort(byLabels(expectedPrometheusRes.Data.Result))
+
+					// Ensure the query produces some results.
+					require.NotEmpty(t, expectedPrometheusRes.Data.Result)
+					requireValidSamples(t, expectedPrometheusRes.Data.Result)
+
+					// Count the range queries run for spun off subqueries.
+					rangeQueries := atomic.NewInt32(0)
+					rangeQueryHandler := HandlerFunc(func(ctx context.Context, r Request) (Response, error) {
+						rangeQueries.Inc()
+						assert.IsType(t, &PrometheusRangeQueryRequest{}, r)
+						assert.Equal(t, "/query_range", r.(*PrometheusRangeQueryRequest).Path)
+						return downstream.Do(ctx, r)
+					})
+
+					spinOffware := newSpinOffSubqueriesMiddleware(rangeQueryHandler, log.NewNopLogger(), engine, newSubqueryIntervalFn(), newSpinOffSubqueriesMetrics(reg))
+
+					// Run the query spinning off subqueries.
+					spinOffRes, err := spinOffware.Wrap(downstream).Do(user.InjectOrgID(context.Background(), "test"), req)
+					require.Nil(t, err)
+
+					spinOffPrometheusRes := spinOffRes.(*PrometheusResponse)
+					sort.Sort(byLabels(spinOffPrometheusRes.Data.Result))
+
+					approximatelyEquals(t, expectedPrometheusRes, spinOffPrometheusRes)
+					assert.GreaterOrEqual(t, int(rangeQueries.Load()), testData.expectedSpunOffSubqueries)
+					assert.GreaterOrEqual(t, int(rangeQueries.Load()), testData.expectedMinRangeQueries)
+
+					// Assert metrics.
+					expectedSucceeded, expectedSkipped := 1, 0
+					if testData.expectedSpunOffSubqueries == 0 {
+						expectedSucceeded, expectedSkipped = 0, 1
+					}
+
+					assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+						# HELP cortex_frontend_subquery_spin_off_attempted_total Total number of queries the query-frontend attempted to spin off subqueries from.
+						# TYPE cortex_frontend_subquery_spin_off_attempted_total counter
+						cortex_frontend_subquery_spin_off_attempted_total 1
+
+						# HELP cortex_frontend_subquery_spin_off_succeeded_total Total number of queries the query-frontend successfully spun off subqueries from.
+						# TYPE cortex_frontend_subquery_spin_off_succeeded_total counter
+						cortex_frontend_subquery_spin_off_succeeded_total %d
+
+						# HELP cortex_frontend_subquery_spin_off_skipped_total Total number of queries the query-frontend skipped or failed to spin off subqueries from.
+						# TYPE cortex_frontend_subquery_spin_off_skipped_total counter
+						cortex_frontend_subquery_spin_off_skipped_total{reason="mapping-failed"} 0
+						cortex_frontend_subquery_spin_off_skipped_total{reason="no-subqueries"} %d
+						cortex_frontend_subquery_spin_off_skipped_total{reason="parsing-failed"} 0
+
+						# HELP cortex_frontend_spun_off_subqueries_total Total number of subqueries spun off as range queries.
+						# TYPE cortex_frontend_spun_off_subqueries_total counter
+						cortex_frontend_spun_off_subqueries_total %d
+					`, expectedSucceeded, expectedSkipped, testData.expectedSpunOffSubqueries))))
+				})
+			}
+		})
+	}
+}
+
+func TestSpinOffSubqueries_ShouldReturnErrorOnRangeQueryHandlerFailure(t *testing.T) {
+	engine := newEngine()
+	rangeQueryHandler := HandlerFunc(func(context.Context, Request) (Response, error) {
+		return nil, apierror.New(apierror.TypeTooManyRequests, "too many requests")
+	})
+	spinOffware := newSpinOffSubqueriesMiddleware(rangeQueryHandler, log.NewNopLogger(), engine, newSubqueryIntervalFn(), newSpinOffSubqueriesMetrics(nil))
+
+	req := &PrometheusInstantQueryRequest{
+		Path:  "/query",
+		Time:  util.TimeToMillis(time.Now()),
+		Query: `max_over_time(rate(metric_counter[5m])[1d:1m])`,
+	}
+
+	_, err := spinOffware.Wrap(mockHandlerWith(nil, nil)).Do(user.InjectOrgID(context.Background(), "test"), req)
+	require.Error(t, err)
+	assert.True(t, apierror.IsAPIError(err))
+	assert.Contains(t, err.Error(), "too many requests")
+}
+
+// newSubqueryIntervalFn returns the function used by the test engine to get the step of subqueries which don't specify it.
+func newSubqueryIntervalFn() func(int64) int64 {
+	return func(int64) int64 {
+		return time.Minute.Milliseconds()
+	}
+}
@@ -150,6 +150,7 @@ func (s *splitAndCacheMiddleware) Do(ctx context.Context, req Request) (Response
 		return nil, err
 	}
 
+	queryStats := stats.FromContext(ctx)
 	isCacheEnabled := s.cacheEnabled && (s.shouldCacheReq == nil || s.shouldCacheReq(req))
 	maxCacheFreshness := validation.MaxDurationPerTenant(tenantIDs, s.limits.MaxCacheFreshness)
 	maxCacheTime := int64(model.Now().Add(-maxCacheFreshness))
@@ -177,6 +178,7 @@ func (s *splitAndCacheMiddleware) Do(ctx context.Context, req Request) (Response
 
 		// Lookup all keys from cache.
 		fetchedExtents := s.fetchCacheExtents(ctx, lookupKeys)
+		queryStats.AddResultsCacheLookups(uint32(len(lookupKeys)))
 
 		for lookupIdx, extents := range fetchedExtents {
 			if len(extents) == 0 {
@@ -185,6 +187,8 @@ func (s *splitAndCacheMiddleware) Do(ctx context.Context, req Request) (Response
 				continue
 			}
 
+			queryStats.AddResultsCacheHits(1)
+
 			// We have some extents. This means some parts of the response has been cached and we need
 			// to generate the queries for the missing parts.
 			requests, responses, err := partitionCacheExtents(lookupReqs[lookupIdx].orig, extents, defaultMinCacheExtent, s.extractor)
@@ -219,7 +223,6 @@ func (s *splitAndCacheMiddleware) Do(ctx context.Context, req Request) (Response
 
 	// Update query stats.
 	// Only consider the actual number of downstream requests, not the cache hits.
-	queryStats := stats.FromContext(ctx)
 	queryStats.AddSplitQueries(uint32(len(execReqs)))
 
 	if len(execReqs) > 0 {
@@ -207,6 +207,8 @@ func TestSplitAndCacheMiddleware_ResultsCache(t *testing.T) {
 	// Assert query stats from context
 	queryStats := stats.FromContext(ctx)
 	assert.Equal(t, uint32(1), queryStats.LoadSplitQueries())
+	assert.Equal(t, uint32(1), queryStats.LoadResultsCacheLookups())
+	assert.Equal(t, uint32(0), queryStats.LoadResultsCacheHits())
 
 	// Doing same request again shouldn't change anything.
 	resp, err = rc.Do(ctx, req)
@@ -217,6 +219,8 @@ func TestSplitAndCacheMiddleware_ResultsCache(t *testing.T) {
 	// Assert query stats from context
 	queryStats = stats.FromContext(ctx)
 	assert.Equal(t, uint32(1), queryStats.LoadSplitQueries())
+	assert.Equal(t, uint32(2), queryStats.LoadResultsCacheLookups())
+	assert.Equal(t, uint32(1), queryStats.LoadResultsCacheHits())
 
 	// Doing request with new end time should do one more query.
 	req = req.WithStartEnd(req.GetStart(), req.GetEnd()+step)
@@ -227,6 +231,8 @@ func TestSplitAndCacheMiddleware_ResultsCache(t *testing.T) {
 	// Assert query stats from context
 	queryStats = stats.FromContext(ctx)
 	assert.Equal(t, uint32(2), queryStats.LoadSplitQueries())
+	assert.Equal(t, uint32(3), queryStats.LoadResultsCacheLookups())
+	assert.Equal(t, uint32(2), queryStats.LoadResultsCacheHits())
 }
 
 func TestSplitAndCacheMiddleware_ResultsCache_ShouldNotLookupCacheIfStepIsNotAligned(t *testing.T) {
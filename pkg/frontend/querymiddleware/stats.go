@@ -4,11 +4,17 @@ package querymiddleware
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
 )
 
+// renderStatsContextKey is the context key holding the query stats which should be rendered in the response.
+const renderStatsContextKey contextKey = 1
+
 type queryStatsMiddleware struct {
 	nonAlignedQueries prometheus.Counter
 	next              Handler
@@ -35,3 +41,87 @@ func (s queryStatsMiddleware) Do(ctx context.Context, req Request) (Response, er
 
 	return s.next.Do(ctx, req)
 }
+
+// contextWithRenderedStats returns a context holding the query stats to render in the response
+// if they've been requested with the "stats=all" parameter, like the Prometheus API does.
+// The query stats are tracked in the context if they're not already.
+func contextWithRenderedStats(ctx context.Context, r *http.Request) context.Context {
+	if r.FormValue("stats") != "all" {
+		return ctx
+	}
+
+	queryStats := querier_stats.FromContext(ctx)
+	if queryStats == nil {
+		queryStats, ctx = querier_stats.ContextWithEmptyStats(ctx)
+	}
+
+	return context.WithValue(ctx, renderStatsContextKey, queryStats)
+}
+
+// renderedStatsFromContext returns the query stats to render in the response, or nil if they've not been requested.
+func renderedStatsFromContext(ctx context.Context) *querier_stats.Stats {
+	queryStats, _ := ctx.Value(renderStatsContextKey).(*querier_stats.Stats)
+	return queryStats
+}
+
+// responseStats holds the query stats rendered in the response. Timings are in seconds.
+type responseStats struct {
+	Timings      responseStatsTimings      `json:"timings"`
+	Samples      responseStatsSamples      `json:"samples"`
+	ResultsCache responseStatsResultsCache `json:"resultsCache"`
+	Sharding     responseStatsSharding     `json:"sharding"`
+}
+
+type responseStatsTimings struct {
+	EvalTotalTime         float64 `json:"evalTotalTime"`
+	ExecQueueTime         float64 `json:"execQueueTime"`
+	QuerierWallTime       float64 `json:"querierWallTime"`
+	IngesterFetchTime     float64 `json:"ingesterFetchTime"`
+	StoreGatewayFetchTime float64 `json:"storeGatewayFetchTime"`
+}
+
+type responseStatsSamples struct {
+	TotalQueryableSamples uint64 `json:"totalQueryableSamples"`
+	PeakSamples           uint64 `json:"peakSamples"`
+}
+
+type responseStatsResultsCache struct {
+	Lookups  uint32  `json:"lookups"`
+	Hits     uint32  `json:"hits"`
+	HitRatio float64 `json:"hitRatio"`
+}
+
+type responseStatsSharding struct {
+	ShardedQueries uint32 `json:"shardedQueries"`
+	Shards         uint32 `json:"shards"`
+}
+
+func newResponseStats(s *querier_stats.Stats) *responseStats {
+	res := &responseStats{
+		Timings: responseStatsTimings{
+			EvalTotalTime:         s.LoadEvaluationTime().Seconds(),
+			ExecQueueTime:         s.LoadQueueTime().Seconds(),
+			QuerierWallTime:       s.LoadWallTime().Seconds(),
+			IngesterFetchTime:     s.LoadIngesterFetchTime().Seconds(),
+			StoreGatewayFetchTime: s.LoadStoreGatewayFetchTime().Seconds(),
+		},
+		Samples: responseStatsSamples{
+			TotalQueryableSamples: s.LoadSamplesProcessed(),
+			PeakSamples:           s.LoadPeakSamples(),
+		},
+		ResultsCache: responseStatsResultsCache{
+			Lookups: s.LoadResultsCacheLookups(),
+			Hits:    s.LoadResultsCacheHits(),
+		},
+		Sharding: responseStatsSharding{
+			ShardedQueries: s.LoadShardedQueries(),
+			Shards:         s.LoadQueryShards(),
+		},
+	}
+
+	if res.ResultsCache.Lookups > 0 {
+		res.ResultsCache.HitRatio = float64(res.ResultsCache.Hits) / float64(res.ResultsCache.Lookups)
+	}
+
+	return res
+}
@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+)
+
+func TestContextWithRenderedStats(t *testing.T) {
+	t.Run("should not render stats if not requested", func(t *testing.T) {
+		ctx := contextWithRenderedStats(context.Background(), httptest.NewRequest("GET", "/api/v1/query_range?query=up", nil))
+		assert.Nil(t, renderedStatsFromContext(ctx))
+		assert.False(t, querier_stats.IsEnabled(ctx))
+	})
+
+	t.Run("should enable and render stats if requested and not tracked yet", func(t *testing.T) {
+		ctx := contextWithRenderedStats(context.Background(), httptest.NewRequest("GET", "/api/v1/query_range?query=up&stats=all", nil))
+		assert.NotNil(t, renderedStatsFromContext(ctx))
+		assert.Same(t, querier_stats.FromContext(ctx), renderedStatsFromContext(ctx))
+	})
+
+	t.Run("should render stats already tracked if requested", func(t *testing.T) {
+		queryStats, ctx := querier_stats.ContextWithEmptyStats(context.Background())
+		ctx = contextWithRenderedStats(ctx, httptest.NewRequest("GET", "/api/v1/query_range?query=up&stats=all", nil))
+		assert.Same(t, queryStats, renderedStatsFromContext(ctx))
+	})
+}
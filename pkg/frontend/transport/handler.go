@@ -160,8 +160,7 @@ func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(resp.StatusCode)
-	// we don't check for copy error as there is no much we can do at this point
-	_, _ = io.Copy(w, resp.Body)
+	_, copyErr := io.Copy(w, resp.Body)
 
 	// Check whether we should parse the query string.
 	shouldReportSlowQuery := f.cfg.LogQueriesLongerThan > 0 && queryResponseTime > f.cfg.LogQueriesLongerThan
@@ -175,6 +174,13 @@ func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if f.cfg.QueryStatsEnabled {
 		f.reportQueryStats(r, queryString, queryResponseTime, stats, nil)
 	}
+
+	// Responses streamed while being computed, like the remote read ones, can fail after the status code has been
+	// sent. The connection is aborted, so that the client doesn't take the truncated response as a complete one.
+	if copyErr != nil {
+		level.Debug(util_log.WithContext(r.Context(), f.log)).Log("msg", "failed to write response body", "path", r.URL.Path, "err", copyErr)
+		panic(http.ErrAbortHandler)
+	}
 }
 
 // reportSlowQuery reports slow queries.
@@ -223,7 +229,16 @@ func (f *Handler) reportQueryStats(r *http.Request, queryString url.Values, quer
 		"fetched_chunk_bytes", numBytes,
 		"fetched_chunks_count", numChunks,
 		"sharded_queries", stats.LoadShardedQueries(),
+		"query_shards", stats.LoadQueryShards(),
 		"split_queries", stats.LoadSplitQueries(),
+		"samples_processed", stats.LoadSamplesProcessed(),
+		"peak_samples", stats.LoadPeakSamples(),
+		"queue_time_seconds", stats.LoadQueueTime().Seconds(),
+		"ingester_fetch_time_seconds", stats.LoadIngesterFetchTime().Seconds(),
+		"store_gateway_fetch_time_seconds", stats.LoadStoreGatewayFetchTime().Seconds(),
+		"evaluation_time_seconds", stats.LoadEvaluationTime().Seconds(),
+		"results_cache_lookups", stats.LoadResultsCacheLookups(),
+		"results_cache_hits", stats.LoadResultsCacheHits(),
 	}, formatQueryString(queryString)...)
 
 	if queryErr != nil {
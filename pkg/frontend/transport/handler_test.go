@@ -99,6 +99,33 @@ func TestHandler_ServeHTTP(t *testing.T) {
 	}
 }
 
+func TestHandler_ShouldAbortConnectionOnFailedResponseBody(t *testing.T) {
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			_, _ = pw.Write([]byte("partial"))
+			_ = pw.CloseWithError(errors.New("failed to stream the response"))
+		}()
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       pr,
+		}, nil
+	})
+
+	handler := NewHandler(HandlerConfig{}, roundTripper, log.NewNopLogger(), nil)
+
+	req := httptest.NewRequest("GET", "/api/v1/read", nil)
+	req = req.WithContext(user.InjectOrgID(context.Background(), "12345"))
+	resp := httptest.NewRecorder()
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(resp, req)
+	})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "partial", resp.Body.String())
+}
+
 func TestHandler_FailedRoundTrip(t *testing.T) {
 	for _, test := range []struct {
 		name                string
@@ -154,6 +181,9 @@ func TestHandler_FailedRoundTrip(t *testing.T) {
 			require.NoError(t, err)
 
 			assert.Contains(t, strings.TrimSpace(logs.String()), "sharded_queries")
+			assert.Contains(t, strings.TrimSpace(logs.String()), "samples_processed")
+			assert.Contains(t, strings.TrimSpace(logs.String()), "results_cache_hits")
+			assert.Contains(t, strings.TrimSpace(logs.String()), "query_shards")
 			assert.Contains(t, strings.TrimSpace(logs.String()), "status")
 			if test.expectQueryParamLog {
 				assert.Contains(t, strings.TrimSpace(logs.String()), "param_query")
@@ -216,9 +216,13 @@ func (f *Frontend) Process(server frontendv1pb.Frontend_ProcessServer) error {
 
 		req := reqWrapper.(*request)
 
-		f.queueDuration.Observe(time.Since(req.enqueueTime).Seconds())
+		queueTime := time.Since(req.enqueueTime)
+		f.queueDuration.Observe(queueTime.Seconds())
 		req.queueSpan.Finish()
 
+		// The querier doesn't know about the time spent in the queue, so we track it here.
+		stats.FromContext(req.originalCtx).AddQueueTime(queueTime)
+
 		/*
 		  We want to dequeue the next unexpired request from the chosen tenant queue.
 		  The chance of choosing a particular tenant for dequeueing is (1/active_tenants).
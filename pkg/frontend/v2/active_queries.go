@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package v2
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	statusSuccess = "success"
+	statusError   = "error"
+
+	activeQueryStateQueued    = "queued"
+	activeQueryStateExecuting = "executing"
+)
+
+type activeQuery struct {
+	QueryID         uint64    `json:"queryID"`
+	FrontendAddress string    `json:"frontendAddress"`
+	Tenant          string    `json:"tenant"`
+	Path            string    `json:"path"`
+	Query           string    `json:"query,omitempty"`
+	State           string    `json:"state"`
+	StartTime       time.Time `json:"startTime"`
+	ElapsedSeconds  float64   `json:"elapsedSeconds"`
+	Querier         string    `json:"querier,omitempty"`
+	FetchedSeries   uint64    `json:"fetchedSeries"`
+}
+
+type activeQueriesResult struct {
+	Status string        `json:"status"`
+	Data   []activeQuery `json:"data"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// ActiveQueriesHandler lists the requests of the tenant queued or executed by queriers across the
+// cluster, as reported by all the query-schedulers this query-frontend is connected to. Requests are
+// sorted by start time, oldest first.
+func (f *Frontend) ActiveQueriesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := requestUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	queries, err := f.schedulerWorkers.getActiveQueries(r.Context())
+	if err != nil {
+		level.Warn(f.log).Log("msg", "failed to get active queries from query-schedulers", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		util.WriteJSONResponse(w, activeQueriesResult{Status: statusError, Error: err.Error()})
+		return
+	}
+
+	now := time.Now()
+	result := make([]activeQuery, 0, len(queries))
+	for _, q := range queries {
+		if q.UserID != userID {
+			continue
+		}
+
+		startTime := time.UnixMilli(q.EnqueueTimestampMs)
+		state := activeQueryStateQueued
+		if q.QuerierID != "" {
+			state = activeQueryStateExecuting
+		}
+
+		result = append(result, activeQuery{
+			QueryID:         q.QueryID,
+			FrontendAddress: q.FrontendAddress,
+			Tenant:          q.UserID,
+			Path:            q.Path,
+			Query:           q.Query,
+			State:           state,
+			StartTime:       startTime.UTC(),
+			ElapsedSeconds:  now.Sub(startTime).Seconds(),
+			Querier:         q.QuerierID,
+			FetchedSeries:   q.FetchedSeriesCount,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+
+	util.WriteJSONResponse(w, activeQueriesResult{Status: statusSuccess, Data: result})
+}
+
+// CancelActiveQueryHandler cancels a request of the tenant queued or executed by a querier. The request
+// is identified by the "id" and "frontend" parameters, as listed by ActiveQueriesHandler. If "frontend"
+// is not specified, it defaults to this query-frontend.
+func (f *Frontend) CancelActiveQueryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := requestUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	queryID, err := strconv.ParseUint(r.FormValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid or missing query ID", http.StatusBadRequest)
+		return
+	}
+
+	frontendAddress := r.FormValue("frontend")
+	if frontendAddress == "" {
+		frontendAddress = f.schedulerWorkers.frontendAddress
+	}
+
+	// Requests of other tenants are reported as not found, to not disclose them.
+	owned, err := f.isActiveQueryOwnedBy(r.Context(), frontendAddress, queryID, userID)
+	if err != nil {
+		level.Warn(f.log).Log("msg", "failed to get active queries from query-schedulers", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !owned {
+		http.Error(w, "query not found", http.StatusNotFound)
+		return
+	}
+
+	found, err := f.schedulerWorkers.cancelActiveQuery(r.Context(), frontendAddress, queryID)
+	if err != nil {
+		level.Warn(f.log).Log("msg", "failed to cancel active query", "frontend", frontendAddress, "queryID", queryID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "query not found", http.StatusNotFound)
+		return
+	}
+
+	level.Info(f.log).Log("msg", "cancelled active query", "frontend", frontendAddress, "queryID", queryID, "user", userID)
+	util.WriteTextResponse(w, "query cancelled")
+}
+
+// isActiveQueryOwnedBy returns whether the request, identified by the frontend which enqueued it and
+// its ID, is active and has been issued by the given tenant.
+func (f *Frontend) isActiveQueryOwnedBy(ctx context.Context, frontendAddress string, queryID uint64, userID string) (bool, error) {
+	queries, err := f.schedulerWorkers.getActiveQueries(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, q := range queries {
+		if q.FrontendAddress == frontendAddress && q.QueryID == queryID {
+			return q.UserID == userID, nil
+		}
+	}
+	return false, nil
+}
+
+// requestUserID returns the tenant of the request, in the same format the requests are enqueued with.
+func requestUserID(r *http.Request) (string, error) {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return "", err
+	}
+	return tenant.JoinTenantIDs(tenantIDs), nil
+}
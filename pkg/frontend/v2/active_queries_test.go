@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package v2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/scheduler/schedulerpb"
+)
+
+func TestFrontend_ActiveQueriesHandler(t *testing.T) {
+	f, ms := setupFrontend(t, nil, nil)
+
+	now := time.Now()
+	ms.checkWithLock(func() {
+		ms.activeQueries = []*schedulerpb.ActiveQuery{
+			{
+				QueryID:             2,
+				FrontendAddress:     "frontend-1",
+				UserID:              "user-1",
+				Path:                "/prometheus/api/v1/query_range",
+				Query:               "sum(up)",
+				EnqueueTimestampMs:  now.Add(-time.Minute).UnixMilli(),
+				QuerierID:           "querier-1",
+				DispatchTimestampMs: now.Add(-50 * time.Second).UnixMilli(),
+				FetchedSeriesCount:  10,
+			}, {
+				QueryID:            1,
+				FrontendAddress:    "frontend-2",
+				UserID:             "user-1",
+				Path:               "/prometheus/api/v1/labels",
+				EnqueueTimestampMs: now.Add(-2 * time.Minute).UnixMilli(),
+			}, {
+				QueryID:            3,
+				FrontendAddress:    "frontend-1",
+				UserID:             "user-2",
+				Path:               "/prometheus/api/v1/query",
+				Query:              "sum(secret)",
+				EnqueueTimestampMs: now.Add(-3 * time.Minute).UnixMilli(),
+			},
+		}
+	})
+
+	t.Run("should fail without tenant", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		f.ActiveQueriesHandler(rec, httptest.NewRequest("GET", "/prometheus/api/v1/status/active_queries", nil))
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	req := httptest.NewRequest("GET", "/prometheus/api/v1/status/active_queries", nil)
+	req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+
+	rec := httptest.NewRecorder()
+	f.ActiveQueriesHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var result activeQueriesResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Equal(t, statusSuccess, result.Status)
+	// Queries of other tenants are not listed.
+	require.Len(t, result.Data, 2)
+
+	// Queries are sorted by start time, oldest first.
+	assert.Equal(t, uint64(1), result.Data[0].QueryID)
+	assert.Equal(t, "frontend-2", result.Data[0].FrontendAddress)
+	assert.Equal(t, "user-1", result.Data[0].Tenant)
+	assert.Equal(t, activeQueryStateQueued, result.Data[0].State)
+	assert.Empty(t, result.Data[0].Querier)
+	assert.GreaterOrEqual(t, result.Data[0].ElapsedSeconds, 120.0)
+
+	assert.Equal(t, uint64(2), result.Data[1].QueryID)
+	assert.Equal(t, "sum(up)", result.Data[1].Query)
+	assert.Equal(t, activeQueryStateExecuting, result.Data[1].State)
+	assert.Equal(t, "querier-1", result.Data[1].Querier)
+	assert.Equal(t, uint64(10), result.Data[1].FetchedSeries)
+	assert.Equal(t, now.Add(-time.Minute).UnixMilli(), result.Data[1].StartTime.UnixMilli())
+	assert.GreaterOrEqual(t, result.Data[1].ElapsedSeconds, 60.0)
+}
+
+func TestFrontend_CancelActiveQueryHandler(t *testing.T) {
+	f, ms := setupFrontend(t, nil, nil)
+
+	ms.checkWithLock(func() {
+		ms.activeQueries = []*schedulerpb.ActiveQuery{
+			{QueryID: 1, FrontendAddress: "frontend-1", UserID: "user-1"},
+			{QueryID: 1, FrontendAddress: f.schedulerWorkers.frontendAddress, UserID: "user-1"},
+			{QueryID: 2, FrontendAddress: "frontend-1", UserID: "user-2"},
+		}
+	})
+
+	cancel := func(values url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/query-frontend/cancel_query", strings.NewReader(values.Encode()))
+		req = req.WithContext(user.InjectOrgID(context.Background(), "user-1"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rec := httptest.NewRecorder()
+		f.CancelActiveQueryHandler(rec, req)
+		return rec
+	}
+
+	t.Run("should fail on missing query ID", func(t *testing.T) {
+		rec := cancel(url.Values{"frontend": {"frontend-1"}})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("should return 404 on unknown query", func(t *testing.T) {
+		rec := cancel(url.Values{"frontend": {"frontend-1"}, "id": {"3"}})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("should return 404 on query of another tenant", func(t *testing.T) {
+		rec := cancel(url.Values{"frontend": {"frontend-1"}, "id": {"2"}})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		ms.checkWithLock(func() {
+			require.Len(t, ms.activeQueries, 3)
+		})
+	})
+
+	t.Run("should cancel the query of the given frontend", func(t *testing.T) {
+		rec := cancel(url.Values{"frontend": {"frontend-1"}, "id": {"1"}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		ms.checkWithLock(func() {
+			require.Len(t, ms.activeQueries, 2)
+			assert.Equal(t, f.schedulerWorkers.frontendAddress, ms.activeQueries[0].FrontendAddress)
+		})
+	})
+
+	t.Run("should cancel the query of this frontend if the frontend is not specified", func(t *testing.T) {
+		rec := cancel(url.Values{"id": {"1"}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		ms.checkWithLock(func() {
+			require.Len(t, ms.activeQueries, 1)
+			assert.Equal(t, "user-2", ms.activeQueries[0].UserID)
+		})
+	})
+}
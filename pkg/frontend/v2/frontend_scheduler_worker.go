@@ -14,11 +14,13 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/concurrency"
 	"github.com/grafana/dskit/services"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/httpgrpc"
+	"go.uber.org/atomic"
 	"google.golang.org/grpc"
 
 	"github.com/grafana/mimir/pkg/frontend/v2/frontendv2pb"
@@ -190,8 +192,67 @@ func (f *frontendSchedulerWorkers) getWorkersCount() int {
 	return len(f.workers)
 }
 
+// getActiveQueries returns the requests queued or executed by queriers, as reported by
+// all the query-schedulers this query-frontend is connected to.
+func (f *frontendSchedulerWorkers) getActiveQueries(ctx context.Context) ([]*schedulerpb.ActiveQuery, error) {
+	clients := f.getSchedulerClients()
+
+	var (
+		mtx     sync.Mutex
+		queries []*schedulerpb.ActiveQuery
+	)
+
+	err := concurrency.ForEachJob(ctx, len(clients), len(clients), func(ctx context.Context, idx int) error {
+		resp, err := clients[idx].GetActiveQueries(ctx, &schedulerpb.ActiveQueriesRequest{})
+		if err != nil {
+			return err
+		}
+
+		mtx.Lock()
+		queries = append(queries, resp.Queries...)
+		mtx.Unlock()
+		return nil
+	})
+
+	return queries, err
+}
+
+// cancelActiveQuery cancels the request, identified by the frontend which enqueued it and its ID,
+// in any of the query-schedulers this query-frontend is connected to. Returns whether the request
+// has been found.
+func (f *frontendSchedulerWorkers) cancelActiveQuery(ctx context.Context, frontendAddress string, queryID uint64) (bool, error) {
+	clients := f.getSchedulerClients()
+	found := atomic.NewBool(false)
+
+	err := concurrency.ForEachJob(ctx, len(clients), len(clients), func(ctx context.Context, idx int) error {
+		resp, err := clients[idx].CancelActiveQuery(ctx, &schedulerpb.CancelActiveQueryRequest{FrontendAddress: frontendAddress, QueryID: queryID})
+		if err != nil {
+			return err
+		}
+
+		if resp.Found {
+			found.Store(true)
+		}
+		return nil
+	})
+
+	return found.Load(), err
+}
+
+func (f *frontendSchedulerWorkers) getSchedulerClients() []schedulerpb.SchedulerForFrontendClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	clients := make([]schedulerpb.SchedulerForFrontendClient, 0, len(f.workers))
+	for _, w := range f.workers {
+		clients = append(clients, schedulerpb.NewSchedulerForFrontendClient(w.conn))
+	}
+
+	return clients
+}
+
 func (f *frontendSchedulerWorkers) connectToScheduler(ctx context.Context, address string) (*grpc.ClientConn, error) {
-	// Because we only use single long-running method, it doesn't make sense to inject user ID, send over tracing or add metrics.
+	// Because we mostly use single long-running method, it doesn't make sense to inject user ID, send over tracing or add metrics.
 	opts, err := f.cfg.GRPCClientConfig.DialOption(nil, nil)
 	if err != nil {
 		return nil, err
@@ -335,9 +335,10 @@ type mockScheduler struct {
 
 	replyFunc func(f *Frontend, msg *schedulerpb.FrontendToScheduler) *schedulerpb.SchedulerToFrontend
 
-	mu           sync.Mutex
-	frontendAddr map[string]int
-	msgs         []*schedulerpb.FrontendToScheduler
+	mu            sync.Mutex
+	frontendAddr  map[string]int
+	msgs          []*schedulerpb.FrontendToScheduler
+	activeQueries []*schedulerpb.ActiveQuery
 }
 
 func newMockScheduler(t *testing.T, f *Frontend, replyFunc func(f *Frontend, msg *schedulerpb.FrontendToScheduler) *schedulerpb.SchedulerToFrontend) *mockScheduler {
@@ -387,6 +388,27 @@ func (m *mockScheduler) FrontendLoop(frontend schedulerpb.SchedulerForFrontend_F
 	}
 }
 
+func (m *mockScheduler) GetActiveQueries(_ context.Context, _ *schedulerpb.ActiveQueriesRequest) (*schedulerpb.ActiveQueriesResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return &schedulerpb.ActiveQueriesResponse{Queries: m.activeQueries}, nil
+}
+
+func (m *mockScheduler) CancelActiveQuery(_ context.Context, req *schedulerpb.CancelActiveQueryRequest) (*schedulerpb.CancelActiveQueryResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, q := range m.activeQueries {
+		if q.FrontendAddress == req.FrontendAddress && q.QueryID == req.QueryID {
+			m.activeQueries = append(m.activeQueries[:i], m.activeQueries[i+1:]...)
+			return &schedulerpb.CancelActiveQueryResponse{Found: true}, nil
+		}
+	}
+
+	return &schedulerpb.CancelActiveQueryResponse{Found: false}, nil
+}
+
 func TestConfig_Validate(t *testing.T) {
 	tests := map[string]struct {
 		setup       func(cfg *Config)
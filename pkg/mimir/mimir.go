@@ -206,6 +206,9 @@ func (c *Config) Validate(log log.Logger) error {
 	if err := c.validateFilesystemPaths(log); err != nil {
 		return err
 	}
+	if err := c.LimitsConfig.Validate(); err != nil {
+		return errors.Wrap(err, "invalid limits config")
+	}
 	if err := c.RulerStorage.Validate(); err != nil {
 		return errors.Wrap(err, "invalid rulestore config")
 	}
@@ -245,6 +248,9 @@ func (c *Config) Validate(log log.Logger) error {
 	if err := c.UsageStats.Validate(); err != nil {
 		return errors.Wrap(err, "invalid usage stats config")
 	}
+	if err := c.TenantFederation.Validate(); err != nil {
+		return errors.Wrap(err, "invalid tenant federation config")
+	}
 	if c.isAnyModuleEnabled(AlertManager, Backend) {
 		if err := c.Alertmanager.Validate(); err != nil {
 			return errors.Wrap(err, "invalid alertmanager config")
@@ -679,6 +685,8 @@ func New(cfg Config, reg prometheus.Registerer) (*Mimir, error) {
 			"/frontend.Frontend/Process",
 			"/frontend.Frontend/NotifyClientShutdown",
 			"/schedulerpb.SchedulerForFrontend/FrontendLoop",
+			"/schedulerpb.SchedulerForFrontend/GetActiveQueries",
+			"/schedulerpb.SchedulerForFrontend/CancelActiveQuery",
 			"/schedulerpb.SchedulerForQuerier/QuerierLoop",
 			"/schedulerpb.SchedulerForQuerier/NotifyQuerierShutdown",
 		}, cfg.NoAuthTenant)
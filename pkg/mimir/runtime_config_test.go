@@ -113,3 +113,43 @@ overrides:
 		assert.Nil(t, actual)
 	}
 }
+
+func TestLoadRuntimeConfig_ShouldLoadTenantFederationGroups(t *testing.T) {
+	yamlFile := strings.NewReader(`
+tenant_federation_groups:
+  group-1:
+    tenants: [team-a, team-b]
+  group-2:
+    tenants_regex: "team-.*"
+`)
+	actual, err := loadRuntimeConfig(yamlFile)
+	require.NoError(t, err)
+
+	groups := actual.(*runtimeConfigValues).TenantFederationGroups
+	require.Len(t, groups, 2)
+	assert.Equal(t, []string{"team-a", "team-b"}, groups["group-1"].Tenants)
+	assert.Equal(t, "team-.*", groups["group-2"].TenantsRegex)
+}
+
+func TestLoadRuntimeConfig_ShouldReturnErrorOnInvalidTenantFederationGroups(t *testing.T) {
+	cases := map[string]string{
+		"invalid group ID": `
+tenant_federation_groups:
+  group-1|group-2:
+    tenants: [team-a]
+`,
+		"invalid regex": `
+tenant_federation_groups:
+  group-1:
+    tenants_regex: "team-("
+`,
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := loadRuntimeConfig(strings.NewReader(tc))
+			assert.Error(t, err)
+			assert.Nil(t, actual)
+		})
+	}
+}
@@ -117,6 +117,9 @@ func (c *MimirClient) backfillBlock(blockDir string, logctx *logrus.Entry, sleep
 		}
 
 		if uploadResult.State == "failed" {
+			for _, issue := range uploadResult.Issues {
+				logctx.WithField("issue", issue).Warn("block validation issue")
+			}
 			return errors.Errorf("block validation failed: %s", uploadResult.Error)
 		}
 
@@ -126,8 +129,9 @@ func (c *MimirClient) backfillBlock(blockDir string, logctx *logrus.Entry, sleep
 }
 
 type result struct {
-	State string `json:"result"`
-	Error string `json:"error,omitempty"`
+	State  string   `json:"result"`
+	Error  string   `json:"error,omitempty"`
+	Issues []string `json:"issues,omitempty"`
 }
 
 func (c *MimirClient) getBlockUpload(url string) (result, error) {
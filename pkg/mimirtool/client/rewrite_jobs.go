@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/compactor"
+)
+
+const rewriteJobsAPIPath = "/compactor/rewrite_jobs"
+
+// SubmitRewriteJob submits a block rewrite job, and returns the submitted job.
+func (r *MimirClient) SubmitRewriteJob(ctx context.Context, spec compactor.RewriteJobSpec) (*compactor.RewriteJob, error) {
+	payload, err := yaml.Marshal(&spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode rewrite job")
+	}
+
+	res, err := r.doRequest(rewriteJobsAPIPath, http.MethodPost, bytes.NewReader(payload), int64(len(payload)))
+	if err != nil {
+		return nil, err
+	}
+
+	job := &compactor.RewriteJob{}
+	if err := decodeYAMLResponse(res, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// GetRewriteJob returns the block rewrite job with the given ID, along with its status.
+func (r *MimirClient) GetRewriteJob(ctx context.Context, id string) (*compactor.RewriteJob, error) {
+	res, err := r.doRequest(rewriteJobsAPIPath+"/"+url.PathEscape(id), http.MethodGet, nil, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &compactor.RewriteJob{}
+	if err := decodeYAMLResponse(res, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// ListRewriteJobs returns all the block rewrite jobs of the tenant.
+func (r *MimirClient) ListRewriteJobs(ctx context.Context) ([]*compactor.RewriteJob, error) {
+	res, err := r.doRequest(rewriteJobsAPIPath, http.MethodGet, nil, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*compactor.RewriteJob
+	if err := decodeYAMLResponse(res, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func decodeYAMLResponse(res *http.Response, v interface{}) error {
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read response body")
+	}
+
+	return errors.Wrap(yaml.Unmarshal(body, v), "failed to decode response body")
+}
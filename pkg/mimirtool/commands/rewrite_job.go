@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/relabel"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/compactor"
+	"github.com/grafana/mimir/pkg/mimirtool/client"
+)
+
+// RewriteJobCommand submits block rewrite jobs to the Grafana Mimir compactor, and checks their status.
+type RewriteJobCommand struct {
+	clientConfig client.Config
+
+	selectors           []string
+	relabelConfigFile   string
+	dropSeries          bool
+	fixOutOfOrderChunks bool
+	start               string
+	end                 string
+	dryRun              bool
+	wait                bool
+	pollInterval        time.Duration
+
+	jobID string
+
+	cli *client.MimirClient
+}
+
+// Register rewrite job related commands and flags with the kingpin application.
+func (c *RewriteJobCommand) Register(app *kingpin.Application, envVars EnvVarNames) {
+	cmd := app.Command("rewrite-job", "Rewrite the series of the blocks stored in Grafana Mimir: relabel series, drop series and fix out-of-order chunks.").PreAction(c.setup)
+	cmd.Flag("address", "Address of the Grafana Mimir cluster; alternatively, set "+envVars.Address+".").Envar(envVars.Address).Required().StringVar(&c.clientConfig.Address)
+	cmd.Flag("id", "Grafana Mimir tenant ID; alternatively, set "+envVars.TenantID+".").Envar(envVars.TenantID).Required().StringVar(&c.clientConfig.ID)
+	cmd.Flag("user", fmt.Sprintf("API user to use when contacting Grafana Mimir; alternatively, set %s. If empty, %s is used instead.", envVars.APIUser, envVars.TenantID)).Default("").Envar(envVars.APIUser).StringVar(&c.clientConfig.User)
+	cmd.Flag("key", "API key to use when contacting Grafana Mimir; alternatively, set "+envVars.APIKey+".").Default("").Envar(envVars.APIKey).StringVar(&c.clientConfig.Key)
+	cmd.Flag("tls-ca-path", "TLS CA certificate to verify Grafana Mimir API as part of mTLS; alternatively, set "+envVars.TLSCAPath+".").Default("").Envar(envVars.TLSCAPath).StringVar(&c.clientConfig.TLS.CAPath)
+	cmd.Flag("tls-cert-path", "TLS client certificate to authenticate with the Grafana Mimir API as part of mTLS; alternatively, set "+envVars.TLSCertPath+".").Default("").Envar(envVars.TLSCertPath).StringVar(&c.clientConfig.TLS.CertPath)
+	cmd.Flag("tls-key-path", "TLS client certificate private key to authenticate with the Grafana Mimir API as part of mTLS; alternatively, set "+envVars.TLSKeyPath+".").Default("").Envar(envVars.TLSKeyPath).StringVar(&c.clientConfig.TLS.KeyPath)
+	cmd.Flag("auth-token", "Authentication token bearer authentication; alternatively, set "+envVars.AuthToken+".").Default("").Envar(envVars.AuthToken).StringVar(&c.clientConfig.AuthToken)
+
+	submitCmd := cmd.Command("submit", "Submit a block rewrite job. The job rewrites the series matching any of the selectors in the blocks overlapping the time range.").Action(c.submit)
+	submitCmd.Flag("selector", "Series selector, like '{cluster=\"bad\"}'. Can be specified multiple times.").Required().StringsVar(&c.selectors)
+	submitCmd.Flag("relabel-config-file", "YAML file containing the list of relabel configs to apply to the selected series. Series dropped by the relabeling are deleted.").ExistingFileVar(&c.relabelConfigFile)
+	submitCmd.Flag("drop-series", "Delete the selected series.").BoolVar(&c.dropSeries)
+	submitCmd.Flag("fix-out-of-order-chunks", "Sort the chunks of the selected series by time, merging the overlapping ones.").BoolVar(&c.fixOutOfOrderChunks)
+	submitCmd.Flag("start", "Start of the time range of the samples to rewrite, in RFC3339 format. If empty, there is no lower bound.").Default("").StringVar(&c.start)
+	submitCmd.Flag("end", "End of the time range of the samples to rewrite, in RFC3339 format, excluded. If empty, the job submission time is used.").Default("").StringVar(&c.end)
+	submitCmd.Flag("dry-run", "Only report the series which would be affected, without rewriting any block.").BoolVar(&c.dryRun)
+	submitCmd.Flag("wait", "Wait for the job to complete, and print its final status.").BoolVar(&c.wait)
+	submitCmd.Flag("poll-interval", "How long to sleep between checks of the job status while waiting for the job to complete.").Default("30s").DurationVar(&c.pollInterval)
+
+	statusCmd := cmd.Command("status", "Print the status of a block rewrite job, including the report of the blocks processed so far.").Action(c.status)
+	statusCmd.Arg("job-id", "ID of the job.").Required().StringVar(&c.jobID)
+
+	cmd.Command("list", "List the block rewrite jobs of the tenant.").Action(c.list)
+}
+
+func (c *RewriteJobCommand) setup(_ *kingpin.ParseContext) error {
+	cli, err := client.New(c.clientConfig)
+	if err != nil {
+		return err
+	}
+	c.cli = cli
+	return nil
+}
+
+func (c *RewriteJobCommand) submit(_ *kingpin.ParseContext) error {
+	spec, err := c.buildSpec()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	job, err := c.cli.SubmitRewriteJob(ctx, spec)
+	if err != nil {
+		return errors.Wrap(err, "failed to submit rewrite job")
+	}
+	log.WithFields(log.Fields{"job": job.ID, "dry_run": spec.DryRun}).Info("rewrite job submitted")
+
+	for c.wait && job.State == compactor.RewriteJobPending {
+		time.Sleep(c.pollInterval)
+
+		job, err = c.cli.GetRewriteJob(ctx, job.ID.String())
+		if err != nil {
+			return errors.Wrap(err, "failed to check rewrite job status")
+		}
+		log.WithFields(log.Fields{"job": job.ID, "state": job.State, "processed_blocks": len(job.Blocks)}).Debug("checked rewrite job status")
+	}
+
+	return printYAML(job)
+}
+
+func (c *RewriteJobCommand) buildSpec() (compactor.RewriteJobSpec, error) {
+	spec := compactor.RewriteJobSpec{
+		Selectors:           c.selectors,
+		DropSeries:          c.dropSeries,
+		FixOutOfOrderChunks: c.fixOutOfOrderChunks,
+		DryRun:              c.dryRun,
+	}
+
+	if c.relabelConfigFile != "" {
+		data, err := os.ReadFile(c.relabelConfigFile)
+		if err != nil {
+			return spec, errors.Wrap(err, "unable to read relabel config file")
+		}
+		var cfgs []*relabel.Config
+		if err := yaml.Unmarshal(data, &cfgs); err != nil {
+			return spec, errors.Wrap(err, "unable to parse relabel config file")
+		}
+		spec.RelabelConfigs = cfgs
+	}
+
+	var err error
+	if spec.Start, err = parseRewriteJobTime(c.start); err != nil {
+		return spec, errors.Wrap(err, "invalid start")
+	}
+	if spec.End, err = parseRewriteJobTime(c.end); err != nil {
+		return spec, errors.Wrap(err, "invalid end")
+	}
+
+	return spec, spec.Validate()
+}
+
+func parseRewriteJobTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func (c *RewriteJobCommand) status(_ *kingpin.ParseContext) error {
+	job, err := c.cli.GetRewriteJob(context.Background(), c.jobID)
+	if errors.Is(err, client.ErrResourceNotFound) {
+		return fmt.Errorf("rewrite job %s not found", c.jobID)
+	}
+	if err != nil {
+		return err
+	}
+	return printYAML(job)
+}
+
+func (c *RewriteJobCommand) list(_ *kingpin.ParseContext) error {
+	jobs, err := c.cli.ListRewriteJobs(context.Background())
+	if err != nil {
+		return err
+	}
+	return printYAML(jobs)
+}
+
+func printYAML(v interface{}) error {
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
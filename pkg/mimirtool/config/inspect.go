@@ -281,6 +281,10 @@ func (i *InspectedEntry) decodeValue(decoder decoder) (Value, error) {
 	switch i.FieldType {
 	case "duration":
 		value := decoded.AsInterface().(**duration)
+		if *value == nil {
+			// Durations without a default value, such as the ones not configurable via CLI flags.
+			return DurationValue(0), nil
+		}
 		return DurationValue(time.Duration(**value)), err
 	case "list of strings":
 		return InterfaceValue(*decoded.AsInterface().(*stringSlice)), nil
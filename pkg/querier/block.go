@@ -15,6 +15,7 @@ import (
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
 
 	"github.com/grafana/mimir/pkg/storage/series"
+	"github.com/grafana/mimir/pkg/storage/tsdb/downsample"
 	"github.com/grafana/mimir/pkg/storegateway/labelpb"
 	"github.com/grafana/mimir/pkg/storegateway/storepb"
 )
@@ -115,20 +116,86 @@ func (bqs *blockQuerierSeries) Iterator() chunkenc.Iterator {
 	}
 
 	its := make([]iteratorWithMaxTime, 0, len(bqs.chunks))
+	hasCounter := false
 
 	for _, c := range bqs.chunks {
-		ch, err := chunkenc.FromData(chunkenc.EncXOR, c.Raw.Data)
+		if c.Raw != nil {
+			ch, err := chunkenc.FromData(chunkenc.EncXOR, c.Raw.Data)
+			if err != nil {
+				return series.NewErrIterator(errors.Wrapf(err, "failed to initialize chunk from XOR encoded raw data (series: %v min time: %d max time: %d)", bqs.Labels(), c.MinTime, c.MaxTime))
+			}
+
+			it := ch.Iterator(nil)
+			its = append(its, iteratorWithMaxTime{it, c.MaxTime})
+			continue
+		}
+
+		// The chunk comes from a downsampled block.
+		it, isCounter, err := newAggrChunkIterator(c)
 		if err != nil {
-			return series.NewErrIterator(errors.Wrapf(err, "failed to initialize chunk from XOR encoded raw data (series: %v min time: %d max time: %d)", bqs.Labels(), c.MinTime, c.MaxTime))
+			return series.NewErrIterator(errors.Wrapf(err, "failed to initialize chunk from aggregated data (series: %v min time: %d max time: %d)", bqs.Labels(), c.MinTime, c.MaxTime))
 		}
 
-		it := ch.Iterator(nil)
+		hasCounter = hasCounter || isCounter
 		its = append(its, iteratorWithMaxTime{it, c.MaxTime})
 	}
 
+	// Counter aggregates keep the original raw values, so counter resets have to be applied
+	// across all chunks to generate monotonically increasing values.
+	if hasCounter {
+		counterIts := make([]chunkenc.Iterator, 0, len(its))
+		for _, it := range its {
+			counterIts = append(counterIts, it.Iterator)
+		}
+		return downsample.NewApplyCounterResetsIterator(counterIts...)
+	}
+
 	return newBlockQuerierSeriesIterator(bqs.Labels(), its)
 }
 
+// newAggrChunkIterator returns an iterator over the aggregate chunk, picking the aggregate to iterate
+// among the ones returned by the store-gateway. The returned bool is true if the iterator is over
+// the counter aggregate.
+func newAggrChunkIterator(c storepb.AggrChunk) (chunkenc.Iterator, bool, error) {
+	xorIterator := func(chk *storepb.Chunk) (chunkenc.Iterator, error) {
+		ch, err := chunkenc.FromData(chunkenc.EncXOR, chk.Data)
+		if err != nil {
+			return nil, err
+		}
+		return ch.Iterator(nil), nil
+	}
+
+	switch {
+	case c.Counter != nil:
+		it, err := xorIterator(c.Counter)
+		return it, true, err
+	case c.Count != nil && c.Sum != nil:
+		cnt, err := xorIterator(c.Count)
+		if err != nil {
+			return nil, false, err
+		}
+		sum, err := xorIterator(c.Sum)
+		if err != nil {
+			return nil, false, err
+		}
+		return downsample.NewAverageChunkIterator(cnt, sum), false, nil
+	case c.Sum != nil:
+		it, err := xorIterator(c.Sum)
+		return it, false, err
+	case c.Min != nil:
+		it, err := xorIterator(c.Min)
+		return it, false, err
+	case c.Max != nil:
+		it, err := xorIterator(c.Max)
+		return it, false, err
+	case c.Count != nil:
+		it, err := xorIterator(c.Count)
+		return it, false, err
+	}
+
+	return nil, false, errors.New("no raw data or aggregate in chunk")
+}
+
 func newBlockQuerierSeriesIterator(labels labels.Labels, its []iteratorWithMaxTime) *blockQuerierSeriesIterator {
 	return &blockQuerierSeriesIterator{labels: labels, iterators: its, lastT: math.MinInt64}
 }
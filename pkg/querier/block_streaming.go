@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+
+	"github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/storage/series"
+	"github.com/grafana/mimir/pkg/storegateway/storegatewaypb"
+	"github.com/grafana/mimir/pkg/storegateway/storepb"
+	"github.com/grafana/mimir/pkg/util/limiter"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// chunkStreamReader returns the chunks of the series streamed by a store-gateway.
+type chunkStreamReader interface {
+	GetChunks(seriesIndex uint64) ([]storepb.AggrChunk, error)
+}
+
+// blockStreamingQuerierSeriesSet is a storage.SeriesSet of the series streamed by a store-gateway.
+// The labels of the series have already been received, while the chunks are read from the stream
+// when the series are iterated.
+type blockStreamingQuerierSeriesSet struct {
+	series       []labels.Labels
+	streamReader chunkStreamReader
+
+	// next series to process
+	next int
+
+	currSeries storage.Series
+}
+
+func (bqss *blockStreamingQuerierSeriesSet) Next() bool {
+	bqss.currSeries = nil
+
+	if bqss.next >= len(bqss.series) {
+		return false
+	}
+
+	bqss.currSeries = &blockStreamingQuerierSeries{
+		labels:       bqss.series[bqss.next],
+		seriesIndex:  uint64(bqss.next),
+		streamReader: bqss.streamReader,
+	}
+
+	bqss.next++
+	return true
+}
+
+func (bqss *blockStreamingQuerierSeriesSet) At() storage.Series {
+	return bqss.currSeries
+}
+
+func (bqss *blockStreamingQuerierSeriesSet) Err() error {
+	return nil
+}
+
+func (bqss *blockStreamingQuerierSeriesSet) Warnings() storage.Warnings {
+	return nil
+}
+
+// blockStreamingQuerierSeries is a series whose chunks are read from the stream when iterated.
+// Since chunks are streamed in the order of the series, series must be iterated in order, and
+// each series can be iterated only once.
+type blockStreamingQuerierSeries struct {
+	labels       labels.Labels
+	seriesIndex  uint64
+	streamReader chunkStreamReader
+}
+
+func (bqs *blockStreamingQuerierSeries) Labels() labels.Labels {
+	return bqs.labels
+}
+
+func (bqs *blockStreamingQuerierSeries) Iterator() chunkenc.Iterator {
+	chunks, err := bqs.streamReader.GetChunks(bqs.seriesIndex)
+	if err != nil {
+		return series.NewErrIterator(err)
+	}
+
+	return newBlockQuerierSeries(bqs.labels, chunks).Iterator()
+}
+
+// storeGatewayStreamReader reads the chunks streamed by a store-gateway, once the labels of
+// all series have been received.
+type storeGatewayStreamReader struct {
+	ctx                 context.Context
+	client              storegatewaypb.StoreGateway_SeriesClient
+	cancel              context.CancelFunc
+	expectedSeriesCount int
+	queryLimiter        *limiter.QueryLimiter
+	stats               *stats.Stats
+	log                 log.Logger
+
+	seriesChunksChan chan *storepb.StreamingChunksBatch
+	errorChan        chan error
+	chunksBatch      []*storepb.StreamingChunks
+	err              error
+}
+
+func newStoreGatewayStreamReader(ctx context.Context, client storegatewaypb.StoreGateway_SeriesClient, cancel context.CancelFunc, expectedSeriesCount int, queryLimiter *limiter.QueryLimiter, stats *stats.Stats, log log.Logger) *storeGatewayStreamReader {
+	return &storeGatewayStreamReader{
+		ctx:                 ctx,
+		client:              client,
+		cancel:              cancel,
+		expectedSeriesCount: expectedSeriesCount,
+		queryLimiter:        queryLimiter,
+		stats:               stats,
+		log:                 log,
+	}
+}
+
+// StartBuffering starts reading the chunks from the stream in the background. At most one batch
+// of chunks is buffered: the stream isn't read until the buffered batch has been consumed, which
+// applies back-pressure to the store-gateway through gRPC flow control.
+func (s *storeGatewayStreamReader) StartBuffering() {
+	s.seriesChunksChan = make(chan *storepb.StreamingChunksBatch, 1)
+
+	// The error channel is buffered so that the reading goroutine can exit even if nobody reads the error.
+	s.errorChan = make(chan error, 1)
+
+	go func() {
+		defer close(s.seriesChunksChan)
+
+		if err := s.readStream(s.ctx); err != nil {
+			s.errorChan <- err
+
+			if !errors.Is(err, context.Canceled) {
+				level.Warn(s.log).Log("msg", "failed to read chunks streamed by store-gateway", "err", err)
+			}
+		}
+	}()
+}
+
+func (s *storeGatewayStreamReader) readStream(ctx context.Context) error {
+	totalSeries := 0
+
+	for {
+		msg, err := s.client.Recv()
+		if errors.Is(err, io.EOF) {
+			if totalSeries < s.expectedSeriesCount {
+				return fmt.Errorf("expected to receive chunks for %d series, but the stream ended after %d series", s.expectedSeriesCount, totalSeries)
+			}
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to receive chunks")
+		}
+
+		batch := msg.GetStreamingChunks()
+		if batch == nil {
+			return fmt.Errorf("expected to receive streaming chunks, but received %T", msg.Result)
+		}
+		if len(batch.Series) == 0 {
+			continue
+		}
+
+		totalSeries += len(batch.Series)
+		if totalSeries > s.expectedSeriesCount {
+			return fmt.Errorf("expected to receive chunks for %d series, but received chunks for at least %d series", s.expectedSeriesCount, totalSeries)
+		}
+
+		chunksCount, chunksSize := 0, 0
+		for _, series := range batch.Series {
+			chunksCount += len(series.Chunks)
+			for _, c := range series.Chunks {
+				chunksSize += c.Size()
+			}
+		}
+		if chunkBytesLimitErr := s.queryLimiter.AddChunkBytes(chunksSize); chunkBytesLimitErr != nil {
+			return validation.LimitError(chunkBytesLimitErr.Error())
+		}
+		if chunkLimitErr := s.queryLimiter.AddChunks(chunksCount); chunkLimitErr != nil {
+			return validation.LimitError(chunkLimitErr.Error())
+		}
+
+		s.stats.AddFetchedChunkBytes(uint64(chunksSize))
+		s.stats.AddFetchedChunks(uint64(chunksCount))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case s.seriesChunksChan <- batch:
+		}
+	}
+}
+
+// GetChunks returns the chunks of the series with the input index. Series must be requested in
+// increasing index order: the chunks of the series which are skipped are discarded.
+func (s *storeGatewayStreamReader) GetChunks(seriesIndex uint64) ([]storepb.AggrChunk, error) {
+	for {
+		if len(s.chunksBatch) == 0 {
+			batch, channelOpen := <-s.seriesChunksChan
+			if !channelOpen {
+				// Keep the error, if any, so that it's returned for all the following series too.
+				select {
+				case s.err = <-s.errorChan:
+				default:
+				}
+
+				if s.err != nil {
+					return nil, errors.Wrapf(s.err, "attempted to read chunks of series at index %d from the store-gateway stream, but the stream has failed", seriesIndex)
+				}
+				return nil, fmt.Errorf("attempted to read chunks of series at index %d from the store-gateway stream, but the stream has already been exhausted", seriesIndex)
+			}
+
+			s.chunksBatch = batch.Series
+		}
+
+		next := s.chunksBatch[0]
+		if next.SeriesIndex > seriesIndex {
+			return nil, fmt.Errorf("attempted to read chunks of series at index %d from the store-gateway stream, but the stream has already returned series at index %d", seriesIndex, next.SeriesIndex)
+		}
+
+		s.chunksBatch = s.chunksBatch[1:]
+		if next.SeriesIndex == seriesIndex {
+			return next.Chunks, nil
+		}
+	}
+}
+
+// Close cancels the stream, releasing the resources held by the store-gateway for it.
+func (s *storeGatewayStreamReader) Close() {
+	s.cancel()
+}
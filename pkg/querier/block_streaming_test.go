@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/storegateway/storepb"
+	"github.com/grafana/mimir/pkg/util/limiter"
+)
+
+func TestStoreGatewayStreamReader_HappyPaths(t *testing.T) {
+	series0 := []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 1})}
+	series1 := []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 2})}
+	series2 := []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 3})}
+	series3 := []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 4})}
+	series4 := []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 5})}
+
+	tests := map[string][]*storepb.SeriesResponse{
+		"single series per batch": {
+			mockStreamingChunksResponse(&storepb.StreamingChunks{SeriesIndex: 0, Chunks: series0}),
+			mockStreamingChunksResponse(&storepb.StreamingChunks{SeriesIndex: 1, Chunks: series1}),
+			mockStreamingChunksResponse(&storepb.StreamingChunks{SeriesIndex: 2, Chunks: series2}),
+			mockStreamingChunksResponse(&storepb.StreamingChunks{SeriesIndex: 3, Chunks: series3}),
+			mockStreamingChunksResponse(&storepb.StreamingChunks{SeriesIndex: 4, Chunks: series4}),
+		},
+		"multiple series per batch": {
+			mockStreamingChunksResponse(
+				&storepb.StreamingChunks{SeriesIndex: 0, Chunks: series0},
+				&storepb.StreamingChunks{SeriesIndex: 1, Chunks: series1},
+				&storepb.StreamingChunks{SeriesIndex: 2, Chunks: series2},
+			),
+			mockStreamingChunksResponse(
+				&storepb.StreamingChunks{SeriesIndex: 3, Chunks: series3},
+				&storepb.StreamingChunks{SeriesIndex: 4, Chunks: series4},
+			),
+		},
+		"empty batches": {
+			mockStreamingChunksResponse(
+				&storepb.StreamingChunks{SeriesIndex: 0, Chunks: series0},
+				&storepb.StreamingChunks{SeriesIndex: 1, Chunks: series1},
+				&storepb.StreamingChunks{SeriesIndex: 2, Chunks: series2},
+			),
+			mockStreamingChunksResponse(),
+			mockStreamingChunksResponse(
+				&storepb.StreamingChunks{SeriesIndex: 3, Chunks: series3},
+				&storepb.StreamingChunks{SeriesIndex: 4, Chunks: series4},
+			),
+			mockStreamingChunksResponse(),
+		},
+	}
+
+	for name, responses := range tests {
+		t.Run(name, func(t *testing.T) {
+			reqStats := &stats.Stats{}
+			reader := newTestStoreGatewayStreamReader(responses, 5, limiter.NewQueryLimiter(0, 0, 0), reqStats)
+			defer reader.Close()
+
+			for i, expected := range [][]storepb.AggrChunk{series0, series1, series2, series3, series4} {
+				actual, err := reader.GetChunks(uint64(i))
+				require.NoError(t, err)
+				require.Equal(t, expected, actual)
+			}
+
+			assert.Equal(t, uint64(5), reqStats.LoadFetchedChunks())
+		})
+	}
+}
+
+func TestStoreGatewayStreamReader_SkippedSeries(t *testing.T) {
+	series1 := []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 2})}
+	series3 := []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 4})}
+
+	reader := newTestStoreGatewayStreamReader([]*storepb.SeriesResponse{
+		mockStreamingChunksResponse(
+			&storepb.StreamingChunks{SeriesIndex: 0, Chunks: []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 1})}},
+			&storepb.StreamingChunks{SeriesIndex: 1, Chunks: series1},
+		),
+		mockStreamingChunksResponse(
+			&storepb.StreamingChunks{SeriesIndex: 2, Chunks: []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 3})}},
+			&storepb.StreamingChunks{SeriesIndex: 3, Chunks: series3},
+		),
+	}, 4, limiter.NewQueryLimiter(0, 0, 0), nil)
+	defer reader.Close()
+
+	actual, err := reader.GetChunks(1)
+	require.NoError(t, err)
+	require.Equal(t, series1, actual)
+
+	actual, err = reader.GetChunks(3)
+	require.NoError(t, err)
+	require.Equal(t, series3, actual)
+}
+
+func TestStoreGatewayStreamReader_ReadingSeriesOutOfOrder(t *testing.T) {
+	reader := newTestStoreGatewayStreamReader([]*storepb.SeriesResponse{
+		mockStreamingChunksResponse(
+			&storepb.StreamingChunks{SeriesIndex: 0, Chunks: []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 1})}},
+			&storepb.StreamingChunks{SeriesIndex: 1, Chunks: []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 2})}},
+		),
+	}, 2, limiter.NewQueryLimiter(0, 0, 0), nil)
+	defer reader.Close()
+
+	_, err := reader.GetChunks(1)
+	require.NoError(t, err)
+
+	_, err = reader.GetChunks(0)
+	require.EqualError(t, err, "attempted to read chunks of series at index 0 from the store-gateway stream, but the stream has already been exhausted")
+}
+
+func TestStoreGatewayStreamReader_ReadingTheSameSeriesTwice(t *testing.T) {
+	reader := newTestStoreGatewayStreamReader([]*storepb.SeriesResponse{
+		mockStreamingChunksResponse(
+			&storepb.StreamingChunks{SeriesIndex: 0, Chunks: []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 1})}},
+			&storepb.StreamingChunks{SeriesIndex: 1, Chunks: []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 2})}},
+		),
+	}, 2, limiter.NewQueryLimiter(0, 0, 0), nil)
+	defer reader.Close()
+
+	_, err := reader.GetChunks(0)
+	require.NoError(t, err)
+
+	_, err = reader.GetChunks(0)
+	require.EqualError(t, err, "attempted to read chunks of series at index 0 from the store-gateway stream, but the stream has already returned series at index 1")
+}
+
+func TestStoreGatewayStreamReader_StreamFailures(t *testing.T) {
+	chunk := []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: 1000, V: 1})}
+
+	tests := map[string]struct {
+		responses           []*storepb.SeriesResponse
+		expectedSeriesCount int
+		queryLimiter        *limiter.QueryLimiter
+		expectedErr         string
+	}{
+		"stream ends before the chunks of all series have been received": {
+			responses: []*storepb.SeriesResponse{
+				mockStreamingChunksResponse(&storepb.StreamingChunks{SeriesIndex: 0, Chunks: chunk}),
+			},
+			expectedSeriesCount: 2,
+			queryLimiter:        limiter.NewQueryLimiter(0, 0, 0),
+			expectedErr:         "expected to receive chunks for 2 series, but the stream ended after 1 series",
+		},
+		"stream contains more series than expected": {
+			responses: []*storepb.SeriesResponse{
+				mockStreamingChunksResponse(&storepb.StreamingChunks{SeriesIndex: 0, Chunks: chunk}),
+				mockStreamingChunksResponse(&storepb.StreamingChunks{SeriesIndex: 1, Chunks: chunk}, &storepb.StreamingChunks{SeriesIndex: 2, Chunks: chunk}),
+			},
+			expectedSeriesCount: 2,
+			queryLimiter:        limiter.NewQueryLimiter(0, 0, 0),
+			expectedErr:         "expected to receive chunks for 2 series, but received chunks for at least 3 series",
+		},
+		"stream contains an unexpected response": {
+			responses: []*storepb.SeriesResponse{
+				mockStreamingChunksResponse(&storepb.StreamingChunks{SeriesIndex: 0, Chunks: chunk}),
+				mockSeriesResponse(labels.FromStrings(labels.MetricName, "series_1"), 1000, 1),
+			},
+			expectedSeriesCount: 2,
+			queryLimiter:        limiter.NewQueryLimiter(0, 0, 0),
+			expectedErr:         "expected to receive streaming chunks, but received *storepb.SeriesResponse_Series",
+		},
+		"max chunks per query limit exceeded": {
+			responses: []*storepb.SeriesResponse{
+				mockStreamingChunksResponse(&storepb.StreamingChunks{SeriesIndex: 0, Chunks: chunk}),
+				mockStreamingChunksResponse(&storepb.StreamingChunks{SeriesIndex: 1, Chunks: chunk}),
+			},
+			expectedSeriesCount: 2,
+			queryLimiter:        limiter.NewQueryLimiter(0, 0, 1),
+			expectedErr:         "the query exceeded the maximum number of chunks",
+		},
+		"max chunk bytes per query limit exceeded": {
+			responses: []*storepb.SeriesResponse{
+				mockStreamingChunksResponse(&storepb.StreamingChunks{SeriesIndex: 0, Chunks: chunk}),
+				mockStreamingChunksResponse(&storepb.StreamingChunks{SeriesIndex: 1, Chunks: chunk}),
+			},
+			expectedSeriesCount: 2,
+			queryLimiter:        limiter.NewQueryLimiter(0, chunk[0].Size(), 0),
+			expectedErr:         "the query exceeded the aggregated chunks size limit",
+		},
+	}
+
+	for name, testData := range tests {
+		t.Run(name, func(t *testing.T) {
+			reader := newTestStoreGatewayStreamReader(testData.responses, testData.expectedSeriesCount, testData.queryLimiter, nil)
+			defer reader.Close()
+
+			_, err := reader.GetChunks(0)
+			require.NoError(t, err)
+
+			_, err = reader.GetChunks(1)
+			require.ErrorContains(t, err, "attempted to read chunks of series at index 1 from the store-gateway stream, but the stream has failed")
+			require.ErrorContains(t, err, testData.expectedErr)
+
+			// The error is returned for all the following series too.
+			_, err = reader.GetChunks(2)
+			require.ErrorContains(t, err, testData.expectedErr)
+		})
+	}
+}
+
+func TestStoreGatewayStreamReader_Close(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := newStoreGatewayStreamReader(ctx, &blockingSeriesClientMock{ctx: ctx}, cancel, 1, limiter.NewQueryLimiter(0, 0, 0), nil, log.NewNopLogger())
+	reader.StartBuffering()
+	reader.Close()
+
+	_, err := reader.GetChunks(0)
+	require.True(t, errors.Is(err, context.Canceled))
+}
+
+func newTestStoreGatewayStreamReader(responses []*storepb.SeriesResponse, expectedSeriesCount int, queryLimiter *limiter.QueryLimiter, reqStats *stats.Stats) *storeGatewayStreamReader {
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := newStoreGatewayStreamReader(ctx, &storeGatewaySeriesClientMock{mockedResponses: responses}, cancel, expectedSeriesCount, queryLimiter, reqStats, log.NewNopLogger())
+	reader.StartBuffering()
+	return reader
+}
+
+func mockStreamingChunksResponse(series ...*storepb.StreamingChunks) *storepb.SeriesResponse {
+	return storepb.NewStreamingChunksResponse(&storepb.StreamingChunksBatch{Series: series})
+}
@@ -71,6 +71,63 @@ func TestBlockQuerierSeries(t *testing.T) {
 			expectedMetric: labels.Labels{labels.Label{Name: "foo", Value: "bar"}},
 			expectedErr:    `cannot iterate chunk for series: {foo="bar"}: EOF`,
 		},
+		"should return the average of downsampled chunks with count and sum aggregates": {
+			series: &storepb.Series{
+				Labels: []labelpb.ZLabel{{Name: "foo", Value: "bar"}},
+				Chunks: []storepb.AggrChunk{
+					{
+						MinTime: 1000, MaxTime: 2000,
+						Count: &storepb.Chunk{Type: storepb.Chunk_XOR, Data: mockXORChunkData(promql.Point{T: 1000, V: 2}, promql.Point{T: 2000, V: 4})},
+						Sum:   &storepb.Chunk{Type: storepb.Chunk_XOR, Data: mockXORChunkData(promql.Point{T: 1000, V: 10}, promql.Point{T: 2000, V: 10})},
+					},
+				},
+			},
+			expectedMetric: labels.Labels{{Name: "foo", Value: "bar"}},
+			expectedSamples: []model.SamplePair{
+				{Timestamp: 1000, Value: 5},
+				{Timestamp: 2000, Value: 2.5},
+			},
+		},
+		"should return the requested aggregate of downsampled chunks": {
+			series: &storepb.Series{
+				Labels: []labelpb.ZLabel{{Name: "foo", Value: "bar"}},
+				Chunks: []storepb.AggrChunk{
+					{MinTime: 1000, MaxTime: 2000, Max: &storepb.Chunk{Type: storepb.Chunk_XOR, Data: mockXORChunkData(promql.Point{T: 1000, V: 7}, promql.Point{T: 2000, V: 9})}},
+				},
+			},
+			expectedMetric: labels.Labels{{Name: "foo", Value: "bar"}},
+			expectedSamples: []model.SamplePair{
+				{Timestamp: 1000, Value: 7},
+				{Timestamp: 2000, Value: 9},
+			},
+		},
+		"should apply counter resets across raw and downsampled chunks": {
+			series: &storepb.Series{
+				Labels: []labelpb.ZLabel{{Name: "foo", Value: "bar"}},
+				Chunks: []storepb.AggrChunk{
+					// The last sample of the counter aggregate duplicates the timestamp of the previous one, holding the last raw value.
+					{MinTime: 1000, MaxTime: 2000, Counter: &storepb.Chunk{Type: storepb.Chunk_XOR, Data: mockXORChunkData(promql.Point{T: 1000, V: 5}, promql.Point{T: 2000, V: 10}, promql.Point{T: 2000, V: 12})}},
+					{MinTime: 3000, MaxTime: 4000, Raw: &storepb.Chunk{Type: storepb.Chunk_XOR, Data: mockXORChunkData(promql.Point{T: 3000, V: 2}, promql.Point{T: 4000, V: 4})}},
+				},
+			},
+			expectedMetric: labels.Labels{{Name: "foo", Value: "bar"}},
+			expectedSamples: []model.SamplePair{
+				{Timestamp: 1000, Value: 5},
+				{Timestamp: 2000, Value: 10},
+				{Timestamp: 3000, Value: 12},
+				{Timestamp: 4000, Value: 14},
+			},
+		},
+		"should return error on chunk without raw data and aggregates": {
+			series: &storepb.Series{
+				Labels: []labelpb.ZLabel{{Name: "foo", Value: "bar"}},
+				Chunks: []storepb.AggrChunk{
+					{MinTime: 1000, MaxTime: 2000},
+				},
+			},
+			expectedMetric: labels.Labels{labels.Label{Name: "foo", Value: "bar"}},
+			expectedErr:    `failed to initialize chunk from aggregated data (series: {foo="bar"} min time: 1000 max time: 2000): no raw data or aggregate in chunk`,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -116,6 +173,20 @@ func mockTSDBChunkData() []byte {
 	return chunk.Bytes()
 }
 
+func mockXORChunkData(points ...promql.Point) []byte {
+	chunk := chunkenc.NewXORChunk()
+	appender, err := chunk.Appender()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, p := range points {
+		appender.Append(p.T, p.V)
+	}
+
+	return chunk.Bytes()
+}
+
 type timeRange struct {
 	minT time.Time
 	maxT time.Time
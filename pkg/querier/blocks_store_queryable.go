@@ -43,8 +43,10 @@ import (
 	"github.com/grafana/mimir/pkg/storage/sharding"
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/storage/tsdb/downsample"
 	"github.com/grafana/mimir/pkg/storegateway"
 	"github.com/grafana/mimir/pkg/storegateway/hintspb"
+	"github.com/grafana/mimir/pkg/storegateway/labelpb"
 	"github.com/grafana/mimir/pkg/storegateway/storegatewaypb"
 	"github.com/grafana/mimir/pkg/storegateway/storepb"
 	"github.com/grafana/mimir/pkg/util"
@@ -77,7 +79,7 @@ type BlocksStoreSet interface {
 	// GetClientsFor returns the store gateway clients that should be used to
 	// query the set of blocks in input. The exclude parameter is the map of
 	// blocks -> store-gateway addresses that should be excluded.
-	GetClientsFor(userID string, blockIDs []ulid.ULID, exclude map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error)
+	GetClientsFor(userID string, blocks bucketindex.Blocks, exclude map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error)
 }
 
 // BlocksFinder is the interface used to find blocks for a given user and time range.
@@ -106,6 +108,7 @@ type BlocksStoreLimits interface {
 	MaxLabelsQueryLength(userID string) time.Duration
 	MaxChunksPerQuery(userID string) int
 	StoreGatewayTenantShardSize(userID string) int
+	PartialResponsesEnabled(userID string) bool
 }
 
 type blocksStoreQueryableMetrics struct {
@@ -115,6 +118,7 @@ type blocksStoreQueryableMetrics struct {
 	blocksFound                                       prometheus.Counter
 	blocksQueried                                     prometheus.Counter
 	blocksWithCompactorShardButIncompatibleQueryShard prometheus.Counter
+	partialResponses                                  prometheus.Counter
 }
 
 func newBlocksStoreQueryableMetrics(reg prometheus.Registerer) *blocksStoreQueryableMetrics {
@@ -144,6 +148,10 @@ func newBlocksStoreQueryableMetrics(reg prometheus.Registerer) *blocksStoreQuery
 			Name: "cortex_querier_blocks_with_compactor_shard_but_incompatible_query_shard_total",
 			Help: "Blocks that couldn't be checked for query and compactor sharding optimization due to incompatible shard counts.",
 		}),
+		partialResponses: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_querier_storegateway_partial_responses_total",
+			Help: "Number of requests to store-gateways which returned a partial response because some blocks couldn't be queried.",
+		}),
 	}
 }
 
@@ -152,13 +160,15 @@ func newBlocksStoreQueryableMetrics(reg prometheus.Registerer) *blocksStoreQuery
 type BlocksStoreQueryable struct {
 	services.Service
 
-	stores          BlocksStoreSet
-	finder          BlocksFinder
-	consistency     *BlocksConsistencyChecker
-	logger          log.Logger
-	queryStoreAfter time.Duration
-	metrics         *blocksStoreQueryableMetrics
-	limits          BlocksStoreLimits
+	stores                   BlocksStoreSet
+	finder                   BlocksFinder
+	consistency              *BlocksConsistencyChecker
+	logger                   log.Logger
+	queryStoreAfter          time.Duration
+	storeGatewayQueryTimeout time.Duration
+	streamingChunksBatchSize uint64
+	metrics                  *blocksStoreQueryableMetrics
+	limits                   BlocksStoreLimits
 
 	// Subservices manager.
 	subservices        *services.Manager
@@ -171,6 +181,8 @@ func NewBlocksStoreQueryable(
 	consistency *BlocksConsistencyChecker,
 	limits BlocksStoreLimits,
 	queryStoreAfter time.Duration,
+	storeGatewayQueryTimeout time.Duration,
+	streamingChunksBatchSize uint64,
 	logger log.Logger,
 	reg prometheus.Registerer,
 ) (*BlocksStoreQueryable, error) {
@@ -180,15 +192,17 @@ func NewBlocksStoreQueryable(
 	}
 
 	q := &BlocksStoreQueryable{
-		stores:             stores,
-		finder:             finder,
-		consistency:        consistency,
-		queryStoreAfter:    queryStoreAfter,
-		logger:             logger,
-		subservices:        manager,
-		subservicesWatcher: services.NewFailureWatcher(),
-		metrics:            newBlocksStoreQueryableMetrics(reg),
-		limits:             limits,
+		stores:                   stores,
+		finder:                   finder,
+		consistency:              consistency,
+		queryStoreAfter:          queryStoreAfter,
+		storeGatewayQueryTimeout: storeGatewayQueryTimeout,
+		streamingChunksBatchSize: streamingChunksBatchSize,
+		logger:                   logger,
+		subservices:              manager,
+		subservicesWatcher:       services.NewFailureWatcher(),
+		metrics:                  newBlocksStoreQueryableMetrics(reg),
+		limits:                   limits,
 	}
 
 	q.Service = services.NewBasicService(q.starting, q.running, q.stopping)
@@ -253,7 +267,7 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 		return nil, errors.Wrap(err, "failed to create store-gateway ring client")
 	}
 
-	stores, err = newBlocksStoreReplicationSet(storesRing, randomLoadBalancing, limits, querierCfg.StoreGatewayClient, logger, reg)
+	stores, err = newBlocksStoreReplicationSet(storesRing, randomLoadBalancing, gatewayCfg.TimeBasedReplication, limits, querierCfg.StoreGatewayClient, logger, reg)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create store set")
 	}
@@ -270,7 +284,12 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 		reg,
 	)
 
-	return NewBlocksStoreQueryable(stores, finder, consistency, limits, querierCfg.QueryStoreAfter, logger, reg)
+	var streamingChunksBatchSize uint64
+	if querierCfg.PreferStreamingChunksFromStoreGateways {
+		streamingChunksBatchSize = querierCfg.StreamingChunksBatchSize
+	}
+
+	return NewBlocksStoreQueryable(stores, finder, consistency, limits, querierCfg.QueryStoreAfter, querierCfg.StoreGatewayQueryTimeout, streamingChunksBatchSize, logger, reg)
 }
 
 func (q *BlocksStoreQueryable) starting(ctx context.Context) error {
@@ -309,18 +328,27 @@ func (q *BlocksStoreQueryable) Querier(ctx context.Context, mint, maxt int64) (s
 		return nil, err
 	}
 
+	// The partial response mode set in the context (eg. from the request header) takes precedence over the per-tenant setting.
+	partialResponse, ok := partialResponseFromContext(ctx)
+	if !ok {
+		partialResponse = q.limits.PartialResponsesEnabled(userID)
+	}
+
 	return &blocksStoreQuerier{
-		ctx:             ctx,
-		minT:            mint,
-		maxT:            maxt,
-		userID:          userID,
-		finder:          q.finder,
-		stores:          q.stores,
-		metrics:         q.metrics,
-		limits:          q.limits,
-		consistency:     q.consistency,
-		logger:          q.logger,
-		queryStoreAfter: q.queryStoreAfter,
+		ctx:                      ctx,
+		minT:                     mint,
+		maxT:                     maxt,
+		userID:                   userID,
+		finder:                   q.finder,
+		stores:                   q.stores,
+		metrics:                  q.metrics,
+		limits:                   q.limits,
+		consistency:              q.consistency,
+		logger:                   q.logger,
+		queryStoreAfter:          q.queryStoreAfter,
+		storeGatewayQueryTimeout: q.storeGatewayQueryTimeout,
+		streamingChunksBatchSize: q.streamingChunksBatchSize,
+		partialResponse:          partialResponse,
 	}, nil
 }
 
@@ -338,6 +366,21 @@ type blocksStoreQuerier struct {
 	// If set, the querier manipulates the max time to not be greater than
 	// "now - queryStoreAfter" so that most recent blocks are not queried.
 	queryStoreAfter time.Duration
+
+	// If set, the store-gateways must answer within this timeout, otherwise
+	// the blocks they've been requested are considered not queried.
+	storeGatewayQueryTimeout time.Duration
+
+	// If true, blocks which couldn't be queried from store-gateways are reported as
+	// warnings instead of failing the query.
+	partialResponse bool
+
+	// If greater than 0, store-gateways are requested to stream the chunks in batches of this
+	// size after the series labels. The chunks are read from the streams while the series are
+	// iterated, and the streams are closed when the querier is closed.
+	streamingChunksBatchSize uint64
+	streamReadersMtx         sync.Mutex
+	streamReaders            []*storeGatewayStreamReader
 }
 
 // Select implements storage.Querier interface.
@@ -368,8 +411,8 @@ func (q *blocksStoreQuerier) LabelNames(matchers ...*labels.Matcher) ([]string,
 		convertedMatchers = convertMatchersToLabelMatcher(matchers)
 	)
 
-	queryFunc := func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error) {
-		nameSets, warnings, queriedBlocks, err := q.fetchLabelNamesFromStore(spanCtx, clients, minT, maxT, convertedMatchers)
+	queryFunc := func(ctx context.Context, clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error) {
+		nameSets, warnings, queriedBlocks, err := q.fetchLabelNamesFromStore(ctx, clients, minT, maxT, convertedMatchers)
 		if err != nil {
 			return nil, err
 		}
@@ -380,12 +423,13 @@ func (q *blocksStoreQuerier) LabelNames(matchers ...*labels.Matcher) ([]string,
 		return queriedBlocks, nil
 	}
 
-	err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, nil, queryFunc)
+	// Label names and values are the same in all resolutions, so we query the blocks with the coarsest one.
+	consistencyWarnings, err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, downsample.Resolution1h, nil, queryFunc)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return strutil.MergeSlices(resNameSets...), resWarnings, nil
+	return strutil.MergeSlices(resNameSets...), append(resWarnings, consistencyWarnings...), nil
 }
 
 func (q *blocksStoreQuerier) LabelValues(name string, matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
@@ -409,8 +453,8 @@ func (q *blocksStoreQuerier) LabelValues(name string, matchers ...*labels.Matche
 		resWarnings  = storage.Warnings(nil)
 	)
 
-	queryFunc := func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error) {
-		valueSets, warnings, queriedBlocks, err := q.fetchLabelValuesFromStore(spanCtx, name, clients, minT, maxT, matchers...)
+	queryFunc := func(ctx context.Context, clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error) {
+		valueSets, warnings, queriedBlocks, err := q.fetchLabelValuesFromStore(ctx, name, clients, minT, maxT, matchers...)
 		if err != nil {
 			return nil, err
 		}
@@ -421,15 +465,24 @@ func (q *blocksStoreQuerier) LabelValues(name string, matchers ...*labels.Matche
 		return queriedBlocks, nil
 	}
 
-	err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, nil, queryFunc)
+	// Label names and values are the same in all resolutions, so we query the blocks with the coarsest one.
+	consistencyWarnings, err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, downsample.Resolution1h, nil, queryFunc)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return strutil.MergeSlices(resValueSets...), resWarnings, nil
+	return strutil.MergeSlices(resValueSets...), append(resWarnings, consistencyWarnings...), nil
 }
 
 func (q *blocksStoreQuerier) Close() error {
+	q.streamReadersMtx.Lock()
+	defer q.streamReadersMtx.Unlock()
+
+	for _, r := range q.streamReaders {
+		r.Close()
+	}
+	q.streamReaders = nil
+
 	return nil
 }
 
@@ -453,8 +506,10 @@ func (q *blocksStoreQuerier) selectSorted(sp *storage.SelectHints, matchers ...*
 		return storage.ErrSeriesSet(err)
 	}
 
-	queryFunc := func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error) {
-		seriesSets, queriedBlocks, warnings, numChunks, err := q.fetchSeriesFromStores(spanCtx, sp, clients, minT, maxT, matchers, convertedMatchers, maxChunksLimit, leftChunksLimit)
+	maxResolution := maxResolutionFromHints(sp)
+
+	queryFunc := func(ctx context.Context, clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error) {
+		seriesSets, queriedBlocks, warnings, numChunks, err := q.fetchSeriesFromStores(ctx, sp, clients, minT, maxT, maxResolution, matchers, convertedMatchers, maxChunksLimit, leftChunksLimit)
 		if err != nil {
 			return nil, err
 		}
@@ -471,10 +526,11 @@ func (q *blocksStoreQuerier) selectSorted(sp *storage.SelectHints, matchers ...*
 		return queriedBlocks, nil
 	}
 
-	err = q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, shard, queryFunc)
+	consistencyWarnings, err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, maxResolution, shard, queryFunc)
 	if err != nil {
 		return storage.ErrSeriesSet(err)
 	}
+	resWarnings = append(resWarnings, consistencyWarnings...)
 
 	if len(resSeriesSets) == 0 {
 		storage.EmptySeriesSet()
@@ -485,8 +541,12 @@ func (q *blocksStoreQuerier) selectSorted(sp *storage.SelectHints, matchers ...*
 		resWarnings)
 }
 
-func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logger log.Logger, minT, maxT int64, shard *sharding.ShardSelector,
-	queryFunc func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error)) error {
+// queryWithConsistencyCheck runs queryFunc against the store-gateways holding the blocks for the input time range,
+// retrying missing blocks on other store-gateways. If the partial response mode is enabled, the blocks which couldn't
+// be queried are returned as warnings instead of an error. Blocks are selected with the coarsest resolution available
+// which is not greater than maxResolution.
+func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logger log.Logger, minT, maxT, maxResolution int64, shard *sharding.ShardSelector,
+	queryFunc func(ctx context.Context, clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error)) (storage.Warnings, error) {
 	// If queryStoreAfter is enabled, we do manipulate the query maxt to query samples up until
 	// now - queryStoreAfter, because the most recent time range is covered by ingesters. This
 	// optimization is particularly important for the blocks storage because can be used to skip
@@ -503,24 +563,31 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 		if maxT < minT {
 			q.metrics.storesHit.Observe(0)
 			level.Debug(logger).Log("msg", "empty query time range after max time manipulation")
-			return nil
+			return nil, nil
 		}
 	}
 
 	// Find the list of blocks we need to query given the time range.
 	knownBlocks, knownDeletionMarks, err := q.finder.GetBlocks(ctx, q.userID, minT, maxT)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(knownBlocks) == 0 {
 		q.metrics.storesHit.Observe(0)
 		level.Debug(logger).Log("msg", "no blocks found")
-		return nil
+		return nil, nil
 	}
 
 	q.metrics.blocksFound.Add(float64(len(knownBlocks)))
 
+	// Downsampled blocks overlap the raw blocks they've been generated from, so we only query one resolution
+	// for each time range.
+	if result := selectBlocksByResolution(knownBlocks, minT, maxT, maxResolution); len(result) != len(knownBlocks) {
+		level.Debug(logger).Log("msg", "filtered blocks by resolution", "maxResolution", maxResolution, "before", len(knownBlocks), "after", len(result))
+		knownBlocks = result
+	}
+
 	if shard != nil && shard.ShardCount > 0 {
 		level.Debug(logger).Log("msg", "filtering blocks due to sharding", "blocksBeforeFiltering", knownBlocks.String(), "shardID", shard.LabelValue())
 
@@ -538,34 +605,50 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 
 	var (
 		// At the beginning the list of blocks to query are all known blocks.
-		remainingBlocks = knownBlocks.GetULIDs()
+		remainingBlocks = knownBlocks
 		attemptedBlocks = map[ulid.ULID][]string{}
 		touchedStores   = map[string]struct{}{}
 
 		resQueriedBlocks = []ulid.ULID(nil)
+
+		// The context used to query the store-gateways, which is subject to the store-gateway query timeout.
+		storesCtx = ctx
 	)
 
+	if q.storeGatewayQueryTimeout > 0 {
+		var cancel context.CancelFunc
+		storesCtx, cancel = context.WithTimeout(ctx, q.storeGatewayQueryTimeout)
+		defer cancel()
+	}
+
 	for attempt := 1; attempt <= maxFetchSeriesAttempts; attempt++ {
+		// Do not retry if the store-gateway query timeout has been reached.
+		if storesCtx.Err() != nil {
+			level.Warn(logger).Log("msg", "store-gateway query timeout reached while fetching blocks", "attempt", attempt, "timeout", q.storeGatewayQueryTimeout)
+			break
+		}
+
 		// Find the set of store-gateway instances having the blocks. The exclude parameter is the
 		// map of blocks queried so far, with the list of store-gateway addresses for each block.
 		clients, err := q.stores.GetClientsFor(q.userID, remainingBlocks, attemptedBlocks)
 		if err != nil {
 			// If it's a retry and we get an error, it means there are no more store-gateways left
-			// from which running another attempt, so we're just stopping retrying.
-			if attempt > 1 {
-				level.Warn(logger).Log("msg", "unable to get store-gateway clients while retrying to fetch missing blocks", "err", err)
+			// from which running another attempt, so we're just stopping retrying. In partial
+			// response mode we're also not failing the query if no store-gateway can be found.
+			if attempt > 1 || q.partialResponse {
+				level.Warn(logger).Log("msg", "unable to get store-gateway clients while fetching blocks", "attempt", attempt, "err", err)
 				break
 			}
 
-			return err
+			return nil, err
 		}
 		level.Debug(logger).Log("msg", "found store-gateway instances to query", "num instances", len(clients), "attempt", attempt)
 
 		// Fetch series from stores. If an error occur we do not retry because retries
 		// are only meant to cover missing blocks.
-		queriedBlocks, err := queryFunc(clients, minT, maxT)
+		queriedBlocks, err := queryFunc(storesCtx, clients, minT, maxT)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		level.Debug(logger).Log("msg", "received series from all store-gateways", "queried blocks", strings.Join(convertULIDsToString(queriedBlocks), " "))
 
@@ -586,24 +669,68 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 			q.metrics.storesHit.Observe(float64(len(touchedStores)))
 			q.metrics.refetches.Observe(float64(attempt - 1))
 
-			return nil
+			return nil, nil
 		}
 
 		level.Debug(logger).Log("msg", "consistency check failed", "attempt", attempt, "missing blocks", strings.Join(convertULIDsToString(missingBlocks), " "))
 
 		// The next attempt should just query the missing blocks.
-		remainingBlocks = missingBlocks
+		remainingBlocks = filterBlocksByIDs(knownBlocks, missingBlocks)
+	}
+
+	// If the query has been canceled or timed out, we return its error instead of the missing blocks.
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
 	// We've not been able to query all expected blocks after all retries.
+	if q.partialResponse {
+		q.metrics.partialResponses.Inc()
+		level.Warn(util_log.WithContext(ctx, logger)).Log("msg", "returning partial response because some blocks were not queried", "blocks", strings.Join(convertULIDsToString(remainingBlocks.GetULIDs()), " "))
+		return storage.Warnings{newStorePartialResponseWarning(knownBlocks, remainingBlocks.GetULIDs())}, nil
+	}
+
 	level.Warn(util_log.WithContext(ctx, logger)).Log("msg", "failed consistency check", "err", err)
-	return newStoreConsistencyCheckFailedError(remainingBlocks)
+	return nil, newStoreConsistencyCheckFailedError(remainingBlocks.GetULIDs())
+}
+
+// filterBlocksByIDs returns the blocks whose ID is in the input IDs.
+func filterBlocksByIDs(blocks bucketindex.Blocks, ids []ulid.ULID) bucketindex.Blocks {
+	keep := make(map[ulid.ULID]struct{}, len(ids))
+	for _, id := range ids {
+		keep[id] = struct{}{}
+	}
+
+	filtered := make(bucketindex.Blocks, 0, len(ids))
+	for _, b := range blocks {
+		if _, ok := keep[b.ID]; ok {
+			filtered = append(filtered, b)
+		}
+	}
+	return filtered
 }
 
 func newStoreConsistencyCheckFailedError(remainingBlocks []ulid.ULID) error {
 	return fmt.Errorf("%v. The non-queried blocks are: %s", globalerror.StoreConsistencyCheckFailed.Message("the consistency check failed because some blocks were not queried"), strings.Join(convertULIDsToString(remainingBlocks), " "))
 }
 
+// newStorePartialResponseWarning returns the warning listing the blocks, and their time range, which were not queried.
+func newStorePartialResponseWarning(knownBlocks bucketindex.Blocks, remainingBlocks []ulid.ULID) error {
+	remaining := make(map[ulid.ULID]struct{}, len(remainingBlocks))
+	for _, id := range remainingBlocks {
+		remaining[id] = struct{}{}
+	}
+
+	missing := make([]string, 0, len(remainingBlocks))
+	for _, b := range knownBlocks {
+		if _, ok := remaining[b.ID]; ok {
+			missing = append(missing, fmt.Sprintf("%s (%s - %s)", b.ID.String(), util.FormatTimeMillis(b.MinTime), util.FormatTimeMillis(b.MaxTime)))
+		}
+	}
+
+	return fmt.Errorf("partial response: some blocks were not queried because store-gateways did not respond in time or were unavailable. The non-queried blocks are: %s", strings.Join(missing, ", "))
+}
+
 // filterBlocksByShard removes blocks that can be safely ignored when using query sharding. We know that block can be safely
 // ignored, if it was compacted using split-and-merge compactor, and it has a valid compactor shard ID. We exploit the
 // fact that split-and-merge compactor and query-sharding use the same series-sharding algorithm.
@@ -644,6 +771,87 @@ func filterBlocksByShard(blocks bucketindex.Blocks, queryShardIndex, queryShardC
 	return blocks, incompatibleBlocks
 }
 
+// selectBlocksByResolution returns the blocks to query to cover the time range between minT and maxT (both included),
+// preferring the blocks with the coarsest resolution which is not greater than maxResolution. The time ranges not
+// covered by blocks at a given resolution are filled with blocks at the next finer resolution. The input order of
+// blocks is preserved. Downsampled blocks not covering every compactor shard of the finer blocks they would replace
+// are ignored, so that the time range they cover is filled with the finer blocks.
+func selectBlocksByResolution(blocks bucketindex.Blocks, minT, maxT, maxResolution int64) bucketindex.Blocks {
+	// Fast path: no downsampled blocks.
+	allRaw := true
+	for _, b := range blocks {
+		if b.Resolution != downsample.ResolutionRaw {
+			allRaw = false
+			break
+		}
+	}
+	if allRaw {
+		return blocks
+	}
+
+	refs := make([]downsample.BlockRef, 0, len(blocks))
+	for _, b := range blocks {
+		refs = append(refs, downsample.BlockRef{MinTime: b.MinTime, MaxTime: b.MaxTime, Resolution: b.Resolution, CompactorShardID: b.CompactorShardID})
+	}
+	incomplete := downsample.IncompleteBlocks(refs)
+
+	byResolution := make(map[int64]bucketindex.Blocks, len(downsample.Resolutions))
+	for i, b := range blocks {
+		if _, ok := incomplete[i]; ok {
+			continue
+		}
+		byResolution[b.Resolution] = append(byResolution[b.Resolution], b)
+	}
+	for _, res := range byResolution {
+		sort.Slice(res, func(i, j int) bool {
+			return res[i].MinTime < res[j].MinTime
+		})
+	}
+
+	selected := make(map[ulid.ULID]struct{}, len(blocks))
+
+	// Recursively fill the time range with the blocks at the i-th resolution, and the gaps with the next ones.
+	var fill func(i int, minT, maxT int64)
+	fill = func(i int, minT, maxT int64) {
+		if minT > maxT || i >= len(downsample.Resolutions) {
+			return
+		}
+
+		start := minT
+		for _, b := range byResolution[downsample.Resolutions[i]] {
+			// NOTE: Block intervals are half-open: [b.MinTime, b.MaxTime).
+			if b.MaxTime <= minT {
+				continue
+			}
+			if b.MinTime > maxT {
+				break
+			}
+
+			fill(i+1, start, b.MinTime-1)
+			selected[b.ID] = struct{}{}
+
+			if b.MaxTime > start {
+				start = b.MaxTime
+			}
+		}
+
+		fill(i+1, start, maxT)
+	}
+
+	i := 0
+	for ; i < len(downsample.Resolutions) && downsample.Resolutions[i] > maxResolution; i++ {
+	}
+	fill(i, minT, maxT)
+
+	result := make(bucketindex.Blocks, 0, len(selected))
+	for _, b := range blocks {
+		if _, ok := selected[b.ID]; ok {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
 // canBlockWithCompactorShardIndexContainQueryShard returns false if block with given compactor shard ID can *definitely NOT*
 // contain series for given query shard. Returns true otherwise (we don't know if block *does* contain such series,
 // but we cannot rule it out).
@@ -683,6 +891,7 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 	clients map[BlocksStoreClient][]ulid.ULID,
 	minT int64,
 	maxT int64,
+	maxResolution int64,
 	matchers []*labels.Matcher,
 	convertedMatchers []storepb.LabelMatcher,
 	maxChunksLimit int,
@@ -699,8 +908,11 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 		spanLog       = spanlogger.FromContext(ctx, q.logger)
 		queryLimiter  = limiter.QueryLimiterFromContextWithFallback(ctx)
 		reqStats      = stats.FromContext(ctx)
+		startTime     = time.Now()
 	)
 
+	defer func() { reqStats.AddStoreGatewayFetchTime(time.Since(startTime)) }()
+
 	// Concurrently fetch series from all clients.
 	for c, blockIDs := range clients {
 		// Change variables scope since it will be used in a goroutine.
@@ -713,27 +925,62 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 			// and let the TSDB return us data with no chunks as in prometheus#8050.
 			// But this is an acceptable workaround for now.
 			skipChunks := sp != nil && sp.Func == "series"
+			streamingChunks := q.streamingChunksBatchSize > 0 && !skipChunks
+
+			var streamingChunksBatchSize uint64
+			if streamingChunks {
+				streamingChunksBatchSize = q.streamingChunksBatchSize
+			}
+
+			var aggrs []storepb.Aggr
+			if maxResolution > downsample.ResolutionRaw && sp != nil {
+				aggrs = aggrsFromFunc(sp.Func)
+			}
 
-			req, err := createSeriesRequest(minT, maxT, convertedMatchers, skipChunks, blockIDs)
+			req, err := createSeriesRequest(minT, maxT, maxResolution, aggrs, convertedMatchers, skipChunks, blockIDs, streamingChunksBatchSize)
 			if err != nil {
 				return errors.Wrapf(err, "failed to create series request")
 			}
 
-			stream, err := c.Series(gCtx, req)
+			var (
+				streamCtx           = gCtx
+				cancelStream        = context.CancelFunc(func() {})
+				streamReaderStarted = false
+			)
+			if streamingChunks {
+				var labelsReceived func()
+				streamCtx, cancelStream, labelsReceived = newStoreGatewayStreamContext(gCtx, q.ctx, q.userID)
+				defer labelsReceived()
+			}
+			defer func() {
+				if !streamReaderStarted {
+					cancelStream()
+				}
+			}()
+
+			stream, err := c.Series(streamCtx, req)
 			if err != nil {
 				level.Warn(spanLog).Log("msg", "failed to fetch series", "remote", c.RemoteAddress(), "err", err)
 				return nil
 			}
 
 			mySeries := []*storepb.Series(nil)
+			myStreamingSeries := []labels.Labels(nil)
 			myWarnings := storage.Warnings(nil)
 			myQueriedBlocks := []ulid.ULID(nil)
+			endOfSeriesStream := false
 
 			for {
 				// Ensure the context hasn't been canceled in the meanwhile (eg. an error occurred
 				// in another goroutine).
-				if gCtx.Err() != nil {
-					return gCtx.Err()
+				if err := gCtx.Err(); err != nil {
+					// If the store-gateway query timeout has been reached, the blocks requested to this
+					// store-gateway are considered not queried, and the consistency check will detect it.
+					if errors.Is(err, context.DeadlineExceeded) {
+						level.Warn(spanLog).Log("msg", "store-gateway did not respond within the timeout", "remote", c.RemoteAddress())
+						return nil
+					}
+					return err
 				}
 
 				resp, err := stream.Recv()
@@ -772,6 +1019,25 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 					}
 				}
 
+				if ss := resp.GetStreamingSeries(); ss != nil {
+					for _, s := range ss.Series {
+						lbls := labelpb.ZLabelsToPromLabels(s.Labels)
+
+						// Add series fingerprint to query limiter; will return error if we are over the limit
+						if limitErr := queryLimiter.AddSeries(mimirpb.FromLabelsToLabelAdapters(lbls)); limitErr != nil {
+							return validation.LimitError(limitErr.Error())
+						}
+
+						myStreamingSeries = append(myStreamingSeries, lbls)
+					}
+
+					// The chunks are read from the stream while the series are iterated.
+					if ss.IsEndOfSeriesStream {
+						endOfSeriesStream = true
+						break
+					}
+				}
+
 				if w := resp.GetWarning(); w != "" {
 					myWarnings = append(myWarnings, errors.New(w))
 				}
@@ -791,6 +1057,32 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 				}
 			}
 
+			if endOfSeriesStream {
+				reader := newStoreGatewayStreamReader(streamCtx, stream, cancelStream, len(myStreamingSeries), queryLimiter, reqStats, spanLog)
+				reader.StartBuffering()
+				streamReaderStarted = true
+
+				q.streamReadersMtx.Lock()
+				q.streamReaders = append(q.streamReaders, reader)
+				q.streamReadersMtx.Unlock()
+
+				reqStats.AddFetchedSeries(uint64(len(myStreamingSeries)))
+
+				level.Debug(spanLog).Log("msg", "received series labels from store-gateway, chunks will be streamed",
+					"instance", c.RemoteAddress(),
+					"fetched series", len(myStreamingSeries),
+					"requested blocks", strings.Join(convertULIDsToString(blockIDs), " "),
+					"queried blocks", strings.Join(convertULIDsToString(myQueriedBlocks), " "))
+
+				mtx.Lock()
+				seriesSets = append(seriesSets, &blockStreamingQuerierSeriesSet{series: myStreamingSeries, streamReader: reader})
+				warnings = append(warnings, myWarnings...)
+				queriedBlocks = append(queriedBlocks, myQueriedBlocks...)
+				mtx.Unlock()
+
+				return nil
+			}
+
 			numSeries := len(mySeries)
 			chunksFetched, chunkBytes := countChunksAndBytes(mySeries...)
 
@@ -983,7 +1275,32 @@ func (q *blocksStoreQuerier) fetchLabelValuesFromStore(
 	return valueSets, warnings, queriedBlocks, nil
 }
 
-func createSeriesRequest(minT, maxT int64, matchers []storepb.LabelMatcher, skipChunks bool, blockIDs []ulid.ULID) (*storepb.SeriesRequest, error) {
+// newStoreGatewayStreamContext returns the context of a Series() request streaming chunks from a store-gateway.
+// The stream is read after the series labels have been received, so its context can't be the group context,
+// which is canceled once all requests complete: it's derived from the querier context instead. The returned
+// context is canceled if the group context is done before labelsReceived is called, or by the cancel function.
+func newStoreGatewayStreamContext(groupCtx, querierCtx context.Context, userID string) (_ context.Context, cancel context.CancelFunc, labelsReceived func()) {
+	streamCtx, cancel := context.WithCancel(grpc_metadata.AppendToOutgoingContext(querierCtx, storegateway.GrpcContextMetadataTenantID, userID))
+	received := make(chan struct{})
+
+	go func() {
+		select {
+		case <-groupCtx.Done():
+			// The group context is also canceled when all requests complete, after labelsReceived has been called.
+			select {
+			case <-received:
+			default:
+				cancel()
+			}
+		case <-received:
+		case <-streamCtx.Done():
+		}
+	}()
+
+	return streamCtx, cancel, func() { close(received) }
+}
+
+func createSeriesRequest(minT, maxT, maxResolution int64, aggrs []storepb.Aggr, matchers []storepb.LabelMatcher, skipChunks bool, blockIDs []ulid.ULID, streamingChunksBatchSize uint64) (*storepb.SeriesRequest, error) {
 	// Selectively query only specific blocks.
 	hints := &hintspb.SeriesRequestHints{
 		BlockMatchers: []storepb.LabelMatcher{
@@ -1001,14 +1318,53 @@ func createSeriesRequest(minT, maxT int64, matchers []storepb.LabelMatcher, skip
 	}
 
 	return &storepb.SeriesRequest{
-		MinTime:    minT,
-		MaxTime:    maxT,
-		Matchers:   matchers,
-		Hints:      anyHints,
-		SkipChunks: skipChunks,
+		MinTime:                  minT,
+		MaxTime:                  maxT,
+		MaxResolutionWindow:      maxResolution,
+		Aggregates:               aggrs,
+		Matchers:                 matchers,
+		Hints:                    anyHints,
+		SkipChunks:               skipChunks,
+		StreamingChunksBatchSize: streamingChunksBatchSize,
 	}, nil
 }
 
+// maxResolutionFromHints returns the max resolution of the blocks which can be queried for the given select
+// hints, so that there are at least 5 samples for each step and range of the query.
+func maxResolutionFromHints(sp *storage.SelectHints) int64 {
+	if sp == nil || sp.Step <= 0 {
+		return downsample.ResolutionRaw
+	}
+
+	maxResolution := sp.Step / 5
+	if sp.Range > 0 && sp.Range/5 < maxResolution {
+		maxResolution = sp.Range / 5
+	}
+	return maxResolution
+}
+
+// aggrsFromFunc returns the aggregates to load from downsampled blocks for the given PromQL function.
+func aggrsFromFunc(f string) []storepb.Aggr {
+	if f == "min" || strings.HasPrefix(f, "min_") {
+		return []storepb.Aggr{storepb.Aggr_MIN}
+	}
+	if f == "max" || strings.HasPrefix(f, "max_") {
+		return []storepb.Aggr{storepb.Aggr_MAX}
+	}
+	if f == "count" {
+		return []storepb.Aggr{storepb.Aggr_COUNT}
+	}
+	// The "sum" aggregation needs the actual samples, so it falls into the default case.
+	if strings.HasPrefix(f, "sum_") {
+		return []storepb.Aggr{storepb.Aggr_SUM}
+	}
+	if f == "increase" || f == "rate" || f == "irate" || f == "resets" {
+		return []storepb.Aggr{storepb.Aggr_COUNTER}
+	}
+	// In the default case, we load count and sum to compute the average.
+	return []storepb.Aggr{storepb.Aggr_COUNT, storepb.Aggr_SUM}
+}
+
 func createLabelNamesRequest(minT, maxT int64, blockIDs []ulid.ULID, matchers []storepb.LabelMatcher) (*storepb.LabelNamesRequest, error) {
 	req := &storepb.LabelNamesRequest{
 		Start:    minT,
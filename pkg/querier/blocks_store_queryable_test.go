@@ -34,6 +34,7 @@ import (
 
 	"github.com/grafana/mimir/pkg/storage/sharding"
 	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/storage/tsdb/downsample"
 	"github.com/grafana/mimir/pkg/storegateway/hintspb"
 	"github.com/grafana/mimir/pkg/storegateway/labelpb"
 	"github.com/grafana/mimir/pkg/storegateway/storegatewaypb"
@@ -1496,6 +1497,245 @@ func TestBlocksStoreQuerier_SelectSortedShouldHonorQueryStoreAfter(t *testing.T)
 	}
 }
 
+func TestBlocksStoreQuerier_PartialResponse(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1          = ulid.MustNew(1, nil)
+		block2          = ulid.MustNew(2, nil)
+		metricNameLabel = labels.Label{Name: labels.MetricName, Value: metricName}
+		series1Label    = labels.Label{Name: "series", Value: "1"}
+		knownBlocks     = bucketindex.Blocks{
+			{ID: block1, MinTime: minT, MaxTime: maxT},
+			{ID: block2, MinTime: minT, MaxTime: maxT},
+		}
+	)
+
+	healthyStore := func() BlocksStoreClient {
+		return &storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+			mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT, 1),
+			mockHintsResponse(block1),
+		}}
+	}
+
+	tests := map[string]struct {
+		storeSetResponses        []interface{}
+		partialResponse          bool
+		storeGatewayQueryTimeout time.Duration
+		expectedErr              error
+		expectedWarning          error
+		expectedSeries           []labels.Labels
+	}{
+		"store-gateway holding a block is unavailable and partial response is disabled": {
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					healthyStore(): {block1},
+					&storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesErr: errors.New("unavailable")}: {block2},
+				},
+				errors.New("no store-gateway remaining after exclude"),
+			},
+			expectedErr: newStoreConsistencyCheckFailedError([]ulid.ULID{block2}),
+		},
+		"store-gateway holding a block is unavailable and partial response is enabled": {
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					healthyStore(): {block1},
+					&storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesErr: errors.New("unavailable")}: {block2},
+				},
+				errors.New("no store-gateway remaining after exclude"),
+			},
+			partialResponse: true,
+			expectedWarning: newStorePartialResponseWarning(knownBlocks, []ulid.ULID{block2}),
+			expectedSeries:  []labels.Labels{{metricNameLabel, series1Label}},
+		},
+		"no store-gateway is available and partial response is enabled": {
+			storeSetResponses: []interface{}{
+				errors.New("no store-gateway available"),
+			},
+			partialResponse: true,
+			expectedWarning: newStorePartialResponseWarning(knownBlocks, []ulid.ULID{block1, block2}),
+		},
+		"store-gateway holding a block does not respond within the timeout and partial response is disabled": {
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					healthyStore(): {block1},
+					&blockingStoreGatewayClientMock{storeGatewayClientMock{remoteAddr: "2.2.2.2"}}: {block2},
+				},
+			},
+			storeGatewayQueryTimeout: 500 * time.Millisecond,
+			expectedErr:              newStoreConsistencyCheckFailedError([]ulid.ULID{block2}),
+		},
+		"store-gateway holding a block does not respond within the timeout and partial response is enabled": {
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					healthyStore(): {block1},
+					&blockingStoreGatewayClientMock{storeGatewayClientMock{remoteAddr: "2.2.2.2"}}: {block2},
+				},
+			},
+			partialResponse:          true,
+			storeGatewayQueryTimeout: 500 * time.Millisecond,
+			expectedWarning:          newStorePartialResponseWarning(knownBlocks, []ulid.ULID{block2}),
+			expectedSeries:           []labels.Labels{{metricNameLabel, series1Label}},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+			reg := prometheus.NewPedanticRegistry()
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(knownBlocks, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:                      ctx,
+				minT:                     minT,
+				maxT:                     maxT,
+				userID:                   "user-1",
+				finder:                   finder,
+				stores:                   &blocksStoreSetMock{mockedResponses: testData.storeSetResponses},
+				consistency:              NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:                   log.NewNopLogger(),
+				metrics:                  newBlocksStoreQueryableMetrics(reg),
+				limits:                   &blocksStoreLimitsMock{},
+				storeGatewayQueryTimeout: testData.storeGatewayQueryTimeout,
+				partialResponse:          testData.partialResponse,
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			if testData.expectedErr != nil {
+				assert.EqualError(t, set.Err(), testData.expectedErr.Error())
+				assert.False(t, set.Next())
+				return
+			}
+
+			require.NoError(t, set.Err())
+			assert.Equal(t, storage.Warnings{testData.expectedWarning}, set.Warnings())
+
+			// The series of the blocks queried successfully should be returned.
+			var actualLabels []labels.Labels
+			for set.Next() {
+				actualLabels = append(actualLabels, set.At().Labels())
+			}
+			require.NoError(t, set.Err())
+
+			assert.Equal(t, testData.expectedSeries, actualLabels)
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+				# HELP cortex_querier_storegateway_partial_responses_total Number of requests to store-gateways which returned a partial response because some blocks couldn't be queried.
+				# TYPE cortex_querier_storegateway_partial_responses_total counter
+				cortex_querier_storegateway_partial_responses_total 1
+			`), "cortex_querier_storegateway_partial_responses_total"))
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_StreamingChunks(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1          = ulid.MustNew(1, nil)
+		block2          = ulid.MustNew(2, nil)
+		metricNameLabel = labels.Label{Name: labels.MetricName, Value: metricName}
+		series1Label    = labels.Label{Name: "series", Value: "1"}
+		series2Label    = labels.Label{Name: "series", Value: "2"}
+		series3Label    = labels.Label{Name: "series", Value: "3"}
+	)
+
+	ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+	finder := &blocksFinderMock{}
+	finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{
+		{ID: block1},
+		{ID: block2},
+	}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+	streamingStore := &storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+		storepb.NewStreamingSeriesResponse(&storepb.StreamingSeriesBatch{Series: []*storepb.StreamingSeries{
+			{Labels: labelpb.ZLabelsFromPromLabels(labels.Labels{metricNameLabel, series1Label})},
+		}}),
+		mockHintsResponse(block1),
+		storepb.NewStreamingSeriesResponse(&storepb.StreamingSeriesBatch{Series: []*storepb.StreamingSeries{
+			{Labels: labelpb.ZLabelsFromPromLabels(labels.Labels{metricNameLabel, series2Label})},
+		}, IsEndOfSeriesStream: true}),
+		mockStreamingChunksResponse(
+			&storepb.StreamingChunks{SeriesIndex: 0, Chunks: []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: minT, V: 1})}},
+			&storepb.StreamingChunks{SeriesIndex: 1, Chunks: []storepb.AggrChunk{createAggrChunkWithSamples(promql.Point{T: minT, V: 2})}},
+		),
+	}}
+
+	// Store-gateways not supporting streaming reply with the series as usual.
+	nonStreamingStore := &storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesResponses: []*storepb.SeriesResponse{
+		mockSeriesResponse(labels.Labels{metricNameLabel, series3Label}, minT, 3),
+		mockHintsResponse(block2),
+	}}
+
+	q := &blocksStoreQuerier{
+		ctx:    ctx,
+		minT:   minT,
+		maxT:   maxT,
+		userID: "user-1",
+		finder: finder,
+		stores: &blocksStoreSetMock{mockedResponses: []interface{}{
+			map[BlocksStoreClient][]ulid.ULID{
+				streamingStore:    {block1},
+				nonStreamingStore: {block2},
+			},
+		}},
+		consistency:              NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+		logger:                   log.NewNopLogger(),
+		metrics:                  newBlocksStoreQueryableMetrics(prometheus.NewPedanticRegistry()),
+		limits:                   &blocksStoreLimitsMock{},
+		streamingChunksBatchSize: 256,
+	}
+
+	set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+	require.NoError(t, set.Err())
+
+	type valueResult struct {
+		t int64
+		v float64
+	}
+
+	type seriesResult struct {
+		lbls   labels.Labels
+		values []valueResult
+	}
+
+	var actual []seriesResult
+	for set.Next() {
+		var values []valueResult
+
+		it := set.At().Iterator()
+		for it.Next() {
+			t, v := it.At()
+			values = append(values, valueResult{t: t, v: v})
+		}
+		require.NoError(t, it.Err())
+
+		actual = append(actual, seriesResult{lbls: set.At().Labels(), values: values})
+	}
+	require.NoError(t, set.Err())
+
+	assert.Equal(t, []seriesResult{
+		{lbls: labels.Labels{metricNameLabel, series1Label}, values: []valueResult{{t: minT, v: 1}}},
+		{lbls: labels.Labels{metricNameLabel, series2Label}, values: []valueResult{{t: minT, v: 2}}},
+		{lbls: labels.Labels{metricNameLabel, series3Label}, values: []valueResult{{t: minT, v: 3}}},
+	}, actual)
+
+	// Closing the querier cancels the streams.
+	require.Len(t, q.streamReaders, 1)
+	reader := q.streamReaders[0]
+	require.NoError(t, q.Close())
+	require.ErrorIs(t, reader.ctx.Err(), context.Canceled)
+}
+
 func TestBlocksStoreQuerier_MaxLabelsQueryRange(t *testing.T) {
 	const (
 		engineLookbackDelta = 5 * time.Minute
@@ -1668,7 +1908,7 @@ func TestBlocksStoreQuerier_PromQLExecution(t *testing.T) {
 
 			// Instantiate the querier that will be executed to run the query.
 			logger := log.NewNopLogger()
-			queryable, err := NewBlocksStoreQueryable(stores, finder, NewBlocksConsistencyChecker(0, 0, logger, nil), &blocksStoreLimitsMock{}, 0, logger, nil)
+			queryable, err := NewBlocksStoreQueryable(stores, finder, NewBlocksConsistencyChecker(0, 0, logger, nil), &blocksStoreLimitsMock{}, 0, 0, 0, logger, nil)
 			require.NoError(t, err)
 			require.NoError(t, services.StartAndAwaitRunning(context.Background(), queryable))
 			defer services.StopAndAwaitTerminated(context.Background(), queryable) // nolint:errcheck
@@ -1724,6 +1964,143 @@ func TestCanBlockWithCompactorShardIdContainQueryShard(t *testing.T) {
 	}
 }
 
+func TestSelectBlocksByResolution(t *testing.T) {
+	var (
+		raw1    = &bucketindex.Block{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 100}
+		raw2    = &bucketindex.Block{ID: ulid.MustNew(2, nil), MinTime: 100, MaxTime: 200}
+		raw3    = &bucketindex.Block{ID: ulid.MustNew(3, nil), MinTime: 200, MaxTime: 300}
+		res5m1  = &bucketindex.Block{ID: ulid.MustNew(4, nil), MinTime: 0, MaxTime: 100, Resolution: downsample.Resolution5m}
+		res5m2  = &bucketindex.Block{ID: ulid.MustNew(5, nil), MinTime: 100, MaxTime: 200, Resolution: downsample.Resolution5m}
+		res1h   = &bucketindex.Block{ID: ulid.MustNew(6, nil), MinTime: 0, MaxTime: 100, Resolution: downsample.Resolution1h}
+		raw1Dup = &bucketindex.Block{ID: ulid.MustNew(7, nil), MinTime: 0, MaxTime: 100}
+
+		rawShard1   = &bucketindex.Block{ID: ulid.MustNew(10, nil), MinTime: 0, MaxTime: 100, CompactorShardID: "1_of_2"}
+		rawShard2   = &bucketindex.Block{ID: ulid.MustNew(11, nil), MinTime: 0, MaxTime: 100, CompactorShardID: "2_of_2"}
+		res5mShard1 = &bucketindex.Block{ID: ulid.MustNew(12, nil), MinTime: 0, MaxTime: 100, CompactorShardID: "1_of_2", Resolution: downsample.Resolution5m}
+		res5mShard2 = &bucketindex.Block{ID: ulid.MustNew(13, nil), MinTime: 0, MaxTime: 100, CompactorShardID: "2_of_2", Resolution: downsample.Resolution5m}
+		res1hShard1 = &bucketindex.Block{ID: ulid.MustNew(14, nil), MinTime: 0, MaxTime: 100, CompactorShardID: "1_of_2", Resolution: downsample.Resolution1h}
+	)
+
+	tests := map[string]struct {
+		blocks        bucketindex.Blocks
+		minT, maxT    int64
+		maxResolution int64
+		expected      bucketindex.Blocks
+	}{
+		"only raw blocks": {
+			blocks:        bucketindex.Blocks{raw1, raw2, raw3},
+			minT:          0,
+			maxT:          300,
+			maxResolution: downsample.Resolution1h,
+			expected:      bucketindex.Blocks{raw1, raw2, raw3},
+		},
+		"raw resolution": {
+			blocks:        bucketindex.Blocks{raw1, res1h, raw2, res5m1, res5m2, raw3},
+			minT:          0,
+			maxT:          300,
+			maxResolution: downsample.ResolutionRaw,
+			expected:      bucketindex.Blocks{raw1, raw2, raw3},
+		},
+		"resolution lower than 5m": {
+			blocks:        bucketindex.Blocks{raw1, res1h, raw2, res5m1, res5m2, raw3},
+			minT:          0,
+			maxT:          300,
+			maxResolution: downsample.Resolution5m - 1,
+			expected:      bucketindex.Blocks{raw1, raw2, raw3},
+		},
+		"5m resolution, filling the gaps with raw blocks": {
+			blocks:        bucketindex.Blocks{raw1, res1h, raw2, res5m1, res5m2, raw3},
+			minT:          0,
+			maxT:          300,
+			maxResolution: downsample.Resolution5m,
+			expected:      bucketindex.Blocks{res5m1, res5m2, raw3},
+		},
+		"1h resolution, filling the gaps with 5m and raw blocks": {
+			blocks:        bucketindex.Blocks{raw1, res1h, raw2, res5m1, res5m2, raw3},
+			minT:          0,
+			maxT:          300,
+			maxResolution: downsample.Resolution1h,
+			expected:      bucketindex.Blocks{res1h, res5m2, raw3},
+		},
+		"1h resolution, partial time range": {
+			blocks:        bucketindex.Blocks{raw1, res1h, raw2, res5m1, res5m2, raw3},
+			minT:          150,
+			maxT:          300,
+			maxResolution: downsample.Resolution1h,
+			expected:      bucketindex.Blocks{res5m2, raw3},
+		},
+		"5m resolution, only one of the overlapping raw blocks has been downsampled": {
+			blocks:        bucketindex.Blocks{raw1, raw1Dup, res5m1, raw2},
+			minT:          0,
+			maxT:          200,
+			maxResolution: downsample.Resolution5m,
+			expected:      bucketindex.Blocks{raw1, raw1Dup, raw2},
+		},
+		"5m resolution, only one of the compactor shards has been downsampled": {
+			blocks:        bucketindex.Blocks{rawShard1, rawShard2, res5mShard1},
+			minT:          0,
+			maxT:          100,
+			maxResolution: downsample.Resolution5m,
+			expected:      bucketindex.Blocks{rawShard1, rawShard2},
+		},
+		"5m resolution, all the compactor shards have been downsampled": {
+			blocks:        bucketindex.Blocks{rawShard1, rawShard2, res5mShard1, res5mShard2},
+			minT:          0,
+			maxT:          100,
+			maxResolution: downsample.Resolution5m,
+			expected:      bucketindex.Blocks{res5mShard1, res5mShard2},
+		},
+		"1h resolution, only one of the compactor shards has been downsampled to 1h": {
+			blocks:        bucketindex.Blocks{rawShard1, rawShard2, res5mShard1, res5mShard2, res1hShard1},
+			minT:          0,
+			maxT:          100,
+			maxResolution: downsample.Resolution1h,
+			expected:      bucketindex.Blocks{res5mShard1, res5mShard2},
+		},
+		"1h resolution, only one of the compactor shards has been downsampled to 5m and 1h": {
+			blocks:        bucketindex.Blocks{rawShard1, rawShard2, res5mShard1, res1hShard1},
+			minT:          0,
+			maxT:          100,
+			maxResolution: downsample.Resolution1h,
+			expected:      bucketindex.Blocks{rawShard1, rawShard2},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, selectBlocksByResolution(testData.blocks, testData.minT, testData.maxT, testData.maxResolution))
+		})
+	}
+}
+
+func TestMaxResolutionFromHints(t *testing.T) {
+	assert.Equal(t, downsample.ResolutionRaw, maxResolutionFromHints(nil))
+	assert.Equal(t, downsample.ResolutionRaw, maxResolutionFromHints(&storage.SelectHints{}))
+	assert.Equal(t, int64(12000), maxResolutionFromHints(&storage.SelectHints{Step: time.Minute.Milliseconds()}))
+	assert.Equal(t, downsample.Resolution1h, maxResolutionFromHints(&storage.SelectHints{Step: 5 * time.Hour.Milliseconds()}))
+	assert.Equal(t, downsample.Resolution5m, maxResolutionFromHints(&storage.SelectHints{Step: 5 * time.Hour.Milliseconds(), Range: 25 * time.Minute.Milliseconds()}))
+}
+
+func TestAggrsFromFunc(t *testing.T) {
+	for f, expected := range map[string][]storepb.Aggr{
+		"min":                {storepb.Aggr_MIN},
+		"min_over_time":      {storepb.Aggr_MIN},
+		"max":                {storepb.Aggr_MAX},
+		"max_over_time":      {storepb.Aggr_MAX},
+		"count":              {storepb.Aggr_COUNT},
+		"count_over_time":    {storepb.Aggr_COUNT, storepb.Aggr_SUM},
+		"sum_over_time":      {storepb.Aggr_SUM},
+		"rate":               {storepb.Aggr_COUNTER},
+		"increase":           {storepb.Aggr_COUNTER},
+		"sum":                {storepb.Aggr_COUNT, storepb.Aggr_SUM},
+		"avg_over_time":      {storepb.Aggr_COUNT, storepb.Aggr_SUM},
+		"":                   {storepb.Aggr_COUNT, storepb.Aggr_SUM},
+		"quantile_over_time": {storepb.Aggr_COUNT, storepb.Aggr_SUM},
+	} {
+		assert.Equal(t, expected, aggrsFromFunc(f), f)
+	}
+}
+
 func TestFilterBlocksByShard(t *testing.T) {
 	block1 := &bucketindex.Block{ID: ulid.MustNew(ulid.Now(), crand.Reader), MinTime: 0, MaxTime: 100, CompactorShardID: "1_of_4"}
 	block2 := &bucketindex.Block{ID: ulid.MustNew(ulid.Now(), crand.Reader), MinTime: 0, MaxTime: 100, CompactorShardID: "2_of_4"}
@@ -1802,7 +2179,7 @@ type blocksStoreSetMock struct {
 	nextResult      int
 }
 
-func (m *blocksStoreSetMock) GetClientsFor(_ string, _ []ulid.ULID, _ map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
+func (m *blocksStoreSetMock) GetClientsFor(_ string, _ bucketindex.Blocks, _ map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
 	if m.nextResult >= len(m.mockedResponses) {
 		panic("not enough mocked results")
 	}
@@ -1860,6 +2237,27 @@ func (m *storeGatewayClientMock) RemoteAddress() string {
 	return m.remoteAddr
 }
 
+// blockingStoreGatewayClientMock is a store-gateway client mock whose Series() responses
+// block until the request context is done.
+type blockingStoreGatewayClientMock struct {
+	storeGatewayClientMock
+}
+
+func (m *blockingStoreGatewayClientMock) Series(ctx context.Context, _ *storepb.SeriesRequest, _ ...grpc.CallOption) (storegatewaypb.StoreGateway_SeriesClient, error) {
+	return &blockingSeriesClientMock{ctx: ctx}, nil
+}
+
+type blockingSeriesClientMock struct {
+	grpc.ClientStream
+
+	ctx context.Context
+}
+
+func (m *blockingSeriesClientMock) Recv() (*storepb.SeriesResponse, error) {
+	<-m.ctx.Done()
+	return nil, m.ctx.Err()
+}
+
 type storeGatewaySeriesClientMock struct {
 	grpc.ClientStream
 
@@ -1883,6 +2281,7 @@ type blocksStoreLimitsMock struct {
 	maxLabelsQueryLength        time.Duration
 	maxChunksPerQuery           int
 	storeGatewayTenantShardSize int
+	partialResponsesEnabled     bool
 }
 
 func (m *blocksStoreLimitsMock) MaxLabelsQueryLength(_ string) time.Duration {
@@ -1897,6 +2296,10 @@ func (m *blocksStoreLimitsMock) StoreGatewayTenantShardSize(_ string) int {
 	return m.storeGatewayTenantShardSize
 }
 
+func (m *blocksStoreLimitsMock) PartialResponsesEnabled(_ string) bool {
+	return m.partialResponsesEnabled
+}
+
 func (m *blocksStoreLimitsMock) S3SSEType(_ string) string {
 	return ""
 }
@@ -9,6 +9,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/dskit/ring"
@@ -18,7 +19,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 
-	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
 	"github.com/grafana/mimir/pkg/storegateway"
 	"github.com/grafana/mimir/pkg/util"
 )
@@ -38,6 +39,7 @@ type blocksStoreReplicationSet struct {
 	storesRing        *ring.Ring
 	clientsPool       *client.Pool
 	balancingStrategy loadBalancingStrategy
+	replication       storegateway.TimeBasedReplicationConfig
 	limits            BlocksStoreLimits
 
 	// Subservices manager.
@@ -48,6 +50,7 @@ type blocksStoreReplicationSet struct {
 func newBlocksStoreReplicationSet(
 	storesRing *ring.Ring,
 	balancingStrategy loadBalancingStrategy,
+	replication storegateway.TimeBasedReplicationConfig,
 	limits BlocksStoreLimits,
 	clientConfig ClientConfig,
 	logger log.Logger,
@@ -57,6 +60,7 @@ func newBlocksStoreReplicationSet(
 		storesRing:         storesRing,
 		clientsPool:        newStoreGatewayClientPool(client.NewRingServiceDiscovery(storesRing), clientConfig, logger, reg),
 		balancingStrategy:  balancingStrategy,
+		replication:        replication,
 		limits:             limits,
 		subservicesWatcher: services.NewFailureWatcher(),
 	}
@@ -97,29 +101,31 @@ func (s *blocksStoreReplicationSet) stopping(_ error) error {
 	return services.StopManagerAndAwaitStopped(context.Background(), s.subservices)
 }
 
-func (s *blocksStoreReplicationSet) GetClientsFor(userID string, blockIDs []ulid.ULID, exclude map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
+func (s *blocksStoreReplicationSet) GetClientsFor(userID string, blocks bucketindex.Blocks, exclude map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
 	shards := map[string][]ulid.ULID{}
 
 	userRing := storegateway.GetShuffleShardingSubring(s.storesRing, userID, s.limits)
+	bufDescs, bufHosts, bufZones := ring.MakeBuffersForGet()
+	now := time.Now()
 
 	// Find the replication set of each block we need to query.
-	for _, blockID := range blockIDs {
-		// Do not reuse the same buffer across multiple Get() calls because we do retain the
-		// returned replication set.
-		bufDescs, bufHosts, bufZones := ring.MakeBuffersForGet()
+	for _, b := range blocks {
+		// Recent blocks may be replicated to more store-gateways, so that queries are spread across all of them.
+		replicaSets := s.replication.ReplicaSets(b.MaxTime, userRing.ReplicationFactor(), now)
 
-		set, err := userRing.Get(mimir_tsdb.HashBlockID(blockID), storegateway.BlocksRead, bufDescs, bufHosts, bufZones)
+		// The returned replication set doesn't retain the buffers, so they can be reused across blocks.
+		set, err := storegateway.GetBlockReplicationSet(userRing, b.ID, replicaSets, storegateway.BlocksRead, bufDescs, bufHosts, bufZones)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get store-gateway replication set owning the block %s", blockID.String())
+			return nil, errors.Wrapf(err, "failed to get store-gateway replication set owning the block %s", b.ID.String())
 		}
 
 		// Pick a non excluded store-gateway instance.
-		addr := getNonExcludedInstanceAddr(set, exclude[blockID], s.balancingStrategy)
+		addr := getNonExcludedInstanceAddr(set, exclude[b.ID], s.balancingStrategy)
 		if addr == "" {
-			return nil, fmt.Errorf("no store-gateway instance left after checking exclude for block %s", blockID.String())
+			return nil, fmt.Errorf("no store-gateway instance left after checking exclude for block %s", b.ID.String())
 		}
 
-		shards[addr] = append(shards[addr], blockID)
+		shards[addr] = append(shards[addr], b.ID)
 	}
 
 	clients := map[BlocksStoreClient][]ulid.ULID{}
@@ -8,6 +8,7 @@ package querier
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"strings"
 	"testing"
 	"time"
@@ -21,10 +22,13 @@ import (
 	"github.com/oklog/ulid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/storegateway"
 )
 
 func TestBlocksStoreReplicationSet_GetClientsFor(t *testing.T) {
@@ -338,7 +342,7 @@ func TestBlocksStoreReplicationSet_GetClientsFor(t *testing.T) {
 			}
 
 			reg := prometheus.NewPedanticRegistry()
-			s, err := newBlocksStoreReplicationSet(r, noLoadBalancing, limits, ClientConfig{}, log.NewNopLogger(), reg)
+			s, err := newBlocksStoreReplicationSet(r, noLoadBalancing, storegateway.TimeBasedReplicationConfig{}, limits, ClientConfig{}, log.NewNopLogger(), reg)
 			require.NoError(t, err)
 			require.NoError(t, services.StartAndAwaitRunning(ctx, s))
 			defer services.StopAndAwaitTerminated(ctx, s) //nolint:errcheck
@@ -349,7 +353,7 @@ func TestBlocksStoreReplicationSet_GetClientsFor(t *testing.T) {
 				return err == nil && len(all.Instances) > 0
 			})
 
-			clients, err := s.GetClientsFor(userID, testData.queryBlocks, testData.exclude)
+			clients, err := s.GetClientsFor(userID, blocksWithIDs(testData.queryBlocks...), testData.exclude)
 			assert.Equal(t, testData.expectedErr, err)
 
 			if testData.expectedErr == nil {
@@ -398,7 +402,7 @@ func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSupportRandomLoadBalancin
 
 	limits := &blocksStoreLimitsMock{storeGatewayTenantShardSize: 0}
 	reg := prometheus.NewPedanticRegistry()
-	s, err := newBlocksStoreReplicationSet(r, randomLoadBalancing, limits, ClientConfig{}, log.NewNopLogger(), reg)
+	s, err := newBlocksStoreReplicationSet(r, randomLoadBalancing, storegateway.TimeBasedReplicationConfig{}, limits, ClientConfig{}, log.NewNopLogger(), reg)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(ctx, s))
 	defer services.StopAndAwaitTerminated(ctx, s) //nolint:errcheck
@@ -414,7 +418,7 @@ func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSupportRandomLoadBalancin
 	distribution := map[string]int{}
 
 	for n := 0; n < numRuns; n++ {
-		clients, err := s.GetClientsFor(userID, []ulid.ULID{block1}, nil)
+		clients, err := s.GetClientsFor(userID, blocksWithIDs(block1), nil)
 		require.NoError(t, err)
 		require.Len(t, clients, 1)
 
@@ -438,3 +442,102 @@ func getStoreGatewayClientAddrs(clients map[BlocksStoreClient][]ulid.ULID) map[s
 	}
 	return addrs
 }
+
+func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSpreadRecentBlocksAcrossTimeBasedReplicas(t *testing.T) {
+	const (
+		numRuns           = 1000
+		numInstances      = 12
+		replicationFactor = 3
+	)
+
+	ctx := context.Background()
+	userID := "user-A"
+	now := time.Now()
+	rnd := rand.New(rand.NewSource(1))
+
+	recentBlock := &bucketindex.Block{ID: ulid.MustNew(1, nil), MinTime: now.Add(-3 * time.Hour).UnixMilli(), MaxTime: now.Add(-time.Hour).UnixMilli()}
+	oldBlock := &bucketindex.Block{ID: ulid.MustNew(2, nil), MinTime: now.Add(-50 * time.Hour).UnixMilli(), MaxTime: now.Add(-48 * time.Hour).UnixMilli()}
+
+	// Create a ring.
+	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	require.NoError(t, ringStore.CAS(ctx, "test", func(in interface{}) (interface{}, bool, error) {
+		d := ring.NewDesc()
+		for n := 1; n <= numInstances; n++ {
+			tokens := make([]uint32, 0, 64)
+			for len(tokens) < cap(tokens) {
+				tokens = append(tokens, rnd.Uint32())
+			}
+			d.AddIngester(fmt.Sprintf("instance-%d", n), fmt.Sprintf("127.0.0.%d", n), "", tokens, ring.ACTIVE, now)
+		}
+		return d, true, nil
+	}))
+
+	ringCfg := ring.Config{}
+	flagext.DefaultValues(&ringCfg)
+	ringCfg.ReplicationFactor = replicationFactor
+
+	r, err := ring.NewWithStoreClientAndStrategy(ringCfg, "test", "test", ringStore, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	// Recent blocks are replicated to 2 replica sets.
+	replication := storegateway.TimeBasedReplicationConfig{Enabled: true, AgeBrackets: []storegateway.ReplicationAgeBracket{
+		{MaxAge: model.Duration(24 * time.Hour), ReplicationFactor: 2 * replicationFactor},
+	}}
+
+	limits := &blocksStoreLimitsMock{storeGatewayTenantShardSize: 0}
+	reg := prometheus.NewPedanticRegistry()
+	s, err := newBlocksStoreReplicationSet(r, randomLoadBalancing, replication, limits, ClientConfig{}, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, s))
+	defer services.StopAndAwaitTerminated(ctx, s) //nolint:errcheck
+
+	// Wait until the ring client has initialised the state.
+	test.Poll(t, time.Second, true, func() interface{} {
+		all, err := r.GetAllHealthy(ring.Read)
+		return err == nil && len(all.Instances) > 0
+	})
+
+	for _, b := range []*bucketindex.Block{recentBlock, oldBlock} {
+		expectedSet, err := storegateway.GetBlockReplicationSet(r, b.ID, replication.ReplicaSets(b.MaxTime, replicationFactor, now), storegateway.BlocksRead, nil, nil, nil)
+		require.NoError(t, err)
+
+		if b == recentBlock {
+			require.Greater(t, len(expectedSet.Instances), replicationFactor)
+		} else {
+			require.Len(t, expectedSet.Instances, replicationFactor)
+		}
+
+		// Request the same block multiple times and ensure requests are spread across all its replicas.
+		distribution := map[string]int{}
+
+		for n := 0; n < numRuns; n++ {
+			clients, err := s.GetClientsFor(userID, bucketindex.Blocks{b}, nil)
+			require.NoError(t, err)
+			require.Len(t, clients, 1)
+
+			for addr := range getStoreGatewayClientAddrs(clients) {
+				distribution[addr]++
+			}
+		}
+
+		assert.ElementsMatch(t, expectedSet.GetAddresses(), keysOf(distribution))
+	}
+}
+
+func blocksWithIDs(ids ...ulid.ULID) bucketindex.Blocks {
+	blocks := make(bucketindex.Blocks, 0, len(ids))
+	for _, id := range ids {
+		blocks = append(blocks, &bucketindex.Block{ID: id})
+	}
+	return blocks
+}
+
+func keysOf(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/grafana/mimir/pkg/util/httpgrpcutil"
+)
+
+type partialResponseContextKey int
+
+const partialResponseKey partialResponseContextKey = 0
+
+// ContextWithPartialResponse returns a new context with the partial response mode enabled or disabled.
+// The partial response mode set in the context takes precedence over the per-tenant setting.
+func ContextWithPartialResponse(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, partialResponseKey, enabled)
+}
+
+// partialResponseFromContext returns whether the partial response mode is enabled in the context,
+// and whether it has been set at all.
+func partialResponseFromContext(ctx context.Context) (enabled, ok bool) {
+	enabled, ok = ctx.Value(partialResponseKey).(bool)
+	return
+}
+
+// PartialResponseMiddleware enables or disables the partial response mode for a request, based on the
+// httpgrpcutil.PartialResponseHeader. Requests without the header (or with an invalid value) use the
+// per-tenant setting.
+type PartialResponseMiddleware struct{}
+
+// NewPartialResponseMiddleware makes a new PartialResponseMiddleware.
+func NewPartialResponseMiddleware() PartialResponseMiddleware {
+	return PartialResponseMiddleware{}
+}
+
+// Wrap implements middleware.Interface.
+func (m PartialResponseMiddleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if value := r.Header.Get(httpgrpcutil.PartialResponseHeader); value != "" {
+			if enabled, err := strconv.ParseBool(value); err == nil {
+				r = r.WithContext(ContextWithPartialResponse(r.Context(), enabled))
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
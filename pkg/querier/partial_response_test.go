@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/mimir/pkg/util/httpgrpcutil"
+)
+
+func TestPartialResponseMiddleware(t *testing.T) {
+	tests := map[string]struct {
+		headerValue     string
+		expectedSet     bool
+		expectedEnabled bool
+	}{
+		"no header": {
+			expectedSet: false,
+		},
+		"header enabling partial responses": {
+			headerValue:     "true",
+			expectedSet:     true,
+			expectedEnabled: true,
+		},
+		"header disabling partial responses": {
+			headerValue:     "false",
+			expectedSet:     true,
+			expectedEnabled: false,
+		},
+		"header with an invalid value": {
+			headerValue: "maybe",
+			expectedSet: false,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var actualEnabled, actualSet bool
+			handler := NewPartialResponseMiddleware().Wrap(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				actualEnabled, actualSet = partialResponseFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/query", nil)
+			if testData.headerValue != "" {
+				req.Header.Set(httpgrpcutil.PartialResponseHeader, testData.headerValue)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, testData.expectedSet, actualSet)
+			assert.Equal(t, testData.expectedEnabled, actualEnabled)
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/storage"
+	tsdb_errors "github.com/prometheus/prometheus/tsdb/errors"
 	"github.com/thanos-io/thanos/pkg/strutil"
 	"golang.org/x/sync/errgroup"
 
@@ -47,7 +48,11 @@ type Config struct {
 	QueryStoreAfter    time.Duration `yaml:"query_store_after" category:"advanced"`
 	MaxQueryIntoFuture time.Duration `yaml:"max_query_into_future" category:"advanced"`
 
-	StoreGatewayClient ClientConfig `yaml:"store_gateway_client"`
+	StoreGatewayClient       ClientConfig  `yaml:"store_gateway_client"`
+	StoreGatewayQueryTimeout time.Duration `yaml:"store_gateway_query_timeout" category:"experimental"`
+
+	PreferStreamingChunksFromStoreGateways bool   `yaml:"prefer_streaming_chunks_from_store_gateways" category:"experimental"`
+	StreamingChunksBatchSize               uint64 `yaml:"streaming_chunks_batch_size" category:"experimental"`
 
 	ShuffleShardingIngestersEnabled bool `yaml:"shuffle_sharding_ingesters_enabled" category:"advanced"`
 
@@ -61,8 +66,9 @@ const (
 )
 
 var (
-	errBadLookbackConfigs = fmt.Errorf("the -%s setting must be greater than -%s otherwise queries might return partial results", queryIngestersWithinFlag, queryStoreAfterFlag)
-	errEmptyTimeRange     = errors.New("empty time range")
+	errBadLookbackConfigs       = fmt.Errorf("the -%s setting must be greater than -%s otherwise queries might return partial results", queryIngestersWithinFlag, queryStoreAfterFlag)
+	errEmptyTimeRange           = errors.New("empty time range")
+	errStreamingChunksBatchSize = errors.New("the streaming chunks batch size must be greater than 0 when streaming chunks from store-gateways is enabled")
 )
 
 // RegisterFlags adds the flags required to config this to the given FlagSet.
@@ -73,6 +79,9 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.QueryIngestersWithin, queryIngestersWithinFlag, 13*time.Hour, "Maximum lookback beyond which queries are not sent to ingester. 0 means all queries are sent to ingester.")
 	f.DurationVar(&cfg.MaxQueryIntoFuture, "querier.max-query-into-future", 10*time.Minute, "Maximum duration into the future you can query. 0 to disable.")
 	f.DurationVar(&cfg.QueryStoreAfter, queryStoreAfterFlag, 12*time.Hour, "The time after which a metric should be queried from storage and not just ingesters. 0 means all queries are sent to store. If this option is enabled, the time range of the query sent to the store-gateway will be manipulated to ensure the query end is not more recent than 'now - query-store-after'.")
+	f.DurationVar(&cfg.StoreGatewayQueryTimeout, "querier.store-gateway-query-timeout", 0, "Maximum time the querier waits for store-gateways to respond to a single query. Blocks which have not been queried within the timeout are considered missing: the query fails, or returns a partial response with warnings if the partial response mode is enabled. 0 to disable.")
+	f.BoolVar(&cfg.PreferStreamingChunksFromStoreGateways, "querier.prefer-streaming-chunks-from-store-gateways", false, "Request store-gateways to send the labels of all series first, and then stream the chunks of the series while the query is evaluated, instead of sending full series. This reduces the querier memory usage, because the chunks are not buffered in memory. Store-gateways not supporting streaming send full series.")
+	f.Uint64Var(&cfg.StreamingChunksBatchSize, "querier.streaming-chunks-batch-size", 256, "Number of series whose chunks are sent by store-gateways in a single message, when streaming chunks from store-gateways is enabled. The querier buffers at most one batch per store-gateway.")
 	f.BoolVar(&cfg.ShuffleShardingIngestersEnabled, "querier.shuffle-sharding-ingesters-enabled", true, fmt.Sprintf("Fetch in-memory series from the minimum set of required ingesters, selecting only ingesters which may have received series since -%s. If this setting is false or -%s is '0', queriers always query all ingesters (ingesters shuffle sharding on read path is disabled).", queryIngestersWithinFlag, queryIngestersWithinFlag))
 
 	cfg.EngineConfig.RegisterFlags(f)
@@ -87,6 +96,10 @@ func (cfg *Config) Validate() error {
 		}
 	}
 
+	if cfg.PreferStreamingChunksFromStoreGateways && cfg.StreamingChunksBatchSize == 0 {
+		return errStreamingChunksBatchSize
+	}
+
 	return nil
 }
 
@@ -276,6 +289,17 @@ func (q querier) Select(_ bool, sp *storage.SelectHints, matchers ...*labels.Mat
 		return storage.ErrSeriesSet(validation.NewMaxQueryLengthError(endTime.Sub(startTime), maxQueryLength))
 	}
 
+	set := q.selectSorted(ctx, sp, matchers...)
+
+	// Hide the samples which have aged past the retention rules, even if they
+	// haven't been deleted from the blocks by the compactor yet.
+	if rules := q.limits.CompactorRetentionRules(userID); len(rules) > 0 {
+		return newRetentionRulesSeriesSet(set, rules, q.limits.CompactorBlocksDefaultMaxRetentionPeriod(userID), time.Now(), endMs)
+	}
+	return set
+}
+
+func (q querier) selectSorted(ctx context.Context, sp *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
 	if len(q.queriers) == 1 {
 		return q.queriers[0].Select(true, sp, matchers...)
 	}
@@ -384,8 +408,12 @@ func (q querier) LabelNames(matchers ...*labels.Matcher) ([]string, storage.Warn
 	return strutil.MergeSlices(sets...), warnings, nil
 }
 
-func (querier) Close() error {
-	return nil
+func (q querier) Close() error {
+	errs := tsdb_errors.NewMulti()
+	for _, querier := range q.queriers {
+		errs.Add(querier.Close())
+	}
+	return errs.Err()
 }
 
 func (q querier) mergeSeriesSets(sets []storage.SeriesSet) storage.SeriesSet {
@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/prometheus/common/model"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util/limiter"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+type queryFrontendRequestContextKey int
+
+const queryFrontendRequestKey queryFrontendRequestContextKey = 0
+
+// ContextWithQueryFrontendRequest returns a new context marking the request as received from the query-frontend.
+func ContextWithQueryFrontendRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryFrontendRequestKey, true)
+}
+
+// isQueryFrontendRequest returns whether the request has been received from the query-frontend.
+func isQueryFrontendRequest(ctx context.Context) bool {
+	fromFrontend, _ := ctx.Value(queryFrontendRequestKey).(bool)
+	return fromFrontend
+}
+
+// QueryFrontendRequestMiddleware marks the requests as received from the query-frontend. It must only wrap the
+// handler of the requests received by the querier worker, which are enqueued by the query-frontend, so that
+// clients sending requests directly to the querier can't mark them.
+type QueryFrontendRequestMiddleware struct{}
+
+// NewQueryFrontendRequestMiddleware makes a new QueryFrontendRequestMiddleware.
+func NewQueryFrontendRequestMiddleware() QueryFrontendRequestMiddleware {
+	return QueryFrontendRequestMiddleware{}
+}
+
+// Wrap implements middleware.Interface.
+func (m QueryFrontendRequestMiddleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(ContextWithQueryFrontendRequest(r.Context())))
+	})
+}
+
+// ResultSizeLimits is the interface of the per-tenant limits enforced by the ResultSizeLimitMiddleware.
+type ResultSizeLimits interface {
+	MaxReturnedSeriesPerQuery(userID string) int
+	MaxQueryResponseSizeBytes(userID string) int
+}
+
+// ResultSizeLimitMiddleware enforces the per-tenant limits on the number of series returned by a query
+// and the size in bytes of its JSON encoded response body. Responses exceeding the limits are replaced by
+// a 422 error identifying the limit hit, before being sent to the client. Queries received from the
+// query-frontend are not checked, because the query-frontend enforces the limits on their merged result.
+type ResultSizeLimitMiddleware struct {
+	limits ResultSizeLimits
+	logger log.Logger
+}
+
+// NewResultSizeLimitMiddleware makes a new ResultSizeLimitMiddleware.
+func NewResultSizeLimitMiddleware(limits ResultSizeLimits, logger log.Logger) ResultSizeLimitMiddleware {
+	return ResultSizeLimitMiddleware{
+		limits: limits,
+		logger: logger,
+	}
+}
+
+// Wrap implements middleware.Interface.
+func (m ResultSizeLimitMiddleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isQueryFrontendRequest(r.Context()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tenantIDs, err := tenant.TenantIDs(r.Context())
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		maxSeries := validation.SmallestPositiveIntPerTenant(tenantIDs, m.limits.MaxReturnedSeriesPerQuery)
+		maxBytes := validation.SmallestPositiveIntPerTenant(tenantIDs, m.limits.MaxQueryResponseSizeBytes)
+		if maxSeries <= 0 && maxBytes <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &limitedResponseWriter{header: http.Header{}, statusCode: http.StatusOK, maxBytes: maxBytes}
+		next.ServeHTTP(rec, r)
+
+		body := rec.body.Bytes()
+
+		// Only successful query results are checked, error responses are sent as is.
+		if rec.isQueryResult() {
+			if err := checkResultSizeLimits(rec, maxSeries, maxBytes); err != nil {
+				level.Debug(m.logger).Log("msg", "query result exceeded limits", "err", err)
+
+				res, _ := apierror.HTTPResponseFromError(err)
+				for _, h := range res.Headers {
+					w.Header()[h.Key] = h.Values
+				}
+				w.Header().Set("Content-Length", strconv.Itoa(len(res.Body)))
+				w.WriteHeader(int(res.Code))
+				if _, err := w.Write(res.Body); err != nil {
+					level.Warn(m.logger).Log("msg", "failed to write query response", "err", err)
+				}
+				return
+			}
+		}
+
+		for name, values := range rec.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(rec.statusCode)
+		if _, err := w.Write(body); err != nil {
+			level.Warn(m.logger).Log("msg", "failed to write query response", "err", err)
+		}
+	})
+}
+
+// checkResultSizeLimits checks the JSON encoded query result buffered by the writer against the limits,
+// returning the error to send to the client if any limit is exceeded. 0 disables a limit.
+func checkResultSizeLimits(rec *limitedResponseWriter, maxSeries, maxBytes int) error {
+	if rec.exceeded {
+		return apierror.New(apierror.TypeExec, fmt.Sprintf(limiter.MaxQueryResponseSizeHitMsgFormat, maxBytes))
+	}
+
+	if maxSeries > 0 && countReturnedSeries(rec.body.Bytes()) > maxSeries {
+		return apierror.New(apierror.TypeExec, fmt.Sprintf(limiter.MaxReturnedSeriesHitMsgFormat, maxSeries))
+	}
+
+	return nil
+}
+
+// countReturnedSeries returns the number of series in the JSON encoded query result. The result
+// is parsed in a streaming fashion, without decoding the series. Scalar and string results, and
+// results which can't be parsed, don't count as series.
+func countReturnedSeries(body []byte) int {
+	iter := jsoniter.ConfigFastest.BorrowIterator(body)
+	defer jsoniter.ConfigFastest.ReturnIterator(iter)
+
+	count := 0
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		if field != "data" {
+			iter.Skip()
+			continue
+		}
+
+		var resultType string
+		var result int
+		for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+			switch field {
+			case "resultType":
+				resultType = iter.ReadString()
+			case "result":
+				if iter.WhatIsNext() != jsoniter.ArrayValue {
+					iter.Skip()
+					continue
+				}
+				for iter.ReadArray() {
+					iter.Skip()
+					result++
+				}
+			default:
+				iter.Skip()
+			}
+		}
+
+		if resultType == model.ValVector.String() || resultType == model.ValMatrix.String() {
+			count = result
+		}
+	}
+
+	if iter.Error != nil {
+		return 0
+	}
+	return count
+}
+
+func isJSONResponse(header http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// limitedResponseWriter is a http.ResponseWriter buffering the response in memory. The body of
+// successful query results is buffered up to maxBytes (if set): once exceeded, the rest of the body
+// is discarded, because the response is going to be replaced by an error anyway.
+type limitedResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+	maxBytes   int
+	exceeded   bool
+}
+
+func (w *limitedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *limitedResponseWriter) Write(b []byte) (int, error) {
+	if w.exceeded {
+		return len(b), nil
+	}
+
+	if w.maxBytes > 0 && w.body.Len()+len(b) > w.maxBytes && w.isQueryResult() {
+		w.exceeded = true
+		w.body.Reset()
+		return len(b), nil
+	}
+
+	return w.body.Write(b)
+}
+
+func (w *limitedResponseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+}
+
+// isQueryResult returns whether the response is a successful JSON encoded query result.
+func (w *limitedResponseWriter) isQueryResult() bool {
+	return w.statusCode/100 == 2 && isJSONResponse(w.header)
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/util/limiter"
+)
+
+type mockResultSizeLimits struct {
+	maxSeries int
+	maxBytes  int
+}
+
+func (m mockResultSizeLimits) MaxReturnedSeriesPerQuery(string) int {
+	return m.maxSeries
+}
+
+func (m mockResultSizeLimits) MaxQueryResponseSizeBytes(string) int {
+	return m.maxBytes
+}
+
+func TestResultSizeLimitMiddleware(t *testing.T) {
+	const (
+		vectorBody = `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"a"},"value":[1,"1"]},{"metric":{"__name__":"b"},"value":[1,"2"]}]}}`
+		scalarBody = `{"status":"success","data":{"resultType":"scalar","result":[1,"1"]}}`
+		errorBody  = `{"status":"error","errorType":"bad_data","error":"invalid query"}`
+	)
+
+	tests := map[string]struct {
+		limits             mockResultSizeLimits
+		fromQueryFrontend  bool
+		withFrontendHeader bool
+		statusCode         int
+		body               string
+		expectedStatusCode int
+		expectedError      string
+	}{
+		"no limits": {
+			statusCode:         http.StatusOK,
+			body:               vectorBody,
+			expectedStatusCode: http.StatusOK,
+		},
+		"limits not exceeded": {
+			limits:             mockResultSizeLimits{maxSeries: 2, maxBytes: len(vectorBody)},
+			statusCode:         http.StatusOK,
+			body:               vectorBody,
+			expectedStatusCode: http.StatusOK,
+		},
+		"max returned series exceeded": {
+			limits:             mockResultSizeLimits{maxSeries: 1},
+			statusCode:         http.StatusOK,
+			body:               vectorBody,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedError:      fmt.Sprintf(limiter.MaxReturnedSeriesHitMsgFormat, 1),
+		},
+		"max response size exceeded": {
+			limits:             mockResultSizeLimits{maxBytes: len(vectorBody) - 1},
+			statusCode:         http.StatusOK,
+			body:               vectorBody,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedError:      fmt.Sprintf(limiter.MaxQueryResponseSizeHitMsgFormat, len(vectorBody)-1),
+		},
+		"queries received from the query-frontend are not checked": {
+			limits:             mockResultSizeLimits{maxSeries: 1, maxBytes: 1},
+			fromQueryFrontend:  true,
+			statusCode:         http.StatusOK,
+			body:               vectorBody,
+			expectedStatusCode: http.StatusOK,
+		},
+		"queries claiming to come from the query-frontend through a header are checked": {
+			limits:             mockResultSizeLimits{maxSeries: 1},
+			withFrontendHeader: true,
+			statusCode:         http.StatusOK,
+			body:               vectorBody,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedError:      fmt.Sprintf(limiter.MaxReturnedSeriesHitMsgFormat, 1),
+		},
+		"scalar results don't count as series": {
+			limits:             mockResultSizeLimits{maxSeries: 1},
+			statusCode:         http.StatusOK,
+			body:               scalarBody,
+			expectedStatusCode: http.StatusOK,
+		},
+		"error responses are not checked": {
+			limits:             mockResultSizeLimits{maxBytes: 1},
+			statusCode:         http.StatusBadRequest,
+			body:               errorBody,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			handler := NewResultSizeLimitMiddleware(testData.limits, log.NewNopLogger()).Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(testData.statusCode)
+				_, _ = w.Write([]byte(testData.body))
+			}))
+			if testData.fromQueryFrontend {
+				handler = NewQueryFrontendRequestMiddleware().Wrap(handler)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/query", nil)
+			req = req.WithContext(user.InjectOrgID(req.Context(), "test"))
+			if testData.withFrontendHeader {
+				req.Header.Set("X-Mimir-Query-Frontend", "true")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, testData.expectedStatusCode, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			if testData.expectedError == "" {
+				assert.Equal(t, testData.body, rec.Body.String())
+				return
+			}
+
+			expectedBody, err := json.Marshal(map[string]string{"status": "error", "errorType": "execution", "error": testData.expectedError})
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expectedBody), rec.Body.String())
+		})
+	}
+}
+
+func TestCountReturnedSeries(t *testing.T) {
+	for body, expected := range map[string]int{
+		`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[[1,"1"]]},{"metric":{"a":"b"},"values":[]}]}}`: 2,
+		`{"status":"success","data":{"result":[{"metric":{}, "value":[1,"1"]}],"resultType":"vector"}}`:                                    1,
+		`{"status":"success","data":{"resultType":"vector","result":[]}}`:                                                                  0,
+		`{"status":"success","data":{"resultType":"string","result":[1,"foo"]}}`:                                                           0,
+		`{"status":"success","data":`: 0,
+	} {
+		assert.Equal(t, expected, countReturnedSeries([]byte(body)), body)
+	}
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"time"
+
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// retentionRulesSeriesSet hides the samples of the series matching a retention rule which are
+// older than the rule period, and the samples of the series not matching any rule which are
+// older than the default retention period. Series whose samples are all expired in the queried
+// time range are removed from the set.
+type retentionRulesSeriesSet struct {
+	storage.SeriesSet
+
+	rules         validation.RetentionRules
+	defaultPeriod time.Duration
+	now           time.Time
+	maxT          int64
+	curr          storage.Series
+}
+
+// newRetentionRulesSeriesSet makes a new retentionRulesSeriesSet. A defaultPeriod of 0 means
+// the series not matching any rule are retained forever.
+func newRetentionRulesSeriesSet(set storage.SeriesSet, rules validation.RetentionRules, defaultPeriod time.Duration, now time.Time, maxT int64) storage.SeriesSet {
+	return &retentionRulesSeriesSet{
+		SeriesSet:     set,
+		rules:         rules,
+		defaultPeriod: defaultPeriod,
+		now:           now,
+		maxT:          maxT,
+	}
+}
+
+func (s *retentionRulesSeriesSet) Next() bool {
+	for s.SeriesSet.Next() {
+		series := s.SeriesSet.At()
+
+		period := s.defaultPeriod
+		if rule, ok := s.rules.Match(series.Labels()); ok {
+			period = time.Duration(rule.Period)
+		}
+		if period <= 0 {
+			s.curr = series
+			return true
+		}
+
+		minT := util.TimeToMillis(s.now.Add(-period))
+		if minT > s.maxT {
+			continue
+		}
+
+		s.curr = &retentionRulesSeries{Series: series, minT: minT}
+		return true
+	}
+
+	s.curr = nil
+	return false
+}
+
+func (s *retentionRulesSeriesSet) At() storage.Series {
+	return s.curr
+}
+
+// retentionRulesSeries is a storage.Series whose samples before minT are skipped.
+type retentionRulesSeries struct {
+	storage.Series
+	minT int64
+}
+
+func (s *retentionRulesSeries) Iterator() chunkenc.Iterator {
+	return &minTimeIterator{Iterator: s.Series.Iterator(), minT: s.minT}
+}
+
+// minTimeIterator is a chunkenc.Iterator skipping the samples before minT.
+type minTimeIterator struct {
+	chunkenc.Iterator
+	minT    int64
+	started bool
+}
+
+func (it *minTimeIterator) Next() bool {
+	if !it.started {
+		it.started = true
+		return it.Iterator.Seek(it.minT)
+	}
+	return it.Iterator.Next()
+}
+
+func (it *minTimeIterator) Seek(t int64) bool {
+	it.started = true
+	if t < it.minT {
+		t = it.minT
+	}
+	return it.Iterator.Seek(t)
+}
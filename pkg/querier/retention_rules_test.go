@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/storage/series"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestRetentionRulesSeriesSet(t *testing.T) {
+	var rules validation.RetentionRules
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- selector: '{env="dev"}'
+  period: 2h
+- selector: '{env="test"}'
+  period: 30m
+`), &rules))
+
+	now := time.Unix(10*3600, 0)
+	nowMs := now.UnixMilli()
+
+	// Each series has a sample every 10 minutes in the last 3 hours.
+	newSeries := func(lset labels.Labels) storage.Series {
+		var samples []model.SamplePair
+		for ts := nowMs - 3*time.Hour.Milliseconds(); ts <= nowMs; ts += 10 * time.Minute.Milliseconds() {
+			samples = append(samples, model.SamplePair{Timestamp: model.Time(ts), Value: model.SampleValue(ts)})
+		}
+		return series.NewConcreteSeries(lset, samples)
+	}
+
+	newSet := func() storage.SeriesSet {
+		return series.NewConcreteSeriesSet([]storage.Series{
+			newSeries(labels.FromStrings("__name__", "up", "env", "dev")),
+			newSeries(labels.FromStrings("__name__", "up", "env", "prod")),
+			newSeries(labels.FromStrings("__name__", "up", "env", "test")),
+		})
+	}
+
+	t.Run("samples older than the rule period are skipped", func(t *testing.T) {
+		set := newRetentionRulesSeriesSet(newSet(), rules, 0, now, nowMs)
+
+		minTimes := map[string]int64{}
+		counts := map[string]int{}
+		for set.Next() {
+			s := set.At()
+			env := s.Labels().Get("env")
+
+			it := s.Iterator()
+			for it.Next() {
+				ts, _ := it.At()
+				if _, ok := minTimes[env]; !ok {
+					minTimes[env] = ts
+				}
+				counts[env]++
+			}
+			require.NoError(t, it.Err())
+		}
+		require.NoError(t, set.Err())
+
+		assert.Equal(t, map[string]int64{
+			"dev":  nowMs - 2*time.Hour.Milliseconds(),
+			"prod": nowMs - 3*time.Hour.Milliseconds(),
+			"test": nowMs - 30*time.Minute.Milliseconds(),
+		}, minTimes)
+		assert.Equal(t, map[string]int{"dev": 13, "prod": 19, "test": 4}, counts)
+	})
+
+	t.Run("seeking before the rule period seeks to the first retained sample", func(t *testing.T) {
+		set := newRetentionRulesSeriesSet(newSet(), rules, 0, now, nowMs)
+
+		require.True(t, set.Next())
+		require.Equal(t, "dev", set.At().Labels().Get("env"))
+
+		it := set.At().Iterator()
+		require.True(t, it.Seek(0))
+		ts, _ := it.At()
+		assert.Equal(t, nowMs-2*time.Hour.Milliseconds(), ts)
+
+		require.True(t, it.Seek(nowMs-time.Hour.Milliseconds()))
+		ts, _ = it.At()
+		assert.Equal(t, nowMs-time.Hour.Milliseconds(), ts)
+	})
+
+	t.Run("series with all samples expired in the queried range are removed", func(t *testing.T) {
+		set := newRetentionRulesSeriesSet(newSet(), rules, 0, now, nowMs-time.Hour.Milliseconds())
+
+		var envs []string
+		for set.Next() {
+			envs = append(envs, set.At().Labels().Get("env"))
+		}
+		require.NoError(t, set.Err())
+
+		assert.Equal(t, []string{"dev", "prod"}, envs)
+	})
+
+	t.Run("samples of series not matching any rule older than the default period are skipped", func(t *testing.T) {
+		set := newRetentionRulesSeriesSet(series.NewConcreteSeriesSet([]storage.Series{
+			newSeries(labels.FromStrings("__name__", "up", "env", "dev")),
+			newSeries(labels.FromStrings("__name__", "up", "env", "prod")),
+		}), rules, time.Hour, now, nowMs)
+
+		minTimes := map[string]int64{}
+		for set.Next() {
+			s := set.At()
+
+			it := s.Iterator()
+			require.True(t, it.Next())
+			minTimes[s.Labels().Get("env")], _ = it.At()
+			require.NoError(t, it.Err())
+		}
+		require.NoError(t, set.Err())
+
+		assert.Equal(t, map[string]int64{
+			"dev":  nowMs - 2*time.Hour.Milliseconds(),
+			"prod": nowMs - time.Hour.Milliseconds(),
+		}, minTimes)
+	})
+
+	t.Run("series not matching any rule with all samples older than the default period are removed", func(t *testing.T) {
+		set := newRetentionRulesSeriesSet(newSet(), rules, time.Hour, now, nowMs-90*time.Minute.Milliseconds())
+
+		var envs []string
+		for set.Next() {
+			envs = append(envs, set.At().Labels().Get("env"))
+		}
+		require.NoError(t, set.Err())
+
+		assert.Equal(t, []string{"dev"}, envs)
+	})
+}
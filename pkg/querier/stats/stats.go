@@ -139,6 +139,162 @@ func (s *Stats) LoadSplitQueries() uint32 {
 	return atomic.LoadUint32(&s.SplitQueries)
 }
 
+func (s *Stats) AddSamplesProcessed(samples uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.SamplesProcessed, samples)
+}
+
+func (s *Stats) LoadSamplesProcessed() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.SamplesProcessed)
+}
+
+// UpdatePeakSamples sets the peak samples to the input value if it's higher than the current one.
+func (s *Stats) UpdatePeakSamples(samples uint64) {
+	if s == nil {
+		return
+	}
+
+	for {
+		curr := atomic.LoadUint64(&s.PeakSamples)
+		if samples <= curr || atomic.CompareAndSwapUint64(&s.PeakSamples, curr, samples) {
+			return
+		}
+	}
+}
+
+func (s *Stats) LoadPeakSamples() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.PeakSamples)
+}
+
+func (s *Stats) AddQueueTime(t time.Duration) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddInt64((*int64)(&s.QueueTime), int64(t))
+}
+
+func (s *Stats) LoadQueueTime() time.Duration {
+	if s == nil {
+		return 0
+	}
+
+	return time.Duration(atomic.LoadInt64((*int64)(&s.QueueTime)))
+}
+
+func (s *Stats) AddIngesterFetchTime(t time.Duration) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddInt64((*int64)(&s.IngesterFetchTime), int64(t))
+}
+
+func (s *Stats) LoadIngesterFetchTime() time.Duration {
+	if s == nil {
+		return 0
+	}
+
+	return time.Duration(atomic.LoadInt64((*int64)(&s.IngesterFetchTime)))
+}
+
+func (s *Stats) AddStoreGatewayFetchTime(t time.Duration) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddInt64((*int64)(&s.StoreGatewayFetchTime), int64(t))
+}
+
+func (s *Stats) LoadStoreGatewayFetchTime() time.Duration {
+	if s == nil {
+		return 0
+	}
+
+	return time.Duration(atomic.LoadInt64((*int64)(&s.StoreGatewayFetchTime)))
+}
+
+func (s *Stats) AddEvaluationTime(t time.Duration) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddInt64((*int64)(&s.EvaluationTime), int64(t))
+}
+
+func (s *Stats) LoadEvaluationTime() time.Duration {
+	if s == nil {
+		return 0
+	}
+
+	return time.Duration(atomic.LoadInt64((*int64)(&s.EvaluationTime)))
+}
+
+func (s *Stats) AddResultsCacheLookups(num uint32) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint32(&s.ResultsCacheLookups, num)
+}
+
+func (s *Stats) LoadResultsCacheLookups() uint32 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint32(&s.ResultsCacheLookups)
+}
+
+func (s *Stats) AddResultsCacheHits(num uint32) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint32(&s.ResultsCacheHits, num)
+}
+
+func (s *Stats) LoadResultsCacheHits() uint32 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint32(&s.ResultsCacheHits)
+}
+
+// UpdateQueryShards sets the query shards to the input value if it's higher than the current one.
+func (s *Stats) UpdateQueryShards(shards uint32) {
+	if s == nil {
+		return
+	}
+
+	for {
+		curr := atomic.LoadUint32(&s.QueryShards)
+		if shards <= curr || atomic.CompareAndSwapUint32(&s.QueryShards, curr, shards) {
+			return
+		}
+	}
+}
+
+func (s *Stats) LoadQueryShards() uint32 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint32(&s.QueryShards)
+}
+
 // Merge the provided Stats into this one.
 func (s *Stats) Merge(other *Stats) {
 	if s == nil || other == nil {
@@ -151,6 +307,15 @@ func (s *Stats) Merge(other *Stats) {
 	s.AddFetchedChunks(other.LoadFetchedChunks())
 	s.AddShardedQueries(other.LoadShardedQueries())
 	s.AddSplitQueries(other.LoadSplitQueries())
+	s.AddSamplesProcessed(other.LoadSamplesProcessed())
+	s.UpdatePeakSamples(other.LoadPeakSamples())
+	s.AddQueueTime(other.LoadQueueTime())
+	s.AddIngesterFetchTime(other.LoadIngesterFetchTime())
+	s.AddStoreGatewayFetchTime(other.LoadStoreGatewayFetchTime())
+	s.AddEvaluationTime(other.LoadEvaluationTime())
+	s.AddResultsCacheLookups(other.LoadResultsCacheLookups())
+	s.AddResultsCacheHits(other.LoadResultsCacheHits())
+	s.UpdateQueryShards(other.LoadQueryShards())
 }
 
 func ShouldTrackHTTPGRPCResponse(r *httpgrpc.HTTPResponse) bool {
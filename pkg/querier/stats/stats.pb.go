@@ -42,6 +42,25 @@ type Stats struct {
 	ShardedQueries uint32 `protobuf:"varint,5,opt,name=sharded_queries,json=shardedQueries,proto3" json:"sharded_queries,omitempty"`
 	// The number of split partial queries executed. 0 if splitting is disabled or the query can't be split.
 	SplitQueries uint32 `protobuf:"varint,6,opt,name=split_queries,json=splitQueries,proto3" json:"split_queries,omitempty"`
+	// The number of samples processed by the PromQL engine to execute the query.
+	SamplesProcessed uint64 `protobuf:"varint,7,opt,name=samples_processed,json=samplesProcessed,proto3" json:"samples_processed,omitempty"`
+	// The peak number of samples loaded in memory at the same time by the PromQL engine to execute the query.
+	// When merging stats of multiple queries, the highest peak is retained.
+	PeakSamples uint64 `protobuf:"varint,8,opt,name=peak_samples,json=peakSamples,proto3" json:"peak_samples,omitempty"`
+	// The sum of all time spent by the query waiting in the queue before being picked up by a querier.
+	QueueTime time.Duration `protobuf:"bytes,9,opt,name=queue_time,json=queueTime,proto3,stdduration" json:"queue_time"`
+	// The sum of all time spent fetching series and chunks from ingesters.
+	IngesterFetchTime time.Duration `protobuf:"bytes,10,opt,name=ingester_fetch_time,json=ingesterFetchTime,proto3,stdduration" json:"ingester_fetch_time"`
+	// The sum of all time spent fetching series and chunks from store-gateways.
+	StoreGatewayFetchTime time.Duration `protobuf:"bytes,11,opt,name=store_gateway_fetch_time,json=storeGatewayFetchTime,proto3,stdduration" json:"store_gateway_fetch_time"`
+	// The sum of all time spent by the PromQL engine to evaluate the query.
+	EvaluationTime time.Duration `protobuf:"bytes,12,opt,name=evaluation_time,json=evaluationTime,proto3,stdduration" json:"evaluation_time"`
+	// The number of lookups to the query results cache.
+	ResultsCacheLookups uint32 `protobuf:"varint,13,opt,name=results_cache_lookups,json=resultsCacheLookups,proto3" json:"results_cache_lookups,omitempty"`
+	// The number of lookups to the query results cache which returned a cached response, either fully or partially.
+	ResultsCacheHits uint32 `protobuf:"varint,14,opt,name=results_cache_hits,json=resultsCacheHits,proto3" json:"results_cache_hits,omitempty"`
+	// The highest number of shards a query has been sharded into. 0 if sharding is disabled or the query can't be sharded.
+	QueryShards uint32 `protobuf:"varint,15,opt,name=query_shards,json=queryShards,proto3" json:"query_shards,omitempty"`
 }
 
 func (m *Stats) Reset()      { *m = Stats{} }
@@ -118,6 +137,69 @@ func (m *Stats) GetSplitQueries() uint32 {
 	return 0
 }
 
+func (m *Stats) GetSamplesProcessed() uint64 {
+	if m != nil {
+		return m.SamplesProcessed
+	}
+	return 0
+}
+
+func (m *Stats) GetPeakSamples() uint64 {
+	if m != nil {
+		return m.PeakSamples
+	}
+	return 0
+}
+
+func (m *Stats) GetQueueTime() time.Duration {
+	if m != nil {
+		return m.QueueTime
+	}
+	return 0
+}
+
+func (m *Stats) GetIngesterFetchTime() time.Duration {
+	if m != nil {
+		return m.IngesterFetchTime
+	}
+	return 0
+}
+
+func (m *Stats) GetStoreGatewayFetchTime() time.Duration {
+	if m != nil {
+		return m.StoreGatewayFetchTime
+	}
+	return 0
+}
+
+func (m *Stats) GetEvaluationTime() time.Duration {
+	if m != nil {
+		return m.EvaluationTime
+	}
+	return 0
+}
+
+func (m *Stats) GetResultsCacheLookups() uint32 {
+	if m != nil {
+		return m.ResultsCacheLookups
+	}
+	return 0
+}
+
+func (m *Stats) GetResultsCacheHits() uint32 {
+	if m != nil {
+		return m.ResultsCacheHits
+	}
+	return 0
+}
+
+func (m *Stats) GetQueryShards() uint32 {
+	if m != nil {
+		return m.QueryShards
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Stats)(nil), "stats.Stats")
 }
@@ -125,29 +207,40 @@ func init() {
 func init() { proto.RegisterFile("stats.proto", fileDescriptor_b4756a0aec8b9d44) }
 
 var fileDescriptor_b4756a0aec8b9d44 = []byte{
-	// 340 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x54, 0x91, 0xbd, 0x52, 0xea, 0x40,
-	0x14, 0xc7, 0x77, 0xb9, 0xc0, 0x70, 0x97, 0xcb, 0x75, 0x8c, 0x16, 0x91, 0xe2, 0xc0, 0x68, 0x21,
-	0x8d, 0xc1, 0xd1, 0xd2, 0xc6, 0x01, 0x5f, 0x40, 0xb0, 0xb2, 0xc9, 0xe4, 0x63, 0x49, 0x32, 0x06,
-	0x16, 0xb3, 0x9b, 0x71, 0xec, 0x7c, 0x04, 0x4b, 0x1f, 0xc1, 0x19, 0x5f, 0x84, 0x92, 0x92, 0x4a,
-	0x65, 0x69, 0x2c, 0x79, 0x04, 0x27, 0x27, 0xc1, 0x8f, 0x6e, 0xcf, 0xf9, 0xfd, 0x7f, 0xfb, 0x9f,
-	0xd9, 0x65, 0x75, 0xa9, 0x1c, 0x25, 0xad, 0x69, 0x22, 0x94, 0x30, 0x2a, 0x38, 0x34, 0x8f, 0x82,
-	0x48, 0x85, 0xa9, 0x6b, 0x79, 0x62, 0xdc, 0x0d, 0x44, 0x20, 0xba, 0x48, 0xdd, 0x74, 0x84, 0x13,
-	0x0e, 0x78, 0xca, 0xad, 0x26, 0x04, 0x42, 0x04, 0x31, 0xff, 0x4e, 0xf9, 0x69, 0xe2, 0xa8, 0x48,
-	0x4c, 0x72, 0xbe, 0xff, 0x52, 0x62, 0x95, 0x61, 0x76, 0xb1, 0x71, 0xce, 0xfe, 0xde, 0x39, 0x71,
-	0x6c, 0xab, 0x68, 0xcc, 0x4d, 0xda, 0xa6, 0x9d, 0xfa, 0xc9, 0x9e, 0x95, 0xdb, 0xd6, 0xc6, 0xb6,
-	0x2e, 0x0a, 0xbb, 0x57, 0x9b, 0xbd, 0xb6, 0xc8, 0xd3, 0x5b, 0x8b, 0x0e, 0x6a, 0x99, 0x75, 0x15,
-	0x8d, 0xb9, 0x71, 0xcc, 0x76, 0x47, 0x5c, 0x79, 0x21, 0xf7, 0x6d, 0xc9, 0x93, 0x88, 0x4b, 0xdb,
-	0x13, 0xe9, 0x44, 0x99, 0xa5, 0x36, 0xed, 0x94, 0x07, 0x46, 0xc1, 0x86, 0x88, 0xfa, 0x19, 0x31,
-	0x2c, 0xb6, 0xb3, 0x31, 0xbc, 0x30, 0x9d, 0xdc, 0xd8, 0xee, 0xbd, 0xe2, 0xd2, 0xfc, 0x83, 0xc2,
-	0x76, 0x81, 0xfa, 0x19, 0xe9, 0x65, 0xe0, 0x67, 0x03, 0xe6, 0x37, 0x0d, 0xe5, 0x5f, 0x0d, 0x28,
-	0x14, 0x0d, 0x87, 0x6c, 0x4b, 0x86, 0x4e, 0xe2, 0x73, 0xdf, 0xbe, 0x4d, 0xb1, 0xd9, 0xac, 0xb4,
-	0x69, 0xa7, 0x31, 0xf8, 0x5f, 0xac, 0x2f, 0xf3, 0xad, 0x71, 0xc0, 0x1a, 0x72, 0x1a, 0x47, 0xea,
-	0x2b, 0x56, 0xc5, 0xd8, 0x3f, 0x5c, 0x16, 0xa1, 0xde, 0xd9, 0x7c, 0x09, 0x64, 0xb1, 0x04, 0xb2,
-	0x5e, 0x02, 0x7d, 0xd0, 0x40, 0x9f, 0x35, 0xd0, 0x99, 0x06, 0x3a, 0xd7, 0x40, 0xdf, 0x35, 0xd0,
-	0x0f, 0x0d, 0x64, 0xad, 0x81, 0x3e, 0xae, 0x80, 0xcc, 0x57, 0x40, 0x16, 0x2b, 0x20, 0xd7, 0xf9,
-	0xcf, 0xb9, 0x55, 0x7c, 0xc5, 0xd3, 0xcf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x9d, 0x30, 0xbc, 0x87,
-	0xd6, 0x01, 0x00, 0x00,
+	// 527 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x93, 0x3f, 0x6f, 0xd3, 0x40,
+	0x18, 0xc6, 0x7d, 0xd0, 0x84, 0xe4, 0xf2, 0xaf, 0x71, 0xa8, 0x64, 0x3a, 0x5c, 0x03, 0x0c, 0x44,
+	0x02, 0x5c, 0x54, 0x46, 0x16, 0x94, 0x20, 0x60, 0xe8, 0x00, 0x09, 0x13, 0x42, 0x3a, 0x39, 0xce,
+	0x5b, 0xdb, 0x8a, 0x93, 0x73, 0x7d, 0x67, 0xaa, 0x6c, 0x7c, 0x04, 0x46, 0x3e, 0x02, 0x5f, 0x04,
+	0xa9, 0x63, 0xc6, 0x4e, 0x40, 0x9c, 0x85, 0xb1, 0x1f, 0x01, 0xf9, 0x3d, 0xbb, 0x49, 0xb7, 0x6c,
+	0xb9, 0xe7, 0x79, 0x7e, 0xcf, 0xab, 0xbc, 0x77, 0xa6, 0x35, 0xa9, 0x1c, 0x25, 0xed, 0x28, 0x16,
+	0x4a, 0x98, 0x25, 0x3c, 0x1c, 0x3e, 0xf7, 0x02, 0xe5, 0x27, 0x63, 0xdb, 0x15, 0xb3, 0x63, 0x4f,
+	0x78, 0xe2, 0x18, 0xdd, 0x71, 0x72, 0x86, 0x27, 0x3c, 0xe0, 0x2f, 0x4d, 0x1d, 0x32, 0x4f, 0x08,
+	0x2f, 0x84, 0x4d, 0x6a, 0x92, 0xc4, 0x8e, 0x0a, 0xc4, 0x5c, 0xfb, 0x8f, 0x7e, 0x95, 0x69, 0x69,
+	0x94, 0x15, 0x9b, 0xaf, 0x69, 0xf5, 0xc2, 0x09, 0x43, 0xae, 0x82, 0x19, 0x58, 0xa4, 0x4b, 0x7a,
+	0xb5, 0x93, 0x07, 0xb6, 0xa6, 0xed, 0x82, 0xb6, 0xdf, 0xe4, 0x74, 0xbf, 0x72, 0xf9, 0xfb, 0xc8,
+	0xf8, 0xf1, 0xe7, 0x88, 0x0c, 0x2b, 0x19, 0xf5, 0x29, 0x98, 0x81, 0xf9, 0x82, 0xde, 0x3f, 0x03,
+	0xe5, 0xfa, 0x30, 0xe1, 0x12, 0xe2, 0x00, 0x24, 0x77, 0x45, 0x32, 0x57, 0xd6, 0x9d, 0x2e, 0xe9,
+	0xed, 0x0d, 0xcd, 0xdc, 0x1b, 0xa1, 0x35, 0xc8, 0x1c, 0xd3, 0xa6, 0x9d, 0x82, 0x70, 0xfd, 0x64,
+	0x3e, 0xe5, 0xe3, 0x85, 0x02, 0x69, 0xdd, 0x45, 0xa0, 0x9d, 0x5b, 0x83, 0xcc, 0xe9, 0x67, 0xc6,
+	0xf6, 0x04, 0xcc, 0x17, 0x13, 0xf6, 0x6e, 0x4d, 0x40, 0x20, 0x9f, 0xf0, 0x84, 0xb6, 0xa4, 0xef,
+	0xc4, 0x13, 0x98, 0xf0, 0xf3, 0x04, 0x27, 0x5b, 0xa5, 0x2e, 0xe9, 0x35, 0x86, 0xcd, 0x5c, 0xfe,
+	0xa8, 0x55, 0xf3, 0x31, 0x6d, 0xc8, 0x28, 0x0c, 0xd4, 0x4d, 0xac, 0x8c, 0xb1, 0x3a, 0x8a, 0x45,
+	0xe8, 0x29, 0x6d, 0x4b, 0x67, 0x16, 0x85, 0x20, 0x79, 0x14, 0x0b, 0x17, 0xa4, 0x84, 0x89, 0x75,
+	0x0f, 0x87, 0xef, 0xe7, 0xc6, 0x87, 0x42, 0x37, 0x1f, 0xd2, 0x7a, 0x04, 0xce, 0x94, 0xe7, 0x86,
+	0x55, 0xc1, 0x5c, 0x2d, 0xd3, 0x46, 0x5a, 0x32, 0xfb, 0x94, 0x9e, 0x27, 0x90, 0x80, 0x5e, 0x7a,
+	0x75, 0xf7, 0xa5, 0x57, 0x11, 0xc3, 0xad, 0x8f, 0x68, 0x27, 0x98, 0x7b, 0x20, 0x15, 0xc4, 0x1c,
+	0x17, 0xa0, 0xcb, 0xe8, 0xee, 0x65, 0xed, 0x82, 0x7f, 0x9b, 0xe1, 0x58, 0xfa, 0x85, 0x5a, 0x52,
+	0x89, 0x18, 0xb8, 0xe7, 0x28, 0xb8, 0x70, 0x16, 0xdb, 0xcd, 0xb5, 0xdd, 0x9b, 0x0f, 0xb0, 0xe4,
+	0x9d, 0xee, 0xd8, 0xb4, 0x9f, 0xd2, 0x16, 0x7c, 0x75, 0xc2, 0x04, 0xe3, 0xba, 0xb4, 0xbe, 0x7b,
+	0x69, 0x73, 0xc3, 0x62, 0xdb, 0x09, 0x3d, 0x88, 0x41, 0x26, 0xa1, 0x92, 0xdc, 0x75, 0x5c, 0x1f,
+	0x78, 0x28, 0xc4, 0x34, 0x89, 0xa4, 0xd5, 0xc0, 0x1b, 0xec, 0xe4, 0xe6, 0x20, 0xf3, 0x4e, 0xb5,
+	0x65, 0x3e, 0xa3, 0xe6, 0x6d, 0xc6, 0x0f, 0x94, 0xb4, 0x9a, 0x08, 0xec, 0x6f, 0x03, 0xef, 0x03,
+	0x25, 0xb3, 0x9b, 0xcc, 0x5e, 0xc5, 0x82, 0xe3, 0x9b, 0x91, 0x56, 0x0b, 0x73, 0x35, 0xd4, 0x46,
+	0x28, 0xf5, 0x5f, 0x2d, 0x57, 0xcc, 0xb8, 0x5a, 0x31, 0xe3, 0x7a, 0xc5, 0xc8, 0xb7, 0x94, 0x91,
+	0x9f, 0x29, 0x23, 0x97, 0x29, 0x23, 0xcb, 0x94, 0x91, 0xbf, 0x29, 0x23, 0xff, 0x52, 0x66, 0x5c,
+	0xa7, 0x8c, 0x7c, 0x5f, 0x33, 0x63, 0xb9, 0x66, 0xc6, 0xd5, 0x9a, 0x19, 0x9f, 0xf5, 0x37, 0x3d,
+	0x2e, 0xe3, 0xdf, 0x7d, 0xf9, 0x7f, 0x00, 0x0d, 0xed, 0xd3, 0xe0, 0xf0, 0x03, 0x00, 0x00,
 }
 
 func (this *Stats) Equal(that interface{}) bool {
@@ -187,13 +280,40 @@ func (this *Stats) Equal(that interface{}) bool {
 	if this.SplitQueries != that1.SplitQueries {
 		return false
 	}
+	if this.SamplesProcessed != that1.SamplesProcessed {
+		return false
+	}
+	if this.PeakSamples != that1.PeakSamples {
+		return false
+	}
+	if this.QueueTime != that1.QueueTime {
+		return false
+	}
+	if this.IngesterFetchTime != that1.IngesterFetchTime {
+		return false
+	}
+	if this.StoreGatewayFetchTime != that1.StoreGatewayFetchTime {
+		return false
+	}
+	if this.EvaluationTime != that1.EvaluationTime {
+		return false
+	}
+	if this.ResultsCacheLookups != that1.ResultsCacheLookups {
+		return false
+	}
+	if this.ResultsCacheHits != that1.ResultsCacheHits {
+		return false
+	}
+	if this.QueryShards != that1.QueryShards {
+		return false
+	}
 	return true
 }
 func (this *Stats) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 10)
+	s := make([]string, 0, 19)
 	s = append(s, "&stats.Stats{")
 	s = append(s, "WallTime: "+fmt.Sprintf("%#v", this.WallTime)+",\n")
 	s = append(s, "FetchedSeriesCount: "+fmt.Sprintf("%#v", this.FetchedSeriesCount)+",\n")
@@ -201,6 +321,15 @@ func (this *Stats) GoString() string {
 	s = append(s, "FetchedChunksCount: "+fmt.Sprintf("%#v", this.FetchedChunksCount)+",\n")
 	s = append(s, "ShardedQueries: "+fmt.Sprintf("%#v", this.ShardedQueries)+",\n")
 	s = append(s, "SplitQueries: "+fmt.Sprintf("%#v", this.SplitQueries)+",\n")
+	s = append(s, "SamplesProcessed: "+fmt.Sprintf("%#v", this.SamplesProcessed)+",\n")
+	s = append(s, "PeakSamples: "+fmt.Sprintf("%#v", this.PeakSamples)+",\n")
+	s = append(s, "QueueTime: "+fmt.Sprintf("%#v", this.QueueTime)+",\n")
+	s = append(s, "IngesterFetchTime: "+fmt.Sprintf("%#v", this.IngesterFetchTime)+",\n")
+	s = append(s, "StoreGatewayFetchTime: "+fmt.Sprintf("%#v", this.StoreGatewayFetchTime)+",\n")
+	s = append(s, "EvaluationTime: "+fmt.Sprintf("%#v", this.EvaluationTime)+",\n")
+	s = append(s, "ResultsCacheLookups: "+fmt.Sprintf("%#v", this.ResultsCacheLookups)+",\n")
+	s = append(s, "ResultsCacheHits: "+fmt.Sprintf("%#v", this.ResultsCacheHits)+",\n")
+	s = append(s, "QueryShards: "+fmt.Sprintf("%#v", this.QueryShards)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -232,6 +361,63 @@ func (m *Stats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.QueryShards != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.QueryShards))
+		i--
+		dAtA[i] = 0x78
+	}
+	if m.ResultsCacheHits != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.ResultsCacheHits))
+		i--
+		dAtA[i] = 0x70
+	}
+	if m.ResultsCacheLookups != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.ResultsCacheLookups))
+		i--
+		dAtA[i] = 0x68
+	}
+	n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationTime, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationTime):])
+	if err1 != nil {
+		return 0, err1
+	}
+	i -= n1
+	i = encodeVarintStats(dAtA, i, uint64(n1))
+	i--
+	dAtA[i] = 0x62
+	n2, err2 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.StoreGatewayFetchTime, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.StoreGatewayFetchTime):])
+	if err2 != nil {
+		return 0, err2
+	}
+	i -= n2
+	i = encodeVarintStats(dAtA, i, uint64(n2))
+	i--
+	dAtA[i] = 0x5a
+	n3, err3 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.IngesterFetchTime, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.IngesterFetchTime):])
+	if err3 != nil {
+		return 0, err3
+	}
+	i -= n3
+	i = encodeVarintStats(dAtA, i, uint64(n3))
+	i--
+	dAtA[i] = 0x52
+	n4, err4 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.QueueTime, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.QueueTime):])
+	if err4 != nil {
+		return 0, err4
+	}
+	i -= n4
+	i = encodeVarintStats(dAtA, i, uint64(n4))
+	i--
+	dAtA[i] = 0x4a
+	if m.PeakSamples != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.PeakSamples))
+		i--
+		dAtA[i] = 0x40
+	}
+	if m.SamplesProcessed != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.SamplesProcessed))
+		i--
+		dAtA[i] = 0x38
+	}
 	if m.SplitQueries != 0 {
 		i = encodeVarintStats(dAtA, i, uint64(m.SplitQueries))
 		i--
@@ -257,12 +443,12 @@ func (m *Stats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i--
 		dAtA[i] = 0x10
 	}
-	n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.WallTime, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.WallTime):])
-	if err1 != nil {
-		return 0, err1
+	n5, err5 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.WallTime, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.WallTime):])
+	if err5 != nil {
+		return 0, err5
 	}
-	i -= n1
-	i = encodeVarintStats(dAtA, i, uint64(n1))
+	i -= n5
+	i = encodeVarintStats(dAtA, i, uint64(n5))
 	i--
 	dAtA[i] = 0xa
 	return len(dAtA) - i, nil
@@ -302,6 +488,29 @@ func (m *Stats) Size() (n int) {
 	if m.SplitQueries != 0 {
 		n += 1 + sovStats(uint64(m.SplitQueries))
 	}
+	if m.SamplesProcessed != 0 {
+		n += 1 + sovStats(uint64(m.SamplesProcessed))
+	}
+	if m.PeakSamples != 0 {
+		n += 1 + sovStats(uint64(m.PeakSamples))
+	}
+	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.QueueTime)
+	n += 1 + l + sovStats(uint64(l))
+	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.IngesterFetchTime)
+	n += 1 + l + sovStats(uint64(l))
+	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.StoreGatewayFetchTime)
+	n += 1 + l + sovStats(uint64(l))
+	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationTime)
+	n += 1 + l + sovStats(uint64(l))
+	if m.ResultsCacheLookups != 0 {
+		n += 1 + sovStats(uint64(m.ResultsCacheLookups))
+	}
+	if m.ResultsCacheHits != 0 {
+		n += 1 + sovStats(uint64(m.ResultsCacheHits))
+	}
+	if m.QueryShards != 0 {
+		n += 1 + sovStats(uint64(m.QueryShards))
+	}
 	return n
 }
 
@@ -322,6 +531,15 @@ func (this *Stats) String() string {
 		`FetchedChunksCount:` + fmt.Sprintf("%v", this.FetchedChunksCount) + `,`,
 		`ShardedQueries:` + fmt.Sprintf("%v", this.ShardedQueries) + `,`,
 		`SplitQueries:` + fmt.Sprintf("%v", this.SplitQueries) + `,`,
+		`SamplesProcessed:` + fmt.Sprintf("%v", this.SamplesProcessed) + `,`,
+		`PeakSamples:` + fmt.Sprintf("%v", this.PeakSamples) + `,`,
+		`QueueTime:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.QueueTime), "Duration", "duration.Duration", 1), `&`, ``, 1) + `,`,
+		`IngesterFetchTime:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.IngesterFetchTime), "Duration", "duration.Duration", 1), `&`, ``, 1) + `,`,
+		`StoreGatewayFetchTime:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.StoreGatewayFetchTime), "Duration", "duration.Duration", 1), `&`, ``, 1) + `,`,
+		`EvaluationTime:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationTime), "Duration", "duration.Duration", 1), `&`, ``, 1) + `,`,
+		`ResultsCacheLookups:` + fmt.Sprintf("%v", this.ResultsCacheLookups) + `,`,
+		`ResultsCacheHits:` + fmt.Sprintf("%v", this.ResultsCacheHits) + `,`,
+		`QueryShards:` + fmt.Sprintf("%v", this.QueryShards) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -491,6 +709,233 @@ func (m *Stats) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SamplesProcessed", wireType)
+			}
+			m.SamplesProcessed = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SamplesProcessed |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PeakSamples", wireType)
+			}
+			m.PeakSamples = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PeakSamples |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueueTime", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.QueueTime, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IngesterFetchTime", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.IngesterFetchTime, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StoreGatewayFetchTime", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.StoreGatewayFetchTime, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EvaluationTime", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthStats
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthStats
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.EvaluationTime, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ResultsCacheLookups", wireType)
+			}
+			m.ResultsCacheLookups = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ResultsCacheLookups |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 14:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ResultsCacheHits", wireType)
+			}
+			m.ResultsCacheHits = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ResultsCacheHits |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 15:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryShards", wireType)
+			}
+			m.QueryShards = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.QueryShards |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStats(dAtA[iNdEx:])
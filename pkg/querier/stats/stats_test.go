@@ -115,6 +115,42 @@ func TestStats_AddSplitQueries(t *testing.T) {
 	})
 }
 
+func TestStats_UpdatePeakSamples(t *testing.T) {
+	t.Run("update and load peak samples", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.UpdatePeakSamples(10)
+		stats.UpdatePeakSamples(30)
+		stats.UpdatePeakSamples(20)
+
+		assert.Equal(t, uint64(30), stats.LoadPeakSamples())
+	})
+
+	t.Run("update and load peak samples nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.UpdatePeakSamples(1)
+
+		assert.Equal(t, uint64(0), stats.LoadPeakSamples())
+	})
+}
+
+func TestStats_UpdateQueryShards(t *testing.T) {
+	t.Run("update and load query shards", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.UpdateQueryShards(8)
+		stats.UpdateQueryShards(16)
+		stats.UpdateQueryShards(4)
+
+		assert.Equal(t, uint32(16), stats.LoadQueryShards())
+	})
+
+	t.Run("update and load query shards nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.UpdateQueryShards(1)
+
+		assert.Equal(t, uint32(0), stats.LoadQueryShards())
+	})
+}
+
 func TestStats_Merge(t *testing.T) {
 	t.Run("merge two stats objects", func(t *testing.T) {
 		stats1 := &Stats{}
@@ -124,6 +160,15 @@ func TestStats_Merge(t *testing.T) {
 		stats1.AddFetchedChunks(10)
 		stats1.AddShardedQueries(20)
 		stats1.AddSplitQueries(10)
+		stats1.AddSamplesProcessed(100)
+		stats1.UpdatePeakSamples(30)
+		stats1.AddQueueTime(time.Millisecond)
+		stats1.AddIngesterFetchTime(2 * time.Millisecond)
+		stats1.AddStoreGatewayFetchTime(3 * time.Millisecond)
+		stats1.AddEvaluationTime(4 * time.Millisecond)
+		stats1.AddResultsCacheLookups(2)
+		stats1.AddResultsCacheHits(1)
+		stats1.UpdateQueryShards(16)
 
 		stats2 := &Stats{}
 		stats2.AddWallTime(time.Second)
@@ -132,6 +177,15 @@ func TestStats_Merge(t *testing.T) {
 		stats2.AddFetchedChunks(11)
 		stats2.AddShardedQueries(21)
 		stats2.AddSplitQueries(11)
+		stats2.AddSamplesProcessed(200)
+		stats2.UpdatePeakSamples(20)
+		stats2.AddQueueTime(time.Second)
+		stats2.AddIngesterFetchTime(2 * time.Second)
+		stats2.AddStoreGatewayFetchTime(3 * time.Second)
+		stats2.AddEvaluationTime(4 * time.Second)
+		stats2.AddResultsCacheLookups(3)
+		stats2.AddResultsCacheHits(3)
+		stats2.UpdateQueryShards(8)
 
 		stats1.Merge(stats2)
 
@@ -141,6 +195,15 @@ func TestStats_Merge(t *testing.T) {
 		assert.Equal(t, uint64(21), stats1.LoadFetchedChunks())
 		assert.Equal(t, uint32(41), stats1.LoadShardedQueries())
 		assert.Equal(t, uint32(21), stats1.LoadSplitQueries())
+		assert.Equal(t, uint64(300), stats1.LoadSamplesProcessed())
+		assert.Equal(t, uint64(30), stats1.LoadPeakSamples())
+		assert.Equal(t, 1001*time.Millisecond, stats1.LoadQueueTime())
+		assert.Equal(t, 2002*time.Millisecond, stats1.LoadIngesterFetchTime())
+		assert.Equal(t, 3003*time.Millisecond, stats1.LoadStoreGatewayFetchTime())
+		assert.Equal(t, 4004*time.Millisecond, stats1.LoadEvaluationTime())
+		assert.Equal(t, uint32(5), stats1.LoadResultsCacheLookups())
+		assert.Equal(t, uint32(4), stats1.LoadResultsCacheHits())
+		assert.Equal(t, uint32(16), stats1.LoadQueryShards())
 	})
 
 	t.Run("merge two nil stats objects", func(t *testing.T) {
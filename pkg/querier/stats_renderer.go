@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"time"
+
+	promql_stats "github.com/prometheus/prometheus/util/stats"
+
+	"github.com/grafana/mimir/pkg/querier/stats"
+)
+
+// StatsRenderer tracks the PromQL engine statistics of a query in the query stats
+// stored in the context (if any), so that they get propagated back to the query-frontend.
+// The engine statistics are rendered in the response only if the "stats" parameter is set,
+// like the default Prometheus API behaviour.
+func StatsRenderer(ctx context.Context, s *promql_stats.Statistics, param string) promql_stats.QueryStats {
+	if s == nil {
+		return nil
+	}
+
+	if queryStats := stats.FromContext(ctx); queryStats != nil {
+		if s.Samples != nil {
+			queryStats.AddSamplesProcessed(uint64(s.Samples.TotalSamples))
+			queryStats.UpdatePeakSamples(uint64(s.Samples.PeakSamples))
+		}
+		if s.Timers != nil {
+			// The timer duration is only exposed in seconds.
+			evalTime := s.Timers.GetTimer(promql_stats.EvalTotalTime).Duration()
+			queryStats.AddEvaluationTime(time.Duration(evalTime * float64(time.Second)))
+		}
+	}
+
+	if param != "" {
+		return promql_stats.NewQueryStats(s)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	promql_stats "github.com/prometheus/prometheus/util/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/querier/stats"
+)
+
+func TestStatsRenderer(t *testing.T) {
+	newEngineStats := func() *promql_stats.Statistics {
+		s := &promql_stats.Statistics{
+			Timers:  promql_stats.NewQueryTimers(),
+			Samples: promql_stats.NewQuerySamples(false),
+		}
+		s.Samples.TotalSamples = 100
+		s.Samples.PeakSamples = 20
+
+		timer := s.Timers.GetTimer(promql_stats.EvalTotalTime).Start()
+		time.Sleep(time.Millisecond)
+		timer.Stop()
+		return s
+	}
+
+	t.Run("should track engine stats in the query stats and not render them if the param is empty", func(t *testing.T) {
+		queryStats, ctx := stats.ContextWithEmptyStats(context.Background())
+		engineStats := newEngineStats()
+
+		assert.Nil(t, StatsRenderer(ctx, engineStats, ""))
+		assert.Equal(t, uint64(100), queryStats.LoadSamplesProcessed())
+		assert.Equal(t, uint64(20), queryStats.LoadPeakSamples())
+		assert.InDelta(t, engineStats.Timers.GetTimer(promql_stats.EvalTotalTime).Duration(), queryStats.LoadEvaluationTime().Seconds(), 1e-6)
+		assert.GreaterOrEqual(t, queryStats.LoadEvaluationTime(), time.Millisecond)
+	})
+
+	t.Run("should render engine stats if the param is set", func(t *testing.T) {
+		rendered := StatsRenderer(context.Background(), newEngineStats(), "all")
+		require.NotNil(t, rendered)
+		require.NotNil(t, rendered.Builtin().Samples)
+		assert.Equal(t, int64(100), rendered.Builtin().Samples.TotalQueryableSamples)
+		assert.Equal(t, 20, rendered.Builtin().Samples.PeakSamples)
+	})
+}
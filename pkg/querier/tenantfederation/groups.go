@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package tenantfederation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/thanos-io/objstore"
+	"gopkg.in/yaml.v3"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+)
+
+// Group is a tenant federation group: a set of member tenants which can be queried
+// together through a single tenant ID, the group ID.
+type Group struct {
+	// Tenants explicitly listed as members of the group.
+	Tenants []string `yaml:"tenants"`
+
+	// TenantsRegex is matched against the tenants found in the storage. Matching
+	// tenants are members of the group, in addition to the explicitly listed ones.
+	TenantsRegex string `yaml:"tenants_regex"`
+
+	tenantsRegex *regexp.Regexp
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (g *Group) UnmarshalYAML(value *yaml.Node) error {
+	type plain Group
+	if err := value.Decode((*plain)(g)); err != nil {
+		return err
+	}
+
+	for _, tenantID := range g.Tenants {
+		if err := tenant.ValidTenantID(tenantID); err != nil {
+			return errors.Wrapf(err, "invalid tenant federation group member %q", tenantID)
+		}
+	}
+
+	if g.TenantsRegex != "" {
+		re, err := regexp.Compile("^(?:" + g.TenantsRegex + ")$")
+		if err != nil {
+			return errors.Wrapf(err, "invalid tenant federation group regex %q", g.TenantsRegex)
+		}
+		g.tenantsRegex = re
+	}
+
+	return nil
+}
+
+// GroupsResolver resolves the tenant IDs of a request, expanding the ID of a tenant federation
+// group to the IDs of its members. Groups are addressed by a single tenant ID: requests for
+// multiple tenant IDs separated by '|' are never resolved to groups.
+//
+// To resolve groups defined by a regex, the resolver periodically scans the storage for the
+// tenants having blocks.
+type GroupsResolver struct {
+	services.Service
+
+	groups       func() map[string]*Group
+	usersScanner *mimir_tsdb.UsersScanner
+	logger       log.Logger
+
+	knownTenantsMx sync.RWMutex
+	knownTenants   []string
+}
+
+// NewGroupsResolver returns a GroupsResolver for the groups returned by the input function. The
+// bucketClient is used to find the tenants matching the group regexes: if nil, only the
+// explicitly listed members are resolved.
+func NewGroupsResolver(cfg Config, groups func() map[string]*Group, bucketClient objstore.Bucket, logger log.Logger) *GroupsResolver {
+	r := &GroupsResolver{
+		groups: groups,
+		logger: logger,
+	}
+
+	if bucketClient != nil {
+		r.usersScanner = mimir_tsdb.NewUsersScanner(bucketClient, mimir_tsdb.AllUsers, logger)
+	}
+
+	r.Service = services.NewTimerService(cfg.GroupsTenantsRefreshInterval, r.refreshKnownTenants, r.refreshKnownTenants, nil).WithName("tenant federation groups resolver")
+	return r
+}
+
+// refreshKnownTenants scans the storage for the tenants which can be matched by group regexes.
+// Failures are logged but not returned, because they shouldn't stop the resolver.
+func (r *GroupsResolver) refreshKnownTenants(ctx context.Context) error {
+	if r.usersScanner == nil || !r.hasRegexGroups() {
+		return nil
+	}
+
+	users, _, err := r.usersScanner.ScanUsers(ctx)
+	if err != nil {
+		level.Warn(r.logger).Log("msg", "failed to scan the storage for tenants matching tenant federation groups", "err", err)
+		return nil
+	}
+	sort.Strings(users)
+
+	r.knownTenantsMx.Lock()
+	r.knownTenants = users
+	r.knownTenantsMx.Unlock()
+
+	return nil
+}
+
+func (r *GroupsResolver) hasRegexGroups() bool {
+	for _, g := range r.groups() {
+		if g != nil && g.tenantsRegex != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// newTenantResolver returns the groups resolver if not nil, otherwise a resolver which doesn't resolve groups.
+func newTenantResolver(groups *GroupsResolver) tenant.Resolver {
+	if groups == nil {
+		return tenant.NewMultiResolver()
+	}
+	return groups
+}
+
+// TenantID implements tenant.Resolver. It never expands groups, because the request
+// is expected to be for a single tenant.
+func (r *GroupsResolver) TenantID(ctx context.Context) (string, error) {
+	return tenant.NewMultiResolver().TenantID(ctx)
+}
+
+// TenantIDs implements tenant.Resolver.
+func (r *GroupsResolver) TenantIDs(ctx context.Context) ([]string, error) {
+	tenantIDs, _, err := r.resolve(ctx)
+	return tenantIDs, err
+}
+
+// resolve returns the tenant IDs of the request and whether they are the members of a group.
+// It can be called on a nil GroupsResolver, in which case groups are never resolved.
+func (r *GroupsResolver) resolve(ctx context.Context) ([]string, bool, error) {
+	tenantIDs, err := tenant.NewMultiResolver().TenantIDs(ctx)
+	if err != nil || r == nil || len(tenantIDs) != 1 {
+		return tenantIDs, false, err
+	}
+
+	group, ok := r.groups()[tenantIDs[0]]
+	if !ok || group == nil {
+		return tenantIDs, false, nil
+	}
+
+	members := r.groupMembers(group)
+	if len(members) == 0 {
+		return nil, false, fmt.Errorf("the tenant federation group %s has no members", tenantIDs[0])
+	}
+
+	return members, true, nil
+}
+
+// groupMembers returns the sorted and deduplicated members of the group.
+func (r *GroupsResolver) groupMembers(group *Group) []string {
+	members := sliceToSet(group.Tenants)
+
+	if group.tenantsRegex != nil {
+		r.knownTenantsMx.RLock()
+		for _, tenantID := range r.knownTenants {
+			if group.tenantsRegex.MatchString(tenantID) {
+				members[tenantID] = struct{}{}
+			}
+		}
+		r.knownTenantsMx.RUnlock()
+	}
+
+	out := make([]string, 0, len(members))
+	for tenantID := range members {
+		out = append(out, tenantID)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ValidateGroups returns an error if any of the groups has an invalid ID.
+func ValidateGroups(groups map[string]*Group) error {
+	for groupID := range groups {
+		if err := tenant.ValidTenantID(groupID); err != nil {
+			return errors.Wrapf(err, "invalid tenant federation group ID %q", groupID)
+		}
+	}
+	return nil
+}
+
+// failuresAsWarningsQuerier wraps the querier of a tenant federation group member, turning
+// its failures into warnings, so that querying the group succeeds even if some members fail.
+type failuresAsWarningsQuerier struct {
+	storage.Querier
+}
+
+func (q *failuresAsWarningsQuerier) Select(sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	return &failuresAsWarningsSeriesSet{SeriesSet: q.Querier.Select(sortSeries, hints, matchers...)}
+}
+
+func (q *failuresAsWarningsQuerier) LabelValues(name string, matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	values, warnings, err := q.Querier.LabelValues(name, matchers...)
+	if err != nil {
+		return nil, append(warnings, err), nil
+	}
+	return values, warnings, nil
+}
+
+func (q *failuresAsWarningsQuerier) LabelNames(matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	names, warnings, err := q.Querier.LabelNames(matchers...)
+	if err != nil {
+		return nil, append(warnings, err), nil
+	}
+	return names, warnings, nil
+}
+
+type failuresAsWarningsSeriesSet struct {
+	storage.SeriesSet
+}
+
+func (s *failuresAsWarningsSeriesSet) Err() error {
+	return nil
+}
+
+func (s *failuresAsWarningsSeriesSet) Warnings() storage.Warnings {
+	if err := s.SeriesSet.Err(); err != nil {
+		return append(s.SeriesSet.Warnings(), err)
+	}
+	return s.SeriesSet.Warnings()
+}
+
+// errQuerier is a storage.Querier failing all requests with the same error.
+type errQuerier struct {
+	err error
+}
+
+func (q errQuerier) Select(bool, *storage.SelectHints, ...*labels.Matcher) storage.SeriesSet {
+	return storage.ErrSeriesSet(q.err)
+}
+
+func (q errQuerier) LabelValues(string, ...*labels.Matcher) ([]string, storage.Warnings, error) {
+	return nil, nil, q.err
+}
+
+func (q errQuerier) LabelNames(...*labels.Matcher) ([]string, storage.Warnings, error) {
+	return nil, nil, q.err
+}
+
+func (q errQuerier) Close() error {
+	return nil
+}
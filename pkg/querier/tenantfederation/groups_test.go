@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package tenantfederation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGroup_UnmarshalYAML(t *testing.T) {
+	tests := map[string]struct {
+		input         string
+		expectedErr   string
+		expectedMatch map[string]bool
+	}{
+		"explicit members": {
+			input: `tenants: [team-a, team-b]`,
+		},
+		"regex": {
+			input:         `tenants_regex: "team-.*"`,
+			expectedMatch: map[string]bool{"team-a": true, "other-team-a": false, "team-a-other": true},
+		},
+		"regex is anchored": {
+			input:         `tenants_regex: "team-a|team-b"`,
+			expectedMatch: map[string]bool{"team-a": true, "team-b": true, "team-ab": false, "xteam-a": false},
+		},
+		"invalid member": {
+			input:       `tenants: ["team-a|team-b"]`,
+			expectedErr: `invalid tenant federation group member "team-a|team-b"`,
+		},
+		"invalid regex": {
+			input:       `tenants_regex: "team-("`,
+			expectedErr: `invalid tenant federation group regex "team-("`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var g Group
+			err := yaml.Unmarshal([]byte(testData.input), &g)
+			if testData.expectedErr != "" {
+				require.ErrorContains(t, err, testData.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			for tenantID, expected := range testData.expectedMatch {
+				assert.Equal(t, expected, g.tenantsRegex.MatchString(tenantID), tenantID)
+			}
+		})
+	}
+}
+
+func TestValidateGroups(t *testing.T) {
+	require.NoError(t, ValidateGroups(map[string]*Group{"group-1": {Tenants: []string{"team-a"}}}))
+	require.ErrorContains(t, ValidateGroups(map[string]*Group{"group-1|group-2": {Tenants: []string{"team-a"}}}), `invalid tenant federation group ID "group-1|group-2"`)
+}
+
+func TestGroupsResolver_TenantIDs(t *testing.T) {
+	bkt := objstore.NewInMemBucket()
+	for _, tenantID := range []string{"team-a", "team-b", "team-c", "other"} {
+		require.NoError(t, bkt.Upload(context.Background(), tenantID+"/01GC8K6Z1QJ2RZ4K8N6T5V0M5X/meta.json", bytes.NewReader([]byte("{}"))))
+	}
+
+	groups := map[string]*Group{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+explicit:
+  tenants: [team-b, team-a]
+regex:
+  tenants_regex: "team-.*"
+mixed:
+  tenants: [other, team-a]
+  tenants_regex: "team-(a|c)"
+empty:
+  tenants_regex: "unknown-.*"
+`), &groups))
+
+	r := NewGroupsResolver(Config{GroupsTenantsRefreshInterval: time.Minute}, func() map[string]*Group { return groups }, bkt, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), r))
+	})
+
+	tests := map[string]struct {
+		orgID           string
+		expectedTenants []string
+		expectedGroup   bool
+		expectedErr     string
+	}{
+		"tenant which is not a group": {
+			orgID:           "team-a",
+			expectedTenants: []string{"team-a"},
+		},
+		"multiple tenants are never resolved to groups": {
+			orgID:           "explicit|team-c",
+			expectedTenants: []string{"explicit", "team-c"},
+		},
+		"group with explicit members": {
+			orgID:           "explicit",
+			expectedTenants: []string{"team-a", "team-b"},
+			expectedGroup:   true,
+		},
+		"group with regex": {
+			orgID:           "regex",
+			expectedTenants: []string{"team-a", "team-b", "team-c"},
+			expectedGroup:   true,
+		},
+		"group with both explicit members and regex": {
+			orgID:           "mixed",
+			expectedTenants: []string{"other", "team-a", "team-c"},
+			expectedGroup:   true,
+		},
+		"group without members": {
+			orgID:       "empty",
+			expectedErr: "the tenant federation group empty has no members",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := user.InjectOrgID(context.Background(), testData.orgID)
+
+			tenantIDs, isGroup, err := r.resolve(ctx)
+			if testData.expectedErr != "" {
+				require.EqualError(t, err, testData.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedTenants, tenantIDs)
+			assert.Equal(t, testData.expectedGroup, isGroup)
+
+			tenantIDs, err = r.TenantIDs(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedTenants, tenantIDs)
+		})
+	}
+}
+
+func TestMergeQueryable_Groups(t *testing.T) {
+	groups := map[string]*Group{
+		"group": {Tenants: []string{"team-a", "team-b"}},
+	}
+	resolver := NewGroupsResolver(Config{GroupsTenantsRefreshInterval: time.Minute}, func() map[string]*Group { return groups }, nil, log.NewNopLogger())
+
+	upstream := &mockTenantQueryableWithFilter{
+		logger:           log.NewNopLogger(),
+		queryErrByTenant: map[string]error{"team-b": errors.New("limit exceeded")},
+	}
+	q, err := NewQueryable(upstream, resolver, true, log.NewNopLogger()).Querier(user.InjectOrgID(context.Background(), "group"), mint, maxt)
+	require.NoError(t, err)
+
+	t.Run("failures of a group member are returned as warnings", func(t *testing.T) {
+		seriesSet := q.Select(true, nil, labels.MustNewMatcher(labels.MatchEqual, "instance", "host1"))
+
+		var series []labels.Labels
+		for seriesSet.Next() {
+			series = append(series, seriesSet.At().Labels())
+		}
+		require.NoError(t, seriesSet.Err())
+		assert.Contains(t, series, labels.FromStrings(defaultTenantLabel, "team-a", "instance", "host1", "tenant-team-a", "static"))
+		assertWarnings(t, []string{`warning querying tenant_id team-b: limit exceeded`}, seriesSet.Warnings())
+	})
+
+	t.Run("failures of a group member are returned as warnings for label names", func(t *testing.T) {
+		names, warnings, err := q.LabelNames()
+		require.NoError(t, err)
+		assert.Equal(t, []string{defaultTenantLabel, "instance", "tenant-team-a"}, names)
+		assertWarnings(t, []string{`warning querying tenant_id team-b: limit exceeded`}, warnings)
+	})
+
+	t.Run("failures of an explicitly listed tenant are returned as errors", func(t *testing.T) {
+		q, err := NewQueryable(upstream, resolver, true, log.NewNopLogger()).Querier(user.InjectOrgID(context.Background(), "team-a|team-b"), mint, maxt)
+		require.NoError(t, err)
+
+		seriesSet := q.Select(true, nil)
+		for seriesSet.Next() {
+		}
+		require.EqualError(t, seriesSet.Err(), "error querying tenant_id team-b: limit exceeded")
+	})
+}
+
+func assertWarnings(t *testing.T, expected []string, actual storage.Warnings) {
+	t.Helper()
+
+	messages := make([]string, 0, len(actual))
+	for _, w := range actual {
+		messages = append(messages, w.Error())
+	}
+	assert.ElementsMatch(t, expected, messages)
+}
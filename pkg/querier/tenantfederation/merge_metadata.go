@@ -21,11 +21,14 @@ import (
 // metadata for all tenant IDs that are part of the request and merges the results.
 //
 // No deduplication of metadata is done before being returned.
-func NewMetadataSupplier(next querier.MetadataSupplier, logger log.Logger) querier.MetadataSupplier {
+//
+// If groups is not nil, a request for a tenant federation group is federated
+// across the group members.
+func NewMetadataSupplier(next querier.MetadataSupplier, groups *GroupsResolver, logger log.Logger) querier.MetadataSupplier {
 	return &mergeMetadataSupplier{
 		next:     next,
 		logger:   logger,
-		resolver: tenant.NewMultiResolver(),
+		resolver: newTenantResolver(groups),
 	}
 }
 
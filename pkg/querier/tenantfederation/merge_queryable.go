@@ -19,8 +19,6 @@ import (
 	tsdb_errors "github.com/prometheus/prometheus/tsdb/errors"
 	"github.com/weaveworks/common/user"
 
-	"github.com/grafana/dskit/tenant"
-
 	"github.com/grafana/mimir/pkg/util/spanlogger"
 )
 
@@ -36,27 +34,37 @@ import (
 // If the label "__tenant_id__" is already existing, its value is overwritten
 // by the tenant ID and the previous value is exposed through a new label
 // prefixed with "original_". This behaviour is not implemented recursively.
-func NewQueryable(upstream storage.Queryable, byPassWithSingleQuerier bool, logger log.Logger) storage.Queryable {
-	return NewMergeQueryable(defaultTenantLabel, tenantQuerierCallback(upstream), byPassWithSingleQuerier, logger)
+// If groups is not nil, a request for a tenant federation group is federated across
+// the group members, and the failures of each member are returned as warnings.
+func NewQueryable(upstream storage.Queryable, groups *GroupsResolver, byPassWithSingleQuerier bool, logger log.Logger) storage.Queryable {
+	return NewMergeQueryable(defaultTenantLabel, tenantQuerierCallback(upstream, groups), byPassWithSingleQuerier, logger)
 }
 
-func tenantQuerierCallback(queryable storage.Queryable) MergeQuerierCallback {
+func tenantQuerierCallback(queryable storage.Queryable, groups *GroupsResolver) MergeQuerierCallback {
 	return func(ctx context.Context, mint int64, maxt int64) ([]string, []storage.Querier, error) {
-		tenantIDs, err := tenant.TenantIDs(ctx)
+		tenantIDs, isGroup, err := groups.resolve(ctx)
 		if err != nil {
 			return nil, nil, err
 		}
 
 		var queriers = make([]storage.Querier, len(tenantIDs))
 		for pos, tenantID := range tenantIDs {
+			// Each querier runs with its own tenant ID, so that the tenant's own limits are enforced.
 			q, err := queryable.Querier(
 				user.InjectOrgID(ctx, tenantID),
 				mint,
 				maxt,
 			)
-			if err != nil {
+			if err != nil && !isGroup {
 				return nil, nil, err
 			}
+
+			if isGroup {
+				if err != nil {
+					q = errQuerier{err: err}
+				}
+				q = &failuresAsWarningsQuerier{Querier: q}
+			}
 			queriers[pos] = q
 		}
 
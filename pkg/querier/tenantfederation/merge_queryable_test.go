@@ -267,7 +267,7 @@ type mergeQueryableScenario struct {
 
 func (s *mergeQueryableScenario) init() (storage.Querier, error) {
 	// initialize with default tenant label
-	q := NewQueryable(&s.queryable, !s.doNotByPassSingleQuerier, log.NewNopLogger())
+	q := NewQueryable(&s.queryable, nil, !s.doNotByPassSingleQuerier, log.NewNopLogger())
 
 	// inject tenants into context
 	ctx := context.Background()
@@ -346,7 +346,7 @@ type labelValuesScenario struct {
 func TestMergeQueryable_Querier(t *testing.T) {
 	t.Run("querying without a tenant specified should error", func(t *testing.T) {
 		queryable := &mockTenantQueryableWithFilter{logger: log.NewNopLogger()}
-		q := NewQueryable(queryable, false /* bypassWithSingleQuerier */, log.NewNopLogger())
+		q := NewQueryable(queryable, nil, false /* bypassWithSingleQuerier */, log.NewNopLogger())
 		// Create a context with no tenant specified.
 		ctx := context.Background()
 
@@ -927,7 +927,7 @@ func TestTracingMergeQueryable(t *testing.T) {
 	// set a multi tenant resolver
 	tenant.WithDefaultResolver(tenant.NewMultiResolver())
 	filter := mockTenantQueryableWithFilter{}
-	q := NewQueryable(&filter, false, log.NewNopLogger())
+	q := NewQueryable(&filter, nil, false, log.NewNopLogger())
 	// retrieve querier if set
 	querier, err := q.Querier(ctx, mint, maxt)
 	require.NoError(t, err)
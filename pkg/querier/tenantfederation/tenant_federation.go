@@ -6,7 +6,9 @@
 package tenantfederation
 
 import (
+	"errors"
 	"flag"
+	"time"
 
 	"github.com/prometheus/prometheus/model/labels"
 )
@@ -17,13 +19,25 @@ const (
 	maxConcurrency       = 16
 )
 
+var errInvalidGroupsTenantsRefreshInterval = errors.New("the tenant federation groups tenants refresh interval must be greater than 0")
+
 type Config struct {
 	// Enabled switches on support for multi tenant query federation
 	Enabled bool `yaml:"enabled"`
+
+	GroupsTenantsRefreshInterval time.Duration `yaml:"groups_tenants_refresh_interval" category:"experimental"`
 }
 
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.Enabled, "tenant-federation.enabled", false, "If enabled on all services, queries can be federated across multiple tenants. The tenant IDs involved need to be specified separated by a '|' character in the 'X-Scope-OrgID' header.")
+	f.DurationVar(&cfg.GroupsTenantsRefreshInterval, "tenant-federation.groups-tenants-refresh-interval", 5*time.Minute, "How frequently the tenants in the storage are listed to resolve the members of the tenant federation groups defined by a regex.")
+}
+
+func (cfg *Config) Validate() error {
+	if cfg.GroupsTenantsRefreshInterval <= 0 {
+		return errInvalidGroupsTenantsRefreshInterval
+	}
+	return nil
 }
 
 // filterValuesByMatchers applies matchers to inputed `idLabelName` and
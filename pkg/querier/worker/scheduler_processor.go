@@ -37,6 +37,12 @@ import (
 	util_log "github.com/grafana/mimir/pkg/util/log"
 )
 
+const (
+	// progressReportInterval is how frequently the querier reports the progress of the
+	// request in progress to the scheduler, when enabled by the scheduler.
+	progressReportInterval = 5 * time.Second
+)
+
 func newSchedulerProcessor(cfg Config, handler RequestHandler, log log.Logger, reg prometheus.Registerer) (*schedulerProcessor, []services.Service) {
 	p := &schedulerProcessor{
 		log:            log,
@@ -45,6 +51,8 @@ func newSchedulerProcessor(cfg Config, handler RequestHandler, log log.Logger, r
 		querierID:      cfg.QuerierID,
 		grpcConfig:     cfg.GRPCClientConfig,
 
+		progressReportInterval: progressReportInterval,
+
 		schedulerClientFactory: func(conn *grpc.ClientConn) schedulerpb.SchedulerForQuerierClient {
 			return schedulerpb.NewSchedulerForQuerierClient(conn)
 		},
@@ -79,6 +87,9 @@ type schedulerProcessor struct {
 	maxMessageSize int
 	querierID      string
 
+	// How frequently the progress of the request in progress is reported to the scheduler.
+	progressReportInterval time.Duration
+
 	frontendPool                  *client.Pool
 	frontendClientRequestDuration *prometheus.HistogramVec
 
@@ -167,7 +178,26 @@ func (sp *schedulerProcessor) querierLoop(c schedulerpb.SchedulerForQuerier_Quer
 			}
 			logger := util_log.WithContext(ctx, sp.log)
 
-			sp.runRequest(ctx, logger, request.QueryID, request.FrontendAddress, request.StatsEnabled, request.HttpRequest)
+			// Query statistics are tracked to report the progress too, but they're sent
+			// to the frontend only if requested.
+			var stats *querier_stats.Stats
+			if request.StatsEnabled || request.ProgressReportingEnabled {
+				stats, ctx = querier_stats.ContextWithEmptyStats(ctx)
+			}
+
+			stopProgressReporting := func() {}
+			if request.ProgressReportingEnabled {
+				stopProgressReporting = sp.startProgressReporting(c, stats, logger, address)
+			}
+
+			var frontendStats *querier_stats.Stats
+			if request.StatsEnabled {
+				frontendStats = stats
+			}
+			sp.runRequest(ctx, logger, request.QueryID, request.FrontendAddress, frontendStats, request.QueueTime, request.HttpRequest)
+
+			// The stream doesn't support concurrent sends, so we stop reporting the progress first.
+			stopProgressReporting()
 
 			// Report back to scheduler that processing of the query has finished.
 			if err := c.Send(&schedulerpb.QuerierToScheduler{}); err != nil {
@@ -177,11 +207,41 @@ func (sp *schedulerProcessor) querierLoop(c schedulerpb.SchedulerForQuerier_Quer
 	}
 }
 
-func (sp *schedulerProcessor) runRequest(ctx context.Context, logger log.Logger, queryID uint64, frontendAddress string, statsEnabled bool, request *httpgrpc.HTTPRequest) {
-	var stats *querier_stats.Stats
-	if statsEnabled {
-		stats, ctx = querier_stats.ContextWithEmptyStats(ctx)
+// startProgressReporting periodically reports the number of series fetched so far by the
+// request in progress to the scheduler, until the returned function is called.
+func (sp *schedulerProcessor) startProgressReporting(c schedulerpb.SchedulerForQuerier_QuerierLoopClient, stats *querier_stats.Stats, logger log.Logger, address string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(sp.progressReportInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := c.Send(&schedulerpb.QuerierToScheduler{InProgress: true, FetchedSeriesCount: stats.LoadFetchedSeries()}); err != nil {
+					level.Warn(logger).Log("msg", "error reporting query progress to scheduler", "err", err, "addr", address)
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
 	}
+}
+
+// runRequest executes the request and sends the response to the frontend. The stats, if not nil,
+// must be tracked in the input context and are sent to the frontend along with the response.
+func (sp *schedulerProcessor) runRequest(ctx context.Context, logger log.Logger, queryID uint64, frontendAddress string, stats *querier_stats.Stats, queueTime time.Duration, request *httpgrpc.HTTPRequest) {
+	stats.AddQueueTime(queueTime)
 
 	response, err := sp.handler.Handle(ctx, request)
 	if err != nil {
@@ -19,6 +19,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/scheduler/schedulerpb"
 )
 
@@ -96,6 +97,58 @@ func TestSchedulerProcessor_processQueriesOnSingleStream(t *testing.T) {
 		loopClient.AssertCalled(t, "Send", &schedulerpb.QuerierToScheduler{QuerierID: "test-querier-id"})
 	})
 
+	t.Run("should report the progress of the inflight query if enabled by the query-scheduler", func(t *testing.T) {
+		sp, loopClient, requestHandler := prepareSchedulerProcessor()
+		sp.progressReportInterval = 10 * time.Millisecond
+
+		recvCount := atomic.NewInt64(0)
+
+		loopClient.On("Recv").Return(func() (*schedulerpb.SchedulerToQuerier, error) {
+			switch recvCount.Inc() {
+			case 1:
+				return &schedulerpb.SchedulerToQuerier{
+					QueryID:                  1,
+					HttpRequest:              nil,
+					FrontendAddress:          "127.0.0.2",
+					UserID:                   "user-1",
+					ProgressReportingEnabled: true,
+				}, nil
+			default:
+				// No more messages to process, so waiting until terminated.
+				<-loopClient.Context().Done()
+				return nil, loopClient.Context().Err()
+			}
+		})
+
+		workerCtx, workerCancel := context.WithCancel(context.Background())
+
+		requestHandler.On("Handle", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			// The query statistics are tracked even if not requested by the query-frontend.
+			querier_stats.FromContext(args.Get(0).(context.Context)).AddFetchedSeries(5)
+
+			// Slow down the query execution, to give the time to report the progress few times.
+			time.Sleep(200 * time.Millisecond)
+
+			workerCancel()
+		}).Return(&httpgrpc.HTTPResponse{}, nil)
+
+		sp.processQueriesOnSingleStream(workerCtx, nil, "127.0.0.1")
+
+		loopClient.AssertCalled(t, "Send", &schedulerpb.QuerierToScheduler{InProgress: true, FetchedSeriesCount: 5})
+
+		// The last message sent to the scheduler signals the query execution has completed.
+		sendCalls := 0
+		var lastSent *schedulerpb.QuerierToScheduler
+		for _, call := range loopClient.Calls {
+			if call.Method == "Send" {
+				sendCalls++
+				lastSent = call.Arguments.Get(0).(*schedulerpb.QuerierToScheduler)
+			}
+		}
+		require.Greater(t, sendCalls, 2)
+		assert.Equal(t, &schedulerpb.QuerierToScheduler{}, lastSent)
+	})
+
 	t.Run("should not log an error when the query-scheduler is terminates while waiting for the next query to run", func(t *testing.T) {
 		sp, loopClient, requestHandler := prepareSchedulerProcessor()
 
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package scheduler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/httpgrpcutil"
+)
+
+// Query priority classes. These must be kept in sync with the priority classes allowed by the limits.
+const (
+	priorityClassRuler     = "ruler"
+	priorityClassInstant   = "instant"
+	priorityClassRange     = "range"
+	priorityClassLongRange = "long_range"
+	priorityClassOther     = "other"
+)
+
+var priorityClasses = []string{priorityClassRuler, priorityClassInstant, priorityClassRange, priorityClassLongRange, priorityClassOther}
+
+// classifyRequest returns the priority class of the input request, based on the component which issued it,
+// the query type and, for range queries, the estimated duration which is approximated by the queried time range.
+func classifyRequest(req *httpgrpc.HTTPRequest, longRangeQueryThreshold time.Duration) string {
+	if httpgrpcutil.GetHeader(req, httpgrpcutil.QuerySourceHeader) == httpgrpcutil.QuerySourceRuler {
+		return priorityClassRuler
+	}
+
+	u, err := url.Parse(req.GetUrl())
+	if err != nil {
+		return priorityClassOther
+	}
+
+	switch {
+	case strings.HasSuffix(u.Path, "/api/v1/query"):
+		return priorityClassInstant
+
+	case strings.HasSuffix(u.Path, "/api/v1/query_range"):
+		if longRangeQueryThreshold <= 0 {
+			return priorityClassRange
+		}
+
+		params := requestParams(req, u)
+		start, startErr := util.ParseTime(params.Get("start"))
+		end, endErr := util.ParseTime(params.Get("end"))
+		if startErr == nil && endErr == nil && end-start >= longRangeQueryThreshold.Milliseconds() {
+			return priorityClassLongRange
+		}
+		return priorityClassRange
+
+	default:
+		return priorityClassOther
+	}
+}
+
+// requestParams returns the URL query parameters of the request, merged with the form-encoded body parameters.
+func requestParams(req *httpgrpc.HTTPRequest, u *url.URL) url.Values {
+	params := u.Query()
+
+	if req.GetMethod() != http.MethodPost || !strings.HasPrefix(httpgrpcutil.GetHeader(req, "Content-Type"), "application/x-www-form-urlencoded") {
+		return params
+	}
+
+	body, err := url.ParseQuery(string(req.GetBody()))
+	if err != nil {
+		return params
+	}
+
+	for name, values := range body {
+		params[name] = append(params[name], values...)
+	}
+	return params
+}
+
+// queryPriorityClassWeights returns the weights of the query priority classes for the input tenants.
+// In case of a multi-tenant query, the smallest weight of each class across tenants is used.
+// Returns an empty map if none of the tenants has query priority classes enabled.
+func queryPriorityClassWeights(tenantIDs []string, limits Limits) map[string]int {
+	if len(tenantIDs) == 1 {
+		return limits.QueryPriorityClassWeights(tenantIDs[0])
+	}
+
+	result := map[string]int{}
+	for _, tenantID := range tenantIDs {
+		for class, weight := range limits.QueryPriorityClassWeights(tenantID) {
+			if current, ok := result[class]; !ok || weight < current {
+				result[class] = weight
+			}
+		}
+	}
+	return result
+}
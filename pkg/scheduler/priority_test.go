@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/grafana/mimir/pkg/util/httpgrpcutil"
+)
+
+func TestClassifyRequest(t *testing.T) {
+	tests := map[string]struct {
+		req           *httpgrpc.HTTPRequest
+		threshold     time.Duration
+		expectedClass string
+	}{
+		"query issued by the ruler": {
+			req: &httpgrpc.HTTPRequest{Method: "POST", Url: "/prometheus/api/v1/query", Headers: []*httpgrpc.Header{
+				{Key: "x-mimir-query-source", Values: []string{httpgrpcutil.QuerySourceRuler}},
+			}},
+			expectedClass: priorityClassRuler,
+		},
+		"instant query": {
+			req:           &httpgrpc.HTTPRequest{Method: "GET", Url: "/prometheus/api/v1/query?query=up&time=3600"},
+			expectedClass: priorityClassInstant,
+		},
+		"range query shorter than the threshold": {
+			req:           &httpgrpc.HTTPRequest{Method: "GET", Url: "/prometheus/api/v1/query_range?query=up&start=0&end=3600&step=60"},
+			threshold:     12 * time.Hour,
+			expectedClass: priorityClassRange,
+		},
+		"range query longer than the threshold": {
+			req:           &httpgrpc.HTTPRequest{Method: "GET", Url: "/prometheus/api/v1/query_range?query=up&start=1970-01-01T00:00:00Z&end=1970-01-02T00:00:00Z&step=60"},
+			threshold:     12 * time.Hour,
+			expectedClass: priorityClassLongRange,
+		},
+		"range query longer than the threshold with form-encoded body": {
+			req: &httpgrpc.HTTPRequest{
+				Method:  "POST",
+				Url:     "/prometheus/api/v1/query_range",
+				Body:    []byte("query=up&start=0&end=86400&step=60"),
+				Headers: []*httpgrpc.Header{{Key: "Content-Type", Values: []string{"application/x-www-form-urlencoded"}}},
+			},
+			threshold:     12 * time.Hour,
+			expectedClass: priorityClassLongRange,
+		},
+		"range query longer than the threshold but long range class disabled": {
+			req:           &httpgrpc.HTTPRequest{Method: "GET", Url: "/prometheus/api/v1/query_range?query=up&start=0&end=86400&step=60"},
+			threshold:     0,
+			expectedClass: priorityClassRange,
+		},
+		"range query with invalid time range": {
+			req:           &httpgrpc.HTTPRequest{Method: "GET", Url: "/prometheus/api/v1/query_range?query=up&start=foo&end=86400&step=60"},
+			threshold:     12 * time.Hour,
+			expectedClass: priorityClassRange,
+		},
+		"other requests": {
+			req:           &httpgrpc.HTTPRequest{Method: "GET", Url: "/prometheus/api/v1/series?match[]=up"},
+			expectedClass: priorityClassOther,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expectedClass, classifyRequest(testData.req, testData.threshold))
+		})
+	}
+}
+
+func TestQueryPriorityClassWeights(t *testing.T) {
+	overrides := tenantLimits{
+		"tenant-a": {priorityClassRuler: 4, priorityClassRange: 2},
+		"tenant-b": {priorityClassRuler: 2, priorityClassInstant: 3},
+	}
+
+	assert.Equal(t, map[string]int{priorityClassRuler: 4, priorityClassRange: 2}, queryPriorityClassWeights([]string{"tenant-a"}, overrides))
+	assert.Equal(t, map[string]int{priorityClassRuler: 2, priorityClassRange: 2, priorityClassInstant: 3}, queryPriorityClassWeights([]string{"tenant-a", "tenant-b"}, overrides))
+	assert.Empty(t, queryPriorityClassWeights([]string{"tenant-c"}, overrides))
+	assert.Empty(t, queryPriorityClassWeights([]string{"tenant-c", "tenant-d"}, overrides))
+}
+
+type tenantLimits map[string]map[string]int
+
+func (l tenantLimits) MaxQueriersPerUser(_ string) int {
+	return 0
+}
+
+func (l tenantLimits) QueryPriorityClassWeights(user string) map[string]int {
+	return l[user]
+}
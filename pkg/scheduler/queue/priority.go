@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package queue
+
+import (
+	"sort"
+)
+
+const (
+	// DefaultPriorityClass is the priority class of requests enqueued without an explicit class.
+	DefaultPriorityClass = "default"
+
+	// defaultPriorityClassWeight is the weight of priority classes with no configured weight.
+	defaultPriorityClassWeight = 1
+)
+
+// classQueue holds the pending requests of a single priority class for a user.
+type classQueue struct {
+	requests []Request
+
+	// Weight of this class relative to other classes of the same user.
+	weight int
+
+	// Current weight used by the smooth weighted round-robin selection.
+	currentWeight int
+}
+
+// priorityQueue holds the pending requests of a single user, grouped by priority class.
+// Requests of the same class are dequeued in FIFO order, while classes are picked using
+// smooth weighted round-robin, so that each class gets a share of the dequeued requests
+// proportional to its weight and no class is starved.
+type priorityQueue struct {
+	classes map[string]*classQueue
+
+	// Sorted names of the classes with pending requests, used to iterate classes in a deterministic order.
+	classNames []string
+
+	// Total number of pending requests across all classes.
+	length int
+}
+
+func newPriorityQueue() *priorityQueue {
+	return &priorityQueue{
+		classes: map[string]*classQueue{},
+	}
+}
+
+// len returns the total number of pending requests across all classes.
+func (q *priorityQueue) len() int {
+	return q.length
+}
+
+// enqueue appends the request to the queue of the input class. The weight of the class is
+// updated to the input one, because it may change between calls.
+func (q *priorityQueue) enqueue(class string, weight int, req Request) {
+	if weight <= 0 {
+		weight = defaultPriorityClassWeight
+	}
+
+	cq := q.classes[class]
+	if cq == nil {
+		cq = &classQueue{}
+		q.classes[class] = cq
+
+		ix := sort.SearchStrings(q.classNames, class)
+		q.classNames = append(q.classNames, "")
+		copy(q.classNames[ix+1:], q.classNames[ix:])
+		q.classNames[ix] = class
+	}
+
+	cq.weight = weight
+	cq.requests = append(cq.requests, req)
+	q.length++
+}
+
+// dequeue removes and returns the next request and its class. Returns nil if the queue is empty.
+func (q *priorityQueue) dequeue() (Request, string) {
+	if q.length == 0 {
+		return nil, ""
+	}
+
+	var (
+		selected      *classQueue
+		selectedClass string
+		totalWeight   int
+	)
+
+	for _, class := range q.classNames {
+		cq := q.classes[class]
+		cq.currentWeight += cq.weight
+		totalWeight += cq.weight
+
+		if selected == nil || cq.currentWeight > selected.currentWeight {
+			selected = cq
+			selectedClass = class
+		}
+	}
+
+	selected.currentWeight -= totalWeight
+
+	req := selected.requests[0]
+	selected.requests[0] = nil
+	selected.requests = selected.requests[1:]
+	q.length--
+
+	if len(selected.requests) == 0 {
+		q.deleteClass(selectedClass)
+	}
+
+	return req, selectedClass
+}
+
+func (q *priorityQueue) deleteClass(class string) {
+	delete(q.classes, class)
+
+	ix := sort.SearchStrings(q.classNames, class)
+	if ix < len(q.classNames) && q.classNames[ix] == class {
+		q.classNames = append(q.classNames[:ix], q.classNames[ix+1:]...)
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package queue
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	tests := map[string]struct {
+		enqueue  func(q *priorityQueue)
+		expected []string
+	}{
+		"empty queue": {
+			enqueue:  func(q *priorityQueue) {},
+			expected: nil,
+		},
+		"single class should be dequeued in FIFO order": {
+			enqueue: func(q *priorityQueue) {
+				for i := 0; i < 3; i++ {
+					q.enqueue("a", 1, fmt.Sprintf("a-%d", i))
+				}
+			},
+			expected: []string{"a-0", "a-1", "a-2"},
+		},
+		"classes with the same weight should be dequeued in round-robin": {
+			enqueue: func(q *priorityQueue) {
+				for i := 0; i < 3; i++ {
+					q.enqueue("b", 1, fmt.Sprintf("b-%d", i))
+					q.enqueue("a", 1, fmt.Sprintf("a-%d", i))
+				}
+			},
+			expected: []string{"a-0", "b-0", "a-1", "b-1", "a-2", "b-2"},
+		},
+		"classes should be dequeued proportionally to their weight": {
+			enqueue: func(q *priorityQueue) {
+				for i := 0; i < 4; i++ {
+					q.enqueue("a", 3, fmt.Sprintf("a-%d", i))
+				}
+				for i := 0; i < 2; i++ {
+					q.enqueue("b", 1, fmt.Sprintf("b-%d", i))
+				}
+			},
+			expected: []string{"a-0", "a-1", "b-0", "a-2", "a-3", "b-1"},
+		},
+		"class with a low weight should not be starved": {
+			enqueue: func(q *priorityQueue) {
+				for i := 0; i < 20; i++ {
+					q.enqueue("a", 10, fmt.Sprintf("a-%d", i))
+				}
+				q.enqueue("b", 1, "b-0")
+			},
+			expected: []string{"a-0", "a-1", "a-2", "a-3", "a-4", "b-0", "a-5", "a-6", "a-7", "a-8", "a-9", "a-10", "a-11", "a-12", "a-13", "a-14", "a-15", "a-16", "a-17", "a-18", "a-19"},
+		},
+		"zero or negative weight should be treated as the default weight": {
+			enqueue: func(q *priorityQueue) {
+				for i := 0; i < 2; i++ {
+					q.enqueue("a", 0, fmt.Sprintf("a-%d", i))
+					q.enqueue("b", -1, fmt.Sprintf("b-%d", i))
+				}
+			},
+			expected: []string{"a-0", "b-0", "a-1", "b-1"},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			q := newPriorityQueue()
+			testData.enqueue(q)
+			require.Equal(t, len(testData.expected), q.len())
+
+			var actual []string
+			for q.len() > 0 {
+				req, class := q.dequeue()
+				require.NotNil(t, req)
+				assert.Equal(t, class, req.(string)[:1])
+				actual = append(actual, req.(string))
+			}
+
+			assert.Equal(t, testData.expected, actual)
+
+			// Once drained, the queue should not keep track of any class.
+			req, _ := q.dequeue()
+			assert.Nil(t, req)
+			assert.Empty(t, q.classes)
+			assert.Empty(t, q.classNames)
+		})
+	}
+}
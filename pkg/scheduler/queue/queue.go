@@ -85,6 +85,14 @@ func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, que
 //
 // If request is successfully enqueued, successFn is called with the lock held, before any querier can receive the request.
 func (q *RequestQueue) EnqueueRequest(userID string, req Request, maxQueriers int, successFn func()) error {
+	return q.EnqueueRequestWithPriority(userID, DefaultPriorityClass, 0, req, maxQueriers, successFn)
+}
+
+// EnqueueRequestWithPriority is like EnqueueRequest, but puts the request into the queue of the given priority class.
+// Requests of the same user and class are dequeued in FIFO order, while the classes of a user are dequeued with
+// weighted fair queuing: each class gets a share of the user's dequeued requests proportional to its weight.
+// ClassWeight is passed to each call, because it can change between calls (zero or negative = default weight of 1).
+func (q *RequestQueue) EnqueueRequestWithPriority(userID, class string, classWeight int, req Request, maxQueriers int, successFn func()) error {
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
 
@@ -98,19 +106,24 @@ func (q *RequestQueue) EnqueueRequest(userID string, req Request, maxQueriers in
 		return errors.New("no queue found")
 	}
 
-	select {
-	case queue <- req:
-		q.queueLength.WithLabelValues(userID).Inc()
-		q.cond.Broadcast()
-		// Call this function while holding a lock. This guarantees that no querier can fetch the request before function returns.
-		if successFn != nil {
-			successFn()
+	if queue.requests.len() >= q.queues.maxUserQueueSize {
+		if queue.requests.len() == 0 {
+			// Do not leave an empty queue behind, because queriers expect queues to have pending requests.
+			q.queues.deleteQueue(userID)
 		}
-		return nil
-	default:
+
 		q.discardedRequests.WithLabelValues(userID).Inc()
 		return ErrTooManyRequests
 	}
+
+	queue.requests.enqueue(class, classWeight, req)
+	q.queueLength.WithLabelValues(userID).Inc()
+	q.cond.Broadcast()
+	// Call this function while holding a lock. This guarantees that no querier can fetch the request before function returns.
+	if successFn != nil {
+		successFn()
+	}
+	return nil
 }
 
 // GetNextRequestForQuerier find next user queue and takes the next request off of it. Will block if there are no requests.
@@ -145,19 +158,20 @@ FindQueue:
 		}
 
 		// Pick next request from the queue.
-		for {
-			request := <-queue
-			if len(queue) == 0 {
-				q.queues.deleteQueue(userID)
-			}
+		request, _ := queue.requests.dequeue()
+		if queue.requests.len() == 0 {
+			q.queues.deleteQueue(userID)
+		}
+		if request == nil {
+			continue
+		}
 
-			q.queueLength.WithLabelValues(userID).Dec()
+		q.queueLength.WithLabelValues(userID).Dec()
 
-			// Tell close() we've processed a request.
-			q.cond.Broadcast()
+		// Tell close() we've processed a request.
+		q.cond.Broadcast()
 
-			return request, last, nil
-		}
+		return request, last, nil
 	}
 
 	// There are no unexpired requests, so we can get back
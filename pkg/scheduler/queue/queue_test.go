@@ -157,6 +157,43 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBe
 	assert.GreaterOrEqual(t, waitTime.Milliseconds(), forgetDelay.Milliseconds())
 }
 
+func TestRequestQueue_EnqueueRequestWithPriority(t *testing.T) {
+	const maxOutstandingPerTenant = 6
+
+	queue := NewRequestQueue(maxOutstandingPerTenant, 0,
+		promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
+		promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}))
+
+	ctx := context.Background()
+	require.NoError(t, services.StartAndAwaitRunning(ctx, queue))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(ctx, queue))
+	})
+
+	queue.RegisterQuerierConnection("querier-1")
+
+	// The range class has twice the weight of the instant class.
+	for i := 0; i < 3; i++ {
+		require.NoError(t, queue.EnqueueRequestWithPriority("user-1", "range", 2, fmt.Sprintf("range-%d", i), 0, nil))
+		require.NoError(t, queue.EnqueueRequestWithPriority("user-1", "instant", 1, fmt.Sprintf("instant-%d", i), 0, nil))
+	}
+
+	// The max outstanding requests limit applies to all classes of the tenant.
+	require.Equal(t, ErrTooManyRequests, queue.EnqueueRequestWithPriority("user-1", "other", 1, "other-0", 0, nil))
+
+	var actual []Request
+	idx := FirstUser()
+	for i := 0; i < maxOutstandingPerTenant; i++ {
+		req, nextIdx, err := queue.GetNextRequestForQuerier(ctx, idx, "querier-1")
+		require.NoError(t, err)
+		actual = append(actual, req)
+		idx = nextIdx
+	}
+
+	assert.Equal(t, []Request{"range-0", "instant-0", "range-1", "range-2", "instant-1", "instant-2"}, actual)
+	assert.Equal(t, 0, queue.queues.len())
+}
+
 func TestContextCond(t *testing.T) {
 	t.Run("wait until broadcast", func(t *testing.T) {
 		t.Parallel()
@@ -26,6 +26,7 @@ import (
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/middleware"
 	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
 	"google.golang.org/grpc"
 
 	"github.com/grafana/dskit/tenant"
@@ -39,6 +40,14 @@ import (
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
+const (
+	// statusClientClosedRequest is the status code returned to the frontend for cancelled requests.
+	// It's not a 5xx status code, so that the frontend doesn't retry the request.
+	statusClientClosedRequest = 499
+)
+
+var errQueryCancelled = errors.New("query cancelled by an operator")
+
 // Scheduler is responsible for queueing and dispatching queries to Queriers.
 type Scheduler struct {
 	services.Service
@@ -72,6 +81,10 @@ type Scheduler struct {
 	connectedFrontendClients prometheus.GaugeFunc
 	queueDuration            prometheus.Histogram
 	inflightRequests         prometheus.Summary
+
+	// Per priority class metrics.
+	priorityClassQueueLength   *prometheus.GaugeVec
+	priorityClassQueueDuration *prometheus.HistogramVec
 }
 
 type requestKey struct {
@@ -91,6 +104,7 @@ type connectedFrontend struct {
 type Config struct {
 	MaxOutstandingPerTenant int                       `yaml:"max_outstanding_requests_per_tenant"`
 	QuerierForgetDelay      time.Duration             `yaml:"querier_forget_delay" category:"experimental"`
+	LongRangeQueryThreshold time.Duration             `yaml:"long_range_query_threshold" category:"experimental"`
 	GRPCClientConfig        grpcclient.Config         `yaml:"grpc_client_config" doc:"description=This configures the gRPC client used to report errors back to the query-frontend."`
 	ServiceDiscovery        schedulerdiscovery.Config `yaml:",inline"`
 }
@@ -98,6 +112,7 @@ type Config struct {
 func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	f.IntVar(&cfg.MaxOutstandingPerTenant, "query-scheduler.max-outstanding-requests-per-tenant", 100, "Maximum number of outstanding requests per tenant per query-scheduler. In-flight requests above this limit will fail with HTTP response status code 429.")
 	f.DurationVar(&cfg.QuerierForgetDelay, "query-scheduler.querier-forget-delay", 0, "If a querier disconnects without sending notification about graceful shutdown, the query-scheduler will keep the querier in the tenant's shard until the forget delay has passed. This feature is useful to reduce the blast radius when shuffle-sharding is enabled.")
+	f.DurationVar(&cfg.LongRangeQueryThreshold, "query-scheduler.long-range-query-threshold", 12*time.Hour, "Range queries whose time range is greater than or equal to this threshold are classified in the long_range query priority class, otherwise in the range class. 0 to disable the long_range class.")
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("query-scheduler.grpc-client-config", f)
 	cfg.ServiceDiscovery.RegisterFlags(f, logger)
 }
@@ -136,6 +151,22 @@ func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer promethe
 		Help:    "Time spend by requests in queue before getting picked up by a querier.",
 		Buckets: prometheus.DefBuckets,
 	})
+	s.priorityClassQueueLength = promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cortex_query_scheduler_priority_class_queue_length",
+		Help: "Number of queries in the queue per query priority class.",
+	}, []string{"class"})
+	s.priorityClassQueueDuration = promauto.With(registerer).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cortex_query_scheduler_priority_class_queue_duration_seconds",
+		Help:    "Time spend by requests in queue before getting picked up by a querier, per query priority class.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"class"})
+
+	// Initialize known label values.
+	for _, class := range priorityClasses {
+		s.priorityClassQueueLength.WithLabelValues(class)
+		s.priorityClassQueueDuration.WithLabelValues(class)
+	}
+
 	s.connectedQuerierClients = promauto.With(registerer).NewGaugeFunc(prometheus.GaugeOpts{
 		Name: "cortex_query_scheduler_connected_querier_clients",
 		Help: "Number of querier worker clients currently connected to the query-scheduler.",
@@ -179,6 +210,10 @@ func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer promethe
 type Limits interface {
 	// MaxQueriersPerUser returns max queriers to use per tenant, or 0 if shuffle sharding is disabled.
 	MaxQueriersPerUser(user string) int
+
+	// QueryPriorityClassWeights returns the weights of the query priority classes per tenant,
+	// or an empty map if query priority classes are disabled.
+	QueryPriorityClassWeights(user string) map[string]int
 }
 
 type schedulerRequest struct {
@@ -188,8 +223,19 @@ type schedulerRequest struct {
 	request         *httpgrpc.HTTPRequest
 	statsEnabled    bool
 
+	// Query priority class of the request.
+	priorityClass string
+
 	enqueueTime time.Time
 
+	// The querier the request has been dispatched to and when, both guarded by
+	// Scheduler.pendingRequestsMu. They're empty while the request is queued.
+	querierID    string
+	dispatchTime time.Time
+
+	// Number of series fetched so far, as reported by the querier.
+	fetchedSeriesCount atomic.Uint64
+
 	ctx       context.Context
 	ctxCancel context.CancelFunc
 	queueSpan opentracing.Span
@@ -326,6 +372,7 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 		queryID:         msg.QueryID,
 		request:         msg.HttpRequest,
 		statsEnabled:    msg.StatsEnabled,
+		priorityClass:   classifyRequest(msg.HttpRequest, s.cfg.LongRangeQueryThreshold),
 	}
 
 	now := time.Now()
@@ -342,9 +389,17 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 	}
 	maxQueriers := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, s.limits.MaxQueriersPerUser)
 
+	// Requests are enqueued in their priority class only if the tenant has priority classes enabled,
+	// otherwise they're all enqueued in the same class and dequeued in FIFO order.
+	queueClass, queueClassWeight := queue.DefaultPriorityClass, 0
+	if weights := queryPriorityClassWeights(tenantIDs, s.limits); len(weights) > 0 {
+		queueClass, queueClassWeight = req.priorityClass, weights[req.priorityClass]
+	}
+
 	s.activeUsers.UpdateUserTimestamp(userID, now)
-	return s.requestQueue.EnqueueRequest(userID, req, maxQueriers, func() {
+	return s.requestQueue.EnqueueRequestWithPriority(userID, queueClass, queueClassWeight, req, maxQueriers, func() {
 		shouldCancel = false
+		s.priorityClassQueueLength.WithLabelValues(req.priorityClass).Inc()
 
 		s.pendingRequestsMu.Lock()
 		s.pendingRequests[requestKey{frontendAddr: frontendAddr, queryID: msg.QueryID}] = req
@@ -395,7 +450,10 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 
 		r := req.(*schedulerRequest)
 
-		s.queueDuration.Observe(time.Since(r.enqueueTime).Seconds())
+		queueDuration := time.Since(r.enqueueTime)
+		s.queueDuration.Observe(queueDuration.Seconds())
+		s.priorityClassQueueLength.WithLabelValues(r.priorityClass).Dec()
+		s.priorityClassQueueDuration.WithLabelValues(r.priorityClass).Observe(queueDuration.Seconds())
 		r.queueSpan.Finish()
 
 		/*
@@ -418,7 +476,7 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 			continue
 		}
 
-		if err := s.forwardRequestToQuerier(querier, r); err != nil {
+		if err := s.forwardRequestToQuerier(querier, querierID, r, queueDuration); err != nil {
 			return err
 		}
 	}
@@ -433,10 +491,15 @@ func (s *Scheduler) NotifyQuerierShutdown(_ context.Context, req *schedulerpb.No
 	return &schedulerpb.NotifyQuerierShutdownResponse{}, nil
 }
 
-func (s *Scheduler) forwardRequestToQuerier(querier schedulerpb.SchedulerForQuerier_QuerierLoopServer, req *schedulerRequest) error {
+func (s *Scheduler) forwardRequestToQuerier(querier schedulerpb.SchedulerForQuerier_QuerierLoopServer, querierID string, req *schedulerRequest, queueDuration time.Duration) error {
 	// Make sure to cancel request at the end to cleanup resources.
 	defer s.cancelRequestAndRemoveFromPending(req.frontendAddress, req.queryID)
 
+	s.pendingRequestsMu.Lock()
+	req.querierID = querierID
+	req.dispatchTime = time.Now()
+	s.pendingRequestsMu.Unlock()
+
 	// Handle the stream sending & receiving on a goroutine so we can
 	// monitoring the contexts in a select and cancel things appropriately.
 	errCh := make(chan error, 1)
@@ -447,14 +510,26 @@ func (s *Scheduler) forwardRequestToQuerier(querier schedulerpb.SchedulerForQuer
 			FrontendAddress: req.frontendAddress,
 			HttpRequest:     req.request,
 			StatsEnabled:    req.statsEnabled,
+			QueueTime:       queueDuration,
+
+			ProgressReportingEnabled: true,
 		})
 		if err != nil {
 			errCh <- err
 			return
 		}
 
-		_, err = querier.Recv()
-		errCh <- err
+		// The querier may report the progress of the request any number of times
+		// before signaling it's ready to accept another one.
+		for {
+			msg, err := querier.Recv()
+			if err != nil || !msg.GetInProgress() {
+				errCh <- err
+				return
+			}
+
+			req.fetchedSeriesCount.Store(msg.GetFetchedSeriesCount())
+		}
 	}()
 
 	select {
@@ -469,13 +544,67 @@ func (s *Scheduler) forwardRequestToQuerier(querier schedulerpb.SchedulerForQuer
 		// then error out this upstream request _and_ stream.
 
 		if err != nil {
-			s.forwardErrorToFrontend(req.ctx, req, err)
+			s.forwardErrorToFrontend(req.ctx, req, http.StatusInternalServerError, err)
 		}
 		return err
 	}
 }
 
-func (s *Scheduler) forwardErrorToFrontend(ctx context.Context, req *schedulerRequest, requestErr error) {
+// GetActiveQueries implements schedulerpb.SchedulerForFrontendServer.
+func (s *Scheduler) GetActiveQueries(_ context.Context, _ *schedulerpb.ActiveQueriesRequest) (*schedulerpb.ActiveQueriesResponse, error) {
+	s.pendingRequestsMu.Lock()
+	defer s.pendingRequestsMu.Unlock()
+
+	resp := &schedulerpb.ActiveQueriesResponse{Queries: make([]*schedulerpb.ActiveQuery, 0, len(s.pendingRequests))}
+	for _, req := range s.pendingRequests {
+		path, query := httpgrpcutil.GetPathAndQuery(req.request)
+
+		q := &schedulerpb.ActiveQuery{
+			QueryID:            req.queryID,
+			FrontendAddress:    req.frontendAddress,
+			UserID:             req.userID,
+			Path:               path,
+			Query:              query,
+			EnqueueTimestampMs: req.enqueueTime.UnixMilli(),
+			QuerierID:          req.querierID,
+			FetchedSeriesCount: req.fetchedSeriesCount.Load(),
+		}
+		if !req.dispatchTime.IsZero() {
+			q.DispatchTimestampMs = req.dispatchTime.UnixMilli()
+		}
+
+		resp.Queries = append(resp.Queries, q)
+	}
+
+	return resp, nil
+}
+
+// CancelActiveQuery implements schedulerpb.SchedulerForFrontendServer.
+func (s *Scheduler) CancelActiveQuery(ctx context.Context, msg *schedulerpb.CancelActiveQueryRequest) (*schedulerpb.CancelActiveQueryResponse, error) {
+	s.pendingRequestsMu.Lock()
+	req := s.pendingRequests[requestKey{frontendAddr: msg.GetFrontendAddress(), queryID: msg.GetQueryID()}]
+	var querierID string
+	if req != nil {
+		querierID = req.querierID
+	}
+	s.pendingRequestsMu.Unlock()
+
+	if req == nil {
+		return &schedulerpb.CancelActiveQueryResponse{Found: false}, nil
+	}
+
+	level.Info(s.log).Log("msg", "cancelling active query", "frontend", req.frontendAddress, "queryID", req.queryID, "user", req.userID, "querier", querierID)
+
+	// Cancelling the request closes the stream with the querier executing it (if any), which in turn
+	// cancels the query execution in the querier. The querier will not be able to send the response
+	// to the frontend anymore, so we notify the frontend ourselves.
+	s.cancelRequestAndRemoveFromPending(req.frontendAddress, req.queryID)
+	s.forwardErrorToFrontend(ctx, req, statusClientClosedRequest, errQueryCancelled)
+
+	return &schedulerpb.CancelActiveQueryResponse{Found: true}, nil
+}
+
+func (s *Scheduler) forwardErrorToFrontend(ctx context.Context, req *schedulerRequest, statusCode int32, requestErr error) {
 	opts, err := s.cfg.GRPCClientConfig.DialOption([]grpc.UnaryClientInterceptor{
 		otgrpc.OpenTracingClientInterceptor(opentracing.GlobalTracer()),
 		middleware.ClientUserHeaderInterceptor},
@@ -501,7 +630,7 @@ func (s *Scheduler) forwardErrorToFrontend(ctx context.Context, req *schedulerRe
 	_, err = client.QueryResult(userCtx, &frontendv2pb.QueryResultRequest{
 		QueryID: req.queryID,
 		HttpResponse: &httpgrpc.HTTPResponse{
-			Code: http.StatusInternalServerError,
+			Code: statusCode,
 			Body: []byte(requestErr.Error()),
 		},
 	})
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -36,11 +37,15 @@ import (
 const testMaxOutstandingPerTenant = 5
 
 func setupScheduler(t *testing.T, reg prometheus.Registerer) (*Scheduler, schedulerpb.SchedulerForFrontendClient, schedulerpb.SchedulerForQuerierClient) {
+	return setupSchedulerWithLimits(t, reg, &limits{queriers: 2})
+}
+
+func setupSchedulerWithLimits(t *testing.T, reg prometheus.Registerer, schedulerLimits Limits) (*Scheduler, schedulerpb.SchedulerForFrontendClient, schedulerpb.SchedulerForQuerierClient) {
 	cfg := Config{}
 	flagext.DefaultValues(&cfg)
 	cfg.MaxOutstandingPerTenant = testMaxOutstandingPerTenant
 
-	s, err := NewScheduler(cfg, &limits{queriers: 2}, log.NewNopLogger(), reg)
+	s, err := NewScheduler(cfg, schedulerLimits, log.NewNopLogger(), reg)
 	require.NoError(t, err)
 
 	server := grpc.NewServer()
@@ -95,6 +100,7 @@ func TestSchedulerBasicEnqueue(t *testing.T) {
 		require.Equal(t, "frontend-12345", msg2.FrontendAddress)
 		require.Equal(t, "GET", msg2.HttpRequest.Method)
 		require.Equal(t, "/hello", msg2.HttpRequest.Url)
+		require.Greater(t, msg2.QueueTime, time.Duration(0))
 		require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{}))
 	}
 
@@ -411,6 +417,113 @@ func TestSchedulerForwardsErrorToFrontend(t *testing.T) {
 	})
 }
 
+func TestSchedulerActiveQueries(t *testing.T) {
+	_, frontendClient, querierClient := setupScheduler(t, nil)
+
+	frontendLoop := initFrontendLoop(t, frontendClient, "frontend-12345")
+	frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+		Type:        schedulerpb.ENQUEUE,
+		QueryID:     1,
+		UserID:      "user-1",
+		HttpRequest: &httpgrpc.HTTPRequest{Method: "GET", Url: "/api/v1/query?query=up"},
+	})
+	frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+		Type:        schedulerpb.ENQUEUE,
+		QueryID:     2,
+		UserID:      "user-1",
+		HttpRequest: &httpgrpc.HTTPRequest{Method: "GET", Url: "/api/v1/query?query=sum%28up%29"},
+	})
+
+	// Both requests are queued.
+	resp, err := frontendClient.GetActiveQueries(context.Background(), &schedulerpb.ActiveQueriesRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Queries, 2)
+	for _, q := range resp.Queries {
+		require.Equal(t, "frontend-12345", q.FrontendAddress)
+		require.Equal(t, "user-1", q.UserID)
+		require.Equal(t, "/api/v1/query", q.Path)
+		require.NotZero(t, q.EnqueueTimestampMs)
+		require.Empty(t, q.QuerierID)
+		require.Zero(t, q.DispatchTimestampMs)
+	}
+
+	// The querier dequeues the first request and reports its progress.
+	querierLoop := initQuerierLoop(t, querierClient, "querier-1")
+	msg, err := querierLoop.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), msg.QueryID)
+	require.True(t, msg.ProgressReportingEnabled)
+	require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{InProgress: true, FetchedSeriesCount: 10}))
+
+	test.Poll(t, time.Second, []*schedulerpb.ActiveQuery{
+		{QueryID: 1, Query: "up", QuerierID: "querier-1", FetchedSeriesCount: 10},
+		{QueryID: 2, Query: "sum(up)"},
+	}, func() interface{} {
+		resp, err := frontendClient.GetActiveQueries(context.Background(), &schedulerpb.ActiveQueriesRequest{})
+		require.NoError(t, err)
+
+		// Only keep the fields which depend on the request execution.
+		queries := make([]*schedulerpb.ActiveQuery, 0, len(resp.Queries))
+		for _, q := range resp.Queries {
+			if q.QuerierID != "" {
+				require.NotZero(t, q.DispatchTimestampMs)
+			}
+			queries = append(queries, &schedulerpb.ActiveQuery{QueryID: q.QueryID, Query: q.Query, QuerierID: q.QuerierID, FetchedSeriesCount: q.FetchedSeriesCount})
+		}
+		sort.Slice(queries, func(i, j int) bool { return queries[i].QueryID < queries[j].QueryID })
+		return queries
+	})
+
+	// Once the querier signals it's done, the request is no longer active and the querier gets the next one.
+	require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{}))
+	msg, err = querierLoop.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), msg.QueryID)
+
+	resp, err = frontendClient.GetActiveQueries(context.Background(), &schedulerpb.ActiveQueriesRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Queries, 1)
+	require.Equal(t, uint64(2), resp.Queries[0].QueryID)
+}
+
+func TestSchedulerCancelActiveQuery(t *testing.T) {
+	scheduler, frontendClient, querierClient := setupScheduler(t, nil)
+	fm, frontendAddress := setupFrontendMock(t)
+
+	frontendLoop := initFrontendLoop(t, frontendClient, frontendAddress)
+	frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+		Type:        schedulerpb.ENQUEUE,
+		QueryID:     100,
+		UserID:      "test",
+		HttpRequest: &httpgrpc.HTTPRequest{Method: "GET", Url: "/hello"},
+	})
+
+	querierLoop := initQuerierLoop(t, querierClient, "querier-1")
+	_, err := querierLoop.Recv()
+	require.NoError(t, err)
+
+	// Cancelling an unknown request is a no-op.
+	resp, err := frontendClient.CancelActiveQuery(context.Background(), &schedulerpb.CancelActiveQueryRequest{FrontendAddress: frontendAddress, QueryID: 101})
+	require.NoError(t, err)
+	require.False(t, resp.Found)
+
+	resp, err = frontendClient.CancelActiveQuery(context.Background(), &schedulerpb.CancelActiveQueryRequest{FrontendAddress: frontendAddress, QueryID: 100})
+	require.NoError(t, err)
+	require.True(t, resp.Found)
+
+	// The frontend has been notified about the cancellation.
+	frontendResp := fm.getRequest(100)
+	require.NotNil(t, frontendResp)
+	require.Equal(t, int32(statusClientClosedRequest), frontendResp.Code)
+	require.Equal(t, errQueryCancelled.Error(), string(frontendResp.Body))
+
+	// The stream with the querier has been closed, so that the querier cancels the query execution.
+	_, err = querierLoop.Recv()
+	require.Error(t, err)
+
+	verifyNoPendingRequestsLeft(t, scheduler)
+}
+
 func TestSchedulerMetrics(t *testing.T) {
 	reg := prometheus.NewPedanticRegistry()
 
@@ -446,6 +559,76 @@ func TestSchedulerMetrics(t *testing.T) {
 	`), "cortex_query_scheduler_queue_length"))
 }
 
+func TestSchedulerPriorityClasses(t *testing.T) {
+	rangeQuery := &httpgrpc.HTTPRequest{Method: "GET", Url: "/prometheus/api/v1/query_range?query=up&start=0&end=3600&step=60"}
+	rulerQuery := &httpgrpc.HTTPRequest{Method: "GET", Url: "/prometheus/api/v1/query?query=up", Headers: []*httpgrpc.Header{
+		{Key: httpgrpcutil.QuerySourceHeader, Values: []string{httpgrpcutil.QuerySourceRuler}},
+	}}
+
+	tests := map[string]struct {
+		priorityClassWeights map[string]int
+		expectedOrder        []uint64
+	}{
+		"should dequeue requests in FIFO order if priority classes are disabled": {
+			expectedOrder: []uint64{1, 2, 3, 4},
+		},
+		"should dequeue requests proportionally to the priority class weights if enabled": {
+			priorityClassWeights: map[string]int{priorityClassRuler: 3},
+			expectedOrder:        []uint64{3, 1, 4, 2},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			reg := prometheus.NewPedanticRegistry()
+			scheduler, frontendClient, querierClient := setupSchedulerWithLimits(t, reg, &limits{priorityClassWeights: testData.priorityClassWeights})
+
+			frontendLoop := initFrontendLoop(t, frontendClient, "frontend-12345")
+			for queryID, req := range []*httpgrpc.HTTPRequest{rangeQuery, rangeQuery, rulerQuery, rulerQuery} {
+				frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+					Type:        schedulerpb.ENQUEUE,
+					QueryID:     uint64(queryID + 1),
+					UserID:      "test",
+					HttpRequest: req,
+				})
+			}
+
+			require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+				# HELP cortex_query_scheduler_priority_class_queue_length Number of queries in the queue per query priority class.
+				# TYPE cortex_query_scheduler_priority_class_queue_length gauge
+				cortex_query_scheduler_priority_class_queue_length{class="instant"} 0
+				cortex_query_scheduler_priority_class_queue_length{class="long_range"} 0
+				cortex_query_scheduler_priority_class_queue_length{class="other"} 0
+				cortex_query_scheduler_priority_class_queue_length{class="range"} 2
+				cortex_query_scheduler_priority_class_queue_length{class="ruler"} 2
+			`), "cortex_query_scheduler_priority_class_queue_length"))
+
+			querierLoop := initQuerierLoop(t, querierClient, "querier-1")
+
+			var actualOrder []uint64
+			for range testData.expectedOrder {
+				msg, err := querierLoop.Recv()
+				require.NoError(t, err)
+				actualOrder = append(actualOrder, msg.QueryID)
+				require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{}))
+			}
+
+			require.Equal(t, testData.expectedOrder, actualOrder)
+			verifyNoPendingRequestsLeft(t, scheduler)
+
+			require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+				# HELP cortex_query_scheduler_priority_class_queue_length Number of queries in the queue per query priority class.
+				# TYPE cortex_query_scheduler_priority_class_queue_length gauge
+				cortex_query_scheduler_priority_class_queue_length{class="instant"} 0
+				cortex_query_scheduler_priority_class_queue_length{class="long_range"} 0
+				cortex_query_scheduler_priority_class_queue_length{class="other"} 0
+				cortex_query_scheduler_priority_class_queue_length{class="range"} 0
+				cortex_query_scheduler_priority_class_queue_length{class="ruler"} 0
+			`), "cortex_query_scheduler_priority_class_queue_length"))
+		})
+	}
+}
+
 func initFrontendLoop(t *testing.T, client schedulerpb.SchedulerForFrontendClient, frontendAddr string) schedulerpb.SchedulerForFrontend_FrontendLoopClient {
 	loop, err := client.FrontendLoop(context.Background())
 	require.NoError(t, err)
@@ -500,13 +683,38 @@ func verifyNoPendingRequestsLeft(t *testing.T, scheduler *Scheduler) {
 }
 
 type limits struct {
-	queriers int
+	queriers             int
+	priorityClassWeights map[string]int
 }
 
 func (l limits) MaxQueriersPerUser(_ string) int {
 	return l.queriers
 }
 
+func (l limits) QueryPriorityClassWeights(_ string) map[string]int {
+	return l.priorityClassWeights
+}
+
+func setupFrontendMock(t *testing.T) (*frontendMock, string) {
+	fm := &frontendMock{resp: map[uint64]*httpgrpc.HTTPResponse{}}
+
+	frontendGrpcServer := grpc.NewServer()
+	frontendv2pb.RegisterFrontendForQuerierServer(frontendGrpcServer, fm)
+
+	l, err := net.Listen("tcp", "")
+	require.NoError(t, err)
+
+	go func() {
+		_ = frontendGrpcServer.Serve(l)
+	}()
+
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+
+	return fm, l.Addr().String()
+}
+
 type frontendMock struct {
 	mu   sync.Mutex
 	resp map[uint64]*httpgrpc.HTTPResponse
@@ -8,6 +8,8 @@ import (
 	fmt "fmt"
 	_ "github.com/gogo/protobuf/gogoproto"
 	proto "github.com/gogo/protobuf/proto"
+	github_com_gogo_protobuf_types "github.com/gogo/protobuf/types"
+	_ "github.com/golang/protobuf/ptypes/duration"
 	httpgrpc "github.com/weaveworks/common/httpgrpc"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
@@ -18,12 +20,14 @@ import (
 	reflect "reflect"
 	strconv "strconv"
 	strings "strings"
+	time "time"
 )
 
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
+var _ = time.Kitchen
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the proto package it is being compiled against.
@@ -86,6 +90,12 @@ func (SchedulerToFrontendStatus) EnumDescriptor() ([]byte, []int) {
 // To signal that querier is ready to accept another request, querier sends empty message.
 type QuerierToScheduler struct {
 	QuerierID string `protobuf:"bytes,1,opt,name=querierID,proto3" json:"querierID,omitempty"`
+	// Set when the querier is reporting the progress of the request it's currently executing,
+	// instead of signaling that it's ready to accept another one. Sent only if the scheduler
+	// enabled progress reporting for the request.
+	InProgress bool `protobuf:"varint,2,opt,name=inProgress,proto3" json:"inProgress,omitempty"`
+	// Number of series fetched so far by the request in progress.
+	FetchedSeriesCount uint64 `protobuf:"varint,3,opt,name=fetchedSeriesCount,proto3" json:"fetchedSeriesCount,omitempty"`
 }
 
 func (m *QuerierToScheduler) Reset()      { *m = QuerierToScheduler{} }
@@ -127,6 +137,20 @@ func (m *QuerierToScheduler) GetQuerierID() string {
 	return ""
 }
 
+func (m *QuerierToScheduler) GetInProgress() bool {
+	if m != nil {
+		return m.InProgress
+	}
+	return false
+}
+
+func (m *QuerierToScheduler) GetFetchedSeriesCount() uint64 {
+	if m != nil {
+		return m.FetchedSeriesCount
+	}
+	return 0
+}
+
 type SchedulerToQuerier struct {
 	// Query ID as reported by frontend. When querier sends the response back to frontend (using frontendAddress),
 	// it identifies the query by using this ID.
@@ -139,6 +163,12 @@ type SchedulerToQuerier struct {
 	// Whether query statistics tracking should be enabled. The response will include
 	// statistics only when this option is enabled.
 	StatsEnabled bool `protobuf:"varint,5,opt,name=statsEnabled,proto3" json:"statsEnabled,omitempty"`
+	// The time the request spent in the query-scheduler queue. It's tracked in the query
+	// statistics only when statsEnabled is true.
+	QueueTime time.Duration `protobuf:"bytes,6,opt,name=queueTime,proto3,stdduration" json:"queueTime"`
+	// Whether the querier should periodically report the progress of the request
+	// by sending QuerierToScheduler messages with inProgress set to true.
+	ProgressReportingEnabled bool `protobuf:"varint,7,opt,name=progressReportingEnabled,proto3" json:"progressReportingEnabled,omitempty"`
 }
 
 func (m *SchedulerToQuerier) Reset()      { *m = SchedulerToQuerier{} }
@@ -208,6 +238,20 @@ func (m *SchedulerToQuerier) GetStatsEnabled() bool {
 	return false
 }
 
+func (m *SchedulerToQuerier) GetQueueTime() time.Duration {
+	if m != nil {
+		return m.QueueTime
+	}
+	return 0
+}
+
+func (m *SchedulerToQuerier) GetProgressReportingEnabled() bool {
+	if m != nil {
+		return m.ProgressReportingEnabled
+	}
+	return false
+}
+
 type FrontendToScheduler struct {
 	Type FrontendToSchedulerType `protobuf:"varint,1,opt,name=type,proto3,enum=schedulerpb.FrontendToSchedulerType" json:"type,omitempty"`
 	// Used by INIT message. Will be put into all requests passed to querier.
@@ -424,6 +468,291 @@ func (m *NotifyQuerierShutdownResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_NotifyQuerierShutdownResponse proto.InternalMessageInfo
 
+type ActiveQueriesRequest struct {
+}
+
+func (m *ActiveQueriesRequest) Reset()      { *m = ActiveQueriesRequest{} }
+func (*ActiveQueriesRequest) ProtoMessage() {}
+func (*ActiveQueriesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2b3fc28395a6d9c5, []int{6}
+}
+func (m *ActiveQueriesRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ActiveQueriesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ActiveQueriesRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ActiveQueriesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ActiveQueriesRequest.Merge(m, src)
+}
+func (m *ActiveQueriesRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *ActiveQueriesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ActiveQueriesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ActiveQueriesRequest proto.InternalMessageInfo
+
+type ActiveQueriesResponse struct {
+	Queries []*ActiveQuery `protobuf:"bytes,1,rep,name=queries,proto3" json:"queries,omitempty"`
+}
+
+func (m *ActiveQueriesResponse) Reset()      { *m = ActiveQueriesResponse{} }
+func (*ActiveQueriesResponse) ProtoMessage() {}
+func (*ActiveQueriesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2b3fc28395a6d9c5, []int{7}
+}
+func (m *ActiveQueriesResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ActiveQueriesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ActiveQueriesResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ActiveQueriesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ActiveQueriesResponse.Merge(m, src)
+}
+func (m *ActiveQueriesResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *ActiveQueriesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ActiveQueriesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ActiveQueriesResponse proto.InternalMessageInfo
+
+func (m *ActiveQueriesResponse) GetQueries() []*ActiveQuery {
+	if m != nil {
+		return m.Queries
+	}
+	return nil
+}
+
+type ActiveQuery struct {
+	// Request identifier, unique for a given frontend.
+	QueryID         uint64 `protobuf:"varint,1,opt,name=queryID,proto3" json:"queryID,omitempty"`
+	FrontendAddress string `protobuf:"bytes,2,opt,name=frontendAddress,proto3" json:"frontendAddress,omitempty"`
+	UserID          string `protobuf:"bytes,3,opt,name=userID,proto3" json:"userID,omitempty"`
+	// HTTP path and PromQL query (if any) of the request.
+	Path               string `protobuf:"bytes,4,opt,name=path,proto3" json:"path,omitempty"`
+	Query              string `protobuf:"bytes,5,opt,name=query,proto3" json:"query,omitempty"`
+	EnqueueTimestampMs int64  `protobuf:"varint,6,opt,name=enqueueTimestampMs,proto3" json:"enqueueTimestampMs,omitempty"`
+	// The querier executing the request and when it was dispatched to it.
+	// Both are empty if the request is still queued.
+	QuerierID           string `protobuf:"bytes,7,opt,name=querierID,proto3" json:"querierID,omitempty"`
+	DispatchTimestampMs int64  `protobuf:"varint,8,opt,name=dispatchTimestampMs,proto3" json:"dispatchTimestampMs,omitempty"`
+	// Number of series fetched so far by the querier.
+	FetchedSeriesCount uint64 `protobuf:"varint,9,opt,name=fetchedSeriesCount,proto3" json:"fetchedSeriesCount,omitempty"`
+}
+
+func (m *ActiveQuery) Reset()      { *m = ActiveQuery{} }
+func (*ActiveQuery) ProtoMessage() {}
+func (*ActiveQuery) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2b3fc28395a6d9c5, []int{8}
+}
+func (m *ActiveQuery) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ActiveQuery) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ActiveQuery.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ActiveQuery) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ActiveQuery.Merge(m, src)
+}
+func (m *ActiveQuery) XXX_Size() int {
+	return m.Size()
+}
+func (m *ActiveQuery) XXX_DiscardUnknown() {
+	xxx_messageInfo_ActiveQuery.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ActiveQuery proto.InternalMessageInfo
+
+func (m *ActiveQuery) GetQueryID() uint64 {
+	if m != nil {
+		return m.QueryID
+	}
+	return 0
+}
+
+func (m *ActiveQuery) GetFrontendAddress() string {
+	if m != nil {
+		return m.FrontendAddress
+	}
+	return ""
+}
+
+func (m *ActiveQuery) GetUserID() string {
+	if m != nil {
+		return m.UserID
+	}
+	return ""
+}
+
+func (m *ActiveQuery) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *ActiveQuery) GetQuery() string {
+	if m != nil {
+		return m.Query
+	}
+	return ""
+}
+
+func (m *ActiveQuery) GetEnqueueTimestampMs() int64 {
+	if m != nil {
+		return m.EnqueueTimestampMs
+	}
+	return 0
+}
+
+func (m *ActiveQuery) GetQuerierID() string {
+	if m != nil {
+		return m.QuerierID
+	}
+	return ""
+}
+
+func (m *ActiveQuery) GetDispatchTimestampMs() int64 {
+	if m != nil {
+		return m.DispatchTimestampMs
+	}
+	return 0
+}
+
+func (m *ActiveQuery) GetFetchedSeriesCount() uint64 {
+	if m != nil {
+		return m.FetchedSeriesCount
+	}
+	return 0
+}
+
+type CancelActiveQueryRequest struct {
+	FrontendAddress string `protobuf:"bytes,1,opt,name=frontendAddress,proto3" json:"frontendAddress,omitempty"`
+	QueryID         uint64 `protobuf:"varint,2,opt,name=queryID,proto3" json:"queryID,omitempty"`
+}
+
+func (m *CancelActiveQueryRequest) Reset()      { *m = CancelActiveQueryRequest{} }
+func (*CancelActiveQueryRequest) ProtoMessage() {}
+func (*CancelActiveQueryRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2b3fc28395a6d9c5, []int{9}
+}
+func (m *CancelActiveQueryRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *CancelActiveQueryRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_CancelActiveQueryRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *CancelActiveQueryRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CancelActiveQueryRequest.Merge(m, src)
+}
+func (m *CancelActiveQueryRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *CancelActiveQueryRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CancelActiveQueryRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CancelActiveQueryRequest proto.InternalMessageInfo
+
+func (m *CancelActiveQueryRequest) GetFrontendAddress() string {
+	if m != nil {
+		return m.FrontendAddress
+	}
+	return ""
+}
+
+func (m *CancelActiveQueryRequest) GetQueryID() uint64 {
+	if m != nil {
+		return m.QueryID
+	}
+	return 0
+}
+
+type CancelActiveQueryResponse struct {
+	// Whether the request has been found in the scheduler.
+	Found bool `protobuf:"varint,1,opt,name=found,proto3" json:"found,omitempty"`
+}
+
+func (m *CancelActiveQueryResponse) Reset()      { *m = CancelActiveQueryResponse{} }
+func (*CancelActiveQueryResponse) ProtoMessage() {}
+func (*CancelActiveQueryResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2b3fc28395a6d9c5, []int{10}
+}
+func (m *CancelActiveQueryResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *CancelActiveQueryResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_CancelActiveQueryResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *CancelActiveQueryResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CancelActiveQueryResponse.Merge(m, src)
+}
+func (m *CancelActiveQueryResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *CancelActiveQueryResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CancelActiveQueryResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CancelActiveQueryResponse proto.InternalMessageInfo
+
+func (m *CancelActiveQueryResponse) GetFound() bool {
+	if m != nil {
+		return m.Found
+	}
+	return false
+}
+
 func init() {
 	proto.RegisterEnum("schedulerpb.FrontendToSchedulerType", FrontendToSchedulerType_name, FrontendToSchedulerType_value)
 	proto.RegisterEnum("schedulerpb.SchedulerToFrontendStatus", SchedulerToFrontendStatus_name, SchedulerToFrontendStatus_value)
@@ -433,53 +762,78 @@ func init() {
 	proto.RegisterType((*SchedulerToFrontend)(nil), "schedulerpb.SchedulerToFrontend")
 	proto.RegisterType((*NotifyQuerierShutdownRequest)(nil), "schedulerpb.NotifyQuerierShutdownRequest")
 	proto.RegisterType((*NotifyQuerierShutdownResponse)(nil), "schedulerpb.NotifyQuerierShutdownResponse")
+	proto.RegisterType((*ActiveQueriesRequest)(nil), "schedulerpb.ActiveQueriesRequest")
+	proto.RegisterType((*ActiveQueriesResponse)(nil), "schedulerpb.ActiveQueriesResponse")
+	proto.RegisterType((*ActiveQuery)(nil), "schedulerpb.ActiveQuery")
+	proto.RegisterType((*CancelActiveQueryRequest)(nil), "schedulerpb.CancelActiveQueryRequest")
+	proto.RegisterType((*CancelActiveQueryResponse)(nil), "schedulerpb.CancelActiveQueryResponse")
 }
 
 func init() { proto.RegisterFile("scheduler.proto", fileDescriptor_2b3fc28395a6d9c5) }
 
 var fileDescriptor_2b3fc28395a6d9c5 = []byte{
-	// 650 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0xcf, 0x4f, 0x13, 0x41,
-	0x14, 0xde, 0x29, 0x6d, 0x81, 0x57, 0x94, 0x75, 0x00, 0xad, 0x0d, 0x0e, 0x4d, 0x63, 0x4c, 0x25,
-	0xb1, 0x35, 0xd5, 0x44, 0x0f, 0xc4, 0xa4, 0xc2, 0x22, 0x8d, 0xb8, 0x85, 0xe9, 0x34, 0xfe, 0xb8,
-	0x34, 0xb4, 0x1d, 0x5a, 0x02, 0xec, 0x2c, 0xb3, 0xbb, 0x92, 0xde, 0x3c, 0x7a, 0xf4, 0xcf, 0xf0,
-	0x4f, 0xf1, 0x62, 0xc2, 0x91, 0x83, 0x07, 0x59, 0x2e, 0x1e, 0xf9, 0x13, 0x0c, 0xd3, 0x6d, 0xdd,
-	0xd6, 0x16, 0xb8, 0xbd, 0xf7, 0xf6, 0xfb, 0x76, 0xde, 0xf7, 0xbd, 0x37, 0x03, 0xb3, 0x4e, 0xa3,
-	0xcd, 0x9b, 0xde, 0x01, 0x97, 0x39, 0x5b, 0x0a, 0x57, 0xe0, 0x44, 0xbf, 0x60, 0xd7, 0x53, 0x4f,
-	0x5a, 0x7b, 0x6e, 0xdb, 0xab, 0xe7, 0x1a, 0xe2, 0x30, 0xdf, 0x12, 0x2d, 0x91, 0x57, 0x98, 0xba,
-	0xb7, 0xab, 0x32, 0x95, 0xa8, 0xa8, 0xcb, 0x4d, 0x3d, 0x0f, 0xc1, 0x8f, 0xf9, 0xce, 0x67, 0x7e,
-	0x2c, 0xe4, 0xbe, 0x93, 0x6f, 0x88, 0xc3, 0x43, 0x61, 0xe5, 0xdb, 0xae, 0x6b, 0xb7, 0xa4, 0xdd,
-	0xe8, 0x07, 0x5d, 0x56, 0xa6, 0x00, 0x78, 0xdb, 0xe3, 0x72, 0x8f, 0x4b, 0x26, 0x2a, 0xbd, 0xc3,
-	0xf1, 0x22, 0x4c, 0x1f, 0x75, 0xab, 0xa5, 0xb5, 0x24, 0x4a, 0xa3, 0xec, 0x34, 0xfd, 0x57, 0xc8,
-	0xfc, 0x44, 0x80, 0xfb, 0x58, 0x26, 0x02, 0x3e, 0x4e, 0xc2, 0xe4, 0x25, 0xa6, 0x13, 0x50, 0xa2,
-	0xb4, 0x97, 0xe2, 0x17, 0x90, 0xb8, 0x3c, 0x96, 0xf2, 0x23, 0x8f, 0x3b, 0x6e, 0x32, 0x92, 0x46,
-	0xd9, 0x44, 0x61, 0x21, 0xd7, 0x6f, 0x65, 0x83, 0xb1, 0xad, 0xe0, 0x23, 0x0d, 0x23, 0x71, 0x16,
-	0x66, 0x77, 0xa5, 0xb0, 0x5c, 0x6e, 0x35, 0x8b, 0xcd, 0xa6, 0xe4, 0x8e, 0x93, 0x9c, 0x50, 0xdd,
-	0x0c, 0x97, 0xf1, 0x5d, 0x88, 0x7b, 0x8e, 0x6a, 0x37, 0xaa, 0x00, 0x41, 0x86, 0x33, 0x30, 0xe3,
-	0xb8, 0x3b, 0xae, 0x63, 0x58, 0x3b, 0xf5, 0x03, 0xde, 0x4c, 0xc6, 0xd2, 0x28, 0x3b, 0x45, 0x07,
-	0x6a, 0x99, 0xaf, 0x11, 0x98, 0x5b, 0x0f, 0xfe, 0x17, 0x76, 0xe1, 0x25, 0x44, 0xdd, 0x8e, 0xcd,
-	0x95, 0x9a, 0xdb, 0x85, 0x87, 0xb9, 0xd0, 0x70, 0x72, 0x23, 0xf0, 0xac, 0x63, 0x73, 0xaa, 0x18,
-	0xa3, 0xfa, 0x8e, 0x8c, 0xee, 0x3b, 0x64, 0xda, 0xc4, 0xa0, 0x69, 0xe3, 0x14, 0x0d, 0x99, 0x19,
-	0xbb, 0xb1, 0x99, 0xc3, 0x56, 0xc4, 0x47, 0x58, 0xb1, 0x0f, 0x73, 0xa1, 0xc9, 0xf6, 0x44, 0xe2,
-	0x57, 0x10, 0xbf, 0x84, 0x79, 0x4e, 0xe0, 0xc5, 0xa3, 0x01, 0x2f, 0x46, 0x30, 0x2a, 0x0a, 0x4d,
-	0x03, 0x16, 0x9e, 0x87, 0x18, 0x97, 0x52, 0xc8, 0xc0, 0x85, 0x6e, 0x92, 0x59, 0x81, 0x45, 0x53,
-	0xb8, 0x7b, 0xbb, 0x9d, 0x60, 0x83, 0x2a, 0x6d, 0xcf, 0x6d, 0x8a, 0x63, 0xab, 0xd7, 0xf0, 0xd5,
-	0x5b, 0xb8, 0x04, 0x0f, 0xc6, 0xb0, 0x1d, 0x5b, 0x58, 0x0e, 0x5f, 0x5e, 0x81, 0x7b, 0x63, 0xa6,
-	0x84, 0xa7, 0x20, 0x5a, 0x32, 0x4b, 0x4c, 0xd7, 0x70, 0x02, 0x26, 0x0d, 0x73, 0xbb, 0x6a, 0x54,
-	0x0d, 0x1d, 0x61, 0x80, 0xf8, 0x6a, 0xd1, 0x5c, 0x35, 0x36, 0xf5, 0xc8, 0x72, 0x03, 0xee, 0x8f,
-	0xd5, 0x85, 0xe3, 0x10, 0x29, 0xbf, 0xd5, 0x35, 0x9c, 0x86, 0x45, 0x56, 0x2e, 0xd7, 0xde, 0x15,
-	0xcd, 0x8f, 0x35, 0x6a, 0x6c, 0x57, 0x8d, 0x0a, 0xab, 0xd4, 0xb6, 0x0c, 0x5a, 0x63, 0x86, 0x59,
-	0x34, 0x99, 0x8e, 0xf0, 0x34, 0xc4, 0x0c, 0x4a, 0xcb, 0x54, 0x8f, 0xe0, 0x3b, 0x70, 0xab, 0xb2,
-	0x51, 0x65, 0xac, 0x64, 0xbe, 0xa9, 0xad, 0x95, 0xdf, 0x9b, 0xfa, 0x44, 0xe1, 0x17, 0x0a, 0xf9,
-	0xbd, 0x2e, 0x64, 0xef, 0x2a, 0x55, 0x21, 0x11, 0x84, 0x9b, 0x42, 0xd8, 0x78, 0x69, 0xc0, 0xee,
-	0xff, 0xef, 0x6b, 0x6a, 0x69, 0xdc, 0x3c, 0x02, 0x6c, 0x46, 0xcb, 0xa2, 0xa7, 0x08, 0x5b, 0xb0,
-	0x30, 0xd2, 0x32, 0xfc, 0x78, 0x80, 0x7f, 0xd5, 0x50, 0x52, 0xcb, 0x37, 0x81, 0x76, 0x27, 0x50,
-	0xb0, 0x61, 0x3e, 0xac, 0xae, 0xbf, 0x4e, 0x1f, 0x60, 0xa6, 0x17, 0x2b, 0x7d, 0xe9, 0xeb, 0xae,
-	0x56, 0x2a, 0x7d, 0xdd, 0xc2, 0x75, 0x15, 0xbe, 0x2e, 0x9e, 0x9c, 0x11, 0xed, 0xf4, 0x8c, 0x68,
-	0x17, 0x67, 0x04, 0x7d, 0xf1, 0x09, 0xfa, 0xee, 0x13, 0xf4, 0xc3, 0x27, 0xe8, 0xc4, 0x27, 0xe8,
-	0xb7, 0x4f, 0xd0, 0x1f, 0x9f, 0x68, 0x17, 0x3e, 0x41, 0xdf, 0xce, 0x89, 0x76, 0x72, 0x4e, 0xb4,
-	0xd3, 0x73, 0xa2, 0x7d, 0x0a, 0x3f, 0xbb, 0xf5, 0xb8, 0x7a, 0x18, 0x9f, 0xfd, 0x0d, 0x00, 0x00,
-	0xff, 0xff, 0x49, 0x11, 0xf9, 0x34, 0x9d, 0x05, 0x00, 0x00,
+	// 974 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x56, 0xcd, 0x6e, 0xdb, 0x46,
+	0x10, 0x26, 0xa9, 0x1f, 0x4b, 0xa3, 0xb4, 0x51, 0xd6, 0x76, 0x2a, 0x0b, 0x2e, 0xa5, 0x12, 0x6d,
+	0xa0, 0x1a, 0xa8, 0x9c, 0xaa, 0x05, 0x5a, 0x14, 0x41, 0x01, 0xc5, 0x66, 0x12, 0x23, 0x89, 0x64,
+	0x53, 0x14, 0xfa, 0x07, 0x54, 0x90, 0xc4, 0x95, 0x44, 0xc4, 0xe2, 0x32, 0xe4, 0x32, 0x86, 0x6e,
+	0x45, 0x4f, 0x3d, 0xf6, 0xd8, 0x47, 0xe8, 0xbd, 0x8f, 0xd0, 0x4b, 0x0e, 0x3d, 0xf8, 0x98, 0x43,
+	0xd1, 0xd6, 0xf2, 0xa5, 0x47, 0x3f, 0x42, 0xa0, 0xe5, 0x52, 0x22, 0x65, 0xd1, 0xf6, 0x6d, 0x77,
+	0xfe, 0x76, 0xe6, 0xfb, 0x66, 0x86, 0x84, 0xdb, 0x6e, 0x7f, 0x84, 0x0d, 0xef, 0x18, 0x3b, 0x55,
+	0xdb, 0x21, 0x94, 0xa0, 0xdc, 0x5c, 0x60, 0xf7, 0x8a, 0x9f, 0x0c, 0x4d, 0x3a, 0xf2, 0x7a, 0xd5,
+	0x3e, 0x19, 0xef, 0x0e, 0xc9, 0x90, 0xec, 0x32, 0x9b, 0x9e, 0x37, 0x60, 0x37, 0x76, 0x61, 0x27,
+	0xdf, 0xb7, 0xf8, 0x79, 0xc8, 0xfc, 0x04, 0x77, 0x5f, 0xe1, 0x13, 0xe2, 0xbc, 0x70, 0x77, 0xfb,
+	0x64, 0x3c, 0x26, 0xd6, 0xee, 0x88, 0x52, 0x7b, 0xe8, 0xd8, 0xfd, 0xf9, 0x81, 0x7b, 0xc9, 0x43,
+	0x42, 0x86, 0xc7, 0x78, 0x11, 0xdb, 0xf0, 0x9c, 0x2e, 0x35, 0x89, 0xe5, 0xeb, 0x95, 0x9f, 0x45,
+	0x40, 0x47, 0x1e, 0x76, 0x4c, 0xec, 0xe8, 0xa4, 0x15, 0x64, 0x87, 0xb6, 0x21, 0xfb, 0xd2, 0x97,
+	0x1e, 0xec, 0x17, 0xc4, 0xb2, 0x58, 0xc9, 0x6a, 0x0b, 0x01, 0x92, 0x01, 0x4c, 0xeb, 0xd0, 0x21,
+	0x43, 0x07, 0xbb, 0x6e, 0x41, 0x2a, 0x8b, 0x95, 0x8c, 0x16, 0x92, 0xa0, 0x2a, 0xa0, 0x01, 0xa6,
+	0xb3, 0x60, 0xad, 0x99, 0x8b, 0xbb, 0x47, 0x3c, 0x8b, 0x16, 0x12, 0x65, 0xb1, 0x92, 0xd4, 0x56,
+	0x68, 0x94, 0xbf, 0x24, 0x40, 0xf3, 0xb7, 0x75, 0xc2, 0xf3, 0x41, 0x05, 0x58, 0x9b, 0xbd, 0x39,
+	0xe1, 0x29, 0x24, 0xb5, 0xe0, 0x8a, 0xbe, 0x80, 0xdc, 0xac, 0x4e, 0x0d, 0xbf, 0xf4, 0xb0, 0x4b,
+	0x59, 0x06, 0xb9, 0xda, 0x66, 0x75, 0x5e, 0xfb, 0x13, 0x5d, 0x3f, 0xe4, 0x4a, 0x2d, 0x6c, 0x89,
+	0x2a, 0x70, 0x7b, 0xe0, 0x10, 0x8b, 0x62, 0xcb, 0xa8, 0x1b, 0x06, 0x4b, 0x3f, 0xc1, 0xaa, 0x5b,
+	0x16, 0xa3, 0xbb, 0x90, 0xf6, 0x5c, 0x56, 0x7e, 0x92, 0x19, 0xf0, 0x1b, 0x52, 0xe0, 0x96, 0x4b,
+	0xbb, 0xd4, 0x55, 0xad, 0x6e, 0xef, 0x18, 0x1b, 0x85, 0x14, 0xab, 0x3e, 0x22, 0x43, 0x75, 0x86,
+	0x9e, 0x87, 0x75, 0x73, 0x8c, 0x0b, 0x69, 0x96, 0xdc, 0x56, 0xd5, 0x27, 0xa2, 0x1a, 0x10, 0x51,
+	0xdd, 0xe7, 0x44, 0x3c, 0xcc, 0xbc, 0xfe, 0xa7, 0x24, 0xfc, 0xf6, 0x6f, 0x49, 0xd4, 0x16, 0x5e,
+	0xe8, 0x2b, 0x28, 0xd8, 0x1c, 0x4e, 0x0d, 0xdb, 0xc4, 0xa1, 0xa6, 0x35, 0x0c, 0x9e, 0x5c, 0x63,
+	0x4f, 0xc6, 0xea, 0x95, 0x5f, 0x24, 0x58, 0x7f, 0xc4, 0xcb, 0x09, 0x93, 0xfa, 0x25, 0x24, 0xe9,
+	0xc4, 0xc6, 0x0c, 0xcc, 0x77, 0x6b, 0x1f, 0x56, 0x43, 0xcd, 0x58, 0x5d, 0x61, 0xaf, 0x4f, 0x6c,
+	0xac, 0x31, 0x8f, 0x55, 0xb0, 0x49, 0xab, 0x61, 0x0b, 0x71, 0x96, 0x88, 0x72, 0x16, 0x07, 0xe8,
+	0x12, 0x97, 0xa9, 0x1b, 0x73, 0xb9, 0xcc, 0x44, 0xfa, 0x32, 0x13, 0xca, 0x0b, 0x58, 0x0f, 0x35,
+	0x56, 0x50, 0x24, 0xfa, 0x1a, 0xd2, 0x33, 0x33, 0xcf, 0xe5, 0x58, 0xdc, 0x8b, 0x60, 0xb1, 0xc2,
+	0xa3, 0xc5, 0xac, 0x35, 0xee, 0x85, 0x36, 0x20, 0x85, 0x1d, 0x87, 0x38, 0x1c, 0x05, 0xff, 0xa2,
+	0x3c, 0x80, 0xed, 0x06, 0xa1, 0xe6, 0x60, 0xc2, 0x1b, 0xb8, 0x35, 0xf2, 0xa8, 0x41, 0x4e, 0xac,
+	0x20, 0xe1, 0x2b, 0x87, 0x4a, 0x29, 0xc1, 0xfb, 0x31, 0xde, 0xae, 0x4d, 0x2c, 0x17, 0x2b, 0x77,
+	0x61, 0xa3, 0xde, 0xa7, 0xe6, 0x2b, 0xec, 0x1b, 0xb8, 0x3c, 0xac, 0xf2, 0x14, 0x36, 0x97, 0xe4,
+	0xbe, 0x03, 0xaa, 0xf9, 0x5c, 0x98, 0x78, 0x56, 0x66, 0xa2, 0x92, 0xab, 0x15, 0x22, 0x65, 0x2e,
+	0x9c, 0x26, 0x5a, 0x60, 0xa8, 0xfc, 0x29, 0x41, 0x2e, 0xa4, 0xb8, 0x62, 0x06, 0x6f, 0xde, 0x13,
+	0x0b, 0xe6, 0x13, 0x11, 0xe6, 0x11, 0x24, 0xed, 0x2e, 0x1d, 0xf1, 0x7e, 0x60, 0xe7, 0x19, 0xb2,
+	0xec, 0x01, 0xd6, 0x07, 0x59, 0xcd, 0xbf, 0xcc, 0x16, 0x0a, 0xb6, 0xe6, 0xc3, 0xe1, 0xd2, 0xee,
+	0xd8, 0x7e, 0xee, 0x32, 0xc2, 0x13, 0xda, 0x0a, 0x4d, 0x14, 0xe9, 0xb5, 0xe5, 0xf5, 0x75, 0x1f,
+	0xd6, 0x0d, 0xd3, 0xb5, 0xbb, 0xb4, 0x3f, 0x0a, 0x87, 0xcb, 0xb0, 0x70, 0xab, 0x54, 0x31, 0x0b,
+	0x2d, 0x1b, 0xbb, 0xd0, 0x7e, 0x84, 0xc2, 0x5e, 0xd7, 0xea, 0xe3, 0xe3, 0x30, 0xc6, 0xf1, 0x2b,
+	0x48, 0xbc, 0x76, 0x96, 0xa4, 0x08, 0xf6, 0xca, 0xa7, 0xb0, 0xb5, 0x22, 0x3e, 0xa7, 0x7d, 0x03,
+	0x52, 0x03, 0xe2, 0x59, 0x06, 0x0b, 0x9b, 0xd1, 0xfc, 0xcb, 0xce, 0x03, 0x78, 0x2f, 0x66, 0xc6,
+	0x51, 0x06, 0x92, 0x07, 0x8d, 0x03, 0x3d, 0x2f, 0xa0, 0x1c, 0xac, 0xa9, 0x8d, 0xa3, 0xb6, 0xda,
+	0x56, 0xf3, 0x22, 0x02, 0x48, 0xef, 0xd5, 0x1b, 0x7b, 0xea, 0xb3, 0xbc, 0xb4, 0xd3, 0x87, 0xad,
+	0xd8, 0xa9, 0x40, 0x69, 0x90, 0x9a, 0x4f, 0xf3, 0x02, 0x2a, 0xc3, 0xb6, 0xde, 0x6c, 0x76, 0x9e,
+	0xd7, 0x1b, 0xdf, 0x75, 0x34, 0xf5, 0xa8, 0xad, 0xb6, 0xf4, 0x56, 0xe7, 0x50, 0xd5, 0x3a, 0xba,
+	0xda, 0xa8, 0x37, 0xf4, 0xbc, 0x88, 0xb2, 0x90, 0x52, 0x35, 0xad, 0xa9, 0xe5, 0x25, 0x74, 0x07,
+	0xde, 0x69, 0x3d, 0x69, 0xeb, 0xfa, 0x41, 0xe3, 0x71, 0x67, 0xbf, 0xf9, 0x4d, 0x23, 0x9f, 0xa8,
+	0xfd, 0x2d, 0x86, 0xa6, 0xf5, 0x11, 0x71, 0x82, 0xef, 0x40, 0x1b, 0x72, 0xfc, 0xf8, 0x8c, 0x10,
+	0x1b, 0x95, 0x22, 0x5d, 0x7c, 0xf9, 0xe3, 0x55, 0x2c, 0xc5, 0x4d, 0x33, 0xb7, 0x55, 0x84, 0x8a,
+	0x78, 0x5f, 0x44, 0x16, 0x6c, 0xae, 0x1c, 0x38, 0xf4, 0x71, 0xc4, 0xff, 0xaa, 0x91, 0x2e, 0xee,
+	0xdc, 0xc4, 0xd4, 0xe7, 0xa5, 0xf6, 0x87, 0x04, 0x1b, 0xe1, 0xf2, 0xe6, 0xdb, 0xe8, 0x5b, 0xb8,
+	0x15, 0x9c, 0x59, 0x81, 0xe5, 0xeb, 0x36, 0x73, 0xb1, 0x7c, 0xdd, 0xbe, 0xe2, 0x25, 0xfe, 0x00,
+	0xf9, 0xc7, 0x98, 0x46, 0xb6, 0x03, 0xfa, 0x20, 0x66, 0x09, 0x2c, 0x36, 0x4a, 0x51, 0xb9, 0xca,
+	0x84, 0x6f, 0x23, 0x01, 0x19, 0x70, 0xe7, 0x52, 0x13, 0xa2, 0x8f, 0x22, 0xae, 0x71, 0x43, 0x50,
+	0xbc, 0x77, 0x9d, 0x59, 0xf0, 0xca, 0xc3, 0xfa, 0xe9, 0x99, 0x2c, 0xbc, 0x39, 0x93, 0x85, 0x8b,
+	0x33, 0x59, 0xfc, 0x69, 0x2a, 0x8b, 0xbf, 0x4f, 0x65, 0xf1, 0xf5, 0x54, 0x16, 0x4f, 0xa7, 0xb2,
+	0xf8, 0xdf, 0x54, 0x16, 0xff, 0x9f, 0xca, 0xc2, 0xc5, 0x54, 0x16, 0x7f, 0x3d, 0x97, 0x85, 0xd3,
+	0x73, 0x59, 0x78, 0x73, 0x2e, 0x0b, 0xdf, 0x87, 0x7f, 0xb4, 0x7a, 0x69, 0xf6, 0xcd, 0xfd, 0xec,
+	0x6d, 0x00, 0x00, 0x00, 0xff, 0xff, 0x10, 0x41, 0xf7, 0xa6, 0x8f, 0x09, 0x00, 0x00,
 }
 
 func (x FrontendToSchedulerType) String() string {
@@ -518,6 +872,12 @@ func (this *QuerierToScheduler) Equal(that interface{}) bool {
 	if this.QuerierID != that1.QuerierID {
 		return false
 	}
+	if this.InProgress != that1.InProgress {
+		return false
+	}
+	if this.FetchedSeriesCount != that1.FetchedSeriesCount {
+		return false
+	}
 	return true
 }
 func (this *SchedulerToQuerier) Equal(that interface{}) bool {
@@ -554,6 +914,12 @@ func (this *SchedulerToQuerier) Equal(that interface{}) bool {
 	if this.StatsEnabled != that1.StatsEnabled {
 		return false
 	}
+	if this.QueueTime != that1.QueueTime {
+		return false
+	}
+	if this.ProgressReportingEnabled != that1.ProgressReportingEnabled {
+		return false
+	}
 	return true
 }
 func (this *FrontendToScheduler) Equal(that interface{}) bool {
@@ -667,50 +1033,203 @@ func (this *NotifyQuerierShutdownResponse) Equal(that interface{}) bool {
 	}
 	return true
 }
-func (this *QuerierToScheduler) GoString() string {
-	if this == nil {
-		return "nil"
+func (this *ActiveQueriesRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
 	}
-	s := make([]string, 0, 5)
-	s = append(s, "&schedulerpb.QuerierToScheduler{")
-	s = append(s, "QuerierID: "+fmt.Sprintf("%#v", this.QuerierID)+",\n")
-	s = append(s, "}")
-	return strings.Join(s, "")
-}
-func (this *SchedulerToQuerier) GoString() string {
-	if this == nil {
-		return "nil"
+
+	that1, ok := that.(*ActiveQueriesRequest)
+	if !ok {
+		that2, ok := that.(ActiveQueriesRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
 	}
-	s := make([]string, 0, 9)
-	s = append(s, "&schedulerpb.SchedulerToQuerier{")
-	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
-	if this.HttpRequest != nil {
-		s = append(s, "HttpRequest: "+fmt.Sprintf("%#v", this.HttpRequest)+",\n")
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
 	}
-	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
-	s = append(s, "UserID: "+fmt.Sprintf("%#v", this.UserID)+",\n")
-	s = append(s, "StatsEnabled: "+fmt.Sprintf("%#v", this.StatsEnabled)+",\n")
-	s = append(s, "}")
-	return strings.Join(s, "")
+	return true
 }
-func (this *FrontendToScheduler) GoString() string {
-	if this == nil {
-		return "nil"
-	}
-	s := make([]string, 0, 10)
-	s = append(s, "&schedulerpb.FrontendToScheduler{")
-	s = append(s, "Type: "+fmt.Sprintf("%#v", this.Type)+",\n")
-	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
-	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
-	s = append(s, "UserID: "+fmt.Sprintf("%#v", this.UserID)+",\n")
-	if this.HttpRequest != nil {
-		s = append(s, "HttpRequest: "+fmt.Sprintf("%#v", this.HttpRequest)+",\n")
+func (this *ActiveQueriesResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
 	}
-	s = append(s, "StatsEnabled: "+fmt.Sprintf("%#v", this.StatsEnabled)+",\n")
-	s = append(s, "}")
-	return strings.Join(s, "")
-}
-func (this *SchedulerToFrontend) GoString() string {
+
+	that1, ok := that.(*ActiveQueriesResponse)
+	if !ok {
+		that2, ok := that.(ActiveQueriesResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Queries) != len(that1.Queries) {
+		return false
+	}
+	for i := range this.Queries {
+		if !this.Queries[i].Equal(that1.Queries[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *ActiveQuery) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*ActiveQuery)
+	if !ok {
+		that2, ok := that.(ActiveQuery)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.QueryID != that1.QueryID {
+		return false
+	}
+	if this.FrontendAddress != that1.FrontendAddress {
+		return false
+	}
+	if this.UserID != that1.UserID {
+		return false
+	}
+	if this.Path != that1.Path {
+		return false
+	}
+	if this.Query != that1.Query {
+		return false
+	}
+	if this.EnqueueTimestampMs != that1.EnqueueTimestampMs {
+		return false
+	}
+	if this.QuerierID != that1.QuerierID {
+		return false
+	}
+	if this.DispatchTimestampMs != that1.DispatchTimestampMs {
+		return false
+	}
+	if this.FetchedSeriesCount != that1.FetchedSeriesCount {
+		return false
+	}
+	return true
+}
+func (this *CancelActiveQueryRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*CancelActiveQueryRequest)
+	if !ok {
+		that2, ok := that.(CancelActiveQueryRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.FrontendAddress != that1.FrontendAddress {
+		return false
+	}
+	if this.QueryID != that1.QueryID {
+		return false
+	}
+	return true
+}
+func (this *CancelActiveQueryResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*CancelActiveQueryResponse)
+	if !ok {
+		that2, ok := that.(CancelActiveQueryResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.Found != that1.Found {
+		return false
+	}
+	return true
+}
+func (this *QuerierToScheduler) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&schedulerpb.QuerierToScheduler{")
+	s = append(s, "QuerierID: "+fmt.Sprintf("%#v", this.QuerierID)+",\n")
+	s = append(s, "InProgress: "+fmt.Sprintf("%#v", this.InProgress)+",\n")
+	s = append(s, "FetchedSeriesCount: "+fmt.Sprintf("%#v", this.FetchedSeriesCount)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *SchedulerToQuerier) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 11)
+	s = append(s, "&schedulerpb.SchedulerToQuerier{")
+	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
+	if this.HttpRequest != nil {
+		s = append(s, "HttpRequest: "+fmt.Sprintf("%#v", this.HttpRequest)+",\n")
+	}
+	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
+	s = append(s, "UserID: "+fmt.Sprintf("%#v", this.UserID)+",\n")
+	s = append(s, "StatsEnabled: "+fmt.Sprintf("%#v", this.StatsEnabled)+",\n")
+	s = append(s, "QueueTime: "+fmt.Sprintf("%#v", this.QueueTime)+",\n")
+	s = append(s, "ProgressReportingEnabled: "+fmt.Sprintf("%#v", this.ProgressReportingEnabled)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *FrontendToScheduler) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 10)
+	s = append(s, "&schedulerpb.FrontendToScheduler{")
+	s = append(s, "Type: "+fmt.Sprintf("%#v", this.Type)+",\n")
+	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
+	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
+	s = append(s, "UserID: "+fmt.Sprintf("%#v", this.UserID)+",\n")
+	if this.HttpRequest != nil {
+		s = append(s, "HttpRequest: "+fmt.Sprintf("%#v", this.HttpRequest)+",\n")
+	}
+	s = append(s, "StatsEnabled: "+fmt.Sprintf("%#v", this.StatsEnabled)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *SchedulerToFrontend) GoString() string {
 	if this == nil {
 		return "nil"
 	}
@@ -740,6 +1259,66 @@ func (this *NotifyQuerierShutdownResponse) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *ActiveQueriesRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 4)
+	s = append(s, "&schedulerpb.ActiveQueriesRequest{")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ActiveQueriesResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&schedulerpb.ActiveQueriesResponse{")
+	if this.Queries != nil {
+		s = append(s, "Queries: "+fmt.Sprintf("%#v", this.Queries)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ActiveQuery) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 13)
+	s = append(s, "&schedulerpb.ActiveQuery{")
+	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
+	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
+	s = append(s, "UserID: "+fmt.Sprintf("%#v", this.UserID)+",\n")
+	s = append(s, "Path: "+fmt.Sprintf("%#v", this.Path)+",\n")
+	s = append(s, "Query: "+fmt.Sprintf("%#v", this.Query)+",\n")
+	s = append(s, "EnqueueTimestampMs: "+fmt.Sprintf("%#v", this.EnqueueTimestampMs)+",\n")
+	s = append(s, "QuerierID: "+fmt.Sprintf("%#v", this.QuerierID)+",\n")
+	s = append(s, "DispatchTimestampMs: "+fmt.Sprintf("%#v", this.DispatchTimestampMs)+",\n")
+	s = append(s, "FetchedSeriesCount: "+fmt.Sprintf("%#v", this.FetchedSeriesCount)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *CancelActiveQueryRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&schedulerpb.CancelActiveQueryRequest{")
+	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
+	s = append(s, "QueryID: "+fmt.Sprintf("%#v", this.QueryID)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *CancelActiveQueryResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&schedulerpb.CancelActiveQueryResponse{")
+	s = append(s, "Found: "+fmt.Sprintf("%#v", this.Found)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func valueToGoStringScheduler(v interface{}, typ string) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -923,6 +1502,11 @@ type SchedulerForFrontendClient interface {
 	// parties... if connection breaks, frontend can cancel (and possibly retry on different scheduler) all pending
 	// requests sent to this scheduler, while scheduler can cancel queued requests from given frontend.
 	FrontendLoop(ctx context.Context, opts ...grpc.CallOption) (SchedulerForFrontend_FrontendLoopClient, error)
+	// Returns the requests currently queued in the scheduler or executed by queriers.
+	GetActiveQueries(ctx context.Context, in *ActiveQueriesRequest, opts ...grpc.CallOption) (*ActiveQueriesResponse, error)
+	// Cancels a request queued in the scheduler or executed by a querier. The frontend which
+	// enqueued the request receives an error as the request's response.
+	CancelActiveQuery(ctx context.Context, in *CancelActiveQueryRequest, opts ...grpc.CallOption) (*CancelActiveQueryResponse, error)
 }
 
 type schedulerForFrontendClient struct {
@@ -964,6 +1548,24 @@ func (x *schedulerForFrontendFrontendLoopClient) Recv() (*SchedulerToFrontend, e
 	return m, nil
 }
 
+func (c *schedulerForFrontendClient) GetActiveQueries(ctx context.Context, in *ActiveQueriesRequest, opts ...grpc.CallOption) (*ActiveQueriesResponse, error) {
+	out := new(ActiveQueriesResponse)
+	err := c.cc.Invoke(ctx, "/schedulerpb.SchedulerForFrontend/GetActiveQueries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *schedulerForFrontendClient) CancelActiveQuery(ctx context.Context, in *CancelActiveQueryRequest, opts ...grpc.CallOption) (*CancelActiveQueryResponse, error) {
+	out := new(CancelActiveQueryResponse)
+	err := c.cc.Invoke(ctx, "/schedulerpb.SchedulerForFrontend/CancelActiveQuery", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SchedulerForFrontendServer is the server API for SchedulerForFrontend service.
 type SchedulerForFrontendServer interface {
 	// After calling this method, both Frontend and Scheduler enter a loop. Frontend will keep sending ENQUEUE and
@@ -973,6 +1575,11 @@ type SchedulerForFrontendServer interface {
 	// parties... if connection breaks, frontend can cancel (and possibly retry on different scheduler) all pending
 	// requests sent to this scheduler, while scheduler can cancel queued requests from given frontend.
 	FrontendLoop(SchedulerForFrontend_FrontendLoopServer) error
+	// Returns the requests currently queued in the scheduler or executed by queriers.
+	GetActiveQueries(context.Context, *ActiveQueriesRequest) (*ActiveQueriesResponse, error)
+	// Cancels a request queued in the scheduler or executed by a querier. The frontend which
+	// enqueued the request receives an error as the request's response.
+	CancelActiveQuery(context.Context, *CancelActiveQueryRequest) (*CancelActiveQueryResponse, error)
 }
 
 // UnimplementedSchedulerForFrontendServer can be embedded to have forward compatible implementations.
@@ -982,6 +1589,12 @@ type UnimplementedSchedulerForFrontendServer struct {
 func (*UnimplementedSchedulerForFrontendServer) FrontendLoop(srv SchedulerForFrontend_FrontendLoopServer) error {
 	return status.Errorf(codes.Unimplemented, "method FrontendLoop not implemented")
 }
+func (*UnimplementedSchedulerForFrontendServer) GetActiveQueries(ctx context.Context, req *ActiveQueriesRequest) (*ActiveQueriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetActiveQueries not implemented")
+}
+func (*UnimplementedSchedulerForFrontendServer) CancelActiveQuery(ctx context.Context, req *CancelActiveQueryRequest) (*CancelActiveQueryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelActiveQuery not implemented")
+}
 
 func RegisterSchedulerForFrontendServer(s *grpc.Server, srv SchedulerForFrontendServer) {
 	s.RegisterService(&_SchedulerForFrontend_serviceDesc, srv)
@@ -1013,10 +1626,55 @@ func (x *schedulerForFrontendFrontendLoopServer) Recv() (*FrontendToScheduler, e
 	return m, nil
 }
 
+func _SchedulerForFrontend_GetActiveQueries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ActiveQueriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SchedulerForFrontendServer).GetActiveQueries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/schedulerpb.SchedulerForFrontend/GetActiveQueries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SchedulerForFrontendServer).GetActiveQueries(ctx, req.(*ActiveQueriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SchedulerForFrontend_CancelActiveQuery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelActiveQueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SchedulerForFrontendServer).CancelActiveQuery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/schedulerpb.SchedulerForFrontend/CancelActiveQuery",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SchedulerForFrontendServer).CancelActiveQuery(ctx, req.(*CancelActiveQueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _SchedulerForFrontend_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "schedulerpb.SchedulerForFrontend",
 	HandlerType: (*SchedulerForFrontendServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetActiveQueries",
+			Handler:    _SchedulerForFrontend_GetActiveQueries_Handler,
+		},
+		{
+			MethodName: "CancelActiveQuery",
+			Handler:    _SchedulerForFrontend_CancelActiveQuery_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "FrontendLoop",
@@ -1048,6 +1706,21 @@ func (m *QuerierToScheduler) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.FetchedSeriesCount != 0 {
+		i = encodeVarintScheduler(dAtA, i, uint64(m.FetchedSeriesCount))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.InProgress {
+		i--
+		if m.InProgress {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x10
+	}
 	if len(m.QuerierID) > 0 {
 		i -= len(m.QuerierID)
 		copy(dAtA[i:], m.QuerierID)
@@ -1078,6 +1751,24 @@ func (m *SchedulerToQuerier) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.ProgressReportingEnabled {
+		i--
+		if m.ProgressReportingEnabled {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x38
+	}
+	n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.QueueTime, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.QueueTime):])
+	if err1 != nil {
+		return 0, err1
+	}
+	i -= n1
+	i = encodeVarintScheduler(dAtA, i, uint64(n1))
+	i--
+	dAtA[i] = 0x32
 	if m.StatsEnabled {
 		i--
 		if m.StatsEnabled {
@@ -1279,11 +1970,217 @@ func (m *NotifyQuerierShutdownResponse) MarshalToSizedBuffer(dAtA []byte) (int,
 	return len(dAtA) - i, nil
 }
 
-func encodeVarintScheduler(dAtA []byte, offset int, v uint64) int {
-	offset -= sovScheduler(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
+func (m *ActiveQueriesRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ActiveQueriesRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ActiveQueriesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *ActiveQueriesResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ActiveQueriesResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ActiveQueriesResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Queries) > 0 {
+		for iNdEx := len(m.Queries) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Queries[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintScheduler(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *ActiveQuery) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ActiveQuery) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ActiveQuery) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.FetchedSeriesCount != 0 {
+		i = encodeVarintScheduler(dAtA, i, uint64(m.FetchedSeriesCount))
+		i--
+		dAtA[i] = 0x48
+	}
+	if m.DispatchTimestampMs != 0 {
+		i = encodeVarintScheduler(dAtA, i, uint64(m.DispatchTimestampMs))
+		i--
+		dAtA[i] = 0x40
+	}
+	if len(m.QuerierID) > 0 {
+		i -= len(m.QuerierID)
+		copy(dAtA[i:], m.QuerierID)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.QuerierID)))
+		i--
+		dAtA[i] = 0x3a
+	}
+	if m.EnqueueTimestampMs != 0 {
+		i = encodeVarintScheduler(dAtA, i, uint64(m.EnqueueTimestampMs))
+		i--
+		dAtA[i] = 0x30
+	}
+	if len(m.Query) > 0 {
+		i -= len(m.Query)
+		copy(dAtA[i:], m.Query)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.Query)))
+		i--
+		dAtA[i] = 0x2a
+	}
+	if len(m.Path) > 0 {
+		i -= len(m.Path)
+		copy(dAtA[i:], m.Path)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.Path)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if len(m.UserID) > 0 {
+		i -= len(m.UserID)
+		copy(dAtA[i:], m.UserID)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.UserID)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.FrontendAddress) > 0 {
+		i -= len(m.FrontendAddress)
+		copy(dAtA[i:], m.FrontendAddress)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.FrontendAddress)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.QueryID != 0 {
+		i = encodeVarintScheduler(dAtA, i, uint64(m.QueryID))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *CancelActiveQueryRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CancelActiveQueryRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *CancelActiveQueryRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.QueryID != 0 {
+		i = encodeVarintScheduler(dAtA, i, uint64(m.QueryID))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.FrontendAddress) > 0 {
+		i -= len(m.FrontendAddress)
+		copy(dAtA[i:], m.FrontendAddress)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.FrontendAddress)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *CancelActiveQueryResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CancelActiveQueryResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *CancelActiveQueryResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Found {
+		i--
+		if m.Found {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintScheduler(dAtA []byte, offset int, v uint64) int {
+	offset -= sovScheduler(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
 		v >>= 7
 		offset++
 	}
@@ -1300,6 +2197,12 @@ func (m *QuerierToScheduler) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovScheduler(uint64(l))
 	}
+	if m.InProgress {
+		n += 2
+	}
+	if m.FetchedSeriesCount != 0 {
+		n += 1 + sovScheduler(uint64(m.FetchedSeriesCount))
+	}
 	return n
 }
 
@@ -1327,6 +2230,11 @@ func (m *SchedulerToQuerier) Size() (n int) {
 	if m.StatsEnabled {
 		n += 2
 	}
+	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.QueueTime)
+	n += 1 + l + sovScheduler(uint64(l))
+	if m.ProgressReportingEnabled {
+		n += 2
+	}
 	return n
 }
 
@@ -1398,6 +2306,99 @@ func (m *NotifyQuerierShutdownResponse) Size() (n int) {
 	return n
 }
 
+func (m *ActiveQueriesRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *ActiveQueriesResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Queries) > 0 {
+		for _, e := range m.Queries {
+			l = e.Size()
+			n += 1 + l + sovScheduler(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *ActiveQuery) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.QueryID != 0 {
+		n += 1 + sovScheduler(uint64(m.QueryID))
+	}
+	l = len(m.FrontendAddress)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
+	l = len(m.UserID)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
+	l = len(m.Path)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
+	l = len(m.Query)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
+	if m.EnqueueTimestampMs != 0 {
+		n += 1 + sovScheduler(uint64(m.EnqueueTimestampMs))
+	}
+	l = len(m.QuerierID)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
+	if m.DispatchTimestampMs != 0 {
+		n += 1 + sovScheduler(uint64(m.DispatchTimestampMs))
+	}
+	if m.FetchedSeriesCount != 0 {
+		n += 1 + sovScheduler(uint64(m.FetchedSeriesCount))
+	}
+	return n
+}
+
+func (m *CancelActiveQueryRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.FrontendAddress)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
+	if m.QueryID != 0 {
+		n += 1 + sovScheduler(uint64(m.QueryID))
+	}
+	return n
+}
+
+func (m *CancelActiveQueryResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Found {
+		n += 2
+	}
+	return n
+}
+
 func sovScheduler(x uint64) (n int) {
 	return (math_bits.Len64(x|1) + 6) / 7
 }
@@ -1410,6 +2411,8 @@ func (this *QuerierToScheduler) String() string {
 	}
 	s := strings.Join([]string{`&QuerierToScheduler{`,
 		`QuerierID:` + fmt.Sprintf("%v", this.QuerierID) + `,`,
+		`InProgress:` + fmt.Sprintf("%v", this.InProgress) + `,`,
+		`FetchedSeriesCount:` + fmt.Sprintf("%v", this.FetchedSeriesCount) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1424,6 +2427,8 @@ func (this *SchedulerToQuerier) String() string {
 		`FrontendAddress:` + fmt.Sprintf("%v", this.FrontendAddress) + `,`,
 		`UserID:` + fmt.Sprintf("%v", this.UserID) + `,`,
 		`StatsEnabled:` + fmt.Sprintf("%v", this.StatsEnabled) + `,`,
+		`QueueTime:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.QueueTime), "Duration", "duration.Duration", 1), `&`, ``, 1) + `,`,
+		`ProgressReportingEnabled:` + fmt.Sprintf("%v", this.ProgressReportingEnabled) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1473,15 +2478,78 @@ func (this *NotifyQuerierShutdownResponse) String() string {
 	}, "")
 	return s
 }
-func valueToStringScheduler(v interface{}) string {
-	rv := reflect.ValueOf(v)
-	if rv.IsNil() {
+func (this *ActiveQueriesRequest) String() string {
+	if this == nil {
 		return "nil"
 	}
-	pv := reflect.Indirect(rv).Interface()
-	return fmt.Sprintf("*%v", pv)
-}
-func (m *QuerierToScheduler) Unmarshal(dAtA []byte) error {
+	s := strings.Join([]string{`&ActiveQueriesRequest{`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ActiveQueriesResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForQueries := "[]*ActiveQuery{"
+	for _, f := range this.Queries {
+		repeatedStringForQueries += strings.Replace(f.String(), "ActiveQuery", "ActiveQuery", 1) + ","
+	}
+	repeatedStringForQueries += "}"
+	s := strings.Join([]string{`&ActiveQueriesResponse{`,
+		`Queries:` + repeatedStringForQueries + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ActiveQuery) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&ActiveQuery{`,
+		`QueryID:` + fmt.Sprintf("%v", this.QueryID) + `,`,
+		`FrontendAddress:` + fmt.Sprintf("%v", this.FrontendAddress) + `,`,
+		`UserID:` + fmt.Sprintf("%v", this.UserID) + `,`,
+		`Path:` + fmt.Sprintf("%v", this.Path) + `,`,
+		`Query:` + fmt.Sprintf("%v", this.Query) + `,`,
+		`EnqueueTimestampMs:` + fmt.Sprintf("%v", this.EnqueueTimestampMs) + `,`,
+		`QuerierID:` + fmt.Sprintf("%v", this.QuerierID) + `,`,
+		`DispatchTimestampMs:` + fmt.Sprintf("%v", this.DispatchTimestampMs) + `,`,
+		`FetchedSeriesCount:` + fmt.Sprintf("%v", this.FetchedSeriesCount) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *CancelActiveQueryRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&CancelActiveQueryRequest{`,
+		`FrontendAddress:` + fmt.Sprintf("%v", this.FrontendAddress) + `,`,
+		`QueryID:` + fmt.Sprintf("%v", this.QueryID) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *CancelActiveQueryResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&CancelActiveQueryResponse{`,
+		`Found:` + fmt.Sprintf("%v", this.Found) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringScheduler(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("*%v", pv)
+}
+func (m *QuerierToScheduler) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1542,6 +2610,45 @@ func (m *QuerierToScheduler) Unmarshal(dAtA []byte) error {
 			}
 			m.QuerierID = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field InProgress", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.InProgress = bool(v != 0)
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedSeriesCount", wireType)
+			}
+			m.FetchedSeriesCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FetchedSeriesCount |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipScheduler(dAtA[iNdEx:])
@@ -1677,16 +2784,680 @@ func (m *SchedulerToQuerier) Unmarshal(dAtA []byte) error {
 			if postIndex < 0 {
 				return ErrInvalidLengthScheduler
 			}
-			if postIndex > l {
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.FrontendAddress = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UserID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.UserID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StatsEnabled", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.StatsEnabled = bool(v != 0)
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueueTime", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.QueueTime, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProgressReportingEnabled", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ProgressReportingEnabled = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipScheduler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowScheduler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: FrontendToScheduler: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: FrontendToScheduler: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+			}
+			m.Type = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Type |= FrontendToSchedulerType(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FrontendAddress", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.FrontendAddress = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryID", wireType)
+			}
+			m.QueryID = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.QueryID |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UserID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.UserID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field HttpRequest", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.HttpRequest == nil {
+				m.HttpRequest = &httpgrpc.HTTPRequest{}
+			}
+			if err := m.HttpRequest.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StatsEnabled", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.StatsEnabled = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipScheduler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SchedulerToFrontend) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowScheduler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SchedulerToFrontend: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SchedulerToFrontend: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
+			}
+			m.Status = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Status |= SchedulerToFrontendStatus(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Error = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipScheduler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *NotifyQuerierShutdownRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowScheduler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: NotifyQuerierShutdownRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: NotifyQuerierShutdownRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QuerierID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.QuerierID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipScheduler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *NotifyQuerierShutdownResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowScheduler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: NotifyQuerierShutdownResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: NotifyQuerierShutdownResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipScheduler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ActiveQueriesRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowScheduler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ActiveQueriesRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ActiveQueriesRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipScheduler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ActiveQueriesResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowScheduler
+			}
+			if iNdEx >= l {
 				return io.ErrUnexpectedEOF
 			}
-			m.FrontendAddress = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 4:
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ActiveQueriesResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ActiveQueriesResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field UserID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Queries", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowScheduler
@@ -1696,44 +3467,26 @@ func (m *SchedulerToQuerier) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthScheduler
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthScheduler
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.UserID = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 5:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field StatsEnabled", wireType)
-			}
-			var v int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowScheduler
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				v |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			m.Queries = append(m.Queries, &ActiveQuery{})
+			if err := m.Queries[len(m.Queries)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
-			m.StatsEnabled = bool(v != 0)
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipScheduler(dAtA[iNdEx:])
@@ -1758,7 +3511,7 @@ func (m *SchedulerToQuerier) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
+func (m *ActiveQuery) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1781,17 +3534,17 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: FrontendToScheduler: wiretype end group for non-group")
+			return fmt.Errorf("proto: ActiveQuery: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: FrontendToScheduler: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: ActiveQuery: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryID", wireType)
 			}
-			m.Type = 0
+			m.QueryID = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowScheduler
@@ -1801,7 +3554,7 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Type |= FrontendToSchedulerType(b&0x7F) << shift
+				m.QueryID |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -1839,10 +3592,10 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 			m.FrontendAddress = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field QueryID", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UserID", wireType)
 			}
-			m.QueryID = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowScheduler
@@ -1852,14 +3605,27 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.QueryID |= uint64(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.UserID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		case 4:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field UserID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Path", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -1887,13 +3653,13 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.UserID = string(dAtA[iNdEx:postIndex])
+			m.Path = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 5:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field HttpRequest", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Query", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowScheduler
@@ -1903,33 +3669,29 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthScheduler
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthScheduler
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.HttpRequest == nil {
-				m.HttpRequest = &httpgrpc.HTTPRequest{}
-			}
-			if err := m.HttpRequest.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Query = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 6:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field StatsEnabled", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field EnqueueTimestampMs", wireType)
 			}
-			var v int
+			m.EnqueueTimestampMs = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowScheduler
@@ -1939,70 +3701,48 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				v |= int(b&0x7F) << shift
+				m.EnqueueTimestampMs |= int64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			m.StatsEnabled = bool(v != 0)
-		default:
-			iNdEx = preIndex
-			skippy, err := skipScheduler(dAtA[iNdEx:])
-			if err != nil {
-				return err
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QuerierID", wireType)
 			}
-			if skippy < 0 {
-				return ErrInvalidLengthScheduler
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			if (iNdEx + skippy) < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthScheduler
 			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *SchedulerToFrontend) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowScheduler
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
 			}
-			if iNdEx >= l {
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: SchedulerToFrontend: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SchedulerToFrontend: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+			m.QuerierID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 8:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field DispatchTimestampMs", wireType)
 			}
-			m.Status = 0
+			m.DispatchTimestampMs = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowScheduler
@@ -2012,16 +3752,16 @@ func (m *SchedulerToFrontend) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Status |= SchedulerToFrontendStatus(b&0x7F) << shift
+				m.DispatchTimestampMs |= int64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedSeriesCount", wireType)
 			}
-			var stringLen uint64
+			m.FetchedSeriesCount = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowScheduler
@@ -2031,24 +3771,11 @@ func (m *SchedulerToFrontend) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				m.FetchedSeriesCount |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthScheduler
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthScheduler
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Error = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipScheduler(dAtA[iNdEx:])
@@ -2073,7 +3800,7 @@ func (m *SchedulerToFrontend) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *NotifyQuerierShutdownRequest) Unmarshal(dAtA []byte) error {
+func (m *CancelActiveQueryRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2096,15 +3823,15 @@ func (m *NotifyQuerierShutdownRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: NotifyQuerierShutdownRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: CancelActiveQueryRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: NotifyQuerierShutdownRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: CancelActiveQueryRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field QuerierID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field FrontendAddress", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -2132,8 +3859,27 @@ func (m *NotifyQuerierShutdownRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.QuerierID = string(dAtA[iNdEx:postIndex])
+			m.FrontendAddress = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryID", wireType)
+			}
+			m.QueryID = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.QueryID |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipScheduler(dAtA[iNdEx:])
@@ -2158,7 +3904,7 @@ func (m *NotifyQuerierShutdownRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *NotifyQuerierShutdownResponse) Unmarshal(dAtA []byte) error {
+func (m *CancelActiveQueryResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2181,12 +3927,32 @@ func (m *NotifyQuerierShutdownResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: NotifyQuerierShutdownResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: CancelActiveQueryResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: NotifyQuerierShutdownResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: CancelActiveQueryResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Found", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Found = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipScheduler(dAtA[iNdEx:])
@@ -25,6 +25,8 @@ const (
 	IndexCompressedFilename = IndexFilename + ".gz"
 	IndexVersion1           = 1
 	IndexVersion2           = 2 // Added CompactorShardID field.
+	IndexVersion3           = 3 // Added Resolution field.
+	IndexVersion4           = 4 // Added Stats field.
 	SegmentsFormatUnknown   = ""
 
 	// SegmentsFormat1Based6Digits defined segments numbered with 6 digits numbers in a sequence starting from number 1
@@ -91,6 +93,40 @@ type Block struct {
 
 	// Block's compactor shard ID, copied from tsdb.CompactorShardIDExternalLabel label.
 	CompactorShardID string `json:"compactor_shard_id,omitempty"`
+
+	// Block's downsampling resolution (millis precision), copied from meta.json.
+	// Raw blocks have resolution 0.
+	Resolution int64 `json:"resolution,omitempty"`
+
+	// Block's stats, copied from meta.json. The top label names are only available if the
+	// updater has been configured to read them from the block index.
+	Stats *BlockStats `json:"stats,omitempty"`
+}
+
+// BlockStats holds the number of series, samples and chunks in a block, and the label names
+// with the highest number of values.
+type BlockStats struct {
+	NumSeries  uint64 `json:"num_series"`
+	NumSamples uint64 `json:"num_samples"`
+	NumChunks  uint64 `json:"num_chunks"`
+
+	// TopLabelNames is sorted by decreasing number of values.
+	TopLabelNames []LabelNameStats `json:"top_label_names,omitempty"`
+
+	// TopLabelNamesFailedAt is a unix timestamp (seconds precision) of the last failure to read
+	// the top label names, used to delay reading them again.
+	TopLabelNamesFailedAt int64 `json:"top_label_names_failed_at,omitempty"`
+}
+
+// LabelNameStats holds the cardinality of a label name in a block.
+type LabelNameStats struct {
+	Name string `json:"name"`
+
+	// Values is the number of distinct values of the label name.
+	Values uint64 `json:"values"`
+
+	// Series is the number of series with the label name, estimated from the size of the postings.
+	Series uint64 `json:"series"`
 }
 
 // Within returns whether the block contains samples within the provided range.
@@ -108,7 +144,7 @@ func (m *Block) GetUploadedAt() time.Time {
 // The returned meta doesn't include all original meta.json data but only a subset
 // of it.
 func (m *Block) ThanosMeta() *metadata.Meta {
-	return &metadata.Meta{
+	meta := &metadata.Meta{
 		BlockMeta: tsdb.BlockMeta{
 			ULID:    m.ID,
 			MinTime: m.MinTime,
@@ -117,9 +153,20 @@ func (m *Block) ThanosMeta() *metadata.Meta {
 		},
 		Thanos: metadata.Thanos{
 			Version:      metadata.ThanosVersion1,
+			Downsample:   metadata.ThanosDownsample{Resolution: m.Resolution},
 			SegmentFiles: m.thanosMetaSegmentFiles(),
 		},
 	}
+
+	if m.Stats != nil {
+		meta.Stats = tsdb.BlockStats{
+			NumSeries:  m.Stats.NumSeries,
+			NumSamples: m.Stats.NumSamples,
+			NumChunks:  m.Stats.NumChunks,
+		}
+	}
+
+	return meta
 }
 
 func (m *Block) thanosMetaSegmentFiles() (files []string) {
@@ -141,6 +188,10 @@ func (m *Block) String() string {
 		shard = "none"
 	}
 
+	if m.Resolution > 0 {
+		return fmt.Sprintf("%s (min time: %s max time: %s, compactor shard: %s, resolution: %s)", m.ID, minT.String(), maxT.String(), shard, time.Duration(m.Resolution)*time.Millisecond)
+	}
+
 	return fmt.Sprintf("%s (min time: %s max time: %s, compactor shard: %s)", m.ID, minT.String(), maxT.String(), shard)
 }
 
@@ -154,6 +205,21 @@ func BlockFromThanosMeta(meta metadata.Meta) *Block {
 		SegmentsFormat:   segmentsFormat,
 		SegmentsNum:      segmentsNum,
 		CompactorShardID: meta.Thanos.Labels[mimir_tsdb.CompactorShardIDExternalLabel],
+		Resolution:       meta.Thanos.Downsample.Resolution,
+		Stats:            blockStatsFromThanosMeta(meta),
+	}
+}
+
+func blockStatsFromThanosMeta(meta metadata.Meta) *BlockStats {
+	// Blocks uploaded without stats in the meta.json have no stats in the index either.
+	if meta.Stats.NumSeries == 0 && meta.Stats.NumSamples == 0 && meta.Stats.NumChunks == 0 {
+		return nil
+	}
+
+	return &BlockStats{
+		NumSeries:  meta.Stats.NumSeries,
+		NumSamples: meta.Stats.NumSamples,
+		NumChunks:  meta.Stats.NumChunks,
 	}
 }
 
@@ -272,6 +272,40 @@ func TestBlockFromThanosMeta(t *testing.T) {
 				CompactorShardID: "some weird value",
 			},
 		},
+		"meta.json of a downsampled block": {
+			meta: metadata.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+				},
+				Thanos: metadata.Thanos{
+					Downsample: metadata.ThanosDownsample{Resolution: 300000},
+				},
+			},
+			expected: Block{
+				ID:         blockID,
+				MinTime:    10,
+				MaxTime:    20,
+				Resolution: 300000,
+			},
+		},
+		"meta.json with stats": {
+			meta: metadata.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+					Stats:   tsdb.BlockStats{NumSeries: 1, NumSamples: 2, NumChunks: 3},
+				},
+			},
+			expected: Block{
+				ID:      blockID,
+				MinTime: 10,
+				MaxTime: 20,
+				Stats:   &BlockStats{NumSeries: 1, NumSamples: 2, NumChunks: 3},
+			},
+		},
 	}
 
 	for testName, testData := range tests {
@@ -378,6 +412,51 @@ func TestBlock_ThanosMeta(t *testing.T) {
 				},
 			},
 		},
+		"downsampled block": {
+			block: Block{
+				ID:         blockID,
+				MinTime:    10,
+				MaxTime:    20,
+				Resolution: 300000,
+			},
+			expected: &metadata.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+					Version: metadata.TSDBVersion1,
+				},
+				Thanos: metadata.Thanos{
+					Version:    metadata.ThanosVersion1,
+					Downsample: metadata.ThanosDownsample{Resolution: 300000},
+				},
+			},
+		},
+		"block with stats": {
+			block: Block{
+				ID:      blockID,
+				MinTime: 10,
+				MaxTime: 20,
+				Stats: &BlockStats{
+					NumSeries:     1,
+					NumSamples:    2,
+					NumChunks:     3,
+					TopLabelNames: []LabelNameStats{{Name: "pod", Values: 1, Series: 1}},
+				},
+			},
+			expected: &metadata.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+					Version: metadata.TSDBVersion1,
+					Stats:   tsdb.BlockStats{NumSeries: 1, NumSamples: 2, NumChunks: 3},
+				},
+				Thanos: metadata.Thanos{
+					Version: metadata.ThanosVersion1,
+				},
+			},
+		},
 	}
 
 	for testName, testData := range tests {
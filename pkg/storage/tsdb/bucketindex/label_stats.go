@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketindex
+
+import (
+	"context"
+	"hash/crc32"
+	"io"
+	"path"
+	"sort"
+
+	"github.com/grafana/dskit/runutil"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/tsdb/encoding"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"github.com/thanos-io/objstore"
+	"github.com/thanos-io/thanos/pkg/block"
+)
+
+const (
+	indexTOCLen = 6*8 + crc32.Size
+
+	// postingsListOverhead is the size of the length, the number of entries and the CRC32 of a postings list.
+	postingsListOverhead = 3 * 4
+)
+
+// readTopLabelNames reads the postings offset table of the block index and returns the n label names
+// with the highest number of values. The number of series of each label name is estimated from the
+// size of its postings lists, which avoids reading the postings.
+func readTopLabelNames(ctx context.Context, bkt objstore.BucketReader, id ulid.ULID, n int) ([]LabelNameStats, error) {
+	indexFile := path.Join(id.String(), block.IndexFilename)
+
+	attrs, err := bkt.Attributes(ctx, indexFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read index file attributes: %v", indexFile)
+	}
+	if attrs.Size < indexTOCLen {
+		return nil, errors.Wrapf(encoding.ErrInvalidSize, "read index file: %v", indexFile)
+	}
+
+	tocBytes, err := readRange(ctx, bkt, indexFile, attrs.Size-indexTOCLen, indexTOCLen)
+	if err != nil {
+		return nil, err
+	}
+	toc, err := index.NewTOCFromByteSlice(offsetByteSlice{b: tocBytes, off: int(attrs.Size - indexTOCLen)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "read index TOC: %v", indexFile)
+	}
+	if toc.LabelIndicesTable < toc.Postings || toc.PostingsTable < toc.LabelIndicesTable || toc.PostingsTable > uint64(attrs.Size-indexTOCLen) {
+		return nil, errors.Errorf("invalid postings offset table position in index file: %v", indexFile)
+	}
+
+	tableBytes, err := readRange(ctx, bkt, indexFile, int64(toc.PostingsTable), attrs.Size-indexTOCLen-int64(toc.PostingsTable))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		stats    []LabelNameStats
+		last     *LabelNameStats
+		lastOff  uint64
+		hasValue bool
+	)
+
+	// The size of a postings list is the distance from the next one, because they're stored in the same
+	// order as the postings offset table, which is sorted by label name and value.
+	addPostingsSize := func(nextOff uint64) {
+		if !hasValue || nextOff < lastOff+postingsListOverhead {
+			return
+		}
+		last.Series += (nextOff - lastOff - postingsListOverhead) / 4
+	}
+
+	err = index.ReadOffsetTable(offsetByteSlice{b: tableBytes, off: int(toc.PostingsTable)}, toc.PostingsTable, func(key []string, off uint64, _ int) error {
+		if len(key) != 2 {
+			return errors.Errorf("unexpected key length for posting table %d", len(key))
+		}
+
+		addPostingsSize(off)
+		lastOff, hasValue = off, true
+
+		// Skip the postings of all series, which are stored with an empty label name.
+		if key[0] == "" {
+			hasValue = false
+			return nil
+		}
+
+		if last == nil || last.Name != key[0] {
+			stats = append(stats, LabelNameStats{Name: key[0]})
+			last = &stats[len(stats)-1]
+		}
+		last.Values++
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "read postings offset table: %v", indexFile)
+	}
+	// The postings section is followed by the label indices table.
+	addPostingsSize(toc.LabelIndicesTable)
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Values > stats[j].Values
+	})
+	if len(stats) > n {
+		stats = stats[:n]
+	}
+
+	return stats, nil
+}
+
+func readRange(ctx context.Context, bkt objstore.BucketReader, name string, off, length int64) (_ []byte, returnErr error) {
+	r, err := bkt.GetRange(ctx, name, off, length)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get range of index file: %v", name)
+	}
+	defer runutil.CloseWithErrCapture(&returnErr, r, "close index file range reader")
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read range of index file: %v", name)
+	}
+	if int64(len(b)) != length {
+		return nil, errors.Wrapf(encoding.ErrInvalidSize, "read range of index file: %v", name)
+	}
+	return b, nil
+}
+
+// offsetByteSlice is an index.ByteSlice holding the bytes of the index file starting at off.
+// The bytes before off can't be read.
+type offsetByteSlice struct {
+	b   []byte
+	off int
+}
+
+func (s offsetByteSlice) Len() int {
+	return s.off + len(s.b)
+}
+
+func (s offsetByteSlice) Range(start, end int) []byte {
+	return s.b[start-s.off : end-s.off]
+}
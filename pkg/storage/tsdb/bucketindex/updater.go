@@ -10,10 +10,12 @@ import (
 	"encoding/json"
 	"io"
 	"path"
+	"sort"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
 	"github.com/grafana/dskit/runutil"
 	"github.com/oklog/ulid"
 	"github.com/pkg/errors"
@@ -32,10 +34,21 @@ var (
 	ErrBlockDeletionMarkCorrupted = errors.New("block deletion mark corrupted")
 )
 
+// topLabelNamesRetryDelay is how long to wait before reading again the top label names of a block
+// after failing to read them.
+const topLabelNamesRetryDelay = 24 * time.Hour
+
 // Updater is responsible to generate an update in-memory bucket index.
 type Updater struct {
 	bkt    objstore.InstrumentedBucket
 	logger log.Logger
+
+	// Number of top label names to store in the block stats. 0 to disable.
+	topLabelNames int
+	// Max number of blocks to read the top label names from at every update (0 for no limit),
+	// and how many of them are read concurrently.
+	topLabelNamesMaxBlocks   int
+	topLabelNamesConcurrency int
 }
 
 func NewUpdater(bkt objstore.Bucket, userID string, cfgProvider bucket.TenantConfigProvider, logger log.Logger) *Updater {
@@ -45,6 +58,18 @@ func NewUpdater(bkt objstore.Bucket, userID string, cfgProvider bucket.TenantCon
 	}
 }
 
+// WithTopLabelNames configures the updater to read the n label names with the highest number of values
+// from the index of each block, and store them in the block stats. Blocks already in the index without
+// the top label names are updated too. Reading the label names requires to fetch the postings offset
+// table of the block index from the storage, so at most maxBlocks blocks are read at every update,
+// with the given concurrency.
+func (w *Updater) WithTopLabelNames(n, maxBlocks, concurrency int) *Updater {
+	w.topLabelNames = n
+	w.topLabelNamesMaxBlocks = maxBlocks
+	w.topLabelNamesConcurrency = concurrency
+	return w
+}
+
 // UpdateIndex generates the bucket index and returns it, without storing it to the storage.
 // If the old index is not passed in input, then the bucket index will be generated from scratch.
 func (w *Updater) UpdateIndex(ctx context.Context, old *Index) (*Index, map[ulid.ULID]error, error) {
@@ -52,7 +77,7 @@ func (w *Updater) UpdateIndex(ctx context.Context, old *Index) (*Index, map[ulid
 	var oldBlockDeletionMarks []*BlockDeletionMark
 
 	// Use the old index if provided, and it is using the latest version format.
-	if old != nil && old.Version == IndexVersion2 {
+	if old != nil && old.Version == IndexVersion4 {
 		oldBlocks = old.Blocks
 		oldBlockDeletionMarks = old.BlockDeletionMarks
 	}
@@ -62,13 +87,15 @@ func (w *Updater) UpdateIndex(ctx context.Context, old *Index) (*Index, map[ulid
 		return nil, nil, err
 	}
 
+	w.updateTopLabelNames(ctx, blocks)
+
 	blockDeletionMarks, err := w.updateBlockDeletionMarks(ctx, oldBlockDeletionMarks)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	return &Index{
-		Version:            IndexVersion2,
+		Version:            IndexVersion4,
 		Blocks:             blocks,
 		BlockDeletionMarks: blockDeletionMarks,
 		UpdatedAt:          time.Now().Unix(),
@@ -168,6 +195,66 @@ func (w *Updater) updateBlockIndexEntry(ctx context.Context, id ulid.ULID) (*Blo
 	return block, nil
 }
 
+// updateTopLabelNames reads the top label names of the blocks missing them, if they're enabled, replacing the
+// blocks in place. Only the most recent blocks are read at every update, up to the configured max, so the top
+// label names of a tenant with many blocks are read across several updates. Blocks which failed to be read are
+// retried after topLabelNamesRetryDelay.
+func (w *Updater) updateTopLabelNames(ctx context.Context, blocks []*Block) {
+	if w.topLabelNames <= 0 {
+		return
+	}
+
+	retryFailedBefore := time.Now().Add(-topLabelNamesRetryDelay).Unix()
+	var pending []int
+	for i, b := range blocks {
+		if b.Stats == nil || len(b.Stats.TopLabelNames) > 0 || b.Stats.TopLabelNamesFailedAt > retryFailedBefore {
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	// The most recent blocks are the most likely to be queried.
+	sort.Slice(pending, func(i, j int) bool {
+		return blocks[pending[i]].MaxTime > blocks[pending[j]].MaxTime
+	})
+	if w.topLabelNamesMaxBlocks > 0 && len(pending) > w.topLabelNamesMaxBlocks {
+		level.Info(w.logger).Log("msg", "reading top label names of a subset of the blocks missing them", "blocks", w.topLabelNamesMaxBlocks, "remaining", len(pending)-w.topLabelNamesMaxBlocks)
+		pending = pending[:w.topLabelNamesMaxBlocks]
+	}
+
+	// Each job replaces a different block, so there's no need to synchronize them.
+	_ = concurrency.ForEachJob(ctx, len(pending), w.topLabelNamesConcurrency, func(ctx context.Context, idx int) error {
+		i := pending[idx]
+		blocks[i] = w.updateBlockTopLabelNames(ctx, blocks[i])
+		return nil
+	})
+}
+
+// updateBlockTopLabelNames returns the block with the top label names in its stats. Failing to read them
+// doesn't fail the update of the index: the time of the failure is recorded in the stats instead.
+func (w *Updater) updateBlockTopLabelNames(ctx context.Context, b *Block) *Block {
+	// Blocks in the old index are shared with its readers, so they're never modified in place.
+	updated := *b
+	stats := *b.Stats
+	updated.Stats = &stats
+
+	names, err := readTopLabelNames(ctx, w.bkt, b.ID, w.topLabelNames)
+	if err != nil {
+		// Don't record the failure if the update has been canceled.
+		if ctx.Err() != nil {
+			return b
+		}
+
+		level.Warn(w.logger).Log("msg", "failed to read top label names when updating bucket index", "block", b.ID.String(), "err", err)
+		stats.TopLabelNamesFailedAt = time.Now().Unix()
+		return &updated
+	}
+
+	stats.TopLabelNames = names
+	stats.TopLabelNamesFailedAt = 0
+	return &updated
+}
+
 func (w *Updater) updateBlockDeletionMarks(ctx context.Context, old []*BlockDeletionMark) ([]*BlockDeletionMark, error) {
 	out := make([]*BlockDeletionMark, 0, len(old))
 	discovered := map[ulid.ULID]struct{}{}
@@ -8,13 +8,20 @@ package bucketindex
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"os"
 	"path"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/oklog/ulid"
 	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/thanos-io/objstore"
@@ -168,7 +175,7 @@ func TestUpdater_UpdateIndex_NoTenantInTheBucket(t *testing.T) {
 		idx, partials, err := w.UpdateIndex(ctx, oldIdx)
 
 		require.NoError(t, err)
-		assert.Equal(t, IndexVersion2, idx.Version)
+		assert.Equal(t, IndexVersion4, idx.Version)
 		assert.InDelta(t, time.Now().Unix(), idx.UpdatedAt, 2)
 		assert.Len(t, idx.Blocks, 0)
 		assert.Len(t, idx.BlockDeletionMarks, 0)
@@ -239,7 +246,7 @@ func getBlockUploadedAt(t testing.TB, bkt objstore.Bucket, userID string, blockI
 }
 
 func assertBucketIndexEqual(t testing.TB, idx *Index, bkt objstore.Bucket, userID string, expectedBlocks []metadata.Meta, expectedDeletionMarks []*metadata.DeletionMark) {
-	assert.Equal(t, IndexVersion2, idx.Version)
+	assert.Equal(t, IndexVersion4, idx.Version)
 	assert.InDelta(t, time.Now().Unix(), idx.UpdatedAt, 2)
 
 	// Build the list of expected block index entries.
@@ -267,3 +274,147 @@ func assertBucketIndexEqual(t testing.TB, idx *Index, bkt objstore.Bucket, userI
 
 	assert.ElementsMatch(t, expectedMarkEntries, idx.BlockDeletionMarks)
 }
+
+func TestUpdater_UpdateIndex_ShouldReadTopLabelNames(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	bkt, storageDir := testutil.PrepareFilesystemBucket(t)
+
+	// Generate a block with 10 series: "pod" has 10 values, "zone" 2 values, and "__name__" 1 value.
+	var specs testutil.BlockSeriesSpecs
+	for i := 0; i < 10; i++ {
+		chk := chunkenc.NewXORChunk()
+		app, err := chk.Appender()
+		require.NoError(t, err)
+		app.Append(int64(i), float64(i))
+
+		specs = append(specs, &testutil.BlockSeriesSpec{
+			Labels: labels.FromStrings(labels.MetricName, "up", "pod", fmt.Sprintf("pod-%d", i), "zone", fmt.Sprintf("zone-%d", i%2)),
+			Chunks: []chunks.Meta{{Chunk: chk, MinTime: int64(i), MaxTime: int64(i)}},
+		})
+	}
+
+	meta, err := testutil.GenerateBlockFromSpec(userID, filepath.Join(storageDir, userID), specs)
+	require.NoError(t, err)
+
+	// Blocks without stats in the meta.json are skipped.
+	w := NewUpdater(bkt, userID, nil, log.NewNopLogger()).WithTopLabelNames(2, 0, 1)
+	idx, _, err := w.UpdateIndex(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, idx.Blocks, 1)
+	assert.Nil(t, idx.Blocks[0].Stats)
+
+	meta.Stats = tsdb.BlockStats{NumSeries: 10, NumSamples: 10, NumChunks: 10}
+	require.NoError(t, meta.WriteToDir(log.NewNopLogger(), filepath.Join(storageDir, userID, meta.ULID.String())))
+
+	// The top label names are read for blocks already in the index.
+	idx, _, err = NewUpdater(bkt, userID, nil, log.NewNopLogger()).UpdateIndex(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, idx.Blocks, 1)
+	assert.Equal(t, &BlockStats{NumSeries: 10, NumSamples: 10, NumChunks: 10}, idx.Blocks[0].Stats)
+
+	idx, _, err = w.UpdateIndex(ctx, idx)
+	require.NoError(t, err)
+	require.Len(t, idx.Blocks, 1)
+	assert.Equal(t, &BlockStats{
+		NumSeries:  10,
+		NumSamples: 10,
+		NumChunks:  10,
+		TopLabelNames: []LabelNameStats{
+			{Name: "pod", Values: 10, Series: 10},
+			{Name: "zone", Values: 2, Series: 10},
+		},
+	}, idx.Blocks[0].Stats)
+}
+
+func TestUpdater_UpdateIndex_ShouldBoundTopLabelNamesReads(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	bkt, storageDir := testutil.PrepareFilesystemBucket(t)
+
+	// Generate blocks with 2 series each, covering increasing time ranges.
+	generateBlock := func(ts int64) ulid.ULID {
+		var specs testutil.BlockSeriesSpecs
+		for i := 0; i < 2; i++ {
+			chk := chunkenc.NewXORChunk()
+			app, err := chk.Appender()
+			require.NoError(t, err)
+			app.Append(ts, float64(i))
+
+			specs = append(specs, &testutil.BlockSeriesSpec{
+				Labels: labels.FromStrings(labels.MetricName, "up", "pod", fmt.Sprintf("pod-%d", i)),
+				Chunks: []chunks.Meta{{Chunk: chk, MinTime: ts, MaxTime: ts}},
+			})
+		}
+
+		meta, err := testutil.GenerateBlockFromSpec(userID, filepath.Join(storageDir, userID), specs)
+		require.NoError(t, err)
+		meta.Stats = tsdb.BlockStats{NumSeries: 2, NumSamples: 2, NumChunks: 2}
+		require.NoError(t, meta.WriteToDir(log.NewNopLogger(), filepath.Join(storageDir, userID, meta.ULID.String())))
+		return meta.ULID
+	}
+
+	topLabelNamesByBlock := func(idx *Index) map[ulid.ULID][]LabelNameStats {
+		res := map[ulid.ULID][]LabelNameStats{}
+		for _, b := range idx.Blocks {
+			res[b.ID] = b.Stats.TopLabelNames
+		}
+		return res
+	}
+
+	block1 := generateBlock(10)
+	block2 := generateBlock(20)
+	block3 := generateBlock(30)
+	expectedTopLabelNames := []LabelNameStats{{Name: "pod", Values: 2, Series: 2}}
+
+	w := NewUpdater(bkt, userID, nil, log.NewNopLogger()).WithTopLabelNames(1, 2, 2)
+
+	// Only the most recent blocks are read at the first update.
+	idx, _, err := w.UpdateIndex(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[ulid.ULID][]LabelNameStats{
+		block1: nil,
+		block2: expectedTopLabelNames,
+		block3: expectedTopLabelNames,
+	}, topLabelNamesByBlock(idx))
+
+	// The remaining blocks are read at the next update.
+	idx, _, err = w.UpdateIndex(ctx, idx)
+	require.NoError(t, err)
+	assert.Equal(t, map[ulid.ULID][]LabelNameStats{
+		block1: expectedTopLabelNames,
+		block2: expectedTopLabelNames,
+		block3: expectedTopLabelNames,
+	}, topLabelNamesByBlock(idx))
+
+	// Failing to read a block is recorded in the index.
+	block4 := generateBlock(40)
+	indexFile := filepath.Join(storageDir, userID, block4.String(), block.IndexFilename)
+	require.NoError(t, os.Rename(indexFile, indexFile+".bak"))
+
+	idx, _, err = w.UpdateIndex(ctx, idx)
+	require.NoError(t, err)
+	assert.Nil(t, idx.Blocks[3].Stats.TopLabelNames)
+	failedAt := idx.Blocks[3].Stats.TopLabelNamesFailedAt
+	assert.NotZero(t, failedAt)
+
+	// The block isn't read again at the next update, even if it could be read now.
+	require.NoError(t, os.Rename(indexFile+".bak", indexFile))
+
+	idx, _, err = w.UpdateIndex(ctx, idx)
+	require.NoError(t, err)
+	assert.Nil(t, idx.Blocks[3].Stats.TopLabelNames)
+	assert.Equal(t, failedAt, idx.Blocks[3].Stats.TopLabelNamesFailedAt)
+
+	// The block is read again once the retry delay has elapsed.
+	stats := *idx.Blocks[3].Stats
+	stats.TopLabelNamesFailedAt = time.Now().Add(-topLabelNamesRetryDelay - time.Minute).Unix()
+	idx.Blocks[3].Stats = &stats
+
+	idx, _, err = w.UpdateIndex(ctx, idx)
+	require.NoError(t, err)
+	assert.Equal(t, expectedTopLabelNames, idx.Blocks[3].Stats.TopLabelNames)
+	assert.Zero(t, idx.Blocks[3].Stats.TopLabelNamesFailedAt)
+}
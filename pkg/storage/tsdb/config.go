@@ -76,6 +76,8 @@ var (
 	errInvalidWALSegmentSizeBytes   = errors.New("invalid TSDB WAL segment size bytes")
 	errInvalidStripeSize            = errors.New("invalid TSDB stripe size")
 	errEmptyBlockranges             = errors.New("empty block ranges for TSDB")
+
+	errInvalidPostingsPlanningLazyMatchersRatio = errors.New("invalid postings planning lazy matchers ratio, must be greater than or equal to 1")
 )
 
 // BlocksStorageConfig holds the config information for the blocks storage.
@@ -302,6 +304,10 @@ type BucketStoreConfig struct {
 
 	// Controls what to do when MaxConcurrent is exceeded: fail immediately or wait for a slot to run.
 	MaxConcurrentRejectOverLimit bool `yaml:"max_concurrent_reject_over_limit" category:"experimental"`
+
+	// Controls which matchers are applied by intersecting postings, and which ones by filtering series labels.
+	PostingsPlanningEnabled           bool    `yaml:"postings_planning_enabled" category:"experimental"`
+	PostingsPlanningLazyMatchersRatio float64 `yaml:"postings_planning_lazy_matchers_ratio" category:"experimental"`
 }
 
 // RegisterFlags registers the BucketStore flags
@@ -331,6 +337,8 @@ func (cfg *BucketStoreConfig) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.IndexHeaderLazyLoadingEnabled, "blocks-storage.bucket-store.index-header-lazy-loading-enabled", true, "If enabled, store-gateway will lazy load an index-header only once required by a query.")
 	f.DurationVar(&cfg.IndexHeaderLazyLoadingIdleTimeout, "blocks-storage.bucket-store.index-header-lazy-loading-idle-timeout", 60*time.Minute, "If index-header lazy loading is enabled and this setting is > 0, the store-gateway will offload unused index-headers after 'idle timeout' inactivity.")
 	f.Uint64Var(&cfg.PartitionerMaxGapBytes, "blocks-storage.bucket-store.partitioner-max-gap-bytes", DefaultPartitionerMaxGapSize, "Max size - in bytes - of a gap for which the partitioner aggregates together two bucket GET object requests.")
+	f.BoolVar(&cfg.PostingsPlanningEnabled, "blocks-storage.bucket-store.postings-planning-enabled", false, "If enabled, the store-gateway estimates the postings size of each matcher of a query from the index-header, and applies the matchers with huge postings by filtering the labels of the series selected by the other matchers, instead of fetching their postings.")
+	f.Float64Var(&cfg.PostingsPlanningLazyMatchersRatio, "blocks-storage.bucket-store.postings-planning-lazy-matchers-ratio", 16, "When postings planning is enabled, a matcher is applied by filtering series labels if its estimated postings size is greater than this ratio multiplied by the estimated postings size of the most selective matcher. A series takes more space than its postings in the index, so the ratio should be greater than 1.")
 }
 
 // Validate the config.
@@ -347,6 +355,9 @@ func (cfg *BucketStoreConfig) Validate() error {
 	if err != nil {
 		return errors.Wrap(err, "metadata-cache configuration")
 	}
+	if cfg.PostingsPlanningEnabled && cfg.PostingsPlanningLazyMatchersRatio < 1 {
+		return errInvalidPostingsPlanningLazyMatchersRatio
+	}
 	return nil
 }
 
@@ -121,6 +121,19 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			expectedErr: errInvalidWALSegmentSizeBytes,
 		},
+		"should fail on postings planning lazy matchers ratio lower than 1": {
+			setup: func(cfg *BlocksStorageConfig) {
+				cfg.BucketStore.PostingsPlanningEnabled = true
+				cfg.BucketStore.PostingsPlanningLazyMatchersRatio = 0.5
+			},
+			expectedErr: errInvalidPostingsPlanningLazyMatchersRatio,
+		},
+		"should pass on postings planning lazy matchers ratio lower than 1 if planning is disabled": {
+			setup: func(cfg *BlocksStorageConfig) {
+				cfg.BucketStore.PostingsPlanningLazyMatchersRatio = 0.5
+			},
+			expectedErr: nil,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/thanos-io/thanos/blob/main/pkg/compact/downsample/downsample.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Thanos Authors.
+
+package downsample
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+)
+
+// ChunkEncAggr is the top level encoding byte for the AggrChunk.
+// It picks the highest number possible to prevent future collisions with wrapped encodings.
+const ChunkEncAggr = chunkenc.Encoding(0xff)
+
+// AggrType represents an aggregation type.
+type AggrType uint8
+
+// Valid aggregations.
+const (
+	AggrCount AggrType = iota
+	AggrSum
+	AggrMin
+	AggrMax
+	AggrCounter
+)
+
+// numAggrTypes is the number of aggregations stored in an AggrChunk.
+const numAggrTypes = int(AggrCounter) + 1
+
+func (t AggrType) String() string {
+	switch t {
+	case AggrCount:
+		return "count"
+	case AggrSum:
+		return "sum"
+	case AggrMin:
+		return "min"
+	case AggrMax:
+		return "max"
+	case AggrCounter:
+		return "counter"
+	}
+	return "<unknown>"
+}
+
+// ErrAggrNotExist is returned if a requested aggregation is not present in an AggrChunk.
+var ErrAggrNotExist = errors.New("aggregate does not exist")
+
+// AggrChunk is a chunk that is composed of a set of aggregates for the same underlying data.
+// Not all aggregates must be present.
+type AggrChunk []byte
+
+// EncodeAggrChunk encodes a new aggregate chunk from the array of chunks for each aggregate.
+// Each array entry corresponds to the respective AggrType number.
+func EncodeAggrChunk(chks [numAggrTypes]chunkenc.Chunk) AggrChunk {
+	var b []byte
+	buf := [binary.MaxVarintLen64]byte{}
+
+	for _, c := range chks {
+		// Unset aggregates are marked with a zero length entry.
+		if c == nil {
+			n := binary.PutUvarint(buf[:], 0)
+			b = append(b, buf[:n]...)
+			continue
+		}
+		l := len(c.Bytes())
+		n := binary.PutUvarint(buf[:], uint64(l))
+		b = append(b, buf[:n]...)
+		b = append(b, byte(c.Encoding()))
+		b = append(b, c.Bytes()...)
+	}
+	return b
+}
+
+// Get returns the sub-chunk for the given aggregate type if it exists.
+func (c AggrChunk) Get(t AggrType) (chunkenc.Chunk, error) {
+	if int(t) >= numAggrTypes {
+		return nil, errors.Errorf("unknown aggregate type %d", t)
+	}
+
+	b := c[:]
+	var x []byte
+
+	for i := AggrType(0); i <= t; i++ {
+		l, n := binary.Uvarint(b)
+		if n < 1 {
+			return nil, errors.New("invalid size")
+		}
+		b = b[n:]
+
+		// If length is set to zero explicitly, that means the aggregate is unset.
+		if l == 0 {
+			if i == t {
+				return nil, ErrAggrNotExist
+			}
+			continue
+		}
+		if len(b) < int(l)+1 {
+			return nil, errors.New("invalid size")
+		}
+		x = b[:int(l)+1]
+		b = b[int(l)+1:]
+	}
+	return chunkenc.FromData(chunkenc.Encoding(x[0]), x[1:])
+}
+
+// Bytes implements chunkenc.Chunk.
+func (c AggrChunk) Bytes() []byte {
+	return c
+}
+
+// Encoding implements chunkenc.Chunk.
+func (c AggrChunk) Encoding() chunkenc.Encoding {
+	return ChunkEncAggr
+}
+
+// Appender implements chunkenc.Chunk. Aggregate chunks are immutable.
+func (c AggrChunk) Appender() (chunkenc.Appender, error) {
+	return nil, errors.New("not implemented")
+}
+
+// Iterator implements chunkenc.Chunk. The samples of an aggregate chunk can only be iterated
+// through the sub-chunk of an aggregate, see Get.
+func (c AggrChunk) Iterator(_ chunkenc.Iterator) chunkenc.Iterator {
+	return errIterator{err: errors.New("aggregate chunks can't be iterated, get the chunk of an aggregate instead")}
+}
+
+// NumSamples implements chunkenc.Chunk. It returns the number of samples of the count aggregate.
+func (c AggrChunk) NumSamples() int {
+	x, err := c.Get(AggrCount)
+	if err != nil {
+		return 0
+	}
+	return x.NumSamples()
+}
+
+// Compact implements chunkenc.Chunk.
+func (c AggrChunk) Compact() {}
+
+type errIterator struct {
+	err error
+}
+
+func (it errIterator) Next() bool           { return false }
+func (it errIterator) Seek(int64) bool      { return false }
+func (it errIterator) At() (int64, float64) { return 0, 0 }
+func (it errIterator) Err() error           { return it.err }
+
+// pool is a chunkenc.Pool supporting aggregate chunks in addition to the chunk encodings supported by Prometheus.
+type pool struct {
+	wrapped chunkenc.Pool
+}
+
+// NewPool returns a chunkenc.Pool which must be used to read the chunks of downsampled blocks.
+func NewPool() chunkenc.Pool {
+	return &pool{wrapped: chunkenc.NewPool()}
+}
+
+func (p *pool) Get(e chunkenc.Encoding, b []byte) (chunkenc.Chunk, error) {
+	if e == ChunkEncAggr {
+		return AggrChunk(b), nil
+	}
+	return p.wrapped.Get(e, b)
+}
+
+func (p *pool) Put(c chunkenc.Chunk) error {
+	if c.Encoding() == ChunkEncAggr {
+		// Aggregate chunks are not pooled.
+		return nil
+	}
+	return p.wrapped.Put(c)
+}
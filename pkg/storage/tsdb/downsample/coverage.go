@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package downsample
+
+// BlockRef describes a block considered when selecting the resolution to query a time range at.
+type BlockRef struct {
+	// MinTime and MaxTime specify the half-open time range [MinTime, MaxTime) of the block.
+	MinTime int64
+	MaxTime int64
+
+	Resolution       int64
+	CompactorShardID string
+}
+
+type blockCoverageKey struct {
+	minTime, maxTime int64
+	compactorShardID string
+}
+
+func (b BlockRef) coverageKey() blockCoverageKey {
+	return blockCoverageKey{minTime: b.MinTime, maxTime: b.MaxTime, compactorShardID: b.CompactorShardID}
+}
+
+// IncompleteBlocks returns the indexes of the downsampled blocks which can't be queried in place of the finer
+// resolution blocks overlapping their time range, because not all of them have been downsampled yet.
+//
+// Downsampling preserves the time range and the compactor shard of a block, so a finer resolution block is
+// covered by a downsampled block with the same time range and compactor shard. Each downsampled block covers
+// at most one block of each finer resolution, so that a downsampled block generated from one of several
+// overlapping blocks of the same shard doesn't replace the others.
+func IncompleteBlocks(blocks []BlockRef) map[int]struct{} {
+	byResolution := make(map[int64][]int, len(Resolutions))
+	counts := make(map[int64]map[blockCoverageKey]int, len(Resolutions))
+	for i, b := range blocks {
+		byResolution[b.Resolution] = append(byResolution[b.Resolution], i)
+		if counts[b.Resolution] == nil {
+			counts[b.Resolution] = map[blockCoverageKey]int{}
+		}
+		counts[b.Resolution][b.coverageKey()]++
+	}
+
+	incomplete := map[int]struct{}{}
+	for r, res := range Resolutions {
+		if res == ResolutionRaw || len(byResolution[res]) == 0 {
+			continue
+		}
+
+		// Number of complete blocks at this resolution, by coverage key.
+		complete := make(map[blockCoverageKey]int, len(counts[res]))
+		for k, n := range counts[res] {
+			complete[k] = n
+		}
+
+		// Excluding a block may uncover finer blocks overlapping other blocks at this resolution,
+		// so iterate until no more blocks are excluded.
+		for changed := true; changed; {
+			changed = false
+
+			for _, i := range byResolution[res] {
+				if _, ok := incomplete[i]; ok {
+					continue
+				}
+				if !isBlockComplete(blocks, blocks[i], Resolutions[r+1:], byResolution, counts, complete) {
+					incomplete[i] = struct{}{}
+					complete[blocks[i].coverageKey()]--
+					changed = true
+				}
+			}
+		}
+	}
+
+	return incomplete
+}
+
+// isBlockComplete returns whether every block at the finer resolutions overlapping the time range of b is covered
+// by a complete block at the resolution of b.
+func isBlockComplete(blocks []BlockRef, b BlockRef, finerResolutions []int64, byResolution map[int64][]int, counts map[int64]map[blockCoverageKey]int, complete map[blockCoverageKey]int) bool {
+	for _, res := range finerResolutions {
+		for _, i := range byResolution[res] {
+			f := blocks[i]
+			if f.MaxTime <= b.MinTime || f.MinTime >= b.MaxTime {
+				continue
+			}
+			if k := f.coverageKey(); counts[res][k] > complete[k] {
+				return false
+			}
+		}
+	}
+	return true
+}
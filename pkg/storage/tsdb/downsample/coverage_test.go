@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package downsample
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncompleteBlocks(t *testing.T) {
+	tests := map[string]struct {
+		blocks   []BlockRef
+		expected map[int]struct{}
+	}{
+		"no downsampled blocks": {
+			blocks: []BlockRef{
+				{MinTime: 0, MaxTime: 100},
+				{MinTime: 0, MaxTime: 100},
+			},
+			expected: map[int]struct{}{},
+		},
+		"downsampled blocks without raw blocks": {
+			blocks: []BlockRef{
+				{MinTime: 0, MaxTime: 100, Resolution: Resolution5m, CompactorShardID: "1_of_2"},
+				{MinTime: 0, MaxTime: 100, Resolution: Resolution1h, CompactorShardID: "1_of_2"},
+			},
+			expected: map[int]struct{}{},
+		},
+		"all the compactor shards have been downsampled": {
+			blocks: []BlockRef{
+				{MinTime: 0, MaxTime: 100, CompactorShardID: "1_of_2"},
+				{MinTime: 0, MaxTime: 100, CompactorShardID: "2_of_2"},
+				{MinTime: 0, MaxTime: 100, Resolution: Resolution5m, CompactorShardID: "1_of_2"},
+				{MinTime: 0, MaxTime: 100, Resolution: Resolution5m, CompactorShardID: "2_of_2"},
+			},
+			expected: map[int]struct{}{},
+		},
+		"only one of the compactor shards has been downsampled": {
+			blocks: []BlockRef{
+				{MinTime: 0, MaxTime: 100, CompactorShardID: "1_of_2"},
+				{MinTime: 0, MaxTime: 100, CompactorShardID: "2_of_2"},
+				{MinTime: 0, MaxTime: 100, Resolution: Resolution5m, CompactorShardID: "1_of_2"},
+				{MinTime: 100, MaxTime: 200, CompactorShardID: "1_of_2"},
+				{MinTime: 100, MaxTime: 200, Resolution: Resolution5m, CompactorShardID: "1_of_2"},
+			},
+			expected: map[int]struct{}{2: {}},
+		},
+		"only one of the overlapping blocks has been downsampled": {
+			blocks: []BlockRef{
+				{MinTime: 0, MaxTime: 100},
+				{MinTime: 0, MaxTime: 100},
+				{MinTime: 0, MaxTime: 100, Resolution: Resolution5m},
+			},
+			expected: map[int]struct{}{2: {}},
+		},
+		"1h block missing a compactor shard downsampled to 5m": {
+			blocks: []BlockRef{
+				{MinTime: 0, MaxTime: 100, Resolution: Resolution5m, CompactorShardID: "1_of_2"},
+				{MinTime: 0, MaxTime: 100, Resolution: Resolution5m, CompactorShardID: "2_of_2"},
+				{MinTime: 0, MaxTime: 100, Resolution: Resolution1h, CompactorShardID: "1_of_2"},
+			},
+			expected: map[int]struct{}{2: {}},
+		},
+		"downsampled block overlapping an incomplete block at the same resolution": {
+			blocks: []BlockRef{
+				{MinTime: 0, MaxTime: 100},
+				{MinTime: 50, MaxTime: 150},
+				{MinTime: 0, MaxTime: 100, Resolution: Resolution5m},
+				{MinTime: 0, MaxTime: 200, Resolution: Resolution5m},
+			},
+			expected: map[int]struct{}{2: {}, 3: {}},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, IncompleteBlocks(testData.blocks))
+		})
+	}
+}
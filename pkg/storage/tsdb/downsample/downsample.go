@@ -0,0 +1,605 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/thanos-io/thanos/blob/main/pkg/compact/downsample/downsample.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Thanos Authors.
+
+package downsample
+
+import (
+	"context"
+	"crypto/rand"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/runutil"
+)
+
+// Standard downsampling resolution levels, in milliseconds.
+const (
+	ResolutionRaw = int64(0)
+	Resolution5m  = int64(5 * 60 * 1000)
+	Resolution1h  = int64(60 * 60 * 1000)
+)
+
+// Resolutions is the list of supported resolutions, from the coarsest to the finest one.
+var Resolutions = []int64{Resolution1h, Resolution5m, ResolutionRaw}
+
+// Downsample downsamples the given block to the given resolution. It writes a new block into
+// dir and returns its ID. The new block keeps the external labels and the compaction sources
+// of the original block, while its meta.json is tagged with the new resolution.
+// If the downsampled block would contain no series, an empty ULID is returned.
+func Downsample(logger log.Logger, origMeta *metadata.Meta, b tsdb.BlockReader, dir string, resolution int64) (id ulid.ULID, err error) {
+	origResolution := origMeta.Thanos.Downsample.Resolution
+	if origResolution >= resolution {
+		return id, errors.Errorf("target resolution %d is not lower than the existing one %d", resolution, origResolution)
+	}
+
+	indexr, err := b.Index()
+	if err != nil {
+		return id, errors.Wrap(err, "open index reader")
+	}
+	defer runutil.CloseWithErrCapture(&err, indexr, "downsample index reader")
+
+	chunkr, err := b.Chunks()
+	if err != nil {
+		return id, errors.Wrap(err, "open chunk reader")
+	}
+	defer runutil.CloseWithErrCapture(&err, chunkr, "downsample chunk reader")
+
+	id = ulid.MustNew(ulid.Now(), rand.Reader)
+	blockDir := filepath.Join(dir, id.String())
+
+	if err := os.MkdirAll(blockDir, 0o750); err != nil {
+		return id, errors.Wrap(err, "create block directory")
+	}
+
+	// Remove the partially written block in case of failure.
+	defer func() {
+		if err != nil {
+			if rerr := os.RemoveAll(blockDir); rerr != nil {
+				err = errors.Wrapf(err, "failed to remove the partially downsampled block: %v", rerr)
+			}
+		}
+	}()
+
+	stats, err := writeDownsampledBlock(indexr, chunkr, blockDir, origResolution, resolution)
+	if err != nil {
+		return id, err
+	}
+
+	if stats.NumSeries == 0 {
+		if err := os.RemoveAll(blockDir); err != nil {
+			return id, errors.Wrap(err, "remove empty downsampled block")
+		}
+		return ulid.ULID{}, nil
+	}
+
+	thanosMeta := origMeta.Thanos
+	thanosMeta.Labels = make(map[string]string, len(origMeta.Thanos.Labels))
+	for k, v := range origMeta.Thanos.Labels {
+		thanosMeta.Labels[k] = v
+	}
+	thanosMeta.Downsample.Resolution = resolution
+	thanosMeta.Source = metadata.CompactorSource
+	thanosMeta.SegmentFiles = block.GetSegmentFiles(blockDir)
+	thanosMeta.Files = nil
+
+	meta := &metadata.Meta{
+		BlockMeta: tsdb.BlockMeta{
+			ULID:       id,
+			MinTime:    origMeta.MinTime,
+			MaxTime:    origMeta.MaxTime,
+			Stats:      stats,
+			Compaction: origMeta.Compaction,
+			Version:    metadata.TSDBVersion1,
+		},
+		Thanos: thanosMeta,
+	}
+
+	if err := meta.WriteToDir(logger, blockDir); err != nil {
+		return id, errors.Wrap(err, "write meta")
+	}
+
+	return id, nil
+}
+
+// writeDownsampledBlock writes the index and chunks of the downsampled block into blockDir.
+func writeDownsampledBlock(indexr tsdb.IndexReader, chunkr tsdb.ChunkReader, blockDir string, origResolution, resolution int64) (stats tsdb.BlockStats, err error) {
+	chunkw, err := chunks.NewWriter(filepath.Join(blockDir, block.ChunksDirname))
+	if err != nil {
+		return stats, errors.Wrap(err, "open chunk writer")
+	}
+	defer runutil.CloseWithErrCapture(&err, chunkw, "downsample chunk writer")
+
+	indexw, err := index.NewWriter(context.Background(), filepath.Join(blockDir, block.IndexFilename))
+	if err != nil {
+		return stats, errors.Wrap(err, "open index writer")
+	}
+	defer runutil.CloseWithErrCapture(&err, indexw, "downsample index writer")
+
+	// The downsampled block has the same series of the original one, so we can copy all symbols.
+	symbols := indexr.Symbols()
+	for symbols.Next() {
+		if err := indexw.AddSymbol(symbols.At()); err != nil {
+			return stats, errors.Wrap(err, "add symbol")
+		}
+	}
+	if err := symbols.Err(); err != nil {
+		return stats, errors.Wrap(err, "iterate symbols")
+	}
+
+	postings, err := indexr.Postings(index.AllPostingsKey())
+	if err != nil {
+		return stats, errors.Wrap(err, "get all postings")
+	}
+	postings = indexr.SortedPostings(postings)
+
+	var (
+		lset    labels.Labels
+		chks    []chunks.Meta
+		samples []sample
+		ref     storage.SeriesRef
+	)
+
+	for postings.Next() {
+		if err := indexr.Series(postings.At(), &lset, &chks); err != nil {
+			return stats, errors.Wrapf(err, "get series %d", postings.At())
+		}
+
+		var downsampled []chunks.Meta
+
+		if origResolution == ResolutionRaw {
+			samples = samples[:0]
+			for _, c := range chks {
+				chk, err := chunkr.Chunk(c)
+				if err != nil {
+					return stats, errors.Wrapf(err, "get chunk %d, series %d", c.Ref, postings.At())
+				}
+				if err := expandChunkIterator(chk.Iterator(nil), &samples); err != nil {
+					return stats, errors.Wrapf(err, "expand chunk %d, series %d", c.Ref, postings.At())
+				}
+			}
+			downsampled = downsampleRaw(samples, resolution)
+		} else {
+			aggrChks := make([]AggrChunk, 0, len(chks))
+			for _, c := range chks {
+				chk, err := chunkr.Chunk(c)
+				if err != nil {
+					return stats, errors.Wrapf(err, "get chunk %d, series %d", c.Ref, postings.At())
+				}
+				aggrChk, ok := chk.(AggrChunk)
+				if !ok {
+					return stats, errors.Errorf("expected downsampled chunk got %T instead for series %d", chk, postings.At())
+				}
+				aggrChks = append(aggrChks, aggrChk)
+			}
+
+			if len(aggrChks) > 0 {
+				downsampled, err = downsampleAggr(aggrChks, &samples, chks[0].MinTime, chks[len(chks)-1].MaxTime, origResolution, resolution)
+				if err != nil {
+					return stats, errors.Wrapf(err, "downsample aggregate chunks of series %d", postings.At())
+				}
+			}
+		}
+
+		if len(downsampled) == 0 {
+			continue
+		}
+
+		if err := chunkw.WriteChunks(downsampled...); err != nil {
+			return stats, errors.Wrapf(err, "write chunks of series %d", postings.At())
+		}
+		if err := indexw.AddSeries(ref, lset, downsampled...); err != nil {
+			return stats, errors.Wrapf(err, "add series %d", postings.At())
+		}
+		ref++
+
+		stats.NumSeries++
+		stats.NumChunks += uint64(len(downsampled))
+		for _, c := range downsampled {
+			stats.NumSamples += uint64(c.Chunk.NumSamples())
+		}
+	}
+	if err := postings.Err(); err != nil {
+		return stats, errors.Wrap(err, "iterate series")
+	}
+
+	return stats, nil
+}
+
+type sample struct {
+	t int64
+	v float64
+}
+
+// expandChunkIterator reads all samples from the iterator and appends them to buf.
+// Stale markers and out of order samples are skipped.
+func expandChunkIterator(it chunkenc.Iterator, buf *[]sample) error {
+	// For safety reasons, we check for each sample that it does not go back in time.
+	// If it does, we skip it.
+	lastT := int64(math.MinInt64)
+
+	for it.Next() {
+		t, v := it.At()
+		if value.IsStaleNaN(v) {
+			continue
+		}
+		if t >= lastT {
+			*buf = append(*buf, sample{t, v})
+			lastT = t
+		}
+	}
+	return it.Err()
+}
+
+// aggrChunkBuilder builds an aggregate chunk, appending samples to the chunk of each aggregate.
+type aggrChunkBuilder struct {
+	mint, maxt int64
+
+	chunks [numAggrTypes]chunkenc.Chunk
+	apps   [numAggrTypes]chunkenc.Appender
+}
+
+func newAggrChunkBuilder() *aggrChunkBuilder {
+	b := &aggrChunkBuilder{
+		mint: math.MaxInt64,
+		maxt: math.MinInt64,
+	}
+	for i := range b.chunks {
+		b.chunks[i] = chunkenc.NewXORChunk()
+		b.apps[i], _ = b.chunks[i].Appender()
+	}
+	return b
+}
+
+func (b *aggrChunkBuilder) add(t int64, aggr *aggregator) {
+	if t < b.mint {
+		b.mint = t
+	}
+	if t > b.maxt {
+		b.maxt = t
+	}
+	b.apps[AggrSum].Append(t, aggr.sum)
+	b.apps[AggrMin].Append(t, aggr.min)
+	b.apps[AggrMax].Append(t, aggr.max)
+	b.apps[AggrCount].Append(t, float64(aggr.count))
+	b.apps[AggrCounter].Append(t, aggr.counter)
+}
+
+func (b *aggrChunkBuilder) encode() chunks.Meta {
+	return chunks.Meta{
+		MinTime: b.mint,
+		MaxTime: b.maxt,
+		Chunk:   EncodeAggrChunk(b.chunks),
+	}
+}
+
+// currentWindow returns the end timestamp of the window that t falls into.
+func currentWindow(t, r int64) int64 {
+	// The next timestamp is the next number after s.t that's aligned with window.
+	// We subtract 1 because block ranges are [from, to) and the last sample would
+	// go out of bounds otherwise.
+	return t - (t % r) + r - 1
+}
+
+// rangeFullness returns the fraction of how the range [mint, maxt] covered
+// with count samples at the given step size.
+// It return value is bounded to [0, 1].
+func rangeFullness(mint, maxt, step int64, count int) float64 {
+	f := float64(count) / (float64(maxt-mint) / float64(step))
+	if f > 1 {
+		return 1
+	}
+	return f
+}
+
+// targetChunkCount calculates how many chunks should be produced when downsampling a series.
+// It consider the total time range, the number of input sample, the input and output resolution.
+func targetChunkCount(mint, maxt, inRes, outRes int64, count int) (x int) {
+	// We compute how many samples we could produce for the given time range and adjust
+	// it by how densely the range is actually filled given the number of input samples and their
+	// resolution.
+	maxSamples := float64((maxt - mint) / outRes)
+	expSamples := int(maxSamples*rangeFullness(mint, maxt, inRes, count)) + 1
+
+	// Increase the number of target chunks until each chunk will have less than
+	// 140 samples on average.
+	for x = 1; expSamples/x > 140; x++ {
+	}
+	return x
+}
+
+// downsampleRaw create a series of aggregation chunks for the given sample data.
+func downsampleRaw(data []sample, resolution int64) []chunks.Meta {
+	if len(data) == 0 {
+		return nil
+	}
+
+	mint, maxt := data[0].t, data[len(data)-1].t
+	// We assume a raw resolution of 1 minute. In practice it will often be lower
+	// but this is sufficient for our heuristic to produce well-sized chunks.
+	numChunks := targetChunkCount(mint, maxt, 1*60*1000, resolution, len(data))
+	return downsampleRawLoop(data, resolution, numChunks)
+}
+
+func downsampleRawLoop(data []sample, resolution int64, numChunks int) []chunks.Meta {
+	batchSize := (len(data) / numChunks) + 1
+	chks := make([]chunks.Meta, 0, numChunks)
+
+	for len(data) > 0 {
+		j := batchSize
+		if j > len(data) {
+			j = len(data)
+		}
+		curW := currentWindow(data[j-1].t, resolution)
+
+		// The batch we took might end in the middle of a downsampling window. We additionally grab
+		// all further samples in the window to keep our samples regular.
+		for ; j < len(data) && data[j].t <= curW; j++ {
+		}
+
+		batch := data[:j]
+		data = data[j:]
+
+		ab := newAggrChunkBuilder()
+
+		// Encode first raw value; see ApplyCounterResetsSeriesIterator.
+		ab.apps[AggrCounter].Append(batch[0].t, batch[0].v)
+
+		lastT := downsampleBatch(batch, resolution, ab.add)
+
+		// Encode last raw value; see ApplyCounterResetsSeriesIterator.
+		ab.apps[AggrCounter].Append(lastT, batch[len(batch)-1].v)
+
+		chks = append(chks, ab.encode())
+	}
+
+	return chks
+}
+
+// downsampleBatch aggregates the data over the given resolution and calls add each time
+// the end of a resolution was reached.
+func downsampleBatch(data []sample, resolution int64, add func(int64, *aggregator)) int64 {
+	var (
+		aggr  aggregator
+		nextT = int64(-1)
+		lastT = data[len(data)-1].t
+	)
+	// Fill up one aggregate chunk with up to m samples.
+	for _, s := range data {
+		if value.IsStaleNaN(s.v) {
+			continue
+		}
+		if s.t > nextT {
+			if nextT != -1 {
+				add(nextT, &aggr)
+			}
+			aggr.reset()
+			nextT = currentWindow(s.t, resolution)
+			// Limit next timestamp to not go beyond the batch. A subsequent batch
+			// may overlap in time range otherwise.
+			// We have aligned batches for raw downsamplings but subsequent downsamples
+			// are forced to be chunk-boundary aligned and cannot guarantee this.
+			if nextT > lastT {
+				nextT = lastT
+			}
+		}
+		aggr.add(s.v)
+	}
+	// Add the last sample.
+	add(nextT, &aggr)
+
+	return nextT
+}
+
+// downsampleAggr downsamples a sequence of aggregation chunks to the given resolution.
+func downsampleAggr(chks []AggrChunk, buf *[]sample, mint, maxt, inRes, outRes int64) ([]chunks.Meta, error) {
+	var numSamples int
+	for _, c := range chks {
+		numSamples += c.NumSamples()
+	}
+	numChunks := targetChunkCount(mint, maxt, inRes, outRes, numSamples)
+	return downsampleAggrLoop(chks, buf, outRes, numChunks)
+}
+
+func downsampleAggrLoop(chks []AggrChunk, buf *[]sample, resolution int64, numChunks int) ([]chunks.Meta, error) {
+	// We downsample aggregates only along chunk boundaries. This is required
+	// for counters to be downsampled correctly since a chunk's first and last
+	// counter values are the true values of the original series. We need
+	// to preserve them even across multiple aggregation iterations.
+	res := make([]chunks.Meta, 0, numChunks)
+	batchSize := (len(chks) / numChunks) + 1
+
+	for len(chks) > 0 {
+		j := batchSize
+		if j > len(chks) {
+			j = len(chks)
+		}
+		part := chks[:j]
+		chks = chks[j:]
+
+		chk, ok, err := downsampleAggrBatch(part, buf, resolution)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res = append(res, chk)
+		}
+	}
+
+	return res, nil
+}
+
+// downsampleAggrBatch downsamples a batch of aggregation chunks into a single aggregation chunk.
+// It returns false if the input chunks contain no samples.
+func downsampleAggrBatch(chks []AggrChunk, buf *[]sample, resolution int64) (chk chunks.Meta, ok bool, err error) {
+	var (
+		chunksByAggr [numAggrTypes]chunkenc.Chunk
+		mint, maxt   = int64(math.MaxInt64), int64(math.MinInt64)
+	)
+
+	updateRange := func(t int64) {
+		if t < mint {
+			mint = t
+		}
+		if t > maxt {
+			maxt = t
+		}
+	}
+
+	// do does a generic aggregation for count, sum, min, and max aggregates.
+	// Counters need special treatment.
+	do := func(at AggrType, f func(a *aggregator) float64) error {
+		*buf = (*buf)[:0]
+		// Expand all samples for the aggregate type.
+		for _, chk := range chks {
+			c, err := chk.Get(at)
+			if errors.Is(err, ErrAggrNotExist) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			if err := expandChunkIterator(c.Iterator(nil), buf); err != nil {
+				return err
+			}
+		}
+		if len(*buf) == 0 {
+			return nil
+		}
+
+		chunksByAggr[at] = chunkenc.NewXORChunk()
+		app, err := chunksByAggr[at].Appender()
+		if err != nil {
+			return err
+		}
+
+		downsampleBatch(*buf, resolution, func(t int64, a *aggregator) {
+			updateRange(t)
+			app.Append(t, f(a))
+		})
+		return nil
+	}
+
+	// To get correct count of elements from already downsampled count chunk
+	// we have to sum those values.
+	if err := do(AggrCount, func(a *aggregator) float64 { return a.sum }); err != nil {
+		return chk, false, err
+	}
+	if err := do(AggrSum, func(a *aggregator) float64 { return a.sum }); err != nil {
+		return chk, false, err
+	}
+	if err := do(AggrMin, func(a *aggregator) float64 { return a.min }); err != nil {
+		return chk, false, err
+	}
+	if err := do(AggrMax, func(a *aggregator) float64 { return a.max }); err != nil {
+		return chk, false, err
+	}
+
+	// Handle counters by applying resets directly.
+	acs := make([]chunkenc.Iterator, 0, len(chks))
+	for _, achk := range chks {
+		c, err := achk.Get(AggrCounter)
+		if errors.Is(err, ErrAggrNotExist) {
+			continue
+		} else if err != nil {
+			return chk, false, err
+		}
+		acs = append(acs, c.Iterator(nil))
+	}
+
+	*buf = (*buf)[:0]
+	it := NewApplyCounterResetsIterator(acs...)
+	if err := expandChunkIterator(it, buf); err != nil {
+		return chk, false, err
+	}
+
+	if len(*buf) > 0 {
+		chunksByAggr[AggrCounter] = chunkenc.NewXORChunk()
+		app, err := chunksByAggr[AggrCounter].Appender()
+		if err != nil {
+			return chk, false, err
+		}
+
+		// Retain first raw value; see ApplyCounterResetsSeriesIterator.
+		app.Append((*buf)[0].t, (*buf)[0].v)
+
+		lastT := downsampleBatch(*buf, resolution, func(t int64, a *aggregator) {
+			updateRange(t)
+			app.Append(t, a.last)
+		})
+
+		// Retain last raw value; see ApplyCounterResetsSeriesIterator.
+		app.Append(lastT, it.LastRawValue())
+	}
+
+	if mint > maxt {
+		return chk, false, nil
+	}
+
+	return chunks.Meta{
+		MinTime: mint,
+		MaxTime: maxt,
+		Chunk:   EncodeAggrChunk(chunksByAggr),
+	}, true, nil
+}
+
+// aggregator collects cumulative stats for a stream of values.
+type aggregator struct {
+	total   int     // Total samples processed.
+	count   int     // Samples in current window.
+	sum     float64 // Value sum of current window.
+	min     float64 // Min of current window.
+	max     float64 // Max of current window.
+	counter float64 // Total counter state since beginning.
+	resets  int     // Number of counter resets since beginning.
+	last    float64 // Last added value.
+}
+
+// reset the stats to start a new aggregation window.
+func (a *aggregator) reset() {
+	a.count = 0
+	a.sum = 0
+	a.min = math.MaxFloat64
+	a.max = -math.MaxFloat64
+}
+
+func (a *aggregator) add(v float64) {
+	if a.total > 0 {
+		if v < a.last {
+			// Counter reset, correct the value.
+			a.counter += v
+			a.resets++
+		} else {
+			// Add delta with last value to the counter.
+			a.counter += v - a.last
+		}
+	} else {
+		// First sample sets the counter.
+		a.counter = v
+	}
+	a.last = v
+
+	a.sum += v
+	a.count++
+	a.total++
+
+	if v < a.min {
+		a.min = v
+	}
+	if v > a.max {
+		a.max = v
+	}
+}
@@ -0,0 +1,291 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package downsample
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"github.com/prometheus/prometheus/tsdb/tsdbutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+)
+
+type testSample struct {
+	t int64
+	v float64
+}
+
+func (s testSample) T() int64   { return s.t }
+func (s testSample) V() float64 { return s.v }
+
+func TestDownsample(t *testing.T) {
+	const (
+		numSamples = 960 // 4h at 15s scrape interval.
+		interval   = int64(15 * time.Second / time.Millisecond)
+		resetAt    = 500
+	)
+
+	var gaugeSamples, counterSamples []tsdbutil.Sample
+	for i := 0; i < numSamples; i++ {
+		ts := int64(i) * interval
+		gaugeSamples = append(gaugeSamples, testSample{t: ts, v: float64(i % 10)})
+
+		counter := float64(i)
+		if i >= resetAt {
+			counter = float64(i - resetAt)
+		}
+		counterSamples = append(counterSamples, testSample{t: ts, v: counter})
+	}
+
+	// The expected counter value once resets have been applied.
+	expectedCounter := float64(resetAt-1) + float64(numSamples-1-resetAt)
+
+	storageDir := t.TempDir()
+	rawMeta, err := testutil.GenerateBlockFromSpec("user-1", storageDir, testutil.BlockSeriesSpecs{
+		{Labels: labels.FromStrings("__name__", "gauge"), Chunks: splitIntoChunks(gaugeSamples, 120)},
+		{Labels: labels.FromStrings("__name__", "counter"), Chunks: splitIntoChunks(counterSamples, 120)},
+	})
+	require.NoError(t, err)
+
+	// Downsample the raw block to 5m resolution.
+	rawBlock, err := tsdb.OpenBlock(log.NewNopLogger(), filepath.Join(storageDir, rawMeta.ULID.String()), NewPool())
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, rawBlock.Close()) })
+
+	id5m, err := Downsample(log.NewNopLogger(), rawMeta, rawBlock, storageDir, Resolution5m)
+	require.NoError(t, err)
+
+	meta5m, err := metadata.ReadFromDir(filepath.Join(storageDir, id5m.String()))
+	require.NoError(t, err)
+	assert.Equal(t, Resolution5m, meta5m.Thanos.Downsample.Resolution)
+	assert.Equal(t, rawMeta.MinTime, meta5m.MinTime)
+	assert.Equal(t, rawMeta.MaxTime, meta5m.MaxTime)
+	assert.Equal(t, rawMeta.Compaction.Sources, meta5m.Compaction.Sources)
+	assert.Equal(t, uint64(2), meta5m.Stats.NumSeries)
+
+	series5m := readAggrChunks(t, storageDir, id5m)
+	require.Len(t, series5m, 2)
+
+	// The first 5m window contains 20 gauge samples with values from 0 to 9.
+	gauge5m := series5m["gauge"]
+	assert.Equal(t, []testSample{{t: Resolution5m - 1, v: 20}}, firstSamples(t, gauge5m, AggrCount, 1))
+	assert.Equal(t, []testSample{{t: Resolution5m - 1, v: 90}}, firstSamples(t, gauge5m, AggrSum, 1))
+	assert.Equal(t, []testSample{{t: Resolution5m - 1, v: 0}}, firstSamples(t, gauge5m, AggrMin, 1))
+	assert.Equal(t, []testSample{{t: Resolution5m - 1, v: 9}}, firstSamples(t, gauge5m, AggrMax, 1))
+	assert.Equal(t, float64(numSamples), sumAggr(t, gauge5m, AggrCount))
+	assert.Len(t, allSamples(t, gauge5m, AggrCount), 48)
+
+	assert.Equal(t, float64(numSamples), sumAggr(t, series5m["counter"], AggrCount))
+	assert.Equal(t, expectedCounter, lastCounterValue(t, series5m["counter"]))
+
+	// Downsample the 5m block to 1h resolution.
+	block5m, err := tsdb.OpenBlock(log.NewNopLogger(), filepath.Join(storageDir, id5m.String()), NewPool())
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, block5m.Close()) })
+
+	id1h, err := Downsample(log.NewNopLogger(), meta5m, block5m, storageDir, Resolution1h)
+	require.NoError(t, err)
+
+	meta1h, err := metadata.ReadFromDir(filepath.Join(storageDir, id1h.String()))
+	require.NoError(t, err)
+	assert.Equal(t, Resolution1h, meta1h.Thanos.Downsample.Resolution)
+	assert.Equal(t, rawMeta.Compaction.Sources, meta1h.Compaction.Sources)
+
+	series1h := readAggrChunks(t, storageDir, id1h)
+	require.Len(t, series1h, 2)
+
+	// The first 1h window contains 240 gauge samples.
+	gauge1h := series1h["gauge"]
+	assert.Equal(t, []testSample{{t: Resolution1h - 1, v: 240}}, firstSamples(t, gauge1h, AggrCount, 1))
+	assert.Equal(t, []testSample{{t: Resolution1h - 1, v: 1080}}, firstSamples(t, gauge1h, AggrSum, 1))
+	assert.Equal(t, []testSample{{t: Resolution1h - 1, v: 0}}, firstSamples(t, gauge1h, AggrMin, 1))
+	assert.Equal(t, []testSample{{t: Resolution1h - 1, v: 9}}, firstSamples(t, gauge1h, AggrMax, 1))
+	assert.Equal(t, float64(numSamples), sumAggr(t, gauge1h, AggrCount))
+	assert.Len(t, allSamples(t, gauge1h, AggrCount), 4)
+
+	assert.Equal(t, float64(numSamples), sumAggr(t, series1h["counter"], AggrCount))
+	assert.Equal(t, expectedCounter, lastCounterValue(t, series1h["counter"]))
+
+	// Downsampling to a resolution not lower than the block one is not allowed.
+	_, err = Downsample(log.NewNopLogger(), meta1h, block5m, storageDir, Resolution5m)
+	require.Error(t, err)
+}
+
+func TestAggrChunk_Get(t *testing.T) {
+	count := chunkenc.NewXORChunk()
+	app, err := count.Appender()
+	require.NoError(t, err)
+	app.Append(1, 10)
+	app.Append(2, 20)
+
+	chk := EncodeAggrChunk([numAggrTypes]chunkenc.Chunk{AggrCount: count})
+	assert.Equal(t, 2, chk.NumSamples())
+
+	got, err := chk.Get(AggrCount)
+	require.NoError(t, err)
+	assert.Equal(t, count.Bytes(), got.Bytes())
+
+	for _, at := range []AggrType{AggrSum, AggrMin, AggrMax, AggrCounter} {
+		_, err := chk.Get(at)
+		assert.ErrorIs(t, err, ErrAggrNotExist, at.String())
+	}
+
+	// The aggregate chunk must be returned as is by the pool, which is used to read downsampled blocks.
+	pooled, err := NewPool().Get(ChunkEncAggr, chk.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, chk, pooled)
+}
+
+func TestApplyCounterResetsSeriesIterator(t *testing.T) {
+	// Each chunk starts with the first raw value and ends with the last raw value,
+	// duplicating the timestamp of the previous sample.
+	chk1 := newXORChunk(t, []testSample{{0, 1}, {10, 5}, {20, 10}, {20, 10}})
+	chk2 := newXORChunk(t, []testSample{{30, 2}, {40, 8}, {50, 4}, {50, 1}})
+
+	it := NewApplyCounterResetsIterator(chk1.Iterator(nil), chk2.Iterator(nil))
+
+	var got []testSample
+	for it.Next() {
+		ts, v := it.At()
+		got = append(got, testSample{ts, v})
+	}
+	require.NoError(t, it.Err())
+
+	// The counter reset between the chunks (10 -> 2) and the one within the second chunk (8 -> 4) are applied.
+	assert.Equal(t, []testSample{{0, 1}, {10, 5}, {20, 10}, {30, 12}, {40, 18}, {50, 22}}, got)
+}
+
+func TestAverageChunkIterator(t *testing.T) {
+	count := newXORChunk(t, []testSample{{10, 2}, {20, 4}})
+	sum := newXORChunk(t, []testSample{{10, 10}, {20, 10}})
+
+	it := NewAverageChunkIterator(count.Iterator(nil), sum.Iterator(nil))
+	require.True(t, it.Seek(15))
+	ts, v := it.At()
+	assert.Equal(t, int64(20), ts)
+	assert.Equal(t, 2.5, v)
+	require.False(t, it.Next())
+	require.NoError(t, it.Err())
+}
+
+func splitIntoChunks(samples []tsdbutil.Sample, samplesPerChunk int) []chunks.Meta {
+	var res []chunks.Meta
+	for len(samples) > 0 {
+		n := samplesPerChunk
+		if n > len(samples) {
+			n = len(samples)
+		}
+		res = append(res, tsdbutil.ChunkFromSamples(samples[:n]))
+		samples = samples[n:]
+	}
+	return res
+}
+
+func newXORChunk(t *testing.T, samples []testSample) chunkenc.Chunk {
+	chk := chunkenc.NewXORChunk()
+	app, err := chk.Appender()
+	require.NoError(t, err)
+	for _, s := range samples {
+		app.Append(s.t, s.v)
+	}
+	return chk
+}
+
+// readAggrChunks returns the aggregate chunks of each series in the block, by metric name.
+func readAggrChunks(t *testing.T, dir string, id ulid.ULID) map[string][]AggrChunk {
+	b, err := tsdb.OpenBlock(log.NewNopLogger(), filepath.Join(dir, id.String()), NewPool())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, b.Close()) }()
+
+	indexr, err := b.Index()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, indexr.Close()) }()
+
+	chunkr, err := b.Chunks()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, chunkr.Close()) }()
+
+	postings, err := indexr.Postings(index.AllPostingsKey())
+	require.NoError(t, err)
+
+	res := map[string][]AggrChunk{}
+	for postings.Next() {
+		var (
+			lset labels.Labels
+			chks []chunks.Meta
+		)
+		require.NoError(t, indexr.Series(postings.At(), &lset, &chks))
+
+		for _, c := range chks {
+			chk, err := chunkr.Chunk(c)
+			require.NoError(t, err)
+
+			aggrChk, ok := chk.(AggrChunk)
+			require.True(t, ok)
+
+			// Copy the chunk, because it references the block's memory mapped data.
+			res[lset.Get("__name__")] = append(res[lset.Get("__name__")], append(AggrChunk(nil), aggrChk...))
+		}
+	}
+	require.NoError(t, postings.Err())
+
+	return res
+}
+
+func allSamples(t *testing.T, chks []AggrChunk, at AggrType) []testSample {
+	var res []testSample
+	for _, c := range chks {
+		chk, err := c.Get(at)
+		require.NoError(t, err)
+
+		it := chk.Iterator(nil)
+		for it.Next() {
+			ts, v := it.At()
+			res = append(res, testSample{ts, v})
+		}
+		require.NoError(t, it.Err())
+	}
+	return res
+}
+
+func firstSamples(t *testing.T, chks []AggrChunk, at AggrType, n int) []testSample {
+	return allSamples(t, chks, at)[:n]
+}
+
+func sumAggr(t *testing.T, chks []AggrChunk, at AggrType) float64 {
+	sum := 0.0
+	for _, s := range allSamples(t, chks, at) {
+		sum += s.v
+	}
+	return sum
+}
+
+func lastCounterValue(t *testing.T, chks []AggrChunk) float64 {
+	its := make([]chunkenc.Iterator, 0, len(chks))
+	for _, c := range chks {
+		chk, err := c.Get(AggrCounter)
+		require.NoError(t, err)
+		its = append(its, chk.Iterator(nil))
+	}
+
+	last := math.NaN()
+	it := NewApplyCounterResetsIterator(its...)
+	for it.Next() {
+		_, last = it.At()
+	}
+	require.NoError(t, it.Err())
+	return last
+}
@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/thanos-io/thanos/blob/main/pkg/compact/downsample/downsample.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Thanos Authors.
+
+package downsample
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+)
+
+// ApplyCounterResetsSeriesIterator generates monotonically increasing values by iterating
+// over an ordered sequence of chunks, which should be raw or aggregated chunks
+// of counter values. The generated samples can be used by PromQL functions
+// like 'rate' that calculate differences between counter values. Stale Markers
+// are removed as well.
+//
+// Counter aggregation chunks must have the first and last values from their
+// original raw series: the first raw value should be the first value encoded
+// in the chunk, and the last raw value is encoded by the duplication of the
+// previous sample's timestamp. As iteration occurs between chunks, the
+// comparison between the last raw value of the earlier chunk and the first raw
+// value of the later chunk ensures that counter resets between chunks are
+// recognized and that the correct value delta is calculated.
+//
+// It handles overlapped chunks (removes overlaps).
+type ApplyCounterResetsSeriesIterator struct {
+	chks   []chunkenc.Iterator
+	i      int     // Current chunk.
+	total  int     // Total number of processed samples.
+	lastT  int64   // Timestamp of the last sample.
+	lastV  float64 // Value of the last sample.
+	totalV float64 // Total counter state since beginning of series.
+	err    error
+}
+
+// NewApplyCounterResetsIterator makes a new ApplyCounterResetsSeriesIterator.
+func NewApplyCounterResetsIterator(chks ...chunkenc.Iterator) *ApplyCounterResetsSeriesIterator {
+	return &ApplyCounterResetsSeriesIterator{chks: chks}
+}
+
+// LastRawValue returns the last raw (not reset-corrected) value iterated.
+func (it *ApplyCounterResetsSeriesIterator) LastRawValue() float64 {
+	return it.lastV
+}
+
+func (it *ApplyCounterResetsSeriesIterator) Next() bool {
+	for {
+		if it.i >= len(it.chks) {
+			return false
+		}
+		if ok := it.chks[it.i].Next(); !ok {
+			if err := it.chks[it.i].Err(); err != nil {
+				it.err = err
+				return false
+			}
+			it.i++
+			continue
+		}
+
+		t, v := it.chks[it.i].At()
+		if math.IsNaN(v) {
+			continue
+		}
+
+		// First sample sets the initial counter state.
+		if it.total == 0 {
+			it.total++
+			it.lastT, it.lastV = t, v
+			it.totalV = v
+			return true
+		}
+
+		// If the timestamp increased, it is not the special last sample.
+		if t > it.lastT {
+			if v >= it.lastV {
+				it.totalV += v - it.lastV
+			} else {
+				it.totalV += v
+			}
+			it.lastT, it.lastV = t, v
+			it.total++
+			return true
+		}
+
+		// We hit a sample that indicates what the true last value was. For the
+		// next chunk we use it to determine whether there was a counter reset between them.
+		if t == it.lastT {
+			it.lastV = v
+		}
+
+		// Otherwise the series went backwards in time (overlapping chunks), so we skip the sample.
+	}
+}
+
+func (it *ApplyCounterResetsSeriesIterator) At() (t int64, v float64) {
+	return it.lastT, it.totalV
+}
+
+func (it *ApplyCounterResetsSeriesIterator) Seek(x int64) bool {
+	// Don't use the underlying Seek, but iterate over next to not miss counter resets.
+	for {
+		if it.total > 0 {
+			if t, _ := it.At(); t >= x {
+				return true
+			}
+		}
+
+		if ok := it.Next(); !ok {
+			return false
+		}
+	}
+}
+
+func (it *ApplyCounterResetsSeriesIterator) Err() error {
+	return it.err
+}
+
+// AverageChunkIterator emits an artificial series of average samples based on aggregate
+// chunks with sum and count aggregates.
+type AverageChunkIterator struct {
+	cntIt   chunkenc.Iterator
+	sumIt   chunkenc.Iterator
+	started bool
+	t       int64
+	v       float64
+	err     error
+}
+
+// NewAverageChunkIterator makes a new AverageChunkIterator.
+func NewAverageChunkIterator(cnt, sum chunkenc.Iterator) *AverageChunkIterator {
+	return &AverageChunkIterator{cntIt: cnt, sumIt: sum}
+}
+
+func (it *AverageChunkIterator) Next() bool {
+	it.started = true
+
+	cok, sok := it.cntIt.Next(), it.sumIt.Next()
+	if cok != sok {
+		it.err = errors.New("sum and count iterator not aligned")
+		return false
+	}
+	if !cok {
+		if err := it.cntIt.Err(); err != nil {
+			it.err = err
+		} else if err := it.sumIt.Err(); err != nil {
+			it.err = err
+		}
+		return false
+	}
+
+	cntT, cntV := it.cntIt.At()
+	sumT, sumV := it.sumIt.At()
+	if cntT != sumT {
+		it.err = errors.New("sum and count timestamps not aligned")
+		return false
+	}
+	it.t, it.v = cntT, sumV/cntV
+	return true
+}
+
+func (it *AverageChunkIterator) Seek(t int64) bool {
+	if it.err != nil {
+		return false
+	}
+	if it.started && it.t >= t {
+		return true
+	}
+	for it.Next() {
+		if it.t >= t {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *AverageChunkIterator) At() (int64, float64) {
+	return it.t, it.v
+}
+
+func (it *AverageChunkIterator) Err() error {
+	return it.err
+}
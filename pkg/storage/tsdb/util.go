@@ -20,3 +20,21 @@ func HashBlockID(id ulid.ULID) uint32 {
 	}
 	return h
 }
+
+// HashBlockIDReplicaSet returns a 32-bit hash of the block ID for the given
+// replica set, useful to look up the owners of additional block replicas in the
+// ring. The replica set 0 hashes to HashBlockID(id).
+func HashBlockIDReplicaSet(id ulid.ULID, replicaSet int) uint32 {
+	if replicaSet == 0 {
+		return HashBlockID(id)
+	}
+
+	h := client.HashNew32()
+	for i := 0; i < 4; i++ {
+		h = client.HashAddByte32(h, byte(replicaSet>>(8*i)))
+	}
+	for _, b := range id {
+		h = client.HashAddByte32(h, b)
+	}
+	return h
+}
@@ -42,3 +42,17 @@ func TestHashBlockID(t *testing.T) {
 		assert.Equal(t, testCase.expectedEqual, firstHash == secondHash)
 	}
 }
+
+func TestHashBlockIDReplicaSet(t *testing.T) {
+	id := ulid.MustNew(10, rand.Reader)
+
+	assert.Equal(t, HashBlockID(id), HashBlockIDReplicaSet(id, 0))
+	assert.Equal(t, HashBlockIDReplicaSet(id, 1), HashBlockIDReplicaSet(id, 1))
+
+	// Each replica set of the block hashes to a different value.
+	hashes := map[uint32]struct{}{}
+	for replicaSet := 0; replicaSet < 10; replicaSet++ {
+		hashes[HashBlockIDReplicaSet(id, replicaSet)] = struct{}{}
+	}
+	assert.Len(t, hashes, 10)
+}
@@ -51,6 +51,7 @@ import (
 
 	"github.com/grafana/mimir/pkg/storage/sharding"
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/downsample"
 	"github.com/grafana/mimir/pkg/storegateway/hintspb"
 	"github.com/grafana/mimir/pkg/storegateway/indexcache"
 	"github.com/grafana/mimir/pkg/storegateway/indexheader"
@@ -130,6 +131,10 @@ type BucketStore struct {
 
 	// Enables hints in the Series() response.
 	enableSeriesResponseHints bool
+
+	// Matchers whose postings are larger than this ratio times the postings of the most selective matcher
+	// are applied by filtering series labels. 0 disables the postings planning.
+	postingsPlanningRatio float64
 }
 
 type noopCache struct{}
@@ -207,6 +212,14 @@ func WithDebugLogging() BucketStoreOption {
 	}
 }
 
+// WithPostingsPlanning enables the postings planning: the matchers of a Series() request whose postings are larger
+// than ratio times the postings of the most selective matcher are applied by filtering the series labels.
+func WithPostingsPlanning(ratio float64) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.postingsPlanningRatio = ratio
+	}
+}
+
 // NewBucketStore creates a new bucket backed store that implements the store API against
 // an object store bucket. It is optimized to work against high latency backends.
 func NewBucketStore(
@@ -556,6 +569,11 @@ func (s *bucketSeriesSet) Err() error {
 	return s.err
 }
 
+// rewind returns a set iterating the same series from the beginning.
+func (s *bucketSeriesSet) rewind() *bucketSeriesSet {
+	return &bucketSeriesSet{set: s.set, i: -1, err: s.err}
+}
+
 // blockSeries returns series matching given matchers, that have some data in given time range.
 // If skipChunks is provided, then provided minTime and maxTime are ignored and search is performed over the entire
 // block to make the result cacheable.
@@ -571,8 +589,9 @@ func blockSeries(
 	skipChunks bool, // If true, chunks are not loaded and minTime/maxTime are ignored.
 	minTime, maxTime int64, // Series must have data in this time range to be returned (ignored if skipChunks=true).
 	loadAggregates []storepb.Aggr, // List of aggregates to load when loading chunks.
+	postingsPlanningRatio float64, // Matchers with postings larger than this ratio are applied by filtering series labels (0 to disable).
 	logger log.Logger,
-) (storepb.SeriesSet, *queryStats, error) {
+) (*bucketSeriesSet, *queryStats, error) {
 	span, ctx := tracing.StartSpan(ctx, "blockSeries()")
 	span.LogKV(
 		"block ID", indexr.block.meta.ULID.String(),
@@ -592,9 +611,14 @@ func blockSeries(
 		}
 	}
 
-	ps, err := indexr.ExpandedPostings(ctx, matchers)
+	ps, plan, err := indexr.PlannedExpandedPostings(ctx, matchers, postingsPlanningRatio)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "expanded matching posting")
+		return nil, nil, err
+	}
+	if len(plan.lazyMatchers) > 0 {
+		indexr.stats.blocksQueriedWithLazyMatchers++
+	} else if postingsPlanningRatio > 0 {
+		indexr.stats.blocksQueriedWithAllMatchers++
 	}
 
 	// We can't compute the series hash yet because we're still missing the series labels.
@@ -606,7 +630,7 @@ func blockSeries(
 	}
 
 	if len(ps) == 0 {
-		return storepb.EmptySeriesSet(), indexr.stats, nil
+		return newBucketSeriesSet(nil), indexr.stats, nil
 	}
 
 	// Preload all series index data.
@@ -645,6 +669,12 @@ func blockSeries(
 				return
 			}
 
+			// Skip the series if it doesn't match the matchers which haven't been applied on postings.
+			if !plan.matches(lset) {
+				indexr.stats.seriesFilteredByLazyMatchers++
+				continue
+			}
+
 			// Skip the series if it doesn't belong to the shard.
 			if shard != nil {
 				hash, ok := seriesHashCache.Fetch(id)
@@ -805,7 +835,45 @@ func populateChunk(out *storepb.AggrChunk, in chunkenc.Chunk, aggrs []storepb.Ag
 		out.Raw = &storepb.Chunk{Type: storepb.Chunk_XOR, Data: b}
 		return nil
 	}
-	return errors.Errorf("unsupported chunk encoding %d", in.Encoding())
+	if in.Encoding() != downsample.ChunkEncAggr {
+		return errors.Errorf("unsupported chunk encoding %d", in.Encoding())
+	}
+
+	// Chunks of downsampled blocks hold all the aggregates, but we only return the requested ones.
+	ac := downsample.AggrChunk(in.Bytes())
+
+	for _, at := range aggrs {
+		var (
+			aggrType downsample.AggrType
+			outChunk **storepb.Chunk
+		)
+
+		switch at {
+		case storepb.Aggr_COUNT:
+			aggrType, outChunk = downsample.AggrCount, &out.Count
+		case storepb.Aggr_SUM:
+			aggrType, outChunk = downsample.AggrSum, &out.Sum
+		case storepb.Aggr_MIN:
+			aggrType, outChunk = downsample.AggrMin, &out.Min
+		case storepb.Aggr_MAX:
+			aggrType, outChunk = downsample.AggrMax, &out.Max
+		case storepb.Aggr_COUNTER:
+			aggrType, outChunk = downsample.AggrCounter, &out.Counter
+		default:
+			return errors.Errorf("unsupported aggregate %s for downsampled chunk", at)
+		}
+
+		x, err := ac.Get(aggrType)
+		if err != nil {
+			return errors.Wrapf(err, "get %s aggregate", aggrType)
+		}
+		b, err := save(x.Bytes())
+		if err != nil {
+			return err
+		}
+		*outChunk = &storepb.Chunk{Type: storepb.Chunk_XOR, Data: b}
+	}
+	return nil
 }
 
 // debugFoundBlockSetOverview logs on debug level what exactly blocks we used for query in terms of
@@ -870,7 +938,7 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 	var (
 		ctx              = srv.Context()
 		stats            = &queryStats{}
-		res              []storepb.SeriesSet
+		res              []*bucketSeriesSet
 		mtx              sync.Mutex
 		g, gctx          = errgroup.WithContext(ctx)
 		resHints         = &hintspb.SeriesResponseHints{}
@@ -949,6 +1017,7 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 				req.SkipChunks,
 				req.MinTime, req.MaxTime,
 				req.Aggregates,
+				s.postingsPlanningRatio,
 				s.logger,
 			)
 			if err != nil {
@@ -990,6 +1059,10 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 		s.metrics.cachedPostingsCompressedSizeBytes.Add(float64(stats.cachedPostingsCompressedSizeSum))
 		s.metrics.seriesHashCacheRequests.Add(float64(stats.seriesHashCacheRequests))
 		s.metrics.seriesHashCacheHits.Add(float64(stats.seriesHashCacheHits))
+		s.metrics.expandedPostingsCacheSavedBytes.Add(float64(stats.expandedPostingsCacheSavedBytes))
+		s.metrics.seriesPostingsStrategy.WithLabelValues(postingsStrategyAllMatchers).Add(float64(stats.blocksQueriedWithAllMatchers))
+		s.metrics.seriesPostingsStrategy.WithLabelValues(postingsStrategyLazyMatchers).Add(float64(stats.blocksQueriedWithLazyMatchers))
+		s.metrics.seriesFilteredByLazyMatchers.Add(float64(stats.seriesFilteredByLazyMatchers))
 
 		level.Debug(s.logger).Log("msg", "stats query processed",
 			"stats", fmt.Sprintf("%+v", stats), "err", err)
@@ -1013,59 +1086,168 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 		s.metrics.seriesBlocksQueried.Observe(float64(stats.blocksQueried))
 	}
 	// Merge the sub-results from each selected block.
+	streamingChunks := req.StreamingChunksBatchSize > 0 && !req.SkipChunks
 	tracing.DoInSpan(ctx, "bucket_store_merge_all", func(ctx context.Context) {
 		begin := time.Now()
 
 		// NOTE: We "carefully" assume series and chunks are sorted within each SeriesSet. This should be guaranteed by
 		// blockSeries method. In worst case deduplication logic won't deduplicate correctly, which will be accounted later.
-		set := storepb.MergeSeriesSets(res...)
-		for set.Next() {
-			var series storepb.Series
-
-			stats.mergedSeriesCount++
-
-			var lset labels.Labels
-			if req.SkipChunks {
-				lset, _ = set.At()
-			} else {
-				lset, series.Chunks = set.At()
-
-				stats.mergedChunksCount += len(series.Chunks)
-				s.metrics.chunkSizeBytes.Observe(float64(chunksSize(series.Chunks)))
-			}
-			series.Labels = labelpb.ZLabelsFromPromLabels(lset)
-			if err = srv.Send(storepb.NewSeriesResponse(&series)); err != nil {
-				err = status.Error(codes.Unknown, errors.Wrap(err, "send series response").Error())
-				return
+		newSet := func() storepb.SeriesSet {
+			sets := make([]storepb.SeriesSet, 0, len(res))
+			for _, r := range res {
+				sets = append(sets, r.rewind())
 			}
+			return storepb.MergeSeriesSets(sets...)
 		}
-		if set.Err() != nil {
-			err = status.Error(codes.Unknown, errors.Wrap(set.Err(), "expand series set").Error())
+		if streamingChunks {
+			err = s.sendStreamingSeriesAndChunks(newSet, int(req.StreamingChunksBatchSize), resHints, srv, stats)
+		} else {
+			err = s.sendSeries(newSet(), req.SkipChunks, srv, stats)
+		}
+		if err != nil {
 			return
 		}
-		stats.mergeDuration = time.Since(begin)
-		s.metrics.seriesMergeDuration.Observe(stats.mergeDuration.Seconds())
 
-		err = nil
+		// The time spent blocked on the client reading the responses is not accounted as merge time.
+		stats.mergeDuration = time.Since(begin) - stats.sendDuration
+		s.metrics.seriesMergeDuration.Observe(stats.mergeDuration.Seconds())
 	})
+	if err != nil {
+		return err
+	}
+
+	// When streaming chunks, the hints have already been sent before the chunks.
+	if s.enableSeriesResponseHints && !streamingChunks {
+		err = s.sendHints(resHints, srv)
+	}
+
+	return err
+}
+
+// sendSeries sends each series of the set, with its chunks unless skipChunks is true, in a single response.
+func (s *BucketStore) sendSeries(set storepb.SeriesSet, skipChunks bool, srv storepb.Store_SeriesServer, stats *queryStats) error {
+	for set.Next() {
+		var series storepb.Series
 
+		stats.mergedSeriesCount++
+
+		var lset labels.Labels
+		if skipChunks {
+			lset, _ = set.At()
+		} else {
+			lset, series.Chunks = set.At()
+
+			stats.mergedChunksCount += len(series.Chunks)
+			s.metrics.chunkSizeBytes.Observe(float64(chunksSize(series.Chunks)))
+		}
+		series.Labels = labelpb.ZLabelsFromPromLabels(lset)
+		if err := sendSeriesResponse(srv, storepb.NewSeriesResponse(&series), stats); err != nil {
+			return status.Error(codes.Unknown, errors.Wrap(err, "send series response").Error())
+		}
+	}
+	if set.Err() != nil {
+		return status.Error(codes.Unknown, errors.Wrap(set.Err(), "expand series set").Error())
+	}
+	return nil
+}
+
+// sendStreamingSeriesAndChunks sends the labels of all series of the set in batches, followed by the
+// response hints, and then the chunks of the series in batches of the same size. The series are merged
+// twice, once to send their labels and once to send their chunks, so that only a batch of merged series
+// is held in memory at a time. Since gRPC applies flow control to the stream, sending the chunks blocks
+// until the client reads them.
+func (s *BucketStore) sendStreamingSeriesAndChunks(newSet func() storepb.SeriesSet, batchSize int, resHints *hintspb.SeriesResponseHints, srv storepb.Store_SeriesServer, stats *queryStats) error {
+	seriesBatch := &storepb.StreamingSeriesBatch{Series: make([]*storepb.StreamingSeries, 0, batchSize)}
+
+	set := newSet()
+	for set.Next() {
+		lset, _ := set.At()
+		stats.mergedSeriesCount++
+
+		seriesBatch.Series = append(seriesBatch.Series, &storepb.StreamingSeries{Labels: labelpb.ZLabelsFromPromLabels(lset)})
+
+		if len(seriesBatch.Series) == batchSize {
+			if err := sendSeriesResponse(srv, storepb.NewStreamingSeriesResponse(seriesBatch), stats); err != nil {
+				return status.Error(codes.Unknown, errors.Wrap(err, "send streaming series response").Error())
+			}
+			seriesBatch.Series = seriesBatch.Series[:0]
+		}
+	}
+	if set.Err() != nil {
+		return status.Error(codes.Unknown, errors.Wrap(set.Err(), "expand series set").Error())
+	}
+
+	// The hints are sent before the end of the series stream, so that the client
+	// knows the queried blocks before starting to read the chunks.
 	if s.enableSeriesResponseHints {
-		var anyHints *types.Any
+		if err := s.sendHints(resHints, srv); err != nil {
+			return err
+		}
+	}
 
-		if anyHints, err = types.MarshalAny(resHints); err != nil {
-			err = status.Error(codes.Unknown, errors.Wrap(err, "marshal series response hints").Error())
-			return
+	// The last batch of series, which may be empty, marks the end of the series stream.
+	seriesBatch.IsEndOfSeriesStream = true
+	if err := sendSeriesResponse(srv, storepb.NewStreamingSeriesResponse(seriesBatch), stats); err != nil {
+		return status.Error(codes.Unknown, errors.Wrap(err, "send streaming series response").Error())
+	}
+
+	var (
+		chunksBatch = &storepb.StreamingChunksBatch{Series: make([]*storepb.StreamingChunks, 0, batchSize)}
+		seriesIdx   = 0
+	)
+
+	set = newSet()
+	for set.Next() {
+		_, chks := set.At()
+		stats.mergedChunksCount += len(chks)
+		s.metrics.chunkSizeBytes.Observe(float64(chunksSize(chks)))
+
+		chunksBatch.Series = append(chunksBatch.Series, &storepb.StreamingChunks{SeriesIndex: uint64(seriesIdx), Chunks: chks})
+		seriesIdx++
+
+		if len(chunksBatch.Series) == batchSize {
+			if err := sendSeriesResponse(srv, storepb.NewStreamingChunksResponse(chunksBatch), stats); err != nil {
+				return status.Error(codes.Unknown, errors.Wrap(err, "send streaming chunks response").Error())
+			}
+			chunksBatch.Series = chunksBatch.Series[:0]
 		}
+	}
+	if set.Err() != nil {
+		return status.Error(codes.Unknown, errors.Wrap(set.Err(), "expand series set").Error())
+	}
+	if seriesIdx != stats.mergedSeriesCount {
+		return status.Error(codes.Internal, fmt.Sprintf("merged %d series to send the chunks of, but sent the labels of %d series", seriesIdx, stats.mergedSeriesCount))
+	}
 
-		if err = srv.Send(storepb.NewHintsSeriesResponse(anyHints)); err != nil {
-			err = status.Error(codes.Unknown, errors.Wrap(err, "send series response hints").Error())
-			return
+	if len(chunksBatch.Series) > 0 {
+		if err := sendSeriesResponse(srv, storepb.NewStreamingChunksResponse(chunksBatch), stats); err != nil {
+			return status.Error(codes.Unknown, errors.Wrap(err, "send streaming chunks response").Error())
 		}
 	}
 
+	return nil
+}
+
+// sendSeriesResponse sends the response, accounting the time spent blocked on the client in the stats.
+func sendSeriesResponse(srv storepb.Store_SeriesServer, resp *storepb.SeriesResponse, stats *queryStats) error {
+	begin := time.Now()
+	err := srv.Send(resp)
+	stats.sendDuration += time.Since(begin)
 	return err
 }
 
+func (s *BucketStore) sendHints(resHints *hintspb.SeriesResponseHints, srv storepb.Store_SeriesServer) error {
+	anyHints, err := types.MarshalAny(resHints)
+	if err != nil {
+		return status.Error(codes.Unknown, errors.Wrap(err, "marshal series response hints").Error())
+	}
+
+	if err := srv.Send(storepb.NewHintsSeriesResponse(anyHints)); err != nil {
+		return status.Error(codes.Unknown, errors.Wrap(err, "send series response hints").Error())
+	}
+	return nil
+}
+
 func chunksSize(chks []storepb.AggrChunk) (size int) {
 	for _, chk := range chks {
 		size += chk.Size() // This gets the encoded proto size.
@@ -1171,7 +1353,7 @@ func blockLabelNames(ctx context.Context, indexr *bucketIndexReader, matchers []
 
 	// We ignore request's min/max time and query the entire block to make the result cacheable.
 	minTime, maxTime := indexr.block.meta.MinTime, indexr.block.meta.MaxTime
-	seriesSet, _, err := blockSeries(ctx, indexr, nil, matchers, nil, nil, nil, seriesLimiter, true, minTime, maxTime, nil, logger)
+	seriesSet, _, err := blockSeries(ctx, indexr, nil, matchers, nil, nil, nil, seriesLimiter, true, minTime, maxTime, nil, 0, logger)
 	if err != nil {
 		return nil, errors.Wrap(err, "fetch series")
 	}
@@ -1423,12 +1605,11 @@ type bucketBlockSet struct {
 }
 
 // newBucketBlockSet initializes a new set with the known downsampling windows hard-configured.
-// (Mimir only supports no-downsampling)
 // The set currently does not support arbitrary ranges.
 func newBucketBlockSet() *bucketBlockSet {
 	return &bucketBlockSet{
-		resolutions: []int64{0},
-		blocks:      make([][]*bucketBlock, 3),
+		resolutions: downsample.Resolutions,
+		blocks:      make([][]*bucketBlock, len(downsample.Resolutions)),
 	}
 }
 
@@ -1479,10 +1660,12 @@ func int64index(s []int64, x int64) int {
 
 // getFor returns a time-ordered list of blocks that cover date between mint and maxt.
 // Blocks with the biggest resolution possible but not bigger than the given max resolution are returned.
-// It supports overlapping blocks.
+// It supports overlapping blocks. Blocks not matching the block matchers are considered missing, so that
+// the time range they cover is filled with the matching blocks of a lower resolution, if any. The same
+// applies to downsampled blocks not covering every compactor shard of the blocks they would replace.
 //
 // NOTE: s.blocks are expected to be sorted in minTime order.
-func (s *bucketBlockSet) getFor(mint, maxt, maxResolutionMillis int64, blockMatchers []*labels.Matcher) (bs []*bucketBlock) {
+func (s *bucketBlockSet) getFor(mint, maxt, maxResolutionMillis int64, blockMatchers []*labels.Matcher) []*bucketBlock {
 	if mint > maxt {
 		return nil
 	}
@@ -1490,16 +1673,60 @@ func (s *bucketBlockSet) getFor(mint, maxt, maxResolutionMillis int64, blockMatc
 	s.mtx.RLock()
 	defer s.mtx.RUnlock()
 
+	// Collect the blocks overlapping the time range and matching the block-level matchers, if any.
+	var (
+		matching    []*bucketBlock
+		resolutions []int
+		refs        []downsample.BlockRef
+	)
+	for i, bs := range s.blocks {
+		for _, b := range bs {
+			// NOTE: Block intervals are half-open: [b.MinTime, b.MaxTime).
+			if b.meta.MaxTime <= mint || b.meta.MinTime > maxt {
+				continue
+			}
+			if len(blockMatchers) > 0 && !b.matchLabels(blockMatchers) {
+				continue
+			}
+
+			matching = append(matching, b)
+			resolutions = append(resolutions, i)
+			refs = append(refs, downsample.BlockRef{
+				MinTime:          b.meta.MinTime,
+				MaxTime:          b.meta.MaxTime,
+				Resolution:       b.meta.Thanos.Downsample.Resolution,
+				CompactorShardID: b.meta.Thanos.Labels[mimir_tsdb.CompactorShardIDExternalLabel],
+			})
+		}
+	}
+
+	incomplete := downsample.IncompleteBlocks(refs)
+	candidates := make([][]*bucketBlock, len(s.blocks))
+	for j, b := range matching {
+		if _, ok := incomplete[j]; ok {
+			continue
+		}
+		candidates[resolutions[j]] = append(candidates[resolutions[j]], b)
+	}
+
 	// Find first matching resolution.
 	i := 0
 	for ; i < len(s.resolutions) && s.resolutions[i] > maxResolutionMillis; i++ {
 	}
 
-	// Fill the given interval with the blocks for the current resolution.
-	// Our current resolution might not cover all data, so recursively fill the gaps with higher resolution blocks
-	// if there is any.
+	return fillWithBlocks(candidates, i, mint, maxt)
+}
+
+// fillWithBlocks fills the time range between mint and maxt with the candidate blocks at the i-th resolution.
+// Our current resolution might not cover all data, so recursively fill the gaps with higher resolution blocks
+// if there is any.
+func fillWithBlocks(candidates [][]*bucketBlock, i int, mint, maxt int64) (bs []*bucketBlock) {
+	if mint > maxt || i >= len(candidates) {
+		return nil
+	}
+
 	start := mint
-	for _, b := range s.blocks[i] {
+	for _, b := range candidates[i] {
 		if b.meta.MaxTime <= mint {
 			continue
 		}
@@ -1508,23 +1735,15 @@ func (s *bucketBlockSet) getFor(mint, maxt, maxResolutionMillis int64, blockMatc
 			break
 		}
 
-		if i+1 < len(s.resolutions) {
-			bs = append(bs, s.getFor(start, b.meta.MinTime-1, s.resolutions[i+1], blockMatchers)...)
-		}
+		bs = append(bs, fillWithBlocks(candidates, i+1, start, b.meta.MinTime-1)...)
+		bs = append(bs, b)
 
-		// Include the block in the list of matching ones only if there are no block-level matchers
-		// or they actually match.
-		if len(blockMatchers) == 0 || b.matchLabels(blockMatchers) {
-			bs = append(bs, b)
+		if b.meta.MaxTime > start {
+			start = b.meta.MaxTime
 		}
-
-		start = b.meta.MaxTime
 	}
 
-	if i+1 < len(s.resolutions) {
-		bs = append(bs, s.getFor(start, maxt, s.resolutions[i+1], blockMatchers)...)
-	}
-	return bs
+	return append(bs, fillWithBlocks(candidates, i+1, start, maxt)...)
 }
 
 // bucketBlock represents a block that is located in a bucket. It holds intermediate
@@ -1740,11 +1959,44 @@ func (r *bucketIndexReader) ExpandedPostings(ctx context.Context, ms []*labels.M
 		span.Finish()
 	}()
 	var promise expandedPostingsPromise
-	promise, loaded = r.expandedPostingsPromise(ctx, ms)
+	promise, loaded = r.expandedPostingsPromise(ctx, ms, true)
 	returnRefs, cached, returnErr = promise(ctx)
 	return returnRefs, returnErr
 }
 
+// PlannedExpandedPostings returns the expanded postings of the matchers planned to be applied on postings, along with
+// the postings plan (see planPostings). Planning the postings requires reading the index-header for every matcher, so
+// the expanded postings of all the matchers are looked up in the index cache first, and the postings are planned only
+// on a cache miss.
+func (r *bucketIndexReader) PlannedExpandedPostings(ctx context.Context, ms []*labels.Matcher, postingsPlanningRatio float64) ([]storage.SeriesRef, postingsPlan, error) {
+	// Planning is a no-op in this case, and ExpandedPostings() looks up the cache on its own.
+	if postingsPlanningRatio <= 0 || len(ms) < 2 {
+		refs, err := r.ExpandedPostings(ctx, ms)
+		if err != nil {
+			return nil, postingsPlan{}, errors.Wrap(err, "expanded matching posting")
+		}
+		return refs, postingsPlan{postingsMatchers: ms}, nil
+	}
+
+	if refs, ok := r.fetchCachedExpandedPostings(ctx, r.block.userID, indexcache.CanonicalLabelMatchersKey(ms)); ok {
+		return refs, postingsPlan{postingsMatchers: ms}, nil
+	}
+
+	plan, err := planPostings(r.block.indexHeaderReader, ms, postingsPlanningRatio)
+	if err != nil {
+		return nil, postingsPlan{}, errors.Wrap(err, "plan postings")
+	}
+
+	// The expanded postings of all the matchers have just been looked up in the cache, so there's
+	// no need to look them up again if they're all applied on postings.
+	promise, _ := r.expandedPostingsPromise(ctx, plan.postingsMatchers, len(plan.lazyMatchers) > 0)
+	refs, _, err := promise(ctx)
+	if err != nil {
+		return nil, postingsPlan{}, errors.Wrap(err, "expanded matching posting")
+	}
+	return refs, plan, nil
+}
+
 // expandedPostingsPromise is the promise returned by bucketIndexReader.expandedPostingsPromise.
 // The second return value indicates whether the returned data comes from the cache.
 type expandedPostingsPromise func(ctx context.Context) ([]storage.SeriesRef, bool, error)
@@ -1754,9 +2006,10 @@ type expandedPostingsPromise func(ctx context.Context) ([]storage.SeriesRef, boo
 // While first call is blocking, concurrent calls with same matchers will return a promise for the same results, without recalculating them.
 // The second value returned by this function is set to true when this call just loaded a promise created by another goroutine.
 // The promise returned by this function returns a bool value fromCache, set to true when data was loaded from cache.
+// The index cache is looked up only if lookupCache is true, while the computed postings are always stored in the cache.
 // TODO: if promise creator's context is canceled, the entire promise will fail, even if there are more callers waiting for the results
 // TODO: https://github.com/grafana/mimir/issues/331
-func (r *bucketIndexReader) expandedPostingsPromise(ctx context.Context, ms []*labels.Matcher) (promise expandedPostingsPromise, loaded bool) {
+func (r *bucketIndexReader) expandedPostingsPromise(ctx context.Context, ms []*labels.Matcher, lookupCache bool) (promise expandedPostingsPromise, loaded bool) {
 	var (
 		refs   []storage.SeriesRef
 		err    error
@@ -1792,9 +2045,11 @@ func (r *bucketIndexReader) expandedPostingsPromise(ctx context.Context, ms []*l
 	defer close(done)
 	defer r.block.expandedPostingsPromises.Delete(key)
 
-	refs, cached = r.fetchCachedExpandedPostings(ctx, r.block.userID, key)
-	if cached {
-		return promise, false
+	if lookupCache {
+		refs, cached = r.fetchCachedExpandedPostings(ctx, r.block.userID, key)
+		if cached {
+			return promise, false
+		}
 	}
 	refs, err = r.expandedPostings(ctx, ms)
 	if err != nil {
@@ -1830,6 +2085,10 @@ func (r *bucketIndexReader) fetchCachedExpandedPostings(ctx context.Context, use
 		level.Warn(r.block.logger).Log("msg", "can't expand decoded expanded postings cache", "err", err, "matchers_key", key, "block", r.block.meta.ULID)
 		return nil, false
 	}
+
+	// Computing the expanded postings requires fetching at least the postings in the result, each encoded
+	// in 4 bytes in the index, plus the 4 bytes length of the postings list.
+	r.stats.expandedPostingsCacheSavedBytes += 4 + 4*len(refs)
 	return refs, true
 }
 
@@ -2739,6 +2998,12 @@ type queryStats struct {
 	seriesHashCacheRequests int
 	seriesHashCacheHits     int
 
+	expandedPostingsCacheSavedBytes int
+
+	blocksQueriedWithAllMatchers  int
+	blocksQueriedWithLazyMatchers int
+	seriesFilteredByLazyMatchers  int
+
 	chunksTouched          int
 	chunksTouchedSizeSum   int
 	chunksFetched          int
@@ -2750,6 +3015,7 @@ type queryStats struct {
 	mergedSeriesCount int
 	mergedChunksCount int
 	mergeDuration     time.Duration
+	sendDuration      time.Duration
 }
 
 func (s queryStats) merge(o *queryStats) *queryStats {
@@ -2781,6 +3047,12 @@ func (s queryStats) merge(o *queryStats) *queryStats {
 	s.seriesHashCacheRequests += o.seriesHashCacheRequests
 	s.seriesHashCacheHits += o.seriesHashCacheHits
 
+	s.expandedPostingsCacheSavedBytes += o.expandedPostingsCacheSavedBytes
+
+	s.blocksQueriedWithAllMatchers += o.blocksQueriedWithAllMatchers
+	s.blocksQueriedWithLazyMatchers += o.blocksQueriedWithLazyMatchers
+	s.seriesFilteredByLazyMatchers += o.seriesFilteredByLazyMatchers
+
 	s.chunksTouched += o.chunksTouched
 	s.chunksTouchedSizeSum += o.chunksTouchedSizeSum
 	s.chunksFetched += o.chunksFetched
@@ -2792,6 +3064,7 @@ func (s queryStats) merge(o *queryStats) *queryStats {
 	s.mergedSeriesCount += o.mergedSeriesCount
 	s.mergedChunksCount += o.mergedChunksCount
 	s.mergeDuration += o.mergeDuration
+	s.sendDuration += o.sendDuration
 
 	return &s
 }
@@ -395,18 +395,25 @@ func testBucketStore_e2e(t *testing.T, ctx context.Context, s *storeSuite) {
 			},
 		},
 	} {
-		if ok := t.Run(fmt.Sprint(i), func(t *testing.T) {
-			srv := newBucketStoreSeriesServer(ctx)
-
-			assert.NoError(t, s.store.Series(tcase.req, srv))
-			assert.Equal(t, len(tcase.expected), len(srv.SeriesSet))
-
-			for i, s := range srv.SeriesSet {
-				assert.Equal(t, tcase.expected[i], s.Labels)
-				assert.Equal(t, tcase.expectedChunkLen, len(s.Chunks))
+		// Run each test case both without and with streaming chunks, using different batch sizes.
+		for _, streamingBatchSize := range []uint64{0, 1, 5} {
+			if ok := t.Run(fmt.Sprintf("%d,streamingBatchSize=%d", i, streamingBatchSize), func(t *testing.T) {
+				req := *tcase.req
+				req.StreamingChunksBatchSize = streamingBatchSize
+
+				srv := newBucketStoreSeriesServer(ctx)
+
+				assert.NoError(t, s.store.Series(&req, srv))
+				assert.Equal(t, len(tcase.expected), len(srv.SeriesSet))
+				assert.Equal(t, streamingBatchSize > 0 && !req.SkipChunks, srv.IsEndOfSeriesStream)
+
+				for i, s := range srv.SeriesSet {
+					assert.Equal(t, tcase.expected[i], s.Labels)
+					assert.Equal(t, tcase.expectedChunkLen, len(s.Chunks))
+				}
+			}); !ok {
+				return
 			}
-		}); !ok {
-			return
 		}
 	}
 }
@@ -28,6 +28,9 @@ type bucketStoreSeriesServer struct {
 	SeriesSet []*storepb.Series
 	Warnings  storage.Warnings
 	Hints     hintspb.SeriesResponseHints
+
+	// Set when the end of the series stream has been received, when streaming chunks.
+	IsEndOfSeriesStream bool
 }
 
 func newBucketStoreSeriesServer(ctx context.Context) *bucketStoreSeriesServer {
@@ -64,6 +67,42 @@ func (s *bucketStoreSeriesServer) Send(r *storepb.SeriesResponse) error {
 		s.SeriesSet = append(s.SeriesSet, copiedSeries)
 	}
 
+	// When streaming chunks, the series are rebuilt from their labels and chunks.
+	if recvSeries := r.GetStreamingSeries(); recvSeries != nil {
+		if s.IsEndOfSeriesStream {
+			return errors.New("received series labels after the end of the series stream")
+		}
+
+		for _, series := range recvSeries.Series {
+			s.SeriesSet = append(s.SeriesSet, &storepb.Series{Labels: series.Labels})
+		}
+		s.IsEndOfSeriesStream = recvSeries.IsEndOfSeriesStream
+	}
+
+	if recvChunks := r.GetStreamingChunks(); recvChunks != nil {
+		if !s.IsEndOfSeriesStream {
+			return errors.New("received series chunks before the end of the series stream")
+		}
+
+		// Copy the chunks for the same reason of full series.
+		recvChunksData, err := recvChunks.Marshal()
+		if err != nil {
+			return errors.Wrap(err, "marshal received chunks")
+		}
+
+		copiedChunks := &storepb.StreamingChunksBatch{}
+		if err = copiedChunks.Unmarshal(recvChunksData); err != nil {
+			return errors.Wrap(err, "unmarshal received chunks")
+		}
+
+		for _, series := range copiedChunks.Series {
+			if series.SeriesIndex >= uint64(len(s.SeriesSet)) {
+				return errors.Errorf("received chunks for unknown series index %d", series.SeriesIndex)
+			}
+			s.SeriesSet[series.SeriesIndex].Chunks = series.Chunks
+		}
+	}
+
 	return nil
 }
 
@@ -46,6 +46,11 @@ type BucketStoreMetrics struct {
 	seriesHashCacheRequests prometheus.Counter
 	seriesHashCacheHits     prometheus.Counter
 
+	expandedPostingsCacheSavedBytes prometheus.Counter
+
+	seriesPostingsStrategy       *prometheus.CounterVec
+	seriesFilteredByLazyMatchers prometheus.Counter
+
 	seriesFetchDuration   prometheus.Histogram
 	postingsFetchDuration prometheus.Histogram
 
@@ -167,6 +172,20 @@ func NewBucketStoreMetrics(reg prometheus.Registerer) *BucketStoreMetrics {
 		Help: "Total number of fetch hits to the in-memory series hash cache.",
 	})
 
+	m.expandedPostingsCacheSavedBytes = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_expanded_postings_cache_saved_bytes_total",
+		Help: "Total number of postings bytes which haven't been fetched because the expanded postings were found in the index cache. It's a lower bound, computed from the size of the expanded postings in the index format.",
+	})
+
+	m.seriesPostingsStrategy = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_series_postings_strategy_total",
+		Help: "Total number of blocks queried by Series() calls when postings planning is enabled, partitioned by the strategy used to select the series: '" + postingsStrategyAllMatchers + "' when the postings of all matchers are intersected, '" + postingsStrategyLazyMatchers + "' when some matchers are applied by filtering the series labels.",
+	}, []string{"strategy"})
+	m.seriesFilteredByLazyMatchers = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_series_filtered_by_lazy_matchers_total",
+		Help: "Total number of series loaded by Series() calls and then discarded because they don't match the matchers applied by filtering the series labels.",
+	})
+
 	m.chunkSizeBytes = promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
 		Name: "cortex_bucket_store_sent_chunk_size_bytes",
 		Help: "Size in bytes of the chunks for the single series, which is adequate to the gRPC message size sent to querier.",
@@ -468,6 +468,9 @@ func (u *BucketStores) getOrCreateStore(userID string) (*BucketStore, error) {
 	if u.logLevel.String() == "debug" {
 		bucketStoreOpts = append(bucketStoreOpts, WithDebugLogging())
 	}
+	if u.cfg.BucketStore.PostingsPlanningEnabled {
+		bucketStoreOpts = append(bucketStoreOpts, WithPostingsPlanning(u.cfg.BucketStore.PostingsPlanningLazyMatchersRatio))
+	}
 
 	bs, err := NewBucketStore(
 		userID,
@@ -21,6 +21,7 @@ import (
 	"runtime"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -52,6 +53,7 @@ import (
 
 	"github.com/grafana/mimir/pkg/storage/sharding"
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/downsample"
 	"github.com/grafana/mimir/pkg/storegateway/hintspb"
 	"github.com/grafana/mimir/pkg/storegateway/indexcache"
 	"github.com/grafana/mimir/pkg/storegateway/indexheader"
@@ -182,6 +184,171 @@ func TestBucketBlockSet_remove(t *testing.T) {
 	assert.Equal(t, input[2].id, res[1].meta.ULID)
 }
 
+func TestBucketBlockSet_getFor(t *testing.T) {
+	type resBlock struct {
+		id         ulid.ULID
+		mint, maxt int64
+		resolution int64
+		shardID    string
+	}
+
+	var (
+		raw1    = resBlock{id: ulid.MustNew(1, nil), mint: 0, maxt: 100}
+		raw2    = resBlock{id: ulid.MustNew(2, nil), mint: 100, maxt: 200}
+		raw3    = resBlock{id: ulid.MustNew(3, nil), mint: 200, maxt: 300}
+		res5m1  = resBlock{id: ulid.MustNew(4, nil), mint: 0, maxt: 100, resolution: downsample.Resolution5m}
+		res5m2  = resBlock{id: ulid.MustNew(5, nil), mint: 100, maxt: 200, resolution: downsample.Resolution5m}
+		res1h1  = resBlock{id: ulid.MustNew(6, nil), mint: 0, maxt: 100, resolution: downsample.Resolution1h}
+		unknown = resBlock{id: ulid.MustNew(7, nil), mint: 0, maxt: 100, resolution: 1000}
+
+		// Sharded blocks, where only the first compactor shard has been downsampled.
+		rawShard1   = resBlock{id: ulid.MustNew(8, nil), mint: 400, maxt: 500, shardID: "1_of_2"}
+		rawShard2   = resBlock{id: ulid.MustNew(9, nil), mint: 400, maxt: 500, shardID: "2_of_2"}
+		res5mShard1 = resBlock{id: ulid.MustNew(10, nil), mint: 400, maxt: 500, resolution: downsample.Resolution5m, shardID: "1_of_2"}
+	)
+
+	set := newBucketBlockSet()
+	for _, in := range []resBlock{raw1, raw2, raw3, res5m1, res5m2, res1h1, rawShard1, rawShard2, res5mShard1} {
+		var m metadata.Meta
+		m.ULID = in.id
+		m.MinTime = in.mint
+		m.MaxTime = in.maxt
+		m.Thanos.Downsample.Resolution = in.resolution
+		if in.shardID != "" {
+			m.Thanos.Labels = map[string]string{mimir_tsdb.CompactorShardIDExternalLabel: in.shardID}
+		}
+		require.NoError(t, set.add(&bucketBlock{meta: &m, blockLabels: labels.FromStrings(block.BlockIDLabel, in.id.String())}))
+	}
+
+	// Blocks with an unsupported resolution can't be added.
+	var m metadata.Meta
+	m.ULID = unknown.id
+	m.Thanos.Downsample.Resolution = unknown.resolution
+	require.Error(t, set.add(&bucketBlock{meta: &m}))
+
+	blockIDMatcher := func(blocks ...resBlock) []*labels.Matcher {
+		ids := make([]string, 0, len(blocks))
+		for _, b := range blocks {
+			ids = append(ids, b.id.String())
+		}
+		return []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, block.BlockIDLabel, strings.Join(ids, "|"))}
+	}
+
+	tests := map[string]struct {
+		mint, maxt    int64
+		maxResolution int64
+		blockMatchers []*labels.Matcher
+		expected      []resBlock
+	}{
+		"raw resolution": {
+			mint: 0, maxt: 300, maxResolution: 0,
+			expected: []resBlock{raw1, raw2, raw3},
+		},
+		"5m resolution, filling the gaps with raw blocks": {
+			mint: 0, maxt: 300, maxResolution: downsample.Resolution5m,
+			expected: []resBlock{res5m1, res5m2, raw3},
+		},
+		"1h resolution, filling the gaps with 5m and raw blocks": {
+			mint: 0, maxt: 300, maxResolution: downsample.Resolution1h,
+			expected: []resBlock{res1h1, res5m2, raw3},
+		},
+		"resolution between the supported ones": {
+			mint: 0, maxt: 300, maxResolution: 2 * downsample.Resolution5m,
+			expected: []resBlock{res5m1, res5m2, raw3},
+		},
+		"1h resolution, partial time range": {
+			mint: 150, maxt: 300, maxResolution: downsample.Resolution1h,
+			expected: []resBlock{res5m2, raw3},
+		},
+		"1h resolution with block matchers: blocks not matching are filled with lower resolution blocks": {
+			mint: 0, maxt: 300, maxResolution: downsample.Resolution1h,
+			blockMatchers: blockIDMatcher(raw1, res5m2, raw3),
+			expected:      []resBlock{raw1, res5m2, raw3},
+		},
+		"5m resolution, only one of the compactor shards has been downsampled": {
+			mint: 400, maxt: 500, maxResolution: downsample.Resolution5m,
+			expected: []resBlock{rawShard1, rawShard2},
+		},
+		"5m resolution with block matchers, only the downsampled compactor shard matches": {
+			mint: 400, maxt: 500, maxResolution: downsample.Resolution5m,
+			blockMatchers: blockIDMatcher(res5mShard1),
+			expected:      []resBlock{res5mShard1},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var actual []ulid.ULID
+			for _, b := range set.getFor(testData.mint, testData.maxt, testData.maxResolution, testData.blockMatchers) {
+				actual = append(actual, b.meta.ULID)
+			}
+
+			var expected []ulid.ULID
+			for _, b := range testData.expected {
+				expected = append(expected, b.id)
+			}
+
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestPopulateChunk(t *testing.T) {
+	newXORChunk := func(ts int64, v float64) chunkenc.Chunk {
+		c := chunkenc.NewXORChunk()
+		app, err := c.Appender()
+		require.NoError(t, err)
+		app.Append(ts, v)
+		return c
+	}
+
+	save := func(b []byte) ([]byte, error) {
+		return append([]byte(nil), b...), nil
+	}
+
+	t.Run("raw chunk", func(t *testing.T) {
+		in := newXORChunk(1, 1)
+
+		var out storepb.AggrChunk
+		require.NoError(t, populateChunk(&out, rawChunk(append([]byte{byte(chunkenc.EncXOR)}, in.Bytes()...)), nil, save))
+		assert.Equal(t, &storepb.Chunk{Type: storepb.Chunk_XOR, Data: in.Bytes()}, out.Raw)
+	})
+
+	t.Run("aggregate chunk", func(t *testing.T) {
+		count, sum, counter := newXORChunk(1, 10), newXORChunk(1, 100), newXORChunk(1, 50)
+		in := downsample.EncodeAggrChunk([5]chunkenc.Chunk{
+			downsample.AggrCount:   count,
+			downsample.AggrSum:     sum,
+			downsample.AggrMin:     newXORChunk(1, 1),
+			downsample.AggrMax:     newXORChunk(1, 20),
+			downsample.AggrCounter: counter,
+		})
+		raw := rawChunk(append([]byte{byte(downsample.ChunkEncAggr)}, in.Bytes()...))
+
+		// Only the requested aggregates are returned.
+		var out storepb.AggrChunk
+		require.NoError(t, populateChunk(&out, raw, []storepb.Aggr{storepb.Aggr_COUNT, storepb.Aggr_SUM}, save))
+		assert.Equal(t, storepb.AggrChunk{
+			Count: &storepb.Chunk{Type: storepb.Chunk_XOR, Data: count.Bytes()},
+			Sum:   &storepb.Chunk{Type: storepb.Chunk_XOR, Data: sum.Bytes()},
+		}, out)
+
+		out = storepb.AggrChunk{}
+		require.NoError(t, populateChunk(&out, raw, []storepb.Aggr{storepb.Aggr_COUNTER}, save))
+		assert.Equal(t, storepb.AggrChunk{
+			Counter: &storepb.Chunk{Type: storepb.Chunk_XOR, Data: counter.Bytes()},
+		}, out)
+	})
+
+	t.Run("aggregate chunk without the requested aggregate", func(t *testing.T) {
+		in := downsample.EncodeAggrChunk([5]chunkenc.Chunk{downsample.AggrCount: newXORChunk(1, 10)})
+		raw := rawChunk(append([]byte{byte(downsample.ChunkEncAggr)}, in.Bytes()...))
+
+		var out storepb.AggrChunk
+		require.Error(t, populateChunk(&out, raw, []storepb.Aggr{storepb.Aggr_MAX}, save))
+	})
+}
+
 // Regression tests against: https://github.com/thanos-io/thanos/issues/1983.
 func TestReadIndexCache_LoadSeries(t *testing.T) {
 	bkt := objstore.NewInMemBucket()
@@ -649,16 +816,20 @@ func TestBucketIndexReader_ExpandedPostings(t *testing.T) {
 
 		// first call succeeds and caches value
 		matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, "i", "^.+$")}
-		refs, err := b.indexReader().ExpandedPostings(context.Background(), matchers)
+		indexr := b.indexReader()
+		refs, err := indexr.ExpandedPostings(context.Background(), matchers)
 		require.NoError(t, err)
 		require.Equal(t, series, len(refs))
 		require.Equal(t, map[string]int{"i": 1}, labelValuesCalls, "Should have called LabelValues once for label 'i'.")
+		require.Equal(t, 0, indexr.stats.expandedPostingsCacheSavedBytes)
 
 		// second call uses cached value, so it doesn't call LabelValues again
-		refs, err = b.indexReader().ExpandedPostings(context.Background(), matchers)
+		indexr = b.indexReader()
+		refs, err = indexr.ExpandedPostings(context.Background(), matchers)
 		require.NoError(t, err)
 		require.Equal(t, series, len(refs))
 		require.Equal(t, map[string]int{"i": 1}, labelValuesCalls, "Should have used cached value, so it shouldn't call LabelValues again for label 'i'.")
+		require.Equal(t, 4+4*series, indexr.stats.expandedPostingsCacheSavedBytes)
 
 		// different matcher on same label should not be cached
 		differentMatchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchNotEqual, "i", "")}
@@ -668,6 +839,46 @@ func TestBucketIndexReader_ExpandedPostings(t *testing.T) {
 		require.Equal(t, map[string]int{"i": 2}, labelValuesCalls, "Should have called LabelValues again for label 'i'.")
 	})
 
+	t.Run("planned postings are looked up in the cache before planning", func(t *testing.T) {
+		labelValuesCalls := map[string]int{}
+		b := newTestBucketBlock()
+		b.indexHeaderReader = &interceptedIndexReader{
+			Reader: b.indexHeaderReader,
+			onLabelValuesCalled: func(name string) error {
+				labelValuesCalls[name]++
+				return nil
+			},
+		}
+		b.indexCache = newInMemoryIndexCache(t)
+
+		// A huge ratio never applies matchers lazily, so the expanded postings of all matchers are cached.
+		const ratio = 1e6
+		matchers := []*labels.Matcher{
+			labels.MustNewMatcher(labels.MatchRegexp, "i", "^.+$"),
+			labels.MustNewMatcher(labels.MatchRegexp, "n", "^.+$"),
+		}
+
+		// first call plans the postings and caches the expanded postings
+		refs, plan, err := b.indexReader().PlannedExpandedPostings(context.Background(), matchers, ratio)
+		require.NoError(t, err)
+		require.Equal(t, series, len(refs))
+		require.Equal(t, matchers, plan.postingsMatchers)
+		require.Empty(t, plan.lazyMatchers)
+		require.NotZero(t, labelValuesCalls["i"])
+		require.NotZero(t, labelValuesCalls["n"])
+
+		// second call finds the expanded postings in the cache, so it doesn't plan the postings again
+		expectedLabelValuesCalls := map[string]int{"i": labelValuesCalls["i"], "n": labelValuesCalls["n"]}
+		indexr := b.indexReader()
+		refs, plan, err = indexr.PlannedExpandedPostings(context.Background(), matchers, ratio)
+		require.NoError(t, err)
+		require.Equal(t, series, len(refs))
+		require.Equal(t, matchers, plan.postingsMatchers)
+		require.Empty(t, plan.lazyMatchers)
+		require.Equal(t, expectedLabelValuesCalls, labelValuesCalls, "Should have used cached value, so it shouldn't plan the postings again.")
+		require.Equal(t, 4+4*series, indexr.stats.expandedPostingsCacheSavedBytes)
+	})
+
 	t.Run("corrupt cached expanded postings don't make request fail", func(t *testing.T) {
 		b := newTestBucketBlock()
 		b.indexCache = corruptedExpandedPostingsCache{}
@@ -1565,6 +1776,58 @@ func TestSeries_BlockWithMultipleChunks(t *testing.T) {
 	}
 }
 
+func TestBucketStore_sendStreamingSeriesAndChunks(t *testing.T) {
+	chunk := func(minTime int64) storepb.AggrChunk {
+		return storepb.AggrChunk{MinTime: minTime, MaxTime: minTime + 10, Raw: &storepb.Chunk{Type: storepb.Chunk_XOR, Data: []byte{byte(minTime)}}}
+	}
+
+	// The series "b" is in both blocks, and is merged.
+	blocks := []*bucketSeriesSet{
+		newBucketSeriesSet([]seriesEntry{
+			{lset: labels.FromStrings("series", "a"), chks: []storepb.AggrChunk{chunk(0)}},
+			{lset: labels.FromStrings("series", "b"), chks: []storepb.AggrChunk{chunk(0)}},
+			{lset: labels.FromStrings("series", "d"), chks: []storepb.AggrChunk{chunk(0)}},
+		}),
+		newBucketSeriesSet([]seriesEntry{
+			{lset: labels.FromStrings("series", "b"), chks: []storepb.AggrChunk{chunk(20)}},
+			{lset: labels.FromStrings("series", "c"), chks: []storepb.AggrChunk{chunk(20)}},
+		}),
+	}
+	newSet := func() storepb.SeriesSet {
+		return storepb.MergeSeriesSets(blocks[0].rewind(), blocks[1].rewind())
+	}
+
+	store := &BucketStore{metrics: NewBucketStoreMetrics(nil)}
+	srv := &batchSizeRecordingSeriesServer{bucketStoreSeriesServer: newBucketStoreSeriesServer(context.Background())}
+	stats := &queryStats{}
+	require.NoError(t, store.sendStreamingSeriesAndChunks(newSet, 2, &hintspb.SeriesResponseHints{}, srv, stats))
+
+	assert.Equal(t, []*storepb.Series{
+		{Labels: []labelpb.ZLabel{{Name: "series", Value: "a"}}, Chunks: []storepb.AggrChunk{chunk(0)}},
+		{Labels: []labelpb.ZLabel{{Name: "series", Value: "b"}}, Chunks: []storepb.AggrChunk{chunk(0), chunk(20)}},
+		{Labels: []labelpb.ZLabel{{Name: "series", Value: "c"}}, Chunks: []storepb.AggrChunk{chunk(20)}},
+		{Labels: []labelpb.ZLabel{{Name: "series", Value: "d"}}, Chunks: []storepb.AggrChunk{chunk(0)}},
+	}, srv.SeriesSet)
+	assert.True(t, srv.IsEndOfSeriesStream)
+	assert.Equal(t, []int{2, 2}, srv.chunksBatchSizes)
+	assert.Equal(t, 4, stats.mergedSeriesCount)
+	assert.Equal(t, 5, stats.mergedChunksCount)
+}
+
+// batchSizeRecordingSeriesServer records the number of series of each streaming chunks batch.
+type batchSizeRecordingSeriesServer struct {
+	*bucketStoreSeriesServer
+
+	chunksBatchSizes []int
+}
+
+func (s *batchSizeRecordingSeriesServer) Send(r *storepb.SeriesResponse) error {
+	if chunks := r.GetStreamingChunks(); chunks != nil {
+		s.chunksBatchSizes = append(s.chunksBatchSizes, len(chunks.Series))
+	}
+	return s.bucketStoreSeriesServer.Send(r)
+}
+
 func mustMarshalAny(pb proto.Message) *types.Any {
 	out, err := types.MarshalAny(pb)
 	if err != nil {
@@ -2043,7 +2306,7 @@ func benchmarkBlockSeriesWithConcurrency(b *testing.B, concurrency int, blockMet
 				indexReader := blk.indexReader()
 				chunkReader := blk.chunkReader(ctx)
 
-				seriesSet, _, err := blockSeries(context.Background(), indexReader, chunkReader, matchers, shardSelector, seriesHashCache, chunksLimiter, seriesLimiter, req.SkipChunks, req.MinTime, req.MaxTime, req.Aggregates, log.NewNopLogger())
+				seriesSet, _, err := blockSeries(context.Background(), indexReader, chunkReader, matchers, shardSelector, seriesHashCache, chunksLimiter, seriesLimiter, req.SkipChunks, req.MinTime, req.MaxTime, req.Aggregates, 0, log.NewNopLogger())
 				require.NoError(b, err)
 
 				// Ensure at least 1 series has been returned (as expected).
@@ -2068,7 +2331,7 @@ func TestBlockSeries_skipChunks_ignoresMintMaxt(t *testing.T) {
 
 	sl := NewLimiter(math.MaxUint64, promauto.With(nil).NewCounter(prometheus.CounterOpts{Name: "test"}))
 	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchNotEqual, "i", "")}
-	ss, _, err := blockSeries(context.Background(), b.indexReader(), nil, matchers, nil, nil, nil, sl, skipChunks, mint, maxt, nil, log.NewNopLogger())
+	ss, _, err := blockSeries(context.Background(), b.indexReader(), nil, matchers, nil, nil, nil, sl, skipChunks, mint, maxt, nil, 0, log.NewNopLogger())
 	require.NoError(t, err)
 	require.True(t, ss.Next(), "Result set should have series because when skipChunks=true, mint/maxt should be ignored")
 }
@@ -2089,7 +2352,7 @@ func TestBlockSeries_Cache(t *testing.T) {
 		// This test relies on the fact that p~=foo.* has to call LabelValues(p) when doing ExpandedPostings().
 		// We make that call fail in order to make the entire LabelValues(p~=foo.*) call fail.
 		matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, "p", "foo.*")}
-		_, _, err := blockSeries(context.Background(), b.indexReader(), nil, matchers, nil, nil, nil, sl, true, b.meta.MinTime, b.meta.MaxTime, nil, log.NewNopLogger())
+		_, _, err := blockSeries(context.Background(), b.indexReader(), nil, matchers, nil, nil, nil, sl, true, b.meta.MinTime, b.meta.MaxTime, nil, 0, log.NewNopLogger())
 		require.Error(t, err)
 	})
 
@@ -2143,7 +2406,7 @@ func TestBlockSeries_Cache(t *testing.T) {
 
 		indexr := b.indexReader()
 		for i, tc := range testCases {
-			ss, _, err := blockSeries(context.Background(), indexr, nil, tc.matchers, tc.shard, shc, nil, sl, true, b.meta.MinTime, b.meta.MaxTime, nil, log.NewNopLogger())
+			ss, _, err := blockSeries(context.Background(), indexr, nil, tc.matchers, tc.shard, shc, nil, sl, true, b.meta.MinTime, b.meta.MaxTime, nil, 0, log.NewNopLogger())
 			require.NoError(t, err, "Unexpected error for test case %d", i)
 			lset := lsetFromSeriesSet(t, ss)
 			require.Equalf(t, tc.expectedLabelSet, lset, "Wrong label set for test case %d", i)
@@ -2153,7 +2416,7 @@ func TestBlockSeries_Cache(t *testing.T) {
 		// We break the LookupSymbol so we know for sure we'll be using the cache in the next calls.
 		indexr.dec.LookupSymbol = nil
 		for i, tc := range testCases {
-			ss, _, err := blockSeries(context.Background(), indexr, nil, tc.matchers, tc.shard, shc, nil, sl, true, b.meta.MinTime, b.meta.MaxTime, nil, log.NewNopLogger())
+			ss, _, err := blockSeries(context.Background(), indexr, nil, tc.matchers, tc.shard, shc, nil, sl, true, b.meta.MinTime, b.meta.MaxTime, nil, 0, log.NewNopLogger())
 			require.NoError(t, err, "Unexpected error for test case %d", i)
 			lset := lsetFromSeriesSet(t, ss)
 			require.Equalf(t, tc.expectedLabelSet, lset, "Wrong label set for test case %d", i)
@@ -51,11 +51,14 @@ var (
 // Config holds the store gateway config.
 type Config struct {
 	ShardingRing RingConfig `yaml:"sharding_ring" doc:"description=The hash ring configuration."`
+
+	TimeBasedReplication TimeBasedReplicationConfig `yaml:"time_based_replication" doc:"description=Replicate recent blocks to more store-gateways than older blocks."`
 }
 
 // RegisterFlags registers the Config flags.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	cfg.ShardingRing.RegisterFlags(f, logger)
+	cfg.TimeBasedReplication.RegisterFlagsWithPrefix(f, "store-gateway.time-based-replication.")
 }
 
 // Validate the Config.
@@ -64,6 +67,10 @@ func (cfg *Config) Validate(limits validation.Limits) error {
 		return errInvalidTenantShardSize
 	}
 
+	if err := cfg.TimeBasedReplication.Validate(cfg.ShardingRing.ReplicationFactor); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -156,7 +163,11 @@ func newStoreGateway(gatewayCfg Config, storageCfg mimir_tsdb.BlocksStorageConfi
 		return nil, errors.Wrap(err, "create ring client")
 	}
 
-	shardingStrategy = NewShuffleShardingStrategy(g.ring, lifecyclerCfg.ID, lifecyclerCfg.Addr, limits, logger)
+	if gatewayCfg.TimeBasedReplication.Enabled {
+		shardingStrategy = NewTimeBasedReplicationShardingStrategy(g.ring, lifecyclerCfg.ID, lifecyclerCfg.Addr, limits, gatewayCfg.TimeBasedReplication, logger)
+	} else {
+		shardingStrategy = NewShuffleShardingStrategy(g.ring, lifecyclerCfg.ID, lifecyclerCfg.Addr, limits, logger)
+	}
 
 	g.stores, err = NewBucketStores(storageCfg, shardingStrategy, bucketClient, limits, logLevel, logger, extprom.WrapRegistererWith(prometheus.Labels{"component": "store-gateway"}, reg))
 	if err != nil {
@@ -8,14 +8,19 @@ import (
 	"html/template"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-kit/log/level"
 	"github.com/gorilla/mux"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
 	"github.com/prometheus/prometheus/model/labels"
 	prom_tsdb "github.com/prometheus/prometheus/tsdb"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
 
 	"github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/listblocks"
 )
@@ -49,12 +54,14 @@ type formattedBlockData struct {
 	Sources         []string
 	Parents         []string
 	Stats           prom_tsdb.BlockStats
+	TopLabelNames   string
 }
 
 type richMeta struct {
 	*metadata.Meta
-	DeletedTime *int64  `json:"deletedTime,omitempty"`
-	SplitID     *uint32 `json:"splitId,omitempty"`
+	DeletedTime   *int64                       `json:"deletedTime,omitempty"`
+	SplitID       *uint32                      `json:"splitId,omitempty"`
+	TopLabelNames []bucketindex.LabelNameStats `json:"topLabelNames,omitempty"`
 }
 
 func (s *StoreGateway) BlocksHandler(w http.ResponseWriter, req *http.Request) {
@@ -88,6 +95,20 @@ func (s *StoreGateway) BlocksHandler(w http.ResponseWriter, req *http.Request) {
 	}
 	metas := listblocks.SortBlocks(metasMap)
 
+	// The top label names are only stored in the bucket index.
+	topLabelNames := map[ulid.ULID][]bucketindex.LabelNameStats{}
+	idx, err := bucketindex.ReadIndex(req.Context(), s.stores.bucket, tenantID, s.stores.limits, s.logger)
+	if err != nil && !errors.Is(err, bucketindex.ErrIndexNotFound) {
+		level.Warn(s.logger).Log("msg", "failed to read bucket index", "user", tenantID, "err", err)
+	}
+	if idx != nil {
+		for _, b := range idx.Blocks {
+			if b.Stats != nil {
+				topLabelNames[b.ID] = b.Stats.TopLabelNames
+			}
+		}
+	}
+
 	formattedBlocks := make([]formattedBlockData, 0, len(metas))
 	richMetas := make([]richMeta, 0, len(metas))
 
@@ -123,6 +144,7 @@ func (s *StoreGateway) BlocksHandler(w http.ResponseWriter, req *http.Request) {
 			Sources:         sources,
 			Parents:         parents,
 			Stats:           m.Stats,
+			TopLabelNames:   formatTopLabelNames(topLabelNames[m.ULID]),
 		})
 		var deletedAt *int64
 		if dt, ok := deletedTimes[m.ULID]; ok {
@@ -130,9 +152,10 @@ func (s *StoreGateway) BlocksHandler(w http.ResponseWriter, req *http.Request) {
 			deletedAt = &deletedAtTime
 		}
 		richMetas = append(richMetas, richMeta{
-			Meta:        m,
-			DeletedTime: deletedAt,
-			SplitID:     blockSplitID,
+			Meta:          m,
+			DeletedTime:   deletedAt,
+			SplitID:       blockSplitID,
+			TopLabelNames: topLabelNames[m.ULID],
 		})
 	}
 
@@ -156,3 +179,11 @@ func formatTimeIfNotZero(t time.Time, format string) string {
 
 	return t.Format(format)
 }
+
+func formatTopLabelNames(names []bucketindex.LabelNameStats) string {
+	formatted := make([]string, 0, len(names))
+	for _, n := range names {
+		formatted = append(formatted, fmt.Sprintf("%s (%d values, %d series)", n.Name, n.Values, n.Series))
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -629,6 +629,14 @@ func TestStringCacheKeys_Values(t *testing.T) {
 			key:      seriesForRefCacheKey(user, uid, 12345),
 			expected: fmt.Sprintf("S:%s:%s:12345", user, uid.String()),
 		},
+		"should stringify expanded postings cache key regardless of the matchers order": {
+			key: expandedPostingsCacheKey(user, uid, CanonicalLabelMatchersKey([]*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchRegexp, "pod", "api-.*"),
+				labels.MustNewMatcher(labels.MatchEqual, "namespace", "prod"),
+			})),
+			// The key is persisted in the cache, so it must not change across restarts and releases.
+			expected: fmt.Sprintf("E:%s:%s:%s", user, uid.String(), "UJQfpidd6iC4L5hQnhHSm_eKY27LjZNvyXdcIUj7YLU"),
+		},
 	}
 
 	for testName, testData := range tests {
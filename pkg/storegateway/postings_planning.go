@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/mimir/pkg/storegateway/indexheader"
+)
+
+const (
+	postingsStrategyAllMatchers  = "all_matchers"
+	postingsStrategyLazyMatchers = "lazy_matchers"
+)
+
+// postingsPlan splits the matchers of a request between the ones whose postings are fetched and intersected
+// to find the candidate series, and the lazy ones which are applied by filtering the labels of the candidate series.
+type postingsPlan struct {
+	postingsMatchers []*labels.Matcher
+	lazyMatchers     []*labels.Matcher
+}
+
+// matches returns true if the series labels match all the lazy matchers.
+func (p postingsPlan) matches(lset labels.Labels) bool {
+	for _, m := range p.lazyMatchers {
+		if !m.Matches(lset.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// planPostings estimates the size of the postings of each matcher from the index-header, and plans to apply lazily
+// the matchers whose postings are larger than ratio times the postings of the most selective matcher.
+// Fetching the postings of a broad matcher, like {__name__=~".+"}, may require to fetch most of the postings of a
+// block, while filtering the series selected by the other matchers only requires to load their labels.
+// The most selective matcher is chosen among the ones not selecting all series by default (unlike !=), and it's
+// never applied lazily. A ratio <= 0 disables the planning.
+func planPostings(r indexheader.Reader, ms []*labels.Matcher, ratio float64) (postingsPlan, error) {
+	plan := postingsPlan{postingsMatchers: ms}
+	if ratio <= 0 || len(ms) < 2 {
+		return plan, nil
+	}
+
+	var (
+		sizes       = make([]int64, len(ms))
+		selectedIdx = -1
+	)
+	for i, m := range ms {
+		pg, err := toPostingGroup(r.LabelValues, m)
+		if err != nil {
+			return plan, errors.Wrap(err, "toPostingGroup")
+		}
+
+		// The postings of a matcher matching no series are empty: there's nothing to save.
+		if !pg.addAll && len(pg.addKeys) == 0 {
+			return plan, nil
+		}
+
+		for _, keys := range [][]labels.Label{pg.addKeys, pg.removeKeys} {
+			for _, key := range keys {
+				rng, err := r.PostingsOffset(key.Name, key.Value)
+				if errors.Is(err, indexheader.NotFoundRangeErr) {
+					continue
+				}
+				if err != nil {
+					return plan, errors.Wrap(err, "index header PostingsOffset")
+				}
+				sizes[i] += rng.End - rng.Start
+			}
+		}
+
+		// Only the matchers adding postings can select the candidate series without fetching all postings.
+		if !pg.addAll && (selectedIdx < 0 || sizes[i] < sizes[selectedIdx]) {
+			selectedIdx = i
+		}
+	}
+
+	if selectedIdx < 0 {
+		return plan, nil
+	}
+
+	maxSize := ratio * float64(sizes[selectedIdx])
+	plan.postingsMatchers = make([]*labels.Matcher, 0, len(ms))
+	for i, m := range ms {
+		if i != selectedIdx && float64(sizes[i]) > maxSize {
+			plan.lazyMatchers = append(plan.lazyMatchers, m)
+			continue
+		}
+		plan.postingsMatchers = append(plan.postingsMatchers, m)
+	}
+
+	return plan, nil
+}
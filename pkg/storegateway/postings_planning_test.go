@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/util/test"
+)
+
+func TestPlanPostings(t *testing.T) {
+	newTestBucketBlock := prepareTestBlock(test.NewTB(t), 1000)
+	b := newTestBucketBlock()
+
+	// The "n" label value is set on 40 series, while "j" is set on all series.
+	selective := labels.MustNewMatcher(labels.MatchEqual, "n", "1"+labelLongSuffix)
+	broad := labels.MustNewMatcher(labels.MatchRegexp, "j", "foo|bar")
+	notEqual := labels.MustNewMatcher(labels.MatchNotEqual, "j", "foo")
+	empty := labels.MustNewMatcher(labels.MatchEqual, "n", "missing")
+
+	tests := map[string]struct {
+		matchers                 []*labels.Matcher
+		ratio                    float64
+		expectedPostingsMatchers []*labels.Matcher
+		expectedLazyMatchers     []*labels.Matcher
+	}{
+		"planning disabled": {
+			matchers:                 []*labels.Matcher{selective, broad},
+			ratio:                    0,
+			expectedPostingsMatchers: []*labels.Matcher{selective, broad},
+		},
+		"single matcher": {
+			matchers:                 []*labels.Matcher{broad},
+			ratio:                    2,
+			expectedPostingsMatchers: []*labels.Matcher{broad},
+		},
+		"broad matcher applied lazily": {
+			matchers:                 []*labels.Matcher{broad, selective},
+			ratio:                    2,
+			expectedPostingsMatchers: []*labels.Matcher{selective},
+			expectedLazyMatchers:     []*labels.Matcher{broad},
+		},
+		"broad matcher postings smaller than the ratio": {
+			matchers:                 []*labels.Matcher{broad, selective},
+			ratio:                    1000,
+			expectedPostingsMatchers: []*labels.Matcher{broad, selective},
+		},
+		"not equal matcher applied lazily": {
+			matchers:                 []*labels.Matcher{notEqual, selective},
+			ratio:                    2,
+			expectedPostingsMatchers: []*labels.Matcher{selective},
+			expectedLazyMatchers:     []*labels.Matcher{notEqual},
+		},
+		"no matcher selecting a subset of the series": {
+			matchers:                 []*labels.Matcher{notEqual, labels.MustNewMatcher(labels.MatchNotEqual, "p", "foo")},
+			ratio:                    2,
+			expectedPostingsMatchers: []*labels.Matcher{notEqual, labels.MustNewMatcher(labels.MatchNotEqual, "p", "foo")},
+		},
+		"matcher selecting no series": {
+			matchers:                 []*labels.Matcher{broad, empty},
+			ratio:                    2,
+			expectedPostingsMatchers: []*labels.Matcher{empty},
+			expectedLazyMatchers:     []*labels.Matcher{broad},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			plan, err := planPostings(b.indexHeaderReader, testData.matchers, testData.ratio)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedPostingsMatchers, plan.postingsMatchers)
+			assert.Equal(t, testData.expectedLazyMatchers, plan.lazyMatchers)
+		})
+	}
+}
+
+func TestBlockSeries_PostingsPlanning(t *testing.T) {
+	newTestBucketBlock := prepareTestBlock(test.NewTB(t), 1000)
+
+	tests := map[string]struct {
+		matchers                     []*labels.Matcher
+		expectedLazyMatchersStrategy bool
+		expectedFilteredSeries       bool
+	}{
+		"regexp matcher on all series": {
+			matchers: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchRegexp, "j", ".+"),
+				labels.MustNewMatcher(labels.MatchEqual, "i", "3"+labelLongSuffix),
+			},
+			expectedLazyMatchersStrategy: true,
+		},
+		"regexp matcher filtering out series": {
+			matchers: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchEqual, "n", "1"+labelLongSuffix),
+				labels.MustNewMatcher(labels.MatchRegexp, "i", "[1-3].+"),
+			},
+			expectedLazyMatchersStrategy: true,
+			expectedFilteredSeries:       true,
+		},
+		"not equal matcher": {
+			matchers: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchNotEqual, "j", "bar"),
+				labels.MustNewMatcher(labels.MatchEqual, "n", "1"+labelLongSuffix),
+			},
+			expectedLazyMatchersStrategy: true,
+			expectedFilteredSeries:       true,
+		},
+		"selective matchers": {
+			matchers: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchEqual, "n", "1"+labelLongSuffix),
+				labels.MustNewMatcher(labels.MatchEqual, "i", "1"+labelLongSuffix),
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			b := newTestBucketBlock()
+			sl := NewLimiter(math.MaxUint64, promauto.With(nil).NewCounter(prometheus.CounterOpts{Name: "test"}))
+
+			// Query the block with and without postings planning, and compare the results.
+			expectedSet, expectedStats, err := blockSeries(context.Background(), b.indexReader(), nil, testData.matchers, nil, nil, nil, sl, true, b.meta.MinTime, b.meta.MaxTime, nil, 0, log.NewNopLogger())
+			require.NoError(t, err)
+			expected := lsetFromSeriesSet(t, expectedSet)
+			require.NotEmpty(t, expected)
+			assert.Zero(t, expectedStats.blocksQueriedWithAllMatchers)
+			assert.Zero(t, expectedStats.blocksQueriedWithLazyMatchers)
+
+			actualSet, actualStats, err := blockSeries(context.Background(), b.indexReader(), nil, testData.matchers, nil, nil, nil, sl, true, b.meta.MinTime, b.meta.MaxTime, nil, 2, log.NewNopLogger())
+			require.NoError(t, err)
+			assert.Equal(t, expected, lsetFromSeriesSet(t, actualSet))
+
+			if testData.expectedLazyMatchersStrategy {
+				assert.Equal(t, 1, actualStats.blocksQueriedWithLazyMatchers)
+				assert.Zero(t, actualStats.blocksQueriedWithAllMatchers)
+			} else {
+				assert.Equal(t, 1, actualStats.blocksQueriedWithAllMatchers)
+				assert.Zero(t, actualStats.blocksQueriedWithLazyMatchers)
+			}
+			assert.Equal(t, testData.expectedFilteredSeries, actualStats.seriesFilteredByLazyMatchers > 0)
+		})
+	}
+}
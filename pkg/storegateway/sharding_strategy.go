@@ -7,6 +7,7 @@ package storegateway
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -15,8 +16,6 @@ import (
 	"github.com/pkg/errors"
 	"github.com/thanos-io/thanos/pkg/block"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
-
-	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 )
 
 const (
@@ -92,6 +91,12 @@ func (s *ShuffleShardingStrategy) FilterUsers(_ context.Context, userIDs []strin
 
 // FilterBlocks implements ShardingStrategy.
 func (s *ShuffleShardingStrategy) FilterBlocks(_ context.Context, userID string, metas map[ulid.ULID]*metadata.Meta, loaded map[ulid.ULID]struct{}, synced block.GaugeVec) error {
+	return s.filterBlocks(userID, metas, loaded, synced, func(*metadata.Meta, int) int { return 1 })
+}
+
+// filterBlocks filters metas in-place keeping only blocks owned by the store-gateway. The replicaSets
+// function returns the number of replica sets of a block, given the ring replication factor.
+func (s *ShuffleShardingStrategy) filterBlocks(userID string, metas map[ulid.ULID]*metadata.Meta, loaded map[ulid.ULID]struct{}, synced block.GaugeVec, replicaSets func(meta *metadata.Meta, replicationFactor int) int) error {
 	// As a protection, ensure the store-gateway instance is healthy in the ring. If it's unhealthy because it's failing
 	// to heartbeat or get updates from the ring, or even removed from the ring because of the auto-forget feature, then
 	// keep the previously loaded blocks.
@@ -114,11 +119,11 @@ func (s *ShuffleShardingStrategy) FilterBlocks(_ context.Context, userID string,
 	r := GetShuffleShardingSubring(s.r, userID, s.limits)
 	bufDescs, bufHosts, bufZones := ring.MakeBuffersForGet()
 
-	for blockID := range metas {
-		key := mimir_tsdb.HashBlockID(blockID)
+	for blockID, meta := range metas {
+		blockReplicaSets := replicaSets(meta, r.ReplicationFactor())
 
 		// Check if the block is owned by the store-gateway
-		set, err := r.Get(key, BlocksOwnerSync, bufDescs, bufHosts, bufZones)
+		set, err := GetBlockReplicationSet(r, blockID, blockReplicaSets, BlocksOwnerSync, bufDescs, bufHosts, bufZones)
 
 		// If an error occurs while checking the ring, we keep the previously loaded blocks.
 		if err != nil {
@@ -145,7 +150,7 @@ func (s *ShuffleShardingStrategy) FilterBlocks(_ context.Context, userID string,
 		// for queries.
 		if _, ok := loaded[blockID]; ok {
 			// The ring Get() returns an error if there's no available instance.
-			if _, err := r.Get(key, BlocksOwnerRead, bufDescs, bufHosts, bufZones); err != nil {
+			if _, err := GetBlockReplicationSet(r, blockID, blockReplicaSets, BlocksOwnerRead, bufDescs, bufHosts, bufZones); err != nil {
 				// Keep the block.
 				continue
 			}
@@ -161,6 +166,31 @@ func (s *ShuffleShardingStrategy) FilterBlocks(_ context.Context, userID string,
 	return nil
 }
 
+// TimeBasedReplicationShardingStrategy is a shuffle sharding strategy which replicates recent blocks to more
+// store-gateways than older blocks, according to the configured age brackets.
+type TimeBasedReplicationShardingStrategy struct {
+	*ShuffleShardingStrategy
+
+	replication TimeBasedReplicationConfig
+}
+
+// NewTimeBasedReplicationShardingStrategy makes a new TimeBasedReplicationShardingStrategy.
+func NewTimeBasedReplicationShardingStrategy(r *ring.Ring, instanceID, instanceAddr string, limits ShardingLimits, replication TimeBasedReplicationConfig, logger log.Logger) *TimeBasedReplicationShardingStrategy {
+	return &TimeBasedReplicationShardingStrategy{
+		ShuffleShardingStrategy: NewShuffleShardingStrategy(r, instanceID, instanceAddr, limits, logger),
+		replication:             replication,
+	}
+}
+
+// FilterBlocks implements ShardingStrategy.
+func (s *TimeBasedReplicationShardingStrategy) FilterBlocks(_ context.Context, userID string, metas map[ulid.ULID]*metadata.Meta, loaded map[ulid.ULID]struct{}, synced block.GaugeVec) error {
+	now := time.Now()
+
+	return s.filterBlocks(userID, metas, loaded, synced, func(meta *metadata.Meta, replicationFactor int) int {
+		return s.replication.ReplicaSets(meta.MaxTime, replicationFactor, now)
+	})
+}
+
 // GetShuffleShardingSubring returns the subring to be used for a given user. This function
 // should be used both by store-gateway and querier in order to guarantee the same logic is used.
 func GetShuffleShardingSubring(ring *ring.Ring, userID string, limits ShardingLimits) ring.ReadRing {
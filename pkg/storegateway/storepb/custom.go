@@ -32,6 +32,22 @@ func NewHintsSeriesResponse(hints *types.Any) *SeriesResponse {
 	}
 }
 
+func NewStreamingSeriesResponse(series *StreamingSeriesBatch) *SeriesResponse {
+	return &SeriesResponse{
+		Result: &SeriesResponse_StreamingSeries{
+			StreamingSeries: series,
+		},
+	}
+}
+
+func NewStreamingChunksResponse(chunks *StreamingChunksBatch) *SeriesResponse {
+	return &SeriesResponse{
+		Result: &SeriesResponse_StreamingChunks{
+			StreamingChunks: chunks,
+		},
+	}
+}
+
 type emptySeriesSet struct{}
 
 func (emptySeriesSet) Next() bool                       { return false }
@@ -9,6 +9,7 @@ import (
 	_ "github.com/gogo/protobuf/gogoproto"
 	proto "github.com/gogo/protobuf/proto"
 	types "github.com/gogo/protobuf/types"
+	github_com_grafana_mimir_pkg_storegateway_labelpb "github.com/grafana/mimir/pkg/storegateway/labelpb"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
@@ -82,6 +83,10 @@ type SeriesRequest struct {
 	// Range vector selector range in milliseconds.
 	// Deprecated: Use query_hints instead.
 	Range int64 `protobuf:"varint,11,opt,name=range,proto3" json:"range,omitempty"`
+	// If greater than 0, the store-gateway streams the series labels first, then the chunks
+	// of the series in batches of this size, instead of sending full series. Store-gateways
+	// not supporting streaming ignore this field and send full series.
+	StreamingChunksBatchSize uint64 `protobuf:"varint,100,opt,name=streaming_chunks_batch_size,json=streamingChunksBatchSize,proto3" json:"streaming_chunks_batch_size,omitempty"`
 }
 
 func (m *SeriesRequest) Reset()      { *m = SeriesRequest{} }
@@ -121,6 +126,8 @@ type SeriesResponse struct {
 	//	*SeriesResponse_Series
 	//	*SeriesResponse_Warning
 	//	*SeriesResponse_Hints
+	//	*SeriesResponse_StreamingSeries
+	//	*SeriesResponse_StreamingChunks
 	Result isSeriesResponse_Result `protobuf_oneof:"result"`
 }
 
@@ -172,10 +179,18 @@ type SeriesResponse_Warning struct {
 type SeriesResponse_Hints struct {
 	Hints *types.Any `protobuf:"bytes,3,opt,name=hints,proto3,oneof"`
 }
+type SeriesResponse_StreamingSeries struct {
+	StreamingSeries *StreamingSeriesBatch `protobuf:"bytes,4,opt,name=streaming_series,json=streamingSeries,proto3,oneof"`
+}
+type SeriesResponse_StreamingChunks struct {
+	StreamingChunks *StreamingChunksBatch `protobuf:"bytes,5,opt,name=streaming_chunks,json=streamingChunks,proto3,oneof"`
+}
 
-func (*SeriesResponse_Series) isSeriesResponse_Result()  {}
-func (*SeriesResponse_Warning) isSeriesResponse_Result() {}
-func (*SeriesResponse_Hints) isSeriesResponse_Result()   {}
+func (*SeriesResponse_Series) isSeriesResponse_Result()          {}
+func (*SeriesResponse_Warning) isSeriesResponse_Result()         {}
+func (*SeriesResponse_Hints) isSeriesResponse_Result()           {}
+func (*SeriesResponse_StreamingSeries) isSeriesResponse_Result() {}
+func (*SeriesResponse_StreamingChunks) isSeriesResponse_Result() {}
 
 func (m *SeriesResponse) GetResult() isSeriesResponse_Result {
 	if m != nil {
@@ -205,14 +220,179 @@ func (m *SeriesResponse) GetHints() *types.Any {
 	return nil
 }
 
+func (m *SeriesResponse) GetStreamingSeries() *StreamingSeriesBatch {
+	if x, ok := m.GetResult().(*SeriesResponse_StreamingSeries); ok {
+		return x.StreamingSeries
+	}
+	return nil
+}
+
+func (m *SeriesResponse) GetStreamingChunks() *StreamingChunksBatch {
+	if x, ok := m.GetResult().(*SeriesResponse_StreamingChunks); ok {
+		return x.StreamingChunks
+	}
+	return nil
+}
+
 // XXX_OneofWrappers is for the internal use of the proto package.
 func (*SeriesResponse) XXX_OneofWrappers() []interface{} {
 	return []interface{}{
 		(*SeriesResponse_Series)(nil),
 		(*SeriesResponse_Warning)(nil),
 		(*SeriesResponse_Hints)(nil),
+		(*SeriesResponse_StreamingSeries)(nil),
+		(*SeriesResponse_StreamingChunks)(nil),
+	}
+}
+
+type StreamingSeries struct {
+	Labels []github_com_grafana_mimir_pkg_storegateway_labelpb.ZLabel `protobuf:"bytes,1,rep,name=labels,proto3,customtype=github.com/grafana/mimir/pkg/storegateway/labelpb.ZLabel" json:"labels"`
+}
+
+func (m *StreamingSeries) Reset()      { *m = StreamingSeries{} }
+func (*StreamingSeries) ProtoMessage() {}
+func (*StreamingSeries) Descriptor() ([]byte, []int) {
+	return fileDescriptor_77a6da22d6a3feb1, []int{2}
+}
+func (m *StreamingSeries) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StreamingSeries) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StreamingSeries.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *StreamingSeries) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamingSeries.Merge(m, src)
+}
+func (m *StreamingSeries) XXX_Size() int {
+	return m.Size()
+}
+func (m *StreamingSeries) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamingSeries.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamingSeries proto.InternalMessageInfo
+
+type StreamingSeriesBatch struct {
+	Series []*StreamingSeries `protobuf:"bytes,1,rep,name=series,proto3" json:"series,omitempty"`
+	// Set in the last batch of series labels. The chunks of the series are sent afterwards,
+	// in the same order of the series labels.
+	IsEndOfSeriesStream bool `protobuf:"varint,2,opt,name=is_end_of_series_stream,json=isEndOfSeriesStream,proto3" json:"is_end_of_series_stream,omitempty"`
+}
+
+func (m *StreamingSeriesBatch) Reset()      { *m = StreamingSeriesBatch{} }
+func (*StreamingSeriesBatch) ProtoMessage() {}
+func (*StreamingSeriesBatch) Descriptor() ([]byte, []int) {
+	return fileDescriptor_77a6da22d6a3feb1, []int{3}
+}
+func (m *StreamingSeriesBatch) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StreamingSeriesBatch) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StreamingSeriesBatch.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *StreamingSeriesBatch) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamingSeriesBatch.Merge(m, src)
+}
+func (m *StreamingSeriesBatch) XXX_Size() int {
+	return m.Size()
+}
+func (m *StreamingSeriesBatch) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamingSeriesBatch.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamingSeriesBatch proto.InternalMessageInfo
+
+type StreamingChunks struct {
+	// Index of the series in the order the series labels have been sent.
+	SeriesIndex uint64      `protobuf:"varint,1,opt,name=series_index,json=seriesIndex,proto3" json:"series_index,omitempty"`
+	Chunks      []AggrChunk `protobuf:"bytes,2,rep,name=chunks,proto3" json:"chunks"`
+}
+
+func (m *StreamingChunks) Reset()      { *m = StreamingChunks{} }
+func (*StreamingChunks) ProtoMessage() {}
+func (*StreamingChunks) Descriptor() ([]byte, []int) {
+	return fileDescriptor_77a6da22d6a3feb1, []int{4}
+}
+func (m *StreamingChunks) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StreamingChunks) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StreamingChunks.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *StreamingChunks) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamingChunks.Merge(m, src)
+}
+func (m *StreamingChunks) XXX_Size() int {
+	return m.Size()
+}
+func (m *StreamingChunks) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamingChunks.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamingChunks proto.InternalMessageInfo
+
+type StreamingChunksBatch struct {
+	Series []*StreamingChunks `protobuf:"bytes,1,rep,name=series,proto3" json:"series,omitempty"`
+}
+
+func (m *StreamingChunksBatch) Reset()      { *m = StreamingChunksBatch{} }
+func (*StreamingChunksBatch) ProtoMessage() {}
+func (*StreamingChunksBatch) Descriptor() ([]byte, []int) {
+	return fileDescriptor_77a6da22d6a3feb1, []int{5}
+}
+func (m *StreamingChunksBatch) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *StreamingChunksBatch) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_StreamingChunksBatch.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
 }
+func (m *StreamingChunksBatch) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamingChunksBatch.Merge(m, src)
+}
+func (m *StreamingChunksBatch) XXX_Size() int {
+	return m.Size()
+}
+func (m *StreamingChunksBatch) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamingChunksBatch.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamingChunksBatch proto.InternalMessageInfo
 
 type LabelNamesRequest struct {
 	Start int64 `protobuf:"varint,3,opt,name=start,proto3" json:"start,omitempty"`
@@ -227,7 +407,7 @@ type LabelNamesRequest struct {
 func (m *LabelNamesRequest) Reset()      { *m = LabelNamesRequest{} }
 func (*LabelNamesRequest) ProtoMessage() {}
 func (*LabelNamesRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{2}
+	return fileDescriptor_77a6da22d6a3feb1, []int{6}
 }
 func (m *LabelNamesRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -268,7 +448,7 @@ type LabelNamesResponse struct {
 func (m *LabelNamesResponse) Reset()      { *m = LabelNamesResponse{} }
 func (*LabelNamesResponse) ProtoMessage() {}
 func (*LabelNamesResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{3}
+	return fileDescriptor_77a6da22d6a3feb1, []int{7}
 }
 func (m *LabelNamesResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -311,7 +491,7 @@ type LabelValuesRequest struct {
 func (m *LabelValuesRequest) Reset()      { *m = LabelValuesRequest{} }
 func (*LabelValuesRequest) ProtoMessage() {}
 func (*LabelValuesRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{4}
+	return fileDescriptor_77a6da22d6a3feb1, []int{8}
 }
 func (m *LabelValuesRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -352,7 +532,7 @@ type LabelValuesResponse struct {
 func (m *LabelValuesResponse) Reset()      { *m = LabelValuesResponse{} }
 func (*LabelValuesResponse) ProtoMessage() {}
 func (*LabelValuesResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{5}
+	return fileDescriptor_77a6da22d6a3feb1, []int{9}
 }
 func (m *LabelValuesResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -385,6 +565,10 @@ func init() {
 	proto.RegisterEnum("thanos.Aggr", Aggr_name, Aggr_value)
 	proto.RegisterType((*SeriesRequest)(nil), "thanos.SeriesRequest")
 	proto.RegisterType((*SeriesResponse)(nil), "thanos.SeriesResponse")
+	proto.RegisterType((*StreamingSeries)(nil), "thanos.StreamingSeries")
+	proto.RegisterType((*StreamingSeriesBatch)(nil), "thanos.StreamingSeriesBatch")
+	proto.RegisterType((*StreamingChunks)(nil), "thanos.StreamingChunks")
+	proto.RegisterType((*StreamingChunksBatch)(nil), "thanos.StreamingChunksBatch")
 	proto.RegisterType((*LabelNamesRequest)(nil), "thanos.LabelNamesRequest")
 	proto.RegisterType((*LabelNamesResponse)(nil), "thanos.LabelNamesResponse")
 	proto.RegisterType((*LabelValuesRequest)(nil), "thanos.LabelValuesRequest")
@@ -394,54 +578,70 @@ func init() {
 func init() { proto.RegisterFile("rpc.proto", fileDescriptor_77a6da22d6a3feb1) }
 
 var fileDescriptor_77a6da22d6a3feb1 = []byte{
-	// 746 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x93, 0x4f, 0x4f, 0xdb, 0x48,
-	0x18, 0xc6, 0x3d, 0xf1, 0x9f, 0x38, 0x6f, 0x42, 0x34, 0x3b, 0x04, 0x64, 0xbc, 0x92, 0x89, 0x72,
-	0x8a, 0x10, 0x0a, 0xab, 0xac, 0xb4, 0xd2, 0x1e, 0x03, 0xda, 0x15, 0x8d, 0x0a, 0x95, 0x0c, 0x94,
-	0xaa, 0x97, 0xd4, 0x81, 0xa9, 0x63, 0x91, 0x8c, 0x53, 0x8f, 0x53, 0xe0, 0xd6, 0x4f, 0x50, 0xf5,
-	0x63, 0x54, 0xea, 0xb7, 0xe8, 0x89, 0x5b, 0x39, 0x72, 0xaa, 0x9a, 0x70, 0xe9, 0xa9, 0xe2, 0x23,
-	0x54, 0x9e, 0x71, 0xfe, 0x55, 0xa9, 0x28, 0x52, 0x4f, 0x99, 0xe7, 0x79, 0x26, 0xe3, 0x77, 0x7e,
-	0xef, 0x3b, 0x90, 0x8b, 0xfa, 0x27, 0xb5, 0x7e, 0x14, 0xc6, 0x21, 0x31, 0xe2, 0x8e, 0xc7, 0x42,
-	0x6e, 0xe7, 0xe3, 0xcb, 0x3e, 0xe5, 0xd2, 0xb4, 0x4b, 0x7e, 0xe8, 0x87, 0x62, 0xb9, 0x95, 0xac,
-	0x52, 0x77, 0xcd, 0x0f, 0x43, 0xbf, 0x4b, 0xb7, 0x84, 0x6a, 0x0f, 0x5e, 0x6e, 0x79, 0xec, 0x52,
-	0x46, 0x95, 0x6f, 0x19, 0x58, 0x3a, 0xa0, 0x51, 0x40, 0xb9, 0x4b, 0x5f, 0x0d, 0x28, 0x8f, 0xc9,
-	0x1a, 0x98, 0xbd, 0x80, 0xb5, 0xe2, 0xa0, 0x47, 0x2d, 0x54, 0x46, 0x55, 0xd5, 0xcd, 0xf6, 0x02,
-	0x76, 0x18, 0xf4, 0xa8, 0x88, 0xbc, 0x0b, 0x19, 0x65, 0xd2, 0xc8, 0xbb, 0x10, 0xd1, 0x3f, 0x49,
-	0x14, 0x9f, 0x74, 0x68, 0xc4, 0x2d, 0xb5, 0xac, 0x56, 0xf3, 0xf5, 0x52, 0x4d, 0x16, 0x58, 0x7b,
-	0xec, 0xb5, 0x69, 0x77, 0x4f, 0x86, 0xdb, 0xda, 0xd5, 0xe7, 0x75, 0xc5, 0x9d, 0xec, 0x25, 0x75,
-	0x58, 0x49, 0x8e, 0x8c, 0x28, 0x0f, 0xbb, 0x83, 0x38, 0x08, 0x59, 0xeb, 0x3c, 0x60, 0xa7, 0xe1,
-	0xb9, 0xa5, 0x89, 0xf3, 0x97, 0x7b, 0xde, 0x85, 0x3b, 0xc9, 0x8e, 0x45, 0x44, 0x36, 0x01, 0x3c,
-	0xdf, 0x8f, 0xa8, 0xef, 0xc5, 0x94, 0x5b, 0x7a, 0x59, 0xad, 0x16, 0xeb, 0x85, 0xf1, 0xd7, 0x1a,
-	0xbe, 0x1f, 0xb9, 0x33, 0x39, 0x59, 0x87, 0x3c, 0x3f, 0x0b, 0xfa, 0xad, 0x93, 0xce, 0x80, 0x9d,
-	0x71, 0xcb, 0x2c, 0xa3, 0xaa, 0xe9, 0x42, 0x62, 0xed, 0x08, 0x87, 0x6c, 0x80, 0xde, 0x09, 0x58,
-	0xcc, 0xad, 0x5c, 0x19, 0x89, 0xba, 0x25, 0xad, 0xda, 0x98, 0x56, 0xad, 0xc1, 0x2e, 0x5d, 0xb9,
-	0x85, 0x10, 0xd0, 0x78, 0x4c, 0xfb, 0x16, 0x88, 0xea, 0xc4, 0x9a, 0x94, 0x40, 0x8f, 0x3c, 0xe6,
-	0x53, 0x2b, 0x2f, 0x4c, 0x29, 0x9a, 0x9a, 0x69, 0xe0, 0x6c, 0x53, 0x33, 0xb3, 0xd8, 0x6c, 0x6a,
-	0x66, 0x01, 0x2f, 0x35, 0x35, 0x73, 0x09, 0x17, 0x2b, 0x6f, 0x11, 0x14, 0xc7, 0xc0, 0x79, 0x3f,
-	0x64, 0x9c, 0x92, 0x2a, 0x18, 0x5c, 0x38, 0x82, 0x77, 0xbe, 0x5e, 0x1c, 0xdf, 0x45, 0xee, 0xdb,
-	0x55, 0xdc, 0x34, 0x27, 0x36, 0x64, 0xcf, 0xbd, 0x88, 0x05, 0xcc, 0x17, 0xfc, 0x73, 0xbb, 0x8a,
-	0x3b, 0x36, 0xc8, 0xe6, 0xf8, 0x1a, 0xea, 0xcf, 0xaf, 0xb1, 0xab, 0xa4, 0x17, 0xd9, 0x36, 0xc1,
-	0x88, 0x28, 0x1f, 0x74, 0xe3, 0xca, 0x07, 0x04, 0x7f, 0x88, 0x16, 0xed, 0x7b, 0xbd, 0xe9, 0x14,
-	0x94, 0x40, 0xe7, 0xb1, 0x17, 0xc5, 0xe2, 0x34, 0xd5, 0x95, 0x82, 0x60, 0x50, 0x29, 0x3b, 0x4d,
-	0x7b, 0x93, 0x2c, 0xa7, 0xf0, 0xf4, 0xfb, 0xe1, 0xcd, 0xce, 0x88, 0xf1, 0xeb, 0x33, 0xd2, 0xd4,
-	0x4c, 0x84, 0x33, 0x4d, 0xcd, 0xcc, 0x60, 0xb5, 0x12, 0x01, 0x99, 0x2d, 0x36, 0x25, 0x58, 0x02,
-	0x9d, 0x25, 0x86, 0x85, 0xca, 0x6a, 0x35, 0xe7, 0x4a, 0x41, 0x6c, 0x30, 0x53, 0x38, 0xdc, 0xca,
-	0x88, 0x60, 0xa2, 0xa7, 0x75, 0xab, 0xf7, 0xd6, 0x5d, 0xf9, 0x88, 0xd2, 0x8f, 0x3e, 0xf5, 0xba,
-	0x83, 0x39, 0x44, 0xdd, 0xc4, 0x15, 0x5d, 0xcb, 0xb9, 0x52, 0x4c, 0xc1, 0x69, 0x0b, 0xc0, 0xe9,
-	0x0b, 0xc0, 0x19, 0x0f, 0x03, 0x97, 0x7d, 0x10, 0xb8, 0x0c, 0x56, 0x9b, 0x9a, 0xa9, 0x62, 0xad,
-	0x32, 0x80, 0xe5, 0xb9, 0x3b, 0xa4, 0xe4, 0x56, 0xc1, 0x78, 0x2d, 0x9c, 0x14, 0x5d, 0xaa, 0x7e,
-	0x17, 0xbb, 0x8d, 0x17, 0xa0, 0x25, 0x2f, 0x92, 0x14, 0xc0, 0x4c, 0x7e, 0x5b, 0x6e, 0xe3, 0x18,
-	0x2b, 0xa4, 0x08, 0x20, 0xd4, 0xce, 0x93, 0xa3, 0xfd, 0x43, 0x8c, 0x26, 0xe9, 0xc1, 0xd1, 0x1e,
-	0xce, 0x4c, 0xd4, 0xde, 0xa3, 0x7d, 0xac, 0x4e, 0x55, 0xe3, 0x19, 0xd6, 0x08, 0x86, 0xc2, 0xf4,
-	0x9f, 0xff, 0xb9, 0x58, 0xaf, 0x7f, 0x42, 0xa0, 0x1f, 0xc4, 0x61, 0x44, 0xc9, 0xbf, 0x60, 0xc8,
-	0x17, 0x43, 0x56, 0xe6, 0x5f, 0x50, 0xda, 0x31, 0x7b, 0xf5, 0x47, 0x5b, 0x42, 0xf8, 0x0b, 0x91,
-	0x1d, 0x80, 0xe9, 0x58, 0x91, 0xb5, 0x39, 0xba, 0xb3, 0xef, 0xc2, 0xb6, 0x17, 0x45, 0x29, 0xcb,
-	0xff, 0x21, 0x3f, 0x83, 0x98, 0xcc, 0x6f, 0x9d, 0x9b, 0x1d, 0xfb, 0xcf, 0x85, 0x99, 0x3c, 0x67,
-	0xbb, 0x71, 0x35, 0x74, 0x94, 0xeb, 0xa1, 0xa3, 0xdc, 0x0c, 0x1d, 0xe5, 0x6e, 0xe8, 0xa0, 0x37,
-	0x23, 0x07, 0xbd, 0x1f, 0x39, 0xe8, 0x6a, 0xe4, 0xa0, 0xeb, 0x91, 0x83, 0xbe, 0x8c, 0x1c, 0xf4,
-	0x75, 0xe4, 0x28, 0x77, 0x23, 0x07, 0xbd, 0xbb, 0x75, 0x94, 0xeb, 0x5b, 0x47, 0xb9, 0xb9, 0x75,
-	0x94, 0xe7, 0x59, 0x9e, 0x80, 0xe8, 0xb7, 0xdb, 0x86, 0xe8, 0xc5, 0xdf, 0xdf, 0x03, 0x00, 0x00,
-	0xff, 0xff, 0xbc, 0x86, 0x4e, 0xf7, 0x30, 0x06, 0x00, 0x00,
+	// 994 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x54, 0xcd, 0x6e, 0xdb, 0x46,
+	0x10, 0x26, 0xc5, 0x1f, 0x51, 0x23, 0xdb, 0x61, 0x36, 0x4a, 0x42, 0x2b, 0x05, 0xad, 0xf2, 0x24,
+	0x04, 0x81, 0x54, 0xb8, 0x45, 0xd1, 0x1e, 0x0a, 0x54, 0x36, 0xd2, 0xda, 0x42, 0xed, 0x00, 0xeb,
+	0xa4, 0x29, 0x72, 0x51, 0x29, 0x6b, 0x4d, 0x11, 0x16, 0x97, 0x2a, 0x97, 0xaa, 0x7f, 0x80, 0x02,
+	0x7d, 0x84, 0x3e, 0x44, 0x0f, 0x05, 0x8a, 0xbe, 0x44, 0x4f, 0xbe, 0xd5, 0xc7, 0xa0, 0x87, 0xa0,
+	0x96, 0x2f, 0x3d, 0xe6, 0x11, 0x0a, 0xee, 0xae, 0x24, 0x32, 0x90, 0x91, 0x04, 0xe8, 0x89, 0x9c,
+	0xef, 0x9b, 0x9d, 0x9d, 0xf9, 0x66, 0x76, 0xa0, 0x92, 0x8c, 0x0f, 0x5b, 0xe3, 0x24, 0x4e, 0x63,
+	0x64, 0xa6, 0x43, 0x9f, 0xc6, 0xac, 0x5e, 0x4d, 0xcf, 0xc6, 0x84, 0x09, 0xb0, 0x5e, 0x0b, 0xe2,
+	0x20, 0xe6, 0xbf, 0xed, 0xec, 0x4f, 0xa2, 0xeb, 0x41, 0x1c, 0x07, 0x23, 0xd2, 0xe6, 0x56, 0x7f,
+	0x72, 0xd4, 0xf6, 0xe9, 0x99, 0xa0, 0xbc, 0x3f, 0x34, 0x58, 0x3d, 0x20, 0x49, 0x48, 0x18, 0x26,
+	0x3f, 0x4c, 0x08, 0x4b, 0xd1, 0x3a, 0x58, 0x51, 0x48, 0x7b, 0x69, 0x18, 0x11, 0x47, 0x6d, 0xa8,
+	0x4d, 0x0d, 0x97, 0xa3, 0x90, 0x3e, 0x0d, 0x23, 0xc2, 0x29, 0xff, 0x54, 0x50, 0x25, 0x49, 0xf9,
+	0xa7, 0x9c, 0xfa, 0x34, 0xa3, 0xd2, 0xc3, 0x21, 0x49, 0x98, 0xa3, 0x35, 0xb4, 0x66, 0x75, 0xb3,
+	0xd6, 0x12, 0x09, 0xb6, 0xbe, 0xf1, 0xfb, 0x64, 0xb4, 0x27, 0xc8, 0x2d, 0xfd, 0xe2, 0xd5, 0x86,
+	0x82, 0xe7, 0xbe, 0x68, 0x13, 0xee, 0x66, 0x21, 0x13, 0xc2, 0xe2, 0xd1, 0x24, 0x0d, 0x63, 0xda,
+	0x3b, 0x09, 0xe9, 0x20, 0x3e, 0x71, 0x74, 0x1e, 0xff, 0x4e, 0xe4, 0x9f, 0xe2, 0x39, 0xf7, 0x9c,
+	0x53, 0xe8, 0x11, 0x80, 0x1f, 0x04, 0x09, 0x09, 0xfc, 0x94, 0x30, 0xc7, 0x68, 0x68, 0xcd, 0xb5,
+	0xcd, 0x95, 0xd9, 0x6d, 0x9d, 0x20, 0x48, 0x70, 0x8e, 0x47, 0x1b, 0x50, 0x65, 0xc7, 0xe1, 0xb8,
+	0x77, 0x38, 0x9c, 0xd0, 0x63, 0xe6, 0x58, 0x0d, 0xb5, 0x69, 0x61, 0xc8, 0xa0, 0x6d, 0x8e, 0xa0,
+	0x87, 0x60, 0x0c, 0x43, 0x9a, 0x32, 0xa7, 0xd2, 0x50, 0x79, 0xde, 0x42, 0xad, 0xd6, 0x4c, 0xad,
+	0x56, 0x87, 0x9e, 0x61, 0xe1, 0x82, 0x10, 0xe8, 0x2c, 0x25, 0x63, 0x07, 0x78, 0x76, 0xfc, 0x1f,
+	0xd5, 0xc0, 0x48, 0x7c, 0x1a, 0x10, 0xa7, 0xca, 0x41, 0x61, 0xa0, 0x2f, 0xe0, 0x01, 0x4b, 0x13,
+	0xe2, 0x47, 0x21, 0x0d, 0xe4, 0xdd, 0xbd, 0x7e, 0x56, 0x75, 0x8f, 0x85, 0xe7, 0xc4, 0x19, 0x34,
+	0xd4, 0xa6, 0x8e, 0x9d, 0xb9, 0x8b, 0xc8, 0x65, 0x2b, 0x73, 0x38, 0x08, 0xcf, 0x49, 0x57, 0xb7,
+	0x4c, 0xbb, 0xdc, 0xd5, 0xad, 0xb2, 0x6d, 0x75, 0x75, 0x6b, 0xc5, 0x5e, 0xed, 0xea, 0xd6, 0xaa,
+	0xbd, 0xe6, 0xfd, 0x5a, 0x82, 0xb5, 0x59, 0xbf, 0xd8, 0x38, 0xa6, 0x8c, 0xa0, 0x26, 0x98, 0x8c,
+	0x23, 0xbc, 0x5d, 0xd5, 0xcd, 0xb5, 0x99, 0x14, 0xc2, 0x6f, 0x47, 0xc1, 0x92, 0x47, 0x75, 0x28,
+	0x9f, 0xf8, 0x09, 0x0d, 0x69, 0xc0, 0xdb, 0x57, 0xd9, 0x51, 0xf0, 0x0c, 0x40, 0x8f, 0x66, 0x2a,
+	0x68, 0x37, 0xab, 0xb0, 0xa3, 0xcc, 0x74, 0xd8, 0x05, 0x7b, 0x51, 0x9d, 0xbc, 0x5d, 0xe7, 0x07,
+	0x3f, 0x98, 0xdf, 0x3e, 0xe3, 0x45, 0x1a, 0xbc, 0xb4, 0x1d, 0x05, 0xdf, 0x62, 0x45, 0xbc, 0x18,
+	0x4a, 0x36, 0xc9, 0xb8, 0x21, 0x54, 0x4e, 0xa5, 0x42, 0x28, 0x89, 0x5b, 0x60, 0x26, 0x84, 0x4d,
+	0x46, 0xa9, 0x77, 0x0e, 0xb7, 0xde, 0xb8, 0x1f, 0x05, 0x60, 0x8e, 0xb2, 0x49, 0xcc, 0x64, 0xca,
+	0xe6, 0x73, 0xb5, 0x30, 0x9f, 0x5b, 0x5f, 0x66, 0x83, 0xf9, 0xf7, 0xab, 0x8d, 0xcf, 0x82, 0x30,
+	0x1d, 0x4e, 0xfa, 0xad, 0xc3, 0x38, 0x6a, 0x07, 0x89, 0x7f, 0xe4, 0x53, 0xbf, 0x1d, 0x85, 0x51,
+	0x98, 0xb4, 0xc7, 0xc7, 0x41, 0x9b, 0xa5, 0xb1, 0x98, 0xab, 0x13, 0xff, 0xac, 0xcd, 0xc3, 0x8d,
+	0xfb, 0xad, 0x17, 0x3c, 0x02, 0x96, 0xe1, 0xbd, 0x9f, 0xa0, 0xb6, 0xac, 0x76, 0xd4, 0xce, 0xf5,
+	0x29, 0x4b, 0xe0, 0xfe, 0x0d, 0x4a, 0xcd, 0xdb, 0xf5, 0x09, 0xdc, 0x0f, 0x59, 0x8f, 0xd0, 0x41,
+	0x2f, 0x3e, 0x92, 0x22, 0xf7, 0x44, 0xc9, 0xbc, 0x7d, 0x16, 0xbe, 0x13, 0xb2, 0xc7, 0x74, 0xf0,
+	0xe4, 0x48, 0x9c, 0x13, 0x61, 0x3c, 0x92, 0x2b, 0x5d, 0x4e, 0xf8, 0x87, 0xb0, 0x22, 0x8f, 0x87,
+	0x74, 0x40, 0x4e, 0xf9, 0x9c, 0xe8, 0xb8, 0x2a, 0xb0, 0xdd, 0x0c, 0xca, 0x92, 0x93, 0xda, 0x97,
+	0x78, 0x72, 0xb7, 0xf3, 0xef, 0x89, 0x87, 0x91, 0x4f, 0x57, 0xba, 0x79, 0x5f, 0xe7, 0xaa, 0xcc,
+	0xb5, 0xe5, 0x1d, 0xaa, 0x14, 0xde, 0xb3, 0x2a, 0xbd, 0xdf, 0x55, 0xb8, 0xcd, 0x05, 0xdc, 0xf7,
+	0xa3, 0xc5, 0x16, 0xaa, 0x81, 0xc1, 0x52, 0x3f, 0x49, 0xf9, 0x38, 0x6a, 0x58, 0x18, 0xc8, 0x06,
+	0x8d, 0xd0, 0x81, 0xdc, 0x0d, 0xd9, 0xef, 0xe2, 0xf1, 0x1a, 0x6f, 0x7f, 0xbc, 0xf9, 0x1d, 0x65,
+	0xbe, 0xfb, 0x8e, 0xea, 0xea, 0x96, 0x6a, 0x97, 0xba, 0xba, 0x55, 0xb2, 0x35, 0x2f, 0x01, 0x94,
+	0x4f, 0x56, 0x3e, 0xc1, 0x1a, 0x18, 0xd4, 0x8f, 0x64, 0xcd, 0x15, 0x2c, 0x0c, 0x54, 0x07, 0x4b,
+	0xbe, 0x2e, 0xa1, 0x6a, 0x05, 0xcf, 0xed, 0x45, 0xde, 0xda, 0x5b, 0xf3, 0xf6, 0xfe, 0x54, 0xe5,
+	0xa5, 0xdf, 0xfa, 0xa3, 0x49, 0x41, 0x22, 0x3e, 0x71, 0xbc, 0x9d, 0x15, 0x2c, 0x8c, 0x85, 0x70,
+	0xfa, 0x12, 0xe1, 0x8c, 0x25, 0xc2, 0x99, 0xef, 0x27, 0x5c, 0xf9, 0xbd, 0x84, 0x2b, 0xd9, 0x5a,
+	0x57, 0xb7, 0x34, 0x5b, 0xf7, 0x26, 0x70, 0xa7, 0x50, 0x83, 0x54, 0xee, 0x1e, 0x98, 0x3f, 0x72,
+	0x44, 0x4a, 0x27, 0xad, 0xff, 0x4b, 0xbb, 0x87, 0xdf, 0x83, 0x9e, 0x4d, 0x30, 0x5a, 0x01, 0x2b,
+	0xfb, 0xf6, 0x70, 0xe7, 0xb9, 0xad, 0xa0, 0x35, 0x00, 0x6e, 0x6d, 0x3f, 0x79, 0xb6, 0xff, 0xd4,
+	0x56, 0xe7, 0xec, 0xc1, 0xb3, 0x3d, 0xbb, 0x34, 0xb7, 0xf6, 0x76, 0xf7, 0x6d, 0x6d, 0x61, 0x75,
+	0xbe, 0xb3, 0x75, 0x64, 0xc3, 0xca, 0xe2, 0xe4, 0x63, 0x6c, 0x1b, 0x9b, 0x7f, 0xa9, 0x60, 0x1c,
+	0x64, 0x6b, 0x01, 0x7d, 0x0e, 0xa6, 0xdc, 0x35, 0x77, 0x8b, 0x2b, 0x58, 0x76, 0xac, 0x7e, 0xef,
+	0x4d, 0x58, 0x88, 0xf0, 0x91, 0x8a, 0xb6, 0x01, 0x16, 0x63, 0x85, 0xd6, 0x0b, 0xea, 0xe6, 0xdf,
+	0x45, 0xbd, 0xbe, 0x8c, 0x92, 0x5a, 0x7e, 0x05, 0xd5, 0x9c, 0xc4, 0xa8, 0xe8, 0x5a, 0x98, 0x9d,
+	0xfa, 0x83, 0xa5, 0x9c, 0x88, 0xb3, 0xd5, 0xb9, 0xb8, 0x72, 0x95, 0xcb, 0x2b, 0x57, 0x79, 0x79,
+	0xe5, 0x2a, 0xaf, 0xaf, 0x5c, 0xf5, 0xe7, 0xa9, 0xab, 0xfe, 0x36, 0x75, 0xd5, 0x8b, 0xa9, 0xab,
+	0x5e, 0x4e, 0x5d, 0xf5, 0x9f, 0xa9, 0xab, 0xfe, 0x3b, 0x75, 0x95, 0xd7, 0x53, 0x57, 0xfd, 0xe5,
+	0xda, 0x55, 0x2e, 0xaf, 0x5d, 0xe5, 0xe5, 0xb5, 0xab, 0xbc, 0x28, 0xf3, 0xfd, 0x38, 0xee, 0xf7,
+	0x4d, 0xde, 0x8b, 0x8f, 0xff, 0x0b, 0x00, 0x00, 0xff, 0xff, 0xe3, 0x6d, 0x97, 0xc3, 0xb0, 0x08,
+	0x00, 0x00,
 }
 
 func (x Aggr) String() string {
@@ -507,6 +707,9 @@ func (this *SeriesRequest) Equal(that interface{}) bool {
 	if this.Range != that1.Range {
 		return false
 	}
+	if this.StreamingChunksBatchSize != that1.StreamingChunksBatchSize {
+		return false
+	}
 	return true
 }
 func (this *SeriesResponse) Equal(that interface{}) bool {
@@ -611,6 +814,176 @@ func (this *SeriesResponse_Hints) Equal(that interface{}) bool {
 	}
 	return true
 }
+func (this *SeriesResponse_StreamingSeries) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*SeriesResponse_StreamingSeries)
+	if !ok {
+		that2, ok := that.(SeriesResponse_StreamingSeries)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.StreamingSeries.Equal(that1.StreamingSeries) {
+		return false
+	}
+	return true
+}
+func (this *SeriesResponse_StreamingChunks) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*SeriesResponse_StreamingChunks)
+	if !ok {
+		that2, ok := that.(SeriesResponse_StreamingChunks)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if !this.StreamingChunks.Equal(that1.StreamingChunks) {
+		return false
+	}
+	return true
+}
+func (this *StreamingSeries) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*StreamingSeries)
+	if !ok {
+		that2, ok := that.(StreamingSeries)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Labels) != len(that1.Labels) {
+		return false
+	}
+	for i := range this.Labels {
+		if !this.Labels[i].Equal(that1.Labels[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *StreamingSeriesBatch) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*StreamingSeriesBatch)
+	if !ok {
+		that2, ok := that.(StreamingSeriesBatch)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Series) != len(that1.Series) {
+		return false
+	}
+	for i := range this.Series {
+		if !this.Series[i].Equal(that1.Series[i]) {
+			return false
+		}
+	}
+	if this.IsEndOfSeriesStream != that1.IsEndOfSeriesStream {
+		return false
+	}
+	return true
+}
+func (this *StreamingChunks) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*StreamingChunks)
+	if !ok {
+		that2, ok := that.(StreamingChunks)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.SeriesIndex != that1.SeriesIndex {
+		return false
+	}
+	if len(this.Chunks) != len(that1.Chunks) {
+		return false
+	}
+	for i := range this.Chunks {
+		if !this.Chunks[i].Equal(&that1.Chunks[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *StreamingChunksBatch) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*StreamingChunksBatch)
+	if !ok {
+		that2, ok := that.(StreamingChunksBatch)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.Series) != len(that1.Series) {
+		return false
+	}
+	for i := range this.Series {
+		if !this.Series[i].Equal(that1.Series[i]) {
+			return false
+		}
+	}
+	return true
+}
 func (this *LabelNamesRequest) Equal(that interface{}) bool {
 	if that == nil {
 		return this == nil
@@ -774,7 +1147,7 @@ func (this *SeriesRequest) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 13)
+	s := make([]string, 0, 14)
 	s = append(s, "&storepb.SeriesRequest{")
 	s = append(s, "MinTime: "+fmt.Sprintf("%#v", this.MinTime)+",\n")
 	s = append(s, "MaxTime: "+fmt.Sprintf("%#v", this.MaxTime)+",\n")
@@ -793,6 +1166,7 @@ func (this *SeriesRequest) GoString() string {
 	}
 	s = append(s, "Step: "+fmt.Sprintf("%#v", this.Step)+",\n")
 	s = append(s, "Range: "+fmt.Sprintf("%#v", this.Range)+",\n")
+	s = append(s, "StreamingChunksBatchSize: "+fmt.Sprintf("%#v", this.StreamingChunksBatchSize)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -800,7 +1174,7 @@ func (this *SeriesResponse) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 7)
+	s := make([]string, 0, 9)
 	s = append(s, "&storepb.SeriesResponse{")
 	if this.Result != nil {
 		s = append(s, "Result: "+fmt.Sprintf("%#v", this.Result)+",\n")
@@ -832,20 +1206,88 @@ func (this *SeriesResponse_Hints) GoString() string {
 		`Hints:` + fmt.Sprintf("%#v", this.Hints) + `}`}, ", ")
 	return s
 }
-func (this *LabelNamesRequest) GoString() string {
+func (this *SeriesResponse_StreamingSeries) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 8)
-	s = append(s, "&storepb.LabelNamesRequest{")
-	s = append(s, "Start: "+fmt.Sprintf("%#v", this.Start)+",\n")
-	s = append(s, "End: "+fmt.Sprintf("%#v", this.End)+",\n")
-	if this.Hints != nil {
-		s = append(s, "Hints: "+fmt.Sprintf("%#v", this.Hints)+",\n")
+	s := strings.Join([]string{`&storepb.SeriesResponse_StreamingSeries{` +
+		`StreamingSeries:` + fmt.Sprintf("%#v", this.StreamingSeries) + `}`}, ", ")
+	return s
+}
+func (this *SeriesResponse_StreamingChunks) GoString() string {
+	if this == nil {
+		return "nil"
 	}
-	if this.Matchers != nil {
-		vs := make([]*LabelMatcher, len(this.Matchers))
-		for i := range vs {
+	s := strings.Join([]string{`&storepb.SeriesResponse_StreamingChunks{` +
+		`StreamingChunks:` + fmt.Sprintf("%#v", this.StreamingChunks) + `}`}, ", ")
+	return s
+}
+func (this *StreamingSeries) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&storepb.StreamingSeries{")
+	s = append(s, "Labels: "+fmt.Sprintf("%#v", this.Labels)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *StreamingSeriesBatch) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&storepb.StreamingSeriesBatch{")
+	if this.Series != nil {
+		s = append(s, "Series: "+fmt.Sprintf("%#v", this.Series)+",\n")
+	}
+	s = append(s, "IsEndOfSeriesStream: "+fmt.Sprintf("%#v", this.IsEndOfSeriesStream)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *StreamingChunks) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&storepb.StreamingChunks{")
+	s = append(s, "SeriesIndex: "+fmt.Sprintf("%#v", this.SeriesIndex)+",\n")
+	if this.Chunks != nil {
+		vs := make([]*AggrChunk, len(this.Chunks))
+		for i := range vs {
+			vs[i] = &this.Chunks[i]
+		}
+		s = append(s, "Chunks: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *StreamingChunksBatch) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&storepb.StreamingChunksBatch{")
+	if this.Series != nil {
+		s = append(s, "Series: "+fmt.Sprintf("%#v", this.Series)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *LabelNamesRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&storepb.LabelNamesRequest{")
+	s = append(s, "Start: "+fmt.Sprintf("%#v", this.Start)+",\n")
+	s = append(s, "End: "+fmt.Sprintf("%#v", this.End)+",\n")
+	if this.Hints != nil {
+		s = append(s, "Hints: "+fmt.Sprintf("%#v", this.Hints)+",\n")
+	}
+	if this.Matchers != nil {
+		vs := make([]*LabelMatcher, len(this.Matchers))
+		for i := range vs {
 			vs[i] = &this.Matchers[i]
 		}
 		s = append(s, "Matchers: "+fmt.Sprintf("%#v", vs)+",\n")
@@ -1134,6 +1576,13 @@ func (m *SeriesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.StreamingChunksBatchSize != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.StreamingChunksBatchSize))
+		i--
+		dAtA[i] = 0x6
+		i--
+		dAtA[i] = 0xa0
+	}
 	if m.Range != 0 {
 		i = encodeVarintRpc(dAtA, i, uint64(m.Range))
 		i--
@@ -1301,6 +1750,209 @@ func (m *SeriesResponse_Hints) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	}
 	return len(dAtA) - i, nil
 }
+func (m *SeriesResponse_StreamingSeries) MarshalTo(dAtA []byte) (int, error) {
+	return m.MarshalToSizedBuffer(dAtA[:m.Size()])
+}
+
+func (m *SeriesResponse_StreamingSeries) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	if m.StreamingSeries != nil {
+		{
+			size, err := m.StreamingSeries.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintRpc(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x22
+	}
+	return len(dAtA) - i, nil
+}
+func (m *SeriesResponse_StreamingChunks) MarshalTo(dAtA []byte) (int, error) {
+	return m.MarshalToSizedBuffer(dAtA[:m.Size()])
+}
+
+func (m *SeriesResponse_StreamingChunks) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	if m.StreamingChunks != nil {
+		{
+			size, err := m.StreamingChunks.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintRpc(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x2a
+	}
+	return len(dAtA) - i, nil
+}
+func (m *StreamingSeries) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StreamingSeries) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *StreamingSeries) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Labels) > 0 {
+		for iNdEx := len(m.Labels) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size := m.Labels[iNdEx].Size()
+				i -= size
+				if _, err := m.Labels[iNdEx].MarshalTo(dAtA[i:]); err != nil {
+					return 0, err
+				}
+				i = encodeVarintRpc(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *StreamingSeriesBatch) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StreamingSeriesBatch) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *StreamingSeriesBatch) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.IsEndOfSeriesStream {
+		i--
+		if m.IsEndOfSeriesStream {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Series) > 0 {
+		for iNdEx := len(m.Series) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Series[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintRpc(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *StreamingChunks) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StreamingChunks) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *StreamingChunks) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Chunks) > 0 {
+		for iNdEx := len(m.Chunks) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Chunks[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintRpc(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if m.SeriesIndex != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.SeriesIndex))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *StreamingChunksBatch) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StreamingChunksBatch) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *StreamingChunksBatch) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Series) > 0 {
+		for iNdEx := len(m.Series) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Series[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintRpc(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
 func (m *LabelNamesRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -1584,6 +2236,9 @@ func (m *SeriesRequest) Size() (n int) {
 	if m.Range != 0 {
 		n += 1 + sovRpc(uint64(m.Range))
 	}
+	if m.StreamingChunksBatchSize != 0 {
+		n += 2 + sovRpc(uint64(m.StreamingChunksBatchSize))
+	}
 	return n
 }
 
@@ -1633,24 +2288,38 @@ func (m *SeriesResponse_Hints) Size() (n int) {
 	}
 	return n
 }
-func (m *LabelNamesRequest) Size() (n int) {
+func (m *SeriesResponse_StreamingSeries) Size() (n int) {
 	if m == nil {
 		return 0
 	}
 	var l int
 	_ = l
-	if m.Start != 0 {
-		n += 1 + sovRpc(uint64(m.Start))
+	if m.StreamingSeries != nil {
+		l = m.StreamingSeries.Size()
+		n += 1 + l + sovRpc(uint64(l))
 	}
-	if m.End != 0 {
-		n += 1 + sovRpc(uint64(m.End))
+	return n
+}
+func (m *SeriesResponse_StreamingChunks) Size() (n int) {
+	if m == nil {
+		return 0
 	}
-	if m.Hints != nil {
-		l = m.Hints.Size()
+	var l int
+	_ = l
+	if m.StreamingChunks != nil {
+		l = m.StreamingChunks.Size()
 		n += 1 + l + sovRpc(uint64(l))
 	}
-	if len(m.Matchers) > 0 {
-		for _, e := range m.Matchers {
+	return n
+}
+func (m *StreamingSeries) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Labels) > 0 {
+		for _, e := range m.Labels {
 			l = e.Size()
 			n += 1 + l + sovRpc(uint64(l))
 		}
@@ -1658,40 +2327,116 @@ func (m *LabelNamesRequest) Size() (n int) {
 	return n
 }
 
-func (m *LabelNamesResponse) Size() (n int) {
+func (m *StreamingSeriesBatch) Size() (n int) {
 	if m == nil {
 		return 0
 	}
 	var l int
 	_ = l
-	if len(m.Names) > 0 {
-		for _, s := range m.Names {
-			l = len(s)
-			n += 1 + l + sovRpc(uint64(l))
-		}
-	}
-	if len(m.Warnings) > 0 {
-		for _, s := range m.Warnings {
-			l = len(s)
+	if len(m.Series) > 0 {
+		for _, e := range m.Series {
+			l = e.Size()
 			n += 1 + l + sovRpc(uint64(l))
 		}
 	}
-	if m.Hints != nil {
-		l = m.Hints.Size()
-		n += 1 + l + sovRpc(uint64(l))
+	if m.IsEndOfSeriesStream {
+		n += 2
 	}
 	return n
 }
 
-func (m *LabelValuesRequest) Size() (n int) {
+func (m *StreamingChunks) Size() (n int) {
 	if m == nil {
 		return 0
 	}
 	var l int
 	_ = l
-	l = len(m.Label)
-	if l > 0 {
-		n += 1 + l + sovRpc(uint64(l))
+	if m.SeriesIndex != 0 {
+		n += 1 + sovRpc(uint64(m.SeriesIndex))
+	}
+	if len(m.Chunks) > 0 {
+		for _, e := range m.Chunks {
+			l = e.Size()
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *StreamingChunksBatch) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Series) > 0 {
+		for _, e := range m.Series {
+			l = e.Size()
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *LabelNamesRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Start != 0 {
+		n += 1 + sovRpc(uint64(m.Start))
+	}
+	if m.End != 0 {
+		n += 1 + sovRpc(uint64(m.End))
+	}
+	if m.Hints != nil {
+		l = m.Hints.Size()
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	if len(m.Matchers) > 0 {
+		for _, e := range m.Matchers {
+			l = e.Size()
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *LabelNamesResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Names) > 0 {
+		for _, s := range m.Names {
+			l = len(s)
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
+	if len(m.Warnings) > 0 {
+		for _, s := range m.Warnings {
+			l = len(s)
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
+	if m.Hints != nil {
+		l = m.Hints.Size()
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	return n
+}
+
+func (m *LabelValuesRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Label)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
 	}
 	if m.Start != 0 {
 		n += 1 + sovRpc(uint64(m.Start))
@@ -1762,6 +2507,7 @@ func (this *SeriesRequest) String() string {
 		`Hints:` + strings.Replace(fmt.Sprintf("%v", this.Hints), "Any", "types.Any", 1) + `,`,
 		`Step:` + fmt.Sprintf("%v", this.Step) + `,`,
 		`Range:` + fmt.Sprintf("%v", this.Range) + `,`,
+		`StreamingChunksBatchSize:` + fmt.Sprintf("%v", this.StreamingChunksBatchSize) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1806,6 +2552,83 @@ func (this *SeriesResponse_Hints) String() string {
 	}, "")
 	return s
 }
+func (this *SeriesResponse_StreamingSeries) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&SeriesResponse_StreamingSeries{`,
+		`StreamingSeries:` + strings.Replace(fmt.Sprintf("%v", this.StreamingSeries), "StreamingSeriesBatch", "StreamingSeriesBatch", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *SeriesResponse_StreamingChunks) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&SeriesResponse_StreamingChunks{`,
+		`StreamingChunks:` + strings.Replace(fmt.Sprintf("%v", this.StreamingChunks), "StreamingChunksBatch", "StreamingChunksBatch", 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *StreamingSeries) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&StreamingSeries{`,
+		`Labels:` + fmt.Sprintf("%v", this.Labels) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *StreamingSeriesBatch) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForSeries := "[]*StreamingSeries{"
+	for _, f := range this.Series {
+		repeatedStringForSeries += strings.Replace(f.String(), "StreamingSeries", "StreamingSeries", 1) + ","
+	}
+	repeatedStringForSeries += "}"
+	s := strings.Join([]string{`&StreamingSeriesBatch{`,
+		`Series:` + repeatedStringForSeries + `,`,
+		`IsEndOfSeriesStream:` + fmt.Sprintf("%v", this.IsEndOfSeriesStream) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *StreamingChunks) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForChunks := "[]AggrChunk{"
+	for _, f := range this.Chunks {
+		repeatedStringForChunks += fmt.Sprintf("%v", f) + ","
+	}
+	repeatedStringForChunks += "}"
+	s := strings.Join([]string{`&StreamingChunks{`,
+		`SeriesIndex:` + fmt.Sprintf("%v", this.SeriesIndex) + `,`,
+		`Chunks:` + repeatedStringForChunks + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *StreamingChunksBatch) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForSeries := "[]*StreamingChunks{"
+	for _, f := range this.Series {
+		repeatedStringForSeries += strings.Replace(f.String(), "StreamingChunks", "StreamingChunks", 1) + ","
+	}
+	repeatedStringForSeries += "}"
+	s := strings.Join([]string{`&StreamingChunksBatch{`,
+		`Series:` + repeatedStringForSeries + `,`,
+		`}`,
+	}, "")
+	return s
+}
 func (this *LabelNamesRequest) String() string {
 	if this == nil {
 		return "nil"
@@ -2158,6 +2981,25 @@ func (m *SeriesRequest) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 100:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StreamingChunksBatchSize", wireType)
+			}
+			m.StreamingChunksBatchSize = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StreamingChunksBatchSize |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
@@ -2313,6 +3155,463 @@ func (m *SeriesResponse) Unmarshal(dAtA []byte) error {
 			}
 			m.Result = &SeriesResponse_Hints{v}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StreamingSeries", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := &StreamingSeriesBatch{}
+			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			m.Result = &SeriesResponse_StreamingSeries{v}
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StreamingChunks", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := &StreamingChunksBatch{}
+			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			m.Result = &SeriesResponse_StreamingChunks{v}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *StreamingSeries) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: StreamingSeries: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: StreamingSeries: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Labels", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Labels = append(m.Labels, github_com_grafana_mimir_pkg_storegateway_labelpb.ZLabel{})
+			if err := m.Labels[len(m.Labels)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *StreamingSeriesBatch) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: StreamingSeriesBatch: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: StreamingSeriesBatch: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Series", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Series = append(m.Series, &StreamingSeries{})
+			if err := m.Series[len(m.Series)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IsEndOfSeriesStream", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.IsEndOfSeriesStream = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *StreamingChunks) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: StreamingChunks: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: StreamingChunks: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SeriesIndex", wireType)
+			}
+			m.SeriesIndex = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SeriesIndex |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Chunks", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Chunks = append(m.Chunks, AggrChunk{})
+			if err := m.Chunks[len(m.Chunks)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *StreamingChunksBatch) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: StreamingChunksBatch: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: StreamingChunksBatch: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Series", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Series = append(m.Series, &StreamingChunks{})
+			if err := m.Series[len(m.Series)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
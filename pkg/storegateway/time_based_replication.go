@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/grafana/dskit/ring"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+)
+
+var (
+	errTimeBasedReplicationNoBrackets         = errors.New("time-based replication requires at least one age bracket")
+	errTimeBasedReplicationBracketsNotInOrder = errors.New("time-based replication age brackets must be sorted by increasing max age")
+)
+
+// ReplicationAgeBracket configures the replication factor of the blocks whose data is more recent than the max age.
+type ReplicationAgeBracket struct {
+	MaxAge            model.Duration `yaml:"max_age" doc:"description=Max age of the blocks in the bracket. The age of a block is computed from the block max time."`
+	ReplicationFactor int            `yaml:"replication_factor" doc:"description=Replication factor of the blocks in the bracket. Must be a multiple of the store-gateway ring replication factor."`
+}
+
+// TimeBasedReplicationConfig configures the replication of recent blocks to more store-gateways than older blocks.
+type TimeBasedReplicationConfig struct {
+	Enabled     bool                    `yaml:"enabled" category:"experimental"`
+	AgeBrackets []ReplicationAgeBracket `yaml:"age_brackets" doc:"nocli|description=List of age brackets, sorted by increasing max age. A block is replicated according to the first bracket whose max age is greater than or equal to the block age. Blocks older than the max age of every bracket are replicated according to the store-gateway ring replication factor." category:"experimental"`
+}
+
+// RegisterFlagsWithPrefix registers the TimeBasedReplicationConfig flags.
+func (cfg *TimeBasedReplicationConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	f.BoolVar(&cfg.Enabled, prefix+"enabled", false, "Enable time-based replication of blocks: the blocks matching an age bracket are replicated to more store-gateways than the other blocks, and queriers spread requests across all replicas. Age brackets are configured in the YAML configuration."+sharedOptionWithRingClient)
+}
+
+// Validate the TimeBasedReplicationConfig.
+func (cfg *TimeBasedReplicationConfig) Validate(replicationFactor int) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if len(cfg.AgeBrackets) == 0 {
+		return errTimeBasedReplicationNoBrackets
+	}
+
+	for i, bracket := range cfg.AgeBrackets {
+		if bracket.MaxAge <= 0 {
+			return fmt.Errorf("invalid time-based replication age bracket %d: max age must be greater than 0", i)
+		}
+		if replicationFactor <= 0 || bracket.ReplicationFactor < replicationFactor || bracket.ReplicationFactor%replicationFactor != 0 {
+			return fmt.Errorf("invalid time-based replication age bracket %d: replication factor must be a multiple of the store-gateway ring replication factor (%d)", i, replicationFactor)
+		}
+		if i > 0 && bracket.MaxAge <= cfg.AgeBrackets[i-1].MaxAge {
+			return errTimeBasedReplicationBracketsNotInOrder
+		}
+	}
+
+	return nil
+}
+
+// ReplicaSets returns the number of replica sets the block with the given max time (in milliseconds) is replicated to.
+// Each replica set is made of replicationFactor store-gateways, looked up in the ring with a different key.
+func (cfg *TimeBasedReplicationConfig) ReplicaSets(blockMaxTime int64, replicationFactor int, now time.Time) int {
+	if !cfg.Enabled || replicationFactor <= 0 {
+		return 1
+	}
+
+	age := now.Sub(time.UnixMilli(blockMaxTime))
+	for _, bracket := range cfg.AgeBrackets {
+		if age <= time.Duration(bracket.MaxAge) {
+			return bracket.ReplicationFactor / replicationFactor
+		}
+	}
+
+	return 1
+}
+
+// GetBlockReplicationSet returns the store-gateways owning the given number of replica sets of a block. Each replica
+// set is looked up in the ring with a different key, so the replica sets honor the ring zone-awareness. The returned
+// set doesn't retain the provided buffers.
+func GetBlockReplicationSet(r ring.ReadRing, blockID ulid.ULID, replicaSets int, op ring.Operation, bufDescs []ring.InstanceDesc, bufHosts, bufZones []string) (ring.ReplicationSet, error) {
+	var instances []ring.InstanceDesc
+
+	if replicaSets < 1 {
+		replicaSets = 1
+	}
+
+	for replicaSet := 0; replicaSet < replicaSets; replicaSet++ {
+		set, err := r.Get(mimir_tsdb.HashBlockIDReplicaSet(blockID, replicaSet), op, bufDescs, bufHosts, bufZones)
+		if err != nil {
+			return ring.ReplicationSet{}, err
+		}
+
+		// Different replica sets may be owned by the same store-gateways.
+		for _, instance := range set.Instances {
+			if !containsInstanceAddr(instances, instance.Addr) {
+				instances = append(instances, instance)
+			}
+		}
+	}
+
+	return ring.ReplicationSet{Instances: instances}, nil
+}
+
+func containsInstanceAddr(instances []ring.InstanceDesc, addr string) bool {
+	for _, instance := range instances {
+		if instance.Addr == addr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/services"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/extprom"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+)
+
+func TestTimeBasedReplicationConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg         TimeBasedReplicationConfig
+		expectedErr string
+	}{
+		"disabled": {
+			cfg: TimeBasedReplicationConfig{},
+		},
+		"valid age brackets": {
+			cfg: TimeBasedReplicationConfig{Enabled: true, AgeBrackets: []ReplicationAgeBracket{
+				{MaxAge: model.Duration(12 * time.Hour), ReplicationFactor: 9},
+				{MaxAge: model.Duration(7 * 24 * time.Hour), ReplicationFactor: 6},
+			}},
+		},
+		"no age brackets": {
+			cfg:         TimeBasedReplicationConfig{Enabled: true},
+			expectedErr: errTimeBasedReplicationNoBrackets.Error(),
+		},
+		"invalid max age": {
+			cfg: TimeBasedReplicationConfig{Enabled: true, AgeBrackets: []ReplicationAgeBracket{
+				{MaxAge: 0, ReplicationFactor: 6},
+			}},
+			expectedErr: "invalid time-based replication age bracket 0: max age must be greater than 0",
+		},
+		"replication factor lower than the ring one": {
+			cfg: TimeBasedReplicationConfig{Enabled: true, AgeBrackets: []ReplicationAgeBracket{
+				{MaxAge: model.Duration(time.Hour), ReplicationFactor: 6},
+				{MaxAge: model.Duration(2 * time.Hour), ReplicationFactor: 1},
+			}},
+			expectedErr: "invalid time-based replication age bracket 1: replication factor must be a multiple of the store-gateway ring replication factor (3)",
+		},
+		"replication factor not a multiple of the ring one": {
+			cfg: TimeBasedReplicationConfig{Enabled: true, AgeBrackets: []ReplicationAgeBracket{
+				{MaxAge: model.Duration(time.Hour), ReplicationFactor: 4},
+			}},
+			expectedErr: "invalid time-based replication age bracket 0: replication factor must be a multiple of the store-gateway ring replication factor (3)",
+		},
+		"age brackets not in order": {
+			cfg: TimeBasedReplicationConfig{Enabled: true, AgeBrackets: []ReplicationAgeBracket{
+				{MaxAge: model.Duration(2 * time.Hour), ReplicationFactor: 6},
+				{MaxAge: model.Duration(time.Hour), ReplicationFactor: 9},
+			}},
+			expectedErr: errTimeBasedReplicationBracketsNotInOrder.Error(),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := testData.cfg.Validate(3)
+			if testData.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, testData.expectedErr)
+			}
+		})
+	}
+}
+
+func TestTimeBasedReplicationConfig_ReplicaSets(t *testing.T) {
+	now := time.Now()
+	cfg := TimeBasedReplicationConfig{Enabled: true, AgeBrackets: []ReplicationAgeBracket{
+		{MaxAge: model.Duration(12 * time.Hour), ReplicationFactor: 9},
+		{MaxAge: model.Duration(7 * 24 * time.Hour), ReplicationFactor: 6},
+	}}
+
+	assert.Equal(t, 3, cfg.ReplicaSets(now.Add(time.Hour).UnixMilli(), 3, now))
+	assert.Equal(t, 3, cfg.ReplicaSets(now.Add(-11*time.Hour).UnixMilli(), 3, now))
+	assert.Equal(t, 2, cfg.ReplicaSets(now.Add(-13*time.Hour).UnixMilli(), 3, now))
+	assert.Equal(t, 1, cfg.ReplicaSets(now.Add(-8*24*time.Hour).UnixMilli(), 3, now))
+
+	cfg.Enabled = false
+	assert.Equal(t, 1, cfg.ReplicaSets(now.UnixMilli(), 3, now))
+}
+
+func TestTimeBasedReplicationShardingStrategy(t *testing.T) {
+	const (
+		userID            = "user-1"
+		numZones          = 3
+		instancesPerZone  = 4
+		replicationFactor = 3
+		numBlocks         = 50
+	)
+
+	ctx := context.Background()
+	now := time.Now()
+	rnd := rand.New(rand.NewSource(1))
+
+	store, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	instanceZones := map[string]string{}
+	require.NoError(t, store.CAS(ctx, "test", func(in interface{}) (interface{}, bool, error) {
+		d := ring.NewDesc()
+		for z := 1; z <= numZones; z++ {
+			for i := 1; i <= instancesPerZone; i++ {
+				addr := fmt.Sprintf("127.0.%d.%d", z, i)
+				instanceZones[addr] = fmt.Sprintf("zone-%d", z)
+
+				tokens := make([]uint32, 0, 64)
+				for len(tokens) < cap(tokens) {
+					tokens = append(tokens, rnd.Uint32())
+				}
+				d.AddIngester(fmt.Sprintf("instance-%d-%d", z, i), addr, instanceZones[addr], tokens, ring.ACTIVE, now)
+			}
+		}
+		return d, true, nil
+	}))
+
+	cfg := ring.Config{
+		ReplicationFactor:    replicationFactor,
+		HeartbeatTimeout:     time.Minute,
+		ZoneAwarenessEnabled: true,
+		SubringCacheDisabled: true,
+	}
+
+	r, err := ring.NewWithStoreClientAndStrategy(cfg, "test", "test", store, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, r))
+	defer services.StopAndAwaitTerminated(ctx, r) //nolint:errcheck
+	require.NoError(t, ring.WaitInstanceState(ctx, r, "instance-1-1", ring.ACTIVE))
+
+	replication := TimeBasedReplicationConfig{Enabled: true, AgeBrackets: []ReplicationAgeBracket{
+		{MaxAge: model.Duration(24 * time.Hour), ReplicationFactor: 2 * replicationFactor},
+	}}
+
+	// Half of the blocks are recent, and the other half are older than the age bracket.
+	recentBlocks := map[ulid.ULID]bool{}
+	newMetas := func() map[ulid.ULID]*metadata.Meta {
+		metas := map[ulid.ULID]*metadata.Meta{}
+		for i := 0; i < numBlocks; i++ {
+			id := ulid.MustNew(uint64(i), rand.New(rand.NewSource(int64(i))))
+			maxTime := now.Add(-48 * time.Hour)
+			if i%2 == 0 {
+				maxTime = now.Add(-time.Hour)
+				recentBlocks[id] = true
+			}
+			metas[id] = &metadata.Meta{BlockMeta: tsdb.BlockMeta{ULID: id, MinTime: maxTime.Add(-2 * time.Hour).UnixMilli(), MaxTime: maxTime.UnixMilli()}}
+		}
+		return metas
+	}
+
+	// Find the owners of each block.
+	owners := map[ulid.ULID][]string{}
+	for addr := range instanceZones {
+		filter := NewTimeBasedReplicationShardingStrategy(r, addr, addr, &shardingLimitsMock{}, replication, log.NewNopLogger())
+		synced := extprom.NewTxGaugeVec(nil, prometheus.GaugeOpts{}, []string{"state"})
+
+		metas := newMetas()
+		require.NoError(t, filter.FilterBlocks(ctx, userID, metas, nil, synced))
+		for id := range metas {
+			owners[id] = append(owners[id], addr)
+		}
+	}
+
+	require.Len(t, owners, numBlocks)
+	moreReplicas := 0
+
+	for id, addrs := range owners {
+		replicasPerZone := map[string]int{}
+		for _, addr := range addrs {
+			replicasPerZone[instanceZones[addr]]++
+		}
+
+		// Every block is replicated across all zones.
+		require.Len(t, replicasPerZone, numZones)
+
+		if !recentBlocks[id] {
+			// Old blocks are owned by the same store-gateways as with the shuffle sharding strategy.
+			require.Len(t, addrs, replicationFactor)
+			set, err := r.Get(mimir_tsdb.HashBlockID(id), BlocksOwnerSync, nil, nil, nil)
+			require.NoError(t, err)
+			for _, addr := range addrs {
+				assert.True(t, set.Includes(addr))
+			}
+			continue
+		}
+
+		// Recent blocks are replicated up to 2 times per zone.
+		require.GreaterOrEqual(t, len(addrs), replicationFactor)
+		require.LessOrEqual(t, len(addrs), 2*replicationFactor)
+		for _, count := range replicasPerZone {
+			require.LessOrEqual(t, count, 2)
+		}
+		if len(addrs) > replicationFactor {
+			moreReplicas++
+		}
+
+		// The owners are the replication set used by queriers.
+		set, err := GetBlockReplicationSet(r, id, replication.ReplicaSets(now.Add(-time.Hour).UnixMilli(), replicationFactor, now), BlocksRead, nil, nil, nil)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, addrs, set.GetAddresses())
+	}
+
+	// Most of the recent blocks get additional replicas.
+	assert.Greater(t, moreReplicas, numBlocks/4)
+}
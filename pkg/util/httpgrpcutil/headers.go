@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package httpgrpcutil
+
+import (
+	"net/textproto"
+
+	"github.com/weaveworks/common/httpgrpc"
+)
+
+const (
+	// QuerySourceHeader is the HTTP header used to tell which component issued a query.
+	// It's used by the query-scheduler to classify queries into priority classes.
+	QuerySourceHeader = "X-Mimir-Query-Source"
+
+	// QuerySourceRuler is the value of QuerySourceHeader for queries issued by the ruler.
+	QuerySourceRuler = "ruler"
+
+	// PartialResponseHeader is the HTTP header used to enable ("true") or disable ("false") the
+	// partial response mode in the querier for a single query, overriding the per-tenant setting.
+	PartialResponseHeader = "X-Mimir-Partial-Response"
+)
+
+// GetHeader returns the first value of the input header in the request, or an empty string if not found.
+func GetHeader(req *httpgrpc.HTTPRequest, name string) string {
+	name = textproto.CanonicalMIMEHeaderKey(name)
+
+	for _, h := range req.GetHeaders() {
+		if textproto.CanonicalMIMEHeaderKey(h.Key) == name && len(h.Values) > 0 {
+			return h.Values[0]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package httpgrpcutil
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/weaveworks/common/httpgrpc"
+)
+
+// GetPathAndQuery returns the URL path of the request and the value of its "query" parameter,
+// looked up both in the URL and, for form-encoded POST requests, in the body. The returned query
+// is empty if the request has no "query" parameter (e.g. label names requests).
+func GetPathAndQuery(req *httpgrpc.HTTPRequest) (path, query string) {
+	u, err := url.ParseRequestURI(req.GetUrl())
+	if err != nil {
+		return "", ""
+	}
+
+	if query = u.Query().Get("query"); query != "" || req.GetMethod() != http.MethodPost {
+		return u.Path, query
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(GetHeader(req, "Content-Type")); err != nil || mediaType != "application/x-www-form-urlencoded" {
+		return u.Path, ""
+	}
+
+	values, err := url.ParseQuery(string(req.GetBody()))
+	if err != nil {
+		return u.Path, ""
+	}
+
+	return u.Path, values.Get("query")
+}
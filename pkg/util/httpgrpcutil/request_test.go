@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package httpgrpcutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/weaveworks/common/httpgrpc"
+)
+
+func TestGetPathAndQuery(t *testing.T) {
+	tests := map[string]struct {
+		req           *httpgrpc.HTTPRequest
+		expectedPath  string
+		expectedQuery string
+	}{
+		"GET request with query": {
+			req:           &httpgrpc.HTTPRequest{Method: "GET", Url: "/prometheus/api/v1/query_range?query=sum%28up%29&start=0&end=60&step=15"},
+			expectedPath:  "/prometheus/api/v1/query_range",
+			expectedQuery: "sum(up)",
+		},
+		"GET request without query": {
+			req:          &httpgrpc.HTTPRequest{Method: "GET", Url: "/prometheus/api/v1/labels?start=0"},
+			expectedPath: "/prometheus/api/v1/labels",
+		},
+		"POST request with query in the form-encoded body": {
+			req: &httpgrpc.HTTPRequest{
+				Method:  "POST",
+				Url:     "/prometheus/api/v1/query",
+				Headers: []*httpgrpc.Header{{Key: "Content-Type", Values: []string{"application/x-www-form-urlencoded"}}},
+				Body:    []byte("query=sum%28up%29&time=60"),
+			},
+			expectedPath:  "/prometheus/api/v1/query",
+			expectedQuery: "sum(up)",
+		},
+		"POST request with a body which is not form-encoded": {
+			req: &httpgrpc.HTTPRequest{
+				Method: "POST",
+				Url:    "/prometheus/api/v1/query",
+				Body:   []byte("query=sum%28up%29&time=60"),
+			},
+			expectedPath: "/prometheus/api/v1/query",
+		},
+		"invalid URL": {
+			req: &httpgrpc.HTTPRequest{Method: "GET", Url: ":invalid"},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			path, query := GetPathAndQuery(testData.req)
+			assert.Equal(t, testData.expectedPath, path)
+			assert.Equal(t, testData.expectedQuery, query)
+		})
+	}
+}
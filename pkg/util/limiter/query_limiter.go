@@ -36,6 +36,14 @@ var (
 		"the query exceeded the maximum number of chunks (limit: %d chunks)",
 		validation.MaxChunksPerQueryFlag,
 	)
+	MaxReturnedSeriesHitMsgFormat = globalerror.MaxReturnedSeriesPerQuery.MessageWithPerTenantLimitConfig(
+		"the query returned more than the maximum number of series (limit: %d series)",
+		validation.MaxReturnedSeriesPerQueryFlag,
+	)
+	MaxQueryResponseSizeHitMsgFormat = globalerror.MaxQueryResponseSize.MessageWithPerTenantLimitConfig(
+		"the query response exceeded the maximum size (limit: %d bytes)",
+		validation.MaxQueryResponseSizeFlag,
+	)
 )
 
 type QueryLimiter struct {
@@ -25,30 +25,35 @@ import (
 )
 
 const (
-	MaxSeriesPerMetricFlag     = "ingester.max-global-series-per-metric"
-	MaxMetadataPerMetricFlag   = "ingester.max-global-metadata-per-metric"
-	MaxSeriesPerUserFlag       = "ingester.max-global-series-per-user"
-	MaxMetadataPerUserFlag     = "ingester.max-global-metadata-per-user"
-	MaxChunksPerQueryFlag      = "querier.max-fetched-chunks-per-query"
-	MaxChunkBytesPerQueryFlag  = "querier.max-fetched-chunk-bytes-per-query"
-	MaxSeriesPerQueryFlag      = "querier.max-fetched-series-per-query"
-	maxLabelNamesPerSeriesFlag = "validation.max-label-names-per-series"
-	maxLabelNameLengthFlag     = "validation.max-length-label-name"
-	maxLabelValueLengthFlag    = "validation.max-length-label-value"
-	maxMetadataLengthFlag      = "validation.max-metadata-length"
-	creationGracePeriodFlag    = "validation.create-grace-period"
-	maxQueryLengthFlag         = "store.max-query-length"
-	maxTotalQueryLengthFlag    = "query-frontend.max-total-query-length"
-	requestRateFlag            = "distributor.request-rate-limit"
-	requestBurstSizeFlag       = "distributor.request-burst-size"
-	ingestionRateFlag          = "distributor.ingestion-rate-limit"
-	ingestionBurstSizeFlag     = "distributor.ingestion-burst-size"
-	HATrackerMaxClustersFlag   = "distributor.ha-tracker.max-clusters"
+	MaxSeriesPerMetricFlag        = "ingester.max-global-series-per-metric"
+	MaxMetadataPerMetricFlag      = "ingester.max-global-metadata-per-metric"
+	MaxSeriesPerUserFlag          = "ingester.max-global-series-per-user"
+	MaxMetadataPerUserFlag        = "ingester.max-global-metadata-per-user"
+	MaxChunksPerQueryFlag         = "querier.max-fetched-chunks-per-query"
+	MaxChunkBytesPerQueryFlag     = "querier.max-fetched-chunk-bytes-per-query"
+	MaxSeriesPerQueryFlag         = "querier.max-fetched-series-per-query"
+	MaxReturnedSeriesPerQueryFlag = "querier.max-returned-series-per-query"
+	MaxQueryResponseSizeFlag      = "querier.max-query-response-size-bytes"
+	maxLabelNamesPerSeriesFlag    = "validation.max-label-names-per-series"
+	maxLabelNameLengthFlag        = "validation.max-length-label-name"
+	maxLabelValueLengthFlag       = "validation.max-length-label-value"
+	maxMetadataLengthFlag         = "validation.max-metadata-length"
+	creationGracePeriodFlag       = "validation.create-grace-period"
+	maxQueryLengthFlag            = "store.max-query-length"
+	maxTotalQueryLengthFlag       = "query-frontend.max-total-query-length"
+	requestRateFlag               = "distributor.request-rate-limit"
+	requestBurstSizeFlag          = "distributor.request-burst-size"
+	ingestionRateFlag             = "distributor.ingestion-rate-limit"
+	ingestionBurstSizeFlag        = "distributor.ingestion-burst-size"
+	HATrackerMaxClustersFlag      = "distributor.ha-tracker.max-clusters"
+	compactorMaxBlocksPerJobFlag  = "compactor.max-blocks-per-job"
 
 	// MinCompactorPartialBlockDeletionDelay is the minimum partial blocks deletion delay that can be configured in Mimir.
 	MinCompactorPartialBlockDeletionDelay = 4 * time.Hour
 )
 
+var errInvalidCompactorMaxBlocksPerJob = fmt.Errorf("invalid %s value, must be 0 or greater than 1", compactorMaxBlocksPerJobFlag)
+
 // LimitError are errors that do not comply with the limits specified.
 type LimitError string
 
@@ -101,22 +106,29 @@ type Limits struct {
 	OutOfOrderTimeWindow model.Duration `yaml:"out_of_order_time_window" json:"out_of_order_time_window" category:"experimental"`
 
 	// Querier enforced limits.
-	MaxChunksPerQuery              int            `yaml:"max_fetched_chunks_per_query" json:"max_fetched_chunks_per_query"`
-	MaxFetchedSeriesPerQuery       int            `yaml:"max_fetched_series_per_query" json:"max_fetched_series_per_query"`
-	MaxFetchedChunkBytesPerQuery   int            `yaml:"max_fetched_chunk_bytes_per_query" json:"max_fetched_chunk_bytes_per_query"`
-	MaxQueryLookback               model.Duration `yaml:"max_query_lookback" json:"max_query_lookback"`
-	MaxQueryLength                 model.Duration `yaml:"max_query_length" json:"max_query_length"`
-	MaxQueryParallelism            int            `yaml:"max_query_parallelism" json:"max_query_parallelism"`
-	MaxLabelsQueryLength           model.Duration `yaml:"max_labels_query_length" json:"max_labels_query_length"`
-	MaxCacheFreshness              model.Duration `yaml:"max_cache_freshness" json:"max_cache_freshness" category:"advanced"`
-	MaxQueriersPerTenant           int            `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
-	QueryShardingTotalShards       int            `yaml:"query_sharding_total_shards" json:"query_sharding_total_shards"`
-	QueryShardingMaxShardedQueries int            `yaml:"query_sharding_max_sharded_queries" json:"query_sharding_max_sharded_queries"`
-	SplitInstantQueriesByInterval  model.Duration `yaml:"split_instant_queries_by_interval" json:"split_instant_queries_by_interval" category:"experimental"`
+	MaxChunksPerQuery                 int            `yaml:"max_fetched_chunks_per_query" json:"max_fetched_chunks_per_query"`
+	MaxFetchedSeriesPerQuery          int            `yaml:"max_fetched_series_per_query" json:"max_fetched_series_per_query"`
+	MaxFetchedChunkBytesPerQuery      int            `yaml:"max_fetched_chunk_bytes_per_query" json:"max_fetched_chunk_bytes_per_query"`
+	MaxReturnedSeriesPerQuery         int            `yaml:"max_returned_series_per_query" json:"max_returned_series_per_query" category:"experimental"`
+	MaxQueryResponseSizeBytes         int            `yaml:"max_query_response_size_bytes" json:"max_query_response_size_bytes" category:"experimental"`
+	MaxQueryLookback                  model.Duration `yaml:"max_query_lookback" json:"max_query_lookback"`
+	MaxQueryLength                    model.Duration `yaml:"max_query_length" json:"max_query_length"`
+	MaxQueryParallelism               int            `yaml:"max_query_parallelism" json:"max_query_parallelism"`
+	MaxLabelsQueryLength              model.Duration `yaml:"max_labels_query_length" json:"max_labels_query_length"`
+	MaxCacheFreshness                 model.Duration `yaml:"max_cache_freshness" json:"max_cache_freshness" category:"advanced"`
+	MaxQueriersPerTenant              int            `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
+	QueryShardingTotalShards          int            `yaml:"query_sharding_total_shards" json:"query_sharding_total_shards"`
+	QueryShardingMaxShardedQueries    int            `yaml:"query_sharding_max_sharded_queries" json:"query_sharding_max_sharded_queries"`
+	QueryShardingTargetSeriesPerShard uint64         `yaml:"query_sharding_target_series_per_shard" json:"query_sharding_target_series_per_shard" category:"experimental"`
+	SplitInstantQueriesByInterval     model.Duration `yaml:"split_instant_queries_by_interval" json:"split_instant_queries_by_interval" category:"experimental"`
+	PartialResponsesEnabled           bool           `yaml:"partial_responses_enabled" json:"partial_responses_enabled" category:"experimental"`
 
 	// Query-frontend limits.
 	MaxTotalQueryLength model.Duration `yaml:"max_total_query_length,omitempty" json:"max_total_query_length,omitempty" category:"experimental"`
 
+	// Query-scheduler limits.
+	QueryPriorityClassWeights QueryPriorityClassWeights `yaml:"query_priority_class_weights" json:"query_priority_class_weights" category:"experimental"`
+
 	// Cardinality
 	CardinalityAnalysisEnabled                    bool `yaml:"cardinality_analysis_enabled" json:"cardinality_analysis_enabled"`
 	LabelNamesAndValuesResultsMaxSizeBytes        int  `yaml:"label_names_and_values_results_max_size_bytes" json:"label_names_and_values_results_max_size_bytes"`
@@ -140,6 +152,14 @@ type Limits struct {
 	CompactorTenantShardSize           int            `yaml:"compactor_tenant_shard_size" json:"compactor_tenant_shard_size"`
 	CompactorPartialBlockDeletionDelay model.Duration `yaml:"compactor_partial_block_deletion_delay" json:"compactor_partial_block_deletion_delay"`
 	CompactorBlockUploadEnabled        bool           `yaml:"compactor_block_upload_enabled" json:"compactor_block_upload_enabled"`
+	CompactorBlockUploadValidation     bool           `yaml:"compactor_block_upload_validation_enabled" json:"compactor_block_upload_validation_enabled" category:"experimental"`
+	CompactorBlockUploadVerifyChunks   bool           `yaml:"compactor_block_upload_verify_chunks" json:"compactor_block_upload_verify_chunks" category:"experimental"`
+	CompactorBlockRewriteEnabled       bool           `yaml:"compactor_block_rewrite_enabled" json:"compactor_block_rewrite_enabled" category:"experimental"`
+	CompactorMaxBlocksPerJob           int            `yaml:"compactor_max_blocks_per_job" json:"compactor_max_blocks_per_job" category:"experimental"`
+	CompactorDownsamplingEnabled       bool           `yaml:"compactor_downsampling_enabled" json:"compactor_downsampling_enabled" category:"experimental"`
+	CompactorDownsampled5mRetention    model.Duration `yaml:"compactor_downsampled_5m_blocks_retention_period" json:"compactor_downsampled_5m_blocks_retention_period" category:"experimental"`
+	CompactorDownsampled1hRetention    model.Duration `yaml:"compactor_downsampled_1h_blocks_retention_period" json:"compactor_downsampled_1h_blocks_retention_period" category:"experimental"`
+	CompactorRetentionRules            RetentionRules `yaml:"compactor_retention_rules" json:"compactor_retention_rules" doc:"nocli|description=List of retention rules applied to the series matching a selector. Series matching a rule are deleted by the compactor, and hidden from queries, once older than the rule period. When a series matches multiple rules, the first one wins. Series not matching any rule follow the blocks retention period." category:"experimental"`
 
 	// This config doesn't have a CLI flag registered here because they're registered in
 	// their own original config struct.
@@ -198,6 +218,8 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.MaxChunksPerQuery, MaxChunksPerQueryFlag, 2e6, "Maximum number of chunks that can be fetched in a single query from ingesters and long-term storage. This limit is enforced in the querier, ruler and store-gateway. 0 to disable.")
 	f.IntVar(&l.MaxFetchedSeriesPerQuery, MaxSeriesPerQueryFlag, 0, "The maximum number of unique series for which a query can fetch samples from each ingesters and storage. This limit is enforced in the querier and ruler. 0 to disable")
 	f.IntVar(&l.MaxFetchedChunkBytesPerQuery, MaxChunkBytesPerQueryFlag, 0, "The maximum size of all chunks in bytes that a query can fetch from each ingester and storage. This limit is enforced in the querier and ruler. 0 to disable.")
+	f.IntVar(&l.MaxReturnedSeriesPerQuery, MaxReturnedSeriesPerQueryFlag, 0, "The maximum number of series a query can return. This limit is enforced in the query-frontend on the merged query result, and in the querier on the result of the queries not received from the query-frontend. 0 to disable.")
+	f.IntVar(&l.MaxQueryResponseSizeBytes, MaxQueryResponseSizeFlag, 0, "The maximum size in bytes of the response of a query, measured on the JSON encoded query result before any HTTP compression. This limit is enforced in the query-frontend on the merged query result, and in the querier on the result of the queries not received from the query-frontend. 0 to disable.")
 	f.Var(&l.MaxQueryLength, maxQueryLengthFlag, "Limit the query time range (end - start time). This limit is enforced in the querier (on the query possibly split by the query-frontend) and ruler. 0 to disable.")
 	f.Var(&l.MaxQueryLookback, "querier.max-query-lookback", "Limit how long back data (series and metadata) can be queried, up until <lookback> duration ago. This limit is enforced in the query-frontend, querier and ruler. If the requested time range is outside the allowed range, the request will not fail but will be manipulated to only query data within the allowed time range. 0 to disable.")
 	f.IntVar(&l.MaxQueryParallelism, "querier.max-query-parallelism", 14, "Maximum number of split (by time) or partial (by shard) queries that will be scheduled in parallel by the query-frontend for a single input query. This limit is introduced to have a fairer query scheduling and avoid a single query over a large time range saturating all available queriers.")
@@ -210,7 +232,13 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.MaxQueriersPerTenant, "query-frontend.max-queriers-per-tenant", 0, "Maximum number of queriers that can handle requests for a single tenant. If set to 0 or value higher than number of available queriers, *all* queriers will handle requests for the tenant. Each frontend (or query-scheduler, if used) will select the same set of queriers for the same tenant (given that all queriers are connected to all frontends / query-schedulers). This option only works with queriers connecting to the query-frontend / query-scheduler, not when using downstream URL.")
 	f.IntVar(&l.QueryShardingTotalShards, "query-frontend.query-sharding-total-shards", 16, "The amount of shards to use when doing parallelisation via query sharding by tenant. 0 to disable query sharding for tenant. Query sharding implementation will adjust the number of query shards based on compactor shards. This allows querier to not search the blocks which cannot possibly have the series for given query shard.")
 	f.IntVar(&l.QueryShardingMaxShardedQueries, "query-frontend.query-sharding-max-sharded-queries", 128, "The max number of sharded queries that can be run for a given received query. 0 to disable limit.")
+	f.Uint64Var(&l.QueryShardingTargetSeriesPerShard, "query-frontend.query-sharding-target-series-per-shard", 0, "How many series a single sharded partial query should load at most. This is not a strict requirement guaranteed to be honoured by query sharding, but a hint given to the query sharding when the query execution is initially planned. The number of shards is chosen based on the series count estimated from previous executions of the same query, and it can be higher than the total shards, up to the max sharded queries limit (or the total shards if the max sharded queries limit is disabled). Requires the query results cache to be enabled. 0 to disable cardinality-based hints.")
 	f.Var(&l.SplitInstantQueriesByInterval, "query-frontend.split-instant-queries-by-interval", "Split instant queries by an interval and execute in parallel. 0 to disable it.")
+	f.BoolVar(&l.PartialResponsesEnabled, "querier.partial-responses-enabled", false, "Enable the partial response mode: when some blocks can't be queried from store-gateways (eg. store-gateways are unavailable or don't respond within -querier.store-gateway-query-timeout), the querier returns the results from ingesters and the store-gateways that answered, with warnings listing the non-queried blocks, instead of failing the query. Can be overridden on a per-request basis with the X-Mimir-Partial-Response header.")
+	if l.QueryPriorityClassWeights == nil {
+		l.QueryPriorityClassWeights = QueryPriorityClassWeights{}
+	}
+	f.Var(&l.QueryPriorityClassWeights, "query-scheduler.query-priority-class-weights", "Per-tenant weights of the query priority classes. Value is a map, where each key is a priority class and value is its weight (positive integer). On command line, this map is given in JSON format. When set, the query-scheduler classifies the tenant's queries into priority classes and dequeues each class proportionally to its weight, with a weight of 1 for classes not listed. When empty, the tenant's queries are dequeued in FIFO order. Allowed priority classes: "+strings.Join(allowedQueryPriorityClasses, ", ")+".")
 
 	f.Var(&l.RulerEvaluationDelay, "ruler.evaluation-delay-duration", "Duration to delay the evaluation of rules to ensure the underlying metrics have been pushed.")
 	f.IntVar(&l.RulerTenantShardSize, "ruler.tenant-shard-size", 0, "The tenant's shard size when sharding is used by ruler. Value of 0 disables shuffle sharding for the tenant, and tenant rules will be sharded across all ruler replicas.")
@@ -225,6 +253,13 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.CompactorTenantShardSize, "compactor.compactor-tenant-shard-size", 0, "Max number of compactors that can compact blocks for single tenant. 0 to disable the limit and use all compactors.")
 	f.Var(&l.CompactorPartialBlockDeletionDelay, "compactor.partial-block-deletion-delay", fmt.Sprintf("If a partial block (unfinished block without %s file) hasn't been modified for this time, it will be marked for deletion. The minimum accepted value is %s: a lower value will be ignored and the feature disabled. 0 to disable.", block.MetaFilename, MinCompactorPartialBlockDeletionDelay.String()))
 	f.BoolVar(&l.CompactorBlockUploadEnabled, "compactor.block-upload-enabled", false, "Enable block upload API for the tenant.")
+	f.BoolVar(&l.CompactorBlockUploadValidation, "compactor.block-upload-validation-enabled", false, "Enable the validation of the blocks uploaded with the block upload API for the tenant. When enabled, the validation runs asynchronously after the request to complete the block upload, which returns before the block upload is completed, and rejects blocks with an invalid index, series or labels.")
+	f.BoolVar(&l.CompactorBlockUploadVerifyChunks, "compactor.block-upload-verify-chunks", true, "Verify the chunks of the blocks uploaded with the block upload API for the tenant, checking their checksum and that their samples are within the block time range. Applies only when the block upload validation is enabled.")
+	f.BoolVar(&l.CompactorBlockRewriteEnabled, "compactor.block-rewrite-enabled", false, "Enable block rewrite API for the tenant. Block rewrite jobs relabel, drop or fix the series of the tenant's blocks.")
+	f.IntVar(&l.CompactorMaxBlocksPerJob, compactorMaxBlocksPerJobFlag, 0, "Maximum number of blocks compacted together by a single compaction job. When a job has more blocks, such as many overlapping out-of-order or backfilled blocks, only the oldest blocks up to the limit are compacted, and the remaining blocks are compacted with the result by the next jobs. 0 to disable the limit.")
+	f.BoolVar(&l.CompactorDownsamplingEnabled, "compactor.downsampling-enabled", false, "Enable downsampling of the tenant's blocks to 5m and 1h resolutions. Downsampled blocks are queried instead of raw blocks when the query step allows it.")
+	f.Var(&l.CompactorDownsampled5mRetention, "compactor.downsampled-5m-blocks-retention-period", "Delete downsampled blocks at 5m resolution containing samples older than the specified retention period. 0 to use the retention period of raw blocks.")
+	f.Var(&l.CompactorDownsampled1hRetention, "compactor.downsampled-1h-blocks-retention-period", "Delete downsampled blocks at 1h resolution containing samples older than the specified retention period. 0 to use the retention period of raw blocks.")
 
 	// Query-frontend.
 	f.Var(&l.MaxTotalQueryLength, maxTotalQueryLengthFlag, fmt.Sprintf("Limit the total query time range (end - start time). This limit is enforced in the query-frontend on the received query. Defaults to the value of -%s if set to 0.", maxQueryLengthFlag))
@@ -269,7 +304,7 @@ func (l *Limits) UnmarshalYAML(value *yaml.Node) error {
 		return err
 	}
 
-	return nil
+	return l.Validate()
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface.
@@ -292,6 +327,14 @@ func (l *Limits) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	return l.Validate()
+}
+
+// Validate returns an error if the limits are invalid.
+func (l *Limits) Validate() error {
+	if l.CompactorMaxBlocksPerJob < 0 || l.CompactorMaxBlocksPerJob == 1 {
+		return errInvalidCompactorMaxBlocksPerJob
+	}
 	return nil
 }
 
@@ -447,6 +490,16 @@ func (o *Overrides) MaxFetchedChunkBytesPerQuery(userID string) int {
 	return o.getOverridesForUser(userID).MaxFetchedChunkBytesPerQuery
 }
 
+// MaxReturnedSeriesPerQuery returns the maximum number of series a query can return.
+func (o *Overrides) MaxReturnedSeriesPerQuery(userID string) int {
+	return o.getOverridesForUser(userID).MaxReturnedSeriesPerQuery
+}
+
+// MaxQueryResponseSizeBytes returns the maximum size in bytes of the response of a query.
+func (o *Overrides) MaxQueryResponseSizeBytes(userID string) int {
+	return o.getOverridesForUser(userID).MaxQueryResponseSizeBytes
+}
+
 // MaxQueryLookback returns the max lookback period of queries.
 func (o *Overrides) MaxQueryLookback(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).MaxQueryLookback)
@@ -482,6 +535,17 @@ func (o *Overrides) MaxQueriersPerUser(userID string) int {
 	return o.getOverridesForUser(userID).MaxQueriersPerTenant
 }
 
+// QueryPriorityClassWeights returns the weights of the query priority classes for this user.
+// Returns an empty map if query priority classes are disabled for the user.
+func (o *Overrides) QueryPriorityClassWeights(userID string) map[string]int {
+	return o.getOverridesForUser(userID).QueryPriorityClassWeights
+}
+
+// PartialResponsesEnabled returns whether the querier should return partial responses when some blocks can't be queried.
+func (o *Overrides) PartialResponsesEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).PartialResponsesEnabled
+}
+
 // MaxQueryParallelism returns the limit to the number of split queries the
 // frontend will process in parallel.
 func (o *Overrides) MaxQueryParallelism(userID string) int {
@@ -500,6 +564,12 @@ func (o *Overrides) QueryShardingMaxShardedQueries(userID string) int {
 	return o.getOverridesForUser(userID).QueryShardingMaxShardedQueries
 }
 
+// QueryShardingTargetSeriesPerShard returns the target number of series each sharded query should fetch.
+// 0 to disable cardinality-based hints.
+func (o *Overrides) QueryShardingTargetSeriesPerShard(userID string) uint64 {
+	return o.getOverridesForUser(userID).QueryShardingTargetSeriesPerShard
+}
+
 // SplitInstantQueriesByInterval returns the split time interval to use when splitting an instant query
 // via the query-frontend. 0 to disable limit.
 func (o *Overrides) SplitInstantQueriesByInterval(userID string) time.Duration {
@@ -555,6 +625,65 @@ func (o *Overrides) CompactorBlocksRetentionPeriod(userID string) time.Duration
 	return time.Duration(o.getOverridesForUser(userID).CompactorBlocksRetentionPeriod)
 }
 
+// CompactorMaxBlocksPerJob returns the maximum number of blocks compacted together by a single compaction job
+// for a given user. 0 means no limit.
+func (o *Overrides) CompactorMaxBlocksPerJob(userID string) int {
+	return o.getOverridesForUser(userID).CompactorMaxBlocksPerJob
+}
+
+// CompactorDownsamplingEnabled returns whether downsampling of blocks is enabled for a given user.
+func (o *Overrides) CompactorDownsamplingEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).CompactorDownsamplingEnabled
+}
+
+// CompactorDownsampled5mBlocksRetentionPeriod returns the retention period of blocks downsampled
+// to 5m resolution for a given user. 0 means the raw blocks retention period applies.
+func (o *Overrides) CompactorDownsampled5mBlocksRetentionPeriod(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).CompactorDownsampled5mRetention)
+}
+
+// CompactorDownsampled1hBlocksRetentionPeriod returns the retention period of blocks downsampled
+// to 1h resolution for a given user. 0 means the raw blocks retention period applies.
+func (o *Overrides) CompactorDownsampled1hBlocksRetentionPeriod(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).CompactorDownsampled1hRetention)
+}
+
+// CompactorRetentionRules returns the per-series retention rules for a given user.
+func (o *Overrides) CompactorRetentionRules(userID string) RetentionRules {
+	return o.getOverridesForUser(userID).CompactorRetentionRules
+}
+
+// CompactorBlocksDefaultMaxRetentionPeriod returns the longest retention period among the raw and downsampled
+// blocks for a given user, which applies to the series not matching any retention rule. 0 means blocks are
+// retained forever.
+func (o *Overrides) CompactorBlocksDefaultMaxRetentionPeriod(userID string) time.Duration {
+	retention := o.CompactorBlocksRetentionPeriod(userID)
+	if retention == 0 || !o.CompactorDownsamplingEnabled(userID) {
+		return retention
+	}
+
+	for _, candidate := range []time.Duration{o.CompactorDownsampled5mBlocksRetentionPeriod(userID), o.CompactorDownsampled1hBlocksRetentionPeriod(userID)} {
+		if candidate > retention {
+			retention = candidate
+		}
+	}
+	return retention
+}
+
+// CompactorBlocksMaxRetentionPeriod returns the longest retention period among the raw and downsampled
+// blocks and the retention rules for a given user. 0 means blocks are retained forever.
+func (o *Overrides) CompactorBlocksMaxRetentionPeriod(userID string) time.Duration {
+	retention := o.CompactorBlocksDefaultMaxRetentionPeriod(userID)
+	if retention == 0 {
+		return retention
+	}
+
+	if rulesRetention := o.CompactorRetentionRules(userID).MaxPeriod(); rulesRetention > retention {
+		retention = rulesRetention
+	}
+	return retention
+}
+
 // CompactorSplitAndMergeShards returns the number of shards to use when splitting blocks.
 func (o *Overrides) CompactorSplitAndMergeShards(userID string) int {
 	return o.getOverridesForUser(userID).CompactorSplitAndMergeShards
@@ -584,6 +713,21 @@ func (o *Overrides) CompactorBlockUploadEnabled(tenantID string) bool {
 	return o.getOverridesForUser(tenantID).CompactorBlockUploadEnabled
 }
 
+// CompactorBlockUploadValidationEnabled returns whether the validation of uploaded blocks is enabled for a given user.
+func (o *Overrides) CompactorBlockUploadValidationEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).CompactorBlockUploadValidation
+}
+
+// CompactorBlockUploadVerifyChunks returns whether the chunks of uploaded blocks are verified for a given user.
+func (o *Overrides) CompactorBlockUploadVerifyChunks(userID string) bool {
+	return o.getOverridesForUser(userID).CompactorBlockUploadVerifyChunks
+}
+
+// CompactorBlockRewriteEnabled returns whether block rewrite is enabled for a certain tenant.
+func (o *Overrides) CompactorBlockRewriteEnabled(tenantID string) bool {
+	return o.getOverridesForUser(tenantID).CompactorBlockRewriteEnabled
+}
+
 // MetricRelabelConfigs returns the metric relabel configs for a given user.
 func (o *Overrides) MetricRelabelConfigs(userID string) []*relabel.Config {
 	return o.getOverridesForUser(userID).MetricRelabelConfigs
@@ -779,6 +923,24 @@ func SmallestPositiveNonZeroIntPerTenant(tenantIDs []string, f func(string) int)
 	return *result
 }
 
+// SmallestPositiveNonZeroUint64PerTenant is returning the minimal positive and
+// non-zero value of the supplied limit function for all given tenants. In many
+// limits a value of 0 means unlimited so the method will return 0 only if all
+// inputs have a limit of 0 or an empty tenant list is given.
+func SmallestPositiveNonZeroUint64PerTenant(tenantIDs []string, f func(string) uint64) uint64 {
+	var result *uint64
+	for _, tenantID := range tenantIDs {
+		v := f(tenantID)
+		if v > 0 && (result == nil || v < *result) {
+			result = &v
+		}
+	}
+	if result == nil {
+		return 0
+	}
+	return *result
+}
+
 // SmallestPositiveNonZeroDurationPerTenant is returning the minimal positive
 // and non-zero value of the supplied limit function for all given tenants. In
 // many limits a value of 0 means unlimited so the method will return 0 only if
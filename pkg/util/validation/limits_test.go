@@ -145,6 +145,36 @@ func TestLimitsAlwaysUsesPromDuration(t *testing.T) {
 	assert.Empty(t, badDurationType, "some Limits fields are using stdlib time.Duration instead of model.Duration")
 }
 
+func TestLimitsValidate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		maxBlocksPerJob int
+		expectedErr     error
+	}{
+		"max blocks per job disabled": {maxBlocksPerJob: 0},
+		"max blocks per job at the minimum": {maxBlocksPerJob: 2},
+		"max blocks per job of one block": {
+			maxBlocksPerJob: 1,
+			expectedErr:     errInvalidCompactorMaxBlocksPerJob,
+		},
+		"negative max blocks per job": {
+			maxBlocksPerJob: -1,
+			expectedErr:     errInvalidCompactorMaxBlocksPerJob,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			l := Limits{CompactorMaxBlocksPerJob: tc.maxBlocksPerJob}
+			assert.Equal(t, tc.expectedErr, l.Validate())
+		})
+	}
+
+	t.Run("invalid limits are rejected when loading them from YAML", func(t *testing.T) {
+		SetDefaultLimitsForYAMLUnmarshalling(Limits{})
+
+		l := Limits{}
+		assert.ErrorIs(t, yaml.Unmarshal([]byte(`compactor_max_blocks_per_job: 1`), &l), errInvalidCompactorMaxBlocksPerJob)
+	})
+}
+
 func TestMetricRelabelConfigLimitsLoadingFromYaml(t *testing.T) {
 	SetDefaultLimitsForYAMLUnmarshalling(Limits{})
 
@@ -233,6 +263,38 @@ func TestSmallestPositiveNonZeroIntPerTenant(t *testing.T) {
 	}
 }
 
+func TestSmallestPositiveNonZeroUint64PerTenant(t *testing.T) {
+	tenantLimits := map[string]*Limits{
+		"tenant-a": {
+			QueryShardingTargetSeriesPerShard: 5,
+		},
+		"tenant-b": {
+			QueryShardingTargetSeriesPerShard: 10,
+		},
+	}
+
+	defaults := Limits{
+		QueryShardingTargetSeriesPerShard: 0,
+	}
+	ov, err := NewOverrides(defaults, NewMockTenantLimits(tenantLimits))
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		tenantIDs []string
+		expLimit  uint64
+	}{
+		{tenantIDs: []string{}, expLimit: 0},
+		{tenantIDs: []string{"tenant-a"}, expLimit: 5},
+		{tenantIDs: []string{"tenant-b"}, expLimit: 10},
+		{tenantIDs: []string{"tenant-c"}, expLimit: 0},
+		{tenantIDs: []string{"tenant-a", "tenant-b"}, expLimit: 5},
+		{tenantIDs: []string{"tenant-c", "tenant-d", "tenant-e"}, expLimit: 0},
+		{tenantIDs: []string{"tenant-a", "tenant-b", "tenant-c"}, expLimit: 5},
+	} {
+		assert.Equal(t, tc.expLimit, SmallestPositiveNonZeroUint64PerTenant(tc.tenantIDs, ov.QueryShardingTargetSeriesPerShard))
+	}
+}
+
 func TestSmallestPositiveNonZeroDurationPerTenant(t *testing.T) {
 	tenantLimits := map[string]*Limits{
 		"tenant-a": {
@@ -297,6 +359,49 @@ func TestLargestPositiveNonZeroDurationPerTenant(t *testing.T) {
 	}
 }
 
+func TestCompactorBlocksMaxRetentionPeriod(t *testing.T) {
+	tenantLimits := map[string]*Limits{
+		"no-retention": {
+			CompactorDownsamplingEnabled:    true,
+			CompactorDownsampled1hRetention: model.Duration(365 * 24 * time.Hour),
+		},
+		"downsampling-disabled": {
+			CompactorBlocksRetentionPeriod:  model.Duration(30 * 24 * time.Hour),
+			CompactorDownsampled1hRetention: model.Duration(365 * 24 * time.Hour),
+		},
+		"downsampling-enabled": {
+			CompactorBlocksRetentionPeriod:  model.Duration(30 * 24 * time.Hour),
+			CompactorDownsamplingEnabled:    true,
+			CompactorDownsampled5mRetention: model.Duration(90 * 24 * time.Hour),
+			CompactorDownsampled1hRetention: model.Duration(365 * 24 * time.Hour),
+		},
+		"downsampling-enabled-without-retention": {
+			CompactorBlocksRetentionPeriod: model.Duration(30 * 24 * time.Hour),
+			CompactorDownsamplingEnabled:   true,
+		},
+		"retention-rules": {
+			CompactorBlocksRetentionPeriod: model.Duration(30 * 24 * time.Hour),
+			CompactorRetentionRules: RetentionRules{
+				{Selector: `{env="dev"}`, Period: model.Duration(7 * 24 * time.Hour)},
+				{Selector: `{__name__=~"slo_.*"}`, Period: model.Duration(2 * 365 * 24 * time.Hour)},
+			},
+		},
+	}
+
+	ov, err := NewOverrides(Limits{}, NewMockTenantLimits(tenantLimits))
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Duration(0), ov.CompactorBlocksMaxRetentionPeriod("no-retention"))
+	assert.Equal(t, 30*24*time.Hour, ov.CompactorBlocksMaxRetentionPeriod("downsampling-disabled"))
+	assert.Equal(t, 365*24*time.Hour, ov.CompactorBlocksMaxRetentionPeriod("downsampling-enabled"))
+	assert.Equal(t, 30*24*time.Hour, ov.CompactorBlocksMaxRetentionPeriod("downsampling-enabled-without-retention"))
+	assert.Equal(t, 2*365*24*time.Hour, ov.CompactorBlocksMaxRetentionPeriod("retention-rules"))
+
+	// The retention rules don't apply to the series not matching any of them.
+	assert.Equal(t, 365*24*time.Hour, ov.CompactorBlocksDefaultMaxRetentionPeriod("downsampling-enabled"))
+	assert.Equal(t, 30*24*time.Hour, ov.CompactorBlocksDefaultMaxRetentionPeriod("retention-rules"))
+}
+
 func TestMaxTotalQueryLengthWithoutDefault(t *testing.T) {
 	tenantLimits := map[string]*Limits{
 		"tenant-a": {
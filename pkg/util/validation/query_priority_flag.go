@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+// allowedQueryPriorityClasses are the priority classes the query-scheduler classifies queries into.
+var allowedQueryPriorityClasses = []string{
+	"ruler", "instant", "range", "long_range", "other",
+}
+
+// QueryPriorityClassWeights is a map of query priority class to its weight.
+type QueryPriorityClassWeights map[string]int
+
+// String implements flag.Value
+func (m QueryPriorityClassWeights) String() string {
+	out, err := json.Marshal(map[string]int(m))
+	if err != nil {
+		return fmt.Sprintf("failed to marshal: %v", err)
+	}
+	return string(out)
+}
+
+// Set implements flag.Value
+func (m *QueryPriorityClassWeights) Set(s string) error {
+	newMap := map[string]int{}
+	return m.replaceMap(json.Unmarshal([]byte(s), &newMap), newMap)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. The map is replaced rather than updated,
+// so that the per-tenant overrides never share the map of the default limits.
+func (m *QueryPriorityClassWeights) UnmarshalYAML(value *yaml.Node) error {
+	newMap := map[string]int{}
+	return m.replaceMap(value.DecodeWithOptions(newMap, yaml.DecodeOptions{KnownFields: true}), newMap)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *QueryPriorityClassWeights) UnmarshalJSON(data []byte) error {
+	newMap := map[string]int{}
+	return m.replaceMap(json.Unmarshal(data, &newMap), newMap)
+}
+
+func (m *QueryPriorityClassWeights) replaceMap(unmarshalErr error, newMap map[string]int) error {
+	if unmarshalErr != nil {
+		return unmarshalErr
+	}
+
+	for k, v := range newMap {
+		if !util.StringsContain(allowedQueryPriorityClasses, k) {
+			return errors.Errorf("unknown query priority class: %s", k)
+		}
+		if v <= 0 {
+			return errors.Errorf("the weight of the query priority class %s must be a positive integer", k)
+		}
+	}
+
+	*m = newMap
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (m QueryPriorityClassWeights) MarshalYAML() (interface{}, error) {
+	return map[string]int(m), nil
+}
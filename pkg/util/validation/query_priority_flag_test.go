@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestQueryPriorityClassWeights(t *testing.T) {
+	for name, tc := range map[string]struct {
+		args     []string
+		expected QueryPriorityClassWeights
+		error    string
+	}{
+		"basic test": {
+			args: []string{"-map-flag", "{\"ruler\": 4, \"long_range\": 1}"},
+			expected: QueryPriorityClassWeights{
+				"ruler":      4,
+				"long_range": 1,
+			},
+		},
+
+		"unknown priority class": {
+			args:  []string{"-map-flag", "{\"unknown\": 2 }"},
+			error: "invalid value \"{\\\"unknown\\\": 2 }\" for flag -map-flag: unknown query priority class: unknown",
+		},
+
+		"non positive weight": {
+			args:  []string{"-map-flag", "{\"range\": 0 }"},
+			error: "invalid value \"{\\\"range\\\": 0 }\" for flag -map-flag: the weight of the query priority class range must be a positive integer",
+		},
+
+		"parsing error": {
+			args:  []string{"-map-flag", "{\"hello\": ..."},
+			error: "invalid value \"{\\\"hello\\\": ...\" for flag -map-flag: invalid character '.' looking for beginning of value",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			v := QueryPriorityClassWeights{}
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(&bytes.Buffer{}) // otherwise errors would go to stderr.
+			fs.Var(&v, "map-flag", "Map flag, you can pass JSON into this")
+			err := fs.Parse(tc.args)
+
+			if tc.error != "" {
+				require.NotNil(t, err)
+				assert.Equal(t, tc.error, err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, v)
+			}
+		})
+	}
+}
+
+func TestQueryPriorityClassWeights_ShouldNotShareTheMapWithDefaults(t *testing.T) {
+	defaults := QueryPriorityClassWeights{"ruler": 4}
+
+	t.Run("yaml", func(t *testing.T) {
+		type config struct {
+			Weights QueryPriorityClassWeights `yaml:"weights"`
+		}
+
+		cfg := config{Weights: defaults}
+		require.NoError(t, yaml.Unmarshal([]byte("weights:\n  range: 2\n"), &cfg))
+
+		assert.Equal(t, QueryPriorityClassWeights{"range": 2}, cfg.Weights)
+		assert.Equal(t, QueryPriorityClassWeights{"ruler": 4}, defaults)
+
+		out, err := yaml.Marshal(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "weights:\n    range: 2\n", string(out))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		type config struct {
+			Weights QueryPriorityClassWeights `json:"weights"`
+		}
+
+		cfg := config{Weights: defaults}
+		require.NoError(t, json.Unmarshal([]byte(`{"weights": {"instant": 3}}`), &cfg))
+
+		assert.Equal(t, QueryPriorityClassWeights{"instant": 3}, cfg.Weights)
+		assert.Equal(t, QueryPriorityClassWeights{"ruler": 4}, defaults)
+	})
+}
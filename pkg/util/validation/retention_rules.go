@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// RetentionRule configures the retention period of the series matching a selector.
+type RetentionRule struct {
+	Selector string         `yaml:"selector" json:"selector" doc:"description=Series selector, for example {env=\"dev\"}. The series matching the selector are retained for the configured period."`
+	Period   model.Duration `yaml:"period" json:"period" doc:"description=Retention period of the series matching the selector. Must be greater than 0."`
+
+	matchers []*labels.Matcher
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (r *RetentionRule) UnmarshalYAML(value *yaml.Node) error {
+	type plain RetentionRule
+	if err := value.DecodeWithOptions((*plain)(r), yaml.DecodeOptions{KnownFields: true}); err != nil {
+		return err
+	}
+	return r.parse()
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *RetentionRule) UnmarshalJSON(data []byte) error {
+	type plain RetentionRule
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	return r.parse()
+}
+
+func (r *RetentionRule) parse() error {
+	matchers, err := parser.ParseMetricSelector(r.Selector)
+	if err != nil {
+		return errors.Wrapf(err, "invalid retention rule selector %q", r.Selector)
+	}
+	if r.Period <= 0 {
+		return errors.Errorf("invalid retention rule period for selector %q: must be greater than 0", r.Selector)
+	}
+
+	r.matchers = matchers
+	return nil
+}
+
+// Matchers returns the label matchers parsed from the rule selector.
+func (r RetentionRule) Matchers() []*labels.Matcher {
+	return r.matchers
+}
+
+// Matches returns whether the series with the given labels matches the rule selector.
+func (r RetentionRule) Matches(lset labels.Labels) bool {
+	for _, m := range r.matchers {
+		if !m.Matches(lset.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// RetentionRules is a list of retention rules. When a series matches multiple rules, the first one wins.
+type RetentionRules []RetentionRule
+
+// ExampleDoc provides an example doc for this config.
+func (r RetentionRules) ExampleDoc() (comment string, yaml interface{}) {
+	return `The following configuration retains the series of the dev environment for 7 days and the SLO series for 2 years,` +
+			` while the other series follow the blocks retention period.`,
+		[]map[string]string{
+			{"selector": `{env="dev"}`, "period": "7d"},
+			{"selector": `{__name__=~"slo_.*"}`, "period": "2y"},
+		}
+}
+
+// Match returns the first rule matching the series with the given labels, if any.
+func (r RetentionRules) Match(lset labels.Labels) (RetentionRule, bool) {
+	for _, rule := range r {
+		if rule.Matches(lset) {
+			return rule, true
+		}
+	}
+	return RetentionRule{}, false
+}
+
+// MaxPeriod returns the longest retention period among the rules, or 0 if there are no rules.
+func (r RetentionRules) MaxPeriod() time.Duration {
+	var period time.Duration
+	for _, rule := range r {
+		if p := time.Duration(rule.Period); p > period {
+			period = p
+		}
+	}
+	return period
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRetentionRules_Unmarshal(t *testing.T) {
+	for name, tc := range map[string]struct {
+		yaml  string
+		json  string
+		error string
+	}{
+		"valid rules": {
+			yaml: `
+- selector: '{env="dev"}'
+  period: 7d
+- selector: '{__name__=~"slo_.*"}'
+  period: 2y
+`,
+			json: `[{"selector": "{env=\"dev\"}", "period": "7d"}, {"selector": "{__name__=~\"slo_.*\"}", "period": "2y"}]`,
+		},
+		"invalid selector": {
+			yaml:  `[{selector: '{env=dev}', period: 7d}]`,
+			json:  `[{"selector": "{env=dev}", "period": "7d"}]`,
+			error: `invalid retention rule selector "{env=dev}"`,
+		},
+		"missing period": {
+			yaml:  `[{selector: '{env="dev"}'}]`,
+			json:  `[{"selector": "{env=\"dev\"}"}]`,
+			error: `invalid retention rule period for selector "{env=\"dev\"}": must be greater than 0`,
+		},
+		"unknown field": {
+			yaml:  `[{selector: '{env="dev"}', period: 7d, priority: 1}]`,
+			error: "field priority not found",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var fromYAML RetentionRules
+			err := yaml.Unmarshal([]byte(tc.yaml), &fromYAML)
+			if tc.error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.error)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, fromYAML, 2)
+				assert.Equal(t, model.Duration(7*24*time.Hour), fromYAML[0].Period)
+				assert.Len(t, fromYAML[0].Matchers(), 1)
+				assert.Len(t, fromYAML[1].Matchers(), 1)
+			}
+
+			if tc.json == "" {
+				return
+			}
+
+			var fromJSON RetentionRules
+			err = json.Unmarshal([]byte(tc.json), &fromJSON)
+			if tc.error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.error)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, fromYAML, fromJSON)
+			}
+		})
+	}
+}
+
+func TestRetentionRules_Match(t *testing.T) {
+	var rules RetentionRules
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- selector: '{env="dev"}'
+  period: 7d
+- selector: '{__name__=~"slo_.*"}'
+  period: 2y
+`), &rules))
+
+	assert.Equal(t, 2*365*24*time.Hour, rules.MaxPeriod())
+	assert.Equal(t, time.Duration(0), RetentionRules(nil).MaxPeriod())
+
+	for _, tc := range []struct {
+		lset           labels.Labels
+		expectedPeriod time.Duration
+		expectedMatch  bool
+	}{
+		{lset: labels.FromStrings("__name__", "up", "env", "dev"), expectedPeriod: 7 * 24 * time.Hour, expectedMatch: true},
+		{lset: labels.FromStrings("__name__", "slo_errors", "env", "prod"), expectedPeriod: 2 * 365 * 24 * time.Hour, expectedMatch: true},
+		// The first matching rule wins.
+		{lset: labels.FromStrings("__name__", "slo_errors", "env", "dev"), expectedPeriod: 7 * 24 * time.Hour, expectedMatch: true},
+		{lset: labels.FromStrings("__name__", "up", "env", "prod"), expectedMatch: false},
+	} {
+		rule, ok := rules.Match(tc.lset)
+		assert.Equal(t, tc.expectedMatch, ok, tc.lset.String())
+		assert.Equal(t, tc.expectedPeriod, time.Duration(rule.Period), tc.lset.String())
+	}
+}
@@ -340,6 +340,8 @@ func getFieldCustomType(t reflect.Type) (string, bool) {
 		return "url", true
 	case reflect.TypeOf(time.Duration(0)).String():
 		return "duration", true
+	case reflect.TypeOf(model.Duration(0)).String():
+		return "duration", true
 	case reflect.TypeOf(flagext.StringSliceCSV{}).String():
 		return "string", true
 	case reflect.TypeOf(flagext.CIDRSliceCSV{}).String():
@@ -420,6 +422,8 @@ func getCustomFieldType(t reflect.Type) (string, bool) {
 		return "url", true
 	case reflect.TypeOf(time.Duration(0)).String():
 		return "duration", true
+	case reflect.TypeOf(model.Duration(0)).String():
+		return "duration", true
 	case reflect.TypeOf(flagext.StringSliceCSV{}).String():
 		return "string", true
 	case reflect.TypeOf(flagext.CIDRSliceCSV{}).String():
@@ -459,6 +463,8 @@ func ReflectType(typ string) reflect.Type {
 		return reflect.TypeOf([]*relabel.Config{})
 	case "map of string to float64":
 		return reflect.TypeOf(map[string]float64{})
+	case "map of string to int":
+		return reflect.TypeOf(map[string]int{})
 	case "list of durations":
 		return reflect.TypeOf(tsdb.DurationList{})
 	case "map of string to validation.ForwardingRule":
@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	_ "embed" // Used to embed html template
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const mismatchFileExtension = ".json"
+
+var (
+	//go:embed mismatches.gohtml
+	mismatchesPageHTML     string
+	mismatchesPageTemplate = template.Must(template.New("mismatches").Parse(mismatchesPageHTML))
+
+	//go:embed mismatch.gohtml
+	mismatchPageHTML     string
+	mismatchPageTemplate = template.Must(template.New("mismatch").Parse(mismatchPageHTML))
+)
+
+// Mismatch is a request whose responses from the preferred and secondary backends didn't match.
+type Mismatch struct {
+	ID        string           `json:"id"`
+	Timestamp time.Time        `json:"timestamp"`
+	RouteName string           `json:"route_name"`
+	Method    string           `json:"method"`
+	Path      string           `json:"path"`
+	Query     string           `json:"query"`
+	Error     string           `json:"error"`
+	Expected  ArchivedResponse `json:"expected"`
+	Actual    ArchivedResponse `json:"actual"`
+}
+
+// ArchivedResponse is a backend response stored in the mismatches archive.
+type ArchivedResponse struct {
+	Backend string `json:"backend"`
+	Status  int    `json:"status"`
+	Body    string `json:"body"`
+}
+
+// PrettyBody returns the body indented, if it's JSON, otherwise as is.
+func (r ArchivedResponse) PrettyBody() string {
+	buf := bytes.Buffer{}
+	if err := json.Indent(&buf, []byte(r.Body), "", "  "); err != nil {
+		return r.Body
+	}
+	return buf.String()
+}
+
+// MismatchArchive stores the mismatches on disk, one JSON file per mismatch, keeping
+// only the most recent ones.
+type MismatchArchive struct {
+	dir        string
+	maxEntries int
+	logger     log.Logger
+
+	// Serializes writes, so that the old entries are removed consistently.
+	mtx sync.Mutex
+}
+
+func NewMismatchArchive(dir string, maxEntries int, logger log.Logger) (*MismatchArchive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "failed to create the mismatches archive directory")
+	}
+
+	return &MismatchArchive{
+		dir:        dir,
+		maxEntries: maxEntries,
+		logger:     logger,
+	}, nil
+}
+
+// Store adds the mismatch to the archive, assigning it an ID, and removes the oldest
+// mismatches exceeding the max number of entries.
+func (a *MismatchArchive) Store(m Mismatch) error {
+	// ULIDs are lexicographically sortable by time, so the file names sort by age.
+	m.ID = ulid.MustNew(ulid.Timestamp(m.Timestamp), crand.Reader).String()
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	// Write to a temporary file first, so that partially written mismatches are never listed.
+	tmpPath := filepath.Join(a.dir, m.ID+".tmp")
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(a.dir, m.ID+mismatchFileExtension)); err != nil {
+		return err
+	}
+
+	return a.removeOldest()
+}
+
+func (a *MismatchArchive) removeOldest() error {
+	if a.maxEntries <= 0 {
+		return nil
+	}
+
+	ids, err := a.listIDs()
+	if err != nil {
+		return err
+	}
+
+	for len(ids) > a.maxEntries {
+		if err := os.Remove(filepath.Join(a.dir, ids[0]+mismatchFileExtension)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		ids = ids[1:]
+	}
+
+	return nil
+}
+
+// listIDs returns the IDs of the archived mismatches, from the oldest.
+func (a *MismatchArchive) listIDs() ([]string, error) {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, mismatchFileExtension) {
+			continue
+		}
+
+		id := strings.TrimSuffix(name, mismatchFileExtension)
+		if _, err := ulid.Parse(id); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Get returns the mismatch with the input ID.
+func (a *MismatchArchive) Get(id string) (Mismatch, error) {
+	// The ID is used to build the file path, so it must be validated.
+	if _, err := ulid.Parse(id); err != nil {
+		return Mismatch{}, errors.Wrapf(err, "invalid mismatch ID %s", id)
+	}
+
+	data, err := os.ReadFile(filepath.Join(a.dir, id+mismatchFileExtension))
+	if err != nil {
+		return Mismatch{}, err
+	}
+
+	var m Mismatch
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Mismatch{}, errors.Wrapf(err, "failed to unmarshal mismatch %s", id)
+	}
+	return m, nil
+}
+
+// List returns the archived mismatches, from the most recent. The responses are not returned.
+func (a *MismatchArchive) List() ([]Mismatch, error) {
+	ids, err := a.listIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Mismatch, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		m, err := a.Get(ids[i])
+		if os.IsNotExist(errors.Cause(err)) {
+			// Removed in the meanwhile.
+			continue
+		}
+		if err != nil {
+			level.Warn(a.logger).Log("msg", "failed to read archived mismatch", "id", ids[i], "err", err)
+			continue
+		}
+
+		m.Expected.Body = ""
+		m.Actual.Body = ""
+		out = append(out, m)
+	}
+
+	return out, nil
+}
+
+type mismatchesPageContents struct {
+	Now        time.Time  `json:"now"`
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// ListHandler serves the list of archived mismatches.
+func (a *MismatchArchive) ListHandler(w http.ResponseWriter, r *http.Request) {
+	mismatches, err := a.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	util.RenderHTTPResponse(w, mismatchesPageContents{
+		Now:        time.Now(),
+		Mismatches: mismatches,
+	}, mismatchesPageTemplate, r)
+}
+
+// MismatchHandler serves an archived mismatch, including the backend responses.
+func (a *MismatchArchive) MismatchHandler(w http.ResponseWriter, r *http.Request) {
+	m, err := a.Get(mux.Vars(r)["id"])
+	if os.IsNotExist(errors.Cause(err)) {
+		http.Error(w, "mismatch not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	util.RenderHTTPResponse(w, m, mismatchPageTemplate, r)
+}
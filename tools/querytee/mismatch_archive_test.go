@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMismatchArchive(t *testing.T) {
+	archive, err := NewMismatchArchive(t.TempDir(), 2, log.NewNopLogger())
+	require.NoError(t, err)
+
+	now := time.Now()
+	for i, query := range []string{"query=up", "query=down", "query=sideways"} {
+		require.NoError(t, archive.Store(Mismatch{
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+			RouteName: "api_v1_query",
+			Method:    "GET",
+			Path:      "/api/v1/query",
+			Query:     query,
+			Error:     "expected 1 metrics but got 0",
+			Expected:  ArchivedResponse{Backend: "backend-1", Status: 200, Body: `{"status":"success"}`},
+			Actual:    ArchivedResponse{Backend: "backend-2", Status: 200, Body: `{"status":"error"}`},
+		}))
+	}
+
+	// The oldest mismatch has been removed, and the most recent is listed first.
+	list, err := archive.List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "query=sideways", list[0].Query)
+	assert.Equal(t, "query=down", list[1].Query)
+	assert.Empty(t, list[0].Expected.Body)
+
+	m, err := archive.Get(list[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "query=sideways", m.Query)
+	assert.Equal(t, `{"status":"success"}`, m.Expected.Body)
+	assert.Equal(t, "{\n  \"status\": \"success\"\n}", m.Expected.PrettyBody())
+
+	_, err = archive.Get("../../etc/passwd")
+	require.Error(t, err)
+
+	router := mux.NewRouter()
+	router.Path("/mismatches").HandlerFunc(archive.ListHandler)
+	router.Path("/mismatches/{id}").HandlerFunc(archive.MismatchHandler)
+
+	tests := map[string]struct {
+		path             string
+		expectedStatus   int
+		expectedContains string
+	}{
+		"list": {
+			path:             "/mismatches",
+			expectedStatus:   http.StatusOK,
+			expectedContains: "query=down",
+		},
+		"mismatch": {
+			path:             "/mismatches/" + list[0].ID,
+			expectedStatus:   http.StatusOK,
+			expectedContains: "expected 1 metrics but got 0",
+		},
+		"unknown mismatch": {
+			path:           "/mismatches/01GC8K6Z1QJ2RZ4K8N6T5V0M5X",
+			expectedStatus: http.StatusNotFound,
+		},
+		"invalid mismatch ID": {
+			path:           "/mismatches/invalid",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", testData.path, nil))
+
+			assert.Equal(t, testData.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), testData.expectedContains)
+		})
+	}
+}
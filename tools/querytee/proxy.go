@@ -18,6 +18,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/weaveworks/common/server"
@@ -36,6 +37,11 @@ type ProxyConfig struct {
 	UseRelativeError               bool
 	PassThroughNonRegisteredRoutes bool
 	SkipRecentSamples              time.Duration
+	CompareIgnoreSeries            flagext.StringSlice
+	MismatchesArchiveDir           string
+	MismatchesArchiveMaxEntries    int
+	ShadowMode                     bool
+	ShadowSamplePercentage         float64
 }
 
 func (cfg *ProxyConfig) RegisterFlags(f *flag.FlagSet) {
@@ -48,6 +54,11 @@ func (cfg *ProxyConfig) RegisterFlags(f *flag.FlagSet) {
 	f.Float64Var(&cfg.ValueComparisonTolerance, "proxy.value-comparison-tolerance", 0.000001, "The tolerance to apply when comparing floating point values in the responses. 0 to disable tolerance and require exact match (not recommended).")
 	f.BoolVar(&cfg.UseRelativeError, "proxy.compare-use-relative-error", false, "Use relative error tolerance when comparing floating point values.")
 	f.DurationVar(&cfg.SkipRecentSamples, "proxy.compare-skip-recent-samples", 60*time.Second, "The window from now to skip comparing samples. 0 to disable.")
+	f.Var(&cfg.CompareIgnoreSeries, "proxy.compare-ignore-series", "Series selector of the series to exclude when comparing responses, like {job=\"test\"}. Can be specified multiple times.")
+	f.StringVar(&cfg.MismatchesArchiveDir, "proxy.mismatches-archive-dir", "", "Directory where the requests whose responses don't match are stored together with the responses, and can be browsed at the /mismatches page. Empty to disable the archive.")
+	f.IntVar(&cfg.MismatchesArchiveMaxEntries, "proxy.mismatches-archive-max-entries", 1000, "Maximum number of mismatches kept in the archive. The oldest ones are removed first. 0 to disable the limit.")
+	f.BoolVar(&cfg.ShadowMode, "proxy.shadow-mode", false, "Send back to the client the response of the preferred backend as soon as it's received, without waiting for the secondary backends, which receive only a sample of the requests configured via -proxy.shadow-sample-percentage.")
+	f.Float64Var(&cfg.ShadowSamplePercentage, "proxy.shadow-sample-percentage", 100, "The percentage of requests sent to the secondary backends when the shadow mode is enabled. Valid values are between 0 and 100.")
 	f.BoolVar(&cfg.PassThroughNonRegisteredRoutes, "proxy.passthrough-non-registered-routes", false, "Passthrough requests for non-registered routes to preferred backend.")
 }
 
@@ -66,6 +77,9 @@ type Proxy struct {
 	metrics    *ProxyMetrics
 	routes     []Route
 
+	// The archive where the mismatches are stored, if enabled.
+	mismatchArchive *MismatchArchive
+
 	// The HTTP and gRPC servers used to run the proxy service.
 	server *server.Server
 
@@ -78,6 +92,22 @@ func NewProxy(cfg ProxyConfig, logger log.Logger, routes []Route, registerer pro
 		return nil, fmt.Errorf("when enabling comparison of results -backend.preferred flag must be set to hostname of preferred backend")
 	}
 
+	if cfg.ShadowMode && cfg.PreferredBackend == "" {
+		return nil, fmt.Errorf("when enabling the shadow mode -backend.preferred flag must be set to hostname of preferred backend")
+	}
+
+	if cfg.ShadowSamplePercentage < 0 || cfg.ShadowSamplePercentage > 100 {
+		return nil, fmt.Errorf("the -proxy.shadow-sample-percentage flag must be between 0 and 100")
+	}
+
+	if cfg.MismatchesArchiveDir != "" && !cfg.CompareResponses {
+		return nil, fmt.Errorf("when enabling the mismatches archive -proxy.compare-responses flag must be set")
+	}
+
+	if _, err := ParseSeriesSelectors(cfg.CompareIgnoreSeries); err != nil {
+		return nil, err
+	}
+
 	if cfg.PassThroughNonRegisteredRoutes && cfg.PreferredBackend == "" {
 		return nil, fmt.Errorf("when enabling passthrough for non-registered routes -backend.preferred flag must be set to hostname of backend where those requests needs to be passed")
 	}
@@ -143,6 +173,14 @@ func NewProxy(cfg ProxyConfig, logger log.Logger, routes []Route, registerer pro
 		return nil, fmt.Errorf("when enabling comparison of results number of backends should be 2 exactly")
 	}
 
+	if cfg.MismatchesArchiveDir != "" {
+		archive, err := NewMismatchArchive(cfg.MismatchesArchiveDir, cfg.MismatchesArchiveMaxEntries, logger)
+		if err != nil {
+			return nil, err
+		}
+		p.mismatchArchive = archive
+	}
+
 	// At least 2 backends are suggested
 	if len(p.backends) < 2 {
 		level.Warn(p.logger).Log("msg", "The proxy is running with only 1 backend. At least 2 backends are required to fulfil the purpose of the proxy and compare results.")
@@ -191,7 +229,12 @@ func (p *Proxy) Start() error {
 		if p.cfg.CompareResponses {
 			comparator = route.ResponseComparator
 		}
-		router.Path(route.Path).Methods(route.Methods...).Handler(NewProxyEndpoint(p.backends, route.RouteName, p.metrics, p.logger, comparator))
+		router.Path(route.Path).Methods(route.Methods...).Handler(NewProxyEndpoint(p.backends, route.RouteName, p.metrics, p.logger, comparator, p.mismatchArchive, p.cfg.ShadowMode, p.cfg.ShadowSamplePercentage))
+	}
+
+	if p.mismatchArchive != nil {
+		router.Path("/mismatches").Methods("GET").HandlerFunc(p.mismatchArchive.ListHandler)
+		router.Path("/mismatches/{id}").Methods("GET").HandlerFunc(p.mismatchArchive.MismatchHandler)
 	}
 
 	if p.cfg.PassThroughNonRegisteredRoutes {
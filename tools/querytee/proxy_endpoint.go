@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"strconv"
 	"sync"
@@ -30,14 +31,22 @@ type ProxyEndpoint struct {
 	logger     log.Logger
 	comparator ResponsesComparator
 
+	// The archive where the mismatches are stored. Nil if disabled.
+	mismatchArchive *MismatchArchive
+
 	// Whether for this endpoint there's a preferred backend configured.
 	hasPreferredBackend bool
 
+	// Whether the response of the preferred backend is sent back to the client without
+	// waiting for the secondary backends, and the percentage of requests sent to them.
+	shadowMode             bool
+	shadowSamplePercentage float64
+
 	// The route name used to track metrics.
 	routeName string
 }
 
-func NewProxyEndpoint(backends []*ProxyBackend, routeName string, metrics *ProxyMetrics, logger log.Logger, comparator ResponsesComparator) *ProxyEndpoint {
+func NewProxyEndpoint(backends []*ProxyBackend, routeName string, metrics *ProxyMetrics, logger log.Logger, comparator ResponsesComparator, mismatchArchive *MismatchArchive, shadowMode bool, shadowSamplePercentage float64) *ProxyEndpoint {
 	hasPreferredBackend := false
 	for _, backend := range backends {
 		if backend.preferred {
@@ -47,22 +56,36 @@ func NewProxyEndpoint(backends []*ProxyBackend, routeName string, metrics *Proxy
 	}
 
 	return &ProxyEndpoint{
-		backends:            backends,
-		routeName:           routeName,
-		metrics:             metrics,
-		logger:              logger,
-		comparator:          comparator,
-		hasPreferredBackend: hasPreferredBackend,
+		backends:               backends,
+		routeName:              routeName,
+		metrics:                metrics,
+		logger:                 logger,
+		comparator:             comparator,
+		mismatchArchive:        mismatchArchive,
+		hasPreferredBackend:    hasPreferredBackend,
+		shadowMode:             shadowMode,
+		shadowSamplePercentage: shadowSamplePercentage,
 	}
 }
 
 func (p *ProxyEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	backends := p.backends
+	if p.shadowMode && !p.sampleShadowRequest() {
+		backends = p.preferredBackends()
+	}
+
 	// Send the same request to all backends.
-	resCh := make(chan *backendResponse, len(p.backends))
-	go p.executeBackendRequests(r, resCh)
+	resCh := make(chan *backendResponse, len(backends))
+	go p.executeBackendRequests(r, backends, resCh)
 
-	// Wait for the first response that's feasible to be sent back to the client.
-	downstreamRes := p.waitBackendResponseForDownstream(resCh)
+	var downstreamRes *backendResponse
+	if p.shadowMode {
+		// Don't wait for the secondary backends, which are only shadowing the traffic.
+		downstreamRes = p.waitPreferredBackendResponse(resCh)
+	} else {
+		// Wait for the first response that's feasible to be sent back to the client.
+		downstreamRes = p.waitBackendResponseForDownstream(resCh)
+	}
 
 	if downstreamRes.err != nil {
 		http.Error(w, downstreamRes.err.Error(), http.StatusInternalServerError)
@@ -76,12 +99,26 @@ func (p *ProxyEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	p.metrics.responsesTotal.WithLabelValues(downstreamRes.backend.name, r.Method, p.routeName).Inc()
 }
 
-func (p *ProxyEndpoint) executeBackendRequests(r *http.Request, resCh chan *backendResponse) {
+// sampleShadowRequest returns whether the request should be sent to the secondary backends too.
+func (p *ProxyEndpoint) sampleShadowRequest() bool {
+	return rand.Float64()*100 < p.shadowSamplePercentage
+}
+
+func (p *ProxyEndpoint) preferredBackends() []*ProxyBackend {
+	for _, b := range p.backends {
+		if b.preferred {
+			return []*ProxyBackend{b}
+		}
+	}
+	return p.backends
+}
+
+func (p *ProxyEndpoint) executeBackendRequests(r *http.Request, backends []*ProxyBackend, resCh chan *backendResponse) {
 	var (
 		wg           = sync.WaitGroup{}
 		err          error
 		body         []byte
-		responses    = make([]*backendResponse, 0, len(p.backends))
+		responses    = make([]*backendResponse, 0, len(backends))
 		responsesMtx = sync.Mutex{}
 		query        = r.URL.RawQuery
 	)
@@ -105,8 +142,8 @@ func (p *ProxyEndpoint) executeBackendRequests(r *http.Request, resCh chan *back
 
 	level.Debug(p.logger).Log("msg", "Received request", "path", r.URL.Path, "query", query)
 
-	wg.Add(len(p.backends))
-	for _, b := range p.backends {
+	wg.Add(len(backends))
+	for _, b := range backends {
 		b := b
 
 		go func() {
@@ -127,6 +164,7 @@ func (p *ProxyEndpoint) executeBackendRequests(r *http.Request, resCh chan *back
 				status:  status,
 				body:    body,
 				err:     err,
+				elapsed: elapsed,
 			}
 
 			// Log with a level based on the backend response.
@@ -138,12 +176,10 @@ func (p *ProxyEndpoint) executeBackendRequests(r *http.Request, resCh chan *back
 			lvl(p.logger).Log("msg", "Backend response", "path", r.URL.Path, "query", query, "backend", b.name, "status", status, "elapsed", elapsed)
 			p.metrics.requestDuration.WithLabelValues(res.backend.name, r.Method, p.routeName, strconv.Itoa(res.statusCode())).Observe(elapsed.Seconds())
 
-			// Keep track of the response if required.
-			if p.comparator != nil {
-				responsesMtx.Lock()
-				responses = append(responses, res)
-				responsesMtx.Unlock()
-			}
+			// Keep track of the response, to compare it with the other backends.
+			responsesMtx.Lock()
+			responses = append(responses, res)
+			responsesMtx.Unlock()
 
 			resCh <- res
 		}()
@@ -153,8 +189,10 @@ func (p *ProxyEndpoint) executeBackendRequests(r *http.Request, resCh chan *back
 	wg.Wait()
 	close(resCh)
 
-	// Compare responses.
-	if p.comparator != nil {
+	p.trackLatencyRatios(responses)
+
+	// Compare responses. When in shadow mode, the secondary backends may have not received the request.
+	if p.comparator != nil && len(responses) == 2 {
 		expectedResponse := responses[0]
 		actualResponse := responses[1]
 		if responses[1].backend.preferred {
@@ -167,12 +205,70 @@ func (p *ProxyEndpoint) executeBackendRequests(r *http.Request, resCh chan *back
 			level.Error(util_log.Logger).Log("msg", "response comparison failed", "route-name", p.routeName,
 				"query", r.URL.RawQuery, "err", err)
 			result = comparisonFailed
+
+			if p.mismatchArchive != nil {
+				p.archiveMismatch(r, query, expectedResponse, actualResponse, err)
+			}
 		}
 
 		p.metrics.responsesComparedTotal.WithLabelValues(p.routeName, result).Inc()
 	}
 }
 
+func (p *ProxyEndpoint) archiveMismatch(r *http.Request, query string, expectedResponse, actualResponse *backendResponse, comparisonErr error) {
+	err := p.mismatchArchive.Store(Mismatch{
+		Timestamp: time.Now(),
+		RouteName: p.routeName,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Query:     query,
+		Error:     comparisonErr.Error(),
+		Expected:  newArchivedResponse(expectedResponse),
+		Actual:    newArchivedResponse(actualResponse),
+	})
+	if err != nil {
+		level.Warn(p.logger).Log("msg", "Unable to archive responses mismatch", "route-name", p.routeName, "err", err)
+	}
+}
+
+// trackLatencyRatios tracks the ratio between the latency of each secondary backend and the preferred one.
+// Failed requests are not tracked, because their latency is not comparable.
+func (p *ProxyEndpoint) trackLatencyRatios(responses []*backendResponse) {
+	var preferredRes *backendResponse
+	for _, res := range responses {
+		if res.backend.preferred {
+			preferredRes = res
+		}
+	}
+	if preferredRes == nil || !preferredRes.succeeded() || preferredRes.elapsed <= 0 {
+		return
+	}
+
+	for _, res := range responses {
+		if res.backend.preferred || !res.succeeded() {
+			continue
+		}
+		p.metrics.latencyRatio.WithLabelValues(res.backend.name, p.routeName).Observe(res.elapsed.Seconds() / preferredRes.elapsed.Seconds())
+	}
+}
+
+// waitPreferredBackendResponse returns the response of the preferred backend, regardless of whether it succeeded.
+func (p *ProxyEndpoint) waitPreferredBackendResponse(resCh chan *backendResponse) *backendResponse {
+	var first *backendResponse
+
+	for res := range resCh {
+		if res.backend.preferred {
+			return res
+		}
+		if first == nil {
+			first = res
+		}
+	}
+
+	// Should never happen, because the request is always sent to the preferred backend.
+	return first
+}
+
 func (p *ProxyEndpoint) waitBackendResponseForDownstream(resCh chan *backendResponse) *backendResponse {
 	var (
 		responses                 = make([]*backendResponse, 0, len(p.backends))
@@ -230,6 +326,19 @@ type backendResponse struct {
 	status  int
 	body    []byte
 	err     error
+	elapsed time.Duration
+}
+
+func newArchivedResponse(r *backendResponse) ArchivedResponse {
+	res := ArchivedResponse{
+		Backend: r.backend.name,
+		Status:  r.statusCode(),
+		Body:    string(r.body),
+	}
+	if r.err != nil {
+		res.Body = r.err.Error()
+	}
+	return res
 }
 
 func (r *backendResponse) succeeded() bool {
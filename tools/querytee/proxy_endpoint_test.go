@@ -17,6 +17,8 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
@@ -100,7 +102,7 @@ func Test_ProxyEndpoint_waitBackendResponseForDownstream(t *testing.T) {
 		testData := testData
 
 		t.Run(testName, func(t *testing.T) {
-			endpoint := NewProxyEndpoint(testData.backends, "test", NewProxyMetrics(nil), log.NewNopLogger(), nil)
+			endpoint := NewProxyEndpoint(testData.backends, "test", NewProxyMetrics(nil), log.NewNopLogger(), nil, nil, false, 0)
 
 			// Send the responses from a dedicated goroutine.
 			resCh := make(chan *backendResponse)
@@ -144,7 +146,7 @@ func Test_ProxyEndpoint_Requests(t *testing.T) {
 		NewProxyBackend("backend-1", backendURL1, time.Second, true),
 		NewProxyBackend("backend-2", backendURL2, time.Second, false),
 	}
-	endpoint := NewProxyEndpoint(backends, "test", NewProxyMetrics(nil), log.NewNopLogger(), nil)
+	endpoint := NewProxyEndpoint(backends, "test", NewProxyMetrics(nil), log.NewNopLogger(), nil, nil, false, 0)
 
 	for _, tc := range []struct {
 		name    string
@@ -227,6 +229,130 @@ func Test_ProxyEndpoint_Requests(t *testing.T) {
 	}
 }
 
+func Test_ProxyEndpoint_MismatchArchive(t *testing.T) {
+	backend1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":["a","b"]}`))
+	}))
+	defer backend1.Close()
+	backendURL1, err := url.Parse(backend1.URL)
+	require.NoError(t, err)
+
+	backend2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":["a"]}`))
+	}))
+	defer backend2.Close()
+	backendURL2, err := url.Parse(backend2.URL)
+	require.NoError(t, err)
+
+	archive, err := NewMismatchArchive(t.TempDir(), 0, log.NewNopLogger())
+	require.NoError(t, err)
+
+	backends := []*ProxyBackend{
+		NewProxyBackend("backend-1", backendURL1, time.Second, true),
+		NewProxyBackend("backend-2", backendURL2, time.Second, false),
+	}
+	endpoint := NewProxyEndpoint(backends, "api_v1_labels", NewProxyMetrics(nil), log.NewNopLogger(), NewLabelsComparator(), archive, false, 0)
+
+	r, err := http.NewRequest("GET", "http://test/api/v1/labels?match[]=up", nil)
+	require.NoError(t, err)
+	endpoint.ServeHTTP(httptest.NewRecorder(), r)
+
+	// The comparison runs once all backends responded, after the response is sent back.
+	var list []Mismatch
+	require.Eventually(t, func() bool {
+		list, err = archive.List()
+		return err == nil && len(list) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	m, err := archive.Get(list[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "api_v1_labels", m.RouteName)
+	assert.Equal(t, "/api/v1/labels", m.Path)
+	assert.Equal(t, "match[]=up", m.Query)
+	assert.Equal(t, `expected value "b" missing from actual response`, m.Error)
+	assert.Equal(t, ArchivedResponse{Backend: "backend-1", Status: 200, Body: `{"status":"success","data":["a","b"]}`}, m.Expected)
+	assert.Equal(t, ArchivedResponse{Backend: "backend-2", Status: 200, Body: `{"status":"success","data":["a"]}`}, m.Actual)
+}
+
+func Test_ProxyEndpoint_ShadowMode(t *testing.T) {
+	tests := map[string]struct {
+		preferredStatus           int
+		samplePercentage          float64
+		expectedSecondaryRequests uint64
+		expectedLatencyRatios     int
+	}{
+		"the preferred backend response is returned without waiting for the secondary backend": {
+			preferredStatus:           200,
+			samplePercentage:          100,
+			expectedSecondaryRequests: 1,
+			expectedLatencyRatios:     1,
+		},
+		"the preferred backend response is returned even if not successful": {
+			preferredStatus:           500,
+			samplePercentage:          100,
+			expectedSecondaryRequests: 1,
+			expectedLatencyRatios:     0,
+		},
+		"requests which are not sampled are not sent to the secondary backend": {
+			preferredStatus:           200,
+			samplePercentage:          0,
+			expectedSecondaryRequests: 0,
+			expectedLatencyRatios:     0,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			preferred := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(testData.preferredStatus)
+				_, _ = w.Write([]byte("preferred"))
+			}))
+			defer preferred.Close()
+			preferredURL, err := url.Parse(preferred.URL)
+			require.NoError(t, err)
+
+			var (
+				secondaryRequests atomic.Uint64
+				releaseSecondary  = make(chan struct{})
+			)
+			secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				secondaryRequests.Inc()
+				<-releaseSecondary
+				_, _ = w.Write([]byte("secondary"))
+			}))
+			defer secondary.Close()
+			secondaryURL, err := url.Parse(secondary.URL)
+			require.NoError(t, err)
+
+			metrics := NewProxyMetrics(prometheus.NewPedanticRegistry())
+			backends := []*ProxyBackend{
+				NewProxyBackend("backend-1", preferredURL, 5*time.Second, true),
+				NewProxyBackend("backend-2", secondaryURL, 5*time.Second, false),
+			}
+			endpoint := NewProxyEndpoint(backends, "test", metrics, log.NewNopLogger(), nil, nil, true, testData.samplePercentage)
+
+			r, err := http.NewRequest("GET", "http://test/api/v1/test", nil)
+			require.NoError(t, err)
+
+			// The secondary backend is blocked until released, so the response must come from the preferred one.
+			w := httptest.NewRecorder()
+			endpoint.ServeHTTP(w, r)
+			assert.Equal(t, testData.preferredStatus, w.Code)
+			assert.Equal(t, "preferred", w.Body.String())
+
+			close(releaseSecondary)
+
+			// The latency ratio is tracked once all backends responded.
+			require.Eventually(t, func() bool {
+				return testutil.CollectAndCount(metrics.requestDuration) == 1+int(testData.expectedSecondaryRequests) &&
+					testutil.CollectAndCount(metrics.latencyRatio) == testData.expectedLatencyRatios
+			}, 5*time.Second, 10*time.Millisecond)
+
+			assert.Equal(t, testData.expectedSecondaryRequests, secondaryRequests.Load())
+		})
+	}
+}
+
 func Test_backendResponse_succeeded(t *testing.T) {
 	tests := map[string]struct {
 		resStatus int
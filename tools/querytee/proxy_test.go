@@ -41,6 +41,26 @@ func Test_NewProxy(t *testing.T) {
 	p, err := NewProxy(cfg, log.NewNopLogger(), testRoutes, nil)
 	assert.Equal(t, errMinBackends, err)
 	assert.Nil(t, p)
+
+	cfg = ProxyConfig{BackendEndpoints: "http://backend-1,http://backend-2", MismatchesArchiveDir: t.TempDir()}
+	p, err = NewProxy(cfg, log.NewNopLogger(), testRoutes, nil)
+	assert.EqualError(t, err, "when enabling the mismatches archive -proxy.compare-responses flag must be set")
+	assert.Nil(t, p)
+
+	cfg = ProxyConfig{BackendEndpoints: "http://backend-1,http://backend-2", CompareIgnoreSeries: []string{`{job=}`}}
+	p, err = NewProxy(cfg, log.NewNopLogger(), testRoutes, nil)
+	assert.ErrorContains(t, err, "invalid series selector {job=}")
+	assert.Nil(t, p)
+
+	cfg = ProxyConfig{BackendEndpoints: "http://backend-1,http://backend-2", ShadowMode: true}
+	p, err = NewProxy(cfg, log.NewNopLogger(), testRoutes, nil)
+	assert.EqualError(t, err, "when enabling the shadow mode -backend.preferred flag must be set to hostname of preferred backend")
+	assert.Nil(t, p)
+
+	cfg = ProxyConfig{BackendEndpoints: "http://backend-1,http://backend-2", PreferredBackend: "backend-1", ShadowMode: true, ShadowSamplePercentage: 101}
+	p, err = NewProxy(cfg, log.NewNopLogger(), testRoutes, nil)
+	assert.EqualError(t, err, "the -proxy.shadow-sample-percentage flag must be between 0 and 100")
+	assert.Nil(t, p)
 }
 
 func Test_Proxy_RequestsForwarding(t *testing.T) {
@@ -14,6 +14,8 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
 
 	util_log "github.com/grafana/mimir/pkg/util/log"
 )
@@ -33,6 +35,70 @@ type SampleComparisonOptions struct {
 	Tolerance         float64
 	UseRelativeError  bool
 	SkipRecentSamples time.Duration
+
+	// IgnoreSeries are the selectors of the series excluded from the comparison. A series
+	// is excluded if it matches all the matchers of at least one selector.
+	IgnoreSeries [][]*labels.Matcher
+}
+
+// ParseSeriesSelectors parses the input series selectors, like {job="test"}.
+func ParseSeriesSelectors(selectors []string) ([][]*labels.Matcher, error) {
+	out := make([][]*labels.Matcher, 0, len(selectors))
+	for _, selector := range selectors {
+		matchers, err := parser.ParseMetricSelector(selector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid series selector %s", selector)
+		}
+		out = append(out, matchers)
+	}
+	return out, nil
+}
+
+// isIgnored returns whether the series must be excluded from the comparison.
+func (opts SampleComparisonOptions) isIgnored(metric model.Metric) bool {
+	for _, matchers := range opts.IgnoreSeries {
+		if matchesAll(metric, matchers) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAll(metric model.Metric, matchers []*labels.Matcher) bool {
+	for _, m := range matchers {
+		if !m.Matches(string(metric[model.LabelName(m.Name)])) {
+			return false
+		}
+	}
+	return true
+}
+
+func filterIgnoredMatrix(matrix model.Matrix, opts SampleComparisonOptions) model.Matrix {
+	if len(opts.IgnoreSeries) == 0 {
+		return matrix
+	}
+
+	filtered := matrix[:0]
+	for _, stream := range matrix {
+		if !opts.isIgnored(stream.Metric) {
+			filtered = append(filtered, stream)
+		}
+	}
+	return filtered
+}
+
+func filterIgnoredVector(vector model.Vector, opts SampleComparisonOptions) model.Vector {
+	if len(opts.IgnoreSeries) == 0 {
+		return vector
+	}
+
+	filtered := vector[:0]
+	for _, sample := range vector {
+		if !opts.isIgnored(sample.Metric) {
+			filtered = append(filtered, sample)
+		}
+	}
+	return filtered
 }
 
 func NewSamplesComparator(opts SampleComparisonOptions) *SamplesComparator {
@@ -97,6 +163,9 @@ func compareMatrix(expectedRaw, actualRaw json.RawMessage, opts SampleComparison
 		return err
 	}
 
+	expected = filterIgnoredMatrix(expected, opts)
+	actual = filterIgnoredMatrix(actual, opts)
+
 	if len(expected) != len(actual) {
 		return fmt.Errorf("expected %d metrics but got %d", len(expected),
 			len(actual))
@@ -153,6 +222,9 @@ func compareVector(expectedRaw, actualRaw json.RawMessage, opts SampleComparison
 		return err
 	}
 
+	expected = filterIgnoredVector(expected, opts)
+	actual = filterIgnoredVector(actual, opts)
+
 	if len(expected) != len(actual) {
 		return fmt.Errorf("expected %d metrics but got %d", len(expected),
 			len(actual))
@@ -239,6 +239,7 @@ func TestCompareSamplesResponse(t *testing.T) {
 		err               error
 		useRelativeError  bool
 		skipRecentSamples time.Duration
+		ignoreSeries      []string
 	}{
 		{
 			name: "difference in response status",
@@ -406,14 +407,55 @@ func TestCompareSamplesResponse(t *testing.T) {
 						}`),
 			skipRecentSamples: time.Hour,
 		},
+		{
+			name: "should not fail when vector series only differing in ignored series",
+			expected: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"vector","result":[{"metric":{"foo":"bar"},"value":[1,"1"]},{"metric":{"foo":"ignored"},"value":[1,"1"]}]}
+						}`),
+			actual: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"vector","result":[{"metric":{"foo":"bar"},"value":[1,"1"]},{"metric":{"foo":"ignored","job":"test"},"value":[1,"2"]}]}
+						}`),
+			ignoreSeries: []string{`{foo="ignored"}`},
+		},
+		{
+			name: "should not fail when matrix series only differing in ignored series",
+			expected: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"]]},{"metric":{"foo":"ignored"},"values":[[1,"1"]]}]}
+						}`),
+			actual: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"]]}]}
+						}`),
+			ignoreSeries: []string{`{job="other"}`, `{foo=~"ign.*"}`},
+		},
+		{
+			name: "should fail when series differ and are not ignored",
+			expected: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"]]},{"metric":{"foo":"ignored"},"values":[[1,"1"]]}]}
+						}`),
+			actual: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"]]}]}
+						}`),
+			ignoreSeries: []string{`{foo="ignored",job="test"}`},
+			err:          errors.New("expected 2 metrics but got 1"),
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
+			ignoreSeries, err := ParseSeriesSelectors(tc.ignoreSeries)
+			require.NoError(t, err)
+
 			samplesComparator := NewSamplesComparator(SampleComparisonOptions{
 				Tolerance:         float64(tc.tolerance),
 				UseRelativeError:  bool(tc.useRelativeError),
 				SkipRecentSamples: tc.skipRecentSamples,
+				IgnoreSeries:      ignoreSeries,
 			})
-			err := samplesComparator.Compare(tc.expected, tc.actual)
+			err = samplesComparator.Compare(tc.expected, tc.actual)
 			if tc.err == nil {
 				require.NoError(t, err)
 				return
@@ -423,3 +465,13 @@ func TestCompareSamplesResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSeriesSelectors(t *testing.T) {
+	selectors, err := ParseSeriesSelectors([]string{`{job="test"}`, `up{instance=~"host.*"}`})
+	require.NoError(t, err)
+	require.Len(t, selectors, 2)
+	require.Len(t, selectors[1], 2)
+
+	_, err = ParseSeriesSelectors([]string{`{job=}`})
+	require.ErrorContains(t, err, "invalid series selector {job=}")
+}
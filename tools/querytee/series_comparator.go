@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+// LabelsResponse is the response of the /api/v1/labels and /api/v1/label/{name}/values routes.
+type LabelsResponse struct {
+	Status string
+	Data   []string
+}
+
+// LabelsComparator compares the responses of the /api/v1/labels and /api/v1/label/{name}/values
+// routes. The order of label names and values is not relevant for the comparison.
+type LabelsComparator struct{}
+
+func NewLabelsComparator() *LabelsComparator {
+	return &LabelsComparator{}
+}
+
+func (c *LabelsComparator) Compare(expectedResponse, actualResponse []byte) error {
+	var expected, actual LabelsResponse
+
+	err := json.Unmarshal(expectedResponse, &expected)
+	if err != nil {
+		return errors.Wrap(err, "unable to unmarshal expected response")
+	}
+
+	err = json.Unmarshal(actualResponse, &actual)
+	if err != nil {
+		return errors.Wrap(err, "unable to unmarshal actual response")
+	}
+
+	if expected.Status != actual.Status {
+		return fmt.Errorf("expected status %s but got %s", expected.Status, actual.Status)
+	}
+
+	actualValues := make(map[string]struct{}, len(actual.Data))
+	for _, v := range actual.Data {
+		actualValues[v] = struct{}{}
+	}
+
+	// Check for missing values first, because it gives a more useful error than the count mismatch.
+	for _, v := range expected.Data {
+		if _, ok := actualValues[v]; !ok {
+			return fmt.Errorf("expected value %q missing from actual response", v)
+		}
+	}
+
+	if len(expected.Data) != len(actual.Data) {
+		return fmt.Errorf("expected %d values but got %d", len(expected.Data), len(actual.Data))
+	}
+
+	return nil
+}
+
+// SeriesResponse is the response of the /api/v1/series route.
+type SeriesResponse struct {
+	Status string
+	Data   []model.Metric
+}
+
+// SeriesComparator compares the responses of the /api/v1/series route. The order of series
+// is not relevant for the comparison.
+type SeriesComparator struct {
+	opts SampleComparisonOptions
+}
+
+// NewSeriesComparator returns a SeriesComparator. Only the IgnoreSeries option is used
+// by the comparator.
+func NewSeriesComparator(opts SampleComparisonOptions) *SeriesComparator {
+	return &SeriesComparator{opts: opts}
+}
+
+func (c *SeriesComparator) Compare(expectedResponse, actualResponse []byte) error {
+	var expected, actual SeriesResponse
+
+	err := json.Unmarshal(expectedResponse, &expected)
+	if err != nil {
+		return errors.Wrap(err, "unable to unmarshal expected response")
+	}
+
+	err = json.Unmarshal(actualResponse, &actual)
+	if err != nil {
+		return errors.Wrap(err, "unable to unmarshal actual response")
+	}
+
+	if expected.Status != actual.Status {
+		return fmt.Errorf("expected status %s but got %s", expected.Status, actual.Status)
+	}
+
+	expectedSeries := c.fingerprints(expected.Data)
+	actualSeries := c.fingerprints(actual.Data)
+
+	missing := model.Fingerprints{}
+	for fp := range expectedSeries {
+		if _, ok := actualSeries[fp]; !ok {
+			missing = append(missing, fp)
+		}
+	}
+	if len(missing) > 0 {
+		// Report the first missing series in fingerprint order, to get a deterministic error.
+		sort.Sort(missing)
+		return fmt.Errorf("expected series %s missing from actual response", expectedSeries[missing[0]])
+	}
+
+	if len(expectedSeries) != len(actualSeries) {
+		return fmt.Errorf("expected %d series but got %d", len(expectedSeries), len(actualSeries))
+	}
+
+	return nil
+}
+
+// fingerprints returns the series which are not ignored, by fingerprint.
+func (c *SeriesComparator) fingerprints(series []model.Metric) map[model.Fingerprint]model.Metric {
+	out := make(map[model.Fingerprint]model.Metric, len(series))
+	for _, metric := range series {
+		if !c.opts.isIgnored(metric) {
+			out[metric.Fingerprint()] = metric
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelsComparator_Compare(t *testing.T) {
+	tests := map[string]struct {
+		expected    string
+		actual      string
+		expectedErr string
+	}{
+		"same values in the same order": {
+			expected: `{"status":"success","data":["a","b","c"]}`,
+			actual:   `{"status":"success","data":["a","b","c"]}`,
+		},
+		"same values in a different order": {
+			expected: `{"status":"success","data":["a","b","c"]}`,
+			actual:   `{"status":"success","data":["c","a","b"]}`,
+		},
+		"difference in response status": {
+			expected:    `{"status":"success","data":["a"]}`,
+			actual:      `{"status":"error"}`,
+			expectedErr: "expected status success but got error",
+		},
+		"value missing from actual response": {
+			expected:    `{"status":"success","data":["a","b","c"]}`,
+			actual:      `{"status":"success","data":["c","a"]}`,
+			expectedErr: `expected value "b" missing from actual response`,
+		},
+		"extra value in actual response": {
+			expected:    `{"status":"success","data":["a","b"]}`,
+			actual:      `{"status":"success","data":["c","a","b"]}`,
+			expectedErr: "expected 2 values but got 3",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := NewLabelsComparator().Compare([]byte(testData.expected), []byte(testData.actual))
+			if testData.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, testData.expectedErr)
+			}
+		})
+	}
+}
+
+func TestSeriesComparator_Compare(t *testing.T) {
+	tests := map[string]struct {
+		expected     string
+		actual       string
+		ignoreSeries []string
+		expectedErr  string
+	}{
+		"same series in a different order": {
+			expected: `{"status":"success","data":[{"__name__":"up","job":"a"},{"__name__":"up","job":"b"}]}`,
+			actual:   `{"status":"success","data":[{"__name__":"up","job":"b"},{"__name__":"up","job":"a"}]}`,
+		},
+		"difference in response status": {
+			expected:    `{"status":"success","data":[]}`,
+			actual:      `{"status":"error"}`,
+			expectedErr: "expected status success but got error",
+		},
+		"series missing from actual response": {
+			expected:    `{"status":"success","data":[{"__name__":"up","job":"a"},{"__name__":"up","job":"b"}]}`,
+			actual:      `{"status":"success","data":[{"__name__":"up","job":"a"}]}`,
+			expectedErr: `expected series up{job="b"} missing from actual response`,
+		},
+		"extra series in actual response": {
+			expected:    `{"status":"success","data":[{"__name__":"up","job":"a"}]}`,
+			actual:      `{"status":"success","data":[{"__name__":"up","job":"a"},{"__name__":"up","job":"b"}]}`,
+			expectedErr: "expected 1 series but got 2",
+		},
+		"series only differing in ignored series": {
+			expected:     `{"status":"success","data":[{"__name__":"up","job":"a"}]}`,
+			actual:       `{"status":"success","data":[{"__name__":"up","job":"a"},{"__name__":"up","job":"b"}]}`,
+			ignoreSeries: []string{`{job="b"}`},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ignoreSeries, err := ParseSeriesSelectors(testData.ignoreSeries)
+			require.NoError(t, err)
+
+			err = NewSeriesComparator(SampleComparisonOptions{IgnoreSeries: ignoreSeries}).Compare([]byte(testData.expected), []byte(testData.actual))
+			if testData.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, testData.expectedErr)
+			}
+		})
+	}
+}